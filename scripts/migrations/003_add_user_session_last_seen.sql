@@ -0,0 +1,13 @@
+-- Migration 003: Track session activity
+-- Adds last-seen tracking so users can review their active sessions
+
+ALTER TABLE sa3d.user_sessions ADD COLUMN last_seen_at TIMESTAMP WITH TIME ZONE;
+
+-- Sessions listed per user are filtered by activity
+CREATE INDEX idx_user_sessions_user_active ON sa3d.user_sessions (user_id, is_active, expires_at);
+
+DO $$
+BEGIN
+    RAISE NOTICE 'Migration 003 completed: Session last-seen tracking added';
+END
+$$;
@@ -0,0 +1,11 @@
+-- Migration 004: Record analyzer and metrics versions
+-- Results produced by different tool versions are not directly comparable
+
+ALTER TABLE sa3d.analyses ADD COLUMN analyzer_version VARCHAR(50);
+ALTER TABLE sa3d.analyses ADD COLUMN metrics_version VARCHAR(50);
+
+DO $$
+BEGIN
+    RAISE NOTICE 'Migration 004 completed: Analysis version tracking added';
+END
+$$;
@@ -0,0 +1,10 @@
+-- Migration 005: Per-project language selection
+-- Projects can restrict analysis to a subset of languages
+
+ALTER TABLE sa3d.projects ADD COLUMN enabled_languages TEXT;
+
+DO $$
+BEGIN
+    RAISE NOTICE 'Migration 005 completed: Project language selection added';
+END
+$$;
@@ -0,0 +1,11 @@
+-- Migration 006: Per-project analysis notifications
+-- Summaries of finished analyses go to these addresses and webhooks
+
+ALTER TABLE sa3d.projects ADD COLUMN notification_emails TEXT;
+ALTER TABLE sa3d.projects ADD COLUMN notification_webhooks TEXT;
+
+DO $$
+BEGIN
+    RAISE NOTICE 'Migration 006 completed: Project notification settings added';
+END
+$$;
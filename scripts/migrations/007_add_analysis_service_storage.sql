@@ -0,0 +1,45 @@
+-- Migration 007: Analysis service storage
+-- Columns and tables the analysis service reads and writes directly
+
+-- Analyses are soft-deleted with their project
+ALTER TABLE sa3d.analyses ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- Progress of running analyses and the job options they were started with
+ALTER TABLE sa3d.analyses ADD COLUMN progress INTEGER DEFAULT 0;
+ALTER TABLE sa3d.analyses ADD COLUMN total_files INTEGER DEFAULT 0;
+ALTER TABLE sa3d.analyses ADD COLUMN job_details JSONB DEFAULT '{}';
+ALTER TABLE sa3d.analyses ADD COLUMN aggregate_metrics JSONB;
+
+CREATE INDEX idx_analyses_project_started_at ON sa3d.analyses (project_id, started_at DESC) WHERE deleted_at IS NULL;
+
+-- Issues below this severity are left out of aggregate counts
+ALTER TABLE sa3d.projects ADD COLUMN min_severity VARCHAR(20);
+
+-- Source files analyses read
+CREATE TABLE sa3d.project_files (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    project_id UUID NOT NULL REFERENCES sa3d.projects(id) ON DELETE CASCADE,
+    path VARCHAR(1000) NOT NULL,
+    content BYTEA,
+    size BIGINT NOT NULL DEFAULT 0,
+    modified_at TIMESTAMP WITH TIME ZONE,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX idx_project_files_project_path ON sa3d.project_files (project_id, path) WHERE deleted_at IS NULL;
+
+CREATE TRIGGER update_project_files_updated_at
+    BEFORE UPDATE ON sa3d.project_files
+    FOR EACH ROW EXECUTE FUNCTION sa3d.update_updated_at_column();
+
+-- The complete per-file result, as returned by the analysis service
+ALTER TABLE sa3d.analysis_files ADD COLUMN result JSONB;
+
+DO $$
+BEGIN
+    RAISE NOTICE 'Migration 007 completed: Analysis service storage added';
+END
+$$;
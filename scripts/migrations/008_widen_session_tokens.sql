@@ -0,0 +1,13 @@
+-- Migration 008: Widen session tokens
+-- Access tokens are signed JWTs, longer than the 255 characters the session
+-- columns held
+
+ALTER TABLE sa3d.user_sessions
+    ALTER COLUMN session_token TYPE TEXT,
+    ALTER COLUMN refresh_token TYPE TEXT;
+
+DO $$
+BEGIN
+    RAISE NOTICE 'Migration 008 completed: Session tokens widened';
+END
+$$;
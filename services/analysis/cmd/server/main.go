@@ -1,16 +1,25 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/config"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/handler"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/storage"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
 )
 
 func main() {
@@ -18,66 +27,163 @@ func main() {
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.JSONFormatter{})
 	logger.SetLevel(logrus.InfoLevel)
+	logger.AddHook(utils.MaskingHook{})
 
-	// Initialize configuration
-	viper.SetDefault("ANALYSIS_SERVER_PORT", "8080")
-	viper.SetDefault("LOG_LEVEL", "info")
-	viper.AutomaticEnv()
+	// Load configuration from config.yaml and the environment
+	cfg, err := config.Load(viper.New())
+	if err != nil {
+		logger.Fatalf("Failed to load configuration: %v", err)
+	}
 
 	// Set log level from config
-	if level, err := logrus.ParseLevel(viper.GetString("LOG_LEVEL")); err == nil {
+	if level, err := logrus.ParseLevel(cfg.LogLevel); err == nil {
 		logger.SetLevel(level)
 	}
+	utils.LogStartup(logger, "analysis", cfg)
+
+	// Connect to the database holding projects and analyses
+	database, err := services.NewDatabaseServiceWithConfig(services.DatabaseConfig{
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.Name,
+		SSLMode:  cfg.Database.SSLMode,
+		// The replica port defaults to the primary's, as in SecretManager
+		ReplicaHost: cfg.Database.ReplicaHost,
+		ReplicaPort: cmp.Or(cfg.Database.ReplicaPort, cfg.Database.Port),
+	}, logger)
+	if err != nil {
+		logger.Fatalf("Failed to connect to database: %v", err)
+	}
 
-	// Initialize Gin router
-	gin.SetMode(gin.ReleaseMode)
-	router := gin.New()
-	router.Use(gin.Recovery())
-
-	// Add basic middleware for logging
-	router.Use(func(c *gin.Context) {
-		start := time.Now()
-		c.Next()
-		logger.WithFields(logrus.Fields{
-			"method":    c.Request.Method,
-			"path":      c.Request.URL.Path,
-			"status":    c.Writer.Status(),
-			"duration":  time.Since(start),
-			"client_ip": c.ClientIP(),
-		}).Info("Request processed")
+	// Initialize Redis client for job status and result caching
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr(),
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
 	})
+	if err := redisClient.Ping(context.Background()).Err(); err != nil {
+		logger.Fatalf("Failed to connect to Redis: %v", err)
+	}
 
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status": "healthy",
-			"service": "analysis-service",
-			"timestamp": time.Now().UTC().Format(time.RFC3339),
-		})
-	})
+	// Analysis events can be turned off for deployments without Kafka; the
+	// service is then built without a writer and drops events
+	var kafkaWriter *kafka.Writer
+	if cfg.Kafka.Enabled {
+		kafkaWriter = &kafka.Writer{
+			Addr:     kafka.TCP(cfg.Kafka.Brokers...),
+			Topic:    cfg.Kafka.Topic,
+			Balancer: &kafka.Hash{},
+			// The service retries failed writes itself, keeping each
+			// analysis's events in order
+			MaxAttempts: 1,
+		}
+	} else {
+		logger.Info("Analysis event publishing disabled")
+	}
 
-	// Basic info endpoint
-	router.GET("/info", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"service": "analysis-service",
-			"version": "1.0.0",
-			"status": "running",
-		})
-	})
+	// Rule overrides were checked when the configuration was validated
+	ruleEngine, err := cfg.RuleEngine()
+	if err != nil {
+		logger.Fatalf("Invalid rule configuration: %v", err)
+	}
 
-	// Placeholder analysis endpoint
-	router.POST("/analyze", func(c *gin.Context) {
-		c.JSON(http.StatusNotImplemented, gin.H{
-			"error": "Analysis service implementation in progress",
-			"message": "This endpoint will be implemented in the next development phase",
-		})
-	})
+	// The source backend was checked when the configuration was validated
+	sources, err := cfg.SourceStore()
+	if err != nil {
+		logger.Fatalf("Invalid source backend: %v", err)
+	}
+
+	analysisRepo := storage.NewAnalysisRepository(database.DB)
+	metricsRepo := storage.NewMetricsRepositoryWithReplica(database.DB, database.ReadDB())
+	projectRepo := storage.NewProjectRepositoryWithSources(database.DB, database.ReadDB(), sources)
+	projectRepo.SetInlineLimit(cfg.Sources.InlineLimit)
+	analysisService := service.NewAnalysisService(
+		projectRepo,
+		analysisRepo,
+		metricsRepo,
+		redisClient,
+		kafkaWriter,
+		logger,
+	)
+	analysisService.SetRuleEngine(ruleEngine)
+	analysisService.SetCalculatorConfig(cfg.CalculatorConfig())
+	analysisService.SetMaxDuration(cfg.Timeouts.Analysis)
+	analysisService.SetFileTimeout(cfg.Timeouts.File)
+	analysisService.SetMaxConcurrentAnalyses(cfg.Workers.MaxConcurrent)
+	analysisService.SetMaxConcurrentFiles(cfg.Workers.MaxConcurrentFiles)
+	analysisService.SetFileLimit(cfg.FileLimit())
+	advisories, err := cfg.AdvisoryDatabase()
+	if err != nil {
+		logger.Fatalf("Failed to load advisories: %v", err)
+	}
+	if advisories != nil {
+		analysisService.SetAdvisories(advisories)
+		logger.Infof("Checking dependencies against %d advisories", advisories.Len())
+	}
+	if dispatcher := cfg.NotificationDispatcher(logger); dispatcher != nil {
+		analysisService.SetNotificationDispatcher(dispatcher)
+		logger.Info("Notifying project recipients of finished analyses")
+	}
+
+	// Feature flags come from the config and FEATURE_* variables and are
+	// overridden at runtime by the feature_flags Redis hash
+	features, err := services.LoadFeatureFlags(cfg.Features, os.Environ())
+	if err != nil {
+		logger.Fatalf("Invalid feature flags: %v", err)
+	}
+	analysisService.SetFeatureFlags(features)
+	loadFeatureFlags := func(ctx context.Context) (map[string]string, error) {
+		return redisClient.HGetAll(ctx, services.FeatureFlagsRedisKey).Result()
+	}
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	defer stopRefresh()
+	if cfg.FeatureRefreshInterval > 0 {
+		go features.RunRefresh(refreshCtx, loadFeatureFlags, cfg.FeatureRefreshInterval, logger)
+	} else if err := features.Refresh(refreshCtx, loadFeatureFlags); err != nil {
+		logger.Warnf("Failed to load feature flags from Redis: %v", err)
+	}
+	if cfg.Kafka.Enabled {
+		// Serialization for published analysis events ("json" or "protobuf")
+		eventCodec, err := cfg.EventCodec()
+		if err != nil {
+			logger.Fatalf("Invalid event configuration: %v", err)
+		}
+		analysisService.SetEventCodec(eventCodec)
+		analysisService.SetEventRetry(cfg.EventRetry())
+		analysisService.SetEventBuffer(cfg.Kafka.Buffer)
+		logger.Infof("Publishing analysis events as %s", eventCodec.Format())
+
+		// Readiness reports unreachable brokers, and events are held
+		// until they recover
+		kafkaHealth := cfg.KafkaHealth()
+		analysisService.SetKafkaHealth(kafkaHealth)
+		if cfg.Kafka.Health.Interval > 0 {
+			go kafkaHealth.Run(refreshCtx, cfg.Kafka.Health.Interval, logger)
+		}
+	}
+
+	// Analyses running longer than this are cancelled and marked failed; 0 disables the limit
+	logger.Infof("Maximum analysis duration: %s", cfg.Timeouts.Analysis)
+
+	// Analyses beyond this many wait in a priority queue; 0 disables the limit
+	logger.Infof("Maximum concurrent analyses: %d", cfg.Workers.MaxConcurrent)
+	logger.Infof("Maximum concurrently analyzed files: %d", cmp.Or(cfg.Workers.MaxConcurrentFiles, service.DefaultMaxConcurrentFiles()))
+
+	metricsService := service.NewMetricsService(analysisRepo, metricsRepo, logger)
+
+	// Initialize Gin router
+	gin.SetMode(gin.ReleaseMode)
+	router := handler.NewRouter(analysisService, metricsService, logger)
 
 	// Start server
-	port := viper.GetString("ANALYSIS_SERVER_PORT")
+	port := cfg.Server.Port
 	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: router,
+		Addr:         ":" + port,
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
 	}
 
 	// Graceful shutdown
@@ -95,12 +201,28 @@ func main() {
 
 	logger.Info("Shutting down server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v", err)
+		logger.Errorf("Server forced to shutdown: %v", err)
+	}
+
+	// Close connections once requests have drained and queued events are written
+	if err := analysisService.FlushEvents(ctx); err != nil {
+		logger.Warnf("Analysis events left unpublished: %v", err)
+	}
+	if kafkaWriter != nil {
+		if err := kafkaWriter.Close(); err != nil {
+			logger.Errorf("Failed to close Kafka writer: %v", err)
+		}
+	}
+	if err := redisClient.Close(); err != nil {
+		logger.Errorf("Failed to close Redis client: %v", err)
+	}
+	if err := database.Close(); err != nil {
+		logger.Errorf("Failed to close database: %v", err)
 	}
 
 	logger.Info("Server shutdown complete")
-}
\ No newline at end of file
+}
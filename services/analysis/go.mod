@@ -3,6 +3,7 @@ module github.com/sa3d-modernized/sa3d/services/analysis
 go 1.23
 
 require (
+	github.com/alicebob/miniredis/v2 v2.33.0
 	github.com/gin-gonic/gin v1.10.0
 	github.com/google/uuid v1.6.0
 	github.com/jackc/pgx/v5 v5.7.2
@@ -14,4 +15,6 @@ require (
 	go.opentelemetry.io/otel v1.33.0
 	go.opentelemetry.io/otel/trace v1.33.0
 	golang.org/x/sync v0.10.0
+	gorm.io/driver/postgres v1.6.0
+	gorm.io/gorm v1.25.12
 )
\ No newline at end of file
@@ -5,8 +5,14 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
+// AnalyzerVersion identifies the parsing and extraction logic. Bump it
+// whenever a change would alter analysis output so cached results are
+// invalidated.
+const AnalyzerVersion = "1.9.0"
+
 // Language represents a programming language
 type Language string
 
@@ -23,12 +29,17 @@ const (
 // AnalysisResult contains the parsed AST and metadata
 type AnalysisResult struct {
 	Language     Language
+	// Package is the package or module the file declares, where the language has one
+	Package      string
 	AST          interface{} // Language-specific AST
 	Functions    []Function
 	Classes      []Class
 	Imports      []Import
 	Comments     []Comment
 	Errors       []ParseError
+	// Truncated is set when the analyzer ran out of its time budget and
+	// the declarations above are only the ones found before it did
+	Truncated    bool
 }
 
 // Function represents a function/method in the code
@@ -42,6 +53,15 @@ type Function struct {
 	IsPublic       bool
 	IsTest         bool
 	Documentation  string
+	// IsAbstract marks a signature without a body, such as an interface method
+	IsAbstract     bool
+	// PromotedFrom names the embedded type a method was promoted from
+	PromotedFrom   string
+	// Receiver names the type a method belongs to; for a method listed
+	// among a file's functions, that type is declared in another file
+	Receiver       string
+	TypeParams     []TypeParameter
+	Calls          []string // called functions, deduplicated
 }
 
 // Class represents a class/struct/interface
@@ -54,13 +74,25 @@ type Class struct {
 	Properties    []Property
 	IsPublic      bool
 	Documentation string
+	Embeds        []string // embedded type names
+	// PromotedMethods are methods reachable through embedded types declared
+	// in the same file; they are not counted as the type's own methods
+	PromotedMethods []Function
+	TypeParams      []TypeParameter
+}
+
+// TypeParameter represents a type parameter of a generic function or type
+type TypeParameter struct {
+	Name       string
+	Constraint string
 }
 
 // Property represents a class property/field
 type Property struct {
-	Name     string
-	Type     string
-	IsPublic bool
+	Name       string
+	Type       string
+	IsPublic   bool
+	IsEmbedded bool
 }
 
 // Parameter represents a function parameter
@@ -98,22 +130,45 @@ type Analyzer interface {
 }
 
 // analyzerRegistry holds all registered analyzers
-var analyzerRegistry = make(map[Language]Analyzer)
+var (
+	analyzerRegistry   = make(map[Language]Analyzer)
+	analyzerRegistryMu sync.RWMutex
+)
 
 // RegisterAnalyzer registers a language analyzer
 func RegisterAnalyzer(lang Language, analyzer Analyzer) {
+	analyzerRegistryMu.Lock()
+	defer analyzerRegistryMu.Unlock()
 	analyzerRegistry[lang] = analyzer
 }
 
+// UnregisterAnalyzer removes the analyzer registered for a language
+func UnregisterAnalyzer(lang Language) {
+	analyzerRegistryMu.Lock()
+	defer analyzerRegistryMu.Unlock()
+	delete(analyzerRegistry, lang)
+}
+
 // GetAnalyzer returns the analyzer for a language
 func GetAnalyzer(lang Language) (Analyzer, error) {
+	analyzerRegistryMu.RLock()
 	analyzer, ok := analyzerRegistry[lang]
+	analyzerRegistryMu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("no analyzer registered for language: %s", lang)
 	}
 	return analyzer, nil
 }
 
+// ParseLanguage returns the supported language with the given name, ignoring case
+func ParseLanguage(name string) (Language, bool) {
+	switch lang := Language(strings.ToLower(strings.TrimSpace(name))); lang {
+	case LanguageGo, LanguageJava, LanguagePython, LanguageJavaScript, LanguageTypeScript, LanguageCSharp:
+		return lang, true
+	}
+	return LanguageUnknown, false
+}
+
 // DetectLanguage detects the programming language from file path and content
 func DetectLanguage(filePath string, content []byte) Language {
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -5,15 +5,29 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"strings"
+	"time"
 )
 
+// DefaultTimeBudget bounds how long the Go analyzer spends on a single file
+const DefaultTimeBudget = 10 * time.Second
+
 // GoAnalyzer implements the Analyzer interface for Go
-type GoAnalyzer struct{}
+type GoAnalyzer struct {
+	timeBudget time.Duration
+}
 
-// NewGoAnalyzer creates a new Go analyzer
+// NewGoAnalyzer creates a new Go analyzer with the default time budget
 func NewGoAnalyzer() *GoAnalyzer {
-	return &GoAnalyzer{}
+	return NewGoAnalyzerWithBudget(DefaultTimeBudget)
+}
+
+// NewGoAnalyzerWithBudget creates a Go analyzer that stops extracting
+// declarations once budget has elapsed and returns a truncated result.
+// A zero budget disables the limit.
+func NewGoAnalyzerWithBudget(budget time.Duration) *GoAnalyzer {
+	return &GoAnalyzer{timeBudget: budget}
 }
 
 // Language returns the language this analyzer supports
@@ -23,6 +37,11 @@ func (a *GoAnalyzer) Language() Language {
 
 // Analyze analyzes Go source code
 func (a *GoAnalyzer) Analyze(ctx context.Context, content []byte) (*AnalysisResult, error) {
+	var deadline time.Time
+	if a.timeBudget > 0 {
+		deadline = time.Now().Add(a.timeBudget)
+	}
+
 	result := &AnalysisResult{
 		Language:  LanguageGo,
 		Functions: []Function{},
@@ -55,6 +74,7 @@ func (a *GoAnalyzer) Analyze(ctx context.Context, content []byte) (*AnalysisResu
 	}
 
 	result.AST = node
+	result.Package = node.Name.Name
 
 	// Extract comments
 	for _, commentGroup := range node.Comments {
@@ -81,14 +101,30 @@ func (a *GoAnalyzer) Analyze(ctx context.Context, content []byte) (*AnalysisResu
 		})
 	}
 
-	// Walk the AST to extract functions and types
+	// Walk the AST to extract functions and types, computing complexity as
+	// each function is found. Once the budget is spent the walk stops and
+	// the declarations seen so far are returned. Methods are attached once
+	// every type is known, as they may come before their type.
+	var methods []Function
 	ast.Inspect(node, func(n ast.Node) bool {
+		if result.Truncated {
+			return false
+		}
+		switch n.(type) {
+		case *ast.FuncDecl, *ast.GenDecl:
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				result.Truncated = true
+				return false
+			}
+		}
+
 		switch x := n.(type) {
 		case *ast.FuncDecl:
 			function := a.extractFunction(x, fset, result.Comments)
 			if x.Recv != nil {
 				// This is a method, add it to the appropriate struct/type
-				a.addMethodToClass(result, x, function, fset)
+				function.Receiver = receiverTypeName(x)
+				methods = append(methods, function)
 			} else {
 				// This is a standalone function
 				result.Functions = append(result.Functions, function)
@@ -109,17 +145,11 @@ func (a *GoAnalyzer) Analyze(ctx context.Context, content []byte) (*AnalysisResu
 		return true
 	})
 
-	// Calculate complexity for functions
-	for i := range result.Functions {
-		result.Functions[i].Complexity = a.calculateComplexity(result.Functions[i], node)
-	}
-
-	// Calculate complexity for methods in classes
-	for i := range result.Classes {
-		for j := range result.Classes[i].Methods {
-			result.Classes[i].Methods[j].Complexity = a.calculateComplexity(result.Classes[i].Methods[j], node)
-		}
+	for _, method := range methods {
+		a.addMethodToClass(result, method)
 	}
+	a.promoteInterfaceMethods(result)
+	a.promoteStructMethods(result)
 
 	return result, nil
 }
@@ -133,6 +163,8 @@ func (a *GoAnalyzer) extractFunction(fn *ast.FuncDecl, fset *token.FileSet, comm
 		Parameters: []Parameter{},
 		IsPublic:   ast.IsExported(fn.Name.Name),
 		IsTest:     strings.HasPrefix(fn.Name.Name, "Test") || strings.HasPrefix(fn.Name.Name, "Benchmark"),
+		Complexity: a.calculateComplexity(fn),
+		Calls:      a.extractCalls(fn),
 	}
 
 	// Extract documentation
@@ -142,10 +174,24 @@ func (a *GoAnalyzer) extractFunction(fn *ast.FuncDecl, fset *token.FileSet, comm
 		function.Documentation = ExtractDocumentation(comments, function.StartLine)
 	}
 
+	a.extractSignature(fn.Type, &function)
+
+	return function
+}
+
+// extractSignature fills in the parameters and return type of a function
+func (a *GoAnalyzer) extractSignature(funcType *ast.FuncType, function *Function) {
+	function.TypeParams = a.extractTypeParams(funcType.TypeParams)
+
 	// Extract parameters
-	if fn.Type.Params != nil {
-		for _, field := range fn.Type.Params.List {
+	if funcType.Params != nil {
+		for _, field := range funcType.Params.List {
 			paramType := a.typeToString(field.Type)
+			if len(field.Names) == 0 {
+				// Unnamed parameter, common in interface signatures
+				function.Parameters = append(function.Parameters, Parameter{Type: paramType})
+				continue
+			}
 			for _, name := range field.Names {
 				function.Parameters = append(function.Parameters, Parameter{
 					Name: name.Name,
@@ -156,15 +202,185 @@ func (a *GoAnalyzer) extractFunction(fn *ast.FuncDecl, fset *token.FileSet, comm
 	}
 
 	// Extract return type
-	if fn.Type.Results != nil && len(fn.Type.Results.List) > 0 {
+	if funcType.Results != nil && len(funcType.Results.List) > 0 {
 		var returnTypes []string
-		for _, result := range fn.Type.Results.List {
-			returnTypes = append(returnTypes, a.typeToString(result.Type))
+		for _, result := range funcType.Results.List {
+			resultType := a.typeToString(result.Type)
+			// (a, b int) is two results of type int
+			for i := 0; i < max(1, len(result.Names)); i++ {
+				returnTypes = append(returnTypes, resultType)
+			}
 		}
 		function.ReturnType = strings.Join(returnTypes, ", ")
 	}
+}
 
-	return function
+// extractTypeParams returns the type parameters declared by a generic
+// function or type, or nil if it has none
+func (a *GoAnalyzer) extractTypeParams(fields *ast.FieldList) []TypeParameter {
+	if fields == nil {
+		return nil
+	}
+
+	var params []TypeParameter
+	for _, field := range fields.List {
+		constraint := a.typeToString(field.Type)
+		for _, name := range field.Names {
+			params = append(params, TypeParameter{
+				Name:       name.Name,
+				Constraint: constraint,
+			})
+		}
+	}
+	return params
+}
+
+// extractInterfaceMethods records the method signatures and embedded
+// interfaces of an interface type
+func (a *GoAnalyzer) extractInterfaceMethods(iface *ast.InterfaceType, class *Class, fset *token.FileSet, comments []Comment) {
+	if iface.Methods == nil {
+		return
+	}
+
+	for _, field := range iface.Methods.List {
+		funcType, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			// Embedded interface; type set elements such as ~int are
+			// constraints rather than interfaces and are skipped
+			switch field.Type.(type) {
+			case *ast.Ident, *ast.SelectorExpr:
+				class.Embeds = append(class.Embeds, a.typeToString(field.Type))
+			}
+			continue
+		}
+
+		for _, name := range field.Names {
+			method := Function{
+				Name:       name.Name,
+				StartLine:  fset.Position(field.Pos()).Line,
+				EndLine:    fset.Position(field.End()).Line,
+				Parameters: []Parameter{},
+				IsPublic:   ast.IsExported(name.Name),
+				IsAbstract: true,
+			}
+			if field.Doc != nil {
+				method.Documentation = field.Doc.Text()
+			} else {
+				method.Documentation = ExtractDocumentation(comments, method.StartLine)
+			}
+			a.extractSignature(funcType, &method)
+			class.Methods = append(class.Methods, method)
+		}
+	}
+}
+
+// promoteInterfaceMethods adds the methods of embedded interfaces declared in
+// the same file to each embedding interface's method set
+func (a *GoAnalyzer) promoteInterfaceMethods(result *AnalysisResult) {
+	interfaces := make(map[string]*Class)
+	for i := range result.Classes {
+		if result.Classes[i].Type == "interface" {
+			interfaces[result.Classes[i].Name] = &result.Classes[i]
+		}
+	}
+
+	// Resolve against the declared methods only, so the result doesn't
+	// depend on the order interfaces are visited in
+	declared := make(map[string][]Function, len(interfaces))
+	for name, iface := range interfaces {
+		declared[name] = iface.Methods
+	}
+
+	var collect func(name string, seen map[string]bool) []Function
+	collect = func(name string, seen map[string]bool) []Function {
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+		iface, ok := interfaces[name]
+		if !ok {
+			return nil
+		}
+		var methods []Function
+		for _, embedded := range iface.Embeds {
+			for _, method := range declared[embedded] {
+				if method.PromotedFrom == "" {
+					method.PromotedFrom = embedded
+				}
+				methods = append(methods, method)
+			}
+			methods = append(methods, collect(embedded, seen)...)
+		}
+		return methods
+	}
+
+	for name, iface := range interfaces {
+		if len(iface.Embeds) == 0 {
+			continue
+		}
+		have := make(map[string]bool, len(iface.Methods))
+		for _, method := range iface.Methods {
+			have[method.Name] = true
+		}
+		for _, method := range collect(name, map[string]bool{}) {
+			if !have[method.Name] {
+				have[method.Name] = true
+				iface.Methods = append(iface.Methods, method)
+			}
+		}
+	}
+}
+
+// promoteStructMethods records the methods a struct gains from types it
+// embeds that are declared in the same file. Methods and fields of the
+// struct itself shadow promoted ones, and shallower embeddings win.
+func (a *GoAnalyzer) promoteStructMethods(result *AnalysisResult) {
+	types := make(map[string]*Class, len(result.Classes))
+	for i := range result.Classes {
+		types[result.Classes[i].Name] = &result.Classes[i]
+	}
+
+	for i := range result.Classes {
+		class := &result.Classes[i]
+		if class.Type != "struct" || len(class.Embeds) == 0 {
+			continue
+		}
+
+		shadowed := make(map[string]bool)
+		for _, method := range class.Methods {
+			shadowed[method.Name] = true
+		}
+		for _, property := range class.Properties {
+			shadowed[property.Name] = true
+		}
+
+		seen := map[string]bool{class.Name: true}
+		level := class.Embeds
+		for len(level) > 0 {
+			var next []string
+			for _, name := range level {
+				embedded, ok := types[name]
+				if !ok || seen[name] {
+					continue
+				}
+				seen[name] = true
+				for _, method := range embedded.Methods {
+					if shadowed[method.Name] {
+						continue
+					}
+					shadowed[method.Name] = true
+					if method.PromotedFrom == "" {
+						method.PromotedFrom = name
+					}
+					class.PromotedMethods = append(class.PromotedMethods, method)
+				}
+				if embedded.Type == "struct" {
+					next = append(next, embedded.Embeds...)
+				}
+			}
+			level = next
+		}
+	}
 }
 
 // extractType extracts type information (struct, interface, etc.)
@@ -176,6 +392,7 @@ func (a *GoAnalyzer) extractType(typeSpec *ast.TypeSpec, genDecl *ast.GenDecl, f
 		Methods:    []Function{},
 		Properties: []Property{},
 		IsPublic:   ast.IsExported(typeSpec.Name.Name),
+		TypeParams: a.extractTypeParams(typeSpec.TypeParams),
 	}
 
 	// Extract documentation
@@ -193,12 +410,19 @@ func (a *GoAnalyzer) extractType(typeSpec *ast.TypeSpec, genDecl *ast.GenDecl, f
 			for _, field := range t.Fields.List {
 				fieldType := a.typeToString(field.Type)
 				if len(field.Names) == 0 {
-					// Embedded field
+					// Embedded field, named after its type as in Go
+					embedded := strings.TrimPrefix(fieldType, "*")
+					if i := strings.Index(embedded, "["); i >= 0 {
+						embedded = embedded[:i] // drop type arguments
+					}
+					name := embedded[strings.LastIndex(embedded, ".")+1:]
 					class.Properties = append(class.Properties, Property{
-						Name:     fieldType,
-						Type:     fieldType,
-						IsPublic: ast.IsExported(fieldType),
+						Name:       name,
+						Type:       fieldType,
+						IsPublic:   ast.IsExported(name),
+						IsEmbedded: true,
 					})
+					class.Embeds = append(class.Embeds, embedded)
 				} else {
 					for _, name := range field.Names {
 						class.Properties = append(class.Properties, Property{
@@ -213,7 +437,7 @@ func (a *GoAnalyzer) extractType(typeSpec *ast.TypeSpec, genDecl *ast.GenDecl, f
 
 	case *ast.InterfaceType:
 		class.Type = "interface"
-		// Interface methods are handled separately
+		a.extractInterfaceMethods(t, class, fset, comments)
 
 	default:
 		// Type alias or other type definition
@@ -223,30 +447,41 @@ func (a *GoAnalyzer) extractType(typeSpec *ast.TypeSpec, genDecl *ast.GenDecl, f
 	return class
 }
 
-// addMethodToClass adds a method to the appropriate class
-func (a *GoAnalyzer) addMethodToClass(result *AnalysisResult, fn *ast.FuncDecl, method Function, fset *token.FileSet) {
-	if fn.Recv == nil || len(fn.Recv.List) == 0 {
-		return
-	}
-
-	// Get receiver type name
-	var receiverType string
-	switch t := fn.Recv.List[0].Type.(type) {
-	case *ast.Ident:
-		receiverType = t.Name
-	case *ast.StarExpr:
-		if ident, ok := t.X.(*ast.Ident); ok {
-			receiverType = ident.Name
-		}
-	}
-
-	// Find the class and add the method
+// addMethodToClass adds a method to its receiver type when the type is
+// declared in this file. Types are matched within the file only, so a
+// same-named type of another package never gets its methods; a method
+// whose type is declared in another file of the package is kept with the
+// standalone functions, its Receiver naming the type.
+func (a *GoAnalyzer) addMethodToClass(result *AnalysisResult, method Function) {
 	for i := range result.Classes {
-		if result.Classes[i].Name == receiverType {
+		if result.Classes[i].Name == method.Receiver {
 			result.Classes[i].Methods = append(result.Classes[i].Methods, method)
 			return
 		}
 	}
+	result.Functions = append(result.Functions, method)
+}
+
+// receiverTypeName returns the name of a method's receiver type, ignoring
+// pointers and type parameters
+func receiverTypeName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	expr := fn.Recv.List[0].Type
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	switch t := expr.(type) {
+	case *ast.IndexExpr:
+		expr = t.X
+	case *ast.IndexListExpr:
+		expr = t.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
 }
 
 // typeToString converts an AST expression to a string representation
@@ -260,39 +495,166 @@ func (a *GoAnalyzer) typeToString(expr ast.Expr) string {
 		if t.Len == nil {
 			return "[]" + a.typeToString(t.Elt)
 		}
-		return "[...]" + a.typeToString(t.Elt)
+		return "[" + a.arrayLenToString(t.Len) + "]" + a.typeToString(t.Elt)
+	case *ast.Ellipsis:
+		// Variadic parameter
+		return "..." + a.typeToString(t.Elt)
 	case *ast.MapType:
 		return "map[" + a.typeToString(t.Key) + "]" + a.typeToString(t.Value)
 	case *ast.ChanType:
+		switch t.Dir {
+		case ast.SEND:
+			return "chan<- " + a.typeToString(t.Value)
+		case ast.RECV:
+			return "<-chan " + a.typeToString(t.Value)
+		}
 		return "chan " + a.typeToString(t.Value)
 	case *ast.FuncType:
-		return "func(...)"
+		return "func" + a.signatureToString(t)
+	case *ast.ParenExpr:
+		return "(" + a.typeToString(t.X) + ")"
 	case *ast.InterfaceType:
 		return "interface{}"
+	case *ast.StructType:
+		if t.Fields == nil || len(t.Fields.List) == 0 {
+			return "struct{}"
+		}
+		return "struct{...}"
 	case *ast.SelectorExpr:
 		return a.typeToString(t.X) + "." + t.Sel.Name
+	case *ast.IndexExpr:
+		return a.typeToString(t.X) + "[" + a.typeToString(t.Index) + "]"
+	case *ast.IndexListExpr:
+		args := make([]string, len(t.Indices))
+		for i, index := range t.Indices {
+			args[i] = a.typeToString(index)
+		}
+		return a.typeToString(t.X) + "[" + strings.Join(args, ", ") + "]"
+	case *ast.UnaryExpr:
+		// Approximation element in a constraint, e.g. ~int
+		return t.Op.String() + a.typeToString(t.X)
+	case *ast.BinaryExpr:
+		// Union in a constraint, e.g. ~int | ~float64
+		return a.typeToString(t.X) + " " + t.Op.String() + " " + a.typeToString(t.Y)
 	default:
 		return "unknown"
 	}
 }
 
-// calculateComplexity calculates cyclomatic complexity for a function
-func (a *GoAnalyzer) calculateComplexity(fn Function, file *ast.File) int {
-	complexity := 1 // Base complexity
+// arrayLenToString renders the length of an array type
+func (a *GoAnalyzer) arrayLenToString(expr ast.Expr) string {
+	switch l := expr.(type) {
+	case *ast.Ellipsis:
+		return "..."
+	case *ast.BasicLit:
+		return l.Value
+	case *ast.Ident:
+		return l.Name
+	case *ast.SelectorExpr:
+		return a.typeToString(l)
+	default:
+		return "..."
+	}
+}
 
-	// Find the function node in the AST
-	var funcNode *ast.FuncDecl
-	ast.Inspect(file, func(n ast.Node) bool {
-		if f, ok := n.(*ast.FuncDecl); ok && f.Name.Name == fn.Name {
-			funcNode = f
-			return false
+// signatureToString renders the parameters and results of a function type,
+// keeping parameter names where the source has them
+func (a *GoAnalyzer) signatureToString(funcType *ast.FuncType) string {
+	signature := "(" + a.fieldListToString(funcType.Params) + ")"
+	if funcType.Results == nil || len(funcType.Results.List) == 0 {
+		return signature
+	}
+
+	results := a.fieldListToString(funcType.Results)
+	if len(funcType.Results.List) == 1 && len(funcType.Results.List[0].Names) == 0 {
+		return signature + " " + results
+	}
+	return signature + " (" + results + ")"
+}
+
+// fieldListToString renders a parameter or result list
+func (a *GoAnalyzer) fieldListToString(fields *ast.FieldList) string {
+	if fields == nil {
+		return ""
+	}
+
+	parts := make([]string, 0, len(fields.List))
+	for _, field := range fields.List {
+		fieldType := a.typeToString(field.Type)
+		if len(field.Names) == 0 {
+			parts = append(parts, fieldType)
+			continue
+		}
+		names := make([]string, len(field.Names))
+		for i, name := range field.Names {
+			names[i] = name.Name
+		}
+		parts = append(parts, strings.Join(names, ", ")+" "+fieldType)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// extractCalls returns the functions called from a function body, in order
+// of first call. Calls through selectors are recorded as written, e.g.
+// fmt.Println or s.repo.Save; builtins and conversions to predeclared
+// types are ignored.
+func (a *GoAnalyzer) extractCalls(fn *ast.FuncDecl) []string {
+	if fn.Body == nil {
+		return nil
+	}
+
+	var calls []string
+	seen := make(map[string]bool)
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		callee := call.Fun
+		switch f := callee.(type) {
+		case *ast.IndexExpr:
+			callee = f.X // explicit instantiation, e.g. Map[int](xs)
+		case *ast.IndexListExpr:
+			callee = f.X
+		}
+
+		var name string
+		switch f := callee.(type) {
+		case *ast.Ident:
+			if types.Universe.Lookup(f.Name) == nil {
+				name = f.Name
+			}
+		case *ast.SelectorExpr:
+			name = a.selectorToString(f)
+		}
+
+		if name != "" && !seen[name] {
+			seen[name] = true
+			calls = append(calls, name)
 		}
 		return true
 	})
+	return calls
+}
 
-	if funcNode == nil {
-		return complexity
+// selectorToString renders a selector chain such as pkg.Func or a.b.Method,
+// or "" when the chain isn't made of plain identifiers
+func (a *GoAnalyzer) selectorToString(sel *ast.SelectorExpr) string {
+	switch x := sel.X.(type) {
+	case *ast.Ident:
+		return x.Name + "." + sel.Sel.Name
+	case *ast.SelectorExpr:
+		if prefix := a.selectorToString(x); prefix != "" {
+			return prefix + "." + sel.Sel.Name
+		}
 	}
+	return ""
+}
+
+// calculateComplexity calculates cyclomatic complexity for a function
+func (a *GoAnalyzer) calculateComplexity(funcNode *ast.FuncDecl) int {
+	complexity := 1 // Base complexity
 
 	// Count decision points
 	ast.Inspect(funcNode, func(n ast.Node) bool {
@@ -2,7 +2,10 @@ package analyzer_test
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -334,4 +337,366 @@ func TestDetectLanguage(t *testing.T) {
 			assert.Equal(t, tt.expected, detected)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestGoAnalyzer_InterfaceMethods(t *testing.T) {
+	code := `package store
+
+import "io"
+
+// Store persists records
+type Store interface {
+	// Get loads a record
+	Get(id string) ([]byte, error)
+	Put(id string, data []byte) error
+	Delete(string) error
+}
+
+type ReadStore interface {
+	Get(id string) ([]byte, error)
+}
+
+type ClosableStore interface {
+	ReadStore
+	io.Closer
+	Flush() error
+}`
+
+	result, err := analyzer.NewGoAnalyzer().Analyze(context.Background(), []byte(code))
+	require.NoError(t, err)
+
+	classes := make(map[string]analyzer.Class)
+	for _, class := range result.Classes {
+		classes[class.Name] = class
+	}
+
+	t.Run("signatures", func(t *testing.T) {
+		store := classes["Store"]
+		assert.Equal(t, "interface", store.Type)
+		require.Len(t, store.Methods, 3)
+
+		get := store.Methods[0]
+		assert.Equal(t, "Get", get.Name)
+		assert.True(t, get.IsAbstract)
+		assert.Equal(t, 0, get.Complexity)
+		assert.Equal(t, []analyzer.Parameter{{Name: "id", Type: "string"}}, get.Parameters)
+		assert.Equal(t, "[]byte, error", get.ReturnType)
+		assert.Equal(t, "Get loads a record\n", get.Documentation)
+
+		assert.Equal(t, []analyzer.Parameter{{Type: "string"}}, store.Methods[2].Parameters)
+	})
+
+	t.Run("embedded interfaces", func(t *testing.T) {
+		closable := classes["ClosableStore"]
+		assert.Equal(t, []string{"ReadStore", "io.Closer"}, closable.Embeds)
+
+		methods := make(map[string]string)
+		for _, method := range closable.Methods {
+			methods[method.Name] = method.PromotedFrom
+		}
+		assert.Equal(t, map[string]string{"Flush": "", "Get": "ReadStore"}, methods)
+	})
+}
+
+func TestGoAnalyzer_EmbeddedFields(t *testing.T) {
+	code := `package shapes
+
+import "sync"
+
+type Base struct {
+	ID string
+}
+
+func (b *Base) Describe() string { return b.ID }
+
+func (b *Base) Reset() { b.ID = "" }
+
+type Shape struct {
+	*Base
+	sync.Mutex
+	Name string
+}
+
+func (s *Shape) Reset() {}`
+
+	result, err := analyzer.NewGoAnalyzer().Analyze(context.Background(), []byte(code))
+	require.NoError(t, err)
+
+	var shape analyzer.Class
+	for _, class := range result.Classes {
+		if class.Name == "Shape" {
+			shape = class
+		}
+	}
+
+	assert.Equal(t, []analyzer.Property{
+		{Name: "Base", Type: "*Base", IsPublic: true, IsEmbedded: true},
+		{Name: "Mutex", Type: "sync.Mutex", IsPublic: true, IsEmbedded: true},
+		{Name: "Name", Type: "string", IsPublic: true},
+	}, shape.Properties)
+	assert.Equal(t, []string{"Base", "sync.Mutex"}, shape.Embeds)
+
+	// Reset is declared on Shape itself, so only Describe is promoted
+	require.Len(t, shape.Methods, 1)
+	require.Len(t, shape.PromotedMethods, 1)
+	assert.Equal(t, "Describe", shape.PromotedMethods[0].Name)
+	assert.Equal(t, "Base", shape.PromotedMethods[0].PromotedFrom)
+}
+
+func TestGoAnalyzer_Generics(t *testing.T) {
+	code := `package collections
+
+type Number interface {
+	~int | ~float64
+}
+
+// Sum adds up the values
+func Sum[T Number](values []T) T {
+	var total T
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+func (p *Pair[K, V]) Swap() Pair[V, K] {
+	return Pair[V, K]{Key: p.Value, Value: p.Key}
+}
+
+func Index(pairs []Pair[string, int]) map[string]Pair[string, int] {
+	return nil
+}`
+
+	result, err := analyzer.NewGoAnalyzer().Analyze(context.Background(), []byte(code))
+	require.NoError(t, err)
+
+	functions := make(map[string]analyzer.Function)
+	for _, fn := range result.Functions {
+		functions[fn.Name] = fn
+	}
+	classes := make(map[string]analyzer.Class)
+	for _, class := range result.Classes {
+		classes[class.Name] = class
+	}
+
+	t.Run("generic function", func(t *testing.T) {
+		sum := functions["Sum"]
+		assert.Equal(t, []analyzer.TypeParameter{{Name: "T", Constraint: "Number"}}, sum.TypeParams)
+		assert.Equal(t, []analyzer.Parameter{{Name: "values", Type: "[]T"}}, sum.Parameters)
+		assert.Equal(t, "T", sum.ReturnType)
+	})
+
+	t.Run("generic struct", func(t *testing.T) {
+		pair := classes["Pair"]
+		assert.Equal(t, []analyzer.TypeParameter{
+			{Name: "K", Constraint: "comparable"},
+			{Name: "V", Constraint: "any"},
+		}, pair.TypeParams)
+
+		require.Len(t, pair.Methods, 1)
+		assert.Equal(t, "Swap", pair.Methods[0].Name)
+		assert.Equal(t, "Pair[V, K]", pair.Methods[0].ReturnType)
+	})
+
+	t.Run("instantiated types", func(t *testing.T) {
+		index := functions["Index"]
+		assert.Equal(t, "[]Pair[string, int]", index.Parameters[0].Type)
+		assert.Equal(t, "map[string]Pair[string, int]", index.ReturnType)
+		assert.Empty(t, index.TypeParams)
+	})
+}
+
+func TestGoAnalyzer_TypeRendering(t *testing.T) {
+	code := `package events
+
+func Subscribe(handler func(topic string, payload []byte) error) {}
+
+func Log(format string, args ...interface{}) {}
+
+func Workers(jobs chan func() (result int, err error), done <-chan struct{}, out chan<- int) {}
+
+const N = 8
+
+func Sizes(fixed [4]byte, named [N]int) {}
+
+func Split(path string) (dir, file string) { return "", "" }`
+
+	result, err := analyzer.NewGoAnalyzer().Analyze(context.Background(), []byte(code))
+	require.NoError(t, err)
+
+	params := make(map[string][]string)
+	returns := make(map[string]string)
+	for _, fn := range result.Functions {
+		for _, p := range fn.Parameters {
+			params[fn.Name] = append(params[fn.Name], p.Type)
+		}
+		returns[fn.Name] = fn.ReturnType
+	}
+
+	tests := []struct {
+		function string
+		expected []string
+	}{
+		{"Subscribe", []string{"func(topic string, payload []byte) error"}},
+		{"Log", []string{"string", "...interface{}"}},
+		{"Workers", []string{"chan func() (result int, err error)", "<-chan struct{}", "chan<- int"}},
+		{"Sizes", []string{"[4]byte", "[N]int"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.function, func(t *testing.T) {
+			assert.Equal(t, tt.expected, params[tt.function])
+		})
+	}
+
+	assert.Equal(t, "string, string", returns["Split"])
+}
+
+func TestGoAnalyzer_Calls(t *testing.T) {
+	code := `package main
+
+import "fmt"
+
+func helper() int { return 1 }
+
+func run() {
+	n := helper()
+	n += helper()
+	items := make([]int, 0, n)
+	fmt.Println(len(items), int64(n))
+}`
+
+	result, err := analyzer.NewGoAnalyzer().Analyze(context.Background(), []byte(code))
+	require.NoError(t, err)
+
+	calls := make(map[string][]string)
+	for _, fn := range result.Functions {
+		calls[fn.Name] = fn.Calls
+	}
+	assert.Equal(t, []string{"helper", "fmt.Println"}, calls["run"])
+	assert.Empty(t, calls["helper"])
+}
+
+// generatedGoFile returns a Go file with n small functions, each with one branch
+func generatedGoFile(n int) []byte {
+	var b strings.Builder
+	b.WriteString("package generated\n\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "func F%d(x int) int {\n\tif x > %d {\n\t\treturn x\n\t}\n\treturn %d\n}\n\n", i, i, i)
+	}
+	return []byte(b.String())
+}
+
+func TestGoAnalyzer_MethodComplexityByReceiver(t *testing.T) {
+	code := `package main
+
+type Simple struct{}
+
+func (s Simple) Run() {}
+
+type Branchy struct{}
+
+func (b *Branchy) Run(x int) int {
+	if x > 0 {
+		return x
+	}
+	for x < 0 {
+		x++
+	}
+	return x
+}`
+
+	result, err := analyzer.NewGoAnalyzer().Analyze(context.Background(), []byte(code))
+	require.NoError(t, err)
+
+	complexity := make(map[string]int)
+	for _, class := range result.Classes {
+		require.Len(t, class.Methods, 1)
+		complexity[class.Name] = class.Methods[0].Complexity
+	}
+	assert.Equal(t, 1, complexity["Simple"])
+	assert.Equal(t, 3, complexity["Branchy"])
+}
+
+func TestGoAnalyzer_MethodsScopedToFile(t *testing.T) {
+	code := `package accounts
+
+import "example.com/app/users"
+
+// Close is declared before its type
+func (a *Account) Close() {}
+
+type Account struct {
+	users.User
+}
+
+func (a Account) Balance() int { return 0 }
+
+// Rename belongs to a User declared in another file of this package, not
+// to the embedded users.User
+func (u *User) Rename(name string) {}
+`
+
+	result, err := analyzer.NewGoAnalyzer().Analyze(context.Background(), []byte(code))
+	require.NoError(t, err)
+
+	assert.Equal(t, "accounts", result.Package)
+	require.Len(t, result.Classes, 1)
+	account := result.Classes[0]
+	var methods []string
+	for _, method := range account.Methods {
+		methods = append(methods, method.Name)
+		assert.Equal(t, "Account", method.Receiver)
+	}
+	assert.ElementsMatch(t, []string{"Close", "Balance"}, methods)
+	assert.Empty(t, account.PromotedMethods)
+
+	require.Len(t, result.Functions, 1, "a method of a type declared elsewhere isn't dropped")
+	assert.Equal(t, "Rename", result.Functions[0].Name)
+	assert.Equal(t, "User", result.Functions[0].Receiver)
+}
+
+func TestGoAnalyzer_TimeBudget(t *testing.T) {
+	const functions = 20000
+	content := generatedGoFile(functions)
+
+	t.Run("large file completes within default budget", func(t *testing.T) {
+		result, err := analyzer.NewGoAnalyzer().Analyze(context.Background(), content)
+		require.NoError(t, err)
+
+		assert.False(t, result.Truncated)
+		require.Len(t, result.Functions, functions)
+		for _, fn := range result.Functions {
+			assert.Equal(t, 2, fn.Complexity)
+		}
+	})
+
+	t.Run("exceeded budget returns partial result", func(t *testing.T) {
+		budget := time.Millisecond
+		start := time.Now()
+		result, err := analyzer.NewGoAnalyzerWithBudget(budget).Analyze(context.Background(), content)
+		elapsed := time.Since(start)
+		require.NoError(t, err)
+
+		assert.True(t, result.Truncated)
+		assert.Less(t, len(result.Functions), functions)
+		assert.Less(t, elapsed, analyzer.DefaultTimeBudget)
+	})
+}
+
+func BenchmarkGoAnalyzer_ManyFunctions(b *testing.B) {
+	content := generatedGoFile(5000)
+	goAnalyzer := analyzer.NewGoAnalyzer()
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := goAnalyzer.Analyze(ctx, content); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,566 @@
+// Package config loads the analysis service configuration from an optional
+// config file and the environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/notify"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/webhook"
+	"github.com/sa3d-modernized/sa3d/shared/events"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+)
+
+// EnvPrefix prefixes the environment variable of every setting, with dots
+// in the key replaced by underscores: server.port is ANALYSIS_SERVER_PORT
+const EnvPrefix = "ANALYSIS"
+
+// ErrInvalidConfig is returned for configuration that fails validation
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Config is the analysis service configuration
+type Config struct {
+	Server struct {
+		Port         string        `mapstructure:"port"`
+		ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+		WriteTimeout time.Duration `mapstructure:"write_timeout"`
+		// ShutdownTimeout bounds stopping the server and draining requests
+		ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	} `mapstructure:"server"`
+
+	LogLevel string `mapstructure:"log_level"`
+
+	Redis struct {
+		Host     string `mapstructure:"host"`
+		Port     string `mapstructure:"port"`
+		Password string `mapstructure:"password"`
+		DB       int    `mapstructure:"db"`
+	} `mapstructure:"redis"`
+
+	// Kafka configures publishing analysis events. Without Enabled the
+	// service is built without a writer and drops events.
+	Kafka struct {
+		Enabled bool     `mapstructure:"enabled"`
+		Brokers []string `mapstructure:"brokers"`
+		Topic   string   `mapstructure:"topic"`
+		// Format is the event serialization, "json" or "protobuf"
+		Format string `mapstructure:"format"`
+		// Retry bounds retrying failed event writes: Attempts writes per
+		// event, waiting Backoff after the first failure and twice as long
+		// after each further one, up to MaxBackoff
+		Retry struct {
+			Attempts   int           `mapstructure:"attempts"`
+			Backoff    time.Duration `mapstructure:"backoff"`
+			MaxBackoff time.Duration `mapstructure:"max_backoff"`
+		} `mapstructure:"retry"`
+		// Buffer bounds how many events wait to be written, as they do
+		// while Kafka is unreachable; 0 removes the limit
+		Buffer int `mapstructure:"buffer"`
+		// Health probes the brokers for topic metadata every Interval,
+		// giving up after Timeout; 0 turns off the periodic probe, leaving
+		// readiness requests to probe
+		Health struct {
+			Timeout  time.Duration `mapstructure:"timeout"`
+			Interval time.Duration `mapstructure:"interval"`
+		} `mapstructure:"health"`
+	} `mapstructure:"kafka"`
+
+	// Database is the Postgres database holding projects and analyses. It
+	// reads the same DB_* variables as the shared SecretManager. Project and
+	// result reads go to the replica at ReplicaHost when one is set.
+	Database struct {
+		Host        string `mapstructure:"host"`
+		Port        string `mapstructure:"port"`
+		User        string `mapstructure:"user"`
+		Password    string `mapstructure:"password"`
+		Name        string `mapstructure:"name"`
+		SSLMode     string `mapstructure:"ssl_mode"`
+		ReplicaHost string `mapstructure:"replica_host"`
+		ReplicaPort string `mapstructure:"replica_port"`
+	} `mapstructure:"database"`
+
+	// Workers bounds concurrent work. Analyses beyond MaxConcurrent wait in
+	// a priority queue; 0 disables the limit. MaxConcurrentFiles bounds the
+	// files analyzed at once across all analyses; 0 uses
+	// service.DefaultMaxConcurrentFiles.
+	Workers struct {
+		MaxConcurrent      int `mapstructure:"max_concurrent"`
+		MaxConcurrentFiles int `mapstructure:"max_concurrent_files"`
+	} `mapstructure:"workers"`
+
+	// Limits bound the size of an analysis: more than MaxFiles files, counted
+	// after the file filter, either fail it or, with OverMaxFiles truncate,
+	// leave the smallest out; 0 disables the limit
+	Limits struct {
+		MaxFiles     int    `mapstructure:"max_files"`
+		OverMaxFiles string `mapstructure:"over_max_files"`
+	} `mapstructure:"limits"`
+
+	// Timeouts bound analyses: Analysis is the longest a whole analysis may
+	// run and File the longest one file may take; 0 disables either limit
+	Timeouts struct {
+		Analysis time.Duration `mapstructure:"analysis"`
+		File     time.Duration `mapstructure:"file"`
+	} `mapstructure:"timeouts"`
+
+	// Advisories configures checking dependencies against a local database
+	// of known vulnerabilities, such as an extracted OSV export: a JSON file
+	// or a directory of them
+	Advisories struct {
+		Enabled bool   `mapstructure:"enabled"`
+		Path    string `mapstructure:"path"`
+	} `mapstructure:"advisories"`
+
+	// Notifications configures telling a project's recipients about its
+	// finished analyses, linking to them under BaseURL. Emails go through
+	// the SMTP relay at Email.Addr when one is set; webhooks are signed
+	// with Webhook.Secret and retried as Webhook configures.
+	Notifications struct {
+		Enabled bool   `mapstructure:"enabled"`
+		BaseURL string `mapstructure:"base_url"`
+		Email   struct {
+			Addr     string `mapstructure:"addr"`
+			From     string `mapstructure:"from"`
+			Username string `mapstructure:"username"`
+			Password string `mapstructure:"password"`
+		} `mapstructure:"email"`
+		Webhook struct {
+			Secret         string        `mapstructure:"secret"`
+			MaxAttempts    int           `mapstructure:"max_attempts"`
+			InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+			MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+			Timeout        time.Duration `mapstructure:"timeout"`
+		} `mapstructure:"webhook"`
+	} `mapstructure:"notifications"`
+
+	// Features are the feature flags by name. FEATURE_* variables override
+	// them, and so does the feature_flags Redis hash, read again every
+	// FeatureRefreshInterval; 0 reads it only at startup.
+	Features               map[string]bool `mapstructure:"features"`
+	FeatureRefreshInterval time.Duration   `mapstructure:"feature_refresh_interval"`
+
+	// Rules overrides the thresholds, severities and enablement of the
+	// code smell rules by rule name; rules not listed keep their defaults
+	Rules map[string]RuleOverride `mapstructure:"rules"`
+
+	// Metrics configures the metric formulas. DebtWeights scales the
+	// technical debt of excess complexity by language; languages not
+	// listed weigh 1.
+	Metrics struct {
+		DebtWeights map[string]float64 `mapstructure:"debt_weights"`
+	} `mapstructure:"metrics"`
+
+	// Sources configures where the content of project files stored without
+	// it in the database is read from: Backend "database" keeps all content
+	// in the database, "filesystem" reads it from under Path and "s3" from
+	// an S3-compatible bucket. With a store, files saved larger than
+	// InlineLimit bytes go to it.
+	Sources struct {
+		Backend     string `mapstructure:"backend"`
+		Path        string `mapstructure:"path"`
+		InlineLimit int64  `mapstructure:"inline_limit"`
+		S3          struct {
+			Endpoint  string `mapstructure:"endpoint"`
+			Bucket    string `mapstructure:"bucket"`
+			Region    string `mapstructure:"region"`
+			Prefix    string `mapstructure:"prefix"`
+			AccessKey string `mapstructure:"access_key"`
+			SecretKey string `mapstructure:"secret_key"`
+		} `mapstructure:"s3"`
+	} `mapstructure:"sources"`
+}
+
+// Source backends of Config.Sources
+const (
+	SourcesDatabase   = "database"
+	SourcesFilesystem = "filesystem"
+	SourcesS3         = "s3"
+)
+
+// RuleOverride changes part of a rule's configuration; unset fields keep
+// the rule's defaults
+type RuleOverride struct {
+	Enabled   *bool   `mapstructure:"enabled"`
+	Severity  string  `mapstructure:"severity"`
+	Threshold float64 `mapstructure:"threshold"`
+}
+
+// legacyEnv are the environment variables read before the typed config,
+// still honoured after the prefixed names
+var legacyEnv = map[string][]string{
+	"log_level":              {"LOG_LEVEL"},
+	"kafka.enabled":          {"EVENTS_ENABLED"},
+	"kafka.format":           {"EVENTS_FORMAT"},
+	"timeouts.analysis":      {"ANALYSIS_MAX_DURATION"},
+	"workers.max_concurrent": {"ANALYSIS_MAX_CONCURRENT"},
+	"redis.host":             {"REDIS_HOST"},
+	"redis.port":             {"REDIS_PORT"},
+	"redis.password":         {"REDIS_PASSWORD"},
+	"redis.db":               {"REDIS_DB"},
+	"kafka.brokers":          {"KAFKA_BROKERS"},
+	"database.host":          {"DB_HOST"},
+	"database.port":          {"DB_PORT"},
+	"database.user":          {"DB_USER"},
+	"database.password":      {"DB_PASSWORD"},
+	"database.name":          {"DB_NAME"},
+	"database.ssl_mode":      {"DB_SSL_MODE"},
+	"database.replica_host":  {"DB_REPLICA_HOST"},
+	"database.replica_port":  {"DB_REPLICA_PORT"},
+}
+
+// Load reads the configuration into v from the environment and a config
+// file, then validates it. Unless v already names a file, config.yaml is
+// looked for in ., ./config and /etc/analysis-service and may be absent.
+func Load(v *viper.Viper) (*Config, error) {
+	if v.ConfigFileUsed() == "" {
+		v.SetConfigName("config")
+		v.SetConfigType("yaml")
+		v.AddConfigPath(".")
+		v.AddConfigPath("./config")
+		v.AddConfigPath("/etc/analysis-service")
+	}
+
+	setDefaults(v)
+
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	for key, names := range legacyEnv {
+		prefixed := EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if err := v.BindEnv(append([]string{key, prefixed}, names...)...); err != nil {
+			return nil, fmt.Errorf("failed to bind %s: %w", key, err)
+		}
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// setDefaults registers the default of every setting
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("server.port", "8080")
+	v.SetDefault("server.read_timeout", "15s")
+	v.SetDefault("server.write_timeout", "60s")
+	v.SetDefault("server.shutdown_timeout", "5s")
+	v.SetDefault("log_level", "info")
+	v.SetDefault("redis.host", "localhost")
+	v.SetDefault("redis.port", "6379")
+	v.SetDefault("redis.password", "")
+	v.SetDefault("redis.db", 0)
+	v.SetDefault("kafka.enabled", true)
+	v.SetDefault("kafka.brokers", []string{"localhost:9092"})
+	v.SetDefault("kafka.topic", "analysis-events")
+	v.SetDefault("kafka.format", events.FormatJSON)
+	v.SetDefault("kafka.retry.attempts", service.DefaultEventRetry.Attempts)
+	v.SetDefault("kafka.retry.backoff", service.DefaultEventRetry.Backoff)
+	v.SetDefault("kafka.retry.max_backoff", service.DefaultEventRetry.MaxBackoff)
+	v.SetDefault("kafka.buffer", service.DefaultEventBuffer)
+	v.SetDefault("kafka.health.timeout", service.DefaultKafkaHealthTimeout)
+	v.SetDefault("kafka.health.interval", service.DefaultKafkaHealthInterval)
+	v.SetDefault("database.host", "localhost")
+	v.SetDefault("database.port", "5432")
+	v.SetDefault("database.user", "")
+	v.SetDefault("database.password", "")
+	v.SetDefault("database.name", "")
+	v.SetDefault("database.ssl_mode", "require")
+	v.SetDefault("database.replica_host", "")
+	v.SetDefault("database.replica_port", "")
+	v.SetDefault("workers.max_concurrent", service.DefaultMaxConcurrentAnalyses)
+	v.SetDefault("workers.max_concurrent_files", 0)
+	v.SetDefault("limits.max_files", service.DefaultMaxFiles)
+	v.SetDefault("limits.over_max_files", service.FileLimitTruncate)
+	v.SetDefault("timeouts.analysis", service.DefaultMaxDuration)
+	v.SetDefault("timeouts.file", service.DefaultFileTimeout)
+	v.SetDefault("advisories.enabled", false)
+	v.SetDefault("advisories.path", "")
+	v.SetDefault("notifications.enabled", false)
+	v.SetDefault("notifications.base_url", "")
+	v.SetDefault("notifications.email.addr", "")
+	v.SetDefault("notifications.email.from", "")
+	v.SetDefault("notifications.email.username", "")
+	v.SetDefault("notifications.email.password", "")
+	v.SetDefault("notifications.webhook.secret", "")
+	v.SetDefault("notifications.webhook.max_attempts", webhook.DefaultConfig().MaxAttempts)
+	v.SetDefault("notifications.webhook.initial_backoff", webhook.DefaultConfig().InitialBackoff)
+	v.SetDefault("notifications.webhook.max_backoff", webhook.DefaultConfig().MaxBackoff)
+	v.SetDefault("notifications.webhook.timeout", webhook.DefaultConfig().Timeout)
+	v.SetDefault("features."+services.FlagEventPublishing, true)
+	v.SetDefault("feature_refresh_interval", "30s")
+	v.SetDefault("sources.backend", SourcesDatabase)
+	v.SetDefault("sources.path", "")
+	v.SetDefault("sources.inline_limit", services.DefaultInlineSourceLimit)
+	v.SetDefault("sources.s3.endpoint", "")
+	v.SetDefault("sources.s3.bucket", "")
+	v.SetDefault("sources.s3.region", "")
+	v.SetDefault("sources.s3.prefix", "")
+	v.SetDefault("sources.s3.access_key", "")
+	v.SetDefault("sources.s3.secret_key", "")
+}
+
+// Validate reports every invalid setting, each wrapping ErrInvalidConfig
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrInvalidConfig, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Server.Port == "" {
+		invalid("server.port is required")
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.ShutdownTimeout < 0 {
+		invalid("server timeouts must not be negative")
+	}
+	if _, err := logrus.ParseLevel(c.LogLevel); err != nil {
+		invalid("log_level: %v", err)
+	}
+	if c.Redis.Host == "" || c.Redis.Port == "" {
+		invalid("redis.host and redis.port are required")
+	}
+	if c.Redis.DB < 0 {
+		invalid("redis.db must not be negative: %d", c.Redis.DB)
+	}
+	if c.Kafka.Enabled {
+		if len(c.Kafka.Brokers) == 0 {
+			invalid("kafka.brokers is required when events are enabled")
+		}
+		if c.Kafka.Topic == "" {
+			invalid("kafka.topic is required when events are enabled")
+		}
+		if _, err := events.NewCodec(c.Kafka.Format); err != nil {
+			invalid("kafka.format: %v", err)
+		}
+		if c.Kafka.Retry.Attempts < 1 {
+			invalid("kafka.retry.attempts must be at least 1: %d", c.Kafka.Retry.Attempts)
+		}
+		if c.Kafka.Retry.Backoff < 0 || c.Kafka.Retry.MaxBackoff < c.Kafka.Retry.Backoff {
+			invalid("kafka.retry.backoff must not be negative nor above kafka.retry.max_backoff")
+		}
+		if c.Kafka.Buffer < 0 {
+			invalid("kafka.buffer must not be negative: %d", c.Kafka.Buffer)
+		}
+		if c.Kafka.Health.Timeout <= 0 {
+			invalid("kafka.health.timeout must be positive: %s", c.Kafka.Health.Timeout)
+		}
+		if c.Kafka.Health.Interval < 0 {
+			invalid("kafka.health.interval must not be negative: %s", c.Kafka.Health.Interval)
+		}
+	}
+	if c.Database.Host == "" {
+		invalid("database.host is required")
+	}
+	if c.Database.User == "" {
+		invalid("database.user is required")
+	}
+	if c.Database.Name == "" {
+		invalid("database.name is required")
+	}
+	if c.Workers.MaxConcurrent < 0 {
+		invalid("workers.max_concurrent must not be negative: %d", c.Workers.MaxConcurrent)
+	}
+	if c.Workers.MaxConcurrentFiles < 0 {
+		invalid("workers.max_concurrent_files must not be negative: %d", c.Workers.MaxConcurrentFiles)
+	}
+	if err := c.FileLimit().Validate(); err != nil {
+		invalid("limits: %v", err)
+	}
+	if c.Timeouts.Analysis < 0 {
+		invalid("timeouts.analysis must not be negative: %s", c.Timeouts.Analysis)
+	}
+	if c.Timeouts.File < 0 {
+		invalid("timeouts.file must not be negative: %s", c.Timeouts.File)
+	}
+	if c.Advisories.Enabled && c.Advisories.Path == "" {
+		invalid("advisories.path is required when the advisory check is enabled")
+	}
+	if c.Notifications.Enabled {
+		if c.Notifications.BaseURL == "" {
+			invalid("notifications.base_url is required when notifications are enabled")
+		}
+		if c.Notifications.Email.Addr != "" && c.Notifications.Email.From == "" {
+			invalid("notifications.email.from is required with notifications.email.addr")
+		}
+		if c.Notifications.Webhook.MaxAttempts < 1 {
+			invalid("notifications.webhook.max_attempts must be at least 1: %d", c.Notifications.Webhook.MaxAttempts)
+		}
+		if c.Notifications.Webhook.InitialBackoff <= 0 || c.Notifications.Webhook.MaxBackoff < c.Notifications.Webhook.InitialBackoff {
+			invalid("notifications.webhook.initial_backoff must be positive and not above notifications.webhook.max_backoff")
+		}
+		if c.Notifications.Webhook.Timeout <= 0 {
+			invalid("notifications.webhook.timeout must be positive: %s", c.Notifications.Webhook.Timeout)
+		}
+	}
+	if c.FeatureRefreshInterval < 0 {
+		invalid("feature_refresh_interval must not be negative: %s", c.FeatureRefreshInterval)
+	}
+	if _, err := c.RuleEngine(); err != nil {
+		invalid("rules: %v", err)
+	}
+	if err := c.CalculatorConfig().Validate(); err != nil {
+		invalid("metrics.debt_weights: %v", err)
+	}
+	if c.Sources.InlineLimit < 0 {
+		invalid("sources.inline_limit must not be negative: %d", c.Sources.InlineLimit)
+	}
+	switch c.Sources.Backend {
+	case SourcesDatabase:
+	case SourcesFilesystem:
+		if c.Sources.Path == "" {
+			invalid("sources.path is required for the filesystem backend")
+		}
+	case SourcesS3:
+		if err := c.ObjectStoreConfig().Validate(); err != nil {
+			invalid("sources.s3: %v", err)
+		}
+	default:
+		invalid("sources.backend must be %s, %s or %s: %q", SourcesDatabase, SourcesFilesystem, SourcesS3, c.Sources.Backend)
+	}
+
+	return errors.Join(errs...)
+}
+
+// RedisAddr returns the host:port address of Redis
+func (c *Config) RedisAddr() string {
+	return net.JoinHostPort(c.Redis.Host, c.Redis.Port)
+}
+
+// EventCodec returns the codec for the configured event format
+func (c *Config) EventCodec() (events.Codec, error) {
+	return events.NewCodec(c.Kafka.Format)
+}
+
+// EventRetry returns how failed event writes are retried
+func (c *Config) EventRetry() service.EventRetry {
+	return service.EventRetry{
+		Attempts:   c.Kafka.Retry.Attempts,
+		Backoff:    c.Kafka.Retry.Backoff,
+		MaxBackoff: c.Kafka.Retry.MaxBackoff,
+	}
+}
+
+// KafkaHealth returns the check probing the configured brokers
+func (c *Config) KafkaHealth() *service.KafkaHealth {
+	return service.NewKafkaHealth(service.MetadataProbe(c.Kafka.Brokers, c.Kafka.Topic), c.Kafka.Health.Timeout)
+}
+
+// FileLimit returns the cap on the files of an analysis
+func (c *Config) FileLimit() service.FileLimit {
+	return service.FileLimit{Max: c.Limits.MaxFiles, Policy: c.Limits.OverMaxFiles}
+}
+
+// AdvisoryDatabase loads the advisories dependencies are checked against,
+// nil when the check is disabled
+func (c *Config) AdvisoryDatabase() (*metrics.AdvisoryDatabase, error) {
+	if !c.Advisories.Enabled {
+		return nil, nil
+	}
+	return metrics.LoadAdvisoryDatabase(c.Advisories.Path)
+}
+
+// WebhookConfig returns how notification webhooks are signed and retried
+func (c *Config) WebhookConfig() webhook.Config {
+	return webhook.Config{
+		Secret:         c.Notifications.Webhook.Secret,
+		MaxAttempts:    c.Notifications.Webhook.MaxAttempts,
+		InitialBackoff: c.Notifications.Webhook.InitialBackoff,
+		MaxBackoff:     c.Notifications.Webhook.MaxBackoff,
+		Timeout:        c.Notifications.Webhook.Timeout,
+	}
+}
+
+// NotificationDispatcher returns the dispatcher of the configured
+// notifiers, nil when notifications are disabled
+func (c *Config) NotificationDispatcher(logger *logrus.Logger) *notify.Dispatcher {
+	if !c.Notifications.Enabled {
+		return nil
+	}
+	notifiers := []notify.Notifier{notify.NewWebhookNotifier(webhook.NewDeliverer(c.WebhookConfig(), logger))}
+	if c.Notifications.Email.Addr != "" {
+		notifiers = append(notifiers, notify.NewEmailNotifier(notify.EmailConfig{
+			Addr:     c.Notifications.Email.Addr,
+			From:     c.Notifications.Email.From,
+			Username: c.Notifications.Email.Username,
+			Password: c.Notifications.Email.Password,
+		}))
+	}
+	return notify.NewDispatcher(c.Notifications.BaseURL, logger, notifiers...)
+}
+
+// CalculatorConfig returns the configured metric formulas
+func (c *Config) CalculatorConfig() metrics.CalculatorConfig {
+	config := metrics.DefaultCalculatorConfig()
+	config.DebtWeights = c.Metrics.DebtWeights
+	return config
+}
+
+// ObjectStoreConfig returns the bucket of the s3 source backend
+func (c *Config) ObjectStoreConfig() services.ObjectStoreConfig {
+	return services.ObjectStoreConfig{
+		Endpoint:  c.Sources.S3.Endpoint,
+		Bucket:    c.Sources.S3.Bucket,
+		Region:    c.Sources.S3.Region,
+		Prefix:    c.Sources.S3.Prefix,
+		AccessKey: c.Sources.S3.AccessKey,
+		SecretKey: c.Sources.S3.SecretKey,
+	}
+}
+
+// SourceStore returns the store of the configured source backend, nil for
+// the database backend
+func (c *Config) SourceStore() (services.SourceStore, error) {
+	switch c.Sources.Backend {
+	case SourcesFilesystem:
+		return services.NewFileSourceStore(c.Sources.Path), nil
+	case SourcesS3:
+		store, err := services.NewObjectSourceStore(c.ObjectStoreConfig(), nil)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	return nil, nil
+}
+
+// RuleEngine returns the default rules with the configured overrides applied
+func (c *Config) RuleEngine() (*metrics.RuleEngine, error) {
+	engine := metrics.NewRuleEngine()
+	for name, override := range c.Rules {
+		rule, ok := engine.Config(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", metrics.ErrUnknownRule, name)
+		}
+		if override.Enabled != nil {
+			rule.Enabled = *override.Enabled
+		}
+		if override.Severity != "" {
+			rule.Severity = override.Severity
+		}
+		if override.Threshold != 0 {
+			rule.Threshold = override.Threshold
+		}
+		if err := engine.Configure(name, rule); err != nil {
+			return nil, err
+		}
+	}
+	return engine, nil
+}
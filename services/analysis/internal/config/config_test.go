@@ -0,0 +1,250 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/config"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/webhook"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+)
+
+// setRequiredEnv sets the settings without defaults
+func setRequiredEnv(t *testing.T) {
+	t.Setenv("DB_USER", "sa3d")
+	t.Setenv("DB_NAME", "sa3d_db")
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	setRequiredEnv(t)
+
+	cfg, err := config.Load(viper.New())
+	require.NoError(t, err)
+
+	assert.Equal(t, "8080", cfg.Server.Port)
+	assert.Equal(t, 15*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, "info", cfg.LogLevel)
+	assert.Equal(t, "localhost:6379", cfg.RedisAddr())
+	assert.True(t, cfg.Kafka.Enabled)
+	assert.Equal(t, []string{"localhost:9092"}, cfg.Kafka.Brokers)
+	assert.Equal(t, "analysis-events", cfg.Kafka.Topic)
+	assert.Equal(t, service.DefaultEventRetry, cfg.EventRetry())
+	assert.Equal(t, service.DefaultEventBuffer, cfg.Kafka.Buffer)
+	assert.Equal(t, service.DefaultKafkaHealthTimeout, cfg.Kafka.Health.Timeout)
+	assert.Equal(t, service.DefaultKafkaHealthInterval, cfg.Kafka.Health.Interval)
+	assert.Equal(t, "require", cfg.Database.SSLMode)
+	assert.Equal(t, service.DefaultMaxConcurrentAnalyses, cfg.Workers.MaxConcurrent)
+	assert.Equal(t, service.DefaultMaxDuration, cfg.Timeouts.Analysis)
+	assert.Equal(t, service.DefaultFileTimeout, cfg.Timeouts.File)
+	assert.Equal(t, service.FileLimit{Max: service.DefaultMaxFiles, Policy: service.FileLimitTruncate}, cfg.FileLimit())
+	assert.Empty(t, cfg.Rules)
+	assert.Equal(t, map[string]bool{services.FlagEventPublishing: true}, cfg.Features)
+	assert.Equal(t, 30*time.Second, cfg.FeatureRefreshInterval)
+	assert.False(t, cfg.Advisories.Enabled)
+	assert.Nil(t, cfg.NotificationDispatcher(nil), "notifications are off by default")
+	assert.Equal(t, webhook.DefaultConfig(), cfg.WebhookConfig())
+
+	advisories, err := cfg.AdvisoryDatabase()
+	require.NoError(t, err)
+	assert.Nil(t, advisories)
+
+	sources, err := cfg.SourceStore()
+	require.NoError(t, err)
+	assert.Nil(t, sources, "file content stays in the database by default")
+	assert.Equal(t, int64(services.DefaultInlineSourceLimit), cfg.Sources.InlineLimit)
+
+	codec, err := cfg.EventCodec()
+	require.NoError(t, err)
+	assert.Equal(t, "json", codec.Format())
+}
+
+func TestLoad_Environment(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("ANALYSIS_SERVER_PORT", "9090")
+	t.Setenv("ANALYSIS_REDIS_HOST", "redis")
+	t.Setenv("ANALYSIS_TIMEOUTS_FILE", "30s")
+	t.Setenv("KAFKA_BROKERS", "kafka-1:9092,kafka-2:9092")
+	// Variables read before the typed config still apply
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("EVENTS_FORMAT", "protobuf")
+	t.Setenv("ANALYSIS_MAX_CONCURRENT", "8")
+	// and the prefixed name wins over the old one
+	t.Setenv("ANALYSIS_MAX_DURATION", "1h")
+	t.Setenv("ANALYSIS_TIMEOUTS_ANALYSIS", "30m")
+
+	cfg, err := config.Load(viper.New())
+	require.NoError(t, err)
+
+	assert.Equal(t, "9090", cfg.Server.Port)
+	assert.Equal(t, "redis:6379", cfg.RedisAddr())
+	assert.Equal(t, 30*time.Second, cfg.Timeouts.File)
+	assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, cfg.Kafka.Brokers)
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, "protobuf", cfg.Kafka.Format)
+	assert.Equal(t, 8, cfg.Workers.MaxConcurrent)
+	assert.Equal(t, 30*time.Minute, cfg.Timeouts.Analysis)
+	assert.Equal(t, "sa3d", cfg.Database.User)
+}
+
+func TestLoad_SourceStore(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("ANALYSIS_SOURCES_BACKEND", "s3")
+	t.Setenv("ANALYSIS_SOURCES_S3_ENDPOINT", "http://minio:9000")
+	t.Setenv("ANALYSIS_SOURCES_S3_BUCKET", "sources")
+	t.Setenv("ANALYSIS_SOURCES_S3_ACCESS_KEY", "access")
+	t.Setenv("ANALYSIS_SOURCES_S3_SECRET_KEY", "secret")
+
+	cfg, err := config.Load(viper.New())
+	require.NoError(t, err)
+	sources, err := cfg.SourceStore()
+	require.NoError(t, err)
+	assert.IsType(t, &services.ObjectSourceStore{}, sources)
+
+	cfg.Sources.S3.Endpoint = "minio:9000"
+	sources, err = cfg.SourceStore()
+	assert.Error(t, err)
+	assert.Nil(t, sources, "a failed store is a nil interface")
+
+	t.Setenv("ANALYSIS_SOURCES_BACKEND", "filesystem")
+	t.Setenv("ANALYSIS_SOURCES_PATH", t.TempDir())
+	cfg, err = config.Load(viper.New())
+	require.NoError(t, err)
+	sources, err = cfg.SourceStore()
+	require.NoError(t, err)
+	assert.IsType(t, &services.FileSourceStore{}, sources)
+}
+
+func TestLoad_Notifications(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("ANALYSIS_NOTIFICATIONS_ENABLED", "true")
+	t.Setenv("ANALYSIS_NOTIFICATIONS_BASE_URL", "https://sa3d.example.com")
+	t.Setenv("ANALYSIS_NOTIFICATIONS_EMAIL_ADDR", "smtp.example.com:587")
+	t.Setenv("ANALYSIS_NOTIFICATIONS_EMAIL_FROM", "sa3d@example.com")
+	t.Setenv("ANALYSIS_NOTIFICATIONS_WEBHOOK_SECRET", "signing-secret")
+
+	cfg, err := config.Load(viper.New())
+	require.NoError(t, err)
+	assert.Equal(t, "signing-secret", cfg.WebhookConfig().Secret)
+	dispatcher := cfg.NotificationDispatcher(logrus.New())
+	require.NotNil(t, dispatcher)
+	assert.Equal(t, "https://sa3d.example.com/projects/p1/analyses/a1", dispatcher.Link("p1", "a1"))
+}
+
+func TestLoad_ConfigFile(t *testing.T) {
+	setRequiredEnv(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+server:
+  port: 7070
+kafka:
+  enabled: false
+  brokers: []
+rules:
+  long-function:
+    threshold: 80
+  too-many-parameters:
+    enabled: false
+  high-complexity:
+    severity: Critical
+metrics:
+  debt_weights:
+    python: 1.5
+`), 0o600))
+	t.Setenv("ANALYSIS_SERVER_PORT", "6060")
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	cfg, err := config.Load(v)
+	require.NoError(t, err)
+
+	assert.Equal(t, "6060", cfg.Server.Port, "the environment wins over the file")
+	assert.False(t, cfg.Kafka.Enabled, "brokers aren't needed without events")
+
+	assert.Equal(t, map[string]float64{"python": 1.5}, cfg.CalculatorConfig().DebtWeights)
+
+	engine, err := cfg.RuleEngine()
+	require.NoError(t, err)
+	longFunction, _ := engine.Config(metrics.RuleLongFunction)
+	defaults, _ := metrics.NewRuleEngine().Config(metrics.RuleLongFunction)
+	assert.Equal(t, 80.0, longFunction.Threshold)
+	assert.True(t, longFunction.Enabled, "unset fields keep their defaults")
+	assert.Equal(t, defaults.Severity, longFunction.Severity)
+	tooManyParameters, _ := engine.Config(metrics.RuleTooManyParameters)
+	assert.False(t, tooManyParameters.Enabled)
+	highComplexity, _ := engine.Config(metrics.RuleHighComplexity)
+	assert.Equal(t, metrics.SeverityCritical, highComplexity.Severity)
+}
+
+func TestLoad_ValidationFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		file string
+		want string
+	}{
+		{"missing database user", map[string]string{"DB_USER": ""}, "", "database.user is required"},
+		{"missing database name", map[string]string{"DB_NAME": ""}, "", "database.name is required"},
+		{"log level", map[string]string{"LOG_LEVEL": "loud"}, "", "log_level"},
+		{"negative concurrency", map[string]string{"ANALYSIS_WORKERS_MAX_CONCURRENT": "-1"}, "", "workers.max_concurrent"},
+		{"negative file concurrency", map[string]string{"ANALYSIS_WORKERS_MAX_CONCURRENT_FILES": "-1"}, "", "workers.max_concurrent_files"},
+		{"negative analysis timeout", map[string]string{"ANALYSIS_MAX_DURATION": "-1s"}, "", "timeouts.analysis"},
+		{"negative max files", map[string]string{"ANALYSIS_LIMITS_MAX_FILES": "-1"}, "", "limits"},
+		{"file limit policy", map[string]string{"ANALYSIS_LIMITS_OVER_MAX_FILES": "sample"}, "", "unknown policy"},
+		{"negative file timeout", map[string]string{"ANALYSIS_TIMEOUTS_FILE": "-1s"}, "", "timeouts.file"},
+		{"event format", map[string]string{"EVENTS_FORMAT": "xml"}, "", "kafka.format"},
+		{"no brokers", nil, "kafka:\n  brokers: []\n", "kafka.brokers"},
+		{"no event attempts", map[string]string{"ANALYSIS_KAFKA_RETRY_ATTEMPTS": "0"}, "", "kafka.retry.attempts"},
+		{"event backoff above maximum", nil, "kafka:\n  retry:\n    backoff: 10s\n    max_backoff: 1s\n", "kafka.retry.backoff"},
+		{"negative event buffer", map[string]string{"ANALYSIS_KAFKA_BUFFER": "-1"}, "", "kafka.buffer"},
+		{"no kafka health timeout", map[string]string{"ANALYSIS_KAFKA_HEALTH_TIMEOUT": "0s"}, "", "kafka.health.timeout"},
+		{"unknown rule", nil, "rules:\n  no-such-rule:\n    threshold: 1\n", "unknown rule"},
+		{"unknown severity", nil, "rules:\n  long-function:\n    severity: blocker\n", "unknown severity"},
+		{"advisories without path", map[string]string{"ANALYSIS_ADVISORIES_ENABLED": "true"}, "", "advisories.path"},
+		{"notifications without base url", map[string]string{"ANALYSIS_NOTIFICATIONS_ENABLED": "true"}, "", "notifications.base_url"},
+		{"email notifications without sender", map[string]string{"ANALYSIS_NOTIFICATIONS_ENABLED": "true", "ANALYSIS_NOTIFICATIONS_BASE_URL": "https://sa3d.example.com", "ANALYSIS_NOTIFICATIONS_EMAIL_ADDR": "smtp.example.com:587"}, "", "notifications.email.from"},
+		{"no webhook attempts", map[string]string{"ANALYSIS_NOTIFICATIONS_ENABLED": "true", "ANALYSIS_NOTIFICATIONS_BASE_URL": "https://sa3d.example.com", "ANALYSIS_NOTIFICATIONS_WEBHOOK_MAX_ATTEMPTS": "0"}, "", "notifications.webhook.max_attempts"},
+		{"negative debt weight", nil, "metrics:\n  debt_weights:\n    python: -1\n", "metrics.debt_weights"},
+		{"debt weight of unknown language", nil, "metrics:\n  debt_weights:\n    cobol: 2\n", "unknown language"},
+		{"negative inline limit", map[string]string{"ANALYSIS_SOURCES_INLINE_LIMIT": "-1"}, "", "sources.inline_limit"},
+		{"unknown source backend", map[string]string{"ANALYSIS_SOURCES_BACKEND": "git"}, "", "sources.backend"},
+		{"filesystem sources without path", map[string]string{"ANALYSIS_SOURCES_BACKEND": "filesystem"}, "", "sources.path"},
+		{"s3 sources without bucket", map[string]string{"ANALYSIS_SOURCES_BACKEND": "s3", "ANALYSIS_SOURCES_S3_ENDPOINT": "http://minio:9000"}, "", "sources.s3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRequiredEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			v := viper.New()
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				require.NoError(t, os.WriteFile(path, []byte(tt.file), 0o600))
+				v.SetConfigFile(path)
+			}
+
+			_, err := config.Load(v)
+			assert.ErrorIs(t, err, config.ErrInvalidConfig)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+
+	t.Run("reports every failure", func(t *testing.T) {
+		t.Setenv("DB_USER", "")
+		t.Setenv("DB_NAME", "")
+
+		_, err := config.Load(viper.New())
+		assert.ErrorContains(t, err, "database.user is required")
+		assert.ErrorContains(t, err, "database.name is required")
+	})
+}
@@ -0,0 +1,449 @@
+// Package handler exposes the analysis service over HTTP.
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// AnalysisHandler handles analysis endpoints
+type AnalysisHandler struct {
+	analysisService *service.AnalysisService
+	logger          *logrus.Logger
+}
+
+// NewAnalysisHandler creates a new analysis handler
+func NewAnalysisHandler(analysisService *service.AnalysisService, logger *logrus.Logger) *AnalysisHandler {
+	return &AnalysisHandler{
+		analysisService: analysisService,
+		logger:          logger,
+	}
+}
+
+// RegisterRoutes registers the analysis routes on the router
+func (h *AnalysisHandler) RegisterRoutes(router gin.IRouter) {
+	analysis := router.Group("/analysis")
+	{
+		analysis.POST("/start/:projectId", h.StartAnalysis)
+		analysis.GET("/status/:analysisId", h.GetAnalysisStatus)
+		analysis.DELETE("/cancel/:analysisId", h.CancelAnalysis)
+		analysis.GET("/results/:analysisId", h.GetAnalysisResults)
+		analysis.POST("/plan/:projectId", h.PlanAnalysis)
+		analysis.POST("/partial/:projectId", h.StartPartialAnalysis)
+		analysis.POST("/run-sync/:projectId", h.RunAnalysisSync)
+		analysis.POST("/snippet", h.AnalyzeSnippet)
+		analysis.GET("/callgraph/:analysisId", h.GetCallGraph)
+		analysis.GET("/issues/:analysisId", h.GetIssues)
+		analysis.GET("/compare", h.CompareAnalyses)
+	}
+}
+
+// StartAnalysis queues an analysis of the project. The body optionally
+// carries the run's options.
+func (h *AnalysisHandler) StartAnalysis(c *gin.Context) {
+	projectID := c.Param("projectId")
+
+	var opts service.AnalysisOptions
+	if err := c.ShouldBindJSON(&opts); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.analysisService.StartAnalysisWithOptions(c.Request.Context(), projectID, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		case errors.Is(err, service.ErrUnknownLanguage),
+			errors.Is(err, service.ErrInvalidCoverage):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			h.logger.WithError(err).WithFields(logrus.Fields{
+				"project_id": projectID,
+				"request_id": utils.RequestIDFromContext(c.Request.Context()),
+			}).Error("Failed to start analysis")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start analysis"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, withAnalysisLinks(c, job))
+}
+
+// GetAnalysisStatus returns an analysis job with its status and progress
+func (h *AnalysisHandler) GetAnalysisStatus(c *gin.Context) {
+	analysisID := c.Param("analysisId")
+
+	job, err := h.analysisService.GetAnalysis(c.Request.Context(), analysisID)
+	if err != nil {
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"analysis_id": analysisID,
+			"request_id":  utils.RequestIDFromContext(c.Request.Context()),
+		}).Error("Failed to get analysis")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get analysis"})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Analysis not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, withAnalysisLinks(c, job))
+}
+
+// CancelAnalysis cancels a pending or running analysis
+func (h *AnalysisHandler) CancelAnalysis(c *gin.Context) {
+	analysisID := c.Param("analysisId")
+
+	if err := h.analysisService.CancelAnalysis(c.Request.Context(), analysisID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrAnalysisNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Analysis not found"})
+		case errors.Is(err, service.ErrAnalysisFinished):
+			c.JSON(http.StatusConflict, gin.H{"error": "Analysis already finished"})
+		default:
+			h.logger.WithError(err).WithFields(logrus.Fields{
+				"analysis_id": analysisID,
+				"request_id":  utils.RequestIDFromContext(c.Request.Context()),
+			}).Error("Failed to cancel analysis")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel analysis"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"analysis_id": analysisID, "status": service.StatusCancelled})
+}
+
+// GetAnalysisResults returns an analysis with the results of its files
+func (h *AnalysisHandler) GetAnalysisResults(c *gin.Context) {
+	analysisID := c.Param("analysisId")
+
+	results, err := h.analysisService.GetAnalysisResults(c.Request.Context(), analysisID)
+	if err != nil {
+		if errors.Is(err, service.ErrAnalysisNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Analysis not found"})
+			return
+		}
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"analysis_id": analysisID,
+			"request_id":  utils.RequestIDFromContext(c.Request.Context()),
+		}).Error("Failed to get analysis results")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get analysis results"})
+		return
+	}
+
+	c.JSON(http.StatusOK, withResultsLinks(c, results))
+}
+
+// PlanAnalysis returns the files an analysis of the project would process
+func (h *AnalysisHandler) PlanAnalysis(c *gin.Context) {
+	projectID := c.Param("projectId")
+
+	plan, err := h.analysisService.PlanAnalysis(c.Request.Context(), projectID)
+	if err != nil {
+		if errors.Is(err, service.ErrProjectNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"project_id": projectID,
+			"request_id": utils.RequestIDFromContext(c.Request.Context()),
+		}).Error("Failed to plan analysis")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to plan analysis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// PartialAnalysisRequest names the files to re-analyze and the analysis
+// the rest are carried forward from
+type PartialAnalysisRequest struct {
+	BaseAnalysisID string   `json:"base_analysis_id" binding:"required"`
+	Paths          []string `json:"paths" binding:"required"`
+}
+
+// StartPartialAnalysis re-analyzes a subset of a project's files on top of
+// an earlier analysis
+func (h *AnalysisHandler) StartPartialAnalysis(c *gin.Context) {
+	projectID := c.Param("projectId")
+
+	var req PartialAnalysisRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.analysisService.StartPartialAnalysis(c.Request.Context(), projectID, req.Paths, req.BaseAnalysisID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		case errors.Is(err, service.ErrAnalysisNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Base analysis not found"})
+		case errors.Is(err, service.ErrNoPaths), errors.Is(err, service.ErrPathNotInProject):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			h.logger.WithError(err).WithFields(logrus.Fields{
+				"project_id": projectID,
+				"request_id": utils.RequestIDFromContext(c.Request.Context()),
+			}).Error("Failed to start partial analysis")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start partial analysis"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, withAnalysisLinks(c, job))
+}
+
+// RunEvent is one line of a synchronous analysis stream: "started" with
+// the new job, "file" with each file's result as it's analyzed, then
+// "finished" with the final job, or "error" if the run couldn't be followed
+// to the end
+type RunEvent struct {
+	Event  string                      `json:"event"`
+	Job    *service.AnalysisJob        `json:"job,omitempty"`
+	Result *service.FileAnalysisResult `json:"result,omitempty"`
+	Error  string                      `json:"error,omitempty"`
+}
+
+// RunAnalysisSync analyzes a project within the request, streaming its
+// progress as newline-delimited JSON. The analysis is cancelled if the
+// client disconnects. The body optionally carries the run's options. The
+// stream lasts as long as the run, past the server's write timeout.
+func (h *AnalysisHandler) RunAnalysisSync(c *gin.Context) {
+	projectID := c.Param("projectId")
+
+	var opts service.AnalysisOptions
+	if err := c.ShouldBindJSON(&opts); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Writers that can't set deadlines have none to clear
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	encoder := json.NewEncoder(c.Writer)
+	send := func(event RunEvent) {
+		if err := encoder.Encode(event); err == nil {
+			c.Writer.Flush()
+		}
+	}
+
+	job, err := h.analysisService.RunAnalysisSync(c.Request.Context(), projectID, opts, service.RunObserver{
+		Started: func(job *service.AnalysisJob) {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+			send(RunEvent{Event: "started", Job: job})
+		},
+		FileAnalyzed: func(result *service.FileAnalysisResult) {
+			send(RunEvent{Event: "file", Result: result})
+		},
+	})
+	if err != nil {
+		fields := logrus.Fields{
+			"project_id": projectID,
+			"request_id": utils.RequestIDFromContext(c.Request.Context()),
+		}
+		switch {
+		case errors.Is(err, service.ErrRequestCancelled):
+			// Nobody is left to read the stream
+			h.logger.WithFields(fields).Info("Synchronous analysis cancelled by client")
+		case c.Writer.Written():
+			h.logger.WithError(err).WithFields(fields).Error("Failed to finish synchronous analysis")
+			send(RunEvent{Event: "error", Error: "Failed to finish analysis"})
+		case errors.Is(err, service.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		case errors.Is(err, service.ErrUnknownLanguage),
+			errors.Is(err, service.ErrInvalidCoverage):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			h.logger.WithError(err).WithFields(fields).Error("Failed to run analysis")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run analysis"})
+		}
+		return
+	}
+
+	send(RunEvent{Event: "finished", Job: job})
+}
+
+// AnalyzeSnippet analyzes code sent as the raw request body, outside of
+// any project. The filename and language query parameters are optional
+// hints for detecting its language.
+func (h *AnalysisHandler) AnalyzeSnippet(c *gin.Context) {
+	maxSize := h.analysisService.MaxSnippetSize()
+	content, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Snippet exceeds %d bytes", maxSize)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read snippet"})
+		return
+	}
+
+	result, err := h.analysisService.AnalyzeSnippet(c.Request.Context(), service.Snippet{
+		Content:  content,
+		Filename: c.Query("filename"),
+		Language: c.Query("language"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSnippetTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrEmptySnippet),
+			errors.Is(err, service.ErrBinarySnippet),
+			errors.Is(err, service.ErrUnknownLanguage):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrUnsupportedLanguage):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			h.logger.WithError(err).WithFields(logrus.Fields{
+				"filename":   c.Query("filename"),
+				"request_id": utils.RequestIDFromContext(c.Request.Context()),
+			}).Error("Failed to analyze snippet")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze snippet"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetCallGraph returns the call graph of an analysis, optionally filtered to
+// a package or file, or expanded from a root function up to a depth
+func (h *AnalysisHandler) GetCallGraph(c *gin.Context) {
+	analysisID := c.Param("analysisId")
+
+	opts := service.CallGraphOptions{
+		Package: c.Query("package"),
+		File:    c.Query("file"),
+		Root:    c.Query("root"),
+	}
+	if depth := c.Query("depth"); depth != "" {
+		maxDepth, err := strconv.Atoi(depth)
+		if err != nil || maxDepth < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "depth must be a non-negative integer"})
+			return
+		}
+		opts.MaxDepth = maxDepth
+	}
+
+	graph, err := h.analysisService.GetCallGraph(c.Request.Context(), analysisID, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAnalysisNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Analysis not found"})
+		case errors.Is(err, service.ErrFunctionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Root function not found"})
+		default:
+			h.logger.WithError(err).WithFields(logrus.Fields{
+				"analysis_id": analysisID,
+				"request_id":  utils.RequestIDFromContext(c.Request.Context()),
+			}).Error("Failed to build call graph")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build call graph"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
+
+// GetIssues returns the issues of an analysis. The severity and type query
+// parameters take comma-separated lists; min_severity, file (a path, a
+// directory ending in "/" or a glob) and sort (file or severity) take one
+// value each.
+func (h *AnalysisHandler) GetIssues(c *gin.Context) {
+	analysisID := c.Param("analysisId")
+
+	issues, err := h.analysisService.GetIssues(c.Request.Context(), analysisID, service.IssueFilter{
+		Severities:  queryList(c, "severity"),
+		MinSeverity: c.Query("min_severity"),
+		Types:       queryList(c, "type"),
+		File:        c.Query("file"),
+		Sort:        c.Query("sort"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidIssueFilter):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrAnalysisNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Analysis not found"})
+		default:
+			h.logger.WithError(err).WithFields(logrus.Fields{
+				"analysis_id": analysisID,
+				"request_id":  utils.RequestIDFromContext(c.Request.Context()),
+			}).Error("Failed to get issues")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get issues"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, issues)
+}
+
+// queryList returns the values of a query parameter given either repeatedly
+// or as a comma-separated list
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, value := range c.QueryArray(key) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// CompareAnalyses returns the file-level changes from the base analysis to
+// the head analysis, detecting renames above the similarity threshold
+func (h *AnalysisHandler) CompareAnalyses(c *gin.Context) {
+	baseID, headID := c.Query("base"), c.Query("head")
+	if baseID == "" || headID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "base and head analysis IDs are required"})
+		return
+	}
+
+	var opts service.CompareOptions
+	if similarity := c.Query("similarity"); similarity != "" {
+		threshold, err := strconv.ParseFloat(similarity, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "similarity must be a number"})
+			return
+		}
+		opts.RenameSimilarity = threshold
+	}
+
+	comparison, err := h.analysisService.CompareAnalyses(c.Request.Context(), baseID, headID, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidSimilarity):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrAnalysisNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Analysis not found"})
+		default:
+			h.logger.WithError(err).WithFields(logrus.Fields{
+				"base_id":    baseID,
+				"head_id":    headID,
+				"request_id": utils.RequestIDFromContext(c.Request.Context()),
+			}).Error("Failed to compare analyses")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare analyses"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, comparison)
+}
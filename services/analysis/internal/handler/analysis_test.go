@@ -0,0 +1,319 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/handler"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// fakeProjectRepository serves a fixed set of projects from memory
+type fakeProjectRepository struct {
+	files map[string][]*repository.ProjectFile
+}
+
+func (r *fakeProjectRepository) GetByID(ctx context.Context, id string) (*repository.Project, error) {
+	if _, ok := r.files[id]; !ok {
+		return nil, nil
+	}
+	return &repository.Project{ID: id}, nil
+}
+
+func (r *fakeProjectRepository) GetProjectFiles(ctx context.Context, projectID string) ([]*repository.ProjectFile, error) {
+	return r.files[projectID], nil
+}
+
+func newTestRouter(projects *fakeProjectRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	analysisService := service.NewAnalysisService(projects, nil, nil, nil, nil, logger)
+
+	router := gin.New()
+	handler.NewAnalysisHandler(analysisService, logger).RegisterRoutes(router)
+	return router
+}
+
+func TestAnalysisHandler_PlanAnalysis(t *testing.T) {
+	router := newTestRouter(&fakeProjectRepository{
+		files: map[string][]*repository.ProjectFile{
+			"project-1": {
+				{Path: "main.go", Content: []byte("package main\n")},
+				{Path: "vendor/lib/lib.go", Content: []byte("package lib\n")},
+			},
+		},
+	})
+
+	t.Run("returns plan", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/analysis/plan/project-1", nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var plan service.AnalysisPlan
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &plan))
+		assert.Equal(t, "project-1", plan.ProjectID)
+		assert.Equal(t, []string{"main.go"}, plan.Files)
+		require.Len(t, plan.Skipped, 1)
+		assert.Equal(t, service.SkipReasonIgnored, plan.Skipped[0].Reason)
+	})
+
+	t.Run("unknown project", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/analysis/plan/missing", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handler.RequestID())
+	router.GET("/echo", func(c *gin.Context) {
+		c.String(http.StatusOK, utils.RequestIDFromContext(c.Request.Context()))
+	})
+
+	t.Run("adopts forwarded ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/echo", nil)
+		req.Header.Set(utils.RequestIDHeader, "req-123")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "req-123", w.Body.String())
+		assert.Equal(t, "req-123", w.Header().Get(utils.RequestIDHeader))
+	})
+
+	t.Run("generates missing ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/echo", nil)
+		router.ServeHTTP(w, req)
+
+		assert.NotEmpty(t, w.Body.String())
+		assert.Equal(t, w.Body.String(), w.Header().Get(utils.RequestIDHeader))
+	})
+}
+
+func TestAnalysisHandler_CompareAnalyses_BadRequest(t *testing.T) {
+	router := newTestRouter(&fakeProjectRepository{})
+
+	for _, query := range []string{
+		"base=a1",
+		"base=a1&head=a2&similarity=high",
+		"base=a1&head=a2&similarity=1.5",
+	} {
+		t.Run(query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/analysis/compare?"+query, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestAnalysisHandler_CompareAnalyses_VersionWarning(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	results := []*service.FileAnalysisResult{{FilePath: "main.go", Language: "go", LOC: 1}}
+	analyses := &fakeAnalysisRepository{jobs: map[string]*service.AnalysisJob{
+		"a1": {ID: "a1", AnalyzerVersion: "1.8.0", MetricsVersion: "1.0.0"},
+		"a2": {ID: "a2", AnalyzerVersion: "1.9.0", MetricsVersion: "1.0.0"},
+		"a3": {ID: "a3", AnalyzerVersion: "1.9.0", MetricsVersion: "1.0.0"},
+	}}
+	metricsRepo := &fakeMetricsRepository{results: map[string][]*service.FileAnalysisResult{"a1": results, "a2": results, "a3": results}}
+	analysisService := service.NewAnalysisService(&fakeProjectRepository{}, analyses, metricsRepo, nil, nil, logger)
+	router := gin.New()
+	handler.NewAnalysisHandler(analysisService, logger).RegisterRoutes(router)
+
+	compare := func(query string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/analysis/compare?"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	assert.Contains(t, compare("base=a1&head=a2")["warning"], "analyzer 1.8.0 vs 1.9.0")
+	assert.NotContains(t, compare("base=a2&head=a3"), "warning", "matching versions compare without a warning")
+}
+
+func TestAnalysisHandler_GetIssues_BadRequest(t *testing.T) {
+	router := newTestRouter(&fakeProjectRepository{})
+
+	for _, query := range []string{
+		"severity=major,blocker",
+		"severity=major&severity=warning",
+		"min_severity=high",
+		"sort=line",
+	} {
+		t.Run(query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/analysis/issues/a1?"+query, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestAnalysisHandler_StartPartialAnalysis_Errors(t *testing.T) {
+	router := newTestRouter(&fakeProjectRepository{files: map[string][]*repository.ProjectFile{}})
+
+	tests := []struct {
+		name       string
+		projectID  string
+		body       string
+		wantStatus int
+	}{
+		{"missing base analysis", "project-1", `{"paths":["main.go"]}`, http.StatusBadRequest},
+		{"missing paths", "project-1", `{"base_analysis_id":"a1"}`, http.StatusBadRequest},
+		{"malformed body", "project-1", `{"paths":`, http.StatusBadRequest},
+		{"unknown project", "project-1", `{"base_analysis_id":"a1","paths":["main.go"]}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/analysis/partial/"+tt.projectID, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestAnalysisHandler_RunAnalysisSync_Errors(t *testing.T) {
+	router := newTestRouter(&fakeProjectRepository{files: map[string][]*repository.ProjectFile{"project-1": {}}})
+
+	tests := []struct {
+		name       string
+		projectID  string
+		body       string
+		wantStatus int
+	}{
+		{"unknown project", "project-2", "", http.StatusNotFound},
+		{"unknown language", "project-1", `{"languages":["cobol"]}`, http.StatusBadRequest},
+		{"unknown priority", "project-1", `{"priority":"urgent"}`, http.StatusBadRequest},
+		{"malformed body", "project-1", `{"languages":`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/analysis/run-sync/"+tt.projectID, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+// slowAnalyzer takes delay over each file
+type slowAnalyzer struct {
+	delay time.Duration
+}
+
+func (a slowAnalyzer) Analyze(ctx context.Context, content []byte) (*analyzer.AnalysisResult, error) {
+	select {
+	case <-time.After(a.delay):
+		return &analyzer.AnalysisResult{Language: analyzer.LanguageCSharp}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (slowAnalyzer) Language() analyzer.Language {
+	return analyzer.LanguageCSharp
+}
+
+func TestAnalysisHandler_RunAnalysisSync_OutlastsWriteTimeout(t *testing.T) {
+	previous, err := analyzer.GetAnalyzer(analyzer.LanguageCSharp)
+	analyzer.RegisterAnalyzer(analyzer.LanguageCSharp, slowAnalyzer{delay: 150 * time.Millisecond})
+	t.Cleanup(func() {
+		if err != nil {
+			analyzer.UnregisterAnalyzer(analyzer.LanguageCSharp)
+			return
+		}
+		analyzer.RegisterAnalyzer(analyzer.LanguageCSharp, previous)
+	})
+
+	router, _ := newServerRouter(t, &fakeProjectRepository{files: map[string][]*repository.ProjectFile{
+		"project-1": {{Path: "Program.cs", Content: []byte("class Program {}\n")}},
+	}})
+
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := server.Client().Post(server.URL+"/analysis/run-sync/project-1", "application/json", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var events []string
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		var event handler.RunEvent
+		require.NoError(t, json.Unmarshal(lines.Bytes(), &event))
+		events = append(events, event.Event)
+	}
+	require.NoError(t, lines.Err())
+	assert.Equal(t, []string{"started", "file", "finished"}, events, "the stream isn't cut off by the write timeout")
+}
+
+func TestAnalysisHandler_AnalyzeSnippet(t *testing.T) {
+	router := newTestRouter(&fakeProjectRepository{})
+
+	tests := []struct {
+		name       string
+		query      string
+		body       string
+		wantStatus int
+	}{
+		{"go snippet", "?filename=main.go", "package main\n\nfunc main() {\n\tif true {\n\t\tprintln(1)\n\t}\n}\n", http.StatusOK},
+		{"unknown language", "", "just some notes\n", http.StatusUnprocessableEntity},
+		{"unknown language hint", "?language=cobol", "package main\n", http.StatusBadRequest},
+		{"empty", "?filename=main.go", "", http.StatusBadRequest},
+		{"too large", "?filename=main.go", strings.Repeat("// padding\n", 100_000), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/analysis/snippet"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "text/plain")
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var result service.SnippetResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			assert.Equal(t, "go", result.Language)
+			require.NotNil(t, result.Metrics)
+			assert.Equal(t, 1, result.Metrics.FunctionCount)
+			require.Len(t, result.Functions, 1)
+			assert.Equal(t, "main", result.Functions[0].Name)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// Links point at the gateway's routes when the request came through it:
+// the gateway forwards its scheme, host and the /api/v1 prefix it mounts
+// the analysis routes under. Project links only resolve through the gateway.
+
+// analysisResponse is an analysis job with its links, set when requested
+type analysisResponse struct {
+	*service.AnalysisJob
+	Links utils.Links `json:"_links,omitempty"`
+}
+
+// resultsResponse is an analysis's results with their links, set when
+// requested
+type resultsResponse struct {
+	*service.AnalysisResults
+	Links utils.Links `json:"_links,omitempty"`
+}
+
+// fileMetricsResponse is a file's metrics with their links, set when
+// requested
+type fileMetricsResponse struct {
+	*service.FileMetricsDetail
+	Links utils.Links `json:"_links,omitempty"`
+}
+
+// withAnalysisLinks adds the links of job when the client asked for them
+func withAnalysisLinks(c *gin.Context, job *service.AnalysisJob) analysisResponse {
+	response := analysisResponse{AnalysisJob: job}
+	if utils.LinksRequested(c.Request) {
+		response.Links = analysisLinks(utils.BaseURL(c.Request), job)
+	}
+	return response
+}
+
+// analysisLinks links an analysis to its status, project, results and
+// issues, and to cancelling it while it hasn't finished
+func analysisLinks(base string, job *service.AnalysisJob) utils.Links {
+	id := url.PathEscape(job.ID)
+	links := utils.Links{
+		"self":    {Href: base + "/analysis/status/" + id},
+		"project": {Href: base + "/projects/" + url.PathEscape(job.ProjectID)},
+		"results": {Href: base + "/analysis/results/" + id},
+		"issues":  {Href: base + "/analysis/issues/" + id},
+	}
+	if job.Status == service.StatusPending || job.Status == service.StatusRunning {
+		links["cancel"] = utils.Link{Href: base + "/analysis/cancel/" + id, Method: http.MethodDelete}
+	}
+	return links
+}
+
+// withResultsLinks adds the links of an analysis's results when the
+// client asked for them
+func withResultsLinks(c *gin.Context, results *service.AnalysisResults) resultsResponse {
+	response := resultsResponse{AnalysisResults: results}
+	if utils.LinksRequested(c.Request) && results.Analysis != nil {
+		links := analysisLinks(utils.BaseURL(c.Request), results.Analysis)
+		links["analysis"] = links["self"]
+		links["self"] = links["results"]
+		delete(links, "results")
+		response.Links = links
+	}
+	return response
+}
+
+// withFileMetricsLinks adds the links of a file's metrics when the client
+// asked for them
+func withFileMetricsLinks(c *gin.Context, detail *service.FileMetricsDetail) fileMetricsResponse {
+	response := fileMetricsResponse{FileMetricsDetail: detail}
+	if utils.LinksRequested(c.Request) {
+		base := utils.BaseURL(c.Request)
+		projectID := url.PathEscape(detail.ProjectID)
+		response.Links = utils.Links{
+			"self":     {Href: base + "/metrics/file/" + projectID + "/" + escapePath(detail.FilePath)},
+			"project":  {Href: base + "/projects/" + projectID},
+			"metrics":  {Href: base + "/metrics/project/" + projectID},
+			"analysis": {Href: base + "/analysis/status/" + url.PathEscape(detail.AnalysisID)},
+		}
+	}
+	return response
+}
+
+// escapePath escapes each segment of a slash-separated path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// MetricsHandler handles metrics endpoints
+type MetricsHandler struct {
+	metricsService *service.MetricsService
+	logger         *logrus.Logger
+}
+
+// NewMetricsHandler creates a new metrics handler
+func NewMetricsHandler(metricsService *service.MetricsService, logger *logrus.Logger) *MetricsHandler {
+	return &MetricsHandler{
+		metricsService: metricsService,
+		logger:         logger,
+	}
+}
+
+// RegisterRoutes registers the metrics routes on the router
+func (h *MetricsHandler) RegisterRoutes(router gin.IRouter) {
+	metrics := router.Group("/metrics")
+	{
+		// File paths have slashes of their own, escaped or not
+		metrics.GET("/file/:projectId/*filePath", h.GetFileMetrics)
+	}
+}
+
+// GetFileMetrics returns a file's metrics from the project's latest analysis
+func (h *MetricsHandler) GetFileMetrics(c *gin.Context) {
+	projectID := c.Param("projectId")
+	filePath := strings.TrimPrefix(c.Param("filePath"), "/")
+	if filePath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File path is required"})
+		return
+	}
+
+	detail, err := h.metricsService.GetFileMetrics(c.Request.Context(), projectID, filePath)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNoCompletedAnalysis):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project has no completed analysis"})
+		case errors.Is(err, service.ErrFileNotAnalyzed):
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found in latest analysis"})
+		default:
+			h.logger.WithError(err).WithFields(logrus.Fields{
+				"project_id": projectID,
+				"file":       filePath,
+				"request_id": utils.RequestIDFromContext(c.Request.Context()),
+			}).Error("Failed to get file metrics")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file metrics"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, withFileMetricsLinks(c, detail))
+}
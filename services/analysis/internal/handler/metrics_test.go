@@ -0,0 +1,145 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/handler"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// fakeAnalysisRepository holds jobs by ID and one completed analysis per
+// project
+type fakeAnalysisRepository struct {
+	jobs   map[string]*service.AnalysisJob
+	latest map[string]*service.AnalysisJob
+}
+
+func (r *fakeAnalysisRepository) CreateJob(ctx context.Context, job *service.AnalysisJob) error {
+	return nil
+}
+
+func (r *fakeAnalysisRepository) GetJob(ctx context.Context, jobID string) (*service.AnalysisJob, error) {
+	return r.jobs[jobID], nil
+}
+
+func (r *fakeAnalysisRepository) UpdateJob(ctx context.Context, job *service.AnalysisJob) error {
+	return nil
+}
+
+func (r *fakeAnalysisRepository) GetLatestJob(ctx context.Context, projectID string, status service.AnalysisStatus) (*service.AnalysisJob, error) {
+	return r.latest[projectID], nil
+}
+
+// fakeMetricsRepository serves stored file results by analysis
+type fakeMetricsRepository struct {
+	results map[string][]*service.FileAnalysisResult
+}
+
+func (r *fakeMetricsRepository) SaveAnalysisResults(ctx context.Context, analysisID string, results []*service.FileAnalysisResult, aggregateMetrics *service.AggregateMetrics) error {
+	return nil
+}
+
+func (r *fakeMetricsRepository) GetAnalysisResults(ctx context.Context, analysisID string) ([]*service.FileAnalysisResult, error) {
+	return r.results[analysisID], nil
+}
+
+func TestMetricsHandler_GetFileMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	metricsService := service.NewMetricsService(
+		&fakeAnalysisRepository{latest: map[string]*service.AnalysisJob{
+			"project-1": {ID: "a1", ProjectID: "project-1", Status: service.StatusCompleted},
+		}},
+		&fakeMetricsRepository{results: map[string][]*service.FileAnalysisResult{
+			"a1": {
+				{FilePath: "main.go", Language: "go", Complexity: 3},
+				{FilePath: "internal/server/routes.go", Language: "go", Complexity: 5,
+					Functions: []service.FunctionSummary{{Name: "Register", StartLine: 5, EndLine: 20, Complexity: 5}}},
+			},
+		}},
+		logger,
+	)
+	router := gin.New()
+	handler.NewMetricsHandler(metricsService, logger).RegisterRoutes(router)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantFile   string
+	}{
+		{"existing file", "/metrics/file/project-1/main.go", http.StatusOK, "main.go"},
+		{"path with subdirectories", "/metrics/file/project-1/internal/server/routes.go", http.StatusOK, "internal/server/routes.go"},
+		{"URL-encoded path", "/metrics/file/project-1/internal%2Fserver%2Froutes.go", http.StatusOK, "internal/server/routes.go"},
+		{"missing file", "/metrics/file/project-1/internal/missing.go", http.StatusNotFound, ""},
+		{"no file path", "/metrics/file/project-1/", http.StatusBadRequest, ""},
+		{"no completed analysis", "/metrics/file/project-2/main.go", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantFile == "" {
+				return
+			}
+			var detail service.FileMetricsDetail
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+			assert.Equal(t, "a1", detail.AnalysisID)
+			assert.Equal(t, tt.wantFile, detail.FilePath)
+		})
+	}
+}
+
+func TestMetricsHandler_GetFileMetrics_Links(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	metricsService := service.NewMetricsService(
+		&fakeAnalysisRepository{latest: map[string]*service.AnalysisJob{
+			"project-1": {ID: "a1", ProjectID: "project-1", Status: service.StatusCompleted},
+		}},
+		&fakeMetricsRepository{results: map[string][]*service.FileAnalysisResult{
+			"a1": {{FilePath: "internal/my server/routes.go", Language: "go"}},
+		}},
+		logger,
+	)
+	router := gin.New()
+	handler.NewMetricsHandler(metricsService, logger).RegisterRoutes(router)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics/file/project-1/internal/my%20server/routes.go?links=1", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "api.example.com")
+	req.Header.Set("X-Forwarded-Prefix", "/api/v1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var body struct {
+		FilePath string                `json:"file_path"`
+		Links    map[string]utils.Link `json:"_links"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "internal/my server/routes.go", body.FilePath)
+	assert.Equal(t, map[string]utils.Link{
+		"self":     {Href: "https://api.example.com/api/v1/metrics/file/project-1/internal/my%20server/routes.go"},
+		"project":  {Href: "https://api.example.com/api/v1/projects/project-1"},
+		"metrics":  {Href: "https://api.example.com/api/v1/metrics/project/project-1"},
+		"analysis": {Href: "https://api.example.com/api/v1/analysis/status/a1"},
+	}, body.Links)
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// RequestID adopts the request ID forwarded by the gateway, or generates one,
+// and stores it in the request context so service logs can be correlated
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(utils.RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+		contextkeys.RequestID.Set(c, requestID)
+		c.Request = c.Request.WithContext(utils.WithRequestID(c.Request.Context(), requestID))
+		c.Header(utils.RequestIDHeader, requestID)
+		c.Next()
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// NewRouter creates the analysis service's router with its middleware,
+// health, readiness and info endpoints and the analysis and metrics routes
+func NewRouter(analysisService *service.AnalysisService, metricsService *service.MetricsService, logger *logrus.Logger) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(RequestID())
+	router.Use(requestLogger(logger))
+
+	// Health check endpoint
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":    "healthy",
+			"service":   "analysis-service",
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		})
+	})
+
+	// Readiness reflects the dependencies the service can't work without
+	router.GET("/health/ready", func(c *gin.Context) {
+		checks := gin.H{"kafka": "disabled"}
+		var failures []string
+		if health := analysisService.KafkaHealth(); health != nil {
+			checks["kafka"] = "ok"
+			if err := health.Check(c.Request.Context()); err != nil {
+				checks["kafka"] = "unavailable"
+				failures = append(failures, err.Error())
+			}
+		}
+
+		if len(failures) > 0 {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status": "not ready",
+				"checks": checks,
+				"errors": failures,
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status": "ready",
+			"checks": checks,
+		})
+	})
+
+	// Basic info endpoint
+	router.GET("/info", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"service": "analysis-service",
+			"version": utils.Version,
+			"commit":  utils.BuildCommit(),
+			"status":  "running",
+		})
+	})
+
+	NewAnalysisHandler(analysisService, logger).RegisterRoutes(router)
+	NewMetricsHandler(metricsService, logger).RegisterRoutes(router)
+
+	return router
+}
+
+// requestLogger logs every request once it has been served
+func requestLogger(logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		logger.WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"status":     c.Writer.Status(),
+			"duration":   time.Since(start),
+			"client_ip":  c.ClientIP(),
+			"request_id": contextkeys.RequestID.GetString(c),
+		}).Info("Request processed")
+	}
+}
@@ -0,0 +1,333 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/handler"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// memoryJobRepository stores analysis jobs and their results in memory
+type memoryJobRepository struct {
+	mu      sync.Mutex
+	jobs    map[string]service.AnalysisJob
+	results map[string][]*service.FileAnalysisResult
+}
+
+func newMemoryJobRepository() *memoryJobRepository {
+	return &memoryJobRepository{
+		jobs:    make(map[string]service.AnalysisJob),
+		results: make(map[string][]*service.FileAnalysisResult),
+	}
+}
+
+func (r *memoryJobRepository) CreateJob(ctx context.Context, job *service.AnalysisJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[job.ID] = *job
+	return nil
+}
+
+func (r *memoryJobRepository) GetJob(ctx context.Context, jobID string) (*service.AnalysisJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[jobID]
+	if !ok {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (r *memoryJobRepository) UpdateJob(ctx context.Context, job *service.AnalysisJob) error {
+	return r.CreateJob(ctx, job)
+}
+
+func (r *memoryJobRepository) GetLatestJob(ctx context.Context, projectID string, status service.AnalysisStatus) (*service.AnalysisJob, error) {
+	return nil, nil
+}
+
+func (r *memoryJobRepository) SaveAnalysisResults(ctx context.Context, analysisID string, results []*service.FileAnalysisResult, aggregateMetrics *service.AggregateMetrics) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results[analysisID] = results
+	return nil
+}
+
+func (r *memoryJobRepository) GetAnalysisResults(ctx context.Context, analysisID string) ([]*service.FileAnalysisResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.results[analysisID], nil
+}
+
+// newServerRouter builds the full analysis router on in-memory repositories
+// and a miniredis-backed Redis
+func newServerRouter(t *testing.T, projects *fakeProjectRepository) (*gin.Engine, *memoryJobRepository) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	jobs := newMemoryJobRepository()
+	analysisService := service.NewAnalysisService(projects, jobs, jobs, client, nil, logger)
+	metricsService := service.NewMetricsService(jobs, jobs, logger)
+	return handler.NewRouter(analysisService, metricsService, logger), jobs
+}
+
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, path, nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRouter_AnalysisLifecycle(t *testing.T) {
+	router, _ := newServerRouter(t, &fakeProjectRepository{
+		files: map[string][]*repository.ProjectFile{
+			"project-1": {
+				{Path: "main.go", Content: []byte("package main\n\nfunc main() {\n\tif true {\n\t\tprintln(1)\n\t}\n}\n")},
+			},
+		},
+	})
+
+	w := serve(router, http.MethodPost, "/analysis/start/project-1", "")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var started service.AnalysisJob
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &started))
+	assert.Equal(t, "project-1", started.ProjectID)
+	require.NotEmpty(t, started.ID)
+
+	var status service.AnalysisJob
+	require.Eventually(t, func() bool {
+		w := serve(router, http.MethodGet, "/analysis/status/"+started.ID, "")
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &status) != nil {
+			return false
+		}
+		return status.Status.Finished()
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, service.StatusCompleted, status.Status, status.Error)
+
+	w = serve(router, http.MethodGet, "/analysis/results/"+started.ID, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var results service.AnalysisResults
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Equal(t, started.ID, results.Analysis.ID)
+	require.Len(t, results.Files, 1)
+	assert.Equal(t, "main.go", results.Files[0].FilePath)
+
+	w = serve(router, http.MethodDelete, "/analysis/cancel/"+started.ID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestRouter_StartAnalysis_Errors(t *testing.T) {
+	router, _ := newServerRouter(t, &fakeProjectRepository{
+		files: map[string][]*repository.ProjectFile{"project-1": nil},
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"unknown project", "/analysis/start/missing", "", http.StatusNotFound},
+		{"unknown language", "/analysis/start/project-1", `{"languages":["cobol"]}`, http.StatusBadRequest},
+		{"malformed body", "/analysis/start/project-1", `{"languages":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, http.MethodPost, tt.path, tt.body)
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestRouter_CancelAnalysis(t *testing.T) {
+	router, jobs := newServerRouter(t, &fakeProjectRepository{})
+	require.NoError(t, jobs.CreateJob(context.Background(), &service.AnalysisJob{
+		ID:        "pending-1",
+		ProjectID: "project-1",
+		Status:    service.StatusPending,
+		StartedAt: time.Now(),
+	}))
+
+	w := serve(router, http.MethodDelete, "/analysis/cancel/pending-1", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, string(service.StatusCancelled), body["status"])
+
+	job, err := jobs.GetJob(context.Background(), "pending-1")
+	require.NoError(t, err)
+	assert.Equal(t, service.StatusCancelled, job.Status)
+
+	w = serve(router, http.MethodDelete, "/analysis/cancel/pending-1", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestRouter_UnknownAnalysis(t *testing.T) {
+	router, _ := newServerRouter(t, &fakeProjectRepository{})
+
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/analysis/status/missing"},
+		{http.MethodDelete, "/analysis/cancel/missing"},
+		{http.MethodGet, "/analysis/results/missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := serve(router, tt.method, tt.path, "")
+			assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestRouter_Health(t *testing.T) {
+	router, _ := newServerRouter(t, &fakeProjectRepository{})
+
+	w := serve(router, http.MethodGet, "/health", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get(utils.RequestIDHeader))
+}
+
+func TestRouter_Readiness(t *testing.T) {
+	// A port nothing listens on stands in for a broker that is down
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	newRouter := func(health *service.KafkaHealth) *gin.Engine {
+		gin.SetMode(gin.TestMode)
+		logger := logrus.New()
+		logger.SetLevel(logrus.FatalLevel)
+		jobs := newMemoryJobRepository()
+		analysisService := service.NewAnalysisService(&fakeProjectRepository{}, jobs, jobs, nil, nil, logger)
+		if health != nil {
+			analysisService.SetKafkaHealth(health)
+		}
+		return handler.NewRouter(analysisService, service.NewMetricsService(jobs, jobs, logger), logger)
+	}
+
+	tests := []struct {
+		name   string
+		health *service.KafkaHealth
+		status int
+		kafka  string
+	}{
+		{"publishing disabled", nil, http.StatusOK, "disabled"},
+		{"unreachable broker", service.NewKafkaHealth(service.MetadataProbe([]string{unreachable}, "analysis-events"), time.Second), http.StatusServiceUnavailable, "unavailable"},
+		{"reachable broker", service.NewKafkaHealth(func(context.Context) error { return nil }, time.Second), http.StatusOK, "ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(newRouter(tt.health), http.MethodGet, "/health/ready", "")
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+
+			var body struct {
+				Checks map[string]string `json:"checks"`
+				Errors []string          `json:"errors"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.kafka, body.Checks["kafka"])
+			if tt.status != http.StatusOK {
+				require.Len(t, body.Errors, 1)
+				assert.Contains(t, body.Errors[0], unreachable)
+				assert.ErrorIs(t, tt.health.Err(), service.ErrKafkaUnavailable)
+			}
+		})
+	}
+}
+
+func TestRouter_Links(t *testing.T) {
+	router, jobs := newServerRouter(t, &fakeProjectRepository{})
+	for _, job := range []*service.AnalysisJob{
+		{ID: "running-1", ProjectID: "project-1", Status: service.StatusRunning, StartedAt: time.Now()},
+		{ID: "completed-1", ProjectID: "project-1", Status: service.StatusCompleted, StartedAt: time.Now()},
+	} {
+		require.NoError(t, jobs.CreateJob(context.Background(), job))
+	}
+
+	get := func(path string, header map[string]string) map[string]utils.Link {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var body struct {
+			ID    string                `json:"id"`
+			Links map[string]utils.Link `json:"_links"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Links
+	}
+
+	t.Run("not requested", func(t *testing.T) {
+		assert.Nil(t, get("/analysis/status/running-1", nil))
+		assert.Nil(t, get("/analysis/status/running-1?links=false", map[string]string{"Accept": utils.HALMediaType}))
+	})
+
+	t.Run("query flag", func(t *testing.T) {
+		assert.Equal(t, map[string]utils.Link{
+			"self":    {Href: "http://example.com/analysis/status/running-1"},
+			"project": {Href: "http://example.com/projects/project-1"},
+			"results": {Href: "http://example.com/analysis/results/running-1"},
+			"issues":  {Href: "http://example.com/analysis/issues/running-1"},
+			"cancel":  {Href: "http://example.com/analysis/cancel/running-1", Method: http.MethodDelete},
+		}, get("/analysis/status/running-1?links=true", nil))
+	})
+
+	forwarded := map[string]string{
+		"Accept":             "application/json, " + utils.HALMediaType + ";q=0.9",
+		"X-Forwarded-Proto":  "https",
+		"X-Forwarded-Host":   "api.example.com",
+		"X-Forwarded-Prefix": "/api/v1/",
+	}
+
+	t.Run("through the gateway", func(t *testing.T) {
+		links := get("/analysis/status/completed-1", forwarded)
+		assert.Equal(t, "https://api.example.com/api/v1/analysis/status/completed-1", links["self"].Href)
+		assert.Equal(t, "https://api.example.com/api/v1/projects/project-1", links["project"].Href)
+		assert.NotContains(t, links, "cancel", "a finished analysis can't be cancelled")
+	})
+
+	t.Run("results", func(t *testing.T) {
+		assert.Equal(t, map[string]utils.Link{
+			"self":     {Href: "https://api.example.com/api/v1/analysis/results/completed-1"},
+			"analysis": {Href: "https://api.example.com/api/v1/analysis/status/completed-1"},
+			"project":  {Href: "https://api.example.com/api/v1/projects/project-1"},
+			"issues":   {Href: "https://api.example.com/api/v1/analysis/issues/completed-1"},
+		}, get("/analysis/results/completed-1", forwarded))
+	})
+}
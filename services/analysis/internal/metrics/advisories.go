@@ -0,0 +1,315 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidAdvisories is returned for an advisory database that can't be read
+var ErrInvalidAdvisories = errors.New("invalid advisory database")
+
+// Advisory is a known vulnerability of some versions of a package, in the
+// OSV schema (https://ossf.github.io/osv-schema/) with the fields the check
+// needs
+type Advisory struct {
+	ID       string   `json:"id"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Summary  string   `json:"summary"`
+	Affected []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string `json:"type"`
+			Events []struct {
+				Introduced   string `json:"introduced,omitempty"`
+				Fixed        string `json:"fixed,omitempty"`
+				LastAffected string `json:"last_affected,omitempty"`
+			} `json:"events"`
+		} `json:"ranges,omitempty"`
+		Versions []string `json:"versions,omitempty"`
+	} `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity,omitempty"`
+	} `json:"database_specific"`
+}
+
+// advisorySeverities maps the severities advisory databases publish to
+// issue severities
+var advisorySeverities = map[string]string{
+	"CRITICAL": SeverityCritical,
+	"HIGH":     SeverityMajor,
+	"MODERATE": SeverityMinor,
+	"MEDIUM":   SeverityMinor,
+	"LOW":      SeverityInfo,
+}
+
+// Severity returns the issue severity of the advisory; advisories without
+// a known severity are major
+func (a *Advisory) Severity() string {
+	if severity, ok := advisorySeverities[strings.ToUpper(a.DatabaseSpecific.Severity)]; ok {
+		return severity
+	}
+	return SeverityMajor
+}
+
+// Affects reports whether the advisory applies to a version of a package
+func (a *Advisory) Affects(ecosystem, name, version string) bool {
+	for _, affected := range a.Affected {
+		if affected.Package.Ecosystem != ecosystem || !samePackage(ecosystem, affected.Package.Name, name) {
+			continue
+		}
+		for _, listed := range affected.Versions {
+			if compareVersions(listed, version) == 0 {
+				return true
+			}
+		}
+		for _, r := range affected.Ranges {
+			if r.Type == "GIT" {
+				continue
+			}
+			// Events are sorted by version: each introduced opens a range
+			// closed by the next fixed or last_affected
+			introduced, open := "", false
+			for _, event := range r.Events {
+				switch {
+				case event.Introduced != "":
+					introduced, open = event.Introduced, true
+				case event.Fixed != "" && open:
+					if inRange(version, introduced, event.Fixed, false) {
+						return true
+					}
+					open = false
+				case event.LastAffected != "" && open:
+					if inRange(version, introduced, event.LastAffected, true) {
+						return true
+					}
+					open = false
+				}
+			}
+			if open && inRange(version, introduced, "", false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AdvisoryDatabase matches dependencies against known vulnerabilities
+type AdvisoryDatabase struct {
+	// byPackage indexes the advisories by ecosystem and package name
+	byPackage map[string][]*Advisory
+	count     int
+}
+
+// NewAdvisoryDatabase creates a database of the advisories
+func NewAdvisoryDatabase(advisories []Advisory) *AdvisoryDatabase {
+	db := &AdvisoryDatabase{byPackage: make(map[string][]*Advisory)}
+	for i := range advisories {
+		advisory := &advisories[i]
+		seen := make(map[string]bool)
+		for _, affected := range advisory.Affected {
+			key := packageKey(affected.Package.Ecosystem, affected.Package.Name)
+			if !seen[key] {
+				seen[key] = true
+				db.byPackage[key] = append(db.byPackage[key], advisory)
+			}
+		}
+	}
+	db.count = len(advisories)
+	return db
+}
+
+// LoadAdvisoryDatabase reads OSV advisories from a path: a JSON file
+// holding one advisory or an array of them, or a directory of such files
+// as an extracted OSV export is
+func LoadAdvisoryDatabase(path string) (*AdvisoryDatabase, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAdvisories, err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidAdvisories, err)
+		}
+		sort.Strings(files)
+	}
+
+	var advisories []Advisory
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidAdvisories, err)
+		}
+		parsed, err := parseAdvisories(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidAdvisories, file, err)
+		}
+		advisories = append(advisories, parsed...)
+	}
+	return NewAdvisoryDatabase(advisories), nil
+}
+
+// Len returns how many advisories the database holds
+func (db *AdvisoryDatabase) Len() int {
+	return db.count
+}
+
+// CheckManifest reports a vulnerability issue, at the declaring line, for
+// every advisory affecting a dependency the manifest declares. File is left
+// empty, as with RuleEngine.
+func (db *AdvisoryDatabase) CheckManifest(filePath string, content []byte) []Issue {
+	ecosystem := ManifestEcosystem(filePath)
+	if ecosystem == "" {
+		return nil
+	}
+
+	var issues []Issue
+	for _, dep := range extractManifest(filePath, content) {
+		for _, advisory := range db.byPackage[packageKey(ecosystem, dep.Name)] {
+			if !advisory.Affects(ecosystem, dep.Name, dep.Version) {
+				continue
+			}
+			message := fmt.Sprintf("%s %s has a known vulnerability (%s)", dep.Name, dep.Version, advisory.ID)
+			if advisory.Summary != "" {
+				message += ": " + advisory.Summary
+			}
+			issues = append(issues, Issue{
+				Type:     IssueTypeVulnerability,
+				Severity: advisory.Severity(),
+				Line:     dep.line,
+				Message:  message,
+				Rule:     advisory.ID,
+			})
+		}
+	}
+	return issues
+}
+
+func parseAdvisories(data []byte) ([]Advisory, error) {
+	data = []byte(strings.TrimSpace(string(data)))
+	if len(data) > 0 && data[0] == '[' {
+		var advisories []Advisory
+		err := json.Unmarshal(data, &advisories)
+		return advisories, err
+	}
+	var advisory Advisory
+	if err := json.Unmarshal(data, &advisory); err != nil {
+		return nil, err
+	}
+	return []Advisory{advisory}, nil
+}
+
+// packageKey identifies a package within the database; PyPI names are
+// case-insensitive
+func packageKey(ecosystem, name string) string {
+	if ecosystem == EcosystemPyPI {
+		name = normalizePyPIName(name)
+	}
+	return ecosystem + "/" + name
+}
+
+func samePackage(ecosystem, a, b string) bool {
+	return packageKey(ecosystem, a) == packageKey(ecosystem, b)
+}
+
+// normalizePyPIName folds a PyPI project name as PEP 503 does
+func normalizePyPIName(name string) string {
+	name = strings.ToLower(name)
+	return strings.NewReplacer("_", "-", ".", "-").Replace(name)
+}
+
+// inRange reports whether version is at least introduced, "0" being the
+// first version, and below end, or at most end when inclusive. An empty
+// end leaves the range open.
+func inRange(version, introduced, end string, inclusive bool) bool {
+	if introduced != "0" && compareVersions(version, introduced) < 0 {
+		return false
+	}
+	if end == "" {
+		return true
+	}
+	cmp := compareVersions(version, end)
+	return cmp < 0 || (inclusive && cmp == 0)
+}
+
+// compareVersions orders dotted versions such as v1.2.3 numerically, a
+// pre-release (1.2.3-rc.1) coming before its release. Build metadata is
+// ignored.
+func compareVersions(a, b string) int {
+	a, aPre := splitVersion(a)
+	b, bPre := splitVersion(b)
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		if cmp := compareVersionPart(versionPart(aParts, i), versionPart(bParts, i)); cmp != 0 {
+			return cmp
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	aIDs, bIDs := strings.Split(aPre, "."), strings.Split(bPre, ".")
+	for i := 0; i < len(aIDs) && i < len(bIDs); i++ {
+		if cmp := compareVersionPart(aIDs[i], bIDs[i]); cmp != 0 {
+			return cmp
+		}
+	}
+	return compareInts(len(aIDs), len(bIDs))
+}
+
+// splitVersion strips the v prefix and build metadata of a version and
+// splits off its pre-release
+func splitVersion(version string) (string, string) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	version, _, _ = strings.Cut(version, "+")
+	release, pre, _ := strings.Cut(version, "-")
+	return release, pre
+}
+
+func versionPart(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return "0"
+}
+
+// compareVersionPart compares numeric parts as numbers and others as text,
+// numbers coming first
+func compareVersionPart(a, b string) int {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareInts(aNum, bNum)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
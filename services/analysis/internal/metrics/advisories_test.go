@@ -0,0 +1,156 @@
+package metrics_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+// seededAdvisories are OSV entries for packages the test manifests declare
+const seededAdvisories = `[
+  {
+    "id": "GHSA-jfh8-c2jp-5v3q",
+    "summary": "Prototype pollution in lodash",
+    "affected": [{
+      "package": {"ecosystem": "npm", "name": "lodash"},
+      "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "4.17.21"}]}]
+    }],
+    "database_specific": {"severity": "HIGH"}
+  },
+  {
+    "id": "GO-2022-0969",
+    "summary": "Denial of service in net/http",
+    "affected": [{
+      "package": {"ecosystem": "Go", "name": "golang.org/x/net"},
+      "ranges": [{"type": "SEMVER", "events": [{"introduced": "0.0.0-20220101000000-000000000000"}, {"fixed": "0.0.0-20220906165146-f3363e06e74c"}]}]
+    }]
+  },
+  {
+    "id": "PYSEC-2023-74",
+    "summary": "Unintended leak of Proxy-Authorization header",
+    "affected": [{
+      "package": {"ecosystem": "PyPI", "name": "requests"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "2.3.0"}, {"last_affected": "2.30.0"}]}],
+      "versions": ["2.31.0rc1"]
+    }],
+    "database_specific": {"severity": "MODERATE"}
+  }
+]`
+
+func seededDatabase(t *testing.T) *metrics.AdvisoryDatabase {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "advisories.json")
+	require.NoError(t, os.WriteFile(path, []byte(seededAdvisories), 0o600))
+	db, err := metrics.LoadAdvisoryDatabase(path)
+	require.NoError(t, err)
+	require.Equal(t, 3, db.Len())
+	return db
+}
+
+func TestAdvisoryDatabase_CheckManifest(t *testing.T) {
+	db := seededDatabase(t)
+
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		rules    []string
+		line     int
+		severity string
+	}{
+		{
+			name:     "vulnerable npm caret range",
+			path:     "web/package.json",
+			content:  "{\n  \"name\": \"web\",\n  \"dependencies\": {\n    \"lodash\": \"^4.17.15\",\n    \"react\": \"18.2.0\"\n  }\n}\n",
+			rules:    []string{"GHSA-jfh8-c2jp-5v3q"},
+			line:     4,
+			severity: metrics.SeverityMajor,
+		},
+		{
+			name:    "fixed npm version",
+			path:    "package.json",
+			content: `{"dependencies": {"lodash": "4.17.21"}}`,
+		},
+		{
+			name:     "vulnerable go pseudo-version",
+			path:     "go.mod",
+			content:  "module example.com/app\n\ngo 1.21\n\nrequire (\n\tgithub.com/gin-gonic/gin v1.10.0\n\tgolang.org/x/net v0.0.0-20220722155237-a158d28d115b // indirect\n)\n",
+			rules:    []string{"GO-2022-0969"},
+			line:     7,
+			severity: metrics.SeverityMajor,
+		},
+		{
+			name:     "vulnerable pinned requirement",
+			path:     "requirements.txt",
+			content:  "# runtime\nflask==2.3.2\nRequests[socks]==2.28.1 ; python_version >= \"3.8\"\n",
+			rules:    []string{"PYSEC-2023-74"},
+			line:     3,
+			severity: metrics.SeverityMinor,
+		},
+		{
+			name:     "explicitly listed version",
+			path:     "requirements.txt",
+			content:  "requests==2.31.0rc1\n",
+			rules:    []string{"PYSEC-2023-74"},
+			line:     1,
+			severity: metrics.SeverityMinor,
+		},
+		{
+			name:    "unpinned requirement",
+			path:    "requirements.txt",
+			content: "requests>=2.0\n",
+		},
+		{
+			name:    "not a manifest",
+			path:    "main.go",
+			content: "package main\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := db.CheckManifest(tt.path, []byte(tt.content))
+			var rules []string
+			for _, issue := range issues {
+				rules = append(rules, issue.Rule)
+				assert.Equal(t, metrics.IssueTypeVulnerability, issue.Type)
+				assert.Equal(t, tt.severity, issue.Severity)
+				assert.Equal(t, tt.line, issue.Line)
+			}
+			assert.Equal(t, tt.rules, rules)
+		})
+	}
+}
+
+func TestLoadAdvisoryDatabase_Directory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "GHSA-1.json"), []byte(`{"id": "GHSA-1", "affected": [{"package": {"ecosystem": "npm", "name": "left-pad"}, "versions": ["1.0.0"]}]}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not an advisory"), 0o600))
+
+	db, err := metrics.LoadAdvisoryDatabase(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, db.Len())
+	assert.Len(t, db.CheckManifest("package.json", []byte(`{"dependencies": {"left-pad": "1.0.0"}}`)), 1)
+
+	_, err = metrics.LoadAdvisoryDatabase(filepath.Join(dir, "missing.json"))
+	assert.ErrorIs(t, err, metrics.ErrInvalidAdvisories)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o600))
+	_, err = metrics.LoadAdvisoryDatabase(dir)
+	assert.ErrorIs(t, err, metrics.ErrInvalidAdvisories)
+}
+
+func TestExtractDependencies(t *testing.T) {
+	deps := metrics.ExtractDependencies("go.mod", []byte("module m\n\nrequire github.com/google/uuid v1.6.0\n"))
+	assert.Equal(t, []models.Dependency{
+		{Name: "github.com/google/uuid", Version: "v1.6.0", Type: metrics.DependencyType, Source: "go.mod"},
+	}, deps)
+
+	assert.Empty(t, metrics.ExtractDependencies("package.json", []byte(`{"dependencies": {"app": "file:../app", "next": "latest"}}`)))
+}
@@ -2,28 +2,34 @@ package metrics
 
 import (
 	"math"
-	"strings"
 
 	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
 )
 
+// MetricsVersion identifies the metric formulas. Bump it whenever a change
+// would alter calculated values so results remain comparable.
+const MetricsVersion = "1.5.0"
+
 // FileMetrics represents metrics for a single file
 type FileMetrics struct {
-	LOC                  int     // Lines of Code
-	CodeLines            int     // Actual code lines (excluding comments and blanks)
-	CommentLines         int     // Comment lines
-	BlankLines           int     // Blank lines
-	CyclomaticComplexity int     // Total cyclomatic complexity
-	FunctionCount        int     // Number of functions
-	ClassCount           int     // Number of classes
-	ImportCount          int     // Number of imports
-	AverageComplexity    float64 // Average complexity per function
-	MaxComplexity        int     // Maximum complexity in any function
-	MaintainabilityIndex float64 // Maintainability index (0-100)
-	TechnicalDebt        float64 // Technical debt in hours
-	CodeSmells           int     // Number of code smells detected
-	DuplicationRatio     float64 // Code duplication ratio (0-1)
-	TestCoverage         float64 // Test coverage percentage (0-100)
+	LOC                  int     `json:"loc"`                   // Lines of Code
+	CodeLines            int     `json:"code_lines"`            // Actual code lines (excluding comments and blanks)
+	CommentLines         int     `json:"comment_lines"`         // Comment lines
+	BlankLines           int     `json:"blank_lines"`           // Blank lines
+	CyclomaticComplexity int     `json:"cyclomatic_complexity"` // Total cyclomatic complexity
+	FunctionCount        int     `json:"functions"`             // Number of functions
+	ClassCount           int     `json:"classes"`               // Number of classes
+	ImportCount          int     `json:"imports"`               // Number of imports
+	AverageComplexity    float64 `json:"average_complexity"`    // Average complexity per function
+	MaxComplexity        int     `json:"max_complexity"`        // Maximum complexity in any function
+	MaintainabilityIndex float64 `json:"maintainability"`       // Maintainability index (0-100)
+	TechnicalDebt        float64 `json:"technical_debt"`        // Technical debt in hours
+	CodeSmells           int     `json:"code_smells"`           // Number of code smells detected
+	Issues               []Issue `json:"-"`                     // Rule violations behind CodeSmells, reported on their own
+	DuplicationRatio     float64 `json:"duplication_ratio"`     // Code duplication ratio (0-1)
+	TestCoverage         float64 `json:"test_coverage"`         // Test coverage percentage (0-100)
+	CoverageEstimated    bool    `json:"coverage_estimated"`    // TestCoverage is estimated rather than measured
+	Empty                bool    `json:"empty"`                 // File has no code: it is empty, or whitespace and comments only
 }
 
 // Calculator calculates metrics from analysis results
@@ -32,6 +38,8 @@ type Calculator struct {
 	complexityThreshold int
 	locThreshold        int
 	duplicationWindow   int
+	rules               *RuleEngine
+	config              CalculatorConfig
 }
 
 // NewCalculator creates a new metrics calculator
@@ -40,11 +48,43 @@ func NewCalculator() *Calculator {
 		complexityThreshold: 10,  // Functions with complexity > 10 are considered complex
 		locThreshold:        500, // Files with > 500 LOC are considered large
 		duplicationWindow:   6,   // Minimum lines for duplication detection
+		rules:               NewRuleEngine(),
+		config:              DefaultCalculatorConfig(),
+	}
+}
+
+// NewCalculatorWithRules creates a calculator that detects code smells with
+// the given rule engine
+func NewCalculatorWithRules(rules *RuleEngine) *Calculator {
+	c := NewCalculator()
+	if rules != nil {
+		c.rules = rules
 	}
+	return c
+}
+
+// NewCalculatorWithConfig creates a calculator that detects code smells
+// with the given rule engine and calculates metrics with config
+func NewCalculatorWithConfig(rules *RuleEngine, config CalculatorConfig) *Calculator {
+	c := NewCalculatorWithRules(rules)
+	c.config = config
+	return c
 }
 
-// Calculate calculates metrics from analysis result
+// Calculate calculates metrics from analysis result. Without the source,
+// line counts are estimated from the declarations; see CalculateWithSource.
 func (c *Calculator) Calculate(result *analyzer.AnalysisResult) *FileMetrics {
+	return c.calculate(result, nil)
+}
+
+// CalculateWithSource calculates metrics from an analysis result and the
+// file content it came from, classifying every line as code, comment or
+// blank with the rules of the file's language
+func (c *Calculator) CalculateWithSource(result *analyzer.AnalysisResult, content []byte) *FileMetrics {
+	return c.calculate(result, content)
+}
+
+func (c *Calculator) calculate(result *analyzer.AnalysisResult, content []byte) *FileMetrics {
 	metrics := &FileMetrics{
 		FunctionCount: len(result.Functions),
 		ClassCount:    len(result.Classes),
@@ -52,7 +92,19 @@ func (c *Calculator) Calculate(result *analyzer.AnalysisResult) *FileMetrics {
 	}
 
 	// Count lines
-	c.countLines(result, metrics)
+	if content != nil {
+		c.classifyLines(result, content, metrics)
+	} else {
+		c.countLines(result, metrics)
+	}
+
+	// An empty file has no code to measure, so none of the ratios apply
+	if metrics.CodeLines == 0 && metrics.FunctionCount == 0 && metrics.ClassCount == 0 {
+		metrics.Empty = true
+		metrics.MaintainabilityIndex = 100.0
+		metrics.CoverageEstimated = true
+		return metrics
+	}
 
 	// Calculate complexity metrics
 	c.calculateComplexityMetrics(result, metrics)
@@ -63,14 +115,17 @@ func (c *Calculator) Calculate(result *analyzer.AnalysisResult) *FileMetrics {
 	// Estimate technical debt
 	metrics.TechnicalDebt = c.estimateTechnicalDebt(result, metrics)
 
-	// Count code smells
-	metrics.CodeSmells = c.countCodeSmells(result, metrics)
+	// Detect code smells
+	metrics.Issues = c.rules.Run(result, metrics)
+	metrics.CodeSmells = len(metrics.Issues)
 
 	// Calculate duplication ratio (simplified)
 	metrics.DuplicationRatio = c.calculateDuplicationRatio(result)
 
-	// Calculate test coverage (would need actual coverage data)
+	// Estimate test coverage; a coverage report supplied with the analysis
+	// replaces the estimate with measured coverage
 	metrics.TestCoverage = c.estimateTestCoverage(result)
+	metrics.CoverageEstimated = true
 
 	return metrics
 }
@@ -80,21 +135,22 @@ func (c *Calculator) countLines(result *analyzer.AnalysisResult, metrics *FileMe
 	// This is a simplified implementation
 	// In a real implementation, we would parse the actual content
 	
-	// Estimate based on function and class definitions
+	// Estimate based on function and class definitions; spans with no
+	// end line count as none
 	for _, fn := range result.Functions {
-		lines := fn.EndLine - fn.StartLine + 1
+		lines := max(fn.EndLine-fn.StartLine+1, 0)
 		metrics.LOC += lines
 		metrics.CodeLines += int(float64(lines) * 0.7) // Assume 70% are code lines
 	}
 
 	for _, class := range result.Classes {
-		lines := class.EndLine - class.StartLine + 1
+		lines := max(class.EndLine-class.StartLine+1, 0)
 		metrics.LOC += lines
 		metrics.CodeLines += int(float64(lines) * 0.7)
 		
 		// Add method lines
 		for _, method := range class.Methods {
-			methodLines := method.EndLine - method.StartLine + 1
+			methodLines := max(method.EndLine-method.StartLine+1, 0)
 			metrics.FunctionCount++
 			metrics.CodeLines += int(float64(methodLines) * 0.7)
 		}
@@ -102,13 +158,27 @@ func (c *Calculator) countLines(result *analyzer.AnalysisResult, metrics *FileMe
 
 	// Count comment lines
 	for _, comment := range result.Comments {
-		metrics.CommentLines += comment.EndLine - comment.StartLine + 1
+		metrics.CommentLines += max(comment.EndLine-comment.StartLine+1, 0)
 	}
 
 	// Estimate blank lines
 	metrics.BlankLines = int(float64(metrics.LOC) * 0.15) // Assume 15% blank lines
 }
 
+// classifyLines counts the file's lines by kind. Methods still count
+// towards FunctionCount as in countLines.
+func (c *Calculator) classifyLines(result *analyzer.AnalysisResult, content []byte, metrics *FileMetrics) {
+	counts := CountLines(result.Language, content)
+	metrics.LOC = counts.Total
+	metrics.CodeLines = counts.Code
+	metrics.CommentLines = counts.Comment
+	metrics.BlankLines = counts.Blank
+
+	for _, class := range result.Classes {
+		metrics.FunctionCount += len(class.Methods)
+	}
+}
+
 // calculateComplexityMetrics calculates complexity-related metrics
 func (c *Calculator) calculateComplexityMetrics(result *analyzer.AnalysisResult, metrics *FileMetrics) {
 	totalComplexity := 0
@@ -128,9 +198,12 @@ func (c *Calculator) calculateComplexityMetrics(result *analyzer.AnalysisResult,
 		functionCount++
 	}
 
-	// Process methods in classes
+	// Process methods in classes; interface signatures have no body to measure
 	for _, class := range result.Classes {
 		for _, method := range class.Methods {
+			if method.IsAbstract {
+				continue
+			}
 			complexity := method.Complexity
 			if complexity == 0 {
 				complexity = 1
@@ -181,10 +254,11 @@ func (c *Calculator) calculateMaintainabilityIndex(metrics *FileMetrics) float64
 func (c *Calculator) estimateTechnicalDebt(result *analyzer.AnalysisResult, metrics *FileMetrics) float64 {
 	debt := 0.0
 
-	// High complexity functions
+	// High complexity functions, weighed by language
+	weight := c.config.debtWeight(result.Language)
 	for _, fn := range result.Functions {
 		if fn.Complexity > c.complexityThreshold {
-			debt += float64(fn.Complexity-c.complexityThreshold) * 0.5 // 0.5 hours per complexity point
+			debt += float64(fn.Complexity-c.complexityThreshold) * DebtHoursPerComplexity * weight
 		}
 	}
 
@@ -208,58 +282,13 @@ func (c *Calculator) estimateTechnicalDebt(result *analyzer.AnalysisResult, metr
 	return math.Round(debt*100) / 100
 }
 
-// countCodeSmells counts various code smells
-func (c *Calculator) countCodeSmells(result *analyzer.AnalysisResult, metrics *FileMetrics) int {
-	smells := 0
-
-	// Long functions
-	for _, fn := range result.Functions {
-		if fn.EndLine-fn.StartLine > 50 {
-			smells++
-		}
-		// Too many parameters
-		if len(fn.Parameters) > 5 {
-			smells++
-		}
-		// High complexity
-		if fn.Complexity > c.complexityThreshold {
-			smells++
-		}
-	}
-
-	// Large classes
-	for _, class := range result.Classes {
-		if len(class.Methods) > 20 {
-			smells++
-		}
-		if len(class.Properties) > 15 {
-			smells++
-		}
-	}
-
-	// Too many imports (potential feature envy)
-	if metrics.ImportCount > 20 {
-		smells++
-	}
-
-	// Low comment ratio
-	if metrics.LOC > 0 {
-		commentRatio := float64(metrics.CommentLines) / float64(metrics.LOC)
-		if commentRatio < 0.05 {
-			smells++
-		}
-	}
-
-	return smells
-}
-
 // calculateDuplicationRatio calculates code duplication ratio
 func (c *Calculator) calculateDuplicationRatio(result *analyzer.AnalysisResult) float64 {
 	// This is a simplified implementation
 	// Real implementation would use suffix trees or other algorithms
 	
-	// For now, return a low duplication ratio
-	// In production, this would analyze actual code patterns
+	// For now, return a low duplication ratio; the analysis service
+	// replaces it with the project-level CloneDetector result
 	return 0.05
 }
 
@@ -276,8 +305,11 @@ func (c *Calculator) estimateTestCoverage(result *analyzer.AnalysisResult) float
 
 	// Count methods
 	for _, class := range result.Classes {
-		totalFunctions += len(class.Methods)
 		for _, method := range class.Methods {
+			if method.IsAbstract {
+				continue
+			}
+			totalFunctions++
 			if method.IsTest {
 				testFunctions++
 			}
@@ -293,43 +325,42 @@ func (c *Calculator) estimateTestCoverage(result *analyzer.AnalysisResult) float
 	return math.Min(100, math.Round(coverage*100)/100)
 }
 
-// AggregateMetrics aggregates metrics from multiple files
-func AggregateMetrics(fileMetrics []*FileMetrics) map[string]interface{} {
-	totalLOC := 0
-	totalComplexity := 0
-	totalFunctions := 0
-	totalClasses := 0
-	totalDebt := 0.0
-	totalSmells := 0
-	avgMaintainability := 0.0
-	avgCoverage := 0.0
+// Aggregate is metrics summed or averaged over files
+type Aggregate struct {
+	TotalLOC               int     `json:"total_loc"`
+	TotalComplexity        int     `json:"total_complexity"`
+	TotalFunctions         int     `json:"total_functions"`
+	TotalClasses           int     `json:"total_classes"`
+	TotalTechnicalDebt     float64 `json:"total_technical_debt"`
+	TotalCodeSmells        int     `json:"total_code_smells"`
+	AverageMaintainability float64 `json:"average_maintainability"`
+	AverageTestCoverage    float64 `json:"average_test_coverage"`
+	FileCount              int     `json:"file_count"`
+}
 
+// AggregateMetrics aggregates metrics from multiple files. Empty files are
+// counted but left out of the averages, having nothing to measure.
+func AggregateMetrics(fileMetrics []*FileMetrics) *Aggregate {
+	aggregate := &Aggregate{FileCount: len(fileMetrics)}
+	measured := 0
 	for _, m := range fileMetrics {
-		totalLOC += m.LOC
-		totalComplexity += m.CyclomaticComplexity
-		totalFunctions += m.FunctionCount
-		totalClasses += m.ClassCount
-		totalDebt += m.TechnicalDebt
-		totalSmells += m.CodeSmells
-		avgMaintainability += m.MaintainabilityIndex
-		avgCoverage += m.TestCoverage
-	}
-
-	fileCount := len(fileMetrics)
-	if fileCount > 0 {
-		avgMaintainability /= float64(fileCount)
-		avgCoverage /= float64(fileCount)
+		aggregate.TotalLOC += m.LOC
+		aggregate.TotalComplexity += m.CyclomaticComplexity
+		aggregate.TotalFunctions += m.FunctionCount
+		aggregate.TotalClasses += m.ClassCount
+		aggregate.TotalTechnicalDebt += m.TechnicalDebt
+		aggregate.TotalCodeSmells += m.CodeSmells
+		if m.Empty {
+			continue
+		}
+		aggregate.AverageMaintainability += m.MaintainabilityIndex
+		aggregate.AverageTestCoverage += m.TestCoverage
+		measured++
 	}
 
-	return map[string]interface{}{
-		"total_loc":               totalLOC,
-		"total_complexity":        totalComplexity,
-		"total_functions":         totalFunctions,
-		"total_classes":           totalClasses,
-		"total_technical_debt":    totalDebt,
-		"total_code_smells":       totalSmells,
-		"average_maintainability": avgMaintainability,
-		"average_test_coverage":   avgCoverage,
-		"file_count":              fileCount,
+	if measured > 0 {
+		aggregate.AverageMaintainability /= float64(measured)
+		aggregate.AverageTestCoverage /= float64(measured)
 	}
+	return aggregate
 }
\ No newline at end of file
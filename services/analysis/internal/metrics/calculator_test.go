@@ -0,0 +1,88 @@
+package metrics_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+// assertFinite fails for any NaN or infinite ratio of m
+func assertFinite(t *testing.T, m *metrics.FileMetrics) {
+	t.Helper()
+	for name, value := range map[string]float64{
+		"average_complexity": m.AverageComplexity,
+		"maintainability":    m.MaintainabilityIndex,
+		"technical_debt":     m.TechnicalDebt,
+		"duplication_ratio":  m.DuplicationRatio,
+		"test_coverage":      m.TestCoverage,
+	} {
+		assert.False(t, math.IsNaN(value) || math.IsInf(value, 0), "%s is %g", name, value)
+	}
+}
+
+func TestCalculator_EmptyFiles(t *testing.T) {
+	calculator := metrics.NewCalculator()
+
+	tests := []struct {
+		name     string
+		content  string
+		comments int
+	}{
+		{name: "empty", content: ""},
+		{name: "whitespace only", content: "\n  \n\t\n"},
+		{name: "comments only", content: "// Package doc\n\n/* block\n   comment */\n", comments: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &analyzer.AnalysisResult{Language: analyzer.LanguageGo}
+			m := calculator.CalculateWithSource(result, []byte(tt.content))
+
+			assert.True(t, m.Empty)
+			assert.Zero(t, m.CodeLines)
+			assert.Equal(t, tt.comments, m.CommentLines)
+			assert.Equal(t, 100.0, m.MaintainabilityIndex)
+			assert.Zero(t, m.TechnicalDebt)
+			assert.Zero(t, m.CodeSmells)
+			assert.Empty(t, m.Issues)
+			assertFinite(t, m)
+		})
+	}
+
+	code := calculator.CalculateWithSource(&analyzer.AnalysisResult{
+		Language:  analyzer.LanguageGo,
+		Functions: []analyzer.Function{{Name: "main", Complexity: 1, StartLine: 3, EndLine: 3}},
+	}, []byte("package main\n\nfunc main() {}\n"))
+	assert.False(t, code.Empty)
+	assertFinite(t, code)
+}
+
+func TestCalculator_InvertedSpans(t *testing.T) {
+	// Without the source, lines are estimated from spans, which an analyzer
+	// may leave without an end line
+	m := metrics.NewCalculator().Calculate(&analyzer.AnalysisResult{
+		Language:  analyzer.LanguageGo,
+		Functions: []analyzer.Function{{Name: "main", Complexity: 1, StartLine: 5}},
+		Comments:  []analyzer.Comment{{StartLine: 2}},
+	})
+	assert.GreaterOrEqual(t, m.LOC, 0)
+	assert.GreaterOrEqual(t, m.CommentLines, 0)
+	assertFinite(t, m)
+}
+
+func TestAggregateMetrics_SkipsEmptyFiles(t *testing.T) {
+	aggregate := metrics.AggregateMetrics([]*metrics.FileMetrics{
+		{LOC: 10, CodeLines: 8, MaintainabilityIndex: 60, TestCoverage: 40},
+		{Empty: true, MaintainabilityIndex: 100},
+	})
+	assert.Equal(t, 2, aggregate.FileCount)
+	assert.Equal(t, 60.0, aggregate.AverageMaintainability)
+	assert.Equal(t, 40.0, aggregate.AverageTestCoverage)
+
+	onlyEmpty := metrics.AggregateMetrics([]*metrics.FileMetrics{{Empty: true}})
+	assert.Zero(t, onlyEmpty.AverageMaintainability)
+	assert.False(t, math.IsNaN(onlyEmpty.AverageTestCoverage))
+}
@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// IssueTypeDuplication is the issue type reported for cloned code
+const IssueTypeDuplication = "duplication"
+
+// RuleCrossFileClone is the rule name on clone issues
+const RuleCrossFileClone = "cross-file-clone"
+
+// Location is a line range in a file
+type Location struct {
+	File      string `json:"file"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+// SourceFile is a file fed to the clone detector
+type SourceFile struct {
+	Path    string
+	Content []byte
+}
+
+// CloneGroup is a block of code repeated at several locations
+type CloneGroup struct {
+	Lines     int        `json:"lines"`
+	Locations []Location `json:"locations"`
+}
+
+// CloneReport is the result of clone detection over a project
+type CloneReport struct {
+	Groups []CloneGroup
+	// DuplicatedLines and TotalLines count non-blank lines
+	DuplicatedLines int
+	TotalLines      int
+	// FileDuplication maps a file path to the share of its lines that are cloned
+	FileDuplication map[string]float64
+}
+
+// DuplicationRatio is the share of project lines that belong to a clone
+func (r *CloneReport) DuplicationRatio() float64 {
+	if r.TotalLines == 0 {
+		return 0
+	}
+	return float64(r.DuplicatedLines) / float64(r.TotalLines)
+}
+
+// Issues returns one duplication issue per clone group and file involved,
+// each listing every location of the group
+func (r *CloneReport) Issues() map[string][]Issue {
+	issues := make(map[string][]Issue)
+	for _, group := range r.Groups {
+		for _, loc := range group.Locations {
+			issues[loc.File] = append(issues[loc.File], Issue{
+				Type:      IssueTypeDuplication,
+				Severity:  SeverityMajor,
+				File:      loc.File,
+				Line:      loc.StartLine,
+				Message:   fmt.Sprintf("%d duplicated lines, also found in %d other locations", group.Lines, len(group.Locations)-1),
+				Rule:      RuleCrossFileClone,
+				Locations: group.Locations,
+			})
+		}
+	}
+	return issues
+}
+
+// CloneDetector finds blocks of code repeated across files by hashing every
+// window of consecutive normalized lines
+type CloneDetector struct {
+	window int
+}
+
+// NewCloneDetector creates a detector reporting clones of at least window
+// non-blank lines; a non-positive window uses the default of 6
+func NewCloneDetector(window int) *CloneDetector {
+	if window <= 0 {
+		window = 6
+	}
+	return &CloneDetector{window: window}
+}
+
+// sourceLine is a normalized non-blank line and its original line number
+type sourceLine struct {
+	text string
+	line int
+}
+
+// windowRef identifies the window starting at line index pos of file
+type windowRef struct {
+	file int
+	pos  int
+}
+
+// Detect reports clone groups that span more than one file
+func (d *CloneDetector) Detect(files []SourceFile) *CloneReport {
+	report := &CloneReport{FileDuplication: make(map[string]float64)}
+
+	lines := make([][]sourceLine, len(files))
+	hashes := make([][]uint64, len(files))
+	index := make(map[uint64][]windowRef)
+
+	for f, file := range files {
+		lines[f] = normalizeLines(file.Content)
+		report.TotalLines += len(lines[f])
+
+		for pos := 0; pos+d.window <= len(lines[f]); pos++ {
+			h := fnv.New64a()
+			for _, l := range lines[f][pos : pos+d.window] {
+				h.Write([]byte(l.text))
+				h.Write([]byte{'\n'})
+			}
+			sum := h.Sum64()
+			hashes[f] = append(hashes[f], sum)
+			index[sum] = append(index[sum], windowRef{file: f, pos: pos})
+		}
+	}
+
+	// Only windows present in more than one file start a group
+	crossFile := func(refs []windowRef) bool {
+		for _, ref := range refs[1:] {
+			if ref.file != refs[0].file {
+				return true
+			}
+		}
+		return false
+	}
+
+	visited := make([][]bool, len(files))
+	duplicated := make([][]bool, len(files))
+	for f := range files {
+		visited[f] = make([]bool, len(hashes[f]))
+		duplicated[f] = make([]bool, len(lines[f]))
+	}
+
+	for f := range files {
+		for pos := range hashes[f] {
+			if visited[f][pos] {
+				continue
+			}
+			refs := index[hashes[f][pos]]
+			if !crossFile(refs) {
+				continue
+			}
+
+			// Grow the group while every location continues in lockstep
+			length := 1
+			for pos+length < len(hashes[f]) && sameShifted(index[hashes[f][pos+length]], refs, length) {
+				length++
+			}
+
+			group := CloneGroup{Lines: length + d.window - 1}
+			for _, ref := range refs {
+				for i := 0; i < length; i++ {
+					visited[ref.file][ref.pos+i] = true
+				}
+				for i := 0; i < group.Lines; i++ {
+					duplicated[ref.file][ref.pos+i] = true
+				}
+				group.Locations = append(group.Locations, Location{
+					File:      files[ref.file].Path,
+					StartLine: lines[ref.file][ref.pos].line,
+					EndLine:   lines[ref.file][ref.pos+group.Lines-1].line,
+				})
+			}
+			report.Groups = append(report.Groups, group)
+		}
+	}
+
+	for f, file := range files {
+		count := 0
+		for _, dup := range duplicated[f] {
+			if dup {
+				count++
+			}
+		}
+		report.DuplicatedLines += count
+		if len(lines[f]) > 0 {
+			report.FileDuplication[file.Path] = float64(count) / float64(len(lines[f]))
+		}
+	}
+
+	return report
+}
+
+// sameShifted reports whether next holds exactly the windows of refs moved
+// forward by offset lines
+func sameShifted(next, refs []windowRef, offset int) bool {
+	if len(next) != len(refs) {
+		return false
+	}
+	want := make(map[windowRef]bool, len(refs))
+	for _, ref := range refs {
+		want[windowRef{file: ref.file, pos: ref.pos + offset}] = true
+	}
+	for _, ref := range next {
+		if !want[ref] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLines drops blank lines and collapses whitespace so formatting
+// differences don't hide a clone
+func normalizeLines(content []byte) []sourceLine {
+	var lines []sourceLine
+	for i, raw := range strings.Split(string(content), "\n") {
+		text := strings.Join(strings.Fields(raw), " ")
+		if text == "" {
+			continue
+		}
+		lines = append(lines, sourceLine{text: text, line: i + 1})
+	}
+	return lines
+}
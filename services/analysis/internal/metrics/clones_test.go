@@ -0,0 +1,78 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+const clonedBlock = `	total := 0
+	for _, item := range items {
+		if item.Price > 0 {
+			total += item.Price * item.Quantity
+		}
+	}
+	return total
+`
+
+func TestCloneDetector_CrossFileClone(t *testing.T) {
+	orders := "package orders\n\nfunc Total(items []Item) int {\n" + clonedBlock + "}\n"
+	// Same block, re-indented and shifted down, in another file
+	invoices := "package invoices\n\nimport \"fmt\"\n\n// Sum adds up items\nfunc Sum(items []Item) int {\n" +
+		"  total := 0\n  for _, item := range items {\n    if item.Price > 0 {\n      total += item.Price * item.Quantity\n    }\n  }\n  return total\n" +
+		"}\n\nfunc Print() { fmt.Println(\"x\") }\n"
+	unrelated := "package other\n\nfunc A() int {\n\treturn 1\n}\n"
+
+	report := metrics.NewCloneDetector(6).Detect([]metrics.SourceFile{
+		{Path: "orders/total.go", Content: []byte(orders)},
+		{Path: "invoices/sum.go", Content: []byte(invoices)},
+		{Path: "other/a.go", Content: []byte(unrelated)},
+	})
+
+	require.Len(t, report.Groups, 1)
+	group := report.Groups[0]
+	assert.Equal(t, 8, group.Lines, "block plus the shared closing brace")
+	assert.ElementsMatch(t, []metrics.Location{
+		{File: "orders/total.go", StartLine: 4, EndLine: 11},
+		{File: "invoices/sum.go", StartLine: 7, EndLine: 14},
+	}, group.Locations)
+
+	assert.Equal(t, 16, report.DuplicatedLines)
+	assert.InDelta(t, 16.0/float64(report.TotalLines), report.DuplicationRatio(), 1e-9)
+	assert.Zero(t, report.FileDuplication["other/a.go"])
+	assert.Greater(t, report.FileDuplication["orders/total.go"], report.FileDuplication["invoices/sum.go"])
+
+	issues := report.Issues()
+	require.Len(t, issues["orders/total.go"], 1)
+	issue := issues["orders/total.go"][0]
+	assert.Equal(t, metrics.IssueTypeDuplication, issue.Type)
+	assert.Equal(t, metrics.RuleCrossFileClone, issue.Rule)
+	assert.Equal(t, 4, issue.Line)
+	assert.Len(t, issue.Locations, 2)
+	require.Len(t, issues["invoices/sum.go"], 1)
+	assert.Equal(t, 7, issues["invoices/sum.go"][0].Line)
+	assert.Empty(t, issues["other/a.go"])
+}
+
+func TestCloneDetector_IgnoresSingleFileRepeats(t *testing.T) {
+	content := "package a\n\nfunc A() int {\n" + clonedBlock + "}\n\nfunc B() int {\n" + clonedBlock + "}\n"
+
+	report := metrics.NewCloneDetector(6).Detect([]metrics.SourceFile{
+		{Path: "a.go", Content: []byte(content)},
+	})
+
+	assert.Empty(t, report.Groups)
+	assert.Zero(t, report.DuplicationRatio())
+}
+
+func TestCloneDetector_ShortBlocks(t *testing.T) {
+	report := metrics.NewCloneDetector(6).Detect([]metrics.SourceFile{
+		{Path: "a.go", Content: []byte("x := 1\ny := 2\n")},
+		{Path: "b.go", Content: []byte("x := 1\ny := 2\n")},
+	})
+
+	assert.Empty(t, report.Groups, "copies shorter than the window are not clones")
+}
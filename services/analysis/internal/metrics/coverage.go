@@ -0,0 +1,280 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Coverage report formats
+const (
+	// CoverageFormatGo is the profile written by go test -coverprofile
+	CoverageFormatGo = "go"
+	// CoverageFormatLCOV is the lcov tracefile format
+	CoverageFormatLCOV = "lcov"
+	// CoverageFormatCobertura is the Cobertura XML format
+	CoverageFormatCobertura = "cobertura"
+)
+
+var (
+	// ErrUnknownCoverageFormat is returned for a coverage format that can't be parsed
+	ErrUnknownCoverageFormat = errors.New("unknown coverage format")
+	// ErrInvalidCoverageReport is returned for a report that doesn't parse in its format
+	ErrInvalidCoverageReport = errors.New("malformed coverage report")
+)
+
+// FileCoverage is the line coverage of one file
+type FileCoverage struct {
+	// Lines maps every coverable line to whether the tests executed it
+	Lines map[int]bool
+}
+
+// CoveredLines returns how many lines the tests executed
+func (f *FileCoverage) CoveredLines() int {
+	covered := 0
+	for _, hit := range f.Lines {
+		if hit {
+			covered++
+		}
+	}
+	return covered
+}
+
+// CoverableLines returns how many lines could be executed
+func (f *FileCoverage) CoverableLines() int {
+	return len(f.Lines)
+}
+
+// Percent returns the share of coverable lines executed, 0-100. A file
+// without coverable lines is fully covered.
+func (f *FileCoverage) Percent() float64 {
+	return CoveragePercent(f.CoveredLines(), f.CoverableLines())
+}
+
+// CoveragePercent returns covered out of coverable lines as a percentage
+// rounded to two decimals, 100 when nothing is coverable
+func CoveragePercent(covered, coverable int) float64 {
+	if coverable == 0 {
+		return 100
+	}
+	return math.Round(float64(covered)/float64(coverable)*10000) / 100
+}
+
+// hit records a line's execution count; a line hit by any block is covered
+func (f *FileCoverage) hit(line, count int) {
+	f.Lines[line] = f.Lines[line] || count > 0
+}
+
+// CoverageReport is the line coverage of a project's files, read from a
+// coverage report produced by its tests
+type CoverageReport struct {
+	files map[string]*FileCoverage
+	// byBase indexes the report's paths by file name, to match project
+	// paths against report paths carrying a module or checkout prefix
+	byBase map[string][]string
+}
+
+// ParseCoverage parses a coverage report in one of the CoverageFormat* formats
+func ParseCoverage(format string, data []byte) (*CoverageReport, error) {
+	report := &CoverageReport{files: make(map[string]*FileCoverage), byBase: make(map[string][]string)}
+	var err error
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case CoverageFormatGo:
+		err = report.parseGoProfile(data)
+	case CoverageFormatLCOV:
+		err = report.parseLCOV(data)
+	case CoverageFormatCobertura:
+		err = report.parseCobertura(data)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCoverageFormat, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidCoverageReport, format, err)
+	}
+
+	for name := range report.files {
+		base := path.Base(name)
+		report.byBase[base] = append(report.byBase[base], name)
+	}
+	for _, names := range report.byBase {
+		sort.Strings(names)
+	}
+	return report, nil
+}
+
+// File returns the coverage of a project file, or nil when the report has
+// none. Report paths may carry a prefix such as a Go module path or an
+// absolute checkout directory; the shortest one ending in the file's path
+// is used.
+func (r *CoverageReport) File(filePath string) *FileCoverage {
+	filePath = normalizeCoveragePath(filePath)
+	if coverage, ok := r.files[filePath]; ok {
+		return coverage
+	}
+	var match string
+	for _, name := range r.byBase[path.Base(filePath)] {
+		if strings.HasSuffix(name, "/"+filePath) && (match == "" || len(name) < len(match)) {
+			match = name
+		}
+	}
+	if match == "" {
+		return nil
+	}
+	return r.files[match]
+}
+
+// Files returns how many files the report covers
+func (r *CoverageReport) Files() int {
+	return len(r.files)
+}
+
+func (r *CoverageReport) file(name string) *FileCoverage {
+	name = normalizeCoveragePath(name)
+	coverage, ok := r.files[name]
+	if !ok {
+		coverage = &FileCoverage{Lines: make(map[int]bool)}
+		r.files[name] = coverage
+	}
+	return coverage
+}
+
+func normalizeCoveragePath(name string) string {
+	name = strings.ReplaceAll(strings.TrimSpace(name), "\\", "/")
+	return strings.TrimPrefix(path.Clean(name), "./")
+}
+
+// parseGoProfile reads lines such as "pkg/util.go:3.14,5.2 1 1" after the
+// mode line: a block's position, its statement count and execution count.
+// Every line a block spans is coverable.
+func (r *CoverageReport) parseGoProfile(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if lineNumber == 1 {
+			if !strings.HasPrefix(line, "mode:") {
+				return errors.New("missing mode line")
+			}
+			continue
+		}
+
+		// The last colon ends the file name, which may hold a drive letter
+		separator := strings.LastIndex(line, ":")
+		if separator <= 0 {
+			return fmt.Errorf("line %d: malformed block %q", lineNumber, line)
+		}
+		name := line[:separator]
+		fields := strings.Fields(line[separator+1:])
+		if len(fields) != 3 {
+			return fmt.Errorf("line %d: malformed block %q", lineNumber, line)
+		}
+		start, end, ok := strings.Cut(fields[0], ",")
+		startLine, err1 := strconv.Atoi(strings.SplitN(start, ".", 2)[0])
+		endLine, err2 := strconv.Atoi(strings.SplitN(end, ".", 2)[0])
+		count, err3 := strconv.Atoi(fields[2])
+		if !ok || err1 != nil || err2 != nil || err3 != nil || startLine <= 0 || endLine < startLine {
+			return fmt.Errorf("line %d: malformed block %q", lineNumber, line)
+		}
+
+		coverage := r.file(name)
+		for l := startLine; l <= endLine; l++ {
+			coverage.hit(l, count)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if lineNumber == 0 {
+		return errors.New("empty profile")
+	}
+	return nil
+}
+
+// parseLCOV reads SF: records and their DA:line,count entries
+func (r *CoverageReport) parseLCOV(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var current *FileCoverage
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			current = r.file(strings.TrimPrefix(line, "SF:"))
+		case strings.HasPrefix(line, "DA:"):
+			if current == nil {
+				return fmt.Errorf("line %d: DA outside of a source file record", lineNumber)
+			}
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if len(fields) < 2 {
+				return fmt.Errorf("line %d: malformed DA %q", lineNumber, line)
+			}
+			number, err1 := strconv.Atoi(fields[0])
+			count, err2 := strconv.ParseFloat(fields[1], 64)
+			if err1 != nil || err2 != nil || number <= 0 {
+				return fmt.Errorf("line %d: malformed DA %q", lineNumber, line)
+			}
+			current.hit(number, int(math.Ceil(count)))
+		case line == "end_of_record":
+			current = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(r.files) == 0 {
+		return errors.New("no source file records")
+	}
+	return nil
+}
+
+// coberturaReport is the part of a Cobertura report that carries line hits
+type coberturaReport struct {
+	XMLName xml.Name `xml:"coverage"`
+	Classes []struct {
+		Filename string `xml:"filename,attr"`
+		Lines    []struct {
+			Number int    `xml:"number,attr"`
+			Hits   string `xml:"hits,attr"`
+		} `xml:"lines>line"`
+	} `xml:"packages>package>classes>class"`
+}
+
+// parseCobertura reads the lines of every class; classes of one file, such
+// as a file's top-level code and its classes, are merged
+func (r *CoverageReport) parseCobertura(data []byte) error {
+	var report coberturaReport
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return err
+	}
+	for _, class := range report.Classes {
+		if class.Filename == "" {
+			return errors.New("class without filename")
+		}
+		coverage := r.file(class.Filename)
+		for _, line := range class.Lines {
+			hits, err := strconv.ParseFloat(line.Hits, 64)
+			if err != nil || line.Number <= 0 {
+				return fmt.Errorf("%s: malformed line %d with hits %q", class.Filename, line.Number, line.Hits)
+			}
+			coverage.hit(line.Number, int(math.Ceil(hits)))
+		}
+	}
+	if len(r.files) == 0 {
+		return errors.New("no classes")
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+const goCoverProfile = `mode: count
+github.com/acme/app/internal/calc/calc.go:3.24,5.2 1 4
+github.com/acme/app/internal/calc/calc.go:7.24,8.12 1 0
+github.com/acme/app/internal/calc/calc.go:8.12,10.3 1 0
+github.com/acme/app/internal/calc/calc.go:11.2,11.10 1 2
+github.com/acme/app/cmd/main.go:5.13,7.2 2 0
+`
+
+func TestParseCoverage_GoProfile(t *testing.T) {
+	report, err := metrics.ParseCoverage(metrics.CoverageFormatGo, []byte(goCoverProfile))
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Files())
+
+	calc := report.File("internal/calc/calc.go")
+	require.NotNil(t, calc)
+	// Lines 3-5 and 11 ran; 7-10 didn't, line 8 being shared by two blocks
+	assert.Equal(t, 8, calc.CoverableLines())
+	assert.Equal(t, 4, calc.CoveredLines())
+	assert.Equal(t, 50.0, calc.Percent())
+
+	main := report.File("cmd/main.go")
+	require.NotNil(t, main)
+	assert.Equal(t, 0.0, main.Percent())
+
+	assert.Nil(t, report.File("internal/other/other.go"))
+	assert.Nil(t, report.File("calc/calc.go.bak"))
+}
+
+func TestParseCoverage_LCOV(t *testing.T) {
+	tracefile := `TN:
+SF:/home/ci/app/src/util.js
+DA:1,1
+DA:2,0
+DA:3,5
+end_of_record
+SF:/home/ci/app/src/index.js
+DA:1,0
+end_of_record
+`
+	report, err := metrics.ParseCoverage(metrics.CoverageFormatLCOV, []byte(tracefile))
+	require.NoError(t, err)
+
+	util := report.File("src/util.js")
+	require.NotNil(t, util)
+	assert.Equal(t, 66.67, util.Percent())
+	assert.Equal(t, 0.0, report.File("./src/index.js").Percent())
+}
+
+func TestParseCoverage_Cobertura(t *testing.T) {
+	xml := `<?xml version="1.0" ?>
+<coverage line-rate="0.75">
+  <packages>
+    <package name="app">
+      <classes>
+        <class name="app.models" filename="app/models.py">
+          <lines>
+            <line number="1" hits="1"/>
+            <line number="2" hits="0"/>
+          </lines>
+        </class>
+        <class name="app.models.User" filename="app/models.py">
+          <lines>
+            <line number="4" hits="3"/>
+            <line number="5" hits="1"/>
+          </lines>
+        </class>
+      </classes>
+    </package>
+  </packages>
+</coverage>`
+	report, err := metrics.ParseCoverage("Cobertura", []byte(xml))
+	require.NoError(t, err)
+
+	models := report.File("app/models.py")
+	require.NotNil(t, models)
+	assert.Equal(t, 4, models.CoverableLines())
+	assert.Equal(t, 75.0, models.Percent())
+}
+
+func TestParseCoverage_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		err    error
+	}{
+		{name: "unknown format", format: "jacoco", data: "<report/>", err: metrics.ErrUnknownCoverageFormat},
+		{name: "go without mode", format: metrics.CoverageFormatGo, data: "calc.go:1.1,2.2 1 1\n", err: metrics.ErrInvalidCoverageReport},
+		{name: "go malformed block", format: metrics.CoverageFormatGo, data: "mode: set\ncalc.go:1.1 1 1\n", err: metrics.ErrInvalidCoverageReport},
+		{name: "go empty", format: metrics.CoverageFormatGo, data: "", err: metrics.ErrInvalidCoverageReport},
+		{name: "lcov without files", format: metrics.CoverageFormatLCOV, data: "TN:\n", err: metrics.ErrInvalidCoverageReport},
+		{name: "lcov line outside file", format: metrics.CoverageFormatLCOV, data: "DA:1,1\n", err: metrics.ErrInvalidCoverageReport},
+		{name: "cobertura not xml", format: metrics.CoverageFormatCobertura, data: "mode: set", err: metrics.ErrInvalidCoverageReport},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := metrics.ParseCoverage(tt.format, []byte(tt.data))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestCoveragePercent(t *testing.T) {
+	assert.Equal(t, 100.0, metrics.CoveragePercent(0, 0))
+	assert.Equal(t, 33.33, metrics.CoveragePercent(1, 3))
+}
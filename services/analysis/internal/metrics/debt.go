@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+)
+
+// DebtHoursPerComplexity is the technical debt, in hours, of each point of
+// a function's complexity over the threshold, before the language weight
+const DebtHoursPerComplexity = 0.5
+
+// ErrInvalidDebtWeight is returned for a language debt weight that is
+// negative or not a number
+var ErrInvalidDebtWeight = errors.New("invalid debt weight")
+
+// CalculatorConfig configures the formulas of a Calculator
+type CalculatorConfig struct {
+	// DebtWeights scales the debt of excess complexity by language, as in
+	// {"python": 1.2}, for languages whose complexity costs more or less to
+	// pay down. Languages not listed weigh 1.
+	DebtWeights map[string]float64
+}
+
+// DefaultCalculatorConfig returns the configuration NewCalculator uses,
+// weighing every language alike
+func DefaultCalculatorConfig() CalculatorConfig {
+	return CalculatorConfig{}
+}
+
+// Validate checks that every debt weight is a known language's and is a
+// non-negative number
+func (c CalculatorConfig) Validate() error {
+	var errs []error
+	for _, language := range c.languages() {
+		weight := c.DebtWeights[language]
+		if _, ok := analyzer.ParseLanguage(language); !ok {
+			errs = append(errs, fmt.Errorf("%w: unknown language %q", ErrInvalidDebtWeight, language))
+		}
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			errs = append(errs, fmt.Errorf("%w: %s: %g", ErrInvalidDebtWeight, language, weight))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Fingerprint identifies the configuration, so results calculated under
+// another one aren't reused. It is "" for the default configuration.
+func (c CalculatorConfig) Fingerprint() string {
+	languages := c.languages()
+	if len(languages) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, language := range languages {
+		fmt.Fprintf(h, "%s:%g;", strings.ToLower(language), c.DebtWeights[language])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// debtWeight returns the debt weight of a language, 1 when none is set
+func (c CalculatorConfig) debtWeight(language analyzer.Language) float64 {
+	for name, weight := range c.DebtWeights {
+		if strings.EqualFold(name, string(language)) {
+			return weight
+		}
+	}
+	return 1
+}
+
+// languages returns the languages with a debt weight in sorted order
+func (c CalculatorConfig) languages() []string {
+	languages := make([]string, 0, len(c.DebtWeights))
+	for language := range c.DebtWeights {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
@@ -0,0 +1,66 @@
+package metrics_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+// complexResult is a file of one private function with the given
+// complexity, so its only debt is the excess complexity
+func complexResult(language analyzer.Language, complexity int) *analyzer.AnalysisResult {
+	return &analyzer.AnalysisResult{
+		Language:  language,
+		Functions: []analyzer.Function{{Name: "process", Complexity: complexity, StartLine: 1, EndLine: 10}},
+	}
+}
+
+func TestCalculator_DebtWeights(t *testing.T) {
+	// The complexity smell would add debt of its own
+	rules := metrics.NewRuleEngine()
+	rule, ok := rules.Config(metrics.RuleHighComplexity)
+	require.True(t, ok)
+	rule.Enabled = false
+	require.NoError(t, rules.Configure(metrics.RuleHighComplexity, rule))
+	calculator := metrics.NewCalculatorWithConfig(rules, metrics.CalculatorConfig{
+		DebtWeights: map[string]float64{"go": 0.8, "Python": 1.5},
+	})
+
+	// 20 points over a threshold of 10 are worth 5 hours unweighted
+	goDebt := calculator.Calculate(complexResult(analyzer.LanguageGo, 20)).TechnicalDebt
+	pythonDebt := calculator.Calculate(complexResult(analyzer.LanguagePython, 20)).TechnicalDebt
+	javaDebt := calculator.Calculate(complexResult(analyzer.LanguageJava, 20)).TechnicalDebt
+	assert.Equal(t, 4.0, goDebt)
+	assert.Equal(t, 7.5, pythonDebt, "weights match languages ignoring case")
+	assert.Equal(t, 5.0, javaDebt, "unlisted languages weigh 1")
+
+	defaultDebt := metrics.NewCalculatorWithRules(rules).Calculate(complexResult(analyzer.LanguagePython, 20)).TechnicalDebt
+	assert.Equal(t, 5.0, defaultDebt)
+}
+
+func TestCalculatorConfig_Validate(t *testing.T) {
+	assert.NoError(t, metrics.DefaultCalculatorConfig().Validate())
+	assert.NoError(t, metrics.CalculatorConfig{DebtWeights: map[string]float64{"go": 0, "typescript": 2}}.Validate())
+
+	for _, weights := range []map[string]float64{
+		{"go": -0.5},
+		{"go": math.NaN()},
+		{"cobol": 1},
+	} {
+		assert.ErrorIs(t, metrics.CalculatorConfig{DebtWeights: weights}.Validate(), metrics.ErrInvalidDebtWeight, weights)
+	}
+}
+
+func TestCalculatorConfig_Fingerprint(t *testing.T) {
+	assert.Empty(t, metrics.DefaultCalculatorConfig().Fingerprint())
+
+	weighted := metrics.CalculatorConfig{DebtWeights: map[string]float64{"go": 0.8}}
+	assert.NotEmpty(t, weighted.Fingerprint())
+	assert.Equal(t, weighted.Fingerprint(), metrics.CalculatorConfig{DebtWeights: map[string]float64{"go": 0.8}}.Fingerprint())
+	assert.NotEqual(t, weighted.Fingerprint(), metrics.CalculatorConfig{DebtWeights: map[string]float64{"go": 0.9}}.Fingerprint())
+}
@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+// Package ecosystems, named as in OSV advisories
+const (
+	EcosystemGo   = "Go"
+	EcosystemNPM  = "npm"
+	EcosystemPyPI = "PyPI"
+)
+
+// manifestEcosystems maps the manifests dependencies are read from to the
+// ecosystem of their packages
+var manifestEcosystems = map[string]string{
+	"go.mod":           EcosystemGo,
+	"package.json":     EcosystemNPM,
+	"requirements.txt": EcosystemPyPI,
+}
+
+// DependencyType is the type of dependencies declared in a manifest
+const DependencyType = "external"
+
+// manifestDependency is a dependency with the manifest line declaring it
+type manifestDependency struct {
+	models.Dependency
+	line int
+}
+
+// ManifestEcosystem returns the ecosystem of the packages a manifest
+// declares, or "" for a file that isn't a known manifest
+func ManifestEcosystem(filePath string) string {
+	return manifestEcosystems[path.Base(filePath)]
+}
+
+// ExtractDependencies returns the dependencies a manifest declares with a
+// pinned version: go.mod requirements, package.json dependencies and
+// requirements.txt packages pinned with ==. Source is the manifest's path.
+func ExtractDependencies(filePath string, content []byte) []models.Dependency {
+	entries := extractManifest(filePath, content)
+	if len(entries) == 0 {
+		return nil
+	}
+	deps := make([]models.Dependency, len(entries))
+	for i, entry := range entries {
+		deps[i] = entry.Dependency
+	}
+	return deps
+}
+
+func extractManifest(filePath string, content []byte) []manifestDependency {
+	var entries []manifestDependency
+	switch ManifestEcosystem(filePath) {
+	case EcosystemGo:
+		entries = parseGoMod(content)
+	case EcosystemNPM:
+		entries = parsePackageJSON(content)
+	case EcosystemPyPI:
+		entries = parseRequirements(content)
+	}
+	for i := range entries {
+		entries[i].Type = DependencyType
+		entries[i].Source = filePath
+	}
+	return entries
+}
+
+// parseGoMod reads require directives, single or in a block
+func parseGoMod(content []byte) []manifestDependency {
+	var entries []manifestDependency
+	inBlock := false
+	scanLines(content, func(line string, lineNo int) {
+		line, _, _ = strings.Cut(line, "//")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			return
+		case inBlock && fields[0] == ")":
+			inBlock = false
+			return
+		case !inBlock && fields[0] == "require":
+			if len(fields) > 1 && fields[1] == "(" {
+				inBlock = true
+				return
+			}
+			fields = fields[1:]
+		case !inBlock:
+			return
+		}
+		if len(fields) >= 2 {
+			entries = append(entries, manifestDependency{
+				Dependency: models.Dependency{Name: fields[0], Version: fields[1]},
+				line:       lineNo,
+			})
+		}
+	})
+	return entries
+}
+
+// npmVersion matches the version a package.json range starts from, such
+// as 4.17.1 in ^4.17.1 or ~4.17.1
+var npmVersion = regexp.MustCompile(`^[\^~=v]*(\d+(?:\.\d+){0,2}(?:-[0-9A-Za-z.-]+)?)$`)
+
+// parsePackageJSON reads dependencies and devDependencies. A caret or
+// tilde range is taken at its lower bound; ranges that can't be reduced to
+// one version, tags and URLs are left out.
+func parsePackageJSON(content []byte) []manifestDependency {
+	var manifest struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil
+	}
+
+	var entries []manifestDependency
+	for _, deps := range []map[string]string{manifest.Dependencies, manifest.DevDependencies} {
+		for name, spec := range deps {
+			match := npmVersion.FindStringSubmatch(strings.TrimSpace(spec))
+			if match == nil {
+				continue
+			}
+			entries = append(entries, manifestDependency{
+				Dependency: models.Dependency{Name: name, Version: match[1]},
+				line:       jsonKeyLine(content, name),
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].line < entries[j].line })
+	return entries
+}
+
+// parseRequirements reads packages pinned with ==, ignoring extras,
+// environment markers and comments
+func parseRequirements(content []byte) []manifestDependency {
+	var entries []manifestDependency
+	scanLines(content, func(line string, lineNo int) {
+		line, _, _ = strings.Cut(line, "#")
+		line, _, _ = strings.Cut(line, ";")
+		name, version, ok := strings.Cut(line, "==")
+		if !ok {
+			return
+		}
+		name, _, _ = strings.Cut(name, "[")
+		name, version = strings.TrimSpace(name), strings.TrimSpace(version)
+		if name == "" || version == "" || strings.ContainsAny(version, " ,<>=!~*") {
+			return
+		}
+		entries = append(entries, manifestDependency{
+			Dependency: models.Dependency{Name: name, Version: version},
+			line:       lineNo,
+		})
+	})
+	return entries
+}
+
+func scanLines(content []byte, fn func(line string, lineNo int)) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fn(scanner.Text(), lineNo)
+	}
+}
+
+// jsonKeyLine returns the line of the first occurrence of a quoted key, 0
+// if it isn't found
+func jsonKeyLine(content []byte, key string) int {
+	quoted, _ := json.Marshal(key)
+	offset := bytes.Index(content, quoted)
+	if offset < 0 {
+		return 0
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+)
+
+// LineCounts classifies a file's physical lines. A line with any code is a
+// code line; a line with only comments is a comment line.
+type LineCounts struct {
+	Total   int
+	Code    int
+	Comment int
+	Blank   int
+}
+
+// lineRules describe how a language marks comments and strings
+type lineRules struct {
+	lineComments  []string    // markers commenting out the rest of the line
+	blockComments [][2]string // opening and closing block comment markers
+	quotes        string      // characters delimiting single-line strings
+	multiline     string      // characters delimiting strings that may span lines
+	// docstrings counts triple-quoted strings standing alone as a
+	// statement as comments, as Python does for documentation
+	docstrings bool
+}
+
+var cStyleComments = [][2]string{{"/*", "*/"}}
+
+// languageLineRules holds the rules per language. Block comments cover
+// documentation comments such as JSDoc, Javadoc and Go doc comments.
+var languageLineRules = map[analyzer.Language]lineRules{
+	analyzer.LanguageGo:         {lineComments: []string{"//"}, blockComments: cStyleComments, quotes: `"'`, multiline: "`"},
+	analyzer.LanguageJava:       {lineComments: []string{"//"}, blockComments: cStyleComments, quotes: `"'`},
+	analyzer.LanguageCSharp:     {lineComments: []string{"//"}, blockComments: cStyleComments, quotes: `"'`},
+	analyzer.LanguageJavaScript: {lineComments: []string{"//"}, blockComments: cStyleComments, quotes: `"'`, multiline: "`"},
+	analyzer.LanguageTypeScript: {lineComments: []string{"//"}, blockComments: cStyleComments, quotes: `"'`, multiline: "`"},
+	analyzer.LanguagePython:     {lineComments: []string{"#"}, quotes: `"'`, docstrings: true},
+}
+
+// CountLines classifies each line of content using the rules of its
+// language. Comment markers inside strings don't count, and a language
+// without rules has only code and blank lines.
+func CountLines(language analyzer.Language, content []byte) LineCounts {
+	rules := languageLineRules[language]
+	counter := lineCounter{rules: rules}
+
+	var counts LineCounts
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		counts.Total++
+		code, comment := counter.classify(scanner.Text())
+		switch {
+		case code:
+			counts.Code++
+		case comment:
+			counts.Comment++
+		default:
+			counts.Blank++
+		}
+	}
+	return counts
+}
+
+// lineCounter carries comment and string state from one line to the next
+type lineCounter struct {
+	rules lineRules
+	// closing is the marker ending the comment or string the previous line
+	// left open, and commentOpen whether it's a comment (or docstring)
+	closing     string
+	commentOpen bool
+}
+
+// classify reports whether a line holds code and whether it holds comments
+func (lc *lineCounter) classify(line string) (code, comment bool) {
+	for i := 0; i < len(line); {
+		if lc.closing != "" {
+			end := strings.Index(line[i:], lc.closing)
+			if lc.commentOpen {
+				comment = true
+			} else {
+				code = true
+			}
+			if end < 0 {
+				return code, comment
+			}
+			i += end + len(lc.closing)
+			lc.closing = ""
+			continue
+		}
+
+		rest := line[i:]
+		if opener, closer, ok := lc.blockComment(rest); ok {
+			comment = true
+			lc.closing, lc.commentOpen = closer, true
+			i += len(opener)
+			continue
+		}
+
+		switch c := line[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			i++
+		case hasAnyPrefix(rest, lc.rules.lineComments):
+			return code, true
+		case lc.rules.docstrings && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, `'''`)):
+			// A triple-quoted string opening the line is a docstring;
+			// after code it's an ordinary string value
+			lc.closing, lc.commentOpen = rest[:3], !code
+			comment = comment || lc.commentOpen
+			i += 3
+		case strings.IndexByte(lc.rules.multiline, c) >= 0:
+			code = true
+			lc.closing, lc.commentOpen = string(c), false
+			i++
+		case strings.IndexByte(lc.rules.quotes, c) >= 0:
+			code = true
+			i += 1 + stringLength(rest[1:], c)
+		default:
+			code = true
+			i++
+		}
+	}
+	return code, comment
+}
+
+// blockComment returns the block comment markers if s opens a block comment
+func (lc *lineCounter) blockComment(s string) (opener, closer string, ok bool) {
+	for _, block := range lc.rules.blockComments {
+		if strings.HasPrefix(s, block[0]) {
+			return block[0], block[1], true
+		}
+	}
+	return "", "", false
+}
+
+// stringLength returns how far a string literal runs past its opening quote,
+// including the closing quote; an unterminated literal runs to the line end
+func stringLength(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
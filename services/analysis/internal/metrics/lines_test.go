@@ -0,0 +1,137 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		language analyzer.Language
+		source   string
+		want     metrics.LineCounts
+	}{
+		{
+			name:     "python docstrings",
+			language: analyzer.LanguagePython,
+			source: `"""Module docstring
+spanning two lines."""
+
+import os  # trailing comment
+
+
+def run():
+    '''Run it.'''
+    # a comment
+    query = """
+    SELECT 1
+    """
+    return query
+`,
+			want: metrics.LineCounts{Total: 13, Code: 6, Comment: 4, Blank: 3},
+		},
+		{
+			name:     "python hash in string",
+			language: analyzer.LanguagePython,
+			source:   "tag = '#not-a-comment'\n",
+			want:     metrics.LineCounts{Total: 1, Code: 1},
+		},
+		{
+			name:     "jsdoc",
+			language: analyzer.LanguageJavaScript,
+			source: `/**
+ * Adds two numbers.
+ * @param {number} a
+ */
+function add(a, b) { // inline
+  const url = "http://example.com"; /* not
+  code */ return a + b;
+}
+const tpl = ` + "`" + `
+// inside a template literal
+` + "`" + `;
+`,
+			want: metrics.LineCounts{Total: 11, Code: 7, Comment: 4},
+		},
+		{
+			name:     "go block and line comments",
+			language: analyzer.LanguageGo,
+			source: `// Package main does things.
+package main
+
+/*
+Block comment.
+*/
+func main() {
+	s := "/* not a comment */"
+	_ = s
+}
+`,
+			want: metrics.LineCounts{Total: 10, Code: 5, Comment: 4, Blank: 1},
+		},
+		{
+			name:     "java javadoc",
+			language: analyzer.LanguageJava,
+			source: `/** Greets. */
+public class Hello {
+    /*
+     * Entry point.
+     */
+    public static void main(String[] args) {}
+}
+`,
+			want: metrics.LineCounts{Total: 7, Code: 3, Comment: 4},
+		},
+		{
+			name:     "csharp",
+			language: analyzer.LanguageCSharp,
+			source:   "/// <summary>Doc</summary>\nclass A {}\n\n",
+			want:     metrics.LineCounts{Total: 3, Code: 1, Comment: 1, Blank: 1},
+		},
+		{
+			name:     "unknown language",
+			language: analyzer.LanguageUnknown,
+			source:   "# heading\n\ntext\n",
+			want:     metrics.LineCounts{Total: 3, Code: 2, Blank: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, metrics.CountLines(tt.language, []byte(tt.source)))
+		})
+	}
+}
+
+func TestCalculator_CalculateWithSource(t *testing.T) {
+	source := []byte(`"""Utilities.
+
+Longer description of the module.
+"""
+
+
+def add(a, b):
+    """Add two numbers."""
+    return a + b
+`)
+	result := &analyzer.AnalysisResult{
+		Language:  analyzer.LanguagePython,
+		Functions: []analyzer.Function{{Name: "add", StartLine: 7, EndLine: 9, Complexity: 1}},
+	}
+
+	fileMetrics := metrics.NewCalculator().CalculateWithSource(result, source)
+	assert.Equal(t, 9, fileMetrics.LOC)
+	assert.Equal(t, 2, fileMetrics.CodeLines)
+	assert.Equal(t, 4, fileMetrics.CommentLines)
+	// Blank lines inside a docstring are still blank
+	assert.Equal(t, 3, fileMetrics.BlankLines)
+
+	// Docstrings earn the documented-code bonus the estimate misses
+	estimated := metrics.NewCalculator().Calculate(result)
+	assert.Greater(t, fileMetrics.MaintainabilityIndex, estimated.MaintainabilityIndex)
+}
@@ -0,0 +1,321 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+)
+
+// Issue severities
+const (
+	SeverityCritical = "critical"
+	SeverityMajor    = "major"
+	SeverityMinor    = "minor"
+	SeverityInfo     = "info"
+)
+
+// ErrUnknownSeverity is returned for a severity other than the four above
+var ErrUnknownSeverity = errors.New("unknown severity")
+
+// severityRanks orders the severities from least to most severe
+var severityRanks = map[string]int{
+	SeverityInfo:     1,
+	SeverityMinor:    2,
+	SeverityMajor:    3,
+	SeverityCritical: 4,
+}
+
+// ParseSeverity returns the severity with the given name, in any case
+func ParseSeverity(name string) (string, error) {
+	severity := strings.ToLower(strings.TrimSpace(name))
+	if _, ok := severityRanks[severity]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownSeverity, name)
+	}
+	return severity, nil
+}
+
+// SeverityRank orders a severity among the others, higher being more
+// severe. Unknown severities, as found in old results, rank below info.
+func SeverityRank(severity string) int {
+	return severityRanks[strings.ToLower(severity)]
+}
+
+// SeverityAtLeast reports whether a severity is at least as severe as
+// minimum; an empty minimum admits every severity
+func SeverityAtLeast(severity, minimum string) bool {
+	return minimum == "" || SeverityRank(severity) >= SeverityRank(minimum)
+}
+
+// IssueTypeCodeSmell is the issue type reported by the built-in rules
+const IssueTypeCodeSmell = "code_smell"
+
+// Built-in rule names
+const (
+	RuleLongFunction      = "long-function"
+	RuleTooManyParameters = "too-many-parameters"
+	RuleHighComplexity    = "high-complexity"
+	RuleTooManyMethods    = "too-many-methods"
+	RuleTooManyProperties = "too-many-properties"
+	RuleTooManyImports    = "too-many-imports"
+	RuleLowCommentRatio   = "low-comment-ratio"
+)
+
+// ErrUnknownRule is returned when configuring a rule the engine doesn't have
+var ErrUnknownRule = errors.New("unknown rule")
+
+// Issue is a single rule violation; it mirrors models.Issue
+type Issue struct {
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Message  string `json:"message"`
+	Rule     string `json:"rule"`
+	// Locations lists every place involved, for issues spanning several
+	Locations []Location `json:"locations,omitempty"`
+}
+
+// RuleConfig controls whether a rule runs, how severe its issues are and
+// the limit above which it fires
+type RuleConfig struct {
+	Enabled   bool    `json:"enabled" mapstructure:"enabled"`
+	Severity  string  `json:"severity" mapstructure:"severity"`
+	Threshold float64 `json:"threshold" mapstructure:"threshold"`
+}
+
+// Finding is a rule hit before the engine stamps it with rule metadata
+type Finding struct {
+	Line    int
+	Message string
+}
+
+// Rule inspects the analysis of one file and reports findings
+type Rule interface {
+	Name() string
+	DefaultConfig() RuleConfig
+	Check(result *analyzer.AnalysisResult, metrics *FileMetrics, threshold float64) []Finding
+}
+
+// ruleFunc adapts a check function to the Rule interface
+type ruleFunc struct {
+	name     string
+	defaults RuleConfig
+	check    func(result *analyzer.AnalysisResult, metrics *FileMetrics, threshold float64) []Finding
+}
+
+func (r ruleFunc) Name() string              { return r.name }
+func (r ruleFunc) DefaultConfig() RuleConfig { return r.defaults }
+func (r ruleFunc) Check(result *analyzer.AnalysisResult, metrics *FileMetrics, threshold float64) []Finding {
+	return r.check(result, metrics, threshold)
+}
+
+// NewRule creates a rule from a check function
+func NewRule(name string, defaults RuleConfig, check func(*analyzer.AnalysisResult, *FileMetrics, float64) []Finding) Rule {
+	return ruleFunc{name: name, defaults: defaults, check: check}
+}
+
+// RuleEngine runs a configurable set of rules over analysis results
+type RuleEngine struct {
+	rules  []Rule
+	config map[string]RuleConfig
+}
+
+// NewRuleEngine creates an engine running the given rules with their default
+// configuration; with no rules it runs DefaultRules
+func NewRuleEngine(rules ...Rule) *RuleEngine {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	engine := &RuleEngine{
+		rules:  rules,
+		config: make(map[string]RuleConfig, len(rules)),
+	}
+	for _, rule := range rules {
+		engine.config[rule.Name()] = rule.DefaultConfig()
+	}
+	return engine
+}
+
+// Configure replaces the configuration of a rule. Zero severity and
+// threshold keep the rule's defaults; other severities must be known.
+func (e *RuleEngine) Configure(name string, cfg RuleConfig) error {
+	current, ok := e.config[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRule, name)
+	}
+	if cfg.Severity == "" {
+		cfg.Severity = current.Severity
+	}
+	severity, err := ParseSeverity(cfg.Severity)
+	if err != nil {
+		return fmt.Errorf("rule %s: %w", name, err)
+	}
+	cfg.Severity = severity
+	if cfg.Threshold == 0 {
+		cfg.Threshold = current.Threshold
+	}
+	e.config[name] = cfg
+	return nil
+}
+
+// Disable turns a rule off
+func (e *RuleEngine) Disable(name string) error {
+	cfg, ok := e.config[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRule, name)
+	}
+	cfg.Enabled = false
+	e.config[name] = cfg
+	return nil
+}
+
+// Config returns the effective configuration of a rule
+func (e *RuleEngine) Config(name string) (RuleConfig, bool) {
+	cfg, ok := e.config[name]
+	return cfg, ok
+}
+
+// Run applies every enabled rule to result and returns the issues found.
+// File is left empty; callers know which file was analyzed.
+func (e *RuleEngine) Run(result *analyzer.AnalysisResult, metrics *FileMetrics) []Issue {
+	var issues []Issue
+	for _, rule := range e.rules {
+		cfg := e.config[rule.Name()]
+		if !cfg.Enabled {
+			continue
+		}
+		for _, finding := range rule.Check(result, metrics, cfg.Threshold) {
+			issues = append(issues, Issue{
+				Type:     IssueTypeCodeSmell,
+				Severity: cfg.Severity,
+				Line:     finding.Line,
+				Message:  finding.Message,
+				Rule:     rule.Name(),
+			})
+		}
+	}
+	return issues
+}
+
+// Fingerprint identifies the rule set and configuration, so results
+// produced under a different configuration can be told apart
+func (e *RuleEngine) Fingerprint() string {
+	names := make([]string, 0, len(e.config))
+	for name := range e.config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		cfg := e.config[name]
+		fmt.Fprintf(h, "%s:%t:%s:%g;", name, cfg.Enabled, cfg.Severity, cfg.Threshold)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// DefaultRules returns the built-in code smell rules
+func DefaultRules() []Rule {
+	return []Rule{
+		NewRule(RuleLongFunction, RuleConfig{Enabled: true, Severity: SeverityMajor, Threshold: 50},
+			func(result *analyzer.AnalysisResult, _ *FileMetrics, threshold float64) []Finding {
+				var findings []Finding
+				for _, fn := range result.Functions {
+					if length := fn.EndLine - fn.StartLine; float64(length) > threshold {
+						findings = append(findings, Finding{
+							Line:    fn.StartLine,
+							Message: fmt.Sprintf("Function %s is %d lines long (limit %g)", fn.Name, length, threshold),
+						})
+					}
+				}
+				return findings
+			}),
+		NewRule(RuleTooManyParameters, RuleConfig{Enabled: true, Severity: SeverityMinor, Threshold: 5},
+			func(result *analyzer.AnalysisResult, _ *FileMetrics, threshold float64) []Finding {
+				var findings []Finding
+				for _, fn := range result.Functions {
+					if float64(len(fn.Parameters)) > threshold {
+						findings = append(findings, Finding{
+							Line:    fn.StartLine,
+							Message: fmt.Sprintf("Function %s takes %d parameters (limit %g)", fn.Name, len(fn.Parameters), threshold),
+						})
+					}
+				}
+				return findings
+			}),
+		NewRule(RuleHighComplexity, RuleConfig{Enabled: true, Severity: SeverityMajor, Threshold: 10},
+			func(result *analyzer.AnalysisResult, _ *FileMetrics, threshold float64) []Finding {
+				var findings []Finding
+				for _, fn := range result.Functions {
+					if float64(fn.Complexity) > threshold {
+						findings = append(findings, Finding{
+							Line:    fn.StartLine,
+							Message: fmt.Sprintf("Function %s has cyclomatic complexity %d (limit %g)", fn.Name, fn.Complexity, threshold),
+						})
+					}
+				}
+				return findings
+			}),
+		NewRule(RuleTooManyMethods, RuleConfig{Enabled: true, Severity: SeverityMajor, Threshold: 20},
+			func(result *analyzer.AnalysisResult, _ *FileMetrics, threshold float64) []Finding {
+				var findings []Finding
+				for _, class := range result.Classes {
+					if float64(len(class.Methods)) > threshold {
+						findings = append(findings, Finding{
+							Line:    class.StartLine,
+							Message: fmt.Sprintf("%s has %d methods (limit %g)", class.Name, len(class.Methods), threshold),
+						})
+					}
+				}
+				return findings
+			}),
+		NewRule(RuleTooManyProperties, RuleConfig{Enabled: true, Severity: SeverityMinor, Threshold: 15},
+			func(result *analyzer.AnalysisResult, _ *FileMetrics, threshold float64) []Finding {
+				var findings []Finding
+				for _, class := range result.Classes {
+					if float64(len(class.Properties)) > threshold {
+						findings = append(findings, Finding{
+							Line:    class.StartLine,
+							Message: fmt.Sprintf("%s has %d properties (limit %g)", class.Name, len(class.Properties), threshold),
+						})
+					}
+				}
+				return findings
+			}),
+		// Many imports hint at feature envy
+		NewRule(RuleTooManyImports, RuleConfig{Enabled: true, Severity: SeverityMinor, Threshold: 20},
+			func(result *analyzer.AnalysisResult, metrics *FileMetrics, threshold float64) []Finding {
+				if float64(metrics.ImportCount) <= threshold {
+					return nil
+				}
+				line := 1
+				if len(result.Imports) > 0 {
+					line = result.Imports[0].Line
+				}
+				return []Finding{{
+					Line:    line,
+					Message: fmt.Sprintf("File has %d imports (limit %g)", metrics.ImportCount, threshold),
+				}}
+			}),
+		NewRule(RuleLowCommentRatio, RuleConfig{Enabled: true, Severity: SeverityInfo, Threshold: 0.05},
+			func(_ *analyzer.AnalysisResult, metrics *FileMetrics, threshold float64) []Finding {
+				if metrics.LOC == 0 {
+					return nil
+				}
+				ratio := float64(metrics.CommentLines) / float64(metrics.LOC)
+				if ratio >= threshold {
+					return nil
+				}
+				return []Finding{{
+					Line:    1,
+					Message: fmt.Sprintf("Comment ratio %.2f is below %g", ratio, threshold),
+				}}
+			}),
+	}
+}
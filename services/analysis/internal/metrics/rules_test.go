@@ -0,0 +1,144 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+func issuesByRule(issues []metrics.Issue) map[string][]metrics.Issue {
+	byRule := make(map[string][]metrics.Issue)
+	for _, issue := range issues {
+		byRule[issue.Rule] = append(byRule[issue.Rule], issue)
+	}
+	return byRule
+}
+
+func TestRuleEngine_DefaultRules(t *testing.T) {
+	manyParams := make([]analyzer.Parameter, 7)
+	methods := make([]analyzer.Function, 21)
+	properties := make([]analyzer.Property, 16)
+	imports := make([]analyzer.Import, 22)
+	for i := range imports {
+		imports[i].Line = 3 + i
+	}
+
+	result := &analyzer.AnalysisResult{
+		Functions: []analyzer.Function{
+			{Name: "short", StartLine: 30, EndLine: 35, Complexity: 1},
+			{Name: "long", StartLine: 40, EndLine: 120, Complexity: 2},
+			{Name: "wide", StartLine: 130, EndLine: 135, Parameters: manyParams, Complexity: 1},
+			{Name: "tangled", StartLine: 140, EndLine: 160, Complexity: 15},
+		},
+		Classes: []analyzer.Class{
+			{Name: "Small", StartLine: 200, EndLine: 210},
+			{Name: "God", StartLine: 300, EndLine: 900, Methods: methods, Properties: properties},
+		},
+		Imports: imports,
+	}
+
+	fileMetrics := metrics.NewCalculator().Calculate(result)
+	byRule := issuesByRule(fileMetrics.Issues)
+
+	tests := []struct {
+		rule     string
+		line     int
+		severity string
+	}{
+		{metrics.RuleLongFunction, 40, metrics.SeverityMajor},
+		{metrics.RuleTooManyParameters, 130, metrics.SeverityMinor},
+		{metrics.RuleHighComplexity, 140, metrics.SeverityMajor},
+		{metrics.RuleTooManyMethods, 300, metrics.SeverityMajor},
+		{metrics.RuleTooManyProperties, 300, metrics.SeverityMinor},
+		{metrics.RuleTooManyImports, 3, metrics.SeverityMinor},
+		{metrics.RuleLowCommentRatio, 1, metrics.SeverityInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			issues := byRule[tt.rule]
+			require.Len(t, issues, 1)
+			assert.Equal(t, tt.line, issues[0].Line)
+			assert.Equal(t, tt.severity, issues[0].Severity)
+			assert.Equal(t, metrics.IssueTypeCodeSmell, issues[0].Type)
+			assert.NotEmpty(t, issues[0].Message)
+		})
+	}
+
+	assert.Equal(t, len(fileMetrics.Issues), fileMetrics.CodeSmells)
+}
+
+func TestRuleEngine_Configure(t *testing.T) {
+	result := &analyzer.AnalysisResult{
+		Functions: []analyzer.Function{
+			{Name: "medium", StartLine: 10, EndLine: 40, Complexity: 6},
+		},
+		Comments: []analyzer.Comment{{StartLine: 1, EndLine: 10}},
+	}
+
+	engine := metrics.NewRuleEngine()
+	fingerprint := engine.Fingerprint()
+	assert.Empty(t, metrics.NewCalculatorWithRules(engine).Calculate(result).Issues)
+
+	require.NoError(t, engine.Configure(metrics.RuleLongFunction, metrics.RuleConfig{Enabled: true, Threshold: 20}))
+	require.NoError(t, engine.Configure(metrics.RuleHighComplexity, metrics.RuleConfig{Enabled: true, Severity: metrics.SeverityCritical, Threshold: 5}))
+	assert.NotEqual(t, fingerprint, engine.Fingerprint())
+
+	byRule := issuesByRule(metrics.NewCalculatorWithRules(engine).Calculate(result).Issues)
+	require.Len(t, byRule[metrics.RuleLongFunction], 1)
+	assert.Equal(t, metrics.SeverityMajor, byRule[metrics.RuleLongFunction][0].Severity, "empty severity keeps the default")
+	require.Len(t, byRule[metrics.RuleHighComplexity], 1)
+	assert.Equal(t, metrics.SeverityCritical, byRule[metrics.RuleHighComplexity][0].Severity)
+
+	require.NoError(t, engine.Disable(metrics.RuleLongFunction))
+	byRule = issuesByRule(metrics.NewCalculatorWithRules(engine).Calculate(result).Issues)
+	assert.Empty(t, byRule[metrics.RuleLongFunction])
+
+	assert.ErrorIs(t, engine.Disable("no-such-rule"), metrics.ErrUnknownRule)
+	assert.ErrorIs(t, engine.Configure("no-such-rule", metrics.RuleConfig{}), metrics.ErrUnknownRule)
+	assert.ErrorIs(t, engine.Configure(metrics.RuleHighComplexity, metrics.RuleConfig{Enabled: true, Severity: "blocker"}), metrics.ErrUnknownSeverity)
+
+	require.NoError(t, engine.Configure(metrics.RuleTooManyImports, metrics.RuleConfig{Enabled: true, Severity: " Major"}))
+	cfg, _ := engine.Config(metrics.RuleTooManyImports)
+	assert.Equal(t, metrics.SeverityMajor, cfg.Severity)
+}
+
+func TestSeverities(t *testing.T) {
+	severity, err := metrics.ParseSeverity("CRITICAL")
+	require.NoError(t, err)
+	assert.Equal(t, metrics.SeverityCritical, severity)
+	_, err = metrics.ParseSeverity("warning")
+	assert.ErrorIs(t, err, metrics.ErrUnknownSeverity)
+
+	ordered := []string{"", metrics.SeverityInfo, metrics.SeverityMinor, metrics.SeverityMajor, metrics.SeverityCritical}
+	for i := 1; i < len(ordered); i++ {
+		assert.Less(t, metrics.SeverityRank(ordered[i-1]), metrics.SeverityRank(ordered[i]), ordered[i])
+	}
+
+	assert.True(t, metrics.SeverityAtLeast(metrics.SeverityInfo, ""))
+	assert.True(t, metrics.SeverityAtLeast(metrics.SeverityMajor, metrics.SeverityMajor))
+	assert.True(t, metrics.SeverityAtLeast(metrics.SeverityCritical, metrics.SeverityMajor))
+	assert.False(t, metrics.SeverityAtLeast(metrics.SeverityMinor, metrics.SeverityMajor))
+	assert.False(t, metrics.SeverityAtLeast("warning", metrics.SeverityInfo), "unknown severities rank lowest")
+}
+
+func TestRuleEngine_CustomRule(t *testing.T) {
+	noTests := metrics.NewRule("no-tests", metrics.RuleConfig{Enabled: true, Severity: metrics.SeverityInfo},
+		func(result *analyzer.AnalysisResult, _ *metrics.FileMetrics, _ float64) []metrics.Finding {
+			for _, fn := range result.Functions {
+				if fn.IsTest {
+					return nil
+				}
+			}
+			return []metrics.Finding{{Line: 1, Message: "No tests"}}
+		})
+
+	engine := metrics.NewRuleEngine(noTests)
+	issues := engine.Run(&analyzer.AnalysisResult{Functions: []analyzer.Function{{Name: "f"}}}, &metrics.FileMetrics{})
+	require.Len(t, issues, 1)
+	assert.Equal(t, "no-tests", issues[0].Rule)
+}
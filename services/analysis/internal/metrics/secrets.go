@@ -0,0 +1,197 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Issue types reported by the secret scanner: a credential matching a known
+// format is a vulnerability, a string that merely looks random a hotspot to
+// review
+const (
+	IssueTypeVulnerability   = "vulnerability"
+	IssueTypeSecurityHotspot = "security_hotspot"
+)
+
+// Secret scanning rule names
+const (
+	RuleAWSAccessKey      = "aws-access-key"
+	RuleAWSSecretKey      = "aws-secret-key"
+	RulePrivateKey        = "private-key"
+	RuleGitHubToken       = "github-token"
+	RuleSlackToken        = "slack-token"
+	RuleStripeKey         = "stripe-key"
+	RuleJWT               = "jwt"
+	RuleHardcodedSecret   = "hardcoded-secret"
+	RuleHighEntropyString = "high-entropy-string"
+)
+
+// SecretSuppression marks a line whose secrets are deliberate, such as test
+// fixtures. It suppresses findings on its own line and on the line after it.
+const SecretSuppression = "sa3d:ignore-secret"
+
+// secretPattern detects one kind of credential
+type secretPattern struct {
+	rule     string
+	severity string
+	message  string
+	re       *regexp.Regexp
+}
+
+// secretPatterns are the known credential formats
+var secretPatterns = []secretPattern{
+	{RulePrivateKey, SeverityCritical, "Private key committed to source",
+		regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`)},
+	{RuleAWSAccessKey, SeverityCritical, "AWS access key ID committed to source",
+		regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{RuleAWSSecretKey, SeverityCritical, "AWS secret access key committed to source",
+		regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]+\s*["']?[A-Za-z0-9/+=]{40}\b`)},
+	{RuleGitHubToken, SeverityCritical, "GitHub token committed to source",
+		regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{59,})\b`)},
+	{RuleSlackToken, SeverityCritical, "Slack token committed to source",
+		regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{RuleStripeKey, SeverityCritical, "Stripe secret key committed to source",
+		regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{24,}\b`)},
+	{RuleJWT, SeverityMajor, "JSON Web Token committed to source",
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{RuleHardcodedSecret, SeverityMajor, "Hardcoded credential assigned to a secret-like name",
+		regexp.MustCompile(`(?i)[\w-]*(?:password|passwd|secret|api_?key|access_?token|auth_?token)[\w-]*["']?\s*[:=]+\s*["']([^"'\s]{8,})["']`)},
+}
+
+// quotedToken matches string literals that could hold an encoded secret
+var quotedToken = regexp.MustCompile(`["'` + "`" + `]([A-Za-z0-9+/=_-]{20,})["'` + "`" + `]`)
+
+// minSecretEntropy is the Shannon entropy, in bits per character, above
+// which a quoted token is reported. Random base64 scores about 5 while
+// words, identifiers and hex digests stay below 4.
+const minSecretEntropy = 4.2
+
+// placeholderMarkers identify example values that aren't real credentials
+var placeholderMarkers = []string{"${", "{{", "<", "example", "changeme", "change-me", "placeholder", "xxxxxxxx", "********"}
+
+// secretScanExempt are files of checksums and resolved versions, which are
+// full of random-looking strings that aren't secrets
+var secretScanExempt = map[string]bool{
+	"go.sum":            true,
+	"package-lock.json": true,
+	"yarn.lock":         true,
+	"pnpm-lock.yaml":    true,
+	"Cargo.lock":        true,
+	"poetry.lock":       true,
+	"composer.lock":     true,
+	"Gemfile.lock":      true,
+}
+
+// ScanSecrets reports credentials committed in a file's content: known
+// credential formats as vulnerabilities and other high-entropy string
+// literals as security hotspots. Lines carrying SecretSuppression, and the
+// lines right after them, are skipped. File is left empty, as with RuleEngine.
+func ScanSecrets(filePath string, content []byte) []Issue {
+	if secretScanExempt[path.Base(filePath)] {
+		return nil
+	}
+
+	var issues []Issue
+	suppressNext := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		suppressed := suppressNext || strings.Contains(line, SecretSuppression)
+		suppressNext = strings.Contains(line, SecretSuppression)
+		if suppressed {
+			continue
+		}
+		issues = append(issues, scanSecretLine(line, lineNo)...)
+	}
+	return issues
+}
+
+// scanSecretLine reports the secrets of one line, at most one per rule. A
+// line matching a known format isn't also searched for random strings.
+func scanSecretLine(line string, lineNo int) []Issue {
+	var issues []Issue
+	for _, pattern := range secretPatterns {
+		loc := pattern.re.FindStringSubmatchIndex(line)
+		if loc == nil {
+			continue
+		}
+		if len(loc) > 2 && loc[2] >= 0 && isPlaceholder(line[loc[2]:loc[3]]) {
+			continue
+		}
+		issues = append(issues, Issue{
+			Type:     IssueTypeVulnerability,
+			Severity: pattern.severity,
+			Line:     lineNo,
+			Column:   loc[0] + 1,
+			Message:  pattern.message,
+			Rule:     pattern.rule,
+		})
+	}
+	if len(issues) > 0 {
+		return issues
+	}
+
+	for _, loc := range quotedToken.FindAllStringSubmatchIndex(line, -1) {
+		token := line[loc[2]:loc[3]]
+		if isPlaceholder(token) || !mixesCharacterClasses(token) || shannonEntropy(token) < minSecretEntropy {
+			continue
+		}
+		return []Issue{{
+			Type:     IssueTypeSecurityHotspot,
+			Severity: SeverityMinor,
+			Line:     lineNo,
+			Column:   loc[2] + 1,
+			Message:  "High-entropy string may be a hardcoded secret",
+			Rule:     RuleHighEntropyString,
+		}}
+	}
+	return nil
+}
+
+// isPlaceholder reports whether a value is an obvious stand-in for a secret
+func isPlaceholder(value string) bool {
+	lower := strings.ToLower(value)
+	for _, marker := range placeholderMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// mixesCharacterClasses reports whether s has upper case letters, lower
+// case letters and digits, as generated secrets do and names rarely do
+func mixesCharacterClasses(s string) bool {
+	var upper, lower, digit bool
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit
+}
+
+// shannonEntropy returns the entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	length := float64(len(s))
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,143 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+// Fake credentials are assembled from parts so this file doesn't itself
+// contain anything a scanner would flag
+var (
+	fakeAWSAccessKey = "AKIA" + "Q3EGRT7KZW2XNPLM"
+	fakeAWSSecretKey = "wJalrXUtnFEMI/K7MDENG/" + "bPxRfiCYzQ8vT4hN2s"
+	fakeGitHubToken  = "ghp_" + "a1B2c3D4e5F6g7H8i9J0k1L2m3N4o5P6q7R8"
+	fakeSlackToken   = "xoxb-" + "2048-551983-Zp4qTq9MfWnC"
+	fakeStripeKey    = "sk_" + "live_" + "4eC39HqLyjWDarjtT1zdp7dc"
+	fakePrivateKey   = "-----BEGIN RSA " + "PRIVATE KEY-----"
+	fakeRandomToken  = "Zx8Kq2Lm9Vb4Nw7Rt1Ys" + "6Hd3Gf5Jc0Pa"
+)
+
+func TestScanSecrets_KnownCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		rule     string
+		severity string
+	}{
+		{"aws access key", `key := "` + fakeAWSAccessKey + `"`, metrics.RuleAWSAccessKey, metrics.SeverityCritical},
+		{"aws secret key", `aws_secret_access_key = ` + fakeAWSSecretKey, metrics.RuleAWSSecretKey, metrics.SeverityCritical},
+		{"private key", fakePrivateKey, metrics.RulePrivateKey, metrics.SeverityCritical},
+		{"github token", `token = "` + fakeGitHubToken + `"`, metrics.RuleGitHubToken, metrics.SeverityCritical},
+		{"slack token", `SLACK = '` + fakeSlackToken + `'`, metrics.RuleSlackToken, metrics.SeverityCritical},
+		{"stripe key", `stripe.Key = "` + fakeStripeKey + `"`, metrics.RuleStripeKey, metrics.SeverityCritical},
+		{"hardcoded password", `db_password: "hunter2hunter2"`, metrics.RuleHardcodedSecret, metrics.SeverityMajor},
+		{"hardcoded api key", `const apiKey = "q8w7e6r5t4y3"`, metrics.RuleHardcodedSecret, metrics.SeverityMajor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "package config\n\n" + tt.line + "\n"
+
+			issues := metrics.ScanSecrets("config/config.go", []byte(content))
+
+			require.NotEmpty(t, issues)
+			var found *metrics.Issue
+			for i := range issues {
+				if issues[i].Rule == tt.rule {
+					found = &issues[i]
+				}
+			}
+			require.NotNil(t, found, "expected a %s issue in %+v", tt.rule, issues)
+			assert.Equal(t, metrics.IssueTypeVulnerability, found.Type)
+			assert.Equal(t, tt.severity, found.Severity)
+			assert.Equal(t, 3, found.Line)
+			assert.Positive(t, found.Column)
+			assert.Empty(t, found.File, "the caller sets the file")
+		})
+	}
+}
+
+func TestScanSecrets_HighEntropyString(t *testing.T) {
+	content := "package client\n\nvar signingSalt = \"" + fakeRandomToken + "\"\n"
+
+	issues := metrics.ScanSecrets("client/client.go", []byte(content))
+
+	require.Len(t, issues, 1)
+	assert.Equal(t, metrics.IssueTypeSecurityHotspot, issues[0].Type)
+	assert.Equal(t, metrics.RuleHighEntropyString, issues[0].Rule)
+	assert.Equal(t, metrics.SeverityMinor, issues[0].Severity)
+	assert.Equal(t, 3, issues[0].Line)
+	assert.Equal(t, len(`var signingSalt = "`)+1, issues[0].Column)
+	assert.NotContains(t, issues[0].Message, fakeRandomToken, "messages never quote the secret")
+}
+
+func TestScanSecrets_Suppression(t *testing.T) {
+	content := strings.Join([]string{
+		`key := "` + fakeAWSAccessKey + `" // ` + metrics.SecretSuppression,
+		`// ` + metrics.SecretSuppression + `: test fixture`,
+		`token := "` + fakeGitHubToken + `"`,
+		`other := "` + fakeGitHubToken + `"`,
+	}, "\n")
+
+	issues := metrics.ScanSecrets("fixtures_test.go", []byte(content))
+
+	require.Len(t, issues, 1, "only the line after the suppressed one is reported")
+	assert.Equal(t, metrics.RuleGitHubToken, issues[0].Rule)
+	assert.Equal(t, 4, issues[0].Line)
+}
+
+func TestScanSecrets_CleanFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		content string
+	}{
+		{
+			name: "ordinary go",
+			path: "service/user.go",
+			content: `package service
+
+import "github.com/sa3d-modernized/sa3d/shared/models"
+
+// User holds login details
+type User struct {
+	Password string ` + "`json:\"password\"`" + `
+}
+
+const checksum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+const message = "ThisIsAVeryLongIdentifierWithoutDigits"
+
+func load() string {
+	password := os.Getenv("DB_PASSWORD")
+	return password
+}
+`,
+		},
+		{
+			name:    "placeholders",
+			path:    "config.yaml",
+			content: "password: \"changeme\"\napi_key: \"${API_KEY}\"\nsecret: \"<your-secret-here>\"\ntoken: \"example-token-value\"\n",
+		},
+		{
+			name:    "lock file",
+			path:    "web/package-lock.json",
+			content: `"integrity": "sha512-` + fakeRandomToken + fakeRandomToken + `"` + "\n",
+		},
+		{
+			name:    "go.sum",
+			path:    "go.sum",
+			content: "github.com/stretchr/testify v1.8.4 h1:" + fakeRandomToken + "=\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Empty(t, metrics.ScanSecrets(tt.path, []byte(tt.content)))
+		})
+	}
+}
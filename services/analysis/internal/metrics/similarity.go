@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math"
+)
+
+// signatureSize is the number of hash functions in a content signature
+const signatureSize = 64
+
+// ContentHash returns the hex SHA-256 of a file's content
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ContentSignature returns a MinHash signature of the set of normalized
+// non-blank lines in content. Comparing two signatures with
+// SignatureSimilarity estimates how many lines the files share, so an edited
+// file can be matched to its earlier version. Content without lines has no
+// signature.
+func ContentSignature(content []byte) []uint32 {
+	lines := normalizeLines(content)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	signature := make([]uint32, signatureSize)
+	for i := range signature {
+		signature[i] = math.MaxUint32
+	}
+	for _, l := range lines {
+		h := fnv.New64a()
+		h.Write([]byte(l.text))
+		lineHash := h.Sum64()
+		for i := range signature {
+			if v := uint32(mix64(lineHash + uint64(i+1)*0x9e3779b97f4a7c15)); v < signature[i] {
+				signature[i] = v
+			}
+		}
+	}
+	return signature
+}
+
+// SignatureSimilarity estimates the Jaccard similarity of the line sets
+// behind two signatures, from 0 (nothing shared) to 1 (same lines)
+func SignatureSimilarity(a, b []uint32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(a))
+}
+
+// mix64 is the splitmix64 finalizer, deriving independent hash functions
+// from one line hash
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
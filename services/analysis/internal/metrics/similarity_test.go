@@ -0,0 +1,48 @@
+package metrics_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+func numberedLines(from, to int) string {
+	var b strings.Builder
+	for i := from; i < to; i++ {
+		fmt.Fprintf(&b, "\tvalue%d := compute(%d)\n", i, i)
+	}
+	return b.String()
+}
+
+func TestSignatureSimilarity(t *testing.T) {
+	original := []byte(numberedLines(0, 100))
+
+	t.Run("identical content", func(t *testing.T) {
+		assert.Equal(t, 1.0, metrics.SignatureSimilarity(metrics.ContentSignature(original), metrics.ContentSignature(original)))
+	})
+
+	t.Run("whitespace changes are ignored", func(t *testing.T) {
+		reindented := strings.ReplaceAll(string(original), "\t", "    ")
+		assert.Equal(t, 1.0, metrics.SignatureSimilarity(metrics.ContentSignature(original), metrics.ContentSignature([]byte(reindented))))
+	})
+
+	t.Run("small edits stay similar", func(t *testing.T) {
+		edited := []byte(numberedLines(0, 95) + numberedLines(200, 205))
+		similarity := metrics.SignatureSimilarity(metrics.ContentSignature(original), metrics.ContentSignature(edited))
+		assert.InDelta(t, 95.0/105.0, similarity, 0.15)
+	})
+
+	t.Run("unrelated content", func(t *testing.T) {
+		other := []byte(numberedLines(500, 600))
+		assert.Less(t, metrics.SignatureSimilarity(metrics.ContentSignature(original), metrics.ContentSignature(other)), 0.1)
+	})
+
+	t.Run("empty content has no signature", func(t *testing.T) {
+		assert.Nil(t, metrics.ContentSignature([]byte("\n  \n")))
+		assert.Zero(t, metrics.SignatureSimilarity(nil, metrics.ContentSignature(original)))
+	})
+}
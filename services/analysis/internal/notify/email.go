@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+)
+
+// EmailConfig configures the SMTP relay used for notifications
+type EmailConfig struct {
+	Addr     string // host:port
+	From     string
+	Username string
+	Password string
+}
+
+// EmailNotifier emails summaries to the project's notification addresses
+type EmailNotifier struct {
+	config   EmailConfig
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmailNotifier creates an email notifier sending through config.Addr
+func NewEmailNotifier(config EmailConfig) *EmailNotifier {
+	return &EmailNotifier{
+		config:   config,
+		sendMail: smtp.SendMail,
+	}
+}
+
+// Notify implements Notifier
+func (n *EmailNotifier) Notify(ctx context.Context, project *repository.Project, summary Summary) error {
+	if len(project.NotificationEmails) == 0 {
+		return nil
+	}
+
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		host := n.config.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, host)
+	}
+
+	if err := n.sendMail(n.config.Addr, auth, n.config.From, project.NotificationEmails, n.message(project, summary)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// message renders the summary as a plain-text email
+func (n *EmailNotifier) message(project *repository.Project, summary Summary) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(n.config.From))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(strings.Join(project.NotificationEmails, ", ")))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(subject(summary)))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+
+	fmt.Fprintf(&b, "%s.\r\n\r\n", subject(summary))
+	fmt.Fprintf(&b, "Files analyzed: %d\r\n", summary.TotalFiles)
+	if summary.Error != "" {
+		fmt.Fprintf(&b, "Error: %s\r\n", summary.Error)
+	}
+	fmt.Fprintf(&b, "Finished: %s\r\n\r\n", summary.FinishedAt.UTC().Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(&b, "View the results: %s\r\n", summary.Link)
+	return []byte(b.String())
+}
+
+// lineBreaks are stripped from header values, so recipients and project
+// names can't add headers of their own
+var lineBreaks = strings.NewReplacer("\r", "", "\n", "")
+
+// headerValue makes value safe to write as a header's value
+func headerValue(value string) string {
+	return lineBreaks.Replace(value)
+}
@@ -0,0 +1,105 @@
+// Package notify tells people about finished analyses.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+)
+
+// defaultNotifyTimeout bounds a single call of a notifier that doesn't set
+// its own timeout
+const defaultNotifyTimeout = 30 * time.Second
+
+// Summary describes the outcome of an analysis
+type Summary struct {
+	Event       string    `json:"event"` // analysis.completed or analysis.failed
+	AnalysisID  string    `json:"analysis_id"`
+	ProjectID   string    `json:"project_id"`
+	ProjectName string    `json:"project_name"`
+	TotalFiles  int       `json:"total_files"`
+	Error       string    `json:"error,omitempty"`
+	Link        string    `json:"link"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+// Notifier delivers a summary to the recipients configured on the project
+type Notifier interface {
+	Notify(ctx context.Context, project *repository.Project, summary Summary) error
+}
+
+// timeoutNotifier is a Notifier that knows how long its deliveries may take,
+// such as one that retries
+type timeoutNotifier interface {
+	Timeout() time.Duration
+}
+
+// Dispatcher fans a summary out to every notifier without blocking the caller
+type Dispatcher struct {
+	notifiers []Notifier
+	baseURL   string
+	timeout   time.Duration
+	logger    *logrus.Logger
+}
+
+// NewDispatcher creates a dispatcher linking summaries to analyses under baseURL
+func NewDispatcher(baseURL string, logger *logrus.Logger, notifiers ...Notifier) *Dispatcher {
+	return &Dispatcher{
+		notifiers: notifiers,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		timeout:   defaultNotifyTimeout,
+		logger:    logger,
+	}
+}
+
+// Link returns the URL of an analysis
+func (d *Dispatcher) Link(projectID, analysisID string) string {
+	return fmt.Sprintf("%s/projects/%s/analyses/%s", d.baseURL, projectID, analysisID)
+}
+
+// Dispatch sends the summary through every notifier in the background.
+// Delivery is best effort: failures are logged, never returned.
+func (d *Dispatcher) Dispatch(project *repository.Project, summary Summary) {
+	if summary.Link == "" {
+		summary.Link = d.Link(summary.ProjectID, summary.AnalysisID)
+	}
+	if summary.ProjectName == "" {
+		summary.ProjectName = project.Name
+	}
+
+	for _, n := range d.notifiers {
+		go func(n Notifier) {
+			timeout := d.timeout
+			if n, ok := n.(timeoutNotifier); ok {
+				timeout = n.Timeout()
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := n.Notify(ctx, project, summary); err != nil {
+				d.logger.WithFields(logrus.Fields{
+					"analysis_id": summary.AnalysisID,
+					"project_id":  summary.ProjectID,
+					"notifier":    fmt.Sprintf("%T", n),
+				}).Warnf("Failed to send notification: %v", err)
+			}
+		}(n)
+	}
+}
+
+// subject is the one-line description of a summary
+func subject(summary Summary) string {
+	name := summary.ProjectName
+	if name == "" {
+		name = summary.ProjectID
+	}
+	if summary.Error != "" {
+		return fmt.Sprintf("Analysis of %s failed", name)
+	}
+	return fmt.Sprintf("Analysis of %s completed", name)
+}
@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/webhook"
+)
+
+func testSummary() Summary {
+	return Summary{
+		Event:      "analysis.completed",
+		AnalysisID: "analysis-1",
+		ProjectID:  "project-1",
+		TotalFiles: 12,
+		Link:       "https://sa3d.example.com/projects/project-1/analyses/analysis-1",
+		FinishedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	var sentTo []string
+	var sent string
+	notifier := NewEmailNotifier(EmailConfig{Addr: "smtp.example.com:587", From: "sa3d@example.com"})
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sentTo = to
+		sent = string(msg)
+		return nil
+	}
+
+	project := &repository.Project{ID: "project-1", Name: "Billing", NotificationEmails: []string{"dev@example.com"}}
+	summary := testSummary()
+	summary.ProjectName = project.Name
+
+	require.NoError(t, notifier.Notify(context.Background(), project, summary))
+	assert.Equal(t, []string{"dev@example.com"}, sentTo)
+	assert.Contains(t, sent, "Subject: Analysis of Billing completed")
+	assert.Contains(t, sent, "Files analyzed: 12")
+	assert.Contains(t, sent, summary.Link)
+
+	// No recipients, nothing sent
+	sentTo = nil
+	require.NoError(t, notifier.Notify(context.Background(), &repository.Project{ID: "quiet"}, summary))
+	assert.Nil(t, sentTo)
+
+	notifier.sendMail = func(string, smtp.Auth, string, []string, []byte) error { return errors.New("relay down") }
+	assert.Error(t, notifier.Notify(context.Background(), project, summary))
+}
+
+func TestEmailNotifier_HeaderInjection(t *testing.T) {
+	var sent string
+	notifier := NewEmailNotifier(EmailConfig{Addr: "smtp.example.com:587", From: "sa3d@example.com"})
+	notifier.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sent = string(msg)
+		return nil
+	}
+
+	project := &repository.Project{
+		ID:                 "project-1",
+		Name:               "Billing\r\nBcc: victim@example.com",
+		NotificationEmails: []string{"dev@example.com\r\nBcc: other@example.com"},
+	}
+	summary := testSummary()
+	summary.ProjectName = project.Name
+
+	require.NoError(t, notifier.Notify(context.Background(), project, summary))
+	headers, _, _ := strings.Cut(sent, "\r\n\r\n")
+	assert.NotContains(t, headers, "\r\nBcc:")
+	assert.Contains(t, headers, "Subject: Analysis of BillingBcc: victim@example.com completed")
+	assert.Contains(t, headers, "To: dev@example.comBcc: other@example.com")
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	received := make(chan Summary, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var summary Summary
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&summary))
+		received <- summary
+	}))
+	defer server.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	notifier := NewWebhookNotifier(webhook.NewDeliverer(webhook.Config{MaxAttempts: 1}, logger))
+	project := &repository.Project{ID: "project-1", NotificationWebhooks: []string{failing.URL, server.URL}}
+
+	err := notifier.Notify(context.Background(), project, testSummary())
+	assert.Error(t, err, "failing webhook is reported")
+
+	select {
+	case summary := <-received:
+		assert.Equal(t, testSummary(), summary, "remaining webhooks still receive the summary")
+	case <-time.After(time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
+
+func TestWebhookNotifier_Timeout(t *testing.T) {
+	notifier := NewWebhookNotifier(webhook.NewDeliverer(webhook.DefaultConfig(), logrus.New()))
+	assert.Equal(t, webhook.DefaultConfig().MaxDuration(), notifier.Timeout())
+	assert.Greater(t, notifier.Timeout(), defaultNotifyTimeout, "retries outlast the default timeout")
+}
+
+type recordingNotifier struct {
+	summaries chan Summary
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, project *repository.Project, summary Summary) error {
+	n.summaries <- summary
+	return errors.New("delivery errors are only logged")
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	recorder := &recordingNotifier{summaries: make(chan Summary, 1)}
+	dispatcher := NewDispatcher("https://sa3d.example.com/", logger, recorder)
+
+	dispatcher.Dispatch(&repository.Project{ID: "project-1", Name: "Billing"}, Summary{
+		Event:      "analysis.failed",
+		AnalysisID: "analysis-1",
+		ProjectID:  "project-1",
+		Error:      "boom",
+	})
+
+	select {
+	case summary := <-recorder.summaries:
+		assert.Equal(t, "https://sa3d.example.com/projects/project-1/analyses/analysis-1", summary.Link)
+		assert.Equal(t, "Billing", summary.ProjectName)
+		assert.Equal(t, "Analysis of Billing failed", subject(summary))
+	case <-time.After(time.Second):
+		t.Fatal("notifier was not called")
+	}
+}
+
+// slowNotifier reports the deadline its call was given
+type slowNotifier struct {
+	timeout   time.Duration
+	deadlines chan time.Time
+}
+
+func (n *slowNotifier) Timeout() time.Duration {
+	return n.timeout
+}
+
+func (n *slowNotifier) Notify(ctx context.Context, project *repository.Project, summary Summary) error {
+	deadline, _ := ctx.Deadline()
+	n.deadlines <- deadline
+	return nil
+}
+
+func TestDispatcher_NotifierTimeout(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	notifier := &slowNotifier{timeout: time.Hour, deadlines: make(chan time.Time, 1)}
+	dispatched := time.Now()
+	NewDispatcher("https://sa3d.example.com", logger, notifier).Dispatch(&repository.Project{ID: "project-1"}, testSummary())
+
+	select {
+	case deadline := <-notifier.deadlines:
+		assert.WithinDuration(t, dispatched.Add(time.Hour), deadline, time.Minute)
+	case <-time.After(time.Second):
+		t.Fatal("notifier was not called")
+	}
+}
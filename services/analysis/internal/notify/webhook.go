@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/webhook"
+)
+
+// WebhookNotifier POSTs summaries as signed JSON to the project's webhooks
+type WebhookNotifier struct {
+	deliverer *webhook.Deliverer
+}
+
+// NewWebhookNotifier creates a webhook notifier delivering through deliverer
+func NewWebhookNotifier(deliverer *webhook.Deliverer) *WebhookNotifier {
+	return &WebhookNotifier{deliverer: deliverer}
+}
+
+// Timeout is the longest a delivery may take with all its retries. The
+// project's webhooks are delivered to at once, so it bounds them all.
+func (n *WebhookNotifier) Timeout() time.Duration {
+	return n.deliverer.MaxDuration()
+}
+
+// Notify implements Notifier. Every webhook is tried; the first error is returned.
+func (n *WebhookNotifier) Notify(ctx context.Context, project *repository.Project, summary Summary) error {
+	errs := make([]error, len(project.NotificationWebhooks))
+	var wg sync.WaitGroup
+	for i, url := range project.NotificationWebhooks {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			_, errs[i] = n.deliverer.Deliver(ctx, url, summary)
+		}(i, url)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
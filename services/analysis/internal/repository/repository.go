@@ -0,0 +1,38 @@
+// Package repository defines the storage contracts used by the analysis service.
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// Project is the subset of project data needed to run an analysis
+type Project struct {
+	ID         string
+	Name       string
+	Language   string
+	Repository string
+	Branch     string
+	// EnabledLanguages limits analysis to these languages; empty enables all
+	EnabledLanguages []string
+	// MinSeverity leaves issues below this severity out of aggregate
+	// counts; empty counts every issue
+	MinSeverity string
+	// NotificationEmails and NotificationWebhooks receive analysis summaries
+	NotificationEmails   []string
+	NotificationWebhooks []string
+}
+
+// ProjectFile is a source file belonging to a project
+type ProjectFile struct {
+	Path       string
+	Content    []byte
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// ProjectRepository provides read access to projects and their files
+type ProjectRepository interface {
+	GetByID(ctx context.Context, id string) (*Project, error)
+	GetProjectFiles(ctx context.Context, projectID string) ([]*ProjectFile, error)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"time"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// AggregateMetrics summarizes the file results of an analysis. It is saved
+// with the analysis and cached as its summary; its JSON keys are stable, so
+// consumers may rely on them.
+type AggregateMetrics struct {
+	TotalFiles        int     `json:"total_files"`
+	TotalLOC          int     `json:"total_loc"`
+	TotalComplexity   int     `json:"total_complexity"`
+	AverageComplexity float64 `json:"average_complexity"`
+	// LanguageDistribution counts the analyzed files of each language
+	LanguageDistribution map[string]int `json:"language_distribution"`
+	ErrorCount           int            `json:"error_count"`
+	SkippedCount         int            `json:"skipped_count"`
+	SkippedBinaryCount   int            `json:"skipped_binary_count"`
+	// EmptyCount counts the analyzed files with no code, which are left
+	// out of AverageComplexity
+	EmptyCount int `json:"empty_count"`
+
+	DuplicationRatio float64 `json:"duplication_ratio"`
+	DuplicatedLines  int     `json:"duplicated_lines"`
+	CloneGroups      int     `json:"clone_groups"`
+
+	// IssueCount and IssuesBySeverity leave out issues below MinSeverity,
+	// which are counted in SuppressedIssues only
+	IssueCount       int            `json:"issue_count"`
+	IssuesBySeverity map[string]int `json:"issues_by_severity"`
+	SuppressedIssues int            `json:"suppressed_issues"`
+	Vulnerabilities  int            `json:"vulnerabilities"`
+	SecurityHotspots int            `json:"security_hotspots"`
+	MinSeverity      string         `json:"min_severity,omitempty"`
+
+	Coverage          float64 `json:"coverage"`
+	CoverageEstimated bool    `json:"coverage_estimated"`
+
+	// Truncated is set when FilesOverLimit files were left out by the file
+	// limit
+	Truncated      bool           `json:"truncated,omitempty"`
+	FilesOverLimit int            `json:"files_over_limit,omitempty"`
+	Resources      *ResourceUsage `json:"resources,omitempty"`
+
+	AnalysisTimestamp time.Time `json:"analysis_timestamp"`
+	AnalyzerVersion   string    `json:"analyzer_version"`
+	MetricsVersion    string    `json:"metrics_version"`
+}
+
+// isEmpty reports whether an analyzed file had no code to measure
+func isEmpty(result *FileAnalysisResult) bool {
+	empty, _ := result.Metrics["empty"].(bool)
+	return empty
+}
+
+// NewAggregateMetrics aggregates file results and the clones found across
+// them, which may be nil. Issues below minSeverity are counted as
+// suppressed only.
+func NewAggregateMetrics(results []*FileAnalysisResult, clones *metrics.CloneReport, minSeverity string) *AggregateMetrics {
+	aggregate := &AggregateMetrics{
+		TotalFiles:           len(results),
+		LanguageDistribution: make(map[string]int),
+		MinSeverity:          minSeverity,
+		AnalysisTimestamp:    utils.Now(),
+		AnalyzerVersion:      analyzer.AnalyzerVersion,
+		MetricsVersion:       metrics.MetricsVersion,
+	}
+	issues := issueCounts{minSeverity: minSeverity, bySeverity: make(map[string]int)}
+
+	for _, result := range results {
+		if result.Skipped != "" {
+			aggregate.SkippedCount++
+			if result.Skipped == SkipReasonBinary {
+				aggregate.SkippedBinaryCount++
+			}
+			continue
+		}
+		// Files without metrics are still scanned for secrets
+		issues.add(result)
+		if result.Error != "" {
+			aggregate.ErrorCount++
+			continue
+		}
+		aggregate.TotalLOC += result.LOC
+		aggregate.TotalComplexity += result.Complexity
+		aggregate.LanguageDistribution[result.Language]++
+		if isEmpty(result) {
+			aggregate.EmptyCount++
+		}
+	}
+
+	if analyzed := aggregate.TotalFiles - aggregate.ErrorCount - aggregate.SkippedCount - aggregate.EmptyCount; analyzed > 0 {
+		aggregate.AverageComplexity = float64(aggregate.TotalComplexity) / float64(analyzed)
+	}
+	if clones != nil {
+		aggregate.DuplicationRatio = clones.DuplicationRatio()
+		aggregate.DuplicatedLines = clones.DuplicatedLines
+		aggregate.CloneGroups = len(clones.Groups)
+	}
+	aggregate.IssueCount = issues.total
+	aggregate.IssuesBySeverity = issues.bySeverity
+	aggregate.SuppressedIssues = issues.suppressed
+	aggregate.Vulnerabilities = issues.vulnerabilities
+	aggregate.SecurityHotspots = issues.hotspots
+	aggregate.Coverage, aggregate.CoverageEstimated = aggregateCoverage(results)
+	return aggregate
+}
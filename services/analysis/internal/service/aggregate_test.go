@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+)
+
+func TestNewAggregateMetrics(t *testing.T) {
+	results := []*service.FileAnalysisResult{
+		{
+			FilePath:   "main.go",
+			Language:   "go",
+			LOC:        30,
+			Complexity: 6,
+			Metrics:    map[string]interface{}{"test_coverage": 40.0},
+			Issues:     []metrics.Issue{{Type: "code_smell", Severity: metrics.SeverityMajor, Rule: "long-function"}},
+		},
+		{FilePath: "util.go", Language: "go", LOC: 10, Complexity: 2, Metrics: map[string]interface{}{"test_coverage": 60.0}},
+		{FilePath: "broken.py", Language: "python", Error: "parse error"},
+		{FilePath: "logo.png", Skipped: service.SkipReasonBinary},
+	}
+	clones := &metrics.CloneReport{DuplicatedLines: 4, TotalLines: 40, Groups: make([]metrics.CloneGroup, 1)}
+
+	aggregate := service.NewAggregateMetrics(results, clones, "")
+	assert.Equal(t, 4, aggregate.TotalFiles)
+	assert.Equal(t, 40, aggregate.TotalLOC)
+	assert.Equal(t, 8, aggregate.TotalComplexity)
+	assert.Equal(t, 4.0, aggregate.AverageComplexity)
+	assert.Equal(t, map[string]int{"go": 2}, aggregate.LanguageDistribution)
+	assert.Equal(t, 1, aggregate.ErrorCount)
+	assert.Equal(t, 1, aggregate.SkippedCount)
+	assert.Equal(t, 1, aggregate.SkippedBinaryCount)
+	assert.Equal(t, 0.1, aggregate.DuplicationRatio)
+	assert.Equal(t, 1, aggregate.CloneGroups)
+	assert.Equal(t, 1, aggregate.IssueCount)
+	assert.Equal(t, 50.0, aggregate.Coverage)
+	assert.True(t, aggregate.CoverageEstimated)
+	assert.Equal(t, analyzer.AnalyzerVersion, aggregate.AnalyzerVersion)
+
+	t.Run("serializes with stable keys", func(t *testing.T) {
+		data, err := json.Marshal(aggregate)
+		require.NoError(t, err)
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+
+		keys := make([]string, 0, len(decoded))
+		for key := range decoded {
+			keys = append(keys, key)
+		}
+		assert.ElementsMatch(t, []string{
+			"total_files", "total_loc", "total_complexity", "average_complexity",
+			"language_distribution", "error_count", "skipped_count", "skipped_binary_count", "empty_count",
+			"duplication_ratio", "duplicated_lines", "clone_groups",
+			"issue_count", "issues_by_severity", "suppressed_issues", "vulnerabilities", "security_hotspots",
+			"coverage", "coverage_estimated",
+			"analysis_timestamp", "analyzer_version", "metrics_version",
+		}, keys, "min_severity, truncated, files_over_limit and resources are left out when unset")
+		assert.Equal(t, float64(40), decoded["total_loc"])
+		assert.Equal(t, map[string]interface{}{"go": float64(2)}, decoded["language_distribution"])
+		assert.Equal(t, map[string]interface{}{metrics.SeverityMajor: float64(1)}, decoded["issues_by_severity"])
+	})
+
+	t.Run("empty files", func(t *testing.T) {
+		withEmpty := append(results[:len(results):len(results)],
+			&service.FileAnalysisResult{FilePath: "doc.go", Language: "go", LOC: 3, Metrics: map[string]interface{}{"empty": true, "test_coverage": 0.0}},
+		)
+		aggregate := service.NewAggregateMetrics(withEmpty, clones, "")
+		assert.Equal(t, 1, aggregate.EmptyCount)
+		assert.Equal(t, map[string]int{"go": 3}, aggregate.LanguageDistribution)
+		assert.Equal(t, 4.0, aggregate.AverageComplexity, "empty files are left out of the average")
+		assert.Equal(t, 50.0, aggregate.Coverage, "and of the coverage estimate")
+	})
+
+	t.Run("without clones", func(t *testing.T) {
+		aggregate := service.NewAggregateMetrics(results, nil, metrics.SeverityMajor)
+		assert.Zero(t, aggregate.DuplicationRatio)
+		assert.Zero(t, aggregate.CloneGroups)
+
+		data, err := json.Marshal(aggregate)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"min_severity":"major"`)
+	})
+}
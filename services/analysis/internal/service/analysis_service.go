@@ -3,9 +3,12 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"runtime"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,12 +16,49 @@ import (
 	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/sa3d-modernized/sa3d/shared/events"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
 
 	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
 	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/notify"
 	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
 )
 
+var (
+	// ErrProjectNotFound is returned when the project to analyze does not exist
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrEventsDisabled is returned when publishing while no event writer is
+	// configured or the event publishing feature flag is off
+	ErrEventsDisabled = errors.New("event publishing disabled")
+	// ErrAnalysisTimeout is the cancellation cause of an analysis that ran
+	// longer than the configured maximum duration
+	ErrAnalysisTimeout = errors.New("analysis exceeded maximum duration")
+	// ErrRequestCancelled is the cancellation cause of a synchronous
+	// analysis whose request ended before it finished
+	ErrRequestCancelled = errors.New("request cancelled")
+	// ErrAnalysisFinished is returned when cancelling an analysis that has
+	// already completed, failed or been cancelled
+	ErrAnalysisFinished = errors.New("analysis already finished")
+	// ErrStorageUnavailable wraps storage failures that may pass on retry,
+	// such as a dropped connection or a serialization conflict
+	ErrStorageUnavailable = errors.New("storage temporarily unavailable")
+)
+
+// saveRetryDelay is the wait before saving results again after a failure
+// that may pass
+const saveRetryDelay = 100 * time.Millisecond
+
+// DefaultMaxDuration bounds the wall-clock time of a single analysis
+const DefaultMaxDuration = 2 * time.Hour
+
+// DefaultFileTimeout bounds analyzing and measuring a single file, however
+// much of the analysis' MaxDuration is left
+const DefaultFileTimeout = time.Minute
+
 // AnalysisStatus represents the status of an analysis job
 type AnalysisStatus string
 
@@ -30,6 +70,11 @@ const (
 	StatusCancelled AnalysisStatus = "CANCELLED"
 )
 
+// Finished reports whether the status is final: completed, failed or cancelled
+func (st AnalysisStatus) Finished() bool {
+	return st == StatusCompleted || st == StatusFailed || st == StatusCancelled
+}
+
 // AnalysisJob represents an analysis job
 type AnalysisJob struct {
 	ID          string         `json:"id"`
@@ -40,6 +85,28 @@ type AnalysisJob struct {
 	Error       string         `json:"error,omitempty"`
 	Progress    int            `json:"progress"`
 	TotalFiles  int            `json:"total_files"`
+	// Languages restricts the run to these languages; empty means all
+	Languages []string `json:"languages,omitempty"`
+	// AnalyzerVersion and MetricsVersion record the logic that produced the results
+	AnalyzerVersion string `json:"analyzer_version"`
+	MetricsVersion  string `json:"metrics_version"`
+	// MinSeverity is the project's minimum severity counted in the
+	// aggregate metrics when the job was created
+	MinSeverity string `json:"min_severity,omitempty"`
+	// Priority orders the job among queued analyses
+	Priority Priority `json:"priority"`
+	// Resources records what the run cost; set once it completes
+	Resources *ResourceUsage `json:"resources,omitempty"`
+	// BaseAnalysisID and Paths are set for partial analyses: only Paths are
+	// re-analyzed, the other files' results come from the base analysis
+	BaseAnalysisID string   `json:"base_analysis_id,omitempty"`
+	Paths          []string `json:"paths,omitempty"`
+	// FilesOverLimit counts the files a truncated run left out for being
+	// over the file limit
+	FilesOverLimit int `json:"files_over_limit,omitempty"`
+	// Coverage is the coverage report supplied with the run; it is only
+	// needed while the run lasts and isn't stored
+	Coverage *metrics.CoverageReport `json:"-"`
 }
 
 // FileAnalysisResult represents the analysis result for a single file
@@ -50,54 +117,376 @@ type FileAnalysisResult struct {
 	Complexity int                    `json:"complexity"`
 	Metrics    map[string]interface{} `json:"metrics"`
 	Error      string                 `json:"error,omitempty"`
+	Skipped    string                 `json:"skipped,omitempty"` // reason the file wasn't analyzed
+	Issues     []metrics.Issue        `json:"issues,omitempty"`
+	Functions  []FunctionSummary      `json:"functions,omitempty"`
+	// ContentHash and Signature fingerprint the file's content so analyses
+	// can be compared across renames; binary files have no signature
+	ContentHash string   `json:"content_hash,omitempty"`
+	Signature   []uint32 `json:"signature,omitempty"`
+	// AnalyzerVersion and MetricsVersion record the logic that produced this result
+	AnalyzerVersion string `json:"analyzer_version"`
+	MetricsVersion  string `json:"metrics_version"`
+}
+
+// AnalysisRepository persists analysis jobs
+type AnalysisRepository interface {
+	CreateJob(ctx context.Context, job *AnalysisJob) error
+	GetJob(ctx context.Context, jobID string) (*AnalysisJob, error)
+	UpdateJob(ctx context.Context, job *AnalysisJob) error
+	// GetLatestJob returns the project's most recently started job with the
+	// status, or nil if it has none
+	GetLatestJob(ctx context.Context, projectID string, status AnalysisStatus) (*AnalysisJob, error)
+}
+
+// MetricsRepository persists per-file and aggregate analysis results
+type MetricsRepository interface {
+	SaveAnalysisResults(ctx context.Context, analysisID string, results []*FileAnalysisResult, aggregate *AggregateMetrics) error
+	GetAnalysisResults(ctx context.Context, analysisID string) ([]*FileAnalysisResult, error)
 }
 
 // AnalysisService handles code analysis operations
 type AnalysisService struct {
 	projectRepo  repository.ProjectRepository
-	analysisRepo repository.AnalysisRepository
-	metricsRepo  repository.MetricsRepository
+	analysisRepo AnalysisRepository
+	metricsRepo  MetricsRepository
+	resultCache  ResultCache
+	ruleEngine   *metrics.RuleEngine
+	calculator   metrics.CalculatorConfig
+	advisories   *metrics.AdvisoryDatabase
+	fileFilter   FileFilter
+	fileLimit    FileLimit
 	redisClient  *redis.Client
-	kafkaWriter  *kafka.Writer
+	publisher    *eventPublisher
+	eventRetry   EventRetry
+	eventBuffer  int
+	kafkaHealth  *KafkaHealth
+	eventCodec   events.Codec
+	features     *services.FeatureFlags
+	notifier     *notify.Dispatcher
+	clock        utils.Clock
 	logger       *logrus.Logger
 	workerPool   int
-	cancelFuncs  sync.Map // map[analysisID]context.CancelFunc
+	fileSlots    *semaphore.Weighted
+	maxDuration  time.Duration
+	fileTimeout  time.Duration
+	cancelFuncs  sync.Map // map[analysisID]context.CancelCauseFunc
+	observers    sync.Map // map[analysisID]func(*FileAnalysisResult)
+	running      atomic.Int32
+	queue        *analysisQueue
 }
 
-// NewAnalysisService creates a new analysis service
+// NewAnalysisService creates a new analysis service. A nil kafkaWriter
+// disables event publishing.
 func NewAnalysisService(
 	projectRepo repository.ProjectRepository,
-	analysisRepo repository.AnalysisRepository,
-	metricsRepo repository.MetricsRepository,
+	analysisRepo AnalysisRepository,
+	metricsRepo MetricsRepository,
 	redisClient *redis.Client,
 	kafkaWriter *kafka.Writer,
 	logger *logrus.Logger,
 ) *AnalysisService {
-	workerPool := runtime.NumCPU() * 2
-	if workerPool < 4 {
-		workerPool = 4
+	workerPool := DefaultMaxConcurrentFiles()
+
+	var resultCache ResultCache
+	if redisClient != nil {
+		resultCache = NewRedisResultCache(redisClient, ResultVersion(), defaultResultCacheTTL)
 	}
 
-	return &AnalysisService{
+	s := &AnalysisService{
 		projectRepo:  projectRepo,
 		analysisRepo: analysisRepo,
 		metricsRepo:  metricsRepo,
+		resultCache:  resultCache,
+		ruleEngine:   metrics.NewRuleEngine(),
+		fileFilter:   DefaultFileFilter(),
+		fileLimit:    FileLimit{Max: DefaultMaxFiles, Policy: FileLimitTruncate},
 		redisClient:  redisClient,
-		kafkaWriter:  kafkaWriter,
+		eventCodec:   events.JSONCodec{},
+		eventRetry:   DefaultEventRetry,
+		eventBuffer:  DefaultEventBuffer,
+		clock:        utils.SystemClock{},
 		logger:       logger,
 		workerPool:   workerPool,
+		fileSlots:    semaphore.NewWeighted(int64(workerPool)),
+		maxDuration:  DefaultMaxDuration,
+		fileTimeout:  DefaultFileTimeout,
+	}
+	if kafkaWriter != nil {
+		s.SetEventWriter(kafkaWriter)
+	}
+	s.queue = newAnalysisQueue(DefaultMaxConcurrentAnalyses, s.runAnalysis)
+	return s
+}
+
+// SetResultCache replaces the content-hash result cache; nil disables caching
+func (s *AnalysisService) SetResultCache(cache ResultCache) {
+	s.resultCache = cache
+}
+
+// SetRuleEngine replaces the rules used to detect code smells. Cached results
+// from the default Redis cache are keyed by the rule configuration too, so
+// changing rules doesn't serve issues found under the old ones.
+func (s *AnalysisService) SetRuleEngine(engine *metrics.RuleEngine) {
+	s.ruleEngine = engine
+	s.versionResultCache()
+}
+
+// SetCalculatorConfig replaces the configuration metrics are calculated
+// with, such as the per-language debt weights. Like rules, it is part of
+// the key of results cached in the default Redis cache.
+func (s *AnalysisService) SetCalculatorConfig(config metrics.CalculatorConfig) {
+	s.calculator = config
+	s.versionResultCache()
+}
+
+// versionResultCache keys the default Redis result cache by the rules and
+// calculator configuration, on top of the analyzer and metrics versions
+func (s *AnalysisService) versionResultCache() {
+	cache, ok := s.resultCache.(*RedisResultCache)
+	if !ok {
+		return
 	}
+	version := ResultVersion() + "." + s.ruleEngine.Fingerprint()
+	if fingerprint := s.calculator.Fingerprint(); fingerprint != "" {
+		version += "." + fingerprint
+	}
+	s.resultCache = cache.WithVersion(version)
+}
+
+// SetAdvisories enables checking the dependencies declared in manifests
+// against known vulnerabilities; nil skips the check
+func (s *AnalysisService) SetAdvisories(advisories *metrics.AdvisoryDatabase) {
+	s.advisories = advisories
+}
+
+// SetFileFilter replaces the filter deciding which project files are analyzed
+func (s *AnalysisService) SetFileFilter(filter FileFilter) {
+	s.fileFilter = filter
+}
+
+// SetFileLimit caps how many files an analysis processes
+func (s *AnalysisService) SetFileLimit(limit FileLimit) {
+	s.fileLimit = limit
+}
+
+// SetNotificationDispatcher enables notifications for finished analyses
+func (s *AnalysisService) SetNotificationDispatcher(dispatcher *notify.Dispatcher) {
+	s.notifier = dispatcher
+}
+
+// SetClock sets the clock analyses are dated by
+func (s *AnalysisService) SetClock(clock utils.Clock) {
+	s.clock = clock
+}
+
+// SetMaxDuration sets how long an analysis may run before it is cancelled
+// and marked failed; zero disables the limit
+func (s *AnalysisService) SetMaxDuration(maxDuration time.Duration) {
+	s.maxDuration = maxDuration
+}
+
+// SetFileTimeout sets how long a single file may take to analyze before it
+// is recorded as timed out and its worker moves on; zero disables the limit
+func (s *AnalysisService) SetFileTimeout(timeout time.Duration) {
+	s.fileTimeout = timeout
+}
+
+// SetMaxConcurrentAnalyses sets how many analyses may run at once; further
+// analyses wait in a priority queue. Zero removes the limit.
+func (s *AnalysisService) SetMaxConcurrentAnalyses(maxConcurrent int) {
+	s.queue.setMaxConcurrent(maxConcurrent)
+}
+
+// DefaultMaxConcurrentFiles is the default bound on files analyzed at once
+// across all analyses: twice the CPUs, and at least 4
+func DefaultMaxConcurrentFiles() int {
+	return max(runtime.NumCPU()*2, 4)
+}
+
+// SetMaxConcurrentFiles sets how many files may be analyzed at once across
+// all running analyses, so many analyses don't oversubscribe the CPUs;
+// workers of every analysis wait for a slot before each file. Zero uses
+// DefaultMaxConcurrentFiles. Set it before analyses start.
+func (s *AnalysisService) SetMaxConcurrentFiles(maxConcurrent int) {
+	if maxConcurrent <= 0 {
+		maxConcurrent = DefaultMaxConcurrentFiles()
+	}
+	s.fileSlots = semaphore.NewWeighted(int64(maxConcurrent))
+}
+
+// SetEventCodec selects the serialization used for published events
+func (s *AnalysisService) SetEventCodec(codec events.Codec) {
+	s.eventCodec = codec
+}
+
+// SetEventWriter replaces the writer events are published with; nil
+// disables event publishing
+func (s *AnalysisService) SetEventWriter(writer EventWriter) {
+	if writer == nil {
+		s.publisher = nil
+		return
+	}
+	s.publisher = newEventPublisher(writer, s.eventRetry, s.logger)
+	s.publisher.buffer = s.eventBuffer
+	s.publisher.health = s.kafkaHealth
+}
+
+// SetEventRetry sets how failed event writes are retried. An analysis's
+// later events wait for the retries, so its events stay in order.
+func (s *AnalysisService) SetEventRetry(retry EventRetry) {
+	s.eventRetry = retry
+	if s.publisher != nil {
+		s.publisher.retry = retry
+	}
+}
+
+// SetEventBuffer sets how many events may wait to be written, as they do
+// while Kafka is unreachable; events published beyond it are dropped. Zero
+// removes the limit.
+func (s *AnalysisService) SetEventBuffer(size int) {
+	s.eventBuffer = size
+	if s.publisher != nil {
+		s.publisher.buffer = size
+	}
+}
+
+// SetKafkaHealth sets the check reporting whether Kafka is reachable. It is
+// surfaced in readiness, and event writes wait while it fails.
+func (s *AnalysisService) SetKafkaHealth(health *KafkaHealth) {
+	s.kafkaHealth = health
+	if s.publisher != nil {
+		s.publisher.health = health
+	}
+}
+
+// KafkaHealth returns the Kafka health check, nil without one
+func (s *AnalysisService) KafkaHealth() *KafkaHealth {
+	return s.kafkaHealth
+}
+
+// FlushEvents waits until the events published so far are written, or
+// dropped after their retries, or ctx is done
+func (s *AnalysisService) FlushEvents(ctx context.Context) error {
+	if s.publisher == nil {
+		return nil
+	}
+	return s.publisher.flush(ctx)
+}
+
+// SetFeatureFlags sets the flags toggling behavior at runtime. Without them
+// events are published whenever there is a Kafka writer.
+func (s *AnalysisService) SetFeatureFlags(features *services.FeatureFlags) {
+	s.features = features
 }
 
 // StartAnalysis starts a new analysis job for a project
 func (s *AnalysisService) StartAnalysis(ctx context.Context, projectID string) (*AnalysisJob, error) {
+	return s.StartAnalysisWithOptions(ctx, projectID, AnalysisOptions{})
+}
+
+// StartAnalysisWithOptions starts a new analysis job for a project with
+// per-run options
+func (s *AnalysisService) StartAnalysisWithOptions(ctx context.Context, projectID string, opts AnalysisOptions) (*AnalysisJob, error) {
+	job, project, err := s.createJob(ctx, projectID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Queue the analysis to run in background, detached from the request
+	// but keeping its request ID for log correlation
+	analysisCtx, cancel := context.WithCancelCause(utils.WithRequestID(context.Background(), utils.RequestIDFromContext(ctx)))
+	s.cancelFuncs.Store(job.ID, cancel)
+
+	// The run updates its own copy, leaving the returned job to the caller
+	run := *job
+	s.queue.submit(analysisCtx, &run, project)
+
+	return job, nil
+}
+
+// RunObserver receives the progress of a synchronous analysis. Both
+// callbacks are optional; FileAnalyzed runs on the analysis' collector, so
+// the run waits for it.
+type RunObserver struct {
+	Started      func(job *AnalysisJob)
+	FileAnalyzed func(result *FileAnalysisResult)
+}
+
+// RunAnalysisSync runs an analysis of the project and waits for it to
+// finish, returning the finished job. Unlike StartAnalysisWithOptions the
+// run is bound to ctx: when ctx ends first, for example because the client
+// disconnected, the analysis is cancelled with ErrRequestCancelled and
+// marked cancelled, and the cause is returned.
+func (s *AnalysisService) RunAnalysisSync(ctx context.Context, projectID string, opts AnalysisOptions, observer RunObserver) (*AnalysisJob, error) {
+	job, project, err := s.createJob(ctx, projectID, opts)
+	if err != nil {
+		return nil, err
+	}
+	if observer.Started != nil {
+		observer.Started(job)
+	}
+	if observer.FileAnalyzed != nil {
+		s.observers.Store(job.ID, observer.FileAnalyzed)
+		defer s.observers.Delete(job.ID)
+	}
+
+	// The run gets its own cause rather than inheriting ctx's, so it can
+	// tell a lost request from CancelAnalysis
+	analysisCtx, cancel := context.WithCancelCause(utils.WithRequestID(context.Background(), utils.RequestIDFromContext(ctx)))
+	defer cancel(nil)
+	requestEnded := func() {
+		cancel(fmt.Errorf("%w: %w", ErrRequestCancelled, context.Cause(ctx)))
+	}
+	stop := context.AfterFunc(ctx, requestEnded)
+	defer stop()
+	s.cancelFuncs.Store(job.ID, cancel)
+
+	select {
+	case <-s.queue.submit(analysisCtx, job, project):
+	case <-ctx.Done():
+		// The run records the cancellation once it notices
+		requestEnded()
+		return nil, context.Cause(analysisCtx)
+	}
+
+	finished, err := s.analysisRepo.GetJob(context.WithoutCancel(ctx), job.ID)
+	if err != nil {
+		return nil, err
+	}
+	if finished.Status == StatusCancelled && errors.Is(context.Cause(analysisCtx), ErrRequestCancelled) {
+		return nil, context.Cause(analysisCtx)
+	}
+	return finished, nil
+}
+
+// createJob validates the options and records a pending job for the project
+func (s *AnalysisService) createJob(ctx context.Context, projectID string, opts AnalysisOptions) (*AnalysisJob, *repository.Project, error) {
 	// Verify project exists
 	project, err := s.projectRepo.GetByID(ctx, projectID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get project: %w", err)
+		return nil, nil, fmt.Errorf("failed to get project: %w", err)
 	}
 	if project == nil {
-		return nil, fmt.Errorf("project not found")
+		return nil, nil, ErrProjectNotFound
+	}
+
+	// Languages chosen for this run override the project's settings
+	languageNames := opts.Languages
+	if len(languageNames) == 0 {
+		languageNames = project.EnabledLanguages
+	}
+	languages, err := parseLanguages(languageNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	minSeverity, err := projectMinSeverity(project)
+	if err != nil {
+		return nil, nil, err
+	}
+	coverage, err := parseCoverage(opts.Coverage)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Create analysis job
@@ -105,13 +494,21 @@ func (s *AnalysisService) StartAnalysis(ctx context.Context, projectID string) (
 		ID:        uuid.New().String(),
 		ProjectID: projectID,
 		Status:    StatusPending,
-		StartedAt: time.Now(),
+		StartedAt: s.clock.Now(),
 		Progress:  0,
+		Languages: languages.names(),
+		Priority:  opts.Priority,
+
+		MinSeverity: minSeverity,
+		Coverage:    coverage,
+
+		AnalyzerVersion: analyzer.AnalyzerVersion,
+		MetricsVersion:  metrics.MetricsVersion,
 	}
 
 	// Save job to database
 	if err := s.analysisRepo.CreateJob(ctx, job); err != nil {
-		return nil, fmt.Errorf("failed to create analysis job: %w", err)
+		return nil, nil, fmt.Errorf("failed to create analysis job: %w", err)
 	}
 
 	// Cache job status in Redis
@@ -119,13 +516,13 @@ func (s *AnalysisService) StartAnalysis(ctx context.Context, projectID string) (
 		s.logger.Warnf("Failed to cache job status: %v", err)
 	}
 
-	// Start analysis in background
-	analysisCtx, cancel := context.WithCancel(context.Background())
-	s.cancelFuncs.Store(job.ID, cancel)
-
-	go s.runAnalysis(analysisCtx, job, project)
+	s.logger.WithFields(logrus.Fields{
+		"analysis_id": job.ID,
+		"project_id":  projectID,
+		"request_id":  utils.RequestIDFromContext(ctx),
+	}).Info("Analysis started")
 
-	return job, nil
+	return job, project, nil
 }
 
 // runAnalysis performs the actual analysis
@@ -134,33 +531,91 @@ func (s *AnalysisService) runAnalysis(ctx context.Context, job *AnalysisJob, pro
 		s.cancelFuncs.Delete(job.ID)
 		if r := recover(); r != nil {
 			s.logger.Errorf("Analysis panic recovered: %v", r)
-			s.updateJobStatus(context.Background(), job.ID, StatusFailed, fmt.Sprintf("Analysis panic: %v", r))
+			s.failAnalysis(context.Background(), job, project, fmt.Sprintf("Analysis panic: %v", r))
+		}
+		if s.publisher != nil {
+			s.publisher.finish(job.ID)
 		}
 	}()
 
+	// Cancelled while queued
+	if s.cancelled(ctx, job.ID) {
+		return
+	}
+
+	if s.maxDuration > 0 {
+		timer := time.AfterFunc(s.maxDuration, func() {
+			s.cancelJob(job.ID, ErrAnalysisTimeout)
+		})
+		defer timer.Stop()
+	}
+
 	// Update status to running
 	job.Status = StatusRunning
 	if err := s.updateJobStatus(ctx, job.ID, StatusRunning, ""); err != nil {
 		s.logger.Errorf("Failed to update job status: %v", err)
 		return
 	}
+	s.publishAnalysisEvent(job.ID, events.AnalysisStarted{ProjectID: project.ID})
+
+	s.running.Add(1)
+	defer s.running.Add(-1)
+	meter := startResourceMeter(func() int { return int(s.running.Load()) - 1 })
+	defer meter.stopSampling()
 
 	// Get project files
 	files, err := s.projectRepo.GetProjectFiles(ctx, project.ID)
 	if err != nil {
-		s.updateJobStatus(ctx, job.ID, StatusFailed, fmt.Sprintf("Failed to get project files: %v", err))
+		s.failAnalysis(ctx, job, project, fmt.Sprintf("Failed to get project files: %v", err))
 		return
 	}
 
+	files, skipped := s.fileFilter.Apply(files)
+	if len(skipped) > 0 {
+		s.logger.WithFields(logrus.Fields{
+			"analysis_id": job.ID,
+			"request_id":  utils.RequestIDFromContext(ctx),
+		}).Infof("Skipping %d files excluded by filter", len(skipped))
+	}
+
+	files, overLimit, err := s.fileLimit.apply(files)
+	if err != nil {
+		s.failAnalysis(ctx, job, project, err.Error())
+		return
+	}
+	if len(overLimit) > 0 {
+		job.FilesOverLimit = len(overLimit)
+		s.logger.WithFields(logrus.Fields{
+			"analysis_id": job.ID,
+			"request_id":  utils.RequestIDFromContext(ctx),
+			"max_files":   s.fileLimit.Max,
+		}).Warnf("Skipping %d files over the file limit", len(overLimit))
+	}
+
+	// A partial analysis re-analyzes just its paths, while clones are
+	// still detected across every file
+	sources := files
+	var carried []*FileAnalysisResult
+	if job.BaseAnalysisID != "" {
+		files, sources, carried, err = s.partialFiles(ctx, job, files)
+		if err != nil {
+			s.failAnalysis(ctx, job, project, fmt.Sprintf("Failed to load base analysis: %v", err))
+			return
+		}
+	}
+
 	job.TotalFiles = len(files)
 	s.cacheJobStatus(ctx, job)
 
+	// Validated when the job was created
+	languages, _ := parseLanguages(job.Languages)
+
 	// Create channels for worker pool
 	fileChan := make(chan *repository.ProjectFile, len(files))
 	resultChan := make(chan *FileAnalysisResult, len(files))
 
 	// Start worker pool
-	g, ctx := errgroup.WithContext(ctx)
+	g, groupCtx := errgroup.WithContext(ctx)
 	
 	// Producer: send files to channel
 	g.Go(func() error {
@@ -168,25 +623,27 @@ func (s *AnalysisService) runAnalysis(ctx context.Context, job *AnalysisJob, pro
 		for _, file := range files {
 			select {
 			case fileChan <- file:
-			case <-ctx.Done():
-				return ctx.Err()
+			case <-groupCtx.Done():
+				return groupCtx.Err()
 			}
 		}
 		return nil
 	})
 
-	// Workers: analyze files
+	// Workers: analyze files, each taking one of the slots shared by all
+	// analyses for every file
+	slots := s.fileSlots
 	for i := 0; i < s.workerPool; i++ {
 		g.Go(func() error {
 			for file := range fileChan {
-				result := s.analyzeFile(ctx, file)
+				if err := slots.Acquire(groupCtx, 1); err != nil {
+					return err
+				}
+				result := s.safeAnalyzeFile(groupCtx, job.ID, file, languages, func() { slots.Release(1) })
 				select {
 				case resultChan <- result:
-					// Update progress
-					job.Progress++
-					s.cacheJobStatus(ctx, job)
-				case <-ctx.Done():
-					return ctx.Err()
+				case <-groupCtx.Done():
+					return groupCtx.Err()
 				}
 			}
 			return nil
@@ -199,66 +656,243 @@ func (s *AnalysisService) runAnalysis(ctx context.Context, job *AnalysisJob, pro
 		for i := 0; i < len(files); i++ {
 			select {
 			case result := <-resultChan:
+				applyCoverage(job.Coverage, result)
 				results = append(results, result)
-			case <-ctx.Done():
-				return ctx.Err()
+				// Update progress; only the collector touches the job here
+				job.Progress++
+				s.cacheJobStatus(groupCtx, job)
+				if progressMilestone(job.Progress, len(files)) {
+					s.publishAnalysisEvent(job.ID, events.AnalysisProgress{
+						ProjectID:     project.ID,
+						FilesAnalyzed: job.Progress,
+						TotalFiles:    job.TotalFiles,
+					})
+				}
+				if observe, ok := s.observers.Load(job.ID); ok {
+					observe.(func(*FileAnalysisResult))(result)
+				}
+			case <-groupCtx.Done():
+				return groupCtx.Err()
 			}
 		}
 		return nil
 	})
 
 	// Wait for all goroutines to complete
-	if err := g.Wait(); err != nil {
-		s.updateJobStatus(ctx, job.ID, StatusFailed, fmt.Sprintf("Analysis failed: %v", err))
+	err = g.Wait()
+	if s.timedOut(ctx) {
+		s.finishTimedOut(ctx, job, project, files, results)
+		return
+	}
+	if s.cancelled(ctx, job.ID) {
+		return
+	}
+	if err != nil {
+		s.failAnalysis(ctx, job, project, fmt.Sprintf("Analysis failed: %v", err))
 		return
 	}
 
+	results = append(results, carried...)
+	clones := s.detectClones(sources, results)
+	job.Resources = meter.Stop(len(files))
+
 	// Process and save results
-	if err := s.processResults(ctx, job, results); err != nil {
-		s.updateJobStatus(ctx, job.ID, StatusFailed, fmt.Sprintf("Failed to process results: %v", err))
+	if err := s.processResults(ctx, job, results, clones); err != nil {
+		if s.timedOut(ctx) {
+			s.finishTimedOut(ctx, job, project, files, results)
+			return
+		}
+		if s.cancelled(ctx, job.ID) {
+			return
+		}
+		s.failAnalysis(ctx, job, project, fmt.Sprintf("Failed to process results: %v", err))
 		return
 	}
 
 	// Update job status to completed
+	if err := s.saveResourceUsage(ctx, job.ID, job.Resources); err != nil {
+		s.logger.WithField("analysis_id", job.ID).Warnf("Failed to save resource usage: %v", err)
+	}
 	s.updateJobStatus(ctx, job.ID, StatusCompleted, "")
+	s.notifyFinished(project, job, events.TypeAnalysisCompleted, "")
 
 	// Publish completion event
-	s.publishAnalysisEvent(job.ID, "analysis.completed", map[string]interface{}{
-		"project_id":   project.ID,
-		"analysis_id":  job.ID,
-		"total_files":  job.TotalFiles,
-		"completed_at": time.Now(),
+	s.publishAnalysisEvent(job.ID, events.AnalysisCompleted{
+		ProjectID:   project.ID,
+		TotalFiles:  job.TotalFiles,
+		CompletedAt: s.clock.Now(),
 	})
 }
 
-// analyzeFile analyzes a single file
-func (s *AnalysisService) analyzeFile(ctx context.Context, file *repository.ProjectFile) *FileAnalysisResult {
+// safeAnalyzeFile analyzes a single file within the file timeout, calling
+// release once the analyzer returns. An analyzer that overruns it is
+// abandoned: the file is recorded with a timeout error and the worker moves
+// on, while the analyzer is left to notice its cancelled context and keeps
+// its slot until it does.
+func (s *AnalysisService) safeAnalyzeFile(ctx context.Context, analysisID string, file *repository.ProjectFile, languages languageSet, release func()) *FileAnalysisResult {
+	if s.fileTimeout <= 0 {
+		defer release()
+		return s.recoverAnalyzeFile(ctx, analysisID, file, languages)
+	}
+
+	fileCtx, cancel := context.WithTimeout(ctx, s.fileTimeout)
+	defer cancel()
+	timer := time.NewTimer(s.fileTimeout)
+	defer timer.Stop()
+
+	done := make(chan *FileAnalysisResult, 1)
+	go func() {
+		defer release()
+		done <- s.recoverAnalyzeFile(fileCtx, analysisID, file, languages)
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case <-timer.C:
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"analysis_id": analysisID,
+		"request_id":  utils.RequestIDFromContext(ctx),
+		"file":        file.Path,
+		"timeout":     s.fileTimeout.String(),
+	}).Warn("File analysis timed out")
+
+	result := &FileAnalysisResult{
+		FilePath:        file.Path,
+		Language:        string(analyzer.DetectLanguage(file.Path, file.Content)),
+		Metrics:         map[string]interface{}{"timed_out": true},
+		Error:           fmt.Sprintf("Analysis timed out after %s", s.fileTimeout),
+		AnalyzerVersion: analyzer.AnalyzerVersion,
+		MetricsVersion:  metrics.MetricsVersion,
+	}
+	fingerprint(result, file)
+	return result
+}
+
+// recoverAnalyzeFile analyzes a single file, turning a panic into a
+// file-level error so one bad file doesn't fail the whole run
+func (s *AnalysisService) recoverAnalyzeFile(ctx context.Context, analysisID string, file *repository.ProjectFile, languages languageSet) (result *FileAnalysisResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.WithFields(logrus.Fields{
+				"analysis_id": analysisID,
+				"request_id":  utils.RequestIDFromContext(ctx),
+				"file":        file.Path,
+				"stack":       string(debug.Stack()),
+			}).Errorf("Analyzer panic recovered: %v", r)
+
+			result = &FileAnalysisResult{
+				FilePath:        file.Path,
+				Language:        string(analyzer.DetectLanguage(file.Path, file.Content)),
+				Metrics:         make(map[string]interface{}),
+				Error:           fmt.Sprintf("Analyzer panic: %v", r),
+				AnalyzerVersion: analyzer.AnalyzerVersion,
+				MetricsVersion:  metrics.MetricsVersion,
+			}
+		}
+	}()
+
+	result = s.analyzeFile(ctx, file, languages)
+	s.checkDependencies(result, file)
+	fingerprint(result, file)
+	return result
+}
+
+// checkDependencies reports the known vulnerabilities of the dependencies a
+// manifest declares. It runs on cached results too, as the advisories
+// change independently of the file.
+func (s *AnalysisService) checkDependencies(result *FileAnalysisResult, file *repository.ProjectFile) {
+	if s.advisories == nil || result.Skipped != "" {
+		return
+	}
+	result.Issues = append(result.Issues, s.advisories.CheckManifest(file.Path, file.Content)...)
+	setIssueFile(result)
+}
+
+// fingerprint records the content hash and signature of a file's result
+func fingerprint(result *FileAnalysisResult, file *repository.ProjectFile) {
+	result.ContentHash = metrics.ContentHash(file.Content)
+	if result.Skipped != SkipReasonBinary {
+		result.Signature = metrics.ContentSignature(file.Content)
+	}
+}
+
+// analyzeContent parses content with the language's analyzer. Content of
+// whitespace and comments only has nothing to parse, and some analyzers
+// reject it, so it yields an empty result for the calculator to flag.
+func analyzeContent(ctx context.Context, fileAnalyzer analyzer.Analyzer, language analyzer.Language, content []byte) (*analyzer.AnalysisResult, error) {
+	if metrics.CountLines(language, content).Code == 0 {
+		return &analyzer.AnalysisResult{Language: language}, nil
+	}
+	return fileAnalyzer.Analyze(ctx, content)
+}
+
+// analyzeFile analyzes a single file unless its language is not enabled
+func (s *AnalysisService) analyzeFile(ctx context.Context, file *repository.ProjectFile, languages languageSet) *FileAnalysisResult {
 	result := &FileAnalysisResult{
-		FilePath: file.Path,
-		Metrics:  make(map[string]interface{}),
+		FilePath:        file.Path,
+		Metrics:         make(map[string]interface{}),
+		AnalyzerVersion: analyzer.AnalyzerVersion,
+		MetricsVersion:  metrics.MetricsVersion,
+	}
+
+	// Analyzers assume text; binary content would only produce garbage
+	if s.fileFilter.skipsContent(file.Content) {
+		result.Skipped = SkipReasonBinary
+		return result
 	}
 
 	// Detect language
 	language := analyzer.DetectLanguage(file.Path, file.Content)
-	result.Language = language
+	result.Language = string(language)
+
+	if !languages.allows(language) {
+		result.Skipped = SkipReasonLanguageDisabled
+		return result
+	}
+
+	// Byte-identical content analyzed before yields the same result
+	if s.resultCache != nil {
+		if cached, ok := s.resultCache.Get(ctx, result.Language, file.Content); ok {
+			cached.FilePath = file.Path
+			for i := range cached.Issues {
+				cached.Issues[i].File = file.Path
+			}
+			return cached
+		}
+	}
+
+	// Secrets are scanned for in every text file, config files without an
+	// analyzer being where they are most often committed
+	secrets := metrics.ScanSecrets(file.Path, file.Content)
 
 	// Get appropriate analyzer
 	fileAnalyzer, err := analyzer.GetAnalyzer(language)
 	if err != nil {
 		result.Error = fmt.Sprintf("No analyzer available for language: %s", language)
+		result.Issues = secrets
+		setIssueFile(result)
 		return result
 	}
 
 	// Parse and analyze file
-	analysisResult, err := fileAnalyzer.Analyze(ctx, file.Content)
+	analysisResult, err := analyzeContent(ctx, fileAnalyzer, language, file.Content)
 	if err != nil {
 		result.Error = fmt.Sprintf("Analysis failed: %v", err)
+		result.Issues = secrets
+		setIssueFile(result)
 		return result
 	}
 
 	// Calculate metrics
-	metricsCalculator := metrics.NewCalculator()
-	fileMetrics := metricsCalculator.Calculate(analysisResult)
+	metricsCalculator := metrics.NewCalculatorWithConfig(s.ruleEngine, s.calculator)
+	fileMetrics := metricsCalculator.CalculateWithSource(analysisResult, file.Content)
+
+	result.Issues = append(fileMetrics.Issues, secrets...)
+	setIssueFile(result)
+	result.Functions = summarizeFunctions(analysisResult)
 
 	result.LOC = fileMetrics.LOC
 	result.Complexity = fileMetrics.CyclomaticComplexity
@@ -276,61 +910,134 @@ func (s *AnalysisService) analyzeFile(ctx context.Context, file *repository.Proj
 		"code_smells":         fileMetrics.CodeSmells,
 		"duplication_ratio":   fileMetrics.DuplicationRatio,
 		"test_coverage":       fileMetrics.TestCoverage,
+		"coverage_estimated":  fileMetrics.CoverageEstimated,
+	}
+
+	if fileMetrics.Empty {
+		result.Metrics["empty"] = true
+	}
+
+	// A file that exhausted the analyzer's time budget has partial metrics;
+	// flag it and keep it out of the cache so a later run can retry it
+	if analysisResult.Truncated {
+		result.Metrics["truncated"] = true
+		s.logger.WithFields(logrus.Fields{
+			"file":       file.Path,
+			"request_id": utils.RequestIDFromContext(ctx),
+		}).Warn("Analysis time budget exceeded, metrics are partial")
+		return result
+	}
+
+	if s.resultCache != nil {
+		if err := s.resultCache.Set(ctx, result.Language, file.Content, result); err != nil {
+			s.logger.Warnf("Failed to cache result for %s: %v", file.Path, err)
+		}
 	}
 
 	return result
 }
 
-// processResults processes and saves analysis results
-func (s *AnalysisService) processResults(ctx context.Context, job *AnalysisJob, results []*FileAnalysisResult) error {
-	// Calculate aggregate metrics
-	aggregateMetrics := s.calculateAggregateMetrics(results)
-
-	// Save results to database
-	if err := s.metricsRepo.SaveAnalysisResults(ctx, job.ID, results, aggregateMetrics); err != nil {
-		return fmt.Errorf("failed to save analysis results: %w", err)
+// setIssueFile points a result's issues at its file
+func setIssueFile(result *FileAnalysisResult) {
+	for i := range result.Issues {
+		result.Issues[i].File = result.FilePath
 	}
+}
 
-	// Cache summary in Redis for quick access
-	summaryKey := fmt.Sprintf("analysis:summary:%s", job.ID)
-	summaryData, _ := json.Marshal(aggregateMetrics)
-	s.redisClient.Set(ctx, summaryKey, summaryData, 24*time.Hour)
+// issueCounts tallies a run's issues for the aggregate metrics
+type issueCounts struct {
+	minSeverity     string
+	total           int
+	suppressed      int
+	vulnerabilities int
+	hotspots        int
+	bySeverity      map[string]int
+}
 
-	return nil
+// add counts a result's issues, leaving those below the minimum severity
+// out of every count but suppressed
+func (c *issueCounts) add(result *FileAnalysisResult) {
+	for _, issue := range result.Issues {
+		if !metrics.SeverityAtLeast(issue.Severity, c.minSeverity) {
+			c.suppressed++
+			continue
+		}
+		c.total++
+		c.bySeverity[issue.Severity]++
+		switch issue.Type {
+		case metrics.IssueTypeVulnerability:
+			c.vulnerabilities++
+		case metrics.IssueTypeSecurityHotspot:
+			c.hotspots++
+		}
+	}
 }
 
-// calculateAggregateMetrics calculates aggregate metrics from file results
-func (s *AnalysisService) calculateAggregateMetrics(results []*FileAnalysisResult) map[string]interface{} {
-	totalLOC := 0
-	totalComplexity := 0
-	totalFiles := len(results)
-	languageDistribution := make(map[string]int)
-	errorCount := 0
+// detectClones finds code duplicated across the project's files and records
+// it on the affected file results
+func (s *AnalysisService) detectClones(files []*repository.ProjectFile, results []*FileAnalysisResult) *metrics.CloneReport {
+	skipped := make(map[string]bool)
+	for _, result := range results {
+		if result.Skipped != "" {
+			skipped[result.FilePath] = true
+		}
+	}
+
+	sources := make([]metrics.SourceFile, 0, len(files))
+	for _, file := range files {
+		if skipped[file.Path] {
+			continue
+		}
+		sources = append(sources, metrics.SourceFile{Path: file.Path, Content: file.Content})
+	}
 
+	report := metrics.NewCloneDetector(0).Detect(sources)
+	issues := report.Issues()
 	for _, result := range results {
-		if result.Error != "" {
-			errorCount++
+		if result.Error != "" || result.Skipped != "" {
 			continue
 		}
-		totalLOC += result.LOC
-		totalComplexity += result.Complexity
-		languageDistribution[result.Language]++
+		result.Issues = append(result.Issues, issues[result.FilePath]...)
+		result.Metrics["duplication_ratio"] = report.FileDuplication[result.FilePath]
 	}
+	return report
+}
 
-	avgComplexity := 0.0
-	if totalFiles-errorCount > 0 {
-		avgComplexity = float64(totalComplexity) / float64(totalFiles-errorCount)
+// processResults processes and saves analysis results
+func (s *AnalysisService) processResults(ctx context.Context, job *AnalysisJob, results []*FileAnalysisResult, clones *metrics.CloneReport) error {
+	// Calculate aggregate metrics
+	aggregateMetrics := NewAggregateMetrics(results, clones, job.MinSeverity)
+	aggregateMetrics.AnalysisTimestamp = s.clock.Now()
+	aggregateMetrics.Resources = job.Resources
+	if job.FilesOverLimit > 0 {
+		aggregateMetrics.Truncated = true
+		aggregateMetrics.FilesOverLimit = job.FilesOverLimit
 	}
 
-	return map[string]interface{}{
-		"total_files":           totalFiles,
-		"total_loc":             totalLOC,
-		"total_complexity":      totalComplexity,
-		"average_complexity":    avgComplexity,
-		"language_distribution": languageDistribution,
-		"error_count":           errorCount,
-		"analysis_timestamp":    time.Now(),
+	// Save results to database. Saving replaces earlier results, so a
+	// transient failure is retried once.
+	err := s.metricsRepo.SaveAnalysisResults(ctx, job.ID, results, aggregateMetrics)
+	if errors.Is(err, ErrStorageUnavailable) {
+		s.logger.WithFields(logrus.Fields{
+			"analysis_id": job.ID,
+			"request_id":  utils.RequestIDFromContext(ctx),
+		}).WithError(err).Warnf("Failed to save analysis results, retrying in %s", saveRetryDelay)
+		select {
+		case <-time.After(saveRetryDelay):
+			err = s.metricsRepo.SaveAnalysisResults(ctx, job.ID, results, aggregateMetrics)
+		case <-ctx.Done():
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save analysis results: %w", err)
 	}
+
+	// Cache summary in Redis for quick access
+	summaryKey := fmt.Sprintf("analysis:summary:%s", job.ID)
+	summaryData, _ := json.Marshal(aggregateMetrics)
+	s.redisClient.Set(ctx, summaryKey, summaryData, 24*time.Hour)
+
+	return nil
 }
 
 // updateJobStatus updates the job status in database and cache
@@ -344,8 +1051,8 @@ func (s *AnalysisService) updateJobStatus(ctx context.Context, jobID string, sta
 	if errorMsg != "" {
 		job.Error = errorMsg
 	}
-	if status == StatusCompleted || status == StatusFailed || status == StatusCancelled {
-		now := time.Now()
+	if status.Finished() {
+		now := s.clock.Now()
 		job.CompletedAt = &now
 	}
 
@@ -353,9 +1060,38 @@ func (s *AnalysisService) updateJobStatus(ctx context.Context, jobID string, sta
 		return err
 	}
 
+	s.invalidateGatewayCache(ctx, "analysisId", jobID)
+	if status == StatusCompleted {
+		// A completed analysis changes the project's metrics
+		s.invalidateGatewayCache(ctx, "projectId", job.ProjectID)
+	}
 	return s.cacheJobStatus(ctx, job)
 }
 
+// invalidateGatewayCache drops the gateway's cached reads of routes with the
+// parameter value, such as an analysis's status and results, so clients see
+// its new state
+func (s *AnalysisService) invalidateGatewayCache(ctx context.Context, param, value string) {
+	tagKey := utils.ResponseCacheTagKey(param, value)
+	keys, err := s.redisClient.SMembers(ctx, tagKey).Result()
+	if err == nil {
+		err = s.redisClient.Del(ctx, append(keys, tagKey)...).Err()
+	}
+	if err != nil {
+		s.logger.WithField("cache_tag", tagKey).Warnf("Failed to invalidate cached gateway responses: %v", err)
+	}
+}
+
+// saveResourceUsage stores what a run cost on its job
+func (s *AnalysisService) saveResourceUsage(ctx context.Context, jobID string, usage *ResourceUsage) error {
+	job, err := s.analysisRepo.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	job.Resources = usage
+	return s.analysisRepo.UpdateJob(ctx, job)
+}
+
 // cacheJobStatus caches job status in Redis
 func (s *AnalysisService) cacheJobStatus(ctx context.Context, job *AnalysisJob) error {
 	key := fmt.Sprintf("analysis:job:%s", job.ID)
@@ -366,29 +1102,160 @@ func (s *AnalysisService) cacheJobStatus(ctx context.Context, job *AnalysisJob)
 	return s.redisClient.Set(ctx, key, data, 24*time.Hour).Err()
 }
 
-// publishAnalysisEvent publishes an event to Kafka
-func (s *AnalysisService) publishAnalysisEvent(analysisID, eventType string, data map[string]interface{}) {
-	event := map[string]interface{}{
-		"analysis_id": analysisID,
-		"event_type":  eventType,
-		"timestamp":   time.Now(),
-		"data":        data,
+// cancelled reports whether the run was cancelled. CancelAnalysis records
+// the status itself; a run whose request ended records it here.
+func (s *AnalysisService) cancelled(ctx context.Context, jobID string) bool {
+	if ctx.Err() == nil {
+		return false
 	}
+	s.logger.WithField("analysis_id", jobID).Info("Analysis cancelled")
 
-	eventData, err := json.Marshal(event)
-	if err != nil {
-		s.logger.Errorf("Failed to marshal event: %v", err)
+	if cause := context.Cause(ctx); errors.Is(cause, ErrRequestCancelled) {
+		if err := s.updateJobStatus(context.WithoutCancel(ctx), jobID, StatusCancelled, "Analysis cancelled: "+cause.Error()); err != nil {
+			s.logger.Errorf("Failed to update job status: %v", err)
+		}
+	}
+	return true
+}
+
+// cancelJob cancels a running analysis through its stored cancel function
+func (s *AnalysisService) cancelJob(jobID string, cause error) {
+	if cancel, ok := s.cancelFuncs.Load(jobID); ok {
+		if cancelFunc, ok := cancel.(context.CancelCauseFunc); ok {
+			cancelFunc(cause)
+		}
+	}
+}
+
+// timedOut reports whether the analysis was cancelled for running too long
+func (s *AnalysisService) timedOut(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrAnalysisTimeout)
+}
+
+// finishTimedOut saves the results of the files analyzed before the
+// analysis hit its maximum duration and marks it failed
+func (s *AnalysisService) finishTimedOut(ctx context.Context, job *AnalysisJob, project *repository.Project, files []*repository.ProjectFile, results []*FileAnalysisResult) {
+	// The analysis context is cancelled; finish up on a fresh one
+	finishCtx := utils.WithRequestID(context.Background(), utils.RequestIDFromContext(ctx))
+	errorMsg := fmt.Sprintf("Analysis timed out after %s", s.maxDuration)
+
+	s.logger.WithFields(logrus.Fields{
+		"analysis_id": job.ID,
+		"request_id":  utils.RequestIDFromContext(ctx),
+		"analyzed":    len(results),
+		"total_files": len(files),
+	}).Warn(errorMsg)
+
+	if len(results) > 0 {
+		analyzed := make(map[string]bool, len(results))
+		for _, result := range results {
+			analyzed[result.FilePath] = true
+		}
+		var analyzedFiles []*repository.ProjectFile
+		for _, file := range files {
+			if analyzed[file.Path] {
+				analyzedFiles = append(analyzedFiles, file)
+			}
+		}
+
+		clones := s.detectClones(analyzedFiles, results)
+		if err := s.processResults(finishCtx, job, results, clones); err != nil {
+			s.logger.WithError(err).WithField("analysis_id", job.ID).Error("Failed to save partial results")
+		}
+	}
+
+	s.failAnalysis(finishCtx, job, project, errorMsg)
+}
+
+// failAnalysis marks the job failed and announces it
+func (s *AnalysisService) failAnalysis(ctx context.Context, job *AnalysisJob, project *repository.Project, errorMsg string) {
+	if err := s.updateJobStatus(ctx, job.ID, StatusFailed, errorMsg); err != nil {
+		s.logger.Errorf("Failed to update job status: %v", err)
+	}
+	s.publishAnalysisEvent(job.ID, events.AnalysisFailed{
+		ProjectID: project.ID,
+		Error:     errorMsg,
+	})
+	s.notifyFinished(project, job, events.TypeAnalysisFailed, errorMsg)
+}
+
+// notifyFinished sends the analysis summary to the project's recipients
+// without waiting for delivery
+func (s *AnalysisService) notifyFinished(project *repository.Project, job *AnalysisJob, event, errorMsg string) {
+	if s.notifier == nil {
 		return
 	}
+	s.notifier.Dispatch(project, notify.Summary{
+		Event:      event,
+		AnalysisID: job.ID,
+		ProjectID:  project.ID,
+		TotalFiles: job.TotalFiles,
+		Error:      errorMsg,
+		FinishedAt: s.clock.Now(),
+	})
+}
+
+// progressEventSteps is how many progress events an analysis publishes at
+// most, one each time another such share of its files is analyzed
+const progressEventSteps = 10
 
-	msg := kafka.Message{
-		Key:   []byte(analysisID),
-		Value: eventData,
+// progressMilestone reports whether analyzing the done-th of total files
+// completes another step of progress. The last file completes none: the
+// completion event follows it.
+func progressMilestone(done, total int) bool {
+	if done <= 0 || done >= total {
+		return false
 	}
+	return done*progressEventSteps/total > (done-1)*progressEventSteps/total
+}
 
-	if err := s.kafkaWriter.WriteMessages(context.Background(), msg); err != nil {
-		s.logger.Errorf("Failed to publish event: %v", err)
+// publishAnalysisEvent queues an event for Kafka behind the analysis's
+// earlier events. Publishing is best effort: writes are retried in the
+// background and dropped when they keep failing, while failures to queue
+// are logged and returned for callers that care.
+func (s *AnalysisService) publishAnalysisEvent(analysisID string, payload events.Payload) error {
+	if s.publisher == nil {
+		s.logger.WithFields(logrus.Fields{
+			"analysis_id": analysisID,
+			"event_type":  payload.EventType(),
+		}).Debug("Event publishing disabled, dropping event")
+		return ErrEventsDisabled
+	}
+	if s.features != nil && !s.features.EventPublishing() {
+		s.logger.WithFields(logrus.Fields{
+			"analysis_id": analysisID,
+			"event_type":  payload.EventType(),
+		}).Debug("Event publishing switched off, dropping event")
+		return ErrEventsDisabled
 	}
+
+	err := s.publisher.publish(analysisID, func(sequence uint64) (kafka.Message, error) {
+		event := events.New(analysisID, payload)
+		event.Sequence = sequence
+		eventData, err := s.eventCodec.Marshal(event)
+		if err != nil {
+			return kafka.Message{}, err
+		}
+		return kafka.Message{
+			Key:   []byte(analysisID),
+			Value: eventData,
+			Headers: []kafka.Header{
+				{Key: events.ContentTypeHeader, Value: []byte(s.eventCodec.ContentType())},
+			},
+		}, nil
+	})
+	if errors.Is(err, ErrEventBufferFull) {
+		s.logger.WithFields(logrus.Fields{
+			"analysis_id": analysisID,
+			"event_type":  payload.EventType(),
+		}).WithError(err).Warn("Dropping event")
+		return err
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to marshal event: %v", err)
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return nil
 }
 
 // GetAnalysis retrieves analysis job details
@@ -396,25 +1263,57 @@ func (s *AnalysisService) GetAnalysis(ctx context.Context, analysisID string) (*
 	// Try cache first
 	key := fmt.Sprintf("analysis:job:%s", analysisID)
 	data, err := s.redisClient.Get(ctx, key).Bytes()
+	corrupt := false
 	if err == nil {
-		var job AnalysisJob
-		if err := json.Unmarshal(data, &job); err == nil {
-			return &job, nil
+		var cached AnalysisJob
+		parseErr := json.Unmarshal(data, &cached)
+		if parseErr == nil {
+			return &cached, nil
 		}
+		corrupt = true
+		s.logger.WithError(parseErr).WithFields(logrus.Fields{
+			"analysis_id": analysisID,
+			"request_id":  utils.RequestIDFromContext(ctx),
+		}).Warn("Corrupt cached analysis, repairing it from the database")
 	}
 
 	// Fallback to database
-	return s.analysisRepo.GetJob(ctx, analysisID)
+	job, err := s.analysisRepo.GetJob(ctx, analysisID)
+	if corrupt {
+		s.repairCachedJob(ctx, key, job, err)
+	}
+	return job, err
+}
+
+// repairCachedJob replaces a cache entry that didn't parse with the job
+// read from the database, or deletes it when there is none to cache, so
+// later reads don't fail to parse it again
+func (s *AnalysisService) repairCachedJob(ctx context.Context, key string, job *AnalysisJob, readErr error) {
+	var err error
+	if readErr == nil && job != nil {
+		err = s.cacheJobStatus(ctx, job)
+	} else {
+		err = s.redisClient.Del(ctx, key).Err()
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("key", key).Warn("Failed to repair corrupt cached analysis")
+	}
 }
 
-// CancelAnalysis cancels a running analysis
+// CancelAnalysis cancels a pending or running analysis
 func (s *AnalysisService) CancelAnalysis(ctx context.Context, analysisID string) error {
-	// Get cancel function
-	if cancel, ok := s.cancelFuncs.Load(analysisID); ok {
-		if cancelFunc, ok := cancel.(context.CancelFunc); ok {
-			cancelFunc()
-		}
+	job, err := s.analysisRepo.GetJob(ctx, analysisID)
+	if err != nil {
+		return fmt.Errorf("failed to get analysis: %w", err)
+	}
+	if job == nil {
+		return fmt.Errorf("%w: %s", ErrAnalysisNotFound, analysisID)
 	}
+	if job.Status.Finished() {
+		return fmt.Errorf("%w: %s is %s", ErrAnalysisFinished, analysisID, job.Status)
+	}
+
+	s.cancelJob(analysisID, context.Canceled)
 
 	// Update status
 	return s.updateJobStatus(ctx, analysisID, StatusCancelled, "Analysis cancelled by user")
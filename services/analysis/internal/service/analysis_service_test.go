@@ -1,20 +1,41 @@
 package service_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/notify"
 	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
 	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
 )
 
+// newTestRedis returns a client connected to an in-process Redis server
+func newTestRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
 // Mock repositories
 type MockProjectRepository struct {
 	mock.Mock
@@ -58,15 +79,135 @@ func (m *MockAnalysisRepository) UpdateJob(ctx context.Context, job *service.Ana
 	return args.Error(0)
 }
 
+func (m *MockAnalysisRepository) GetLatestJob(ctx context.Context, projectID string, status service.AnalysisStatus) (*service.AnalysisJob, error) {
+	args := m.Called(ctx, projectID, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.AnalysisJob), args.Error(1)
+}
+
 type MockMetricsRepository struct {
 	mock.Mock
 }
 
-func (m *MockMetricsRepository) SaveAnalysisResults(ctx context.Context, analysisID string, results []*service.FileAnalysisResult, aggregateMetrics map[string]interface{}) error {
+func (m *MockMetricsRepository) SaveAnalysisResults(ctx context.Context, analysisID string, results []*service.FileAnalysisResult, aggregateMetrics *service.AggregateMetrics) error {
 	args := m.Called(ctx, analysisID, results, aggregateMetrics)
 	return args.Error(0)
 }
 
+func (m *MockMetricsRepository) GetAnalysisResults(ctx context.Context, analysisID string) ([]*service.FileAnalysisResult, error) {
+	args := m.Called(ctx, analysisID)
+	results, _ := args.Get(0).([]*service.FileAnalysisResult)
+	return results, args.Error(1)
+}
+
+// memoryAnalysisRepository is an in-memory AnalysisRepository for tests that
+// let the background analysis run to completion
+type memoryAnalysisRepository struct {
+	mu   sync.Mutex
+	jobs map[string]service.AnalysisJob
+}
+
+func newMemoryAnalysisRepository() *memoryAnalysisRepository {
+	return &memoryAnalysisRepository{jobs: make(map[string]service.AnalysisJob)}
+}
+
+func (r *memoryAnalysisRepository) CreateJob(ctx context.Context, job *service.AnalysisJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[job.ID] = *job
+	return nil
+}
+
+func (r *memoryAnalysisRepository) GetJob(ctx context.Context, jobID string) (*service.AnalysisJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[jobID]
+	if !ok {
+		return nil, fmt.Errorf("job %s not found", jobID)
+	}
+	return &job, nil
+}
+
+func (r *memoryAnalysisRepository) UpdateJob(ctx context.Context, job *service.AnalysisJob) error {
+	return r.CreateJob(ctx, job)
+}
+
+func (r *memoryAnalysisRepository) GetLatestJob(ctx context.Context, projectID string, status service.AnalysisStatus) (*service.AnalysisJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *service.AnalysisJob
+	for _, job := range r.jobs {
+		if job.ProjectID != projectID || job.Status != status {
+			continue
+		}
+		if latest == nil || job.StartedAt.After(latest.StartedAt) {
+			job := job
+			latest = &job
+		}
+	}
+	return latest, nil
+}
+
+// saveCall is what one SaveAnalysisResults call was given
+type saveCall struct {
+	results   []*service.FileAnalysisResult
+	aggregate *service.AggregateMetrics
+}
+
+// analysisRun is an analysis service over a mock project repository with one
+// project, in-memory jobs and a metrics repository that hands on what the
+// analysis saves
+type analysisRun struct {
+	*service.AnalysisService
+	jobs        *memoryAnalysisRepository
+	metricsRepo *MockMetricsRepository
+	saved       chan saveCall
+}
+
+// newAnalysisRun returns an analysis service that analyzes project as files
+func newAnalysisRun(t *testing.T, project *repository.Project, files []*repository.ProjectFile) *analysisRun {
+	t.Helper()
+	mockProjectRepo := new(MockProjectRepository)
+	mockProjectRepo.On("GetByID", mock.Anything, project.ID).Return(project, nil)
+	mockProjectRepo.On("GetProjectFiles", mock.Anything, project.ID).Return(files, nil)
+
+	saved := make(chan saveCall, 1)
+	mockMetricsRepo := new(MockMetricsRepository)
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			saved <- saveCall{
+				results:   args.Get(2).([]*service.FileAnalysisResult),
+				aggregate: args.Get(3).(*service.AggregateMetrics),
+			}
+		}).Return(nil)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	jobs := newMemoryAnalysisRepository()
+	return &analysisRun{
+		AnalysisService: service.NewAnalysisService(mockProjectRepo, jobs, mockMetricsRepo, newTestRedis(t), nil, logger),
+		jobs:            jobs,
+		metricsRepo:     mockMetricsRepo,
+		saved:           saved,
+	}
+}
+
+// wait returns what the analysis saved, failing the test when it saves
+// nothing within 5 seconds
+func (r *analysisRun) wait(t *testing.T) saveCall {
+	t.Helper()
+	select {
+	case call := <-r.saved:
+		return call
+	case <-time.After(5 * time.Second):
+		t.Fatal("analysis results were not saved")
+		return saveCall{}
+	}
+}
+
 // Test AnalysisService
 func TestAnalysisService_StartAnalysis(t *testing.T) {
 	// Setup
@@ -74,10 +215,8 @@ func TestAnalysisService_StartAnalysis(t *testing.T) {
 	mockAnalysisRepo := new(MockAnalysisRepository)
 	mockMetricsRepo := new(MockMetricsRepository)
 	
-	// Create a test Redis client (you might want to use miniredis for testing)
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
+	// Create a test Redis client backed by miniredis
+	redisClient := newTestRedis(t)
 	
 	// Create a test Kafka writer
 	kafkaWriter := &kafka.Writer{
@@ -108,6 +247,13 @@ func TestAnalysisService_StartAnalysis(t *testing.T) {
 	mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(project, nil)
 	mockAnalysisRepo.On("CreateJob", mock.Anything, mock.AnythingOfType("*service.AnalysisJob")).Return(nil)
 
+	// The analysis itself runs in the background and may or may not get far
+	// enough to touch these before the test finishes
+	mockAnalysisRepo.On("GetJob", mock.Anything, mock.Anything).Return(&service.AnalysisJob{ProjectID: projectID}, nil).Maybe()
+	mockAnalysisRepo.On("UpdateJob", mock.Anything, mock.AnythingOfType("*service.AnalysisJob")).Return(nil).Maybe()
+	mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return([]*repository.ProjectFile{}, nil).Maybe()
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+
 	// Execute
 	ctx := context.Background()
 	job, err := analysisService.StartAnalysis(ctx, projectID)
@@ -130,9 +276,7 @@ func TestAnalysisService_StartAnalysis_ProjectNotFound(t *testing.T) {
 	mockAnalysisRepo := new(MockAnalysisRepository)
 	mockMetricsRepo := new(MockMetricsRepository)
 	
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
+	redisClient := newTestRedis(t)
 	
 	kafkaWriter := &kafka.Writer{
 		Addr:  kafka.TCP("localhost:9092"),
@@ -175,9 +319,7 @@ func TestAnalysisService_GetAnalysis(t *testing.T) {
 	mockAnalysisRepo := new(MockAnalysisRepository)
 	mockMetricsRepo := new(MockMetricsRepository)
 	
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
+	redisClient := newTestRedis(t)
 	
 	kafkaWriter := &kafka.Writer{
 		Addr:  kafka.TCP("localhost:9092"),
@@ -222,15 +364,55 @@ func TestAnalysisService_GetAnalysis(t *testing.T) {
 	mockAnalysisRepo.AssertExpectations(t)
 }
 
+func TestAnalysisService_GetAnalysis_RepairsCorruptCache(t *testing.T) {
+	mockAnalysisRepo := new(MockAnalysisRepository)
+	redisClient := newTestRedis(t)
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+	analysisService := service.NewAnalysisService(new(MockProjectRepository), mockAnalysisRepo, new(MockMetricsRepository), redisClient, nil, logger)
+
+	ctx := context.Background()
+	stored := &service.AnalysisJob{ID: "corrupt-1", ProjectID: "test-project", Status: service.StatusCompleted, Progress: 100}
+	mockAnalysisRepo.On("GetJob", mock.Anything, "corrupt-1").Return(stored, nil).Once()
+	mockAnalysisRepo.On("GetJob", mock.Anything, "corrupt-2").Return(nil, nil).Once()
+	require.NoError(t, redisClient.Set(ctx, "analysis:job:corrupt-1", `{"id": "corrupt-1", "status":`, time.Hour).Err())
+	require.NoError(t, redisClient.Set(ctx, "analysis:job:corrupt-2", "not json", time.Hour).Err())
+
+	t.Run("overwritten from the database", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			job, err := analysisService.GetAnalysis(ctx, "corrupt-1")
+			require.NoError(t, err)
+			assert.Equal(t, stored.ID, job.ID)
+			assert.Equal(t, service.StatusCompleted, job.Status)
+		}
+
+		data, err := redisClient.Get(ctx, "analysis:job:corrupt-1").Bytes()
+		require.NoError(t, err)
+		var cached service.AnalysisJob
+		require.NoError(t, json.Unmarshal(data, &cached))
+		assert.Equal(t, stored.ID, cached.ID)
+	})
+
+	t.Run("deleted when the analysis is gone", func(t *testing.T) {
+		job, err := analysisService.GetAnalysis(ctx, "corrupt-2")
+		require.NoError(t, err)
+		assert.Nil(t, job)
+		assert.ErrorIs(t, redisClient.Get(ctx, "analysis:job:corrupt-2").Err(), redis.Nil)
+	})
+
+	// Later reads are served from the repaired cache
+	mockAnalysisRepo.AssertExpectations(t)
+	assert.Equal(t, 2, strings.Count(logs.String(), "Corrupt cached analysis"), "logged once per corrupt entry")
+}
+
 func TestAnalysisService_CancelAnalysis(t *testing.T) {
 	// Setup
 	mockProjectRepo := new(MockProjectRepository)
 	mockAnalysisRepo := new(MockAnalysisRepository)
 	mockMetricsRepo := new(MockMetricsRepository)
 	
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
+	redisClient := newTestRedis(t)
 	
 	kafkaWriter := &kafka.Writer{
 		Addr:  kafka.TCP("localhost:9092"),
@@ -270,4 +452,818 @@ func TestAnalysisService_CancelAnalysis(t *testing.T) {
 
 	// Verify mocks
 	mockAnalysisRepo.AssertExpectations(t)
-}
\ No newline at end of file
+}
+
+func TestAnalysisService_PersistsVersions(t *testing.T) {
+	run := newAnalysisRun(t, &repository.Project{ID: "versioned-project", Name: "Versioned"}, []*repository.ProjectFile{
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+	})
+
+	job, err := run.StartAnalysis(context.Background(), "versioned-project")
+	assert.NoError(t, err)
+
+	created, err := run.jobs.GetJob(context.Background(), job.ID)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.AnalyzerVersion)
+	assert.NotEmpty(t, created.MetricsVersion)
+	assert.Equal(t, created.AnalyzerVersion, job.AnalyzerVersion)
+	assert.Equal(t, created.MetricsVersion, job.MetricsVersion)
+
+	call := run.wait(t)
+	if assert.Len(t, call.results, 1) {
+		assert.Equal(t, job.AnalyzerVersion, call.results[0].AnalyzerVersion)
+		assert.Equal(t, job.MetricsVersion, call.results[0].MetricsVersion)
+		for _, issue := range call.results[0].Issues {
+			assert.Equal(t, "main.go", issue.File)
+		}
+	}
+	assert.Equal(t, job.AnalyzerVersion, call.aggregate.AnalyzerVersion)
+	assert.Equal(t, job.MetricsVersion, call.aggregate.MetricsVersion)
+}
+
+func TestAnalysisService_ReportsCrossFileClones(t *testing.T) {
+	body := "func Total(items []int) int {\n\ttotal := 0\n\tfor _, item := range items {\n\t\tif item > 0 {\n\t\t\ttotal += item\n\t\t}\n\t}\n\treturn total\n}\n"
+	run := newAnalysisRun(t, &repository.Project{ID: "cloned-project"}, []*repository.ProjectFile{
+		{Path: "a/a.go", Content: []byte("package a\n\n" + body)},
+		{Path: "b/b.go", Content: []byte("package b\n\n" + body)},
+	})
+
+	_, err := run.StartAnalysis(context.Background(), "cloned-project")
+	assert.NoError(t, err)
+
+	call := run.wait(t)
+	assert.Equal(t, 1, call.aggregate.CloneGroups)
+	assert.Greater(t, call.aggregate.DuplicationRatio, 0.5)
+	for _, r := range call.results {
+		var clones int
+		for _, issue := range r.Issues {
+			if issue.Type == "duplication" {
+				clones++
+				assert.Len(t, issue.Locations, 2)
+			}
+		}
+		assert.Equal(t, 1, clones, r.FilePath)
+	}
+}
+
+func TestAnalysisService_ReportsSecrets(t *testing.T) {
+	run := newAnalysisRun(t, &repository.Project{ID: "secrets-project"}, []*repository.ProjectFile{
+		{Path: "config/aws.go", Content: []byte("package config\n\nconst accessKey = \"AKIA" + "Q3EGRT7KZW2XNPLM\"\n")},
+		{Path: "client/salt.go", Content: []byte("package client\n\nvar salt = \"Zx8Kq2Lm9Vb4Nw7Rt1Ys" + "6Hd3Gf5Jc0Pa\"\n")},
+		{Path: ".env", Content: []byte("GITHUB_TOKEN=ghp_" + "a1B2c3D4e5F6g7H8i9J0k1L2m3N4o5P6q7R8\n")},
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+	})
+
+	_, err := run.StartAnalysis(context.Background(), "secrets-project")
+	require.NoError(t, err)
+
+	call := run.wait(t)
+	assert.Equal(t, 2, call.aggregate.Vulnerabilities, "the .env file has no analyzer but is still scanned")
+	assert.Equal(t, 1, call.aggregate.SecurityHotspots)
+
+	rules := make(map[string][]string)
+	for _, r := range call.results {
+		for _, issue := range r.Issues {
+			assert.Equal(t, r.FilePath, issue.File)
+			if issue.Type == metrics.IssueTypeVulnerability || issue.Type == metrics.IssueTypeSecurityHotspot {
+				rules[r.FilePath] = append(rules[r.FilePath], issue.Rule)
+			}
+		}
+	}
+	assert.Equal(t, []string{metrics.RuleAWSAccessKey}, rules["config/aws.go"])
+	assert.Equal(t, []string{metrics.RuleHighEntropyString}, rules["client/salt.go"])
+	assert.Equal(t, []string{metrics.RuleGitHubToken}, rules[".env"])
+	assert.Empty(t, rules["main.go"])
+}
+
+func TestAnalysisService_EmptyFiles(t *testing.T) {
+	run := newAnalysisRun(t, &repository.Project{ID: "empty-files-project"}, []*repository.ProjectFile{
+		{Path: "empty.go", Content: []byte{}},
+		{Path: "doc.go", Content: []byte("// Package doc has no code\n\n/* nor\n   here */\n  \n")},
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+	})
+
+	_, err := run.StartAnalysis(context.Background(), "empty-files-project")
+	require.NoError(t, err)
+
+	call := run.wait(t)
+	require.Len(t, call.results, 3)
+	for _, r := range call.results {
+		assert.Empty(t, r.Error, r.FilePath)
+		assert.Equal(t, r.FilePath != "main.go", r.Metrics["empty"] == true, r.FilePath)
+	}
+	assert.Equal(t, 2, call.aggregate.EmptyCount)
+	assert.Equal(t, 3, call.aggregate.TotalFiles)
+	assert.Equal(t, 1.0, call.aggregate.AverageComplexity, "empty files don't dilute the average")
+}
+
+func TestAnalysisService_LanguageSelection(t *testing.T) {
+	files := []*repository.ProjectFile{
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+		{Path: "web/generated.ts", Content: []byte("export const x = 1;\n")},
+	}
+
+	tests := []struct {
+		name        string
+		projectLang []string
+		opts        service.AnalysisOptions
+		skipped     map[string]bool
+	}{
+		{name: "all languages by default", skipped: map[string]bool{}},
+		{name: "run options", opts: service.AnalysisOptions{Languages: []string{"Go"}}, skipped: map[string]bool{"web/generated.ts": true}},
+		{name: "project settings", projectLang: []string{"typescript"}, skipped: map[string]bool{"main.go": true}},
+		{name: "options override project", projectLang: []string{"typescript"}, opts: service.AnalysisOptions{Languages: []string{"go"}}, skipped: map[string]bool{"web/generated.ts": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := newAnalysisRun(t, &repository.Project{ID: "polyglot", EnabledLanguages: tt.projectLang}, files)
+
+			_, err := run.StartAnalysisWithOptions(context.Background(), "polyglot", tt.opts)
+			assert.NoError(t, err)
+
+			call := run.wait(t)
+			assert.Len(t, call.results, len(files))
+			for _, r := range call.results {
+				if tt.skipped[r.FilePath] {
+					assert.Equal(t, service.SkipReasonLanguageDisabled, r.Skipped, r.FilePath)
+				} else {
+					assert.Empty(t, r.Skipped, r.FilePath)
+				}
+			}
+			assert.Equal(t, len(tt.skipped), call.aggregate.SkippedCount)
+		})
+	}
+}
+
+func TestAnalysisService_StartAnalysis_UnknownLanguage(t *testing.T) {
+	mockProjectRepo := new(MockProjectRepository)
+	mockProjectRepo.On("GetByID", mock.Anything, "p").Return(&repository.Project{ID: "p"}, nil)
+
+	analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), nil, newTestRedis(t), nil, logrus.New())
+
+	_, err := analysisService.StartAnalysisWithOptions(context.Background(), "p", service.AnalysisOptions{Languages: []string{"cobol"}})
+	assert.ErrorIs(t, err, service.ErrUnknownLanguage)
+}
+
+func TestAnalysisService_WithoutEventWriter(t *testing.T) {
+	mockProjectRepo := new(MockProjectRepository)
+	analysisRepo := newMemoryAnalysisRepository()
+	mockMetricsRepo := new(MockMetricsRepository)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	// No Kafka writer: events are disabled
+	analysisService := service.NewAnalysisService(mockProjectRepo, analysisRepo, mockMetricsRepo, newTestRedis(t), nil, logger)
+
+	projectID := "quiet-project"
+	mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+	mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return([]*repository.ProjectFile{
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+	}, nil)
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	job, err := analysisService.StartAnalysis(context.Background(), projectID)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		stored, err := analysisRepo.GetJob(context.Background(), job.ID)
+		return err == nil && stored.Status == service.StatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// fakeNotifier records the summaries it is asked to deliver
+type fakeNotifier struct {
+	summaries chan notify.Summary
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, project *repository.Project, summary notify.Summary) error {
+	n.summaries <- summary
+	return nil
+}
+
+// registerTestAnalyzer registers a for lang until the test ends, then
+// restores the analyzer registered before it
+func registerTestAnalyzer(t *testing.T, lang analyzer.Language, a analyzer.Analyzer) {
+	t.Helper()
+	previous, err := analyzer.GetAnalyzer(lang)
+	analyzer.RegisterAnalyzer(lang, a)
+	t.Cleanup(func() {
+		if err != nil {
+			analyzer.UnregisterAnalyzer(lang)
+			return
+		}
+		analyzer.RegisterAnalyzer(lang, previous)
+	})
+}
+
+// blockingAnalyzer holds analysis until the run is cancelled
+type blockingAnalyzer struct {
+	started chan struct{}
+}
+
+func (a blockingAnalyzer) Analyze(ctx context.Context, content []byte) (*analyzer.AnalysisResult, error) {
+	close(a.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingAnalyzer) Language() analyzer.Language {
+	return analyzer.LanguageCSharp
+}
+
+func TestAnalysisService_NotifiesOnCompletion(t *testing.T) {
+	mockProjectRepo := new(MockProjectRepository)
+	analysisRepo := newMemoryAnalysisRepository()
+	mockMetricsRepo := new(MockMetricsRepository)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	analysisService := service.NewAnalysisService(mockProjectRepo, analysisRepo, mockMetricsRepo, newTestRedis(t), nil, logger)
+	notifier := &fakeNotifier{summaries: make(chan notify.Summary, 1)}
+	analysisService.SetNotificationDispatcher(notify.NewDispatcher("https://sa3d.example.com", logger, notifier))
+
+	project := &repository.Project{ID: "notified-project", Name: "Notified"}
+	mockProjectRepo.On("GetByID", mock.Anything, project.ID).Return(project, nil)
+	mockProjectRepo.On("GetProjectFiles", mock.Anything, project.ID).Return([]*repository.ProjectFile{
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+	}, nil)
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	job, err := analysisService.StartAnalysis(context.Background(), project.ID)
+	assert.NoError(t, err)
+
+	select {
+	case summary := <-notifier.summaries:
+		assert.Equal(t, "analysis.completed", summary.Event)
+		assert.Equal(t, job.ID, summary.AnalysisID)
+		assert.Equal(t, "Notified", summary.ProjectName)
+		assert.Equal(t, 1, summary.TotalFiles)
+		assert.Equal(t, "https://sa3d.example.com/projects/notified-project/analyses/"+job.ID, summary.Link)
+	case <-time.After(5 * time.Second):
+		t.Fatal("notifier was not called")
+	}
+}
+
+func TestAnalysisService_InvalidatesGatewayCache(t *testing.T) {
+	mockProjectRepo := new(MockProjectRepository)
+	mockMetricsRepo := new(MockMetricsRepository)
+	redisClient := newTestRedis(t)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), mockMetricsRepo, redisClient, nil, logger)
+
+	projectID := "cached-project"
+	proceed := make(chan struct{})
+	mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+	mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).
+		Run(func(mock.Arguments) { <-proceed }).
+		Return([]*repository.ProjectFile{{Path: "main.go", Content: []byte("package main\n")}}, nil)
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	job, err := analysisService.StartAnalysis(context.Background(), projectID)
+	require.NoError(t, err)
+
+	// Responses the gateway cached while the analysis was running
+	ctx := context.Background()
+	tagKey := utils.ResponseCacheTagKey("analysisId", job.ID)
+	require.NoError(t, redisClient.Set(ctx, "gateway:response:status", "running", time.Minute).Err())
+	require.NoError(t, redisClient.SAdd(ctx, tagKey, "gateway:response:status").Err())
+	projectTagKey := utils.ResponseCacheTagKey("projectId", projectID)
+	require.NoError(t, redisClient.Set(ctx, "gateway:response:metrics", "stale", time.Minute).Err())
+	require.NoError(t, redisClient.SAdd(ctx, projectTagKey, "gateway:response:metrics").Err())
+	close(proceed)
+
+	assert.Eventually(t, func() bool {
+		return redisClient.Exists(ctx, tagKey, "gateway:response:status", projectTagKey, "gateway:response:metrics").Val() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestAnalysisService_RecordsResourceUsage(t *testing.T) {
+	mockProjectRepo := new(MockProjectRepository)
+	mockMetricsRepo := new(MockMetricsRepository)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), mockMetricsRepo, newTestRedis(t), nil, logger)
+
+	projectID := "measured-project"
+	var files []*repository.ProjectFile
+	for i := 0; i < 20; i++ {
+		files = append(files, &repository.ProjectFile{
+			Path:    fmt.Sprintf("pkg/file%d.go", i),
+			Content: []byte(fmt.Sprintf("package pkg\n\nfunc F%d(x int) int {\n\tif x > 0 {\n\t\treturn x\n\t}\n\treturn -x\n}\n", i)),
+		})
+	}
+	aggregates := make(chan *service.AggregateMetrics, 1)
+	mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+	mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return(files, nil)
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			aggregates <- args.Get(3).(*service.AggregateMetrics)
+		}).Return(nil)
+
+	job, err := analysisService.StartAnalysis(context.Background(), projectID)
+	require.NoError(t, err)
+
+	select {
+	case aggregate := <-aggregates:
+		assert.NotNil(t, aggregate.Resources)
+	case <-time.After(5 * time.Second):
+		t.Fatal("analysis results were not saved")
+	}
+
+	var completed *service.AnalysisJob
+	require.Eventually(t, func() bool {
+		completed, err = analysisService.GetAnalysis(context.Background(), job.ID)
+		return err == nil && completed.Status == service.StatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	usage := completed.Resources
+	require.NotNil(t, usage)
+	assert.GreaterOrEqual(t, usage.DurationMs, int64(0))
+	assert.GreaterOrEqual(t, usage.CPUTimeMs, int64(0))
+	assert.Greater(t, usage.FilesPerSecond, 0.0)
+	assert.Greater(t, usage.PeakGoroutines, 0)
+	assert.Greater(t, usage.PeakHeapBytes, uint64(0))
+	assert.Greater(t, usage.AllocatedBytes, uint64(0))
+	assert.Zero(t, usage.ConcurrentAnalyses)
+}
+
+func TestAnalysisService_DoesNotNotifyOnCancellation(t *testing.T) {
+	started := make(chan struct{})
+	registerTestAnalyzer(t, analyzer.LanguageCSharp, blockingAnalyzer{started: started})
+
+	mockProjectRepo := new(MockProjectRepository)
+	analysisRepo := newMemoryAnalysisRepository()
+	mockMetricsRepo := new(MockMetricsRepository)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	analysisService := service.NewAnalysisService(mockProjectRepo, analysisRepo, mockMetricsRepo, newTestRedis(t), nil, logger)
+	notifier := &fakeNotifier{summaries: make(chan notify.Summary, 1)}
+	analysisService.SetNotificationDispatcher(notify.NewDispatcher("https://sa3d.example.com", logger, notifier))
+
+	projectID := "cancelled-project"
+	mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+	mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return([]*repository.ProjectFile{
+		{Path: "Program.cs", Content: []byte("class Program {}\n")},
+	}, nil)
+
+	job, err := analysisService.StartAnalysis(context.Background(), projectID)
+	assert.NoError(t, err)
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("analysis did not start")
+	}
+	assert.NoError(t, analysisService.CancelAnalysis(context.Background(), job.ID))
+
+	assert.Never(t, func() bool { return len(notifier.summaries) > 0 }, 300*time.Millisecond, 10*time.Millisecond)
+
+	stored, err := analysisRepo.GetJob(context.Background(), job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, service.StatusCancelled, stored.Status)
+	mockMetricsRepo.AssertNotCalled(t, "SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAnalysisService_MaxDuration(t *testing.T) {
+	started := make(chan struct{})
+	registerTestAnalyzer(t, analyzer.LanguageCSharp, blockingAnalyzer{started: started})
+
+	projectID := "slow-project"
+	run := newAnalysisRun(t, &repository.Project{ID: projectID}, []*repository.ProjectFile{
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+		{Path: "Program.cs", Content: []byte("class Program {}\n")},
+	})
+	run.SetMaxDuration(200 * time.Millisecond)
+
+	plan, err := run.PlanAnalysis(context.Background(), projectID)
+	require.NoError(t, err)
+	assert.Equal(t, "200ms", plan.MaxDuration)
+
+	job, err := run.StartAnalysis(context.Background(), projectID)
+	require.NoError(t, err)
+
+	var paths []string
+	for _, r := range run.wait(t).results {
+		paths = append(paths, r.FilePath)
+	}
+	assert.Contains(t, paths, "main.go", "partial results are saved")
+
+	assert.Eventually(t, func() bool {
+		stored, err := run.jobs.GetJob(context.Background(), job.ID)
+		return err == nil && stored.Status == service.StatusFailed
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stored, err := run.jobs.GetJob(context.Background(), job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Analysis timed out after 200ms", stored.Error)
+	assert.NotNil(t, stored.CompletedAt)
+}
+
+// panickingAnalyzer simulates a buggy analyzer that blows up on some input
+type panickingAnalyzer struct{}
+
+func (panickingAnalyzer) Analyze(ctx context.Context, content []byte) (*analyzer.AnalysisResult, error) {
+	if bytes.Contains(content, []byte("boom")) {
+		panic("unexpected input")
+	}
+	return &analyzer.AnalysisResult{Language: analyzer.LanguagePython}, nil
+}
+
+func (panickingAnalyzer) Language() analyzer.Language {
+	return analyzer.LanguagePython
+}
+
+func TestAnalysisService_RecoversFilePanic(t *testing.T) {
+	registerTestAnalyzer(t, analyzer.LanguagePython, panickingAnalyzer{})
+
+	files := []*repository.ProjectFile{
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+		{Path: "ok.py", Content: []byte("print('ok')\n")},
+		{Path: "bad.py", Content: []byte("boom\n")},
+	}
+	run := newAnalysisRun(t, &repository.Project{ID: "panicky-project"}, files)
+
+	job, err := run.StartAnalysis(context.Background(), "panicky-project")
+	assert.NoError(t, err)
+
+	results := run.wait(t).results
+	assert.Len(t, results, len(files))
+	for _, r := range results {
+		if r.FilePath == "bad.py" {
+			assert.Contains(t, r.Error, "Analyzer panic")
+		} else {
+			assert.Empty(t, r.Error, r.FilePath)
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		stored, err := run.jobs.GetJob(context.Background(), job.ID)
+		return err == nil && stored.Status == service.StatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// sleepingAnalyzer takes longer than any file timeout in the tests on files
+// marked pathological and ignores its context, like an analyzer stuck on one.
+// It gives up when done is closed, so it doesn't outlive its test.
+type sleepingAnalyzer struct {
+	delay time.Duration
+	done  <-chan struct{}
+}
+
+func (a sleepingAnalyzer) Analyze(ctx context.Context, content []byte) (*analyzer.AnalysisResult, error) {
+	if bytes.HasPrefix(content, []byte("// pathological")) {
+		select {
+		case <-time.After(a.delay):
+		case <-a.done:
+		}
+	}
+	return &analyzer.AnalysisResult{Language: analyzer.LanguageJavaScript}, nil
+}
+
+func (sleepingAnalyzer) Language() analyzer.Language {
+	return analyzer.LanguageJavaScript
+}
+
+func TestAnalysisService_FileTimeout(t *testing.T) {
+	const delay = 2 * time.Second
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	registerTestAnalyzer(t, analyzer.LanguageJavaScript, sleepingAnalyzer{delay: delay, done: done})
+
+	files := []*repository.ProjectFile{
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+		{Path: "slow.js", Content: []byte("// pathological\nwhile (true) {}\n")},
+	}
+	run := newAnalysisRun(t, &repository.Project{ID: "slow-project"}, files)
+	run.SetFileTimeout(50 * time.Millisecond)
+
+	begin := time.Now()
+	job, err := run.StartAnalysis(context.Background(), "slow-project")
+	require.NoError(t, err)
+
+	results := run.wait(t).results
+	assert.Less(t, time.Since(begin), delay, "the run waited for the stuck analyzer")
+	require.Len(t, results, len(files))
+	for _, r := range results {
+		if r.FilePath == "slow.js" {
+			assert.Contains(t, r.Error, "timed out after 50ms")
+			assert.Equal(t, true, r.Metrics["timed_out"])
+			assert.NotEmpty(t, r.ContentHash)
+		} else {
+			assert.Empty(t, r.Error, r.FilePath)
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		stored, err := run.jobs.GetJob(context.Background(), job.ID)
+		return err == nil && stored.Status == service.StatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestAnalysisService_FileTimeoutHoldsSlot(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	registerTestAnalyzer(t, analyzer.LanguageJavaScript, sleepingAnalyzer{delay: delay, done: make(chan struct{})})
+
+	files := []*repository.ProjectFile{
+		{Path: "first.js", Content: []byte("// pathological\nwhile (true) {}\n")},
+		{Path: "second.js", Content: []byte("// pathological\nwhile (true) {}\n")},
+	}
+	run := newAnalysisRun(t, &repository.Project{ID: "stuck-project"}, files)
+	run.SetFileTimeout(50 * time.Millisecond)
+	run.SetMaxConcurrentFiles(1)
+
+	begin := time.Now()
+	_, err := run.StartAnalysis(context.Background(), "stuck-project")
+	require.NoError(t, err)
+
+	results := run.wait(t).results
+	assert.GreaterOrEqual(t, time.Since(begin), delay, "the second file started while the abandoned analyzer ran")
+	require.Len(t, results, len(files))
+	for _, r := range results {
+		assert.Contains(t, r.Error, "timed out after 50ms", r.FilePath)
+	}
+}
+
+func TestVersionMismatchWarning(t *testing.T) {
+	current := &service.AnalysisJob{AnalyzerVersion: "1.0.0", MetricsVersion: "1.0.0"}
+
+	assert.Empty(t, service.VersionMismatchWarning(current, &service.AnalysisJob{AnalyzerVersion: "1.0.0", MetricsVersion: "1.0.0"}))
+	assert.Contains(t, service.VersionMismatchWarning(current, &service.AnalysisJob{AnalyzerVersion: "2.0.0", MetricsVersion: "1.0.0"}), "analyzer 1.0.0 vs 2.0.0")
+	assert.Contains(t, service.VersionMismatchWarning(&service.AnalysisJob{}, current), "unknown")
+}
+
+func TestAnalysisService_RunAnalysisSync(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	t.Run("streams results and returns the finished job", func(t *testing.T) {
+		mockProjectRepo := new(MockProjectRepository)
+		mockMetricsRepo := new(MockMetricsRepository)
+		analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), mockMetricsRepo, newTestRedis(t), nil, logger)
+
+		projectID := "sync-project"
+		mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+		mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return([]*repository.ProjectFile{
+			{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+			{Path: "util.go", Content: []byte("package main\n\nfunc helper() int { return 1 }\n")},
+		}, nil)
+		mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		var started *service.AnalysisJob
+		var streamed []string
+		job, err := analysisService.RunAnalysisSync(context.Background(), projectID, service.AnalysisOptions{}, service.RunObserver{
+			Started:      func(job *service.AnalysisJob) { started = job },
+			FileAnalyzed: func(result *service.FileAnalysisResult) { streamed = append(streamed, result.FilePath) },
+		})
+		require.NoError(t, err)
+		require.NotNil(t, started)
+		assert.Equal(t, started.ID, job.ID)
+		assert.Equal(t, service.StatusCompleted, job.Status)
+		assert.ElementsMatch(t, []string{"main.go", "util.go"}, streamed)
+	})
+
+	t.Run("unknown project", func(t *testing.T) {
+		mockProjectRepo := new(MockProjectRepository)
+		analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), new(MockMetricsRepository), newTestRedis(t), nil, logger)
+		mockProjectRepo.On("GetByID", mock.Anything, "missing").Return(nil, nil)
+
+		_, err := analysisService.RunAnalysisSync(context.Background(), "missing", service.AnalysisOptions{}, service.RunObserver{})
+		assert.ErrorIs(t, err, service.ErrProjectNotFound)
+	})
+
+	t.Run("client disconnect cancels the run", func(t *testing.T) {
+		started := make(chan struct{})
+		registerTestAnalyzer(t, analyzer.LanguageCSharp, blockingAnalyzer{started: started})
+
+		mockProjectRepo := new(MockProjectRepository)
+		analysisRepo := newMemoryAnalysisRepository()
+		mockMetricsRepo := new(MockMetricsRepository)
+		analysisService := service.NewAnalysisService(mockProjectRepo, analysisRepo, mockMetricsRepo, newTestRedis(t), nil, logger)
+
+		projectID := "disconnecting-project"
+		mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+		mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return([]*repository.ProjectFile{
+			{Path: "main.go", Content: []byte("package main\n")},
+			{Path: "Program.cs", Content: []byte("class Program {}\n")},
+		}, nil)
+
+		// The request context stands in for the client's connection
+		requestCtx, disconnect := context.WithCancel(context.Background())
+		jobs := make(chan *service.AnalysisJob, 1)
+		streamed := make(chan string, 2)
+		type outcome struct {
+			job *service.AnalysisJob
+			err error
+		}
+		done := make(chan outcome, 1)
+		go func() {
+			job, err := analysisService.RunAnalysisSync(requestCtx, projectID, service.AnalysisOptions{}, service.RunObserver{
+				Started:      func(job *service.AnalysisJob) { jobs <- job },
+				FileAnalyzed: func(result *service.FileAnalysisResult) { streamed <- result.FilePath },
+			})
+			done <- outcome{job, err}
+		}()
+
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("analysis did not start")
+		}
+		assert.Equal(t, "main.go", <-streamed, "files finished before the disconnect are streamed")
+		disconnect()
+
+		var result outcome
+		select {
+		case result = <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("run did not return after the client disconnected")
+		}
+		assert.Nil(t, result.job)
+		assert.ErrorIs(t, result.err, service.ErrRequestCancelled)
+		assert.ErrorIs(t, result.err, context.Canceled)
+
+		job := <-jobs
+		assert.Eventually(t, func() bool {
+			stored, err := analysisRepo.GetJob(context.Background(), job.ID)
+			return err == nil && stored.Status == service.StatusCancelled
+		}, 5*time.Second, 10*time.Millisecond)
+		stored, err := analysisRepo.GetJob(context.Background(), job.ID)
+		require.NoError(t, err)
+		assert.Contains(t, stored.Error, "request cancelled")
+		mockMetricsRepo.AssertNotCalled(t, "SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestAnalysisService_CoverageReport(t *testing.T) {
+	files := []*repository.ProjectFile{
+		{Path: "calc/calc.go", Content: []byte("package calc\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n\nfunc Sub(a, b int) int {\n\treturn a - b\n}\n")},
+		{Path: "calc/extra.go", Content: []byte("package calc\n\n// Zero returns zero\nfunc Zero() int {\n\treturn 0\n}\n")},
+		{Path: "calc/calc_test.go", Content: []byte("package calc\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Fatal(\"Add\")\n\t}\n}\n")},
+		{Path: "web/app.js", Content: []byte("function add(a, b) {\n  return a + b;\n}\n")},
+	}
+	profile := "mode: set\n" +
+		"github.com/acme/app/calc/calc.go:3.24,5.2 1 1\n" +
+		"github.com/acme/app/calc/calc.go:7.24,9.2 1 0\n"
+
+	tests := []struct {
+		name      string
+		opts      service.AnalysisOptions
+		measured  map[string]float64
+		coverage  float64
+		estimated bool
+	}{
+		{
+			name:     "measured from report",
+			opts:     service.AnalysisOptions{Coverage: &service.CoverageInput{Format: "go", Report: profile}},
+			measured: map[string]float64{"calc/calc.go": 50},
+			// calc/extra.go isn't in the report, so its 4 code lines count as
+			// uncovered; the test file and the JavaScript the report doesn't
+			// cover aren't counted
+			coverage: metrics.CoveragePercent(3, 6+4),
+		},
+		{name: "estimated without report", measured: map[string]float64{}, estimated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registerTestAnalyzer(t, analyzer.LanguageJavaScript, sleepingAnalyzer{})
+			run := newAnalysisRun(t, &repository.Project{ID: "covered"}, files)
+
+			_, err := run.StartAnalysisWithOptions(context.Background(), "covered", tt.opts)
+			assert.NoError(t, err)
+
+			call := run.wait(t)
+			for _, r := range call.results {
+				percent, ok := tt.measured[r.FilePath]
+				assert.Equal(t, !ok, r.Metrics["coverage_estimated"], r.FilePath)
+				if ok {
+					assert.Equal(t, percent, r.Metrics["test_coverage"], r.FilePath)
+					assert.Equal(t, 6, r.Metrics["coverable_lines"], r.FilePath)
+				}
+			}
+			assert.Equal(t, tt.estimated, call.aggregate.CoverageEstimated)
+			if !tt.estimated {
+				assert.Equal(t, tt.coverage, call.aggregate.Coverage)
+			}
+		})
+	}
+}
+
+func TestAnalysisService_StartAnalysis_InvalidCoverage(t *testing.T) {
+	mockProjectRepo := new(MockProjectRepository)
+	mockProjectRepo.On("GetByID", mock.Anything, "p").Return(&repository.Project{ID: "p"}, nil)
+
+	analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), nil, newTestRedis(t), nil, logrus.New())
+
+	for _, input := range []*service.CoverageInput{
+		{Format: "jacoco", Report: "<report/>"},
+		{Format: "go", Report: "calc.go:1.1,2.2 1 1\n"},
+	} {
+		_, err := analysisService.StartAnalysisWithOptions(context.Background(), "p", service.AnalysisOptions{Coverage: input})
+		assert.ErrorIs(t, err, service.ErrInvalidCoverage, input.Format)
+	}
+}
+
+func TestAnalysisService_ReportsVulnerableDependencies(t *testing.T) {
+	run := newAnalysisRun(t, &repository.Project{ID: "dependencies-project"}, []*repository.ProjectFile{
+		{Path: "web/package.json", Content: []byte(`{"dependencies": {"lodash": "4.17.4"}}`)},
+		{Path: "api/package.json", Content: []byte(`{"dependencies": {"lodash": "4.17.21"}}`)},
+	})
+	var advisory metrics.Advisory
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"id": "GHSA-jfh8-c2jp-5v3q",
+		"affected": [{"package": {"ecosystem": "npm", "name": "lodash"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "4.17.21"}]}]}],
+		"database_specific": {"severity": "CRITICAL"}
+	}`), &advisory))
+	run.SetAdvisories(metrics.NewAdvisoryDatabase([]metrics.Advisory{advisory}))
+
+	_, err := run.StartAnalysis(context.Background(), "dependencies-project")
+	require.NoError(t, err)
+
+	call := run.wait(t)
+	assert.Equal(t, 1, call.aggregate.Vulnerabilities)
+	for _, r := range call.results {
+		var advisories []string
+		for _, issue := range r.Issues {
+			if issue.Type == metrics.IssueTypeVulnerability {
+				assert.Equal(t, r.FilePath, issue.File)
+				assert.Equal(t, metrics.SeverityCritical, issue.Severity)
+				advisories = append(advisories, issue.Rule)
+			}
+		}
+		if r.FilePath == "web/package.json" {
+			assert.Equal(t, []string{"GHSA-jfh8-c2jp-5v3q"}, advisories)
+		} else {
+			assert.Empty(t, advisories, r.FilePath)
+		}
+	}
+}
+
+func TestAnalysisService_RetriesTransientSaveFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		firstErr   error
+		wantStatus service.AnalysisStatus
+		wantSaves  int
+	}{
+		{"transient failure is retried", fmt.Errorf("%w: connection reset", service.ErrStorageUnavailable), service.StatusCompleted, 2},
+		{"other failures are not", errors.New("value too long for column"), service.StatusFailed, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProjectRepo := new(MockProjectRepository)
+			mockMetricsRepo := new(MockMetricsRepository)
+			analysisRepo := newMemoryAnalysisRepository()
+			logger := logrus.New()
+			logger.SetLevel(logrus.FatalLevel)
+			analysisService := service.NewAnalysisService(mockProjectRepo, analysisRepo, mockMetricsRepo, newTestRedis(t), nil, logger)
+
+			mockProjectRepo.On("GetByID", mock.Anything, "project-1").Return(&repository.Project{ID: "project-1"}, nil)
+			mockProjectRepo.On("GetProjectFiles", mock.Anything, "project-1").Return([]*repository.ProjectFile{
+				{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+			}, nil)
+			mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+				Return(tt.firstErr).Once()
+			mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+				Return(nil)
+
+			job, err := analysisService.StartAnalysis(context.Background(), "project-1")
+			require.NoError(t, err)
+			assert.Eventually(t, func() bool {
+				stored, err := analysisRepo.GetJob(context.Background(), job.ID)
+				return err == nil && stored.Status == tt.wantStatus
+			}, 5*time.Second, 10*time.Millisecond)
+
+			mockMetricsRepo.AssertNumberOfCalls(t, "SaveAnalysisResults", tt.wantSaves)
+		})
+	}
+}
+
+func TestAnalysisService_Clock(t *testing.T) {
+	run := newAnalysisRun(t, &repository.Project{ID: "clocked-project"}, []*repository.ProjectFile{
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+	})
+	startedAt := time.Date(2020, 5, 4, 3, 2, 1, 0, time.UTC)
+	run.SetClock(utils.NewMockClock(startedAt))
+
+	job, err := run.StartAnalysis(context.Background(), "clocked-project")
+	require.NoError(t, err)
+	assert.Equal(t, startedAt, job.StartedAt)
+
+	assert.Equal(t, startedAt, run.wait(t).aggregate.AnalysisTimestamp)
+	require.Eventually(t, func() bool {
+		stored, err := run.jobs.GetJob(context.Background(), job.ID)
+		return err == nil && stored.CompletedAt != nil && stored.CompletedAt.Equal(startedAt)
+	}, 5*time.Second, 10*time.Millisecond, "the job is completed at the clock's time")
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+)
+
+var (
+	// ErrAnalysisNotFound is returned when an analysis has no stored results
+	ErrAnalysisNotFound = errors.New("analysis not found")
+	// ErrFunctionNotFound is returned when a call graph root isn't a known function
+	ErrFunctionNotFound = errors.New("function not found")
+)
+
+// FunctionSummary records a function's location and the calls it makes, as
+// stored with a file's results for building call graphs
+type FunctionSummary struct {
+	Name       string   `json:"name"`
+	Receiver   string   `json:"receiver,omitempty"`  // type name for methods
+	Signature  string   `json:"signature,omitempty"` // parameter and result types, as in "(int, string) error"
+	StartLine  int      `json:"start_line"`
+	EndLine    int      `json:"end_line"`
+	Complexity int      `json:"complexity"`
+	Calls      []string `json:"calls,omitempty"`
+}
+
+// CallGraphOptions narrows a call graph. Package and File keep only
+// functions declared there; Root limits the graph to functions reachable
+// from that node, at most MaxDepth calls away (0 means no limit).
+type CallGraphOptions struct {
+	Package  string
+	File     string
+	Root     string
+	MaxDepth int
+}
+
+// CallGraphNode is a function in the call graph. IDs have the form
+// package.Name for functions and package.Type.Name for methods, where the
+// package is the file's directory.
+type CallGraphNode struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Receiver   string `json:"receiver,omitempty"`
+	Package    string `json:"package"`
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Complexity int    `json:"complexity"`
+}
+
+// CallGraphEdge is a call from one function to another
+type CallGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// CallGraph is the graph of calls between a project's functions. Calls to
+// functions outside the project aren't included.
+type CallGraph struct {
+	AnalysisID string          `json:"analysis_id"`
+	Nodes      []CallGraphNode `json:"nodes"`
+	Edges      []CallGraphEdge `json:"edges"`
+}
+
+// GetCallGraph builds the call graph of a finished analysis
+func (s *AnalysisService) GetCallGraph(ctx context.Context, analysisID string, opts CallGraphOptions) (*CallGraph, error) {
+	results, err := s.metricsRepo.GetAnalysisResults(ctx, analysisID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analysis results: %w", err)
+	}
+	if len(results) == 0 {
+		return nil, ErrAnalysisNotFound
+	}
+
+	nodes, edges := buildCallGraph(results)
+
+	keep := func(node CallGraphNode) bool {
+		return (opts.Package == "" || node.Package == opts.Package) &&
+			(opts.File == "" || node.File == opts.File)
+	}
+
+	if opts.Root != "" {
+		if _, ok := nodes[opts.Root]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrFunctionNotFound, opts.Root)
+		}
+		reachable := reachableFrom(opts.Root, edges, opts.MaxDepth)
+		filter := keep
+		keep = func(node CallGraphNode) bool {
+			return reachable[node.ID] && filter(node)
+		}
+	}
+
+	graph := &CallGraph{
+		AnalysisID: analysisID,
+		Nodes:      []CallGraphNode{},
+		Edges:      []CallGraphEdge{},
+	}
+	for _, node := range nodes {
+		if keep(node) {
+			graph.Nodes = append(graph.Nodes, node)
+		}
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+
+	for _, node := range graph.Nodes {
+		for _, callee := range edges[node.ID] {
+			if keep(nodes[callee]) {
+				graph.Edges = append(graph.Edges, CallGraphEdge{From: node.ID, To: callee})
+			}
+		}
+	}
+
+	return graph, nil
+}
+
+// buildCallGraph resolves the recorded calls of every function to nodes in
+// the project. Calls are matched by name within the caller's package,
+// pkg.Func selectors against the package whose directory ends in pkg, and
+// x.Method selectors against a method of that name if the caller's package
+// declares exactly one.
+func buildCallGraph(results []*FileAnalysisResult) (map[string]CallGraphNode, map[string][]string) {
+	nodes := make(map[string]CallGraphNode)
+	functions := make(map[string]map[string]string) // package -> function name -> ID
+	methods := make(map[string]map[string][]string) // package -> method name -> IDs
+	packageDirs := make(map[string][]string)        // last path element -> packages
+	calls := make(map[string][]string)              // ID -> recorded calls
+
+	for _, result := range results {
+		if len(result.Functions) == 0 {
+			continue
+		}
+		pkg := path.Dir(result.FilePath)
+		if functions[pkg] == nil {
+			functions[pkg] = make(map[string]string)
+			methods[pkg] = make(map[string][]string)
+			packageDirs[path.Base(pkg)] = append(packageDirs[path.Base(pkg)], pkg)
+		}
+
+		for _, fn := range result.Functions {
+			id := pkg + "." + fn.Name
+			if fn.Receiver != "" {
+				id = pkg + "." + fn.Receiver + "." + fn.Name
+				methods[pkg][fn.Name] = append(methods[pkg][fn.Name], id)
+			} else {
+				functions[pkg][fn.Name] = id
+			}
+			nodes[id] = CallGraphNode{
+				ID:         id,
+				Name:       fn.Name,
+				Receiver:   fn.Receiver,
+				Package:    pkg,
+				File:       result.FilePath,
+				Line:       fn.StartLine,
+				Complexity: fn.Complexity,
+			}
+			calls[id] = fn.Calls
+		}
+	}
+
+	resolve := func(pkg, call string) string {
+		dot := strings.LastIndex(call, ".")
+		if dot < 0 {
+			return functions[pkg][call]
+		}
+		qualifier, name := call[:dot], call[dot+1:]
+		if !strings.Contains(qualifier, ".") {
+			if dirs := packageDirs[qualifier]; len(dirs) == 1 && dirs[0] != pkg {
+				if id, ok := functions[dirs[0]][name]; ok {
+					return id
+				}
+			}
+		}
+		if candidates := methods[pkg][name]; len(candidates) == 1 {
+			return candidates[0]
+		}
+		return ""
+	}
+
+	edges := make(map[string][]string, len(calls))
+	for id, recorded := range calls {
+		seen := make(map[string]bool)
+		for _, call := range recorded {
+			callee := resolve(nodes[id].Package, call)
+			if callee != "" && !seen[callee] {
+				seen[callee] = true
+				edges[id] = append(edges[id], callee)
+			}
+		}
+		sort.Strings(edges[id])
+	}
+
+	return nodes, edges
+}
+
+// reachableFrom returns the nodes reachable from root within maxDepth calls
+// (0 means no limit). Each node is visited once, so recursive and mutually
+// recursive functions don't loop.
+func reachableFrom(root string, edges map[string][]string, maxDepth int) map[string]bool {
+	reachable := map[string]bool{root: true}
+	frontier := []string{root}
+	for depth := 0; len(frontier) > 0 && (maxDepth == 0 || depth < maxDepth); depth++ {
+		var next []string
+		for _, id := range frontier {
+			for _, callee := range edges[id] {
+				if !reachable[callee] {
+					reachable[callee] = true
+					next = append(next, callee)
+				}
+			}
+		}
+		frontier = next
+	}
+	return reachable
+}
+
+// summarizeFunctions records the concrete functions and methods of a file
+// for call graph building
+func summarizeFunctions(result *analyzer.AnalysisResult) []FunctionSummary {
+	var summaries []FunctionSummary
+	add := func(fn analyzer.Function, receiver string) {
+		summaries = append(summaries, FunctionSummary{
+			Name:       fn.Name,
+			Receiver:   receiver,
+			Signature:  functionSignature(fn),
+			StartLine:  fn.StartLine,
+			EndLine:    fn.EndLine,
+			Complexity: fn.Complexity,
+			Calls:      fn.Calls,
+		})
+	}
+
+	// Methods of types declared in other files are listed with the
+	// functions, naming their receiver
+	for _, fn := range result.Functions {
+		add(fn, fn.Receiver)
+	}
+	for _, class := range result.Classes {
+		for _, method := range class.Methods {
+			if !method.IsAbstract {
+				add(method, class.Name)
+			}
+		}
+	}
+	return summaries
+}
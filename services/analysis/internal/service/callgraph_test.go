@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+)
+
+func callGraphFixtureFiles() []*repository.ProjectFile {
+	return []*repository.ProjectFile{
+		{Path: "cmd/app/main.go", Content: []byte(`package main
+
+import "example.com/app/internal/util"
+
+type Server struct{}
+
+func main() {
+	run()
+}
+
+func run() {
+	util.Format()
+	s := &Server{}
+	s.Start()
+}
+
+func (s *Server) Start() {
+	s.loop()
+}
+
+func (s *Server) loop() {
+	s.loop()
+}
+`)},
+		{Path: "internal/util/util.go", Content: []byte(`package util
+
+import "strings"
+
+func Format() string {
+	return strings.TrimSpace(ping())
+}
+
+func ping() string {
+	return pong()
+}
+
+func pong() string {
+	return ping()
+}
+`)},
+	}
+}
+
+func TestAnalysisService_GetCallGraph(t *testing.T) {
+	analysisService := newAnalysisRun(t, &repository.Project{ID: "callgraph-project"}, callGraphFixtureFiles())
+	analysisService.metricsRepo.On("GetAnalysisResults", mock.Anything, "missing").Return(nil, nil)
+
+	job, err := analysisService.StartAnalysis(context.Background(), "callgraph-project")
+	require.NoError(t, err)
+	analysisService.metricsRepo.On("GetAnalysisResults", mock.Anything, job.ID).Return(analysisService.wait(t).results, nil)
+
+	nodeIDs := func(graph *service.CallGraph) []string {
+		var ids []string
+		for _, node := range graph.Nodes {
+			ids = append(ids, node.ID)
+		}
+		return ids
+	}
+
+	t.Run("whole project", func(t *testing.T) {
+		graph, err := analysisService.GetCallGraph(context.Background(), job.ID, service.CallGraphOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"cmd/app.Server.Start",
+			"cmd/app.Server.loop",
+			"cmd/app.main",
+			"cmd/app.run",
+			"internal/util.Format",
+			"internal/util.ping",
+			"internal/util.pong",
+		}, nodeIDs(graph))
+		assert.ElementsMatch(t, []service.CallGraphEdge{
+			{From: "cmd/app.main", To: "cmd/app.run"},
+			{From: "cmd/app.run", To: "internal/util.Format"},
+			{From: "cmd/app.run", To: "cmd/app.Server.Start"},
+			{From: "cmd/app.Server.Start", To: "cmd/app.Server.loop"},
+			{From: "cmd/app.Server.loop", To: "cmd/app.Server.loop"},
+			{From: "internal/util.Format", To: "internal/util.ping"},
+			{From: "internal/util.ping", To: "internal/util.pong"},
+			{From: "internal/util.pong", To: "internal/util.ping"},
+		}, graph.Edges)
+	})
+
+	t.Run("package filter", func(t *testing.T) {
+		graph, err := analysisService.GetCallGraph(context.Background(), job.ID, service.CallGraphOptions{Package: "internal/util"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"internal/util.Format", "internal/util.ping", "internal/util.pong"}, nodeIDs(graph))
+		assert.Len(t, graph.Edges, 3)
+	})
+
+	t.Run("depth from root", func(t *testing.T) {
+		graph, err := analysisService.GetCallGraph(context.Background(), job.ID, service.CallGraphOptions{Root: "cmd/app.run", MaxDepth: 1})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"cmd/app.Server.Start", "cmd/app.run", "internal/util.Format"}, nodeIDs(graph))
+		assert.Len(t, graph.Edges, 2)
+	})
+
+	t.Run("mutual recursion from root", func(t *testing.T) {
+		graph, err := analysisService.GetCallGraph(context.Background(), job.ID, service.CallGraphOptions{Root: "internal/util.ping"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"internal/util.ping", "internal/util.pong"}, nodeIDs(graph))
+		assert.Len(t, graph.Edges, 2)
+	})
+
+	t.Run("unknown root", func(t *testing.T) {
+		_, err := analysisService.GetCallGraph(context.Background(), job.ID, service.CallGraphOptions{Root: "cmd/app.nope"})
+		assert.ErrorIs(t, err, service.ErrFunctionNotFound)
+	})
+
+	t.Run("unknown analysis", func(t *testing.T) {
+		_, err := analysisService.GetCallGraph(context.Background(), "missing", service.CallGraphOptions{})
+		assert.ErrorIs(t, err, service.ErrAnalysisNotFound)
+	})
+}
+
+func TestAnalysisService_GetCallGraph_SameTypeNameInTwoPackages(t *testing.T) {
+	files := []*repository.ProjectFile{
+		{Path: "a/user.go", Content: []byte("package a\n\ntype User struct{}\n\nfunc (u *User) M() {}\n")},
+		{Path: "b/user.go", Content: []byte("package b\n\ntype User struct{}\n\nfunc (u *User) M() {\n\tu.N()\n}\n")},
+		// Methods of b.User declared apart from the type
+		{Path: "b/user_names.go", Content: []byte("package b\n\nfunc (u *User) N() {\n\tif u != nil {\n\t\treturn\n\t}\n}\n")},
+	}
+	analysisService := newAnalysisRun(t, &repository.Project{ID: "same-names-project"}, files)
+
+	job, err := analysisService.StartAnalysis(context.Background(), "same-names-project")
+	require.NoError(t, err)
+	analysisService.metricsRepo.On("GetAnalysisResults", mock.Anything, job.ID).Return(analysisService.wait(t).results, nil)
+
+	graph, err := analysisService.GetCallGraph(context.Background(), job.ID, service.CallGraphOptions{})
+	require.NoError(t, err)
+
+	nodeFiles := make(map[string]string)
+	for _, node := range graph.Nodes {
+		assert.Equal(t, "User", node.Receiver, node.ID)
+		nodeFiles[node.ID] = node.File
+	}
+	assert.Equal(t, map[string]string{
+		"a.User.M": "a/user.go",
+		"b.User.M": "b/user.go",
+		"b.User.N": "b/user_names.go",
+	}, nodeFiles)
+	assert.Equal(t, []service.CallGraphEdge{{From: "b.User.M", To: "b.User.N"}}, graph.Edges)
+}
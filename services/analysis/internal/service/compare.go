@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+// DefaultRenameSimilarity is the content similarity above which a removed
+// and an added file are reported as a rename
+const DefaultRenameSimilarity = 0.5
+
+// ErrInvalidSimilarity is returned for a rename similarity outside (0, 1]
+var ErrInvalidSimilarity = errors.New("rename similarity must be greater than 0 and at most 1")
+
+// How a file changed between two analyses
+const (
+	FileAdded     = "added"
+	FileRemoved   = "removed"
+	FileModified  = "modified"
+	FileRenamed   = "renamed"
+	FileUnchanged = "unchanged"
+)
+
+// CompareOptions tunes an analysis comparison. RenameSimilarity is the
+// share of lines a removed and an added file must have in common to be
+// reported as a rename; 0 uses DefaultRenameSimilarity.
+type CompareOptions struct {
+	RenameSimilarity float64
+}
+
+// FileComparison is how one file changed between two analyses. Deltas are
+// head minus base; PreviousPath and Similarity are set for renames.
+// Functions lists the file's added, removed and changed functions.
+type FileComparison struct {
+	Path            string               `json:"path"`
+	PreviousPath    string               `json:"previous_path,omitempty"`
+	Status          string               `json:"status"`
+	Similarity      float64              `json:"similarity,omitempty"`
+	LOCDelta        int                  `json:"loc_delta"`
+	ComplexityDelta int                  `json:"complexity_delta"`
+	IssueDelta      int                  `json:"issue_delta"`
+	Functions       []FunctionComparison `json:"functions,omitempty"`
+}
+
+// ComparisonSummary counts files and functions by status and totals the
+// metric deltas
+type ComparisonSummary struct {
+	Added             int `json:"added"`
+	Removed           int `json:"removed"`
+	Modified          int `json:"modified"`
+	Renamed           int `json:"renamed"`
+	Unchanged         int `json:"unchanged"`
+	LOCDelta          int `json:"loc_delta"`
+	ComplexityDelta   int `json:"complexity_delta"`
+	IssueDelta        int `json:"issue_delta"`
+	FunctionsAdded    int `json:"functions_added"`
+	FunctionsRemoved  int `json:"functions_removed"`
+	FunctionsModified int `json:"functions_modified"`
+}
+
+// AnalysisComparison is the file-level difference between two analyses.
+// Warning is set when they were produced by different analyzer or metrics
+// versions.
+type AnalysisComparison struct {
+	BaseID  string            `json:"base_id"`
+	HeadID  string            `json:"head_id"`
+	Warning string            `json:"warning,omitempty"`
+	Summary ComparisonSummary `json:"summary"`
+	Files   []FileComparison  `json:"files"`
+}
+
+// CompareAnalyses reports how files changed from the base analysis to the
+// head analysis, down to the functions of each file. Files that disappear
+// from one path and appear at another with similar content are reported as
+// renames rather than a removal and an addition. Comparisons are memoized in
+// Redis until either analysis is deleted.
+func (s *AnalysisService) CompareAnalyses(ctx context.Context, baseID, headID string, opts CompareOptions) (*AnalysisComparison, error) {
+	threshold := opts.RenameSimilarity
+	if threshold == 0 {
+		threshold = DefaultRenameSimilarity
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, fmt.Errorf("%w: %g", ErrInvalidSimilarity, threshold)
+	}
+
+	if comparison, ok := s.cachedComparison(ctx, baseID, headID, threshold); ok {
+		return comparison, nil
+	}
+
+	base, err := s.analysisResults(ctx, baseID)
+	if err != nil {
+		return nil, err
+	}
+	head, err := s.analysisResults(ctx, headID)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &AnalysisComparison{
+		BaseID:  baseID,
+		HeadID:  headID,
+		Warning: s.versionWarning(ctx, baseID, headID),
+		Files:   compareFiles(base, head, threshold),
+	}
+	for _, file := range comparison.Files {
+		summary := &comparison.Summary
+		switch file.Status {
+		case FileAdded:
+			summary.Added++
+		case FileRemoved:
+			summary.Removed++
+		case FileModified:
+			summary.Modified++
+		case FileRenamed:
+			summary.Renamed++
+		case FileUnchanged:
+			summary.Unchanged++
+		}
+		summary.LOCDelta += file.LOCDelta
+		summary.ComplexityDelta += file.ComplexityDelta
+		summary.IssueDelta += file.IssueDelta
+		for _, fn := range file.Functions {
+			switch fn.Status {
+			case FileAdded:
+				summary.FunctionsAdded++
+			case FileRemoved:
+				summary.FunctionsRemoved++
+			case FileModified:
+				summary.FunctionsModified++
+			}
+		}
+	}
+
+	s.cacheComparison(ctx, comparison, threshold)
+	return comparison, nil
+}
+
+// analysisResults returns the stored file results of an analysis
+func (s *AnalysisService) analysisResults(ctx context.Context, analysisID string) ([]*FileAnalysisResult, error) {
+	results, err := s.metricsRepo.GetAnalysisResults(ctx, analysisID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analysis results: %w", err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrAnalysisNotFound, analysisID)
+	}
+	return results, nil
+}
+
+// versionWarning returns VersionMismatchWarning for the two analyses' jobs.
+// The warning is advisory, so a job that can't be read only goes
+// unchecked.
+func (s *AnalysisService) versionWarning(ctx context.Context, baseID, headID string) string {
+	jobs := make([]*AnalysisJob, 0, 2)
+	for _, id := range []string{baseID, headID} {
+		job, err := s.analysisRepo.GetJob(ctx, id)
+		if err != nil {
+			s.logger.WithError(err).WithField("analysis_id", id).Warn("Failed to read analysis versions for comparison")
+			return ""
+		}
+		jobs = append(jobs, job)
+	}
+	return VersionMismatchWarning(jobs[0], jobs[1])
+}
+
+// compareFiles matches base files to head files by path, then pairs the
+// remaining removed and added files as renames: identical content first,
+// then the most similar pairs at or above threshold
+func compareFiles(base, head []*FileAnalysisResult, threshold float64) []FileComparison {
+	baseByPath := make(map[string]*FileAnalysisResult, len(base))
+	for _, result := range base {
+		baseByPath[result.FilePath] = result
+	}
+
+	var files []FileComparison
+	var added []*FileAnalysisResult
+	matched := make(map[string]bool)
+	for _, result := range head {
+		previous, ok := baseByPath[result.FilePath]
+		if !ok {
+			added = append(added, result)
+			continue
+		}
+		matched[result.FilePath] = true
+		status := FileModified
+		if result.ContentHash != "" && result.ContentHash == previous.ContentHash {
+			status = FileUnchanged
+		}
+		files = append(files, fileDelta(result.FilePath, status, previous, result))
+	}
+
+	var removed []*FileAnalysisResult
+	for _, result := range base {
+		if !matched[result.FilePath] {
+			removed = append(removed, result)
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].FilePath < added[j].FilePath })
+	sort.Slice(removed, func(i, j int) bool { return removed[i].FilePath < removed[j].FilePath })
+
+	type candidate struct {
+		from, to   int
+		similarity float64
+	}
+	var candidates []candidate
+	for i, from := range removed {
+		for j, to := range added {
+			similarity := metrics.SignatureSimilarity(from.Signature, to.Signature)
+			if from.ContentHash != "" && from.ContentHash == to.ContentHash {
+				similarity = 1
+			}
+			if similarity >= threshold {
+				candidates = append(candidates, candidate{from: i, to: j, similarity: similarity})
+			}
+		}
+	}
+	// Exact and closest matches claim their partner first
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].similarity > candidates[j].similarity })
+
+	renamedFrom := make(map[int]bool)
+	renamedTo := make(map[int]bool)
+	for _, c := range candidates {
+		if renamedFrom[c.from] || renamedTo[c.to] {
+			continue
+		}
+		renamedFrom[c.from] = true
+		renamedTo[c.to] = true
+		rename := fileDelta(added[c.to].FilePath, FileRenamed, removed[c.from], added[c.to])
+		rename.PreviousPath = removed[c.from].FilePath
+		rename.Similarity = c.similarity
+		files = append(files, rename)
+	}
+
+	for j, result := range added {
+		if !renamedTo[j] {
+			files = append(files, fileDelta(result.FilePath, FileAdded, nil, result))
+		}
+	}
+	for i, result := range removed {
+		if !renamedFrom[i] {
+			files = append(files, fileDelta(result.FilePath, FileRemoved, result, nil))
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	if files == nil {
+		files = []FileComparison{}
+	}
+	return files
+}
+
+// fileDelta compares the metrics and functions of a file's base and head
+// results, either of which is nil when the file was added or removed
+func fileDelta(path, status string, base, head *FileAnalysisResult) FileComparison {
+	delta := FileComparison{Path: path, Status: status}
+	if status != FileUnchanged {
+		delta.Functions = compareFunctions(base, head)
+	}
+	if head != nil {
+		delta.LOCDelta += head.LOC
+		delta.ComplexityDelta += head.Complexity
+		delta.IssueDelta += len(head.Issues)
+	}
+	if base != nil {
+		delta.LOCDelta -= base.LOC
+		delta.ComplexityDelta -= base.Complexity
+		delta.IssueDelta -= len(base.Issues)
+	}
+	return delta
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// comparisonCacheTTL is how long a memoized comparison is kept. Stored
+// results don't change, so entries only go stale when an analysis is
+// deleted, which drops them through utils.ComparisonCacheIndexKey.
+const comparisonCacheTTL = 24 * time.Hour
+
+// comparisonCacheKey builds "analysis:compare:<version>:<base>:<head>:<threshold>".
+// The result version keeps comparisons made by older logic from being served.
+func comparisonCacheKey(baseID, headID string, threshold float64) string {
+	return fmt.Sprintf("analysis:compare:%s:%s:%s:%g", ResultVersion(), baseID, headID, threshold)
+}
+
+// cachedComparison returns a memoized comparison, if any
+func (s *AnalysisService) cachedComparison(ctx context.Context, baseID, headID string, threshold float64) (*AnalysisComparison, bool) {
+	if s.redisClient == nil {
+		return nil, false
+	}
+	data, err := s.redisClient.Get(ctx, comparisonCacheKey(baseID, headID, threshold)).Bytes()
+	if err != nil {
+		return nil, false
+	}
+
+	var comparison AnalysisComparison
+	if err := json.Unmarshal(data, &comparison); err != nil {
+		return nil, false
+	}
+	return &comparison, true
+}
+
+// cacheComparison memoizes a comparison and indexes it under both analyses
+func (s *AnalysisService) cacheComparison(ctx context.Context, comparison *AnalysisComparison, threshold float64) {
+	if s.redisClient == nil {
+		return
+	}
+	data, err := json.Marshal(comparison)
+	if err != nil {
+		return
+	}
+
+	key := comparisonCacheKey(comparison.BaseID, comparison.HeadID, threshold)
+	pipe := s.redisClient.TxPipeline()
+	pipe.Set(ctx, key, data, comparisonCacheTTL)
+	for _, analysisID := range []string{comparison.BaseID, comparison.HeadID} {
+		indexKey := utils.ComparisonCacheIndexKey(analysisID)
+		pipe.SAdd(ctx, indexKey, key)
+		pipe.Expire(ctx, indexKey, comparisonCacheTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.WithFields(logrus.Fields{
+			"base_id":    comparison.BaseID,
+			"head_id":    comparison.HeadID,
+			"request_id": utils.RequestIDFromContext(ctx),
+		}).Warnf("Failed to cache comparison: %v", err)
+	}
+}
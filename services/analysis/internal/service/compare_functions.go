@@ -0,0 +1,153 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+)
+
+// FunctionComparison is how one function of a file changed between two
+// analyses. Functions are matched by receiver, name and signature, so a
+// changed signature is a removal and an addition. Deltas are head minus
+// base; Complexity and Lines are the head's, or the base's for a removal.
+type FunctionComparison struct {
+	Name            string `json:"name"`
+	Receiver        string `json:"receiver,omitempty"`
+	Signature       string `json:"signature,omitempty"`
+	Status          string `json:"status"`
+	Complexity      int    `json:"complexity"`
+	Lines           int    `json:"lines"`
+	ComplexityDelta int    `json:"complexity_delta"`
+	LinesDelta      int    `json:"lines_delta"`
+}
+
+// functionSignature formats the parameter and result types of fn, as in
+// "(int, string) (bool, error)"
+func functionSignature(fn analyzer.Function) string {
+	params := make([]string, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		params[i] = param.Type
+	}
+	signature := "(" + strings.Join(params, ", ") + ")"
+	switch {
+	case fn.ReturnType == "":
+	case strings.Contains(fn.ReturnType, ", "):
+		signature += " (" + fn.ReturnType + ")"
+	default:
+		signature += " " + fn.ReturnType
+	}
+	return signature
+}
+
+// functionLines is how many lines a function spans
+func functionLines(fn FunctionSummary) int {
+	if fn.EndLine < fn.StartLine {
+		return 0
+	}
+	return fn.EndLine - fn.StartLine + 1
+}
+
+// functionKey identifies a function within a file
+func functionKey(fn FunctionSummary, withSignature bool) string {
+	key := fn.Receiver + "." + fn.Name
+	if withSignature {
+		key += fn.Signature
+	}
+	return key
+}
+
+// compareFunctions reports the functions added, removed or changed in
+// complexity or length between a file's base and head results, either of
+// which is nil when the file was added or removed. Unchanged functions
+// are left out. Functions stored before signatures were recorded match
+// by receiver and name alone.
+func compareFunctions(base, head *FileAnalysisResult) []FunctionComparison {
+	var baseFunctions, headFunctions []FunctionSummary
+	if base != nil {
+		baseFunctions = base.Functions
+	}
+	if head != nil {
+		headFunctions = head.Functions
+	}
+
+	var changes []FunctionComparison
+	matchedBase := make(map[int]bool)
+	matchedHead := make(map[int]bool)
+	// Matching by signature comes first; what's left matches by name when
+	// either side has no signature
+	match := func(withSignature bool) {
+		byKey := make(map[string][]int)
+		for i, fn := range baseFunctions {
+			if !matchedBase[i] {
+				key := functionKey(fn, withSignature)
+				byKey[key] = append(byKey[key], i)
+			}
+		}
+		for j, fn := range headFunctions {
+			if matchedHead[j] {
+				continue
+			}
+			key := functionKey(fn, withSignature)
+			for n, i := range byKey[key] {
+				previous := baseFunctions[i]
+				if !withSignature && previous.Signature != "" && fn.Signature != "" {
+					continue
+				}
+				byKey[key] = append(byKey[key][:n:n], byKey[key][n+1:]...)
+				matchedBase[i] = true
+				matchedHead[j] = true
+
+				change := functionChange(fn, FileModified)
+				change.ComplexityDelta = fn.Complexity - previous.Complexity
+				change.LinesDelta = functionLines(fn) - functionLines(previous)
+				if change.ComplexityDelta != 0 || change.LinesDelta != 0 {
+					changes = append(changes, change)
+				}
+				break
+			}
+		}
+	}
+	match(true)
+	match(false)
+
+	for j, fn := range headFunctions {
+		if !matchedHead[j] {
+			change := functionChange(fn, FileAdded)
+			change.ComplexityDelta = change.Complexity
+			change.LinesDelta = change.Lines
+			changes = append(changes, change)
+		}
+	}
+	for i, fn := range baseFunctions {
+		if !matchedBase[i] {
+			change := functionChange(fn, FileRemoved)
+			change.ComplexityDelta = -change.Complexity
+			change.LinesDelta = -change.Lines
+			changes = append(changes, change)
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Receiver != changes[j].Receiver {
+			return changes[i].Receiver < changes[j].Receiver
+		}
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		return changes[i].Status < changes[j].Status
+	})
+	return changes
+}
+
+// functionChange describes fn with the given status and no deltas
+func functionChange(fn FunctionSummary, status string) FunctionComparison {
+	return FunctionComparison{
+		Name:       fn.Name,
+		Receiver:   fn.Receiver,
+		Signature:  fn.Signature,
+		Status:     status,
+		Complexity: fn.Complexity,
+		Lines:      functionLines(fn),
+	}
+}
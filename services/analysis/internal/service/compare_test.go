@@ -0,0 +1,313 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// compareFixture builds a stored result for a file with the given content
+func compareFixture(path, content string, complexity int) *service.FileAnalysisResult {
+	return &service.FileAnalysisResult{
+		FilePath:    path,
+		Language:    "go",
+		LOC:         strings.Count(content, "\n"),
+		Complexity:  complexity,
+		ContentHash: metrics.ContentHash([]byte(content)),
+		Signature:   metrics.ContentSignature([]byte(content)),
+	}
+}
+
+func fixtureSource(name string, from, to int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "package %s\n\n", name)
+	for i := from; i < to; i++ {
+		fmt.Fprintf(&b, "func F%d() int { return %d }\n", i, i)
+	}
+	return b.String()
+}
+
+func newCompareService(t *testing.T, base, head []*service.FileAnalysisResult) *service.AnalysisService {
+	mockMetricsRepo := new(MockMetricsRepository)
+	mockMetricsRepo.On("GetAnalysisResults", mock.Anything, "base").Return(base, nil)
+	mockMetricsRepo.On("GetAnalysisResults", mock.Anything, "head").Return(head, nil)
+	mockMetricsRepo.On("GetAnalysisResults", mock.Anything, "missing").Return(nil, nil)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	return service.NewAnalysisService(new(MockProjectRepository), newMemoryAnalysisRepository(), mockMetricsRepo, newTestRedis(t), nil, logger)
+}
+
+func TestAnalysisService_CompareAnalyses(t *testing.T) {
+	util := fixtureSource("util", 0, 40)
+	editedUtil := fixtureSource("util", 0, 36) + "func Extra() int { return 0 }\n"
+	server := fixtureSource("server", 100, 120)
+
+	base := []*service.FileAnalysisResult{
+		compareFixture("main.go", "package main\n\nfunc main() {}\n", 1),
+		compareFixture("pkg/util.go", util, 40),
+		compareFixture("pkg/server.go", server, 20),
+		compareFixture("pkg/old.go", fixtureSource("old", 200, 220), 20),
+	}
+	head := []*service.FileAnalysisResult{
+		compareFixture("main.go", "package main\n\nfunc main() { run() }\n", 2),
+		compareFixture("internal/util/util.go", util, 40),
+		compareFixture("internal/server/server.go", strings.Replace(editedUtil, "util", "server", 1), 37),
+		compareFixture("internal/server/http.go", fixtureSource("server", 100, 118)+"func Serve() {}\n", 19),
+		compareFixture("pkg/new.go", fixtureSource("new", 300, 320), 20),
+	}
+	analysisService := newCompareService(t, base, head)
+
+	byPath := func(comparison *service.AnalysisComparison) map[string]service.FileComparison {
+		files := make(map[string]service.FileComparison)
+		for _, file := range comparison.Files {
+			files[file.Path] = file
+		}
+		return files
+	}
+
+	t.Run("renames", func(t *testing.T) {
+		comparison, err := analysisService.CompareAnalyses(context.Background(), "base", "head", service.CompareOptions{})
+		require.NoError(t, err)
+		files := byPath(comparison)
+
+		exact := files["internal/util/util.go"]
+		assert.Equal(t, service.FileRenamed, exact.Status)
+		assert.Equal(t, "pkg/util.go", exact.PreviousPath)
+		assert.Equal(t, 1.0, exact.Similarity)
+		assert.Zero(t, exact.ComplexityDelta)
+
+		edited := files["internal/server/http.go"]
+		assert.Equal(t, service.FileRenamed, edited.Status)
+		assert.Equal(t, "pkg/server.go", edited.PreviousPath)
+		assert.Greater(t, edited.Similarity, 0.5)
+		assert.Less(t, edited.Similarity, 1.0)
+		assert.Equal(t, -1, edited.ComplexityDelta)
+		assert.Equal(t, -1, edited.LOCDelta)
+
+		assert.Equal(t, service.FileModified, files["main.go"].Status)
+		assert.Equal(t, 1, files["main.go"].ComplexityDelta)
+		assert.Equal(t, service.FileAdded, files["internal/server/server.go"].Status)
+		assert.Equal(t, service.FileAdded, files["pkg/new.go"].Status)
+		assert.Equal(t, service.FileRemoved, files["pkg/old.go"].Status)
+
+		assert.Equal(t, service.ComparisonSummary{
+			Added:           2,
+			Removed:         1,
+			Modified:        1,
+			Renamed:         2,
+			LOCDelta:        38,
+			ComplexityDelta: 37,
+		}, comparison.Summary)
+	})
+
+	t.Run("strict threshold only keeps exact renames", func(t *testing.T) {
+		comparison, err := analysisService.CompareAnalyses(context.Background(), "base", "head", service.CompareOptions{RenameSimilarity: 1})
+		require.NoError(t, err)
+		files := byPath(comparison)
+
+		assert.Equal(t, service.FileRenamed, files["internal/util/util.go"].Status)
+		assert.Equal(t, service.FileAdded, files["internal/server/http.go"].Status)
+		assert.Equal(t, service.FileRemoved, files["pkg/server.go"].Status)
+	})
+
+	t.Run("unchanged file", func(t *testing.T) {
+		comparison, err := analysisService.CompareAnalyses(context.Background(), "base", "base", service.CompareOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, len(base), comparison.Summary.Unchanged)
+	})
+
+	t.Run("invalid threshold", func(t *testing.T) {
+		_, err := analysisService.CompareAnalyses(context.Background(), "base", "head", service.CompareOptions{RenameSimilarity: 1.5})
+		assert.ErrorIs(t, err, service.ErrInvalidSimilarity)
+	})
+
+	t.Run("unknown analysis", func(t *testing.T) {
+		_, err := analysisService.CompareAnalyses(context.Background(), "base", "missing", service.CompareOptions{})
+		assert.ErrorIs(t, err, service.ErrAnalysisNotFound)
+	})
+}
+
+func TestAnalysisService_CompareAnalysesCache(t *testing.T) {
+	base := []*service.FileAnalysisResult{compareFixture("main.go", "package main\n", 1)}
+	head := []*service.FileAnalysisResult{compareFixture("main.go", "package main\n\nfunc main() {}\n", 2)}
+
+	mockMetricsRepo := new(MockMetricsRepository)
+	mockMetricsRepo.On("GetAnalysisResults", mock.Anything, "base").Return(base, nil)
+	mockMetricsRepo.On("GetAnalysisResults", mock.Anything, "head").Return(head, nil)
+	mockMetricsRepo.On("GetAnalysisResults", mock.Anything, "other").Return(base, nil)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	redisClient := newTestRedis(t)
+	analysisService := service.NewAnalysisService(new(MockProjectRepository), newMemoryAnalysisRepository(), mockMetricsRepo, redisClient, nil, logger)
+
+	ctx := context.Background()
+	compare := func(baseID, headID string) *service.AnalysisComparison {
+		comparison, err := analysisService.CompareAnalyses(ctx, baseID, headID, service.CompareOptions{})
+		require.NoError(t, err)
+		return comparison
+	}
+
+	first := compare("base", "head")
+	mockMetricsRepo.AssertNumberOfCalls(t, "GetAnalysisResults", 2)
+
+	t.Run("repeated compare is served from the cache", func(t *testing.T) {
+		assert.Equal(t, first, compare("base", "head"))
+		mockMetricsRepo.AssertNumberOfCalls(t, "GetAnalysisResults", 2)
+	})
+
+	t.Run("other options and pairs are cached separately", func(t *testing.T) {
+		_, err := analysisService.CompareAnalyses(ctx, "base", "head", service.CompareOptions{RenameSimilarity: 0.9})
+		require.NoError(t, err)
+		mockMetricsRepo.AssertNumberOfCalls(t, "GetAnalysisResults", 4)
+
+		compare("other", "head")
+		mockMetricsRepo.AssertNumberOfCalls(t, "GetAnalysisResults", 6)
+	})
+
+	t.Run("deleting an analysis invalidates its comparisons", func(t *testing.T) {
+		// What the gateway's cache cleaner does when a project's analyses are deleted
+		indexKey := utils.ComparisonCacheIndexKey("base")
+		keys, err := redisClient.SMembers(ctx, indexKey).Result()
+		require.NoError(t, err)
+		assert.Len(t, keys, 2, "both thresholds of the base/head pair")
+		require.NoError(t, redisClient.Del(ctx, append(keys, indexKey)...).Err())
+
+		assert.Equal(t, first, compare("base", "head"))
+		mockMetricsRepo.AssertNumberOfCalls(t, "GetAnalysisResults", 8)
+
+		// The other/head comparison doesn't involve base and stays cached
+		compare("other", "head")
+		mockMetricsRepo.AssertNumberOfCalls(t, "GetAnalysisResults", 8)
+	})
+}
+
+func TestAnalysisService_CompareAnalysesVersionWarning(t *testing.T) {
+	results := []*service.FileAnalysisResult{compareFixture("main.go", "package main\n", 1)}
+	mockMetricsRepo := new(MockMetricsRepository)
+	for _, id := range []string{"old", "current", "also-current", "unknown"} {
+		mockMetricsRepo.On("GetAnalysisResults", mock.Anything, id).Return(results, nil)
+	}
+	analysisRepo := newMemoryAnalysisRepository()
+	ctx := context.Background()
+	for id, version := range map[string]string{"old": "1.8.0", "current": "1.9.0", "also-current": "1.9.0"} {
+		require.NoError(t, analysisRepo.CreateJob(ctx, &service.AnalysisJob{ID: id, AnalyzerVersion: version, MetricsVersion: "1.0.0"}))
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	analysisService := service.NewAnalysisService(new(MockProjectRepository), analysisRepo, mockMetricsRepo, newTestRedis(t), nil, logger)
+
+	compare := func(baseID, headID string) *service.AnalysisComparison {
+		comparison, err := analysisService.CompareAnalyses(ctx, baseID, headID, service.CompareOptions{})
+		require.NoError(t, err)
+		return comparison
+	}
+	assert.Contains(t, compare("old", "current").Warning, "analyzer 1.8.0 vs 1.9.0")
+	assert.Empty(t, compare("current", "also-current").Warning)
+	assert.Empty(t, compare("current", "unknown").Warning, "analyses without a job go unchecked")
+}
+
+func TestAnalysisService_CompareFunctions(t *testing.T) {
+	const before = `package shapes
+
+type Circle struct{ r float64 }
+
+func (c Circle) Area() float64 {
+	return 3.14 * c.r * c.r
+}
+
+func Scale(v float64, by int) float64 {
+	return v * float64(by)
+}
+
+func Legacy() {}
+`
+	const after = `package shapes
+
+type Circle struct{ r float64 }
+
+func (c Circle) Area() float64 {
+	if c.r < 0 {
+		return 0
+	}
+	return 3.14 * c.r * c.r
+}
+
+func Scale(v float64, by float64) float64 {
+	return v * by
+}
+
+func Perimeter(c Circle) (float64, error) {
+	return 2 * 3.14 * c.r, nil
+}
+`
+	mockProjectRepo := new(MockProjectRepository)
+	mockMetricsRepo := new(MockMetricsRepository)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), mockMetricsRepo, newTestRedis(t), nil, logger)
+
+	saved := make(chan []*service.FileAnalysisResult, 1)
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			saved <- args.Get(2).([]*service.FileAnalysisResult)
+		}).Return(nil)
+	analyze := func(projectID, content string) string {
+		mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+		mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return([]*repository.ProjectFile{
+			{Path: "shapes/shapes.go", Content: []byte(content)},
+		}, nil)
+		job, err := analysisService.StartAnalysis(context.Background(), projectID)
+		require.NoError(t, err)
+		select {
+		case results := <-saved:
+			mockMetricsRepo.On("GetAnalysisResults", mock.Anything, job.ID).Return(results, nil)
+		case <-time.After(5 * time.Second):
+			t.Fatal("analysis results were not saved")
+		}
+		return job.ID
+	}
+	baseID := analyze("shapes-before", before)
+	headID := analyze("shapes-after", after)
+
+	comparison, err := analysisService.CompareAnalyses(context.Background(), baseID, headID, service.CompareOptions{})
+	require.NoError(t, err)
+	require.Len(t, comparison.Files, 1)
+	assert.Equal(t, []service.FunctionComparison{
+		{Name: "Legacy", Signature: "()", Status: service.FileRemoved, Complexity: 1, Lines: 1, ComplexityDelta: -1, LinesDelta: -1},
+		{Name: "Perimeter", Signature: "(Circle) (float64, error)", Status: service.FileAdded, Complexity: 1, Lines: 3, ComplexityDelta: 1, LinesDelta: 3},
+		{Name: "Scale", Signature: "(float64, float64) float64", Status: service.FileAdded, Complexity: 1, Lines: 3, ComplexityDelta: 1, LinesDelta: 3},
+		{Name: "Scale", Signature: "(float64, int) float64", Status: service.FileRemoved, Complexity: 1, Lines: 3, ComplexityDelta: -1, LinesDelta: -3},
+		{Name: "Area", Receiver: "Circle", Signature: "() float64", Status: service.FileModified, Complexity: 2, Lines: 6, ComplexityDelta: 1, LinesDelta: 3},
+	}, comparison.Files[0].Functions)
+	assert.Equal(t, 2, comparison.Summary.FunctionsAdded)
+	assert.Equal(t, 2, comparison.Summary.FunctionsRemoved)
+	assert.Equal(t, 1, comparison.Summary.FunctionsModified)
+
+	t.Run("results stored without signatures", func(t *testing.T) {
+		legacy := compareFixture("shapes.go", before, 4)
+		legacy.Functions = []service.FunctionSummary{{Name: "Scale", StartLine: 9, EndLine: 11, Complexity: 1}}
+		current := compareFixture("shapes.go", after, 4)
+		current.Functions = []service.FunctionSummary{{Name: "Scale", Signature: "(float64, float64) float64", StartLine: 12, EndLine: 16, Complexity: 2}}
+
+		comparison, err := newCompareService(t, []*service.FileAnalysisResult{legacy}, []*service.FileAnalysisResult{current}).
+			CompareAnalyses(context.Background(), "base", "head", service.CompareOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []service.FunctionComparison{
+			{Name: "Scale", Signature: "(float64, float64) float64", Status: service.FileModified, Complexity: 2, Lines: 5, ComplexityDelta: 1, LinesDelta: 2},
+		}, comparison.Files[0].Functions)
+	})
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+// ErrInvalidCoverage is returned for a coverage report that can't be parsed
+var ErrInvalidCoverage = errors.New("invalid coverage")
+
+// CoverageInput is a coverage report produced by the project's tests and
+// supplied with an analysis, such as the output of go test -coverprofile
+type CoverageInput struct {
+	// Format is one of go, lcov or cobertura
+	Format string `json:"format"`
+	// Report is the report's content
+	Report string `json:"report"`
+}
+
+// parseCoverage parses the coverage report of the options, nil when none
+// is supplied
+func parseCoverage(input *CoverageInput) (*metrics.CoverageReport, error) {
+	if input == nil {
+		return nil, nil
+	}
+	report, err := metrics.ParseCoverage(input.Format, []byte(input.Report))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCoverage, err)
+	}
+	return report, nil
+}
+
+// applyCoverage replaces the estimated test coverage of an analyzed file
+// with the coverage the report measured. Files the report doesn't cover
+// keep their estimate, flagged as such.
+func applyCoverage(report *metrics.CoverageReport, result *FileAnalysisResult) {
+	if report == nil || result.Skipped != "" || result.Error != "" || result.Metrics == nil {
+		return
+	}
+	coverage := report.File(result.FilePath)
+	if coverage == nil {
+		return
+	}
+	result.Metrics["test_coverage"] = coverage.Percent()
+	result.Metrics["covered_lines"] = coverage.CoveredLines()
+	result.Metrics["coverable_lines"] = coverage.CoverableLines()
+	result.Metrics["coverage_estimated"] = false
+}
+
+// aggregateCoverage returns the project's coverage and whether it is
+// estimated. Measured coverage is the share of coverable lines covered
+// across the project's files. Source files a report leaves out, in a
+// language it covers, were never run by the tests, so they count as
+// uncovered, their code lines standing in for their coverable lines; test
+// files and other languages aren't counted. Without a report it is the
+// average of the estimates of the files with code.
+func aggregateCoverage(results []*FileAnalysisResult) (float64, bool) {
+	covered, coverable, measured := 0, 0, false
+	reported := make(map[string]bool)
+	unreported := make(map[string]int)
+	estimates, estimated := 0.0, 0
+	for _, result := range results {
+		if result.Skipped != "" || result.Error != "" || result.Metrics == nil || isEmpty(result) {
+			continue
+		}
+		if isEstimated, ok := result.Metrics["coverage_estimated"].(bool); ok && !isEstimated {
+			fileCovered, _ := metricNumber(result.Metrics["covered_lines"])
+			fileCoverable, _ := metricNumber(result.Metrics["coverable_lines"])
+			covered += int(fileCovered)
+			coverable += int(fileCoverable)
+			measured = true
+			reported[result.Language] = true
+			continue
+		}
+		if !isTestFile(result.FilePath) {
+			unreported[result.Language] += codeLines(result)
+		}
+		if estimate, ok := metricNumber(result.Metrics["test_coverage"]); ok {
+			estimates += estimate
+			estimated++
+		}
+	}
+
+	if measured {
+		for language, lines := range unreported {
+			if reported[language] {
+				coverable += lines
+			}
+		}
+		return metrics.CoveragePercent(covered, coverable), false
+	}
+	if estimated == 0 {
+		return 0, true
+	}
+	return estimates / float64(estimated), true
+}
+
+// codeLines returns the code lines of a file, its lines of code for results
+// stored without the metric
+func codeLines(result *FileAnalysisResult) int {
+	if lines, ok := metricNumber(result.Metrics["code_lines"]); ok {
+		return int(lines)
+	}
+	return result.LOC
+}
+
+// isTestFile reports whether a file holds tests by the naming conventions of
+// the supported languages, such as calc_test.go, test_calc.py, calc.spec.ts,
+// CalcTest.java and CalcTests.cs
+func isTestFile(filePath string) bool {
+	slashed := "/" + strings.ReplaceAll(filePath, "\\", "/")
+	if strings.Contains(slashed, "/__tests__/") || strings.Contains(slashed, "/src/test/") {
+		return true
+	}
+	name := path.Base(slashed)
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	switch ext {
+	case ".go":
+		return strings.HasSuffix(stem, "_test")
+	case ".py":
+		return strings.HasPrefix(stem, "test_") || strings.HasSuffix(stem, "_test")
+	case ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx":
+		return strings.HasSuffix(stem, ".test") || strings.HasSuffix(stem, ".spec")
+	case ".java", ".cs":
+		return strings.HasSuffix(stem, "Test") || strings.HasSuffix(stem, "Tests")
+	}
+	return false
+}
+
+// metricNumber reads a numeric metric, which is a float64 or json.Number
+// once results have been stored and loaded again
+func metricNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
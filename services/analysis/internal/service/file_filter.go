@@ -0,0 +1,141 @@
+package service
+
+import (
+	"bytes"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+)
+
+// defaultMaxFileSize is the largest file analyzed by default (1 MiB)
+const defaultMaxFileSize = 1 << 20
+
+// Reasons a file is excluded from an analysis
+const (
+	SkipReasonIgnored  = "ignored"
+	SkipReasonTooLarge = "too_large"
+	// SkipReasonLanguageDisabled marks files in a language the run excludes
+	SkipReasonLanguageDisabled = "language_disabled"
+	// SkipReasonBinary marks files whose content isn't text
+	SkipReasonBinary = "binary"
+)
+
+// binarySniffLength is how much of a file is inspected to decide whether
+// it's binary, as git does
+const binarySniffLength = 8000
+
+// FileFilter decides which project files an analysis processes
+type FileFilter struct {
+	// IgnorePatterns are matched against each file. A pattern ending in "/"
+	// matches a directory anywhere in the path; other patterns are glob
+	// patterns matched against the full path and the base name.
+	IgnorePatterns []string
+	// IgnoreFiles name the per-directory ignore files, such as .gitignore,
+	// whose patterns apply on top of IgnorePatterns. Project-level patterns
+	// always apply; a nested file can't re-include what they exclude.
+	IgnoreFiles []string
+	// MaxFileSize is the largest file size in bytes to analyze; 0 disables the limit
+	MaxFileSize int64
+	// SkipBinary skips files whose content looks binary instead of analyzing them
+	SkipBinary bool
+}
+
+// SkippedFile records a file excluded by the filter
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// DefaultFileFilter returns the filter used when none is configured
+func DefaultFileFilter() FileFilter {
+	return FileFilter{
+		IgnorePatterns: []string{".git/", "vendor/", "node_modules/", "dist/", "build/", "*.min.js"},
+		IgnoreFiles:    DefaultIgnoreFiles,
+		MaxFileSize:    defaultMaxFileSize,
+		SkipBinary:     true,
+	}
+}
+
+// Apply splits files into those to analyze and those skipped
+func (f FileFilter) Apply(files []*repository.ProjectFile) ([]*repository.ProjectFile, []SkippedFile) {
+	selected := make([]*repository.ProjectFile, 0, len(files))
+	var skipped []SkippedFile
+	ignores := newIgnoreMatcher(files, f.IgnoreFiles)
+
+	for _, file := range files {
+		if reason := f.skipReason(file, ignores); reason != "" {
+			skipped = append(skipped, SkippedFile{Path: file.Path, Reason: reason})
+			continue
+		}
+		selected = append(selected, file)
+	}
+
+	return selected, skipped
+}
+
+func (f FileFilter) skipReason(file *repository.ProjectFile, ignores *ignoreMatcher) string {
+	if f.isIgnored(file.Path) || ignores.ignored(file.Path) {
+		return SkipReasonIgnored
+	}
+	if f.MaxFileSize > 0 && fileSize(file) > f.MaxFileSize {
+		return SkipReasonTooLarge
+	}
+	return ""
+}
+
+func (f FileFilter) isIgnored(filePath string) bool {
+	normalized := filepath.ToSlash(filePath)
+	segments := strings.Split(path.Dir(normalized), "/")
+
+	for _, pattern := range f.IgnorePatterns {
+		if dir, ok := strings.CutSuffix(pattern, "/"); ok {
+			for _, segment := range segments {
+				if segment == dir {
+					return true
+				}
+			}
+			continue
+		}
+
+		if matched, _ := path.Match(pattern, normalized); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, path.Base(normalized)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// fileSize returns the recorded size of a file, falling back to its content length
+func fileSize(file *repository.ProjectFile) int64 {
+	if file.Size > 0 {
+		return file.Size
+	}
+	return int64(len(file.Content))
+}
+
+// skipsContent reports whether a file is skipped for its content
+func (f FileFilter) skipsContent(content []byte) bool {
+	return f.SkipBinary && isBinary(content)
+}
+
+// isBinary reports whether content looks binary: the start of the file
+// holds a NUL byte or isn't valid UTF-8
+func isBinary(content []byte) bool {
+	sample := content
+	if len(sample) > binarySniffLength {
+		sample = sample[:binarySniffLength]
+		// Don't count a character cut off by the sample as invalid
+		for i := 1; i < utf8.UTFMax && len(sample) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(sample); r != utf8.RuneError || size != 1 {
+				break
+			}
+			sample = sample[:len(sample)-1]
+		}
+	}
+	return bytes.IndexByte(sample, 0) >= 0 || !utf8.Valid(sample)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+)
+
+// DefaultMaxFiles bounds how many files a single analysis processes
+const DefaultMaxFiles = 50000
+
+// What an analysis of more files than the maximum does
+const (
+	// FileLimitFail fails the analysis before any file is analyzed
+	FileLimitFail = "fail"
+	// FileLimitTruncate analyzes the largest files up to the maximum and
+	// skips the others
+	FileLimitTruncate = "truncate"
+)
+
+// SkipReasonFileLimit marks files left out of a truncated analysis
+const SkipReasonFileLimit = "file_limit"
+
+var (
+	// ErrTooManyFiles is returned for a project over the file limit when the
+	// limit's policy is FileLimitFail
+	ErrTooManyFiles = errors.New("too many files")
+	// ErrInvalidFileLimit is returned for a negative maximum or an unknown policy
+	ErrInvalidFileLimit = errors.New("invalid file limit")
+)
+
+// FileLimit caps the files of an analysis, counted after the file filter
+type FileLimit struct {
+	// Max is the most files analyzed; 0 disables the limit
+	Max int
+	// Policy is FileLimitFail or FileLimitTruncate
+	Policy string
+}
+
+// Validate reports a negative maximum or an unknown policy
+func (l FileLimit) Validate() error {
+	if l.Max < 0 {
+		return fmt.Errorf("%w: maximum must not be negative: %d", ErrInvalidFileLimit, l.Max)
+	}
+	if l.Policy != FileLimitFail && l.Policy != FileLimitTruncate {
+		return fmt.Errorf("%w: unknown policy %q", ErrInvalidFileLimit, l.Policy)
+	}
+	return nil
+}
+
+// exceeded reports whether there are more files than the limit allows
+func (l FileLimit) exceeded(files []*repository.ProjectFile) bool {
+	return l.Max > 0 && len(files) > l.Max
+}
+
+// apply returns the files within the limit. Over it, FileLimitFail fails
+// with ErrTooManyFiles while FileLimitTruncate keeps the largest files,
+// by path among files of the same size, in their original order and
+// reports the others as skipped.
+func (l FileLimit) apply(files []*repository.ProjectFile) ([]*repository.ProjectFile, []SkippedFile, error) {
+	if !l.exceeded(files) {
+		return files, nil, nil
+	}
+	if l.Policy == FileLimitFail {
+		return nil, nil, fmt.Errorf("%w: the project has %d files to analyze, more than the maximum of %d", ErrTooManyFiles, len(files), l.Max)
+	}
+
+	bySize := make([]int, len(files))
+	for i := range bySize {
+		bySize[i] = i
+	}
+	sort.SliceStable(bySize, func(a, b int) bool {
+		fa, fb := files[bySize[a]], files[bySize[b]]
+		if sa, sb := fileSize(fa), fileSize(fb); sa != sb {
+			return sa > sb
+		}
+		return fa.Path < fb.Path
+	})
+	kept := make(map[int]bool, l.Max)
+	for _, i := range bySize[:l.Max] {
+		kept[i] = true
+	}
+
+	selected := make([]*repository.ProjectFile, 0, l.Max)
+	skipped := make([]SkippedFile, 0, len(files)-l.Max)
+	for i, file := range files {
+		if kept[i] {
+			selected = append(selected, file)
+		} else {
+			skipped = append(skipped, SkippedFile{Path: file.Path, Reason: SkipReasonFileLimit})
+		}
+	}
+	return selected, skipped, nil
+}
@@ -0,0 +1,94 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+)
+
+func TestAnalysisService_FileLimit(t *testing.T) {
+	// planFixtureFiles leaves main.go, pkg/util/util.go and web/app.js after
+	// filtering, util.go being the largest and app.js the smallest
+	tests := []struct {
+		name      string
+		limit     service.FileLimit
+		files     []string
+		exceeds   bool
+		overLimit []string
+		failed    bool
+	}{
+		{
+			name:  "under the limit",
+			limit: service.FileLimit{Max: 3, Policy: service.FileLimitFail},
+			files: []string{"main.go", "pkg/util/util.go", "web/app.js"},
+		},
+		{
+			name:      "truncated to the largest files",
+			limit:     service.FileLimit{Max: 2, Policy: service.FileLimitTruncate},
+			files:     []string{"main.go", "pkg/util/util.go"},
+			exceeds:   true,
+			overLimit: []string{"web/app.js"},
+		},
+		{
+			name:    "failed over the limit",
+			limit:   service.FileLimit{Max: 2, Policy: service.FileLimitFail},
+			files:   []string{"main.go", "pkg/util/util.go", "web/app.js"},
+			exceeds: true,
+			failed:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectID := "limited-project"
+			analysisService := newAnalysisRun(t, &repository.Project{ID: projectID}, planFixtureFiles())
+			analysisService.SetFileLimit(tt.limit)
+
+			plan, err := analysisService.PlanAnalysis(context.Background(), projectID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.limit.Max, plan.MaxFiles)
+			assert.Equal(t, tt.limit.Policy, plan.FileLimitPolicy)
+			assert.Equal(t, tt.exceeds, plan.ExceedsMaxFiles)
+			assert.Equal(t, tt.files, plan.Files)
+			for _, path := range tt.overLimit {
+				assert.Contains(t, plan.Skipped, service.SkippedFile{Path: path, Reason: service.SkipReasonFileLimit})
+			}
+
+			job, err := analysisService.StartAnalysis(context.Background(), projectID)
+			require.NoError(t, err)
+
+			if tt.failed {
+				assert.Eventually(t, func() bool {
+					stored, err := analysisService.jobs.GetJob(context.Background(), job.ID)
+					return err == nil && stored.Status == service.StatusFailed
+				}, 5*time.Second, 10*time.Millisecond)
+
+				stored, err := analysisService.jobs.GetJob(context.Background(), job.ID)
+				require.NoError(t, err)
+				assert.Contains(t, stored.Error, "too many files")
+				analysisService.metricsRepo.AssertNotCalled(t, "SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			call := analysisService.wait(t)
+			var processed []string
+			for _, r := range call.results {
+				processed = append(processed, r.FilePath)
+			}
+			assert.ElementsMatch(t, tt.files, processed)
+			if len(tt.overLimit) > 0 {
+				assert.Equal(t, true, call.aggregate.Truncated)
+				assert.Equal(t, len(tt.overLimit), call.aggregate.FilesOverLimit)
+			} else {
+				assert.False(t, call.aggregate.Truncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+)
+
+// DefaultIgnoreFiles are the per-directory ignore files honored by default.
+// In a directory with both, .sa3dignore rules come last and win.
+var DefaultIgnoreFiles = []string{".gitignore", ".sa3dignore"}
+
+// ignoreRule is one pattern of an ignore file, in .gitignore syntax
+type ignoreRule struct {
+	segments []string // the pattern split on "/"
+	anchored bool     // matched from the ignore file's directory rather than by name at any depth
+	dirOnly  bool     // the pattern ended in "/" and only matches directories
+	negate   bool     // the pattern started with "!" and re-includes matches
+}
+
+// ignoreMatcher applies the ignore files found among a project's files.
+// Each file's patterns are relative to its directory, and rules deeper in
+// the tree take precedence, as do later rules within a directory.
+type ignoreMatcher struct {
+	rules map[string][]ignoreRule // by directory of the ignore file, "" for the root
+}
+
+// newIgnoreMatcher collects the rules of the ignore files named in names
+func newIgnoreMatcher(files []*repository.ProjectFile, names []string) *ignoreMatcher {
+	m := &ignoreMatcher{rules: make(map[string][]ignoreRule)}
+	for _, name := range names {
+		for _, file := range files {
+			filePath := cleanFilePath(file.Path)
+			if path.Base(filePath) != name {
+				continue
+			}
+			dir := path.Dir(filePath)
+			if dir == "." {
+				dir = ""
+			}
+			m.rules[dir] = append(m.rules[dir], parseIgnoreFile(file.Content)...)
+		}
+	}
+	return m
+}
+
+// ignored reports whether the ignore files exclude filePath. As with git, a
+// file inside an excluded directory can't be re-included.
+func (m *ignoreMatcher) ignored(filePath string) bool {
+	if len(m.rules) == 0 {
+		return false
+	}
+	parts := strings.Split(cleanFilePath(filePath), "/")
+	for i := 1; i < len(parts); i++ {
+		if m.match(parts[:i], true) {
+			return true
+		}
+	}
+	return m.match(parts, false)
+}
+
+// match decides a path by the last rule matching it, going through the ignore
+// files of its ancestor directories from the root down
+func (m *ignoreMatcher) match(parts []string, isDir bool) bool {
+	ignored := false
+	for depth := 0; depth < len(parts); depth++ {
+		for _, rule := range m.rules[strings.Join(parts[:depth], "/")] {
+			if rule.matches(parts[depth:], isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// matches reports whether the rule matches a path relative to its ignore file
+func (r ignoreRule) matches(relative []string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		// Names match at any depth; ancestors are checked on their own
+		matched, _ := path.Match(r.segments[0], relative[len(relative)-1])
+		return matched
+	}
+	return matchSegments(r.segments, relative)
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// matches any number of directories
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				// A trailing "/**" matches everything inside
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// parseIgnoreFile parses the patterns of an ignore file, skipping blank
+// lines and comments
+func parseIgnoreFile(content []byte) []ignoreRule {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreRule parses one line of an ignore file
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	switch {
+	case strings.HasPrefix(line, "!"):
+		rule.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\`):
+		// \# and \! escape a literal first character
+		line = line[1:]
+	}
+	if trimmed, ok := strings.CutSuffix(line, "/"); ok {
+		rule.dirOnly = true
+		line = trimmed
+	}
+	// A slash anywhere but at the end anchors the pattern to its directory
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+// cleanFilePath returns a project file path with forward slashes and
+// without a leading "/"
+func cleanFilePath(filePath string) string {
+	filePath = path.Clean(filepath.ToSlash(filePath))
+	return strings.TrimLeft(filePath, "/")
+}
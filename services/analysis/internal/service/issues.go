@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+)
+
+// ErrInvalidIssueFilter is returned for an unknown severity or sort order
+var ErrInvalidIssueFilter = errors.New("invalid issue filter")
+
+// Issue sort orders
+const (
+	// IssueSortFile orders issues by file, then line; it is the default
+	IssueSortFile = "file"
+	// IssueSortSeverity puts the most severe issues first, then orders by file and line
+	IssueSortSeverity = "severity"
+)
+
+// IssueFilter narrows and orders the issues of an analysis. Empty fields
+// match every issue.
+type IssueFilter struct {
+	// Severities keeps issues of these severities only
+	Severities []string
+	// MinSeverity keeps issues at least this severe
+	MinSeverity string
+	// Types keeps issues of these types only, such as code_smell or vulnerability
+	Types []string
+	// File is a file path, a directory ending in "/" or a path.Match pattern
+	File string
+	// Sort is IssueSortFile or IssueSortSeverity
+	Sort string
+}
+
+// IssueList is the filtered issues of an analysis
+type IssueList struct {
+	AnalysisID string          `json:"analysis_id"`
+	Total      int             `json:"total"`
+	BySeverity map[string]int  `json:"by_severity"`
+	Issues     []metrics.Issue `json:"issues"`
+}
+
+// GetIssues returns the issues of a finished analysis matching the filter.
+// The project's minimum severity only applies to aggregate counts; every
+// stored issue can be listed.
+func (s *AnalysisService) GetIssues(ctx context.Context, analysisID string, filter IssueFilter) (*IssueList, error) {
+	match, err := filter.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.analysisResults(ctx, analysisID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &IssueList{
+		AnalysisID: analysisID,
+		BySeverity: make(map[string]int),
+		Issues:     []metrics.Issue{},
+	}
+	for _, result := range results {
+		for _, issue := range result.Issues {
+			if issue.File == "" {
+				issue.File = result.FilePath
+			}
+			if match(issue) {
+				list.Issues = append(list.Issues, issue)
+				list.BySeverity[issue.Severity]++
+			}
+		}
+	}
+	list.Total = len(list.Issues)
+
+	bySeverity := filter.Sort == IssueSortSeverity
+	sort.SliceStable(list.Issues, func(i, j int) bool {
+		a, b := list.Issues[i], list.Issues[j]
+		if bySeverity {
+			if ra, rb := metrics.SeverityRank(a.Severity), metrics.SeverityRank(b.Severity); ra != rb {
+				return ra > rb
+			}
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+
+	return list, nil
+}
+
+// matcher validates the filter and returns its predicate
+func (f IssueFilter) matcher() (func(metrics.Issue) bool, error) {
+	switch f.Sort {
+	case "", IssueSortFile, IssueSortSeverity:
+	default:
+		return nil, fmt.Errorf("%w: unknown sort %q", ErrInvalidIssueFilter, f.Sort)
+	}
+
+	severities := make(map[string]bool, len(f.Severities))
+	for _, name := range f.Severities {
+		severity, err := metrics.ParseSeverity(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidIssueFilter, err)
+		}
+		severities[severity] = true
+	}
+	minSeverity := ""
+	if f.MinSeverity != "" {
+		var err error
+		if minSeverity, err = metrics.ParseSeverity(f.MinSeverity); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidIssueFilter, err)
+		}
+	}
+	types := make(map[string]bool, len(f.Types))
+	for _, issueType := range f.Types {
+		types[strings.ToLower(strings.TrimSpace(issueType))] = true
+	}
+	file := strings.TrimLeft(f.File, "/")
+
+	return func(issue metrics.Issue) bool {
+		if len(severities) > 0 && !severities[strings.ToLower(issue.Severity)] {
+			return false
+		}
+		if !metrics.SeverityAtLeast(issue.Severity, minSeverity) {
+			return false
+		}
+		if len(types) > 0 && !types[issue.Type] {
+			return false
+		}
+		return file == "" || matchIssueFile(file, cleanFilePath(issue.File))
+	}, nil
+}
+
+// matchIssueFile matches a file filter: a directory ending in "/" matches
+// everything below it, a pattern matches by path.Match, anything else
+// matches the path exactly
+func matchIssueFile(filter, filePath string) bool {
+	if strings.HasSuffix(filter, "/") {
+		return strings.HasPrefix(filePath, filter)
+	}
+	if strings.ContainsAny(filter, "*?[") {
+		matched, _ := path.Match(filter, filePath)
+		return matched
+	}
+	return filter == filePath
+}
+
+// projectMinSeverity returns the project's validated minimum severity
+func projectMinSeverity(project *repository.Project) (string, error) {
+	if project.MinSeverity == "" {
+		return "", nil
+	}
+	severity, err := metrics.ParseSeverity(project.MinSeverity)
+	if err != nil {
+		return "", fmt.Errorf("invalid project minimum severity: %w", err)
+	}
+	return severity, nil
+}
@@ -0,0 +1,208 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+)
+
+func TestAnalysisService_GetIssues(t *testing.T) {
+	results := []*service.FileAnalysisResult{
+		{FilePath: "main.go", Issues: []metrics.Issue{
+			{Type: metrics.IssueTypeCodeSmell, Severity: metrics.SeverityInfo, File: "main.go", Line: 1, Rule: metrics.RuleLowCommentRatio},
+			{Type: metrics.IssueTypeCodeSmell, Severity: metrics.SeverityMajor, File: "main.go", Line: 30, Rule: metrics.RuleLongFunction},
+		}},
+		{FilePath: "internal/config/aws.go", Issues: []metrics.Issue{
+			{Type: metrics.IssueTypeVulnerability, Severity: metrics.SeverityCritical, File: "internal/config/aws.go", Line: 7, Rule: metrics.RuleAWSAccessKey},
+			{Type: metrics.IssueTypeCodeSmell, Severity: metrics.SeverityMinor, File: "internal/config/aws.go", Line: 3, Rule: metrics.RuleTooManyParameters},
+		}},
+		{FilePath: "internal/client/client.go", Issues: []metrics.Issue{
+			{Type: metrics.IssueTypeSecurityHotspot, Severity: metrics.SeverityMinor, File: "internal/client/client.go", Line: 12, Rule: metrics.RuleHighEntropyString},
+		}},
+	}
+
+	mockMetricsRepo := new(MockMetricsRepository)
+	mockMetricsRepo.On("GetAnalysisResults", mock.Anything, "analysis-1").Return(results, nil)
+	mockMetricsRepo.On("GetAnalysisResults", mock.Anything, "missing").Return(nil, nil)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	analysisService := service.NewAnalysisService(new(MockProjectRepository), newMemoryAnalysisRepository(), mockMetricsRepo, newTestRedis(t), nil, logger)
+
+	// location identifies an issue in the listing order
+	location := func(list *service.IssueList) []string {
+		var locations []string
+		for _, issue := range list.Issues {
+			locations = append(locations, issue.Rule+"@"+issue.File)
+		}
+		return locations
+	}
+
+	tests := []struct {
+		name   string
+		filter service.IssueFilter
+		want   []string
+	}{
+		{
+			name:   "all by file and line",
+			filter: service.IssueFilter{},
+			want: []string{
+				"high-entropy-string@internal/client/client.go",
+				"too-many-parameters@internal/config/aws.go",
+				"aws-access-key@internal/config/aws.go",
+				"low-comment-ratio@main.go",
+				"long-function@main.go",
+			},
+		},
+		{
+			name:   "sorted by severity",
+			filter: service.IssueFilter{Sort: service.IssueSortSeverity},
+			want: []string{
+				"aws-access-key@internal/config/aws.go",
+				"long-function@main.go",
+				"high-entropy-string@internal/client/client.go",
+				"too-many-parameters@internal/config/aws.go",
+				"low-comment-ratio@main.go",
+			},
+		},
+		{
+			name:   "severities",
+			filter: service.IssueFilter{Severities: []string{"Critical", "info"}},
+			want:   []string{"aws-access-key@internal/config/aws.go", "low-comment-ratio@main.go"},
+		},
+		{
+			name:   "minimum severity",
+			filter: service.IssueFilter{MinSeverity: metrics.SeverityMajor},
+			want:   []string{"aws-access-key@internal/config/aws.go", "long-function@main.go"},
+		},
+		{
+			name:   "types",
+			filter: service.IssueFilter{Types: []string{metrics.IssueTypeVulnerability, metrics.IssueTypeSecurityHotspot}},
+			want:   []string{"high-entropy-string@internal/client/client.go", "aws-access-key@internal/config/aws.go"},
+		},
+		{
+			name:   "exact file",
+			filter: service.IssueFilter{File: "/main.go"},
+			want:   []string{"low-comment-ratio@main.go", "long-function@main.go"},
+		},
+		{
+			name:   "directory",
+			filter: service.IssueFilter{File: "internal/config/"},
+			want:   []string{"too-many-parameters@internal/config/aws.go", "aws-access-key@internal/config/aws.go"},
+		},
+		{
+			name:   "glob",
+			filter: service.IssueFilter{File: "internal/*/client.go"},
+			want:   []string{"high-entropy-string@internal/client/client.go"},
+		},
+		{
+			name:   "combined",
+			filter: service.IssueFilter{File: "internal/", MinSeverity: metrics.SeverityMinor, Types: []string{metrics.IssueTypeCodeSmell}},
+			want:   []string{"too-many-parameters@internal/config/aws.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := analysisService.GetIssues(context.Background(), "analysis-1", tt.filter)
+			require.NoError(t, err)
+
+			assert.Equal(t, "analysis-1", list.AnalysisID)
+			assert.Equal(t, tt.want, location(list))
+			assert.Equal(t, len(tt.want), list.Total)
+			counted := 0
+			for _, n := range list.BySeverity {
+				counted += n
+			}
+			assert.Equal(t, list.Total, counted)
+		})
+	}
+
+	t.Run("no matches", func(t *testing.T) {
+		list, err := analysisService.GetIssues(context.Background(), "analysis-1", service.IssueFilter{File: "docs/"})
+		require.NoError(t, err)
+		assert.NotNil(t, list.Issues, "encodes as an empty list")
+		assert.Zero(t, list.Total)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		for _, filter := range []service.IssueFilter{
+			{Severities: []string{"blocker"}},
+			{MinSeverity: "high"},
+			{Sort: "line"},
+		} {
+			_, err := analysisService.GetIssues(context.Background(), "analysis-1", filter)
+			assert.ErrorIs(t, err, service.ErrInvalidIssueFilter, "%+v", filter)
+		}
+	})
+
+	t.Run("analysis not found", func(t *testing.T) {
+		_, err := analysisService.GetIssues(context.Background(), "missing", service.IssueFilter{})
+		assert.ErrorIs(t, err, service.ErrAnalysisNotFound)
+	})
+}
+
+func TestAnalysisService_MinSeveritySuppressesAggregateCounts(t *testing.T) {
+	files := []*repository.ProjectFile{
+		// A major high-complexity issue and an info low-comment-ratio one
+		{Path: "branchy.go", Content: []byte("package main\n\nfunc f(a int) int {\n" +
+			"\tif a == 1 {\n\t\treturn 1\n\t}\n\tif a == 2 {\n\t\treturn 2\n\t}\n\tif a == 3 {\n\t\treturn 3\n\t}\n" +
+			"\tif a == 4 {\n\t\treturn 4\n\t}\n\tif a == 5 {\n\t\treturn 5\n\t}\n\tif a == 6 {\n\t\treturn 6\n\t}\n" +
+			"\tif a == 7 {\n\t\treturn 7\n\t}\n\tif a == 8 {\n\t\treturn 8\n\t}\n\tif a == 9 {\n\t\treturn 9\n\t}\n" +
+			"\tif a == 10 {\n\t\treturn 10\n\t}\n\treturn 0\n}\n")},
+		// A minor security hotspot
+		{Path: "salt.go", Content: []byte("// Package main holds the salt\npackage main\n\n// salt seeds hashes\nvar salt = \"Zx8Kq2Lm9Vb4Nw7Rt1Ys" + "6Hd3Gf5Jc0Pa\"\n")},
+	}
+
+	run := func(t *testing.T, minSeverity string) (*service.AggregateMetrics, *service.AnalysisJob) {
+		analysisService := newAnalysisRun(t, &repository.Project{ID: "severity-project", MinSeverity: minSeverity}, files)
+
+		job, err := analysisService.StartAnalysis(context.Background(), "severity-project")
+		require.NoError(t, err)
+		return analysisService.wait(t).aggregate, job
+	}
+
+	t.Run("counts everything by default", func(t *testing.T) {
+		aggregate, job := run(t, "")
+
+		assert.Empty(t, job.MinSeverity)
+		bySeverity := aggregate.IssuesBySeverity
+		assert.Positive(t, bySeverity[metrics.SeverityMajor])
+		assert.Positive(t, bySeverity[metrics.SeverityMinor])
+		assert.Equal(t, 1, aggregate.SecurityHotspots)
+		assert.Equal(t, 0, aggregate.SuppressedIssues)
+		assert.Empty(t, aggregate.MinSeverity)
+	})
+
+	t.Run("suppresses below the project minimum", func(t *testing.T) {
+		all, _ := run(t, "")
+		aggregate, job := run(t, "Major")
+
+		assert.Equal(t, metrics.SeverityMajor, job.MinSeverity)
+		assert.Equal(t, metrics.SeverityMajor, aggregate.MinSeverity)
+		bySeverity := aggregate.IssuesBySeverity
+		assert.Positive(t, bySeverity[metrics.SeverityMajor])
+		assert.Zero(t, bySeverity[metrics.SeverityMinor])
+		assert.Zero(t, bySeverity[metrics.SeverityInfo])
+		assert.Equal(t, 0, aggregate.SecurityHotspots, "the minor hotspot is suppressed")
+		assert.Equal(t, all.IssueCount, aggregate.IssueCount+aggregate.SuppressedIssues)
+	})
+
+	t.Run("invalid project minimum", func(t *testing.T) {
+		mockProjectRepo := new(MockProjectRepository)
+		logger := logrus.New()
+		logger.SetLevel(logrus.FatalLevel)
+		analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), new(MockMetricsRepository), newTestRedis(t), nil, logger)
+		mockProjectRepo.On("GetByID", mock.Anything, "p").Return(&repository.Project{ID: "p", MinSeverity: "blocker"}, nil)
+
+		_, err := analysisService.StartAnalysis(context.Background(), "p")
+		assert.ErrorIs(t, err, metrics.ErrUnknownSeverity)
+	})
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for checking Kafka connectivity
+const (
+	DefaultKafkaHealthTimeout  = 5 * time.Second
+	DefaultKafkaHealthInterval = 10 * time.Second
+)
+
+// ErrKafkaUnavailable is returned when no broker answers a health check
+var ErrKafkaUnavailable = errors.New("kafka unavailable")
+
+// KafkaProbe checks that Kafka can be reached
+type KafkaProbe func(ctx context.Context) error
+
+// MetadataProbe probes Kafka by fetching the topic's partitions from the
+// first broker that answers
+func MetadataProbe(brokers []string, topic string) KafkaProbe {
+	return func(ctx context.Context) error {
+		var dialer kafka.Dialer
+		var errs []error
+		for _, broker := range brokers {
+			err := readPartitions(ctx, &dialer, broker, topic)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", broker, err))
+		}
+		if len(errs) == 0 {
+			errs = append(errs, errors.New("no brokers configured"))
+		}
+		return fmt.Errorf("%w: %w", ErrKafkaUnavailable, errors.Join(errs...))
+	}
+}
+
+// readPartitions fetches the topic's partitions from one broker
+func readPartitions(ctx context.Context, dialer *kafka.Dialer, broker, topic string) error {
+	conn, err := dialer.DialContext(ctx, "tcp", broker)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	_, err = conn.ReadPartitions(topic)
+	return err
+}
+
+// KafkaHealth tracks whether Kafka is reachable. The last check decides:
+// event writes wait while it failed, holding events in memory until a
+// later check succeeds, so checks must keep running, through Run or the
+// readiness endpoint, for events to be written again.
+type KafkaHealth struct {
+	probe   KafkaProbe
+	timeout time.Duration
+
+	mu  sync.Mutex
+	err error
+	// recovered is closed once a check succeeds after a failed one
+	recovered chan struct{}
+}
+
+// NewKafkaHealth creates a health check running probe with timeout; a
+// zero timeout leaves probes to the caller's context
+func NewKafkaHealth(probe KafkaProbe, timeout time.Duration) *KafkaHealth {
+	return &KafkaHealth{probe: probe, timeout: timeout, recovered: make(chan struct{})}
+}
+
+// Check probes Kafka and records the result. A probe cut short because
+// ctx is done isn't recorded.
+func (h *KafkaHealth) Check(ctx context.Context) error {
+	probeCtx := ctx
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		probeCtx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	err := h.probe(probeCtx)
+	if err != nil && ctx.Err() != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil && h.err != nil {
+		close(h.recovered)
+		h.recovered = make(chan struct{})
+	}
+	h.err = err
+	return err
+}
+
+// Err returns the result of the last check, nil before the first
+func (h *KafkaHealth) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// Run checks Kafka every interval until ctx is done, logging when it
+// becomes unreachable and when it recovers
+func (h *KafkaHealth) Run(ctx context.Context, interval time.Duration, logger *logrus.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		before := h.Err()
+		err := h.Check(ctx)
+		switch {
+		case err != nil && before == nil:
+			logger.WithError(err).Warn("Kafka unreachable, holding analysis events until it recovers")
+		case err == nil && before != nil:
+			logger.Info("Kafka reachable again, publishing held analysis events")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// wait blocks while the last check failed
+func (h *KafkaHealth) wait() {
+	h.mu.Lock()
+	for h.err != nil {
+		recovered := h.recovered
+		h.mu.Unlock()
+		<-recovered
+		h.mu.Lock()
+	}
+	h.mu.Unlock()
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+)
+
+// ErrUnknownLanguage is returned when a run enables a language no analyzer supports
+var ErrUnknownLanguage = errors.New("unknown language")
+
+// AnalysisOptions tune a single analysis run
+type AnalysisOptions struct {
+	// Languages limits the run to these languages, overriding the project's
+	// enabled languages; empty falls back to the project setting
+	Languages []string `json:"languages"`
+	// Priority orders the run among queued analyses; zero is normal
+	Priority Priority `json:"priority"`
+	// Coverage is a coverage report measured by the project's tests; without
+	// one, test coverage is estimated
+	Coverage *CoverageInput `json:"coverage,omitempty"`
+}
+
+// languageSet is the set of languages a run analyzes; nil allows all
+type languageSet map[analyzer.Language]bool
+
+// parseLanguages builds the set of enabled languages, nil when none are given
+func parseLanguages(names []string) (languageSet, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	set := make(languageSet, len(names))
+	for _, name := range names {
+		lang, ok := analyzer.ParseLanguage(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownLanguage, name)
+		}
+		set[lang] = true
+	}
+	return set, nil
+}
+
+func (s languageSet) allows(lang analyzer.Language) bool {
+	return s == nil || s[lang]
+}
+
+// names returns the enabled languages in sorted order
+func (s languageSet) names() []string {
+	if s == nil {
+		return nil
+	}
+	names := make([]string, 0, len(s))
+	for lang := range s {
+		names = append(names, string(lang))
+	}
+	sort.Strings(names)
+	return names
+}
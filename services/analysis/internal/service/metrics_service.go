@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+var (
+	// ErrNoCompletedAnalysis is returned when a project has no completed
+	// analysis to read metrics from
+	ErrNoCompletedAnalysis = errors.New("no completed analysis")
+	// ErrFileNotAnalyzed is returned when a file isn't among the results of
+	// the latest analysis
+	ErrFileNotAnalyzed = errors.New("file not in analysis")
+)
+
+// FunctionComplexity is one function's share of a file's complexity
+type FunctionComplexity struct {
+	Name       string `json:"name"`
+	Receiver   string `json:"receiver,omitempty"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Lines      int    `json:"lines"`
+	Complexity int    `json:"complexity"`
+}
+
+// FileMetricsDetail is the drill-down into one file of an analysis: its
+// stored metrics, the complexity of each function, most complex first, and
+// the issues detected in it
+type FileMetricsDetail struct {
+	AnalysisID  string                 `json:"analysis_id"`
+	ProjectID   string                 `json:"project_id"`
+	CompletedAt *time.Time             `json:"completed_at,omitempty"`
+	FilePath    string                 `json:"file_path"`
+	Language    string                 `json:"language"`
+	LOC         int                    `json:"loc"`
+	Complexity  int                    `json:"complexity"`
+	Metrics     map[string]interface{} `json:"metrics"`
+	Functions   []FunctionComplexity   `json:"functions"`
+	Issues      []metrics.Issue        `json:"issues"`
+	Error       string                 `json:"error,omitempty"`
+	Skipped     string                 `json:"skipped,omitempty"`
+}
+
+// MetricsService serves the stored metrics of completed analyses
+type MetricsService struct {
+	analysisRepo AnalysisRepository
+	metricsRepo  MetricsRepository
+	logger       *logrus.Logger
+}
+
+// NewMetricsService creates a new metrics service
+func NewMetricsService(analysisRepo AnalysisRepository, metricsRepo MetricsRepository, logger *logrus.Logger) *MetricsService {
+	return &MetricsService{
+		analysisRepo: analysisRepo,
+		metricsRepo:  metricsRepo,
+		logger:       logger,
+	}
+}
+
+// GetFileMetrics returns the metrics of a file of the project from its
+// latest completed analysis. filePath is relative to the project root.
+func (s *MetricsService) GetFileMetrics(ctx context.Context, projectID, filePath string) (*FileMetricsDetail, error) {
+	filePath = cleanFilePath(filePath)
+	if filePath == "" || filePath == "." {
+		return nil, fmt.Errorf("%w: %q", ErrFileNotAnalyzed, filePath)
+	}
+
+	job, err := s.analysisRepo.GetLatestJob(ctx, projectID, StatusCompleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest analysis: %w", err)
+	}
+	if job == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoCompletedAnalysis, projectID)
+	}
+
+	results, err := s.metricsRepo.GetAnalysisResults(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analysis results: %w", err)
+	}
+	for _, result := range results {
+		if cleanFilePath(result.FilePath) == filePath {
+			return fileMetricsDetail(job, result), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrFileNotAnalyzed, filePath)
+}
+
+// fileMetricsDetail builds the drill-down of a stored file result
+func fileMetricsDetail(job *AnalysisJob, result *FileAnalysisResult) *FileMetricsDetail {
+	detail := &FileMetricsDetail{
+		AnalysisID:  job.ID,
+		ProjectID:   job.ProjectID,
+		CompletedAt: job.CompletedAt,
+		FilePath:    result.FilePath,
+		Language:    result.Language,
+		LOC:         result.LOC,
+		Complexity:  result.Complexity,
+		Metrics:     result.Metrics,
+		Functions:   functionComplexities(result.Functions),
+		Issues:      result.Issues,
+		Error:       result.Error,
+		Skipped:     result.Skipped,
+	}
+	if detail.Issues == nil {
+		detail.Issues = []metrics.Issue{}
+	}
+
+	return detail
+}
+
+// functionComplexities breaks a file's complexity down by function, most
+// complex first
+func functionComplexities(summaries []FunctionSummary) []FunctionComplexity {
+	functions := make([]FunctionComplexity, 0, len(summaries))
+	for _, fn := range summaries {
+		functions = append(functions, FunctionComplexity{
+			Name:       fn.Name,
+			Receiver:   fn.Receiver,
+			StartLine:  fn.StartLine,
+			EndLine:    fn.EndLine,
+			Lines:      fn.EndLine - fn.StartLine + 1,
+			Complexity: fn.Complexity,
+		})
+	}
+	sort.SliceStable(functions, func(i, j int) bool {
+		if functions[i].Complexity != functions[j].Complexity {
+			return functions[i].Complexity > functions[j].Complexity
+		}
+		return functions[i].StartLine < functions[j].StartLine
+	})
+	return functions
+}
@@ -0,0 +1,91 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+)
+
+func TestMetricsService_GetFileMetrics(t *testing.T) {
+	ctx := context.Background()
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	started := time.Now()
+	analysisRepo := newMemoryAnalysisRepository()
+	for _, job := range []*service.AnalysisJob{
+		{ID: "old", ProjectID: "project-1", Status: service.StatusCompleted, StartedAt: started.Add(-time.Hour)},
+		{ID: "latest", ProjectID: "project-1", Status: service.StatusCompleted, StartedAt: started},
+		{ID: "running", ProjectID: "project-1", Status: service.StatusRunning, StartedAt: started.Add(time.Minute)},
+	} {
+		require.NoError(t, analysisRepo.CreateJob(ctx, job))
+	}
+
+	metricsRepo := new(MockMetricsRepository)
+	metricsRepo.On("GetAnalysisResults", ctx, "latest").Return([]*service.FileAnalysisResult{
+		{
+			FilePath:   "main.go",
+			Language:   "go",
+			LOC:        40,
+			Complexity: 9,
+			Metrics:    map[string]interface{}{"functions": 3, "maintainability": 71.5},
+			Functions: []service.FunctionSummary{
+				{Name: "main", StartLine: 3, EndLine: 10, Complexity: 1},
+				{Name: "parse", StartLine: 12, EndLine: 30, Complexity: 6},
+				{Name: "Run", Receiver: "Server", StartLine: 32, EndLine: 40, Complexity: 2},
+			},
+			Issues: []metrics.Issue{
+				{Type: "complexity", Severity: "warning", File: "main.go", Line: 12, Rule: "cyclomatic_complexity"},
+			},
+		},
+		{FilePath: "internal/store/cache/cache.go", Language: "go", LOC: 12, Complexity: 2},
+	}, nil)
+
+	metricsService := service.NewMetricsService(analysisRepo, metricsRepo, logger)
+
+	t.Run("existing file", func(t *testing.T) {
+		detail, err := metricsService.GetFileMetrics(ctx, "project-1", "main.go")
+		require.NoError(t, err)
+
+		assert.Equal(t, "latest", detail.AnalysisID)
+		assert.Equal(t, "main.go", detail.FilePath)
+		assert.Equal(t, 9, detail.Complexity)
+		assert.Equal(t, 71.5, detail.Metrics["maintainability"])
+		require.Len(t, detail.Functions, 3)
+		assert.Equal(t, "parse", detail.Functions[0].Name, "most complex function first")
+		assert.Equal(t, 19, detail.Functions[0].Lines)
+		assert.Equal(t, "Server", detail.Functions[1].Receiver)
+		require.Len(t, detail.Issues, 1)
+		assert.Equal(t, "cyclomatic_complexity", detail.Issues[0].Rule)
+	})
+
+	t.Run("path with subdirectories", func(t *testing.T) {
+		for _, filePath := range []string{"internal/store/cache/cache.go", "/internal/store/cache/cache.go", "./internal/store//cache/cache.go"} {
+			detail, err := metricsService.GetFileMetrics(ctx, "project-1", filePath)
+			require.NoError(t, err, filePath)
+			assert.Equal(t, "internal/store/cache/cache.go", detail.FilePath)
+			assert.Empty(t, detail.Functions)
+			assert.NotNil(t, detail.Issues)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := metricsService.GetFileMetrics(ctx, "project-1", "internal/missing.go")
+		assert.ErrorIs(t, err, service.ErrFileNotAnalyzed)
+
+		_, err = metricsService.GetFileMetrics(ctx, "project-1", "/")
+		assert.ErrorIs(t, err, service.ErrFileNotAnalyzed)
+	})
+
+	t.Run("project without completed analysis", func(t *testing.T) {
+		_, err := metricsService.GetFileMetrics(ctx, "project-2", "main.go")
+		assert.ErrorIs(t, err, service.ErrNoCompletedAnalysis)
+	})
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+// ErrUnknownStatus is returned for an analysis status with no counterpart
+var ErrUnknownStatus = errors.New("unknown analysis status")
+
+// modelStatuses maps job statuses, as the API and the cache carry them, to
+// the statuses stored in the analyses table
+var modelStatuses = map[AnalysisStatus]models.AnalysisStatus{
+	StatusPending:   models.AnalysisStatusPending,
+	StatusRunning:   models.AnalysisStatusRunning,
+	StatusCompleted: models.AnalysisStatusCompleted,
+	StatusFailed:    models.AnalysisStatusFailed,
+	StatusCancelled: models.AnalysisStatusCancelled,
+}
+
+// ParseAnalysisStatus returns the job status with the given name in either
+// case, so statuses named as stored are accepted too
+func ParseAnalysisStatus(name string) (AnalysisStatus, error) {
+	status := AnalysisStatus(strings.ToUpper(strings.TrimSpace(name)))
+	if _, ok := modelStatuses[status]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownStatus, name)
+	}
+	return status, nil
+}
+
+// ModelStatus returns the stored status of a job status
+func ModelStatus(status AnalysisStatus) (models.AnalysisStatus, error) {
+	modelStatus, ok := modelStatuses[status]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownStatus, status)
+	}
+	return modelStatus, nil
+}
+
+// StatusFromModel returns the job status of a stored status
+func StatusFromModel(status models.AnalysisStatus) (AnalysisStatus, error) {
+	for jobStatus, modelStatus := range modelStatuses {
+		if modelStatus == status {
+			return jobStatus, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownStatus, status)
+}
+
+// ToModel converts the job to the shared analysis model it is stored as.
+// The job's IDs must be UUIDs.
+func (job *AnalysisJob) ToModel() (*models.Analysis, error) {
+	id, err := uuid.Parse(job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid analysis id %q: %w", job.ID, err)
+	}
+	projectID, err := uuid.Parse(job.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid project id %q: %w", job.ProjectID, err)
+	}
+	status, err := ModelStatus(job.Status)
+	if err != nil {
+		return nil, err
+	}
+	var resources json.RawMessage
+	if job.Resources != nil {
+		if resources, err = json.Marshal(job.Resources); err != nil {
+			return nil, fmt.Errorf("failed to encode resource usage: %w", err)
+		}
+	}
+
+	analysis := &models.Analysis{
+		ProjectID:       projectID,
+		Status:          status,
+		StartedAt:       job.StartedAt,
+		CompletedAt:     job.CompletedAt,
+		Error:           job.Error,
+		AnalyzerVersion: job.AnalyzerVersion,
+		MetricsVersion:  job.MetricsVersion,
+		Progress:        job.Progress,
+		TotalFiles:      job.TotalFiles,
+		Job: models.AnalysisJobDetails{
+			Languages:      job.Languages,
+			MinSeverity:    job.MinSeverity,
+			Priority:       job.Priority.String(),
+			BaseAnalysisID: job.BaseAnalysisID,
+			Paths:          job.Paths,
+			Resources:      resources,
+		},
+	}
+	analysis.ID = id
+	return analysis, nil
+}
+
+// JobFromModel converts a stored analysis to its job
+func JobFromModel(analysis *models.Analysis) (*AnalysisJob, error) {
+	status, err := StatusFromModel(analysis.Status)
+	if err != nil {
+		return nil, err
+	}
+	priority, err := ParsePriority(analysis.Job.Priority)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &AnalysisJob{
+		ID:              analysis.ID.String(),
+		ProjectID:       analysis.ProjectID.String(),
+		Status:          status,
+		StartedAt:       analysis.StartedAt,
+		CompletedAt:     analysis.CompletedAt,
+		Error:           analysis.Error,
+		Progress:        analysis.Progress,
+		TotalFiles:      analysis.TotalFiles,
+		Languages:       analysis.Job.Languages,
+		AnalyzerVersion: analysis.AnalyzerVersion,
+		MetricsVersion:  analysis.MetricsVersion,
+		MinSeverity:     analysis.Job.MinSeverity,
+		Priority:        priority,
+		BaseAnalysisID:  analysis.Job.BaseAnalysisID,
+		Paths:           analysis.Job.Paths,
+	}
+	if len(analysis.Job.Resources) > 0 {
+		var resources ResourceUsage
+		if err := json.Unmarshal(analysis.Job.Resources, &resources); err != nil {
+			return nil, fmt.Errorf("failed to decode resource usage: %w", err)
+		}
+		job.Resources = &resources
+	}
+	return job, nil
+}
@@ -0,0 +1,146 @@
+package service_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+func TestStatusMapping(t *testing.T) {
+	tests := []struct {
+		job   service.AnalysisStatus
+		model models.AnalysisStatus
+	}{
+		{service.StatusPending, models.AnalysisStatusPending},
+		{service.StatusRunning, models.AnalysisStatusRunning},
+		{service.StatusCompleted, models.AnalysisStatusCompleted},
+		{service.StatusFailed, models.AnalysisStatusFailed},
+		{service.StatusCancelled, models.AnalysisStatusCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.job), func(t *testing.T) {
+			model, err := service.ModelStatus(tt.job)
+			require.NoError(t, err)
+			assert.Equal(t, tt.model, model)
+
+			job, err := service.StatusFromModel(tt.model)
+			require.NoError(t, err)
+			assert.Equal(t, tt.job, job)
+
+			for _, name := range []string{string(tt.job), string(tt.model)} {
+				parsed, err := service.ParseAnalysisStatus(name)
+				require.NoError(t, err)
+				assert.Equal(t, tt.job, parsed)
+			}
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := service.ModelStatus("QUEUED")
+		assert.ErrorIs(t, err, service.ErrUnknownStatus)
+		_, err = service.ModelStatus("pending")
+		assert.ErrorIs(t, err, service.ErrUnknownStatus)
+		_, err = service.StatusFromModel("PENDING")
+		assert.ErrorIs(t, err, service.ErrUnknownStatus)
+		_, err = service.ParseAnalysisStatus("done")
+		assert.ErrorIs(t, err, service.ErrUnknownStatus)
+	})
+}
+
+func TestAnalysisJob_ModelRoundTrip(t *testing.T) {
+	completedAt := time.Now().UTC()
+	job := &service.AnalysisJob{
+		ID:              uuid.NewString(),
+		ProjectID:       uuid.NewString(),
+		Status:          service.StatusCompleted,
+		StartedAt:       completedAt.Add(-time.Minute),
+		CompletedAt:     &completedAt,
+		Error:           "",
+		Progress:        12,
+		TotalFiles:      12,
+		Languages:       []string{"go", "python"},
+		AnalyzerVersion: "1.8.0",
+		MetricsVersion:  "1.4.0",
+		MinSeverity:     "major",
+		Priority:        service.PriorityLow,
+		Resources:       &service.ResourceUsage{DurationMs: 60000, CPUTimeMs: 800, FilesPerSecond: 0.2},
+		BaseAnalysisID:  uuid.NewString(),
+		Paths:           []string{"main.go"},
+	}
+
+	analysis, err := job.ToModel()
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, analysis.ID.String())
+	assert.Equal(t, job.ProjectID, analysis.ProjectID.String())
+	assert.Equal(t, models.AnalysisStatusCompleted, analysis.Status)
+	assert.Equal(t, "low", analysis.Job.Priority)
+	assert.Contains(t, string(analysis.Job.Resources), `"duration_ms":60000`)
+
+	got, err := service.JobFromModel(analysis)
+	require.NoError(t, err)
+	assert.Equal(t, job, got)
+}
+
+func TestAnalysisJob_ToModel_Errors(t *testing.T) {
+	valid := func() *service.AnalysisJob {
+		return &service.AnalysisJob{ID: uuid.NewString(), ProjectID: uuid.NewString(), Status: service.StatusPending}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(job *service.AnalysisJob)
+	}{
+		{"invalid id", func(job *service.AnalysisJob) { job.ID = "analysis-1" }},
+		{"invalid project id", func(job *service.AnalysisJob) { job.ProjectID = "project-1" }},
+		{"unknown status", func(job *service.AnalysisJob) { job.Status = "QUEUED" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := valid()
+			tt.modify(job)
+			_, err := job.ToModel()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestJobFromModel(t *testing.T) {
+	t.Run("defaults for unset details", func(t *testing.T) {
+		analysis := &models.Analysis{ProjectID: uuid.New(), Status: models.AnalysisStatusRunning}
+		analysis.ID = uuid.New()
+
+		job, err := service.JobFromModel(analysis)
+		require.NoError(t, err)
+		assert.Equal(t, service.StatusRunning, job.Status)
+		assert.Equal(t, service.PriorityNormal, job.Priority)
+		assert.Nil(t, job.Resources)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			analysis models.Analysis
+			wantErr  error
+		}{
+			{"uppercase status", models.Analysis{Status: "RUNNING"}, service.ErrUnknownStatus},
+			{"unknown priority", models.Analysis{Status: models.AnalysisStatusPending, Job: models.AnalysisJobDetails{Priority: "urgent"}}, service.ErrUnknownPriority},
+			{"corrupt resources", models.Analysis{Status: models.AnalysisStatusCompleted, Job: models.AnalysisJobDetails{Resources: json.RawMessage(`[1]`)}}, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := service.JobFromModel(&tt.analysis)
+				require.Error(t, err)
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+				}
+			})
+		}
+	})
+}
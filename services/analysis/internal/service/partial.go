@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+)
+
+var (
+	// ErrNoPaths is returned when a partial analysis names no files
+	ErrNoPaths = errors.New("no paths to analyze")
+	// ErrPathNotInProject is returned when a partial analysis names a file
+	// the project doesn't have
+	ErrPathNotInProject = errors.New("path not in project")
+)
+
+// StartPartialAnalysis re-analyzes only the given files of a project. The
+// new analysis is derived from the base analysis: the given files are
+// measured afresh and every other file's results are carried forward, so
+// the new analysis covers the whole project. Cross-file duplication is
+// recomputed over all files.
+func (s *AnalysisService) StartPartialAnalysis(ctx context.Context, projectID string, paths []string, baseAnalysisID string) (*AnalysisJob, error) {
+	project, err := s.projectRepo.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	if project == nil {
+		return nil, ErrProjectNotFound
+	}
+
+	base, err := s.analysisRepo.GetJob(ctx, baseAnalysisID)
+	if err != nil || base == nil || base.ProjectID != projectID {
+		return nil, fmt.Errorf("%w: %s", ErrAnalysisNotFound, baseAnalysisID)
+	}
+	if _, err := s.analysisResults(ctx, baseAnalysisID); err != nil {
+		return nil, err
+	}
+
+	files, err := s.projectRepo.GetProjectFiles(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project files: %w", err)
+	}
+	selected, err := projectPaths(files, paths)
+	if err != nil {
+		return nil, err
+	}
+	minSeverity, err := projectMinSeverity(project)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &AnalysisJob{
+		ID:             uuid.New().String(),
+		ProjectID:      projectID,
+		Status:         StatusPending,
+		StartedAt:      s.clock.Now(),
+		Languages:      base.Languages,
+		BaseAnalysisID: baseAnalysisID,
+		Paths:          selected,
+		MinSeverity:    minSeverity,
+
+		AnalyzerVersion: analyzer.AnalyzerVersion,
+		MetricsVersion:  metrics.MetricsVersion,
+	}
+
+	if err := s.analysisRepo.CreateJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create analysis job: %w", err)
+	}
+	if err := s.cacheJobStatus(ctx, job); err != nil {
+		s.logger.Warnf("Failed to cache job status: %v", err)
+	}
+
+	requestID := utils.RequestIDFromContext(ctx)
+	s.logger.WithFields(logrus.Fields{
+		"analysis_id":      job.ID,
+		"project_id":       projectID,
+		"base_analysis_id": baseAnalysisID,
+		"paths":            len(selected),
+		"request_id":       requestID,
+	}).Info("Partial analysis started")
+
+	analysisCtx, cancel := context.WithCancelCause(utils.WithRequestID(context.Background(), requestID))
+	s.cancelFuncs.Store(job.ID, cancel)
+
+	// The run updates its own copy, leaving the returned job to the caller
+	run := *job
+	s.queue.submit(analysisCtx, &run, project)
+
+	return job, nil
+}
+
+// projectPaths cleans the requested paths, drops duplicates and checks that
+// each one is a file of the project
+func projectPaths(files []*repository.ProjectFile, paths []string) ([]string, error) {
+	known := make(map[string]bool, len(files))
+	for _, file := range files {
+		known[file.Path] = true
+	}
+
+	seen := make(map[string]bool, len(paths))
+	var selected []string
+	for _, p := range paths {
+		cleaned := strings.TrimPrefix(path.Clean(strings.TrimSpace(p)), "./")
+		if !known[cleaned] {
+			return nil, fmt.Errorf("%w: %s", ErrPathNotInProject, p)
+		}
+		if !seen[cleaned] {
+			seen[cleaned] = true
+			selected = append(selected, cleaned)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, ErrNoPaths
+	}
+	sort.Strings(selected)
+	return selected, nil
+}
+
+// partialFiles splits a partial analysis' files into those to re-analyze
+// and the base results carried forward for the rest, and returns the files
+// covered by either as the sources for clone detection. Base results of
+// files no longer in the project are dropped. Carried results lose their
+// cross-file clone issues, which are recomputed for the new analysis.
+func (s *AnalysisService) partialFiles(ctx context.Context, job *AnalysisJob, files []*repository.ProjectFile) (analyze, sources []*repository.ProjectFile, carried []*FileAnalysisResult, err error) {
+	base, err := s.analysisResults(ctx, job.BaseAnalysisID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	requested := make(map[string]bool, len(job.Paths))
+	for _, p := range job.Paths {
+		requested[p] = true
+	}
+	previous := make(map[string]*FileAnalysisResult, len(base))
+	for _, result := range base {
+		previous[result.FilePath] = result
+	}
+
+	for _, file := range files {
+		switch {
+		case requested[file.Path]:
+			analyze = append(analyze, file)
+		case previous[file.Path] != nil:
+			carried = append(carried, carryForward(previous[file.Path]))
+		default:
+			// Added since the base analysis and not requested
+			continue
+		}
+		sources = append(sources, file)
+	}
+	return analyze, sources, carried, nil
+}
+
+// carryForward copies a base result for a derived analysis, leaving out the
+// clone issues that depend on the other files
+func carryForward(result *FileAnalysisResult) *FileAnalysisResult {
+	carried := *result
+	carried.Issues = nil
+	for _, issue := range result.Issues {
+		if issue.Rule != metrics.RuleCrossFileClone {
+			carried.Issues = append(carried.Issues, issue)
+		}
+	}
+	if result.Metrics != nil {
+		carried.Metrics = make(map[string]interface{}, len(result.Metrics))
+		for name, value := range result.Metrics {
+			carried.Metrics[name] = value
+		}
+	}
+	return &carried
+}
@@ -0,0 +1,125 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+)
+
+func TestAnalysisService_StartPartialAnalysis(t *testing.T) {
+	mockProjectRepo := new(MockProjectRepository)
+	mockMetricsRepo := new(MockMetricsRepository)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	analysisService := service.NewAnalysisService(
+		mockProjectRepo,
+		newMemoryAnalysisRepository(),
+		mockMetricsRepo,
+		newTestRedis(t),
+		nil,
+		logger,
+	)
+
+	projectID := "partial-project"
+	before := []*repository.ProjectFile{
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+		{Path: "util/util.go", Content: []byte("package util\n\nfunc A() {}\n")},
+		{Path: "util/more.go", Content: []byte("package util\n\nfunc B() {}\n")},
+	}
+	// Both util files change, but only util.go is re-analyzed
+	after := []*repository.ProjectFile{
+		before[0],
+		{Path: "util/util.go", Content: []byte("package util\n\nfunc A() {\n\tif true {\n\t}\n}\n")},
+		{Path: "util/more.go", Content: []byte("package util\n\nfunc B() {\n}\n\nfunc C() {\n}\n")},
+	}
+
+	mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+	mockProjectRepo.On("GetByID", mock.Anything, "other-project").Return(&repository.Project{ID: "other-project"}, nil)
+	mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return(before, nil).Once()
+	mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return(after, nil)
+
+	saved := make(chan []*service.FileAnalysisResult, 2)
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			saved <- args.Get(2).([]*service.FileAnalysisResult)
+		}).Return(nil)
+	mockMetricsRepo.On("GetAnalysisResults", mock.Anything, "missing").Return(nil, nil)
+
+	waitForResults := func() map[string]*service.FileAnalysisResult {
+		t.Helper()
+		select {
+		case results := <-saved:
+			byPath := make(map[string]*service.FileAnalysisResult)
+			for _, result := range results {
+				byPath[result.FilePath] = result
+			}
+			return byPath
+		case <-time.After(5 * time.Second):
+			t.Fatal("analysis results were not saved")
+			return nil
+		}
+	}
+
+	ctx := context.Background()
+	base, err := analysisService.StartAnalysis(ctx, projectID)
+	require.NoError(t, err)
+	baseResults := waitForResults()
+	stored := make([]*service.FileAnalysisResult, 0, len(baseResults))
+	for _, result := range baseResults {
+		stored = append(stored, result)
+	}
+	mockMetricsRepo.On("GetAnalysisResults", mock.Anything, base.ID).Return(stored, nil)
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := analysisService.StartPartialAnalysis(ctx, projectID, nil, base.ID)
+		assert.ErrorIs(t, err, service.ErrNoPaths)
+
+		_, err = analysisService.StartPartialAnalysis(ctx, projectID, []string{"util/missing.go"}, base.ID)
+		assert.ErrorIs(t, err, service.ErrPathNotInProject)
+
+		_, err = analysisService.StartPartialAnalysis(ctx, projectID, []string{"../main.go"}, base.ID)
+		assert.ErrorIs(t, err, service.ErrPathNotInProject)
+
+		_, err = analysisService.StartPartialAnalysis(ctx, projectID, []string{"main.go"}, "missing")
+		assert.ErrorIs(t, err, service.ErrAnalysisNotFound)
+
+		_, err = analysisService.StartPartialAnalysis(ctx, "other-project", []string{"main.go"}, base.ID)
+		assert.ErrorIs(t, err, service.ErrAnalysisNotFound)
+	})
+
+	t.Run("re-measures only the given paths", func(t *testing.T) {
+		job, err := analysisService.StartPartialAnalysis(ctx, projectID, []string{"./util/util.go", "util/util.go"}, base.ID)
+		require.NoError(t, err)
+		assert.Equal(t, base.ID, job.BaseAnalysisID)
+		assert.Equal(t, []string{"util/util.go"}, job.Paths)
+
+		results := waitForResults()
+		require.Len(t, results, 3)
+
+		assert.Greater(t, results["util/util.go"].LOC, baseResults["util/util.go"].LOC)
+		assert.NotEqual(t, baseResults["util/util.go"].ContentHash, results["util/util.go"].ContentHash)
+
+		// more.go changed too but wasn't requested, so its base results stand
+		for _, path := range []string{"main.go", "util/more.go"} {
+			assert.Equal(t, baseResults[path].LOC, results[path].LOC, path)
+			assert.Equal(t, baseResults[path].ContentHash, results[path].ContentHash, path)
+			assert.NotSame(t, baseResults[path], results[path], path)
+		}
+
+		require.Eventually(t, func() bool {
+			finished, err := analysisService.GetAnalysis(ctx, job.ID)
+			return err == nil && finished.Status == service.StatusCompleted
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, service.StatusPending, job.Status, "the run updates its own copy of the job")
+	})
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+)
+
+// AnalysisPlan describes what an analysis of a project would process
+type AnalysisPlan struct {
+	ProjectID string         `json:"project_id"`
+	FileCount int            `json:"file_count"`
+	TotalSize int64          `json:"total_size"`
+	Languages map[string]int `json:"languages"`
+	Files     []string       `json:"files"`
+	Skipped   []SkippedFile  `json:"skipped"`
+	// MaxDuration is the time limit the analysis would run under, if any
+	MaxDuration string `json:"max_duration,omitempty"`
+	// FileTimeout is the time limit of each file, if any
+	FileTimeout string `json:"file_timeout,omitempty"`
+	// MaxFiles is the most files the analysis would process, if limited,
+	// and FileLimitPolicy what it does with more
+	MaxFiles        int    `json:"max_files,omitempty"`
+	FileLimitPolicy string `json:"file_limit_policy,omitempty"`
+	// ExceedsMaxFiles is set when the project has more files than
+	// MaxFiles: the analysis would fail or skip the files over the limit
+	ExceedsMaxFiles bool `json:"exceeds_max_files,omitempty"`
+}
+
+// PlanAnalysis returns the files an analysis of the project would process,
+// applying the same filters as a real run, without analyzing anything
+func (s *AnalysisService) PlanAnalysis(ctx context.Context, projectID string) (*AnalysisPlan, error) {
+	project, err := s.projectRepo.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	if project == nil {
+		return nil, ErrProjectNotFound
+	}
+
+	files, err := s.projectRepo.GetProjectFiles(ctx, project.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project files: %w", err)
+	}
+
+	selected, skipped := s.fileFilter.Apply(files)
+	exceedsMaxFiles := s.fileLimit.exceeded(selected)
+	if exceedsMaxFiles && s.fileLimit.Policy == FileLimitTruncate {
+		var overLimit []SkippedFile
+		selected, overLimit, _ = s.fileLimit.apply(selected)
+		skipped = append(skipped, overLimit...)
+	}
+
+	languages, err := parseLanguages(project.EnabledLanguages)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &AnalysisPlan{
+		ProjectID: project.ID,
+		Languages: make(map[string]int),
+		Files:     make([]string, 0, len(selected)),
+		Skipped:   skipped,
+	}
+	for _, file := range selected {
+		if s.fileFilter.skipsContent(file.Content) {
+			plan.Skipped = append(plan.Skipped, SkippedFile{Path: file.Path, Reason: SkipReasonBinary})
+			continue
+		}
+		language := analyzer.DetectLanguage(file.Path, file.Content)
+		if !languages.allows(language) {
+			plan.Skipped = append(plan.Skipped, SkippedFile{Path: file.Path, Reason: SkipReasonLanguageDisabled})
+			continue
+		}
+		plan.TotalSize += fileSize(file)
+		plan.Languages[string(language)]++
+		plan.Files = append(plan.Files, file.Path)
+	}
+	plan.FileCount = len(plan.Files)
+	if s.maxDuration > 0 {
+		plan.MaxDuration = s.maxDuration.String()
+	}
+	if s.fileTimeout > 0 {
+		plan.FileTimeout = s.fileTimeout.String()
+	}
+	if s.fileLimit.Max > 0 {
+		plan.MaxFiles = s.fileLimit.Max
+		plan.FileLimitPolicy = s.fileLimit.Policy
+		plan.ExceedsMaxFiles = exceedsMaxFiles
+	}
+	if plan.Skipped == nil {
+		plan.Skipped = []SkippedFile{}
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,240 @@
+package service_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+)
+
+func planFixtureFiles() []*repository.ProjectFile {
+	goSource := []byte("package main\n\nfunc main() {}\n")
+	return []*repository.ProjectFile{
+		{Path: "main.go", Content: goSource},
+		{Path: "pkg/util/util.go", Content: []byte("package util\n\nfunc Util() int { return 1 }\n")},
+		{Path: "web/app.js", Content: []byte("console.log('hi')\n")},
+		{Path: "vendor/github.com/lib/lib.go", Content: goSource},
+		{Path: "web/node_modules/dep/index.js", Content: []byte("module.exports = {}\n")},
+		{Path: "web/app.min.js", Content: []byte("var a=1;")},
+		{Path: "generated/huge.go", Content: goSource, Size: 5 << 20},
+	}
+}
+
+func TestFileFilter_Apply(t *testing.T) {
+	selected, skipped := service.DefaultFileFilter().Apply(planFixtureFiles())
+
+	var paths []string
+	for _, f := range selected {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{"main.go", "pkg/util/util.go", "web/app.js"}, paths)
+
+	assert.ElementsMatch(t, []service.SkippedFile{
+		{Path: "vendor/github.com/lib/lib.go", Reason: service.SkipReasonIgnored},
+		{Path: "web/node_modules/dep/index.js", Reason: service.SkipReasonIgnored},
+		{Path: "web/app.min.js", Reason: service.SkipReasonIgnored},
+		{Path: "generated/huge.go", Reason: service.SkipReasonTooLarge},
+	}, skipped)
+
+	unfiltered, skipped := service.FileFilter{}.Apply(planFixtureFiles())
+	assert.Len(t, unfiltered, len(planFixtureFiles()))
+	assert.Empty(t, skipped)
+}
+
+func TestFileFilter_NestedIgnoreFiles(t *testing.T) {
+	source := []byte("package main\n")
+	ignoreFile := func(path, content string) *repository.ProjectFile {
+		return &repository.ProjectFile{Path: path, Content: []byte(content)}
+	}
+	files := []*repository.ProjectFile{
+		ignoreFile(".gitignore", strings.Join([]string{
+			"# build output",
+			"*.log",
+			"!important.log",
+			"/out/",
+			"tmp/",
+			"generated/**",
+			"!generated/keep.go",
+			"docs/*.md",
+			"!vendor/",
+		}, "\n")),
+		ignoreFile("web/.gitignore", "*.js\n!app.js\n/local/\n"),
+		ignoreFile("web/.sa3dignore", "!legacy.js\n"),
+		ignoreFile("web/lib/.gitignore", "!*.js\n"),
+		ignoreFile("pkg/.gitignore", "!tmp/keep.go\n"),
+	}
+	paths := []string{
+		"main.go", "debug.log", "important.log",
+		"out/bin.go", "pkg/out/out.go",
+		"pkg/tmp/cache.go", "pkg/tmp/keep.go",
+		"generated/api.go", "generated/keep.go",
+		"docs/guide.md", "docs/api/ref.md",
+		"web/app.js", "web/bundle.js", "web/legacy.js", "web/lib/util.js",
+		"web/local/dev.go", "local/dev.go",
+		"vendor/lib/lib.go",
+	}
+	for _, path := range paths {
+		files = append(files, &repository.ProjectFile{Path: path, Content: source})
+	}
+
+	selected, skipped := service.DefaultFileFilter().Apply(files)
+
+	var kept []string
+	for _, file := range selected {
+		if !strings.HasSuffix(file.Path, "ignore") {
+			kept = append(kept, file.Path)
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"main.go",
+		"important.log",     // negated after *.log
+		"pkg/out/out.go",    // /out/ is anchored to the root
+		"generated/keep.go", // re-included; only the directory's contents were ignored
+		"docs/api/ref.md",   // * doesn't cross directories
+		"web/app.js",        // negated in web/.gitignore
+		"web/legacy.js",     // .sa3dignore wins over .gitignore in the same directory
+		"web/lib/util.js",   // the deeper ignore file wins
+		"local/dev.go",      // web's /local/ is relative to web
+	}, kept)
+
+	var ignored []string
+	for _, file := range skipped {
+		assert.Equal(t, service.SkipReasonIgnored, file.Reason)
+		ignored = append(ignored, file.Path)
+	}
+	assert.ElementsMatch(t, []string{
+		"debug.log", "out/bin.go",
+		"pkg/tmp/cache.go",
+		"pkg/tmp/keep.go", // inside an ignored directory, which can't be re-included
+		"generated/api.go", "docs/guide.md",
+		"web/bundle.js", "web/local/dev.go",
+		"vendor/lib/lib.go", // project-level patterns can't be negated
+	}, ignored)
+
+	// Without ignore files configured only the project-level patterns apply
+	filter := service.DefaultFileFilter()
+	filter.IgnoreFiles = nil
+	_, skipped = filter.Apply(files)
+	require.Len(t, skipped, 1)
+	assert.Equal(t, "vendor/lib/lib.go", skipped[0].Path)
+}
+
+func TestAnalysisService_SkipsBinaryFiles(t *testing.T) {
+	// A multi-byte character straddling the sniffed prefix is still text
+	longText := "package main\n\n// " + strings.Repeat("a", 7982) + "é\nfunc main() {}\n"
+	files := []*repository.ProjectFile{
+		{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+		{Path: "long.go", Content: []byte(longText)},
+		{Path: "logo.go", Content: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")},
+		{Path: "latin1.py", Content: []byte("print('caf\xe9')\n")},
+	}
+
+	run := func(t *testing.T, filter *service.FileFilter) (map[string]*service.FileAnalysisResult, *service.AggregateMetrics) {
+		analysisService := newAnalysisRun(t, &repository.Project{ID: "binary-project"}, files)
+		if filter != nil {
+			analysisService.SetFileFilter(*filter)
+		}
+
+		_, err := analysisService.StartAnalysis(context.Background(), "binary-project")
+		require.NoError(t, err)
+
+		call := analysisService.wait(t)
+		byPath := make(map[string]*service.FileAnalysisResult)
+		for _, result := range call.results {
+			byPath[result.FilePath] = result
+		}
+		return byPath, call.aggregate
+	}
+
+	t.Run("skipped by default", func(t *testing.T) {
+		results, aggregate := run(t, nil)
+
+		for _, path := range []string{"logo.go", "latin1.py"} {
+			assert.Equal(t, service.SkipReasonBinary, results[path].Skipped, path)
+			assert.Empty(t, results[path].Language, path)
+			assert.Empty(t, results[path].Error, path)
+		}
+		for _, path := range []string{"main.go", "long.go"} {
+			assert.Empty(t, results[path].Skipped, path)
+			assert.Equal(t, "go", results[path].Language, path)
+		}
+		assert.Equal(t, 2, aggregate.SkippedBinaryCount)
+	})
+
+	t.Run("analyzed when disabled", func(t *testing.T) {
+		filter := service.DefaultFileFilter()
+		filter.SkipBinary = false
+		results, aggregate := run(t, &filter)
+
+		assert.Empty(t, results["logo.go"].Skipped)
+		assert.Equal(t, "go", results["logo.go"].Language, "binary content goes to the analyzer")
+		assert.Equal(t, 0, aggregate.SkippedBinaryCount)
+	})
+}
+
+func TestAnalysisService_PlanAnalysis_MatchesRun(t *testing.T) {
+	projectID := "planned-project"
+	analysisService := newAnalysisRun(t, &repository.Project{ID: projectID}, planFixtureFiles())
+
+	plan, err := analysisService.PlanAnalysis(context.Background(), projectID)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, plan.FileCount)
+	assert.Equal(t, service.DefaultMaxDuration.String(), plan.MaxDuration)
+	assert.Equal(t, map[string]int{"go": 2, "javascript": 1}, plan.Languages)
+	assert.Len(t, plan.Skipped, 4)
+
+	var expectedSize int64
+	for _, f := range planFixtureFiles()[:3] {
+		expectedSize += int64(len(f.Content))
+	}
+	assert.Equal(t, expectedSize, plan.TotalSize)
+
+	// Planning must not start any work
+	analysisService.metricsRepo.AssertNotCalled(t, "SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	_, err = analysisService.StartAnalysis(context.Background(), projectID)
+	require.NoError(t, err)
+
+	var processed []string
+	for _, r := range analysisService.wait(t).results {
+		processed = append(processed, r.FilePath)
+	}
+	sort.Strings(processed)
+
+	planned := append([]string(nil), plan.Files...)
+	sort.Strings(planned)
+	assert.Equal(t, planned, processed, "plan lists exactly the files a run processes")
+}
+
+func TestAnalysisService_PlanAnalysis_EnabledLanguages(t *testing.T) {
+	mockProjectRepo := new(MockProjectRepository)
+	project := &repository.Project{ID: "go-only", EnabledLanguages: []string{"go"}}
+	mockProjectRepo.On("GetByID", mock.Anything, project.ID).Return(project, nil)
+	mockProjectRepo.On("GetProjectFiles", mock.Anything, project.ID).Return(planFixtureFiles(), nil)
+
+	analysisService := service.NewAnalysisService(mockProjectRepo, nil, nil, newTestRedis(t), nil, logrus.New())
+
+	plan, err := analysisService.PlanAnalysis(context.Background(), project.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.go", "pkg/util/util.go"}, plan.Files)
+	assert.Contains(t, plan.Skipped, service.SkippedFile{Path: "web/app.js", Reason: service.SkipReasonLanguageDisabled})
+}
+
+func TestAnalysisService_PlanAnalysis_ProjectNotFound(t *testing.T) {
+	mockProjectRepo := new(MockProjectRepository)
+	mockProjectRepo.On("GetByID", mock.Anything, "missing").Return(nil, nil)
+
+	analysisService := service.NewAnalysisService(mockProjectRepo, nil, nil, newTestRedis(t), nil, logrus.New())
+
+	_, err := analysisService.PlanAnalysis(context.Background(), "missing")
+	assert.ErrorIs(t, err, service.ErrProjectNotFound)
+}
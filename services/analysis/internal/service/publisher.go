@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+// EventWriter writes messages to Kafka; *kafka.Writer satisfies it
+type EventWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// EventRetry bounds retrying a failed event write
+type EventRetry struct {
+	// Attempts is how many times an event is written before it is dropped
+	Attempts int
+	// Backoff is the wait after the first failed attempt, doubling after
+	// each further one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultEventRetry is the retry policy used when none is configured
+var DefaultEventRetry = EventRetry{Attempts: 5, Backoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// DefaultEventBuffer bounds how many events wait to be written
+const DefaultEventBuffer = 10000
+
+// ErrEventBufferFull is returned for an event published while the buffer
+// of events waiting to be written is full
+var ErrEventBufferFull = errors.New("event buffer full")
+
+// delay returns the wait before the retry following a failed attempt,
+// counted from 1
+func (r EventRetry) delay(attempt int) time.Duration {
+	delay := r.Backoff
+	for i := 1; i < attempt && delay < r.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if r.MaxBackoff > 0 && delay > r.MaxBackoff {
+		delay = r.MaxBackoff
+	}
+	return delay
+}
+
+// eventPublisher writes each analysis's events in the order they were
+// published without holding up the analysis. Every analysis has a lane
+// queuing its events, written one at a time and retried with backoff, so a
+// retried event is never overtaken by a later one. Events are numbered
+// within their analysis as they are queued; a write that failed but still
+// reached Kafka is retried and delivered twice, under the same number,
+// for consumers to drop.
+//
+// With a Kafka health check, writes wait while Kafka is unreachable rather
+// than spending their attempts, so events are held until it recovers. At
+// most buffer events wait at a time; later ones are dropped.
+type eventPublisher struct {
+	writer EventWriter
+	retry  EventRetry
+	health *KafkaHealth
+	buffer int
+	logger *logrus.Logger
+
+	mu     sync.Mutex
+	lanes  map[string]*eventLane
+	queued int
+	wg     sync.WaitGroup
+}
+
+// eventLane holds an analysis's events waiting to be written
+type eventLane struct {
+	sequence uint64
+	queue    []kafka.Message
+	// writing is set while a goroutine drains the queue
+	writing bool
+	// finished is set once the analysis publishes no more events
+	finished bool
+}
+
+func newEventPublisher(writer EventWriter, retry EventRetry, logger *logrus.Logger) *eventPublisher {
+	return &eventPublisher{writer: writer, retry: retry, buffer: DefaultEventBuffer, logger: logger, lanes: make(map[string]*eventLane)}
+}
+
+// publish queues the message encode builds with the next sequence number
+// of the analysis. Nothing is queued, and the number isn't used, when
+// encoding fails or the buffer is full.
+func (p *eventPublisher) publish(analysisID string, encode func(sequence uint64) (kafka.Message, error)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.buffer > 0 && p.queued >= p.buffer {
+		return fmt.Errorf("%w: %d events waiting", ErrEventBufferFull, p.queued)
+	}
+	lane, ok := p.lanes[analysisID]
+	if !ok {
+		lane = &eventLane{}
+		p.lanes[analysisID] = lane
+	}
+	msg, err := encode(lane.sequence + 1)
+	if err != nil {
+		return err
+	}
+	lane.sequence++
+	lane.queue = append(lane.queue, msg)
+	p.queued++
+	if !lane.writing {
+		lane.writing = true
+		p.wg.Add(1)
+		go p.drain(analysisID, lane)
+	}
+	return nil
+}
+
+// finish releases the lane of an analysis that publishes no more events
+// once its queue is written
+func (p *eventPublisher) finish(analysisID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if lane, ok := p.lanes[analysisID]; ok {
+		lane.finished = true
+		if !lane.writing {
+			delete(p.lanes, analysisID)
+		}
+	}
+}
+
+// flush waits until every queued event is written or dropped, or ctx is done
+func (p *eventPublisher) flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drain writes a lane's events in order until its queue is empty
+func (p *eventPublisher) drain(analysisID string, lane *eventLane) {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		if len(lane.queue) == 0 {
+			lane.writing = false
+			if lane.finished {
+				delete(p.lanes, analysisID)
+			}
+			p.mu.Unlock()
+			return
+		}
+		msg := lane.queue[0]
+		p.mu.Unlock()
+
+		p.write(analysisID, msg)
+
+		p.mu.Lock()
+		lane.queue = lane.queue[1:]
+		p.queued--
+		p.mu.Unlock()
+	}
+}
+
+// write writes one message, retrying failures with backoff. A message
+// still failing after the last attempt is dropped so the analysis's later
+// events aren't held back for good. Attempts wait while Kafka is known
+// to be unreachable.
+func (p *eventPublisher) write(analysisID string, msg kafka.Message) {
+	attempts := max(p.retry.Attempts, 1)
+	for attempt := 1; ; attempt++ {
+		if p.health != nil {
+			p.health.wait()
+		}
+		err := p.writer.WriteMessages(context.Background(), msg)
+		if err == nil {
+			return
+		}
+		fields := logrus.Fields{
+			"analysis_id": analysisID,
+			"attempt":     attempt,
+		}
+		if attempt >= attempts {
+			p.logger.WithFields(fields).WithError(err).Error("Failed to publish event, dropping it")
+			return
+		}
+		delay := p.retry.delay(attempt)
+		p.logger.WithFields(fields).WithError(err).Warnf("Failed to publish event, retrying in %s", delay)
+		time.Sleep(delay)
+	}
+}
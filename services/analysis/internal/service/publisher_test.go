@@ -0,0 +1,270 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/events"
+)
+
+// flakyWriter fails writes as fail decides. A failure may come after the
+// message was delivered, like a broker acknowledgement lost on the way back.
+type flakyWriter struct {
+	mu        sync.Mutex
+	attempts  map[string]int
+	delivered []kafka.Message
+	fail      func(event *events.Event, attempt int) (delivered bool, err error)
+}
+
+func newFlakyWriter(fail func(event *events.Event, attempt int) (bool, error)) *flakyWriter {
+	return &flakyWriter{attempts: make(map[string]int), fail: fail}
+}
+
+func (w *flakyWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, msg := range msgs {
+		event, err := events.JSONCodec{}.Unmarshal(msg.Value)
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%s/%d", event.AnalysisID, event.Sequence)
+		w.attempts[key]++
+		delivered, err := w.fail(event, w.attempts[key])
+		if delivered || err == nil {
+			w.delivered = append(w.delivered, msg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// events returns the delivered events of each analysis in delivery order
+func (w *flakyWriter) events(t *testing.T) map[string][]*events.Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	byAnalysis := make(map[string][]*events.Event)
+	for _, msg := range w.delivered {
+		event, err := events.JSONCodec{}.Unmarshal(msg.Value)
+		require.NoError(t, err)
+		assert.Equal(t, event.AnalysisID, string(msg.Key))
+		byAnalysis[event.AnalysisID] = append(byAnalysis[event.AnalysisID], event)
+	}
+	return byAnalysis
+}
+
+func newPublishingService(t *testing.T, writer service.EventWriter, retry service.EventRetry, analyses int) (*service.AnalysisService, []string) {
+	mockProjectRepo := new(MockProjectRepository)
+	mockMetricsRepo := new(MockMetricsRepository)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), mockMetricsRepo, newTestRedis(t), nil, logger)
+	analysisService.SetEventWriter(writer)
+	analysisService.SetEventRetry(retry)
+
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	var jobIDs []string
+	for i := 0; i < analyses; i++ {
+		projectID := fmt.Sprintf("published-%d", i)
+		mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+		mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return([]*repository.ProjectFile{
+			{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+		}, nil)
+
+		job, err := analysisService.StartAnalysis(context.Background(), projectID)
+		require.NoError(t, err)
+		jobIDs = append(jobIDs, job.ID)
+	}
+	return analysisService, jobIDs
+}
+
+func TestAnalysisService_PublishesEventsInOrder(t *testing.T) {
+	errTransient := errors.New("leader not available")
+	writer := newFlakyWriter(func(event *events.Event, attempt int) (bool, error) {
+		switch {
+		case event.Type == events.TypeAnalysisStarted && attempt == 1:
+			// Delivered, but the acknowledgement was lost
+			return true, errTransient
+		case attempt <= 2:
+			return false, errTransient
+		}
+		return false, nil
+	})
+	retry := service.EventRetry{Attempts: 5, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	analysisService, jobIDs := newPublishingService(t, writer, retry, 3)
+
+	assert.Eventually(t, func() bool {
+		delivered := writer.events(t)
+		for _, id := range jobIDs {
+			published := delivered[id]
+			if len(published) == 0 || published[len(published)-1].Type != events.TypeAnalysisCompleted {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, analysisService.FlushEvents(ctx))
+
+	dedupe := events.NewDeduplicator(0)
+	for id, delivered := range writer.events(t) {
+		var types []string
+		var sequences []uint64
+		for i, event := range delivered {
+			if i > 0 {
+				assert.GreaterOrEqual(t, event.Sequence, delivered[i-1].Sequence, "events of %s reordered", id)
+			}
+			if dedupe.Duplicate(event) {
+				continue
+			}
+			types = append(types, event.Type)
+			sequences = append(sequences, event.Sequence)
+		}
+		assert.Len(t, delivered, 3, "the started event of %s is delivered twice", id)
+		assert.Equal(t, []string{events.TypeAnalysisStarted, events.TypeAnalysisCompleted}, types, id)
+		assert.Equal(t, []uint64{1, 2}, sequences, id)
+	}
+}
+
+func TestAnalysisService_DropsEventsAfterRetries(t *testing.T) {
+	writer := newFlakyWriter(func(event *events.Event, attempt int) (bool, error) {
+		if event.Type == events.TypeAnalysisStarted {
+			return false, errors.New("broker down")
+		}
+		return false, nil
+	})
+	retry := service.EventRetry{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	_, jobIDs := newPublishingService(t, writer, retry, 1)
+
+	assert.Eventually(t, func() bool {
+		return len(writer.events(t)[jobIDs[0]]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	event := writer.events(t)[jobIDs[0]][0]
+	assert.Equal(t, events.TypeAnalysisCompleted, event.Type, "later events aren't held back by a dropped one")
+	assert.Equal(t, uint64(2), event.Sequence)
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+	assert.Equal(t, 3, writer.attempts[jobIDs[0]+"/1"])
+}
+
+func TestAnalysisService_HoldsEventsWhileKafkaIsDown(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	health := service.NewKafkaHealth(func(context.Context) error {
+		if down.Load() {
+			return service.ErrKafkaUnavailable
+		}
+		return nil
+	}, time.Second)
+	require.Error(t, health.Check(context.Background()))
+
+	writer := newFlakyWriter(func(*events.Event, int) (bool, error) { return false, nil })
+	mockProjectRepo := new(MockProjectRepository)
+	mockMetricsRepo := new(MockMetricsRepository)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), mockMetricsRepo, newTestRedis(t), nil, logger)
+	analysisService.SetKafkaHealth(health)
+	analysisService.SetEventWriter(writer)
+	analysisService.SetEventBuffer(2)
+
+	saved := make(chan struct{}, 2)
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { saved <- struct{}{} }).Return(nil)
+	var jobIDs []string
+	for _, projectID := range []string{"held-project", "dropped-project"} {
+		mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+		mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return([]*repository.ProjectFile{
+			{Path: "main.go", Content: []byte("package main\n\nfunc main() {}\n")},
+		}, nil)
+
+		// The first analysis fills the buffer, dropping the second's events
+		job, err := analysisService.StartAnalysis(context.Background(), projectID)
+		require.NoError(t, err)
+		jobIDs = append(jobIDs, job.ID)
+		select {
+		case <-saved:
+		case <-time.After(5 * time.Second):
+			t.Fatal("analysis results were not saved")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, analysisService.FlushEvents(ctx), context.DeadlineExceeded, "events are held while Kafka is down")
+	assert.Empty(t, writer.events(t))
+
+	down.Store(false)
+	require.NoError(t, health.Check(context.Background()))
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, analysisService.FlushEvents(ctx))
+
+	var types []string
+	delivered := writer.events(t)
+	for _, event := range delivered[jobIDs[0]] {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{events.TypeAnalysisStarted, events.TypeAnalysisCompleted}, types, "held events are replayed in order")
+	assert.Empty(t, delivered[jobIDs[1]])
+}
+
+func TestAnalysisService_PublishesProgressEvents(t *testing.T) {
+	writer := newFlakyWriter(func(*events.Event, int) (bool, error) { return false, nil })
+	mockProjectRepo := new(MockProjectRepository)
+	mockMetricsRepo := new(MockMetricsRepository)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), mockMetricsRepo, newTestRedis(t), nil, logger)
+	analysisService.SetEventWriter(writer)
+
+	var files []*repository.ProjectFile
+	for i := 0; i < 20; i++ {
+		files = append(files, &repository.ProjectFile{
+			Path:    fmt.Sprintf("file%d.go", i),
+			Content: []byte(fmt.Sprintf("package main\n\nfunc f%d() {}\n", i)),
+		})
+	}
+	mockProjectRepo.On("GetByID", mock.Anything, "progress-project").Return(&repository.Project{ID: "progress-project"}, nil)
+	mockProjectRepo.On("GetProjectFiles", mock.Anything, "progress-project").Return(files, nil)
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	job, err := analysisService.StartAnalysis(context.Background(), "progress-project")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		published := writer.events(t)[job.ID]
+		return len(published) > 0 && published[len(published)-1].Type == events.TypeAnalysisCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var analyzed []int
+	for _, event := range writer.events(t)[job.ID] {
+		if progress, ok := event.Data.(events.AnalysisProgress); ok {
+			assert.Equal(t, events.TypeAnalysisProgress, event.Type)
+			assert.Equal(t, "progress-project", progress.ProjectID)
+			assert.Equal(t, 20, progress.TotalFiles)
+			analyzed = append(analyzed, progress.FilesAnalyzed)
+		}
+	}
+	assert.Equal(t, []int{2, 4, 6, 8, 10, 12, 14, 16, 18}, analyzed, "one event per tenth of the files, the last left to the completion event")
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+)
+
+// DefaultMaxConcurrentAnalyses bounds how many analyses run at once; the
+// rest wait in the queue
+const DefaultMaxConcurrentAnalyses = 4
+
+// ErrUnknownPriority is returned for a priority name other than low, normal or high
+var ErrUnknownPriority = errors.New("unknown priority")
+
+// Priority orders queued analyses. Interactive single-project runs use the
+// default normal priority; bulk batches should use low so they don't hold
+// up interactive requests.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// ParsePriority returns the priority with the given name; empty is normal
+func ParsePriority(name string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "low":
+		return PriorityLow, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	return PriorityNormal, fmt.Errorf("%w: %s", ErrUnknownPriority, name)
+}
+
+// String returns the priority's name
+func (p Priority) String() string {
+	switch {
+	case p < PriorityNormal:
+		return "low"
+	case p > PriorityNormal:
+		return "high"
+	}
+	return "normal"
+}
+
+// MarshalText encodes the priority by name
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText decodes a priority name
+func (p *Priority) UnmarshalText(text []byte) error {
+	priority, err := ParsePriority(string(text))
+	if err != nil {
+		return err
+	}
+	*p = priority
+	return nil
+}
+
+// queuedAnalysis is an analysis waiting for a free slot
+type queuedAnalysis struct {
+	ctx     context.Context
+	job     *AnalysisJob
+	project *repository.Project
+	seq     uint64 // submission order, so equal priorities run first-come first-served
+	done    chan struct{}
+}
+
+// analysisHeap orders queued analyses by priority, then submission order
+type analysisHeap []*queuedAnalysis
+
+func (h analysisHeap) Len() int { return len(h) }
+func (h analysisHeap) Less(i, j int) bool {
+	if h[i].job.Priority != h[j].job.Priority {
+		return h[i].job.Priority > h[j].job.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h analysisHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *analysisHeap) Push(x interface{}) { *h = append(*h, x.(*queuedAnalysis)) }
+func (h *analysisHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// analysisQueue runs at most maxConcurrent analyses at a time, starting the
+// highest-priority waiting analysis whenever one finishes. Running analyses
+// aren't interrupted; priority only decides which waiting one goes next.
+type analysisQueue struct {
+	run func(ctx context.Context, job *AnalysisJob, project *repository.Project)
+
+	mu            sync.Mutex
+	waiting       analysisHeap
+	running       int
+	maxConcurrent int // 0 means no limit
+	seq           uint64
+}
+
+func newAnalysisQueue(maxConcurrent int, run func(ctx context.Context, job *AnalysisJob, project *repository.Project)) *analysisQueue {
+	return &analysisQueue{run: run, maxConcurrent: maxConcurrent}
+}
+
+// submit queues an analysis and starts it right away if a slot is free. The
+// returned channel is closed once the analysis has run.
+func (q *analysisQueue) submit(ctx context.Context, job *AnalysisJob, project *repository.Project) <-chan struct{} {
+	queued := &queuedAnalysis{ctx: ctx, job: job, project: project, done: make(chan struct{})}
+
+	q.mu.Lock()
+	q.seq++
+	queued.seq = q.seq
+	heap.Push(&q.waiting, queued)
+	q.mu.Unlock()
+
+	q.dispatch()
+	return queued.done
+}
+
+// setMaxConcurrent changes the limit, starting waiting analyses it makes room for
+func (q *analysisQueue) setMaxConcurrent(maxConcurrent int) {
+	q.mu.Lock()
+	q.maxConcurrent = maxConcurrent
+	q.mu.Unlock()
+
+	q.dispatch()
+}
+
+// dispatch starts waiting analyses while slots are free
+func (q *analysisQueue) dispatch() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.waiting.Len() > 0 && (q.maxConcurrent <= 0 || q.running < q.maxConcurrent) {
+		next := heap.Pop(&q.waiting).(*queuedAnalysis)
+		q.running++
+		go func() {
+			defer q.finished()
+			defer close(next.done)
+			q.run(next.ctx, next.job, next.project)
+		}()
+	}
+}
+
+// finished frees the slot of a completed analysis
+func (q *analysisQueue) finished() {
+	q.mu.Lock()
+	q.running--
+	q.mu.Unlock()
+
+	q.dispatch()
+}
@@ -0,0 +1,170 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+)
+
+func TestAnalysisService_PriorityQueue(t *testing.T) {
+	started := make(chan struct{})
+	registerTestAnalyzer(t, analyzer.LanguageCSharp, blockingAnalyzer{started: started})
+
+	mockProjectRepo := new(MockProjectRepository)
+	mockMetricsRepo := new(MockMetricsRepository)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	analysisService := service.NewAnalysisService(mockProjectRepo, newMemoryAnalysisRepository(), mockMetricsRepo, newTestRedis(t), nil, logger)
+	analysisService.SetMaxConcurrentAnalyses(1)
+
+	// Runs start by listing the project's files, which records their order
+	runs := make(chan string, 10)
+	projects := []string{"blocker", "bulk-1", "bulk-2", "cancelled-bulk", "normal", "urgent"}
+	for _, projectID := range projects {
+		projectID := projectID
+		mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+		files := []*repository.ProjectFile{}
+		if projectID == "blocker" {
+			files = []*repository.ProjectFile{{Path: "Program.cs", Content: []byte("class Program {}\n")}}
+		}
+		mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).
+			Run(func(args mock.Arguments) { runs <- projectID }).
+			Return(files, nil)
+	}
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	ctx := context.Background()
+	blocker, err := analysisService.StartAnalysis(ctx, "blocker")
+	require.NoError(t, err)
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocking analysis did not start")
+	}
+	assert.Equal(t, "blocker", <-runs)
+
+	start := func(projectID string, priority service.Priority) *service.AnalysisJob {
+		job, err := analysisService.StartAnalysisWithOptions(ctx, projectID, service.AnalysisOptions{Priority: priority})
+		require.NoError(t, err)
+		assert.Equal(t, service.StatusPending, job.Status)
+		return job
+	}
+	start("bulk-1", service.PriorityLow)
+	cancelled := start("cancelled-bulk", service.PriorityLow)
+	start("bulk-2", service.PriorityLow)
+	start("normal", service.PriorityNormal)
+	urgent := start("urgent", service.PriorityHigh)
+	assert.Equal(t, service.PriorityHigh, urgent.Priority)
+
+	// Nothing else starts while the slot is taken
+	assert.Never(t, func() bool { return len(runs) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+
+	require.NoError(t, analysisService.CancelAnalysis(ctx, cancelled.ID))
+	require.NoError(t, analysisService.CancelAnalysis(ctx, blocker.ID))
+
+	var order []string
+	for len(order) < 4 {
+		select {
+		case projectID := <-runs:
+			order = append(order, projectID)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("queued analyses did not run, got %v", order)
+		}
+	}
+	assert.Equal(t, []string{"urgent", "normal", "bulk-1", "bulk-2"}, order)
+	assert.Never(t, func() bool { return len(runs) > 0 }, 100*time.Millisecond, 10*time.Millisecond, "cancelled analysis ran")
+
+	stored, err := analysisService.GetAnalysis(ctx, cancelled.ID)
+	require.NoError(t, err)
+	assert.Equal(t, service.StatusCancelled, stored.Status)
+}
+
+func TestPriority_Text(t *testing.T) {
+	var opts service.AnalysisOptions
+	require.NoError(t, json.Unmarshal([]byte(`{"priority":"high"}`), &opts))
+	assert.Equal(t, service.PriorityHigh, opts.Priority)
+
+	data, err := json.Marshal(service.AnalysisOptions{Priority: service.PriorityLow})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"languages":null,"priority":"low"}`, string(data))
+
+	_, err = service.ParsePriority("urgent")
+	assert.ErrorIs(t, err, service.ErrUnknownPriority)
+	assert.Error(t, json.Unmarshal([]byte(`{"priority":"urgent"}`), &opts))
+}
+
+// countingAnalyzer records the most files analyzed at once
+type countingAnalyzer struct {
+	current, peak *atomic.Int32
+}
+
+func (a countingAnalyzer) Analyze(ctx context.Context, content []byte) (*analyzer.AnalysisResult, error) {
+	current := a.current.Add(1)
+	defer a.current.Add(-1)
+	for peak := a.peak.Load(); current > peak && !a.peak.CompareAndSwap(peak, current); peak = a.peak.Load() {
+	}
+	time.Sleep(20 * time.Millisecond)
+	return &analyzer.AnalysisResult{Language: analyzer.LanguageCSharp}, nil
+}
+
+func (countingAnalyzer) Language() analyzer.Language {
+	return analyzer.LanguageCSharp
+}
+
+func TestAnalysisService_MaxConcurrentFiles(t *testing.T) {
+	var current, peak atomic.Int32
+	registerTestAnalyzer(t, analyzer.LanguageCSharp, countingAnalyzer{current: &current, peak: &peak})
+
+	mockProjectRepo := new(MockProjectRepository)
+	analysisRepo := newMemoryAnalysisRepository()
+	mockMetricsRepo := new(MockMetricsRepository)
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	analysisService := service.NewAnalysisService(mockProjectRepo, analysisRepo, mockMetricsRepo, newTestRedis(t), nil, logger)
+	analysisService.SetMaxConcurrentAnalyses(0)
+	analysisService.SetMaxConcurrentFiles(3)
+	// Skip the result cache so every file reaches the analyzer
+	analysisService.SetResultCache(nil)
+	mockMetricsRepo.On("SaveAnalysisResults", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	var jobs []*service.AnalysisJob
+	for i := 0; i < 4; i++ {
+		projectID := fmt.Sprintf("concurrent-%d", i)
+		var files []*repository.ProjectFile
+		for j := 0; j < 6; j++ {
+			files = append(files, &repository.ProjectFile{
+				Path:    fmt.Sprintf("File%d.cs", j),
+				Content: []byte(fmt.Sprintf("class File%d%d {}\n", i, j)),
+			})
+		}
+		mockProjectRepo.On("GetByID", mock.Anything, projectID).Return(&repository.Project{ID: projectID}, nil)
+		mockProjectRepo.On("GetProjectFiles", mock.Anything, projectID).Return(files, nil)
+
+		job, err := analysisService.StartAnalysis(context.Background(), projectID)
+		require.NoError(t, err)
+		jobs = append(jobs, job)
+	}
+
+	for _, job := range jobs {
+		assert.Eventually(t, func() bool {
+			stored, err := analysisRepo.GetJob(context.Background(), job.ID)
+			return err == nil && stored.Status == service.StatusCompleted
+		}, 10*time.Second, 10*time.Millisecond)
+	}
+	assert.Equal(t, int32(3), peak.Load(), "files analyzed at once across all analyses")
+}
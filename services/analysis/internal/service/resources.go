@@ -0,0 +1,138 @@
+package service
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// resourceSampleInterval is how often a running analysis samples heap and
+// goroutine usage
+var resourceSampleInterval = 250 * time.Millisecond
+
+// ResourceUsage records how expensive an analysis run was. CPU time and
+// memory are measured for the whole process, so when ConcurrentAnalyses is
+// non-zero they include the work of analyses that overlapped this one.
+type ResourceUsage struct {
+	DurationMs     int64   `json:"duration_ms"`
+	CPUTimeMs      int64   `json:"cpu_time_ms"`
+	FilesPerSecond float64 `json:"files_per_second"`
+	PeakGoroutines int     `json:"peak_goroutines"`
+	// PeakHeapBytes is the largest live heap seen during the run; HeapGrowthBytes
+	// is how far it rose above the heap in use when the run started
+	PeakHeapBytes   uint64 `json:"peak_heap_bytes"`
+	HeapGrowthBytes uint64 `json:"heap_growth_bytes"`
+	// AllocatedBytes is the total allocated during the run, including memory
+	// already freed
+	AllocatedBytes uint64 `json:"allocated_bytes"`
+	// ConcurrentAnalyses is the most other analyses running at the same time
+	ConcurrentAnalyses int `json:"concurrent_analyses"`
+}
+
+// resourceMeter samples process resource usage while an analysis runs
+type resourceMeter struct {
+	start      time.Time
+	startCPU   time.Duration
+	startHeap  uint64
+	startAlloc uint64
+	concurrent func() int
+
+	mu             sync.Mutex
+	peakHeap       uint64
+	peakGoroutines int
+	peakConcurrent int
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// startResourceMeter starts sampling; concurrent reports how many other
+// analyses are running
+func startResourceMeter(concurrent func() int) *resourceMeter {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	m := &resourceMeter{
+		start:      time.Now(),
+		startCPU:   processCPUTime(),
+		startHeap:  stats.HeapAlloc,
+		startAlloc: stats.TotalAlloc,
+		concurrent: concurrent,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	m.record(&stats)
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(resourceSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.sample()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+	return m
+}
+
+func (m *resourceMeter) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	m.record(&stats)
+}
+
+func (m *resourceMeter) record(stats *runtime.MemStats) {
+	goroutines := runtime.NumGoroutine()
+	concurrent := m.concurrent()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stats.HeapAlloc > m.peakHeap {
+		m.peakHeap = stats.HeapAlloc
+	}
+	if goroutines > m.peakGoroutines {
+		m.peakGoroutines = goroutines
+	}
+	if concurrent > m.peakConcurrent {
+		m.peakConcurrent = concurrent
+	}
+}
+
+// stopSampling ends the sampling goroutine; it's safe to call repeatedly
+func (m *resourceMeter) stopSampling() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+		<-m.done
+	})
+}
+
+// Stop ends sampling and reports the usage of a run that processed files
+func (m *resourceMeter) Stop(files int) *ResourceUsage {
+	m.stopSampling()
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	m.record(&stats)
+
+	elapsed := time.Since(m.start)
+	usage := &ResourceUsage{
+		DurationMs:         elapsed.Milliseconds(),
+		CPUTimeMs:          (processCPUTime() - m.startCPU).Milliseconds(),
+		PeakGoroutines:     m.peakGoroutines,
+		PeakHeapBytes:      m.peakHeap,
+		AllocatedBytes:     stats.TotalAlloc - m.startAlloc,
+		ConcurrentAnalyses: m.peakConcurrent,
+	}
+	if m.peakHeap > m.startHeap {
+		usage.HeapGrowthBytes = m.peakHeap - m.startHeap
+	}
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		usage.FilesPerSecond = float64(files) / seconds
+	}
+	return usage
+}
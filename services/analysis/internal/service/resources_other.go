@@ -0,0 +1,10 @@
+//go:build !unix
+
+package service
+
+import "time"
+
+// processCPUTime isn't measured on this platform
+func processCPUTime() time.Duration {
+	return 0
+}
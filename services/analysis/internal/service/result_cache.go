@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultResultCacheTTL is how long content-addressed file results are kept
+const defaultResultCacheTTL = 7 * 24 * time.Hour
+
+// ResultCache stores file analysis results keyed by file content so
+// byte-identical files are only analyzed once
+type ResultCache interface {
+	Get(ctx context.Context, language string, content []byte) (*FileAnalysisResult, bool)
+	Set(ctx context.Context, language string, content []byte, result *FileAnalysisResult) error
+}
+
+// RedisResultCache is a ResultCache backed by Redis. Keys include the
+// analyzer version so a logic change never serves stale results.
+type RedisResultCache struct {
+	client  *redis.Client
+	version string
+	ttl     time.Duration
+}
+
+// NewRedisResultCache creates a Redis-backed result cache for the given analyzer version
+func NewRedisResultCache(client *redis.Client, version string, ttl time.Duration) *RedisResultCache {
+	if ttl <= 0 {
+		ttl = defaultResultCacheTTL
+	}
+	return &RedisResultCache{
+		client:  client,
+		version: version,
+		ttl:     ttl,
+	}
+}
+
+// WithVersion returns a cache sharing the client and TTL under another version
+func (c *RedisResultCache) WithVersion(version string) *RedisResultCache {
+	return NewRedisResultCache(c.client, version, c.ttl)
+}
+
+// Get returns the cached result for content, if any
+func (c *RedisResultCache) Get(ctx context.Context, language string, content []byte) (*FileAnalysisResult, bool) {
+	data, err := c.client.Get(ctx, c.key(language, content)).Bytes()
+	if err != nil {
+		return nil, false
+	}
+
+	var result FileAnalysisResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, false
+	}
+	return &result, true
+}
+
+// Set stores result for content
+func (c *RedisResultCache) Set(ctx context.Context, language string, content []byte, result *FileAnalysisResult) error {
+	if result == nil {
+		return errors.New("result is nil")
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+	return c.client.Set(ctx, c.key(language, content), data, c.ttl).Err()
+}
+
+// key builds "analysis:result:<version>:<language>:<sha256 of content>"
+func (c *RedisResultCache) key(language string, content []byte) string {
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf("analysis:result:%s:%s:%s", c.version, language, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+)
+
+const cachedGoSource = `package sample
+
+func Add(a, b int) int {
+	return a + b
+}
+`
+
+func newCacheTestService(t *testing.T) (*AnalysisService, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	return NewAnalysisService(nil, nil, nil, client, nil, logger), client
+}
+
+func TestRedisResultCache_HitAndMiss(t *testing.T) {
+	_, client := newCacheTestService(t)
+	cache := NewRedisResultCache(client, "v1", 0)
+	ctx := context.Background()
+
+	_, ok := cache.Get(ctx, "go", []byte(cachedGoSource))
+	assert.False(t, ok, "empty cache misses")
+
+	require.NoError(t, cache.Set(ctx, "go", []byte(cachedGoSource), &FileAnalysisResult{FilePath: "a.go", Language: "go", LOC: 5}))
+
+	cached, ok := cache.Get(ctx, "go", []byte(cachedGoSource))
+	require.True(t, ok)
+	assert.Equal(t, 5, cached.LOC)
+
+	_, ok = cache.Get(ctx, "go", []byte(cachedGoSource+"\n// changed\n"))
+	assert.False(t, ok, "different content misses")
+
+	_, ok = cache.Get(ctx, "python", []byte(cachedGoSource))
+	assert.False(t, ok, "different language misses")
+}
+
+func TestRedisResultCache_VersionInvalidation(t *testing.T) {
+	_, client := newCacheTestService(t)
+	ctx := context.Background()
+
+	v1 := NewRedisResultCache(client, "v1", 0)
+	require.NoError(t, v1.Set(ctx, "go", []byte(cachedGoSource), &FileAnalysisResult{LOC: 5}))
+
+	v2 := NewRedisResultCache(client, "v2", 0)
+	_, ok := v2.Get(ctx, "go", []byte(cachedGoSource))
+	assert.False(t, ok, "results from another analyzer version are not reused")
+
+	_, ok = v1.Get(ctx, "go", []byte(cachedGoSource))
+	assert.True(t, ok)
+}
+
+func TestAnalysisService_AnalyzeFileUsesResultCache(t *testing.T) {
+	svc, client := newCacheTestService(t)
+	ctx := context.Background()
+	cache := NewRedisResultCache(client, ResultVersion(), 0)
+
+	first := svc.analyzeFile(ctx, &repository.ProjectFile{Path: "a/sample.go", Content: []byte(cachedGoSource)}, nil)
+	require.Empty(t, first.Error)
+
+	// The fresh result was stored under the content hash
+	stored, ok := cache.Get(ctx, "go", []byte(cachedGoSource))
+	require.True(t, ok)
+	assert.Equal(t, first.LOC, stored.LOC)
+
+	// Overwrite the entry with a sentinel so a hit is distinguishable from re-analysis
+	require.NoError(t, cache.Set(ctx, "go", []byte(cachedGoSource), &FileAnalysisResult{Language: "go", LOC: 999}))
+
+	second := svc.analyzeFile(ctx, &repository.ProjectFile{Path: "b/copy.go", Content: []byte(cachedGoSource)}, nil)
+	assert.Equal(t, 999, second.LOC, "identical content is served from cache")
+	assert.Equal(t, "b/copy.go", second.FilePath, "cached results carry the current path")
+
+	// Without a cache the file is analyzed again
+	svc.SetResultCache(nil)
+	third := svc.analyzeFile(ctx, &repository.ProjectFile{Path: "b/copy.go", Content: []byte(cachedGoSource)}, nil)
+	assert.Equal(t, first.LOC, third.LOC)
+}
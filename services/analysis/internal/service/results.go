@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// AnalysisResults is an analysis job with the results of its files
+type AnalysisResults struct {
+	Analysis *AnalysisJob          `json:"analysis"`
+	Files    []*FileAnalysisResult `json:"files"`
+}
+
+// GetAnalysisResults returns an analysis with its per-file results. Results
+// are saved when an analysis finishes, so pending and running analyses are
+// returned with no files.
+func (s *AnalysisService) GetAnalysisResults(ctx context.Context, analysisID string) (*AnalysisResults, error) {
+	job, err := s.GetAnalysis(ctx, analysisID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analysis: %w", err)
+	}
+	if job == nil {
+		return nil, fmt.Errorf("%w: %s", ErrAnalysisNotFound, analysisID)
+	}
+
+	files, err := s.metricsRepo.GetAnalysisResults(ctx, analysisID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analysis results: %w", err)
+	}
+	if files == nil {
+		files = []*FileAnalysisResult{}
+	}
+
+	return &AnalysisResults{Analysis: job, Files: files}, nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+var (
+	// ErrEmptySnippet is returned when a snippet has no content
+	ErrEmptySnippet = errors.New("snippet is empty")
+	// ErrSnippetTooLarge is returned when a snippet exceeds MaxSnippetSize
+	ErrSnippetTooLarge = errors.New("snippet too large")
+	// ErrBinarySnippet is returned when a snippet's content isn't text
+	ErrBinarySnippet = errors.New("snippet is binary")
+	// ErrUnsupportedLanguage is returned when a snippet's language can't be
+	// detected or has no analyzer
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+)
+
+// Snippet is code analyzed on its own, outside of any project. Filename
+// and Language are optional hints; the language is detected from the
+// filename and content when not given.
+type Snippet struct {
+	Content  []byte
+	Filename string
+	Language string
+}
+
+// SnippetResult is the outcome of analyzing a snippet
+type SnippetResult struct {
+	Filename  string               `json:"filename,omitempty"`
+	Language  string               `json:"language"`
+	Metrics   *metrics.FileMetrics `json:"metrics"`
+	Issues    []metrics.Issue      `json:"issues"`
+	Functions []FunctionComplexity `json:"functions"`
+	// ParseErrors lists syntax errors; metrics then cover what parsed
+	ParseErrors []string `json:"parse_errors,omitempty"`
+	// Truncated is set when the analyzer ran out of time and the metrics are partial
+	Truncated bool `json:"truncated,omitempty"`
+
+	AnalyzerVersion string `json:"analyzer_version"`
+	MetricsVersion  string `json:"metrics_version"`
+}
+
+// MaxSnippetSize is the largest snippet in bytes AnalyzeSnippet accepts:
+// the file filter's size limit, or the default limit when it has none, as
+// snippets are always analyzed within the request
+func (s *AnalysisService) MaxSnippetSize() int64 {
+	if s.fileFilter.MaxFileSize > 0 {
+		return s.fileFilter.MaxFileSize
+	}
+	return defaultMaxFileSize
+}
+
+// AnalyzeSnippet analyzes a single piece of code synchronously, running the
+// same analyzer, calculator and rules as a project analysis. Nothing is
+// stored.
+func (s *AnalysisService) AnalyzeSnippet(ctx context.Context, snippet Snippet) (*SnippetResult, error) {
+	if len(snippet.Content) == 0 {
+		return nil, ErrEmptySnippet
+	}
+	if size := int64(len(snippet.Content)); size > s.MaxSnippetSize() {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrSnippetTooLarge, size, s.MaxSnippetSize())
+	}
+	if isBinary(snippet.Content) {
+		return nil, ErrBinarySnippet
+	}
+
+	filename := path.Base(cleanFilePath(snippet.Filename))
+	if filename == "." {
+		filename = ""
+	}
+	language := analyzer.DetectLanguage(filename, snippet.Content)
+	if snippet.Language != "" {
+		lang, ok := analyzer.ParseLanguage(snippet.Language)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownLanguage, snippet.Language)
+		}
+		language = lang
+	}
+	if language == analyzer.LanguageUnknown {
+		return nil, fmt.Errorf("%w: could not detect the language, give a filename or language", ErrUnsupportedLanguage)
+	}
+	fileAnalyzer, err := analyzer.GetAnalyzer(language)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLanguage, language)
+	}
+
+	analysisResult, err := analyzeContent(ctx, fileAnalyzer, language, snippet.Content)
+	if err != nil {
+		return nil, fmt.Errorf("snippet analysis failed: %w", err)
+	}
+	fileMetrics := metrics.NewCalculatorWithConfig(s.ruleEngine, s.calculator).CalculateWithSource(analysisResult, snippet.Content)
+
+	result := &SnippetResult{
+		Filename:        filename,
+		Language:        string(language),
+		Metrics:         fileMetrics,
+		Issues:          append(fileMetrics.Issues, metrics.ScanSecrets(filename, snippet.Content)...),
+		Truncated:       analysisResult.Truncated,
+		AnalyzerVersion: analyzer.AnalyzerVersion,
+		MetricsVersion:  metrics.MetricsVersion,
+	}
+	if result.Issues == nil {
+		result.Issues = []metrics.Issue{}
+	}
+	for i := range result.Issues {
+		result.Issues[i].File = filename
+	}
+	for _, parseErr := range analysisResult.Errors {
+		result.ParseErrors = append(result.ParseErrors, parseErr.Message)
+	}
+	result.Functions = functionComplexities(summarizeFunctions(analysisResult))
+
+	s.logger.WithFields(logrus.Fields{
+		"language":   result.Language,
+		"bytes":      len(snippet.Content),
+		"issues":     len(result.Issues),
+		"request_id": utils.RequestIDFromContext(ctx),
+	}).Debug("Snippet analyzed")
+
+	return result, nil
+}
@@ -0,0 +1,97 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+)
+
+const snippetSource = `package snippet
+
+// Classify buckets n
+func Classify(n int) string {
+	switch {
+	case n < 0:
+		return "negative"
+	case n == 0:
+		return "zero"
+	case n < 10:
+		return "small"
+	}
+	return "large"
+}
+
+func Double(n int) int {
+	return n * 2
+}
+`
+
+func TestAnalysisService_AnalyzeSnippet(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	analysisService := service.NewAnalysisService(new(MockProjectRepository), nil, nil, nil, nil, logger)
+	ctx := context.Background()
+
+	t.Run("go snippet", func(t *testing.T) {
+		result, err := analysisService.AnalyzeSnippet(ctx, service.Snippet{
+			Content:  []byte(snippetSource),
+			Filename: "src/classify.go",
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "go", result.Language)
+		assert.Equal(t, "classify.go", result.Filename)
+		require.NotNil(t, result.Metrics)
+		assert.Equal(t, 2, result.Metrics.FunctionCount)
+		assert.Positive(t, result.Metrics.CodeLines)
+		require.Len(t, result.Functions, 2)
+		assert.Equal(t, "Classify", result.Functions[0].Name, "most complex function first")
+		assert.Greater(t, result.Functions[0].Complexity, result.Functions[1].Complexity)
+		assert.NotNil(t, result.Issues)
+		assert.Empty(t, result.ParseErrors)
+	})
+
+	t.Run("language hint", func(t *testing.T) {
+		result, err := analysisService.AnalyzeSnippet(ctx, service.Snippet{Content: []byte(snippetSource), Language: "Go"})
+		require.NoError(t, err)
+		assert.Equal(t, "go", result.Language)
+	})
+
+	t.Run("syntax errors are reported", func(t *testing.T) {
+		result, err := analysisService.AnalyzeSnippet(ctx, service.Snippet{Content: []byte("func broken( {"), Language: "go"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, result.ParseErrors)
+	})
+
+	t.Run("unknown language", func(t *testing.T) {
+		_, err := analysisService.AnalyzeSnippet(ctx, service.Snippet{Content: []byte("some notes\n")})
+		assert.ErrorIs(t, err, service.ErrUnsupportedLanguage)
+
+		_, err = analysisService.AnalyzeSnippet(ctx, service.Snippet{Content: []byte("a = 1\n"), Filename: "notes.rb"})
+		assert.ErrorIs(t, err, service.ErrUnsupportedLanguage)
+
+		_, err = analysisService.AnalyzeSnippet(ctx, service.Snippet{Content: []byte("x = 1\n"), Language: "cobol"})
+		assert.ErrorIs(t, err, service.ErrUnknownLanguage)
+	})
+
+	t.Run("language without analyzer", func(t *testing.T) {
+		_, err := analysisService.AnalyzeSnippet(ctx, service.Snippet{Content: []byte("class Hello {}\n"), Filename: "Hello.java"})
+		assert.ErrorIs(t, err, service.ErrUnsupportedLanguage)
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		_, err := analysisService.AnalyzeSnippet(ctx, service.Snippet{Filename: "main.go"})
+		assert.ErrorIs(t, err, service.ErrEmptySnippet)
+
+		analysisService.SetFileFilter(service.FileFilter{MaxFileSize: 64})
+		defer analysisService.SetFileFilter(service.DefaultFileFilter())
+		_, err = analysisService.AnalyzeSnippet(ctx, service.Snippet{Content: []byte(strings.Repeat("// x\n", 20)), Filename: "main.go"})
+		assert.ErrorIs(t, err, service.ErrSnippetTooLarge)
+	})
+}
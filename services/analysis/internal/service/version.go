@@ -0,0 +1,38 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/analyzer"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+)
+
+// ResultVersion combines the analyzer and metrics versions into the single
+// identifier used to key cached results
+func ResultVersion() string {
+	return fmt.Sprintf("a%s.m%s", analyzer.AnalyzerVersion, metrics.MetricsVersion)
+}
+
+// VersionMismatchWarning returns a warning when two analyses were produced by
+// different analyzer or metrics versions and are therefore not directly
+// comparable, or "" when they match
+func VersionMismatchWarning(base, head *AnalysisJob) string {
+	if base == nil || head == nil {
+		return ""
+	}
+	if base.AnalyzerVersion == head.AnalyzerVersion && base.MetricsVersion == head.MetricsVersion {
+		return ""
+	}
+	return fmt.Sprintf(
+		"analyses were produced by different versions (analyzer %s vs %s, metrics %s vs %s); differences may reflect tool changes rather than code changes",
+		versionOrUnknown(base.AnalyzerVersion), versionOrUnknown(head.AnalyzerVersion),
+		versionOrUnknown(base.MetricsVersion), versionOrUnknown(head.MetricsVersion),
+	)
+}
+
+func versionOrUnknown(version string) string {
+	if version == "" {
+		return "unknown"
+	}
+	return version
+}
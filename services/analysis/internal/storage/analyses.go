@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+// jobColumns are the columns UpdateJob writes
+var jobColumns = []string{
+	"status", "started_at", "completed_at", "error_message", "progress", "total_files",
+	"job_details", "analyzer_version", "metrics_version", "updated_at",
+}
+
+// AnalysisRepository persists analysis jobs in the analyses table
+type AnalysisRepository struct {
+	db *gorm.DB
+}
+
+// NewAnalysisRepository creates an analysis repository on db
+func NewAnalysisRepository(db *gorm.DB) *AnalysisRepository {
+	return &AnalysisRepository{db: db}
+}
+
+// CreateJob inserts a new job
+func (r *AnalysisRepository) CreateJob(ctx context.Context, job *service.AnalysisJob) error {
+	analysis, err := job.ToModel()
+	if err != nil {
+		return err
+	}
+	if err := r.db.WithContext(ctx).Create(analysis).Error; err != nil {
+		return fmt.Errorf("failed to create analysis: %w", err)
+	}
+	return nil
+}
+
+// GetJob returns the job, or nil if there is none with the ID
+func (r *AnalysisRepository) GetJob(ctx context.Context, jobID string) (*service.AnalysisJob, error) {
+	id, err := uuid.Parse(jobID)
+	if err != nil {
+		return nil, nil
+	}
+
+	var analysis models.Analysis
+	err = r.db.WithContext(ctx).Where("id = ?", id).First(&analysis).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analysis: %w", err)
+	}
+	return decodeJob(&analysis)
+}
+
+// UpdateJob writes the job's status, progress and details
+func (r *AnalysisRepository) UpdateJob(ctx context.Context, job *service.AnalysisJob) error {
+	analysis, err := job.ToModel()
+	if err != nil {
+		return err
+	}
+	result := r.db.WithContext(ctx).Model(analysis).Select(jobColumns).Updates(analysis)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update analysis: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", service.ErrAnalysisNotFound, job.ID)
+	}
+	return nil
+}
+
+// GetLatestJob returns the project's most recently started job with the
+// status, or nil if it has none
+func (r *AnalysisRepository) GetLatestJob(ctx context.Context, projectID string, status service.AnalysisStatus) (*service.AnalysisJob, error) {
+	id, err := uuid.Parse(projectID)
+	if err != nil {
+		return nil, nil
+	}
+
+	modelStatus, err := service.ModelStatus(status)
+	if err != nil {
+		return nil, err
+	}
+
+	var analyses []models.Analysis
+	err = r.db.WithContext(ctx).
+		Where("project_id = ? AND status = ?", id, modelStatus).
+		Order("started_at DESC").
+		Limit(1).
+		Find(&analyses).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest analysis: %w", err)
+	}
+	if len(analyses) == 0 {
+		return nil, nil
+	}
+	return decodeJob(&analyses[0])
+}
+
+// decodeJob converts a stored analysis to its job
+func decodeJob(analysis *models.Analysis) (*service.AnalysisJob, error) {
+	job, err := service.JobFromModel(analysis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode analysis %s: %w", analysis.ID, err)
+	}
+	return job, nil
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+)
+
+// newTestDB opens an in-memory database with the tables the repositories use
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := testutil.NewTestDB(t, &models.Project{}, &models.ProjectFile{}, &models.Analysis{}, &analysisFileRow{})
+	// Added by migration 007; the shared model doesn't map it
+	require.NoError(t, db.Exec("ALTER TABLE analyses ADD COLUMN aggregate_metrics TEXT").Error)
+	return db
+}
+
+func newTestJob(projectID string) *service.AnalysisJob {
+	return &service.AnalysisJob{
+		ID:              uuid.NewString(),
+		ProjectID:       projectID,
+		Status:          service.StatusPending,
+		StartedAt:       time.Now().UTC().Truncate(time.Millisecond),
+		Languages:       []string{"go"},
+		AnalyzerVersion: "1.8.0",
+		MetricsVersion:  "1.4.0",
+		MinSeverity:     "major",
+		Priority:        service.PriorityHigh,
+	}
+}
+
+func TestAnalysisRepository_CreateAndGetJob(t *testing.T) {
+	repo := NewAnalysisRepository(newTestDB(t))
+	ctx := context.Background()
+
+	job := newTestJob(uuid.NewString())
+	require.NoError(t, repo.CreateJob(ctx, job))
+
+	got, err := repo.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, job.ID, got.ID)
+	assert.Equal(t, job.ProjectID, got.ProjectID)
+	assert.Equal(t, service.StatusPending, got.Status)
+	assert.True(t, job.StartedAt.Equal(got.StartedAt))
+	assert.Equal(t, []string{"go"}, got.Languages)
+	assert.Equal(t, "major", got.MinSeverity)
+	assert.Equal(t, service.PriorityHigh, got.Priority)
+	assert.Equal(t, "1.8.0", got.AnalyzerVersion)
+	assert.Equal(t, "1.4.0", got.MetricsVersion)
+}
+
+func TestAnalysisRepository_StoresLowercaseStatus(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewAnalysisRepository(db)
+
+	job := newTestJob(uuid.NewString())
+	require.NoError(t, repo.CreateJob(context.Background(), job))
+
+	var analysis models.Analysis
+	require.NoError(t, db.First(&analysis, "id = ?", job.ID).Error)
+	assert.Equal(t, models.AnalysisStatusPending, analysis.Status)
+}
+
+func TestAnalysisRepository_GetJob_Missing(t *testing.T) {
+	repo := NewAnalysisRepository(newTestDB(t))
+
+	for _, id := range []string{uuid.NewString(), "not-a-uuid"} {
+		job, err := repo.GetJob(context.Background(), id)
+		require.NoError(t, err)
+		assert.Nil(t, job, id)
+	}
+}
+
+func TestAnalysisRepository_UpdateJob(t *testing.T) {
+	repo := NewAnalysisRepository(newTestDB(t))
+	ctx := context.Background()
+
+	job := newTestJob(uuid.NewString())
+	require.NoError(t, repo.CreateJob(ctx, job))
+
+	completedAt := time.Now().UTC().Truncate(time.Millisecond)
+	job.Status = service.StatusFailed
+	job.CompletedAt = &completedAt
+	job.Error = "Failed to get project files"
+	job.Progress = 3
+	job.TotalFiles = 7
+	job.Resources = &service.ResourceUsage{DurationMs: 1200, PeakGoroutines: 9}
+	require.NoError(t, repo.UpdateJob(ctx, job))
+
+	got, err := repo.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, service.StatusFailed, got.Status)
+	require.NotNil(t, got.CompletedAt)
+	assert.True(t, completedAt.Equal(*got.CompletedAt))
+	assert.Equal(t, "Failed to get project files", got.Error)
+	assert.Equal(t, 3, got.Progress)
+	assert.Equal(t, 7, got.TotalFiles)
+	assert.Equal(t, job.Resources, got.Resources)
+
+	t.Run("missing job", func(t *testing.T) {
+		err := repo.UpdateJob(ctx, newTestJob(job.ProjectID))
+		assert.ErrorIs(t, err, service.ErrAnalysisNotFound)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		missing := newTestJob(job.ProjectID)
+		missing.ID = "not-a-uuid"
+		assert.Error(t, repo.UpdateJob(ctx, missing))
+	})
+}
+
+func TestAnalysisRepository_GetLatestJob(t *testing.T) {
+	repo := NewAnalysisRepository(newTestDB(t))
+	ctx := context.Background()
+	projectID := uuid.NewString()
+
+	older := newTestJob(projectID)
+	older.Status = service.StatusCompleted
+	older.StartedAt = older.StartedAt.Add(-time.Hour)
+	newer := newTestJob(projectID)
+	newer.Status = service.StatusCompleted
+	running := newTestJob(projectID)
+	running.Status = service.StatusRunning
+	other := newTestJob(uuid.NewString())
+	other.Status = service.StatusCompleted
+	for _, job := range []*service.AnalysisJob{older, newer, running, other} {
+		require.NoError(t, repo.CreateJob(ctx, job))
+	}
+
+	got, err := repo.GetLatestJob(ctx, projectID, service.StatusCompleted)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, newer.ID, got.ID)
+
+	got, err = repo.GetLatestJob(ctx, projectID, service.StatusFailed)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
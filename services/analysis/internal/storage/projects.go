@@ -0,0 +1,165 @@
+// Package storage implements the analysis service's repositories on the
+// shared Postgres database with GORM.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// ProjectRepository reads projects and their source files, and saves files
+type ProjectRepository struct {
+	db *gorm.DB
+	// reads serves GetByID and GetProjectFiles; it is db unless a replica
+	// is given
+	reads *gorm.DB
+	// sources, when set, holds the content of files whose rows have none
+	sources services.SourceStore
+	// inlineLimit is the largest content SaveProjectFile keeps in the row
+	// when there is a source store
+	inlineLimit int64
+}
+
+// NewProjectRepository creates a project repository on db
+func NewProjectRepository(db *gorm.DB) *ProjectRepository {
+	return &ProjectRepository{db: db, reads: db}
+}
+
+// NewProjectRepositoryWithSources creates a project repository that saves
+// files on primary and reads from replica, keeping the content of large
+// files in sources
+func NewProjectRepositoryWithSources(primary, replica *gorm.DB, sources services.SourceStore) *ProjectRepository {
+	return &ProjectRepository{db: primary, reads: replica, sources: sources, inlineLimit: services.DefaultInlineSourceLimit}
+}
+
+// SetInlineLimit sets the largest file content SaveProjectFile keeps in the
+// database when there is a source store. Zero uses
+// services.DefaultInlineSourceLimit.
+func (r *ProjectRepository) SetInlineLimit(limit int64) {
+	if limit <= 0 {
+		limit = services.DefaultInlineSourceLimit
+	}
+	r.inlineLimit = limit
+}
+
+// GetByID returns the project, or nil if there is none with the ID
+func (r *ProjectRepository) GetByID(ctx context.Context, id string) (*repository.Project, error) {
+	projectID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	var project models.Project
+	err = r.reads.WithContext(ctx).Where("id = ?", projectID).First(&project).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+
+	return &repository.Project{
+		ID:                   project.ID.String(),
+		Name:                 project.Name,
+		Language:             project.Language,
+		Repository:           project.Repository,
+		Branch:               project.Branch,
+		EnabledLanguages:     utils.SplitList(project.Settings.EnabledLanguages),
+		MinSeverity:          project.Settings.MinSeverity,
+		NotificationEmails:   utils.SplitList(project.Settings.NotificationEmails),
+		NotificationWebhooks: utils.SplitList(project.Settings.NotificationWebhooks),
+	}, nil
+}
+
+// GetProjectFiles returns the project's source files ordered by path. A
+// file whose row has no content but a size has its content in the source
+// store, when there is one.
+func (r *ProjectRepository) GetProjectFiles(ctx context.Context, projectID string) ([]*repository.ProjectFile, error) {
+	id, err := uuid.Parse(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid project id %q: %w", projectID, err)
+	}
+
+	var rows []models.ProjectFile
+	if err := r.reads.WithContext(ctx).Where("project_id = ?", id).Order("path").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get project files: %w", err)
+	}
+
+	files := make([]*repository.ProjectFile, 0, len(rows))
+	for _, row := range rows {
+		content := row.Content
+		if content == nil && row.Size > 0 && r.sources != nil {
+			content, err = r.sources.Get(ctx, id.String(), row.Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get content of %s: %w", row.Path, err)
+			}
+		}
+		files = append(files, &repository.ProjectFile{
+			Path:       row.Path,
+			Content:    content,
+			Size:       row.Size,
+			ModifiedAt: row.ModifiedAt,
+		})
+	}
+	return files, nil
+}
+
+// SaveProjectFile creates the project's file at file.Path or replaces it.
+// With a source store, content larger than the inline limit is put in the
+// store and the row keeps only its size, which is how GetProjectFiles tells
+// where to read it from.
+func (r *ProjectRepository) SaveProjectFile(ctx context.Context, projectID string, file *repository.ProjectFile) error {
+	id, err := uuid.Parse(projectID)
+	if err != nil {
+		return fmt.Errorf("invalid project id %q: %w", projectID, err)
+	}
+
+	row := models.ProjectFile{
+		ProjectID:  id,
+		Path:       file.Path,
+		Content:    file.Content,
+		Size:       int64(len(file.Content)),
+		ModifiedAt: file.ModifiedAt,
+	}
+	stored := r.sources != nil && row.Size > r.inlineLimit
+	if stored {
+		// The content is in place before the row points to it
+		if err := r.sources.Put(ctx, id.String(), file.Path, file.Content); err != nil {
+			return fmt.Errorf("failed to store content of %s: %w", file.Path, err)
+		}
+		row.Content = nil
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.ProjectFile
+		err := tx.Where("project_id = ? AND path = ?", id, file.Path).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&row).Error
+		}
+		if err != nil {
+			return err
+		}
+		row.BaseModel = existing.BaseModel
+		return tx.Save(&row).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save project file: %w", err)
+	}
+
+	// The file may have been large before
+	if r.sources != nil && !stored {
+		if err := r.sources.Delete(ctx, id.String(), file.Path); err != nil {
+			return fmt.Errorf("failed to remove stored content of %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/repository"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+)
+
+func TestProjectRepository_GetByID(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewProjectRepository(db)
+
+	project := &models.Project{
+		Name:       "sa3d",
+		Language:   "go",
+		Repository: "https://example.com/sa3d.git",
+		Branch:     "develop",
+		CreatedBy:  uuid.New(),
+		Settings: models.ProjectSettings{
+			EnabledLanguages:     "go, python",
+			MinSeverity:          "major",
+			NotificationEmails:   "dev@example.com",
+			NotificationWebhooks: "",
+		},
+	}
+	require.NoError(t, db.Create(project).Error)
+
+	got, err := repo.GetByID(context.Background(), project.ID.String())
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, project.ID.String(), got.ID)
+	assert.Equal(t, "sa3d", got.Name)
+	assert.Equal(t, "develop", got.Branch)
+	assert.Equal(t, []string{"go", "python"}, got.EnabledLanguages)
+	assert.Equal(t, "major", got.MinSeverity)
+	assert.Equal(t, []string{"dev@example.com"}, got.NotificationEmails)
+	assert.Empty(t, got.NotificationWebhooks)
+
+	t.Run("missing project", func(t *testing.T) {
+		for _, id := range []string{uuid.NewString(), "not-a-uuid"} {
+			got, err := repo.GetByID(context.Background(), id)
+			require.NoError(t, err)
+			assert.Nil(t, got, id)
+		}
+	})
+
+	t.Run("deleted project", func(t *testing.T) {
+		require.NoError(t, db.Delete(project).Error)
+		got, err := repo.GetByID(context.Background(), project.ID.String())
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
+
+func TestProjectRepository_GetProjectFiles(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewProjectRepository(db)
+	projectID := uuid.New()
+	modifiedAt := time.Now().UTC().Truncate(time.Second)
+
+	for _, file := range []*models.ProjectFile{
+		{ProjectID: projectID, Path: "pkg/util.go", Content: []byte("package pkg\n"), Size: 12, ModifiedAt: modifiedAt},
+		{ProjectID: projectID, Path: "main.go", Content: []byte("package main\n"), Size: 13, ModifiedAt: modifiedAt},
+		{ProjectID: uuid.New(), Path: "other.go", Content: []byte("package other\n"), Size: 14},
+	} {
+		require.NoError(t, db.Create(file).Error)
+	}
+
+	files, err := repo.GetProjectFiles(context.Background(), projectID.String())
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "main.go", files[0].Path)
+	assert.Equal(t, []byte("package main\n"), files[0].Content)
+	assert.Equal(t, int64(13), files[0].Size)
+	assert.True(t, modifiedAt.Equal(files[0].ModifiedAt))
+	assert.Equal(t, "pkg/util.go", files[1].Path)
+
+	_, err = repo.GetProjectFiles(context.Background(), "not-a-uuid")
+	assert.Error(t, err)
+}
+
+func TestProjectRepository_GetProjectFiles_SourceStore(t *testing.T) {
+	db := newTestDB(t)
+	sources := services.NewFileSourceStore(t.TempDir())
+	repo := NewProjectRepositoryWithSources(db, db, sources)
+	projectID := uuid.New()
+
+	// Large files keep only their metadata in the database
+	require.NoError(t, sources.Put(context.Background(), projectID.String(), "big/generated.go", []byte("package big\n")))
+	for _, file := range []*models.ProjectFile{
+		{ProjectID: projectID, Path: "big/generated.go", Size: 12},
+		{ProjectID: projectID, Path: "main.go", Content: []byte("package main\n"), Size: 13},
+		{ProjectID: projectID, Path: "empty.go", Size: 0},
+	} {
+		require.NoError(t, db.Create(file).Error)
+	}
+
+	files, err := repo.GetProjectFiles(context.Background(), projectID.String())
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	assert.Equal(t, "big/generated.go", files[0].Path)
+	assert.Equal(t, []byte("package big\n"), files[0].Content, "content missing from the row is read from the store")
+	assert.Equal(t, "empty.go", files[1].Path)
+	assert.Empty(t, files[1].Content, "empty files aren't looked up")
+	assert.Equal(t, []byte("package main\n"), files[2].Content)
+
+	require.NoError(t, sources.Delete(context.Background(), projectID.String(), "big/generated.go"))
+	_, err = repo.GetProjectFiles(context.Background(), projectID.String())
+	assert.ErrorIs(t, err, services.ErrSourceNotFound, "a file missing from the store fails the read")
+}
+
+func TestProjectRepository_SaveProjectFile(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	sources := services.NewFileSourceStore(t.TempDir())
+	repo := NewProjectRepositoryWithSources(db, db, sources)
+	repo.SetInlineLimit(16)
+	projectID := uuid.New()
+
+	small := []byte("package main\n")
+	large := []byte("package big\n\nconst X = 1\n")
+	require.NoError(t, repo.SaveProjectFile(ctx, projectID.String(), &repository.ProjectFile{Path: "main.go", Content: small}))
+	require.NoError(t, repo.SaveProjectFile(ctx, projectID.String(), &repository.ProjectFile{Path: "big.go", Content: large}))
+
+	var row models.ProjectFile
+	require.NoError(t, db.Where("project_id = ? AND path = ?", projectID, "big.go").First(&row).Error)
+	assert.Nil(t, row.Content, "large content stays out of the database")
+	assert.Equal(t, int64(len(large)), row.Size)
+	stored, err := sources.Get(ctx, projectID.String(), "big.go")
+	require.NoError(t, err)
+	assert.Equal(t, large, stored)
+
+	_, err = sources.Get(ctx, projectID.String(), "main.go")
+	assert.ErrorIs(t, err, services.ErrSourceNotFound, "small content stays in the database")
+
+	files, err := repo.GetProjectFiles(ctx, projectID.String())
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, large, files[0].Content)
+	assert.Equal(t, small, files[1].Content)
+
+	t.Run("replaced by smaller content", func(t *testing.T) {
+		require.NoError(t, repo.SaveProjectFile(ctx, projectID.String(), &repository.ProjectFile{Path: "big.go", Content: small}))
+
+		var rows []models.ProjectFile
+		require.NoError(t, db.Where("project_id = ? AND path = ?", projectID, "big.go").Find(&rows).Error)
+		require.Len(t, rows, 1, "the file is replaced, not added again")
+		assert.Equal(t, small, rows[0].Content)
+		_, err := sources.Get(ctx, projectID.String(), "big.go")
+		assert.ErrorIs(t, err, services.ErrSourceNotFound, "the stored copy is removed")
+	})
+
+	t.Run("without a store", func(t *testing.T) {
+		repo := NewProjectRepository(db)
+		require.NoError(t, repo.SaveProjectFile(ctx, projectID.String(), &repository.ProjectFile{Path: "inline.go", Content: large}))
+		var inline models.ProjectFile
+		require.NoError(t, db.Where("project_id = ? AND path = ?", projectID, "inline.go").First(&inline).Error)
+		assert.Equal(t, large, inline.Content)
+	})
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+// saveBatchSize is how many file rows are inserted per statement
+const saveBatchSize = 100
+
+// analysisFileRow is an analysis_files row. The columns carry what other
+// services query; Result is the complete result the analysis service returns.
+type analysisFileRow struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey"`
+	AnalysisID   uuid.UUID
+	FilePath     string
+	FileLanguage string
+	FileSize     int64
+	LinesOfCode  int
+	Complexity   int
+	Functions    []service.FunctionSummary   `gorm:"serializer:json"`
+	Issues       []metrics.Issue             `gorm:"serializer:json"`
+	Result       *service.FileAnalysisResult `gorm:"serializer:json"`
+	CreatedAt    time.Time
+}
+
+// TableName stores file rows in the shared analysis_files table
+func (analysisFileRow) TableName() string {
+	return "analysis_files"
+}
+
+// MetricsRepository persists per-file results in analysis_files and
+// aggregate metrics on the analyses row
+type MetricsRepository struct {
+	db *gorm.DB
+	// reads serves GetAnalysisResults; it is db unless a replica is given
+	reads *gorm.DB
+}
+
+// NewMetricsRepository creates a metrics repository on db
+func NewMetricsRepository(db *gorm.DB) *MetricsRepository {
+	return &MetricsRepository{db: db, reads: db}
+}
+
+// NewMetricsRepositoryWithReplica creates a metrics repository that saves
+// results on primary and reads them from replica
+func NewMetricsRepositoryWithReplica(primary, replica *gorm.DB) *MetricsRepository {
+	return &MetricsRepository{db: primary, reads: replica}
+}
+
+// SaveAnalysisResults replaces the analysis's file results and sets its
+// aggregate metrics in one transaction, so a failed save leaves the previous
+// results in place and saving again is safe. Failures a retry may not hit
+// wrap service.ErrStorageUnavailable.
+func (r *MetricsRepository) SaveAnalysisResults(ctx context.Context, analysisID string, results []*service.FileAnalysisResult, aggregate *service.AggregateMetrics) error {
+	id, err := uuid.Parse(analysisID)
+	if err != nil {
+		return fmt.Errorf("invalid analysis id %q: %w", analysisID, err)
+	}
+	if aggregate == nil {
+		aggregate = &service.AggregateMetrics{}
+	}
+	encoded, err := json.Marshal(aggregate)
+	if err != nil {
+		return fmt.Errorf("failed to encode aggregate metrics: %w", err)
+	}
+
+	rows := make([]analysisFileRow, 0, len(results))
+	for _, result := range results {
+		rows = append(rows, analysisFileRow{
+			ID:           uuid.New(),
+			AnalysisID:   id,
+			FilePath:     result.FilePath,
+			FileLanguage: result.Language,
+			LinesOfCode:  result.LOC,
+			Complexity:   result.Complexity,
+			Functions:    result.Functions,
+			Issues:       result.Issues,
+			Result:       result,
+		})
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("analysis_id = ?", id).Delete(&analysisFileRow{}).Error; err != nil {
+			return fmt.Errorf("failed to delete previous results: %w", err)
+		}
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, saveBatchSize).Error; err != nil {
+				return fmt.Errorf("failed to save file results: %w", err)
+			}
+		}
+
+		updates := map[string]interface{}{
+			"aggregate_metrics":     string(encoded),
+			"lines_of_code":         aggregate.TotalLOC,
+			"cyclomatic_complexity": aggregate.TotalComplexity,
+			"vulnerabilities":       aggregate.Vulnerabilities,
+			"security_hotspots":     aggregate.SecurityHotspots,
+			"duplication_ratio":     aggregate.DuplicationRatio,
+		}
+		result := tx.Model(&models.Analysis{}).Where("id = ?", id).Updates(updates)
+		if result.Error != nil {
+			return fmt.Errorf("failed to save aggregate metrics: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: %s", service.ErrAnalysisNotFound, analysisID)
+		}
+		return nil
+	})
+	return markTransient(err)
+}
+
+// GetAnalysisResults returns the analysis's file results ordered by path
+func (r *MetricsRepository) GetAnalysisResults(ctx context.Context, analysisID string) ([]*service.FileAnalysisResult, error) {
+	id, err := uuid.Parse(analysisID)
+	if err != nil {
+		return nil, nil
+	}
+
+	var rows []analysisFileRow
+	if err := r.reads.WithContext(ctx).Where("analysis_id = ?", id).Order("file_path").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get analysis results: %w", err)
+	}
+
+	results := make([]*service.FileAnalysisResult, 0, len(rows))
+	for _, row := range rows {
+		if row.Result != nil {
+			results = append(results, row.Result)
+			continue
+		}
+		// Rows written before results were stored whole
+		results = append(results, &service.FileAnalysisResult{
+			FilePath:   row.FilePath,
+			Language:   row.FileLanguage,
+			LOC:        row.LinesOfCode,
+			Complexity: row.Complexity,
+			Functions:  row.Functions,
+			Issues:     row.Issues,
+		})
+	}
+	return results, nil
+}
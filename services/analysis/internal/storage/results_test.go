@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/metrics"
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+func TestMetricsRepository_SaveAndGetAnalysisResults(t *testing.T) {
+	db := newTestDB(t)
+	analyses := NewAnalysisRepository(db)
+	repo := NewMetricsRepository(db)
+	ctx := context.Background()
+
+	job := newTestJob(uuid.NewString())
+	require.NoError(t, analyses.CreateJob(ctx, job))
+
+	results := []*service.FileAnalysisResult{
+		{
+			FilePath:   "pkg/util.go",
+			Language:   "go",
+			LOC:        20,
+			Complexity: 4,
+			Metrics:    map[string]interface{}{"functions": float64(2)},
+			Issues:     []metrics.Issue{{Type: "code_smell", Severity: "minor", File: "pkg/util.go", Line: 3, Rule: "long-function"}},
+			Functions:  []service.FunctionSummary{{Name: "Util", StartLine: 3, EndLine: 12, Complexity: 3}},
+		},
+		{FilePath: "main.go", Language: "go", LOC: 10, Complexity: 1, Metrics: map[string]interface{}{}},
+	}
+	aggregate := &service.AggregateMetrics{
+		TotalLOC:         30,
+		TotalComplexity:  5,
+		Vulnerabilities:  1,
+		DuplicationRatio: 0.25,
+	}
+	require.NoError(t, repo.SaveAnalysisResults(ctx, job.ID, results, aggregate))
+
+	got, err := repo.GetAnalysisResults(ctx, job.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "main.go", got[0].FilePath)
+	assert.Equal(t, results[0], got[1])
+
+	var analysis models.Analysis
+	require.NoError(t, db.First(&analysis, "id = ?", job.ID).Error)
+	assert.Equal(t, 30, analysis.Metrics.LinesOfCode)
+	assert.Equal(t, 5, analysis.Metrics.CyclomaticComplexity)
+	assert.Equal(t, 1, analysis.Metrics.Vulnerabilities)
+	assert.Equal(t, 0.25, analysis.Metrics.DuplicationRatio)
+
+	var stored string
+	require.NoError(t, db.Raw("SELECT aggregate_metrics FROM analyses WHERE id = ?", job.ID).Scan(&stored).Error)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(stored), &decoded))
+	assert.Equal(t, float64(30), decoded["total_loc"])
+
+	t.Run("saving the same results again is idempotent", func(t *testing.T) {
+		require.NoError(t, repo.SaveAnalysisResults(ctx, job.ID, results, aggregate))
+		again, err := repo.GetAnalysisResults(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, got, again)
+
+		var rows int64
+		require.NoError(t, db.Model(&analysisFileRow{}).Where("analysis_id = ?", job.ID).Count(&rows).Error)
+		assert.Equal(t, int64(2), rows)
+		var analysis models.Analysis
+		require.NoError(t, db.First(&analysis, "id = ?", job.ID).Error)
+		assert.Equal(t, 30, analysis.Metrics.LinesOfCode)
+	})
+
+	t.Run("saving again replaces the results", func(t *testing.T) {
+		require.NoError(t, repo.SaveAnalysisResults(ctx, job.ID, results[:1], aggregate))
+		got, err := repo.GetAnalysisResults(ctx, job.ID)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, "pkg/util.go", got[0].FilePath)
+	})
+}
+
+func TestMetricsRepository_SaveAnalysisResults_MissingAnalysis(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMetricsRepository(db)
+	ctx := context.Background()
+	analysisID := uuid.NewString()
+
+	err := repo.SaveAnalysisResults(ctx, analysisID, []*service.FileAnalysisResult{{FilePath: "main.go"}}, &service.AggregateMetrics{})
+	require.ErrorIs(t, err, service.ErrAnalysisNotFound)
+
+	// The file rows are rolled back with the aggregate update
+	got, err := repo.GetAnalysisResults(ctx, analysisID)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestMetricsRepository_GetAnalysisResults_ColumnsOnly(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMetricsRepository(db)
+	analysisID := uuid.New()
+
+	require.NoError(t, db.Create(&analysisFileRow{
+		ID:           uuid.New(),
+		AnalysisID:   analysisID,
+		FilePath:     "legacy.go",
+		FileLanguage: "go",
+		LinesOfCode:  8,
+		Complexity:   2,
+	}).Error)
+
+	got, err := repo.GetAnalysisResults(context.Background(), analysisID.String())
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, &service.FileAnalysisResult{FilePath: "legacy.go", Language: "go", LOC: 8, Complexity: 2}, got[0])
+}
+
+func TestMetricsRepository_Replica(t *testing.T) {
+	primary, replica := newTestDB(t), newTestDB(t)
+	repo := NewMetricsRepositoryWithReplica(primary, replica)
+	ctx := context.Background()
+
+	job := newTestJob(uuid.NewString())
+	require.NoError(t, NewAnalysisRepository(primary).CreateJob(ctx, job))
+	results := []*service.FileAnalysisResult{{FilePath: "main.go", Language: "go", LOC: 10}}
+	require.NoError(t, repo.SaveAnalysisResults(ctx, job.ID, results, &service.AggregateMetrics{TotalLOC: 10}))
+
+	var saved int64
+	require.NoError(t, primary.Model(&analysisFileRow{}).Count(&saved).Error)
+	assert.EqualValues(t, 1, saved, "results are saved on the primary")
+
+	got, err := repo.GetAnalysisResults(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got, "results are read from the replica, which hasn't caught up")
+
+	require.NoError(t, replica.Create(&analysisFileRow{
+		ID:           uuid.New(),
+		AnalysisID:   uuid.MustParse(job.ID),
+		FilePath:     "replicated.go",
+		FileLanguage: "go",
+	}).Error)
+	got, err = repo.GetAnalysisResults(ctx, job.ID)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "replicated.go", got[0].FilePath)
+}
+
+func TestMarkTransient(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, true},
+		{"connection failure", fmt.Errorf("failed to save file results: %w", &pgconn.PgError{Code: "08006"}), true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"bad connection", driver.ErrBadConn, true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"missing analysis", fmt.Errorf("%w: a", service.ErrAnalysisNotFound), false},
+		{"cancelled", fmt.Errorf("failed to save file results: %w", context.Canceled), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := markTransient(tt.err)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.transient, errors.Is(err, service.ErrStorageUnavailable))
+		})
+	}
+	assert.NoError(t, markTransient(nil))
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/sa3d-modernized/sa3d/services/analysis/internal/service"
+)
+
+// transientCodes are the SQLSTATE codes, or classes by their first two
+// characters, of failures a later attempt may not hit: lost connections,
+// serialization failures, deadlocks and server shutdowns
+var transientCodes = []string{"08", "40001", "40P01", "57P01", "57P02", "57P03"}
+
+// markTransient wraps err in service.ErrStorageUnavailable when it is a
+// failure that may pass on retry. Cancelled and expired contexts aren't
+// transient: the caller gave up.
+func markTransient(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if transient(err) {
+		return fmt.Errorf("%w: %w", service.ErrStorageUnavailable, err)
+	}
+	return err
+}
+
+// transient reports whether err is a failure that may pass on retry
+func transient(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		for _, code := range transientCodes {
+			if strings.HasPrefix(pgErr.Code, code) {
+				return true
+			}
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || pgconn.SafeToRetry(err) || pgconn.Timeout(err) || errors.As(err, &netErr)
+}
@@ -0,0 +1,293 @@
+// Package webhook delivers signed JSON payloads to HTTP endpoints with retries.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers set on every delivery
+const (
+	SignatureHeader  = "X-Signature"
+	DeliveryIDHeader = "X-Delivery-ID"
+)
+
+// signaturePrefix names the HMAC algorithm in the signature header
+const signaturePrefix = "sha256="
+
+// ErrDeliveryFailed is returned when a payload could not be delivered and
+// was dead-lettered
+var ErrDeliveryFailed = errors.New("webhook delivery failed")
+
+// Config tunes delivery
+type Config struct {
+	Secret         string        // HMAC key; empty sends unsigned payloads
+	MaxAttempts    int           // total attempts including the first
+	InitialBackoff time.Duration // wait before the first retry, doubled each retry
+	MaxBackoff     time.Duration
+	Timeout        time.Duration // per attempt
+}
+
+// DefaultConfig returns the delivery settings used when none are configured
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Timeout:        10 * time.Second,
+	}
+}
+
+// MaxDuration is the longest a delivery can take: every attempt timing out,
+// with every backoff between them
+func (c Config) MaxDuration() time.Duration {
+	total := time.Duration(c.MaxAttempts) * c.Timeout
+	backoff := c.InitialBackoff
+	for i := 1; i < c.MaxAttempts; i++ {
+		total += backoff
+		backoff = min(backoff*2, c.MaxBackoff)
+	}
+	return total
+}
+
+// DefaultDeadLetterCapacity is how many deliveries a MemoryDeadLetterQueue
+// keeps unless told otherwise
+const DefaultDeadLetterCapacity = 1000
+
+// Attempt records one try at delivering a payload
+type Attempt struct {
+	Number     int           `json:"number"`
+	At         time.Time     `json:"at"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Delivery is a payload and the history of attempts to deliver it
+type Delivery struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Payload   []byte    `json:"payload"`
+	Attempts  []Attempt `json:"attempts"`
+	Delivered bool      `json:"delivered"`
+}
+
+// DeadLetterQueue keeps deliveries that exhausted their attempts
+type DeadLetterQueue interface {
+	Add(ctx context.Context, delivery *Delivery) error
+}
+
+// MemoryDeadLetterQueue is a DeadLetterQueue held in memory. Once it holds
+// its capacity, the oldest delivery makes room for the newest; the zero
+// value holds DefaultDeadLetterCapacity.
+type MemoryDeadLetterQueue struct {
+	mu         sync.Mutex
+	capacity   int
+	deliveries []*Delivery
+}
+
+// NewMemoryDeadLetterQueue creates a queue keeping the last capacity
+// deliveries, DefaultDeadLetterCapacity when it isn't positive
+func NewMemoryDeadLetterQueue(capacity int) *MemoryDeadLetterQueue {
+	return &MemoryDeadLetterQueue{capacity: capacity}
+}
+
+// Add implements DeadLetterQueue
+func (q *MemoryDeadLetterQueue) Add(ctx context.Context, delivery *Delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	capacity := q.capacity
+	if capacity <= 0 {
+		capacity = DefaultDeadLetterCapacity
+	}
+	if len(q.deliveries) >= capacity {
+		q.deliveries = append(q.deliveries[:0], q.deliveries[len(q.deliveries)-capacity+1:]...)
+	}
+	q.deliveries = append(q.deliveries, delivery)
+	return nil
+}
+
+// Deliveries returns the dead-lettered deliveries
+func (q *MemoryDeadLetterQueue) Deliveries() []*Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]*Delivery(nil), q.deliveries...)
+}
+
+// Deliverer POSTs signed payloads, retrying with exponential backoff
+type Deliverer struct {
+	config     Config
+	client     *http.Client
+	deadLetter DeadLetterQueue
+	logger     *logrus.Logger
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// NewDeliverer creates a deliverer; zero config values take DefaultConfig's
+func NewDeliverer(config Config, logger *logrus.Logger) *Deliverer {
+	defaults := DefaultConfig()
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = defaults.InitialBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+
+	return &Deliverer{
+		config:     config,
+		client:     &http.Client{},
+		deadLetter: NewMemoryDeadLetterQueue(DefaultDeadLetterCapacity),
+		logger:     logger,
+		sleep:      sleepContext,
+	}
+}
+
+// MaxDuration is the longest a delivery can take with the deliverer's config
+func (d *Deliverer) MaxDuration() time.Duration {
+	return d.config.MaxDuration()
+}
+
+// SetDeadLetterQueue replaces where undeliverable payloads are kept
+func (d *Deliverer) SetDeadLetterQueue(queue DeadLetterQueue) {
+	d.deadLetter = queue
+}
+
+// SetHTTPClient replaces the client used for deliveries
+func (d *Deliverer) SetHTTPClient(client *http.Client) {
+	d.client = client
+}
+
+// Deliver POSTs payload as JSON to url. It returns the delivery record
+// and, once all attempts fail, ErrDeliveryFailed after dead-lettering it.
+func (d *Deliverer) Deliver(ctx context.Context, url string, payload interface{}) (*Delivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	delivery := &Delivery{
+		ID:      uuid.New().String(),
+		URL:     url,
+		Payload: body,
+	}
+
+	backoff := d.config.InitialBackoff
+	for number := 1; number <= d.config.MaxAttempts; number++ {
+		attempt, retry := d.attempt(ctx, delivery, number)
+		delivery.Attempts = append(delivery.Attempts, attempt)
+
+		logger := d.logger.WithFields(logrus.Fields{
+			"delivery_id": delivery.ID,
+			"url":         url,
+			"attempt":     number,
+		})
+		if attempt.Error == "" {
+			delivery.Delivered = true
+			logger.Debug("Webhook delivered")
+			return delivery, nil
+		}
+		logger.Warnf("Webhook attempt failed: %s", attempt.Error)
+
+		if !retry || number == d.config.MaxAttempts {
+			break
+		}
+		if err := d.sleep(ctx, backoff); err != nil {
+			break
+		}
+		backoff *= 2
+		if backoff > d.config.MaxBackoff {
+			backoff = d.config.MaxBackoff
+		}
+	}
+
+	if err := d.deadLetter.Add(context.Background(), delivery); err != nil {
+		d.logger.WithField("delivery_id", delivery.ID).Errorf("Failed to dead-letter webhook delivery: %v", err)
+	}
+	last := delivery.Attempts[len(delivery.Attempts)-1]
+	return delivery, fmt.Errorf("%w: %s after %d attempts: %s", ErrDeliveryFailed, url, len(delivery.Attempts), last.Error)
+}
+
+// attempt makes one delivery attempt and reports whether a failure is worth retrying
+func (d *Deliverer) attempt(ctx context.Context, delivery *Delivery, number int) (Attempt, bool) {
+	attempt := Attempt{Number: number, At: time.Now().UTC()}
+
+	ctx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryIDHeader, delivery.ID)
+	if d.config.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(d.config.Secret, delivery.Payload))
+	}
+
+	resp, err := d.client.Do(req)
+	attempt.Duration = time.Since(attempt.At)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt, true
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return attempt, false
+	}
+	attempt.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+
+	// Client errors won't fix themselves, except rate limiting
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return attempt, retry
+}
+
+// Sign returns the X-Signature value for body: "sha256=" and the hex HMAC
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid X-Signature for body; receivers
+// use it to authenticate deliveries
+func Verify(secret string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDeliverer(config Config) (*Deliverer, *[]time.Duration) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	d := NewDeliverer(config, logger)
+	var waits []time.Duration
+	d.sleep = func(ctx context.Context, wait time.Duration) error {
+		waits = append(waits, wait)
+		return nil
+	}
+	return d, &waits
+}
+
+func TestDeliverer_Delivers(t *testing.T) {
+	var body []byte
+	var signature, deliveryID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		deliveryID = r.Header.Get(DeliveryIDHeader)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	d, waits := newTestDeliverer(Config{Secret: "s3cret"})
+
+	delivery, err := d.Deliver(context.Background(), server.URL, map[string]string{"event": "analysis.completed"})
+	require.NoError(t, err)
+
+	assert.True(t, delivery.Delivered)
+	require.Len(t, delivery.Attempts, 1)
+	assert.Equal(t, http.StatusAccepted, delivery.Attempts[0].StatusCode)
+	assert.Empty(t, *waits)
+
+	assert.JSONEq(t, `{"event":"analysis.completed"}`, string(body))
+	assert.Equal(t, delivery.ID, deliveryID)
+	assert.True(t, Verify("s3cret", body, signature))
+}
+
+func TestSign(t *testing.T) {
+	// Reference value: printf '{"a":1}' | openssl dgst -sha256 -hmac key
+	assert.Equal(t, "sha256=88a67f24bbcdaed0e6c997404bb79a743baf44c6bab2f4c27328e3009d22e342", Sign("key", []byte(`{"a":1}`)))
+
+	signature := Sign("key", []byte("payload"))
+	assert.True(t, Verify("key", []byte("payload"), signature))
+	assert.False(t, Verify("other", []byte("payload"), signature))
+	assert.False(t, Verify("key", []byte("tampered"), signature))
+	assert.False(t, Verify("key", []byte("payload"), signature[len("sha256="):]))
+}
+
+func TestDeliverer_RetriesThenGivesUp(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	d, waits := newTestDeliverer(Config{MaxAttempts: 4, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond})
+	queue := &MemoryDeadLetterQueue{}
+	d.SetDeadLetterQueue(queue)
+
+	delivery, err := d.Deliver(context.Background(), server.URL, map[string]int{"n": 1})
+	assert.ErrorIs(t, err, ErrDeliveryFailed)
+
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+	assert.False(t, delivery.Delivered)
+	require.Len(t, delivery.Attempts, 4)
+	for i, attempt := range delivery.Attempts {
+		assert.Equal(t, i+1, attempt.Number)
+		assert.Equal(t, http.StatusServiceUnavailable, attempt.StatusCode)
+	}
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}, *waits)
+
+	require.Len(t, queue.Deliveries(), 1)
+	assert.Equal(t, delivery.ID, queue.Deliveries()[0].ID)
+}
+
+func TestDeliverer_RetriesUntilSuccess(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	d, _ := newTestDeliverer(Config{MaxAttempts: 5})
+	queue := &MemoryDeadLetterQueue{}
+	d.SetDeadLetterQueue(queue)
+
+	delivery, err := d.Deliver(context.Background(), server.URL, "ping")
+	require.NoError(t, err)
+	assert.True(t, delivery.Delivered)
+	assert.Len(t, delivery.Attempts, 3)
+	assert.Empty(t, queue.Deliveries())
+}
+
+func TestDeliverer_ClientErrorIsNotRetried(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	d, _ := newTestDeliverer(Config{MaxAttempts: 5})
+	queue := &MemoryDeadLetterQueue{}
+	d.SetDeadLetterQueue(queue)
+
+	_, err := d.Deliver(context.Background(), server.URL, "ping")
+	assert.ErrorIs(t, err, ErrDeliveryFailed)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Len(t, queue.Deliveries(), 1)
+}
+
+func TestDeliverer_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	d, _ := newTestDeliverer(Config{MaxAttempts: 2, Timeout: 50 * time.Millisecond})
+
+	delivery, err := d.Deliver(context.Background(), server.URL, "slow")
+	assert.ErrorIs(t, err, ErrDeliveryFailed)
+	require.Len(t, delivery.Attempts, 2)
+	assert.NotEmpty(t, delivery.Attempts[0].Error)
+}
+
+func TestConfig_MaxDuration(t *testing.T) {
+	// Five 10s attempts and backoffs of 1s, 2s, 4s and 8s
+	assert.Equal(t, 65*time.Second, DefaultConfig().MaxDuration())
+
+	capped := Config{MaxAttempts: 4, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, Timeout: time.Second}
+	assert.Equal(t, 4*time.Second+600*time.Millisecond, capped.MaxDuration())
+
+	d, _ := newTestDeliverer(Config{})
+	assert.Equal(t, DefaultConfig().MaxDuration(), d.MaxDuration(), "zero values take the defaults")
+}
+
+func TestMemoryDeadLetterQueue_Capacity(t *testing.T) {
+	queue := NewMemoryDeadLetterQueue(2)
+	for _, id := range []string{"d1", "d2", "d3"} {
+		require.NoError(t, queue.Add(context.Background(), &Delivery{ID: id}))
+	}
+
+	deliveries := queue.Deliveries()
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, "d2", deliveries[0].ID, "the oldest delivery is dropped")
+	assert.Equal(t, "d3", deliveries[1].ID)
+
+	defaulted := &MemoryDeadLetterQueue{}
+	for i := 0; i < DefaultDeadLetterCapacity+1; i++ {
+		require.NoError(t, defaulted.Add(context.Background(), &Delivery{}))
+	}
+	assert.Len(t, defaulted.Deliveries(), DefaultDeadLetterCapacity)
+}
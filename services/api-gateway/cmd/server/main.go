@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"slices"
 	"syscall"
 	"time"
 
@@ -14,12 +16,17 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
-	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/time/rate"
 
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/eventbridge"
 	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/handler"
 	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
 	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/proxy"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/reload"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/telemetry"
+	"github.com/sa3d-modernized/sa3d/shared/events"
 	"github.com/sa3d-modernized/sa3d/shared/services"
 	"github.com/sa3d-modernized/sa3d/shared/utils"
 )
@@ -29,6 +36,14 @@ type Config struct {
 		Port         string        `mapstructure:"port"`
 		ReadTimeout  time.Duration `mapstructure:"read_timeout"`
 		WriteTimeout time.Duration `mapstructure:"write_timeout"`
+		// ShutdownTimeout bounds stopping the server and draining requests
+		ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+		// ShutdownDelay is how long the gateway keeps serving after it
+		// starts reporting not ready
+		ShutdownDelay time.Duration `mapstructure:"shutdown_delay"`
+		// TrustedProxies are the addresses or CIDRs of the load balancers
+		// in front of the gateway; only their forwarding headers are believed
+		TrustedProxies []string `mapstructure:"trusted_proxies"`
 	} `mapstructure:"server"`
 
 	Redis struct {
@@ -41,6 +56,8 @@ type Config struct {
 		Analysis struct {
 			URL     string        `mapstructure:"url"`
 			Timeout time.Duration `mapstructure:"timeout"`
+			// SnippetMaxBytes rejects larger snippet bodies before they're proxied
+			SnippetMaxBytes int64 `mapstructure:"snippet_max_bytes"`
 		} `mapstructure:"analysis"`
 		Visualization struct {
 			URL     string        `mapstructure:"url"`
@@ -59,19 +76,119 @@ type Config struct {
 	Auth struct {
 		JWTSecret     string        `mapstructure:"jwt_secret"`
 		TokenDuration time.Duration `mapstructure:"token_duration"`
+		// PasswordHashAlgorithm selects the hasher for new passwords ("bcrypt" or "argon2id")
+		PasswordHashAlgorithm string `mapstructure:"password_hash_algorithm"`
+		// SessionCleanupInterval is how often expired sessions are purged
+		SessionCleanupInterval time.Duration `mapstructure:"session_cleanup_interval"`
+		// SessionCleanupGrace is how long expired sessions are kept before deletion
+		SessionCleanupGrace time.Duration `mapstructure:"session_cleanup_grace"`
+		// InternalToken authenticates backends to internal endpoints; it
+		// comes from INTERNAL_AUTH_TOKEN, and without one they are disabled
+		InternalToken string `mapstructure:"-"`
 	} `mapstructure:"auth"`
 
 	RateLimit struct {
 		RequestsPerSecond int `mapstructure:"requests_per_second"`
 		Burst             int `mapstructure:"burst"`
+		// ExemptPaths, and the paths below them, bypass the global limit
+		ExemptPaths  []string `mapstructure:"exempt_paths"`
+		Registration struct {
+			RequestsPerMinute int `mapstructure:"requests_per_minute"`
+			Burst             int `mapstructure:"burst"`
+		} `mapstructure:"registration"`
+		// Snippet limits ad-hoc snippet analysis per client IP, as each
+		// one is analyzed within its request
+		Snippet struct {
+			RequestsPerMinute int `mapstructure:"requests_per_minute"`
+			Burst             int `mapstructure:"burst"`
+		} `mapstructure:"snippet"`
 	} `mapstructure:"rate_limit"`
 
+	// Challenge configures human verification on registration.
+	// Provider is "none" (default, for development) or "turnstile".
+	Challenge struct {
+		Provider  string `mapstructure:"provider"`
+		Secret    string `mapstructure:"secret"`
+		VerifyURL string `mapstructure:"verify_url"`
+	} `mapstructure:"challenge"`
+
+	// CORS holds the global CORS rules. Groups overrides them for the
+	// route groups named in corsGroups; fields a group leaves unset fall
+	// back to the global rules.
 	CORS struct {
-		AllowedOrigins []string `mapstructure:"allowed_origins"`
-		AllowedMethods []string `mapstructure:"allowed_methods"`
-		AllowedHeaders []string `mapstructure:"allowed_headers"`
-		MaxAge         int      `mapstructure:"max_age"`
+		middleware.CORSConfig `mapstructure:",squash"`
+		Groups                map[string]middleware.CORSConfig `mapstructure:"groups"`
 	} `mapstructure:"cors"`
+
+	// CircuitBreaker opens a service's breaker after FailureThreshold
+	// failed requests and retries it after ResetTimeout
+	CircuitBreaker struct {
+		FailureThreshold int           `mapstructure:"failure_threshold"`
+		ResetTimeout     time.Duration `mapstructure:"reset_timeout"`
+	} `mapstructure:"circuit_breaker"`
+
+	// ResponseCache caches GET responses of the listed routes in Redis.
+	// Routes are full patterns such as /api/v1/analysis/status/:analysisId,
+	// mapped to the TTL of their cached responses.
+	ResponseCache struct {
+		Enabled bool                     `mapstructure:"enabled"`
+		Routes  map[string]time.Duration `mapstructure:"routes"`
+	} `mapstructure:"response_cache"`
+
+	// Projects configures project branch handling. With ValidateBranches,
+	// a project's branch must exist in its repository; projects without a
+	// branch get the first of BranchFallbacks the repository has.
+	Projects struct {
+		ValidateBranches bool          `mapstructure:"validate_branches"`
+		BranchFallbacks  []string      `mapstructure:"branch_fallbacks"`
+		FetchTimeout     time.Duration `mapstructure:"fetch_timeout"`
+	} `mapstructure:"projects"`
+
+	// Telemetry configures OpenTelemetry trace and metric export
+	Telemetry telemetry.Config `mapstructure:"telemetry"`
+
+	// RequestID configures the header request IDs are read from and
+	// echoed in, and whether they are derived from incoming traceparents
+	RequestID middleware.RequestIDConfig `mapstructure:"request_id"`
+
+	// Features are the feature flags by name. FEATURE_* variables override
+	// them, and so does the feature_flags Redis hash, read again every
+	// FeatureRefreshInterval; 0 reads it only at startup.
+	Features               map[string]bool `mapstructure:"features"`
+	FeatureRefreshInterval time.Duration   `mapstructure:"feature_refresh_interval"`
+
+	// PathLimits bounds the length and depth of request paths
+	PathLimits middleware.PathLimitConfig `mapstructure:"path_limits"`
+
+	// SecurityHeaders are set on every response; empty values leave their
+	// header out
+	SecurityHeaders middleware.SecurityHeadersConfig `mapstructure:"security_headers"`
+
+	// Maintenance configures the requests refused while the maintenance
+	// feature flag is on
+	Maintenance middleware.MaintenanceConfig `mapstructure:"maintenance"`
+
+	// Concurrency caps the requests in flight, globally and per route
+	Concurrency middleware.ConcurrencyConfig `mapstructure:"concurrency"`
+
+	// EventBridge consumes the analysis events published to Kafka, each
+	// instance as a consumer group of its own
+	EventBridge struct {
+		Enabled            bool `mapstructure:"enabled"`
+		eventbridge.Config `mapstructure:",squash"`
+	} `mapstructure:"event_bridge"`
+
+	// Debug holds settings for debugging backend integrations. With
+	// BodyLogging enabled, the proxied request and response bodies of the
+	// listed routes are logged, redacted and capped at MaxBytes; auth
+	// routes are never logged.
+	Debug struct {
+		BodyLogging struct {
+			Enabled  bool     `mapstructure:"enabled"`
+			Routes   []string `mapstructure:"routes"`
+			MaxBytes int      `mapstructure:"max_bytes"`
+		} `mapstructure:"body_logging"`
+	} `mapstructure:"debug"`
 }
 
 func main() {
@@ -79,6 +196,7 @@ func main() {
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.JSONFormatter{})
 	logger.SetLevel(logrus.InfoLevel)
+	logger.AddHook(utils.MaskingHook{})
 
 	// Initialize secrets manager
 	secretManager := utils.NewSecretManager(logger)
@@ -88,6 +206,7 @@ func main() {
 	if err != nil {
 		logger.Fatalf("Failed to load configuration: %v", err)
 	}
+	utils.LogStartup(logger, "api-gateway", config)
 
 	// Get Redis credentials securely
 	redisAddr, redisPassword, redisDB, err := secretManager.GetRedisCredentials()
@@ -106,47 +225,133 @@ func main() {
 	if err := redisClient.Ping(ctx).Err(); err != nil {
 		logger.Fatalf("Failed to connect to Redis: %v", err)
 	}
-	defer redisClient.Close()
-
-	// Initialize tracer
-	tracer := otel.Tracer("api-gateway")
 
-	// Create rate limiter
-	limiter := rate.NewLimiter(
-		rate.Limit(config.RateLimit.RequestsPerSecond),
-		config.RateLimit.Burst,
-	)
+	// Initialize tracing and metrics export
+	telemetryProviders, err := telemetry.Setup(ctx, config.Telemetry, logger)
+	if err != nil {
+		logger.Fatalf("Failed to initialize telemetry: %v", err)
+	}
+	tracer := telemetryProviders.TracerProvider.Tracer("api-gateway")
+
+	// Create rate limiters; their limits change when the config is reloaded
+	settings := reloadableSettings(config)
+	limiter := rate.NewLimiter(settings.RateLimit.Rate, settings.RateLimit.Burst)
+	limits := routeLimits{
+		registration: middleware.NewIPRateLimit(settings.Registration.Rate, settings.Registration.Burst),
+		snippet:      middleware.NewIPRateLimit(settings.Snippet.Rate, settings.Snippet.Burst),
+	}
 
 	// Initialize service proxies
 	serviceProxies := initializeServiceProxies(config, logger)
+	circuitBreakers := initializeCircuitBreakers(serviceProxies, config)
+	if config.Debug.BodyLogging.Enabled {
+		logger.Warnf("Logging proxied bodies of %d routes for debugging", len(config.Debug.BodyLogging.Routes))
+		for _, serviceProxy := range serviceProxies {
+			serviceProxy.SetBodyLogging(proxy.BodyLogging{
+				Routes:   config.Debug.BodyLogging.Routes,
+				MaxBytes: config.Debug.BodyLogging.MaxBytes,
+			})
+		}
+	}
+	requestCounter := middleware.NewRequestCounter()
 
 	// Create Gin router
 	router := gin.New()
+	if err := router.SetTrustedProxies(config.Server.TrustedProxies); err != nil {
+		logger.Fatalf("Invalid trusted proxies: %v", err)
+	}
 
 	// Add middleware
-	router.Use(gin.Recovery())
-	router.Use(middleware.Logger(logger))
-	router.Use(middleware.RequestID())
-	router.Use(middleware.CORS(config.CORS))
-	router.Use(middleware.RateLimiter(limiter))
-	router.Use(middleware.Tracing(tracer))
+	corsPolicy := middleware.NewCORSPolicy(config.CORS.CORSConfig)
+	setupMiddleware(router, config, corsPolicy, limiter, requestCounter, tracer, logger)
+	if config.Telemetry.Metrics.Enabled {
+		requestMetrics, err := middleware.RequestMetrics(telemetryProviders.MeterProvider.Meter("api-gateway"))
+		if err != nil {
+			logger.Fatalf("Failed to create request metrics: %v", err)
+		}
+		router.Use(requestMetrics)
+	}
 
 	// Initialize database service
 	dbService, err := services.NewDatabaseService(secretManager, logger)
 	if err != nil {
 		logger.Fatalf("Failed to initialize database service: %v", err)
 	}
-	defer dbService.Close()
+
+	// Initialize password hasher
+	passwordHasher, err := services.NewPasswordHasher(config.Auth.PasswordHashAlgorithm)
+	if err != nil {
+		logger.Fatalf("Failed to initialize password hasher: %v", err)
+	}
 
 	// Initialize authentication service
-	authService := services.NewAuthService(dbService, logger)
+	authService := services.NewAuthServiceWithHasher(dbService, passwordHasher, logger)
+	authService.SetTokenSecret(config.Auth.JWTSecret)
+	if err := middleware.CheckTokenModel(authService, config.Auth.JWTSecret); err != nil {
+		logger.Fatalf("Invalid token configuration: %v", err)
+	}
+
+	// Feature flags come from the config and FEATURE_* variables and are
+	// overridden at runtime by the feature_flags Redis hash
+	features, err := services.LoadFeatureFlags(config.Features, os.Environ())
+	if err != nil {
+		logger.Fatalf("Invalid feature flags: %v", err)
+	}
+	authService.SetFeatureFlags(features)
+	loadFeatureFlags := func(ctx context.Context) (map[string]string, error) {
+		return redisClient.HGetAll(ctx, services.FeatureFlagsRedisKey).Result()
+	}
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	defer stopRefresh()
+	if config.FeatureRefreshInterval > 0 {
+		go features.RunRefresh(refreshCtx, loadFeatureFlags, config.FeatureRefreshInterval, logger)
+	} else if err := features.Refresh(refreshCtx, loadFeatureFlags); err != nil {
+		logger.Warnf("Failed to load feature flags from Redis: %v", err)
+	}
+	switch config.Maintenance.Scope {
+	case middleware.MaintenanceWrites, middleware.MaintenanceAll:
+	default:
+		logger.Fatalf("Invalid maintenance scope %q", config.Maintenance.Scope)
+	}
+	router.Use(middleware.Maintenance(features.Maintenance, config.Maintenance))
+
+	// Initialize project service
+	projectService := services.NewProjectService(dbService, handler.NewRedisAnalysisCacheCleaner(redisClient), logger)
+	projectService.SetBranchFallbacks(config.Projects.BranchFallbacks)
+	if config.Projects.ValidateBranches {
+		projectService.SetSourceFetcher(services.NewGitSourceFetcher(config.Projects.FetchTimeout))
+	}
 
 	// Initialize handlers
 	authHandler := handler.NewProductionAuthHandler(authService, logger)
+	challengeVerifier, err := newChallengeVerifier(config)
+	if err != nil {
+		logger.Fatalf("Failed to initialize challenge verifier: %v", err)
+	}
+	authHandler.SetChallengeVerifier(challengeVerifier)
+	projectHandler := handler.NewProductionProjectHandler(projectService, logger)
 	healthHandler := handler.NewHealthHandler(serviceProxies, logger)
+	healthHandler.SetCircuitBreakers(circuitBreakers)
+	healthHandler.SetRedis(redisClient)
+	healthHandler.SetRateLimiter(limiter)
+	healthHandler.SetRequestCounter(requestCounter)
+
+	// Start expired session sweeper
+	sweeperCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+	go runSessionSweeper(sweeperCtx, authService, config.Auth.SessionCleanupInterval, config.Auth.SessionCleanupGrace, logger)
+
+	// Start relaying analysis events
+	var eventBridge *eventBridgeRun
+	if config.EventBridge.Enabled {
+		eventBridge, err = startEventBridge(config.EventBridge.Config, telemetryProviders.MeterProvider.Meter("api-gateway"), logger)
+		if err != nil {
+			logger.Fatalf("Failed to start event bridge: %v", err)
+		}
+	}
 
 	// Setup routes
-	setupRoutes(router, authHandler, healthHandler, serviceProxies, authService, config, logger)
+	setupRoutes(router, corsPolicy, limits, authHandler, projectHandler, healthHandler, serviceProxies, circuitBreakers, authService, redisClient, config, logger)
 
 	// Metrics endpoint
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
@@ -167,19 +372,53 @@ func main() {
 		}
 	}()
 
+	// SIGHUP reloads the settings that don't need a restart
+	reloader := reload.New(reload.Targets{
+		RateLimiter:  limiter,
+		Registration: limits.registration,
+		Snippet:      limits.snippet,
+		CORS:         corsPolicy,
+		Proxies:      serviceProxies,
+		Features:     features,
+	}, settings, logger)
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	go func() {
+		current := config
+		for range reloads {
+			current = reloadConfig(secretManager, current, reloader, logger)
+		}
+	}()
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopSweeper()
+
+	// Stop taking traffic and drain before closing the dependencies the
+	// remaining requests use
+	shutdown := handler.NewShutdown(srv, logger)
+	shutdown.SetHealthHandler(healthHandler)
+	shutdown.SetRequestCounter(requestCounter)
+	shutdown.SetReadinessDelay(config.Server.ShutdownDelay)
+	if eventBridge != nil {
+		shutdown.AddCloser("event bridge", eventBridge)
+	}
+	shutdown.AddCloser("redis", redisClient)
+	shutdown.AddCloser("database", dbService)
 
-	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), config.Server.ShutdownTimeout)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
-		logger.Errorf("Server forced to shutdown: %v", err)
+	if err := shutdown.Run(ctx); err != nil {
+		logger.Errorf("Shutdown incomplete: %v", err)
+	}
+	// Export what the drained requests recorded
+	if err := telemetryProviders.Shutdown(ctx); err != nil {
+		logger.Errorf("Failed to flush telemetry: %v", err)
 	}
 
 	logger.Info("Server exited")
@@ -196,9 +435,51 @@ func loadConfig(secretManager *utils.SecretManager) (*Config, error) {
 	viper.SetDefault("server.port", "8080")
 	viper.SetDefault("server.read_timeout", "15s")
 	viper.SetDefault("server.write_timeout", "15s")
+	viper.SetDefault("server.shutdown_timeout", "30s")
+	viper.SetDefault("server.shutdown_delay", "0s")
 	viper.SetDefault("rate_limit.requests_per_second", 100)
 	viper.SetDefault("rate_limit.burst", 200)
+	viper.SetDefault("rate_limit.exempt_paths", middleware.DefaultRateLimitExemptPaths)
+	viper.SetDefault("rate_limit.registration.requests_per_minute", 5)
+	viper.SetDefault("rate_limit.registration.burst", 5)
+	viper.SetDefault("rate_limit.snippet.requests_per_minute", 30)
+	viper.SetDefault("rate_limit.snippet.burst", 10)
+	viper.SetDefault("services.analysis.snippet_max_bytes", 1<<20)
+	viper.SetDefault("challenge.provider", "none")
 	viper.SetDefault("cors.max_age", 86400)
+	viper.SetDefault("circuit_breaker.failure_threshold", 5)
+	viper.SetDefault("circuit_breaker.reset_timeout", "30s")
+	viper.SetDefault("projects.branch_fallbacks", services.DefaultBranchFallbacks)
+	viper.SetDefault("projects.fetch_timeout", "10s")
+	viper.SetDefault("auth.password_hash_algorithm", services.HashAlgorithmBcrypt)
+	viper.SetDefault("auth.session_cleanup_interval", "1h")
+	viper.SetDefault("auth.session_cleanup_grace", "24h")
+	viper.SetDefault("telemetry.sample_ratio", 1.0)
+	viper.SetDefault("telemetry.metrics.interval", telemetry.DefaultMetricsInterval)
+	viper.SetDefault("request_id.header", utils.RequestIDHeader)
+	viper.SetDefault("path_limits.max_length", 2048)
+	viper.SetDefault("path_limits.max_segments", 64)
+	viper.SetDefault("security_headers.content_type_options", middleware.DefaultSecurityHeaders.ContentTypeOptions)
+	viper.SetDefault("security_headers.frame_options", middleware.DefaultSecurityHeaders.FrameOptions)
+	viper.SetDefault("security_headers.referrer_policy", middleware.DefaultSecurityHeaders.ReferrerPolicy)
+	viper.SetDefault("security_headers.content_security_policy", middleware.DefaultSecurityHeaders.ContentSecurityPolicy)
+	viper.SetDefault("security_headers.hsts.max_age", middleware.DefaultSecurityHeaders.HSTS.MaxAge)
+	viper.SetDefault("security_headers.hsts.include_subdomains", middleware.DefaultSecurityHeaders.HSTS.IncludeSubdomains)
+	viper.SetDefault("security_headers.hsts.preload", middleware.DefaultSecurityHeaders.HSTS.Preload)
+	viper.SetDefault("maintenance.scope", middleware.MaintenanceWrites)
+	viper.SetDefault("maintenance.retry_after", middleware.DefaultMaintenanceRetryAfter)
+	viper.SetDefault("maintenance.allowed_paths", middleware.DefaultMaintenanceAllowedPaths)
+	viper.SetDefault("concurrency.max_in_flight", 0)
+	viper.SetDefault("concurrency.retry_after", middleware.DefaultConcurrencyRetryAfter)
+	viper.SetDefault("concurrency.exempt_paths", middleware.DefaultConcurrencyExemptPaths)
+	viper.SetDefault("concurrency.websocket_routes", middleware.DefaultWebSocketRoutes)
+	viper.SetDefault("features."+services.FlagRequireEmailVerification, false)
+	viper.SetDefault("features."+services.FlagMaintenance, false)
+	viper.SetDefault("feature_refresh_interval", "30s")
+	viper.SetDefault("event_bridge.enabled", false)
+	viper.SetDefault("event_bridge.topic", eventbridge.DefaultTopic)
+	viper.SetDefault("event_bridge.queue_size", eventbridge.DefaultQueueSize)
+	viper.SetDefault("event_bridge.commit_interval", eventbridge.DefaultCommitInterval)
 
 	// Read from environment variables
 	viper.SetEnvPrefix("GATEWAY")
@@ -223,6 +504,12 @@ func loadConfig(secretManager *utils.SecretManager) (*Config, error) {
 	}
 	config.Auth.JWTSecret = jwtSecret
 
+	internalToken, err := secretManager.GetInternalToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get internal auth token: %w", err)
+	}
+	config.Auth.InternalToken = internalToken
+
 	// Set default token duration if not specified
 	if config.Auth.TokenDuration == 0 {
 		config.Auth.TokenDuration = 24 * time.Hour
@@ -231,6 +518,89 @@ func loadConfig(secretManager *utils.SecretManager) (*Config, error) {
 	return &config, nil
 }
 
+// runSessionSweeper periodically purges expired sessions until ctx is done
+func runSessionSweeper(ctx context.Context, authService *services.AuthService, interval, grace time.Duration, logger *logrus.Logger) {
+	if interval <= 0 {
+		logger.Info("Session cleanup disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := authService.PurgeExpiredSessions(grace); err != nil {
+			logger.WithError(err).Error("Failed to purge expired sessions")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// eventBridgeRun is a running event bridge consumer
+type eventBridgeRun struct {
+	cancel context.CancelFunc
+	done   chan error
+}
+
+// startEventBridge starts consuming analysis events in the background
+func startEventBridge(config eventbridge.Config, meter metric.Meter, logger *logrus.Logger) (*eventBridgeRun, error) {
+	if len(config.Brokers) == 0 {
+		return nil, fmt.Errorf("event_bridge.brokers is required")
+	}
+	consumer := eventbridge.NewConsumer(eventbridge.NewReader(config), relayAnalysisEvent(logger), config, logger)
+	if err := consumer.SetMeter(meter); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &eventBridgeRun{cancel: cancel, done: make(chan error, 1)}
+	go func() {
+		err := consumer.Run(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.WithError(err).Error("Event bridge stopped")
+		}
+		run.done <- err
+	}()
+	return run, nil
+}
+
+// Close stops consuming and commits the offsets of dispatched events
+func (r *eventBridgeRun) Close() error {
+	r.cancel()
+	return <-r.done
+}
+
+// relayAnalysisEvent hands analysis events to the gateway's live clients.
+// The WebSocket handler doesn't serve clients yet, so events are logged.
+func relayAnalysisEvent(logger *logrus.Logger) eventbridge.Handler {
+	return func(_ context.Context, event *events.Event) error {
+		logger.WithFields(logrus.Fields{
+			"event_type":  event.Type,
+			"analysis_id": event.AnalysisID,
+		}).Debug("Received analysis event")
+		return nil
+	}
+}
+
+func newChallengeVerifier(config *Config) (handler.ChallengeVerifier, error) {
+	switch config.Challenge.Provider {
+	case "", "none":
+		return handler.NoopChallengeVerifier{}, nil
+	case "turnstile":
+		if config.Challenge.Secret == "" {
+			return nil, fmt.Errorf("challenge.secret is required for the turnstile provider")
+		}
+		return handler.NewTurnstileVerifier(config.Challenge.Secret, config.Challenge.VerifyURL), nil
+	default:
+		return nil, fmt.Errorf("unknown challenge provider: %s", config.Challenge.Provider)
+	}
+}
+
 func initializeServiceProxies(config *Config, logger *logrus.Logger) map[string]*proxy.ServiceProxy {
 	proxies := make(map[string]*proxy.ServiceProxy)
 
@@ -277,29 +647,210 @@ func initializeServiceProxies(config *Config, logger *logrus.Logger) map[string]
 	return proxies
 }
 
+// initializeCircuitBreakers creates a circuit breaker for each configured
+// service proxy
+func initializeCircuitBreakers(serviceProxies map[string]*proxy.ServiceProxy, config *Config) map[string]*proxy.CircuitBreaker {
+	breakers := make(map[string]*proxy.CircuitBreaker, len(serviceProxies))
+	for name, serviceProxy := range serviceProxies {
+		breakers[name] = proxy.NewCircuitBreaker(serviceProxy, config.CircuitBreaker.FailureThreshold, config.CircuitBreaker.ResetTimeout)
+	}
+	return breakers
+}
+
+// routeLimits are the per-client rate limits of individual routes
+type routeLimits struct {
+	registration *middleware.IPRateLimit
+	snippet      *middleware.IPRateLimit
+}
+
+// reloadableSettings returns the parts of config applied without a restart
+func reloadableSettings(config *Config) reload.Settings {
+	corsGroupsByPrefix := make(map[string]middleware.CORSConfig, len(config.CORS.Groups))
+	for name, groupConfig := range config.CORS.Groups {
+		if prefix, ok := corsGroups[name]; ok {
+			corsGroupsByPrefix[prefix] = groupConfig
+		}
+	}
+	return reload.Settings{
+		RateLimit: reload.RateLimit{Rate: rate.Limit(config.RateLimit.RequestsPerSecond), Burst: config.RateLimit.Burst},
+		Registration: reload.RateLimit{
+			Rate:  rate.Limit(float64(config.RateLimit.Registration.RequestsPerMinute) / 60),
+			Burst: config.RateLimit.Registration.Burst,
+		},
+		Snippet: reload.RateLimit{
+			Rate:  rate.Limit(float64(config.RateLimit.Snippet.RequestsPerMinute) / 60),
+			Burst: config.RateLimit.Snippet.Burst,
+		},
+		CORS:       config.CORS.CORSConfig,
+		CORSGroups: corsGroupsByPrefix,
+		ServiceTimeouts: map[string]time.Duration{
+			"analysis":      config.Services.Analysis.Timeout,
+			"visualization": config.Services.Visualization.Timeout,
+			"collaboration": config.Services.Collaboration.Timeout,
+			"metrics":       config.Services.Metrics.Timeout,
+		},
+		Features: config.Features,
+	}
+}
+
+// reloadConfig reads the config again and applies what changed without a
+// restart, warning about changes that need one. It returns the config now
+// in effect, which is current when reading or applying the new one fails.
+func reloadConfig(secretManager *utils.SecretManager, current *Config, reloader *reload.Reloader, logger *logrus.Logger) *Config {
+	logger.Info("Reloading configuration")
+	next, err := loadConfig(secretManager)
+	if err != nil {
+		logger.WithError(err).Error("Failed to reload configuration, keeping the current one")
+		return current
+	}
+	for _, field := range restartRequired(current, next) {
+		logger.WithField("field", field).Warn("Configuration change needs a restart to take effect")
+	}
+	changes, err := reloader.Apply(reloadableSettings(next))
+	if err != nil {
+		logger.WithError(err).Error("Failed to reload configuration, keeping the current one")
+		return current
+	}
+	if len(changes) == 0 {
+		logger.Info("Configuration reloaded without changes")
+	}
+	return next
+}
+
+// restartRequired names the changed fields only read at startup, such as
+// ports, addresses and secrets
+func restartRequired(current, next *Config) []string {
+	var fields []string
+	check := func(field string, changed bool) {
+		if changed {
+			fields = append(fields, field)
+		}
+	}
+	check("server.port", current.Server.Port != next.Server.Port)
+	check("server.read_timeout", current.Server.ReadTimeout != next.Server.ReadTimeout)
+	check("server.write_timeout", current.Server.WriteTimeout != next.Server.WriteTimeout)
+	check("server.trusted_proxies", !slices.Equal(current.Server.TrustedProxies, next.Server.TrustedProxies))
+	check("redis", current.Redis != next.Redis)
+	check("services.analysis.url", current.Services.Analysis.URL != next.Services.Analysis.URL)
+	check("services.visualization.url", current.Services.Visualization.URL != next.Services.Visualization.URL)
+	check("services.collaboration.url", current.Services.Collaboration.URL != next.Services.Collaboration.URL)
+	check("services.metrics.url", current.Services.Metrics.URL != next.Services.Metrics.URL)
+	check("auth.jwt_secret", current.Auth.JWTSecret != next.Auth.JWTSecret)
+	check("auth.token_duration", current.Auth.TokenDuration != next.Auth.TokenDuration)
+	check("auth.internal_token", current.Auth.InternalToken != next.Auth.InternalToken)
+	check("rate_limit.exempt_paths", !slices.Equal(current.RateLimit.ExemptPaths, next.RateLimit.ExemptPaths))
+	check("concurrency", !reflect.DeepEqual(current.Concurrency, next.Concurrency))
+	check("event_bridge", !reflect.DeepEqual(current.EventBridge, next.EventBridge))
+	return fields
+}
+
+// routeMediaTypes are the media types routes serve besides JSON: the NDJSON
+// progress of synchronous runs and the HTML reference
+var routeMediaTypes = map[string][]string{
+	"/api/v1/analysis/run-sync/:projectId": {"application/x-ndjson"},
+	"/docs":                                {"text/html"},
+}
+
+// corsGroups maps the route group names cors.groups configures to their
+// path prefixes. The more specific auth and admin groups take precedence
+// over api.
+var corsGroups = map[string]string{
+	"auth":  "/api/v1/auth",
+	"admin": "/api/v1/admin",
+	"api":   "/api/v1",
+	"ws":    "/ws",
+}
+
+// applyGroupCORS sets the configured CORS rules of each route group
+func applyGroupCORS(corsPolicy *middleware.CORSPolicy, groups map[string]middleware.CORSConfig, logger *logrus.Logger) {
+	for name, groupConfig := range groups {
+		prefix, ok := corsGroups[name]
+		if !ok {
+			logger.Warnf("Ignoring CORS rules for unknown route group %q", name)
+			continue
+		}
+		corsPolicy.SetGroup(prefix, groupConfig)
+	}
+}
+
+// setupMiddleware installs the middleware every request passes through.
+// Request metrics and maintenance mode follow it once their dependencies are
+// up.
+func setupMiddleware(
+	router *gin.Engine,
+	config *Config,
+	corsPolicy *middleware.CORSPolicy,
+	limiter *rate.Limiter,
+	requestCounter *middleware.RequestCounter,
+	tracer trace.Tracer,
+	logger *logrus.Logger,
+) {
+	trustedProxies, err := middleware.NewTrustedProxies(config.Server.TrustedProxies)
+	if err != nil {
+		logger.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
+	router.Use(gin.Recovery())
+	router.Use(middleware.Logger(logger))
+	router.Use(requestCounter.Middleware())
+	router.Use(middleware.RequestIDWithConfig(config.RequestID))
+	router.Use(middleware.ForwardedHeaders(trustedProxies))
+	router.Use(middleware.SecurityHeaders(config.SecurityHeaders, trustedProxies))
+	router.Use(middleware.RejectAmbiguousHeaders())
+	router.Use(corsPolicy.Middleware())
+	router.Use(middleware.PathLimits(config.PathLimits))
+	router.Use(middleware.ContentNegotiation(middleware.NegotiationConfig{Routes: routeMediaTypes}))
+	router.Use(middleware.RateLimiter(limiter, config.Auth.InternalToken, config.RateLimit.ExemptPaths...))
+	router.Use(middleware.ConcurrencyLimit(config.Concurrency))
+	router.Use(middleware.Tracing(tracer))
+}
+
 func setupRoutes(
 	router *gin.Engine,
+	corsPolicy *middleware.CORSPolicy,
+	limits routeLimits,
 	authHandler *handler.ProductionAuthHandler,
+	projectHandler *handler.ProjectHandler,
 	healthHandler *handler.HealthHandler,
 	serviceProxies map[string]*proxy.ServiceProxy,
+	circuitBreakers map[string]*proxy.CircuitBreaker,
 	authService *services.AuthService,
+	redisClient *redis.Client,
 	config *Config,
 	logger *logrus.Logger,
 ) {
+	// Route groups may allow other origins than the rest of the gateway
+	applyGroupCORS(corsPolicy, config.CORS.Groups, logger)
+
 	// Health check
 	router.GET("/health", healthHandler.Health)
 	router.GET("/health/ready", healthHandler.Ready)
 	router.GET("/health/live", healthHandler.Live)
 
+	// API description
+	openAPIHandler, err := handler.NewOpenAPIHandler(gatewaySpec())
+	if err != nil {
+		logger.Fatalf("Failed to build OpenAPI document: %v", err)
+	}
+	router.GET("/openapi.json", openAPIHandler.Spec)
+	router.GET("/docs", openAPIHandler.Docs)
+
 	// Auth routes (public)
 	auth := router.Group("/api/v1/auth")
 	{
-		auth.POST("/register", authHandler.Register)
+		auth.POST("/register", limits.registration.Middleware(), authHandler.Register)
 		auth.POST("/login", authHandler.Login)
 		auth.POST("/refresh", authHandler.RefreshToken)
 		auth.GET("/validate", authHandler.ValidateToken)
 	}
 
+	// Internal auth routes, for backends
+	authInternal := router.Group("/api/v1/auth")
+	authInternal.Use(middleware.InternalAuth(config.Auth.InternalToken))
+	{
+		authInternal.POST("/validate-batch", authHandler.ValidateBatch)
+	}
+
 	// Protected auth routes
 	authProtected := router.Group("/api/v1/auth")
 	authProtected.Use(middleware.ProductionAuth(authService, logger))
@@ -309,61 +860,93 @@ func setupRoutes(
 		authProtected.POST("/change-password", authHandler.ChangePassword)
 	}
 
+	// Who the caller is, for users and backends alike
+	router.GET("/api/v1/me", middleware.UserOrInternalAuth(authService, config.Auth.InternalToken, logger), authHandler.Me)
+
+	// Admin user management routes
+	adminUsers := router.Group("/api/v1/admin/users")
+	adminUsers.Use(middleware.ProductionRequireAdmin(authService, logger))
+	{
+		adminHandler := handler.NewAdminHandler(authService, logger)
+		adminUsers.GET("", adminHandler.ListUsers)
+		adminUsers.PUT("/:id/role", adminHandler.SetUserRole)
+		adminUsers.POST("/:id/deactivate", adminHandler.DeactivateUser)
+		adminUsers.POST("/:id/activate", adminHandler.ActivateUser)
+		adminUsers.POST("/:id/unlock", adminHandler.UnlockUser)
+	}
+
 	// API routes with authentication
 	api := router.Group("/api/v1")
 	api.Use(middleware.ProductionAuth(authService, logger))
+	if config.ResponseCache.Enabled {
+		api.Use(middleware.NewResponseCache(redisClient, config.ResponseCache.Routes, logger).Middleware())
+	}
 	{
+		// Current user's sessions
+		me := api.Group("/me")
+		{
+			me.GET("/sessions", authHandler.ListSessions)
+			me.DELETE("/sessions", authHandler.RevokeOtherSessions)
+			me.DELETE("/sessions/:id", authHandler.RevokeSession)
+		}
+
 		// Analysis routes
-		if analysisProxy, ok := serviceProxies["analysis"]; ok {
-			analysis := api.Group("/analysis")
-			{
-				analysis.POST("/start/:projectId", createProxyHandler(analysisProxy, "POST", "/analysis/start"))
-				analysis.GET("/status/:analysisId", createProxyHandler(analysisProxy, "GET", "/analysis/status"))
-				analysis.DELETE("/cancel/:analysisId", createProxyHandler(analysisProxy, "DELETE", "/analysis/cancel"))
-				analysis.GET("/results/:analysisId", createProxyHandler(analysisProxy, "GET", "/analysis/results"))
-			}
+		analysisProxy := serviceProxyOrUnconfigured(serviceProxies, circuitBreakers, "analysis", logger)
+		analysis := api.Group("/analysis")
+		{
+			registerProxyRoute(analysis, http.MethodPost, "/start/:projectId", analysisProxy, "/analysis/start/:projectId")
+			registerProxyRoute(analysis, http.MethodPost, "/plan/:projectId", analysisProxy, "/analysis/plan/:projectId")
+			registerProxyRoute(analysis, http.MethodPost, "/partial/:projectId", analysisProxy, "/analysis/partial/:projectId")
+			registerProxyRoute(analysis, http.MethodPost, "/run-sync/:projectId",
+				serviceStreamOrUnconfigured(serviceProxies, circuitBreakers, "analysis", logger), "/analysis/run-sync/:projectId")
+			snippets := analysis.Group("",
+				limits.snippet.Middleware(),
+				middleware.MaxBodySize(config.Services.Analysis.SnippetMaxBytes))
+			registerProxyRoute(snippets, http.MethodPost, "/snippet", analysisProxy, "/analysis/snippet")
+			registerProxyRoute(analysis, http.MethodGet, "/status/:analysisId", analysisProxy, "/analysis/status/:analysisId")
+			registerProxyRoute(analysis, http.MethodDelete, "/cancel/:analysisId", analysisProxy, "/analysis/cancel/:analysisId")
+			registerProxyRoute(analysis, http.MethodGet, "/results/:analysisId", analysisProxy, "/analysis/results/:analysisId")
+			registerProxyRoute(analysis, http.MethodGet, "/callgraph/:analysisId", analysisProxy, "/analysis/callgraph/:analysisId")
+			registerProxyRoute(analysis, http.MethodGet, "/issues/:analysisId", analysisProxy, "/analysis/issues/:analysisId")
+			registerProxyRoute(analysis, http.MethodGet, "/compare", analysisProxy, "/analysis/compare")
 		}
 
 		// Visualization routes
-		if vizProxy, ok := serviceProxies["visualization"]; ok {
-			viz := api.Group("/visualization")
-			{
-				viz.GET("/project/:projectId", createProxyHandler(vizProxy, "GET", "/visualization/project"))
-				viz.POST("/render", createProxyHandler(vizProxy, "POST", "/visualization/render"))
-				viz.GET("/layouts", createProxyHandler(vizProxy, "GET", "/visualization/layouts"))
-				viz.PUT("/layout/:projectId", createProxyHandler(vizProxy, "PUT", "/visualization/layout"))
-			}
+		vizProxy := serviceProxyOrUnconfigured(serviceProxies, circuitBreakers, "visualization", logger)
+		viz := api.Group("/visualization")
+		{
+			registerProxyRoute(viz, http.MethodGet, "/project/:projectId", vizProxy, "/visualization/project/:projectId")
+			registerProxyRoute(viz, http.MethodPost, "/render", vizProxy, "/visualization/render")
+			registerProxyRoute(viz, http.MethodGet, "/layouts", vizProxy, "/visualization/layouts")
+			registerProxyRoute(viz, http.MethodPut, "/layout/:projectId", vizProxy, "/visualization/layout/:projectId")
 		}
 
 		// Collaboration routes
-		if collabProxy, ok := serviceProxies["collaboration"]; ok {
-			collab := api.Group("/collaboration")
-			{
-				collab.GET("/session/:projectId", createProxyHandler(collabProxy, "GET", "/collaboration/session"))
-				collab.POST("/session/join", createProxyHandler(collabProxy, "POST", "/collaboration/session/join"))
-				collab.POST("/session/leave", createProxyHandler(collabProxy, "POST", "/collaboration/session/leave"))
-				collab.GET("/annotations/:projectId", createProxyHandler(collabProxy, "GET", "/collaboration/annotations"))
-				collab.POST("/annotation", createProxyHandler(collabProxy, "POST", "/collaboration/annotation"))
-				collab.PUT("/annotation/:id", createProxyHandler(collabProxy, "PUT", "/collaboration/annotation"))
-				collab.DELETE("/annotation/:id", createProxyHandler(collabProxy, "DELETE", "/collaboration/annotation"))
-			}
+		collabProxy := serviceProxyOrUnconfigured(serviceProxies, circuitBreakers, "collaboration", logger)
+		collab := api.Group("/collaboration")
+		{
+			registerProxyRoute(collab, http.MethodGet, "/session/:projectId", collabProxy, "/collaboration/session/:projectId")
+			registerProxyRoute(collab, http.MethodPost, "/session/join", collabProxy, "/collaboration/session/join")
+			registerProxyRoute(collab, http.MethodPost, "/session/leave", collabProxy, "/collaboration/session/leave")
+			registerProxyRoute(collab, http.MethodGet, "/annotations/:projectId", collabProxy, "/collaboration/annotations/:projectId")
+			registerProxyRoute(collab, http.MethodPost, "/annotation", collabProxy, "/collaboration/annotation")
+			registerProxyRoute(collab, http.MethodPut, "/annotation/:id", collabProxy, "/collaboration/annotation/:id")
+			registerProxyRoute(collab, http.MethodDelete, "/annotation/:id", collabProxy, "/collaboration/annotation/:id")
 		}
 
 		// Metrics routes
-		if metricsProxy, ok := serviceProxies["metrics"]; ok {
-			metrics := api.Group("/metrics")
-			{
-				metrics.GET("/project/:projectId", createProxyHandler(metricsProxy, "GET", "/metrics/project"))
-				metrics.GET("/file/:projectId/:filePath", createProxyHandler(metricsProxy, "GET", "/metrics/file"))
-				metrics.GET("/trends/:projectId", createProxyHandler(metricsProxy, "GET", "/metrics/trends"))
-				metrics.GET("/compare", createProxyHandler(metricsProxy, "GET", "/metrics/compare"))
-			}
+		metricsProxy := serviceProxyOrUnconfigured(serviceProxies, circuitBreakers, "metrics", logger)
+		metrics := api.Group("/metrics")
+		{
+			registerProxyRoute(metrics, http.MethodGet, "/project/:projectId", metricsProxy, "/metrics/project/:projectId")
+			registerProxyRoute(metrics, http.MethodGet, "/file/:projectId/*filePath", metricsProxy, "/metrics/file/:projectId/:filePath")
+			registerProxyRoute(metrics, http.MethodGet, "/trends/:projectId", metricsProxy, "/metrics/trends/:projectId")
+			registerProxyRoute(metrics, http.MethodGet, "/compare", metricsProxy, "/metrics/compare")
 		}
 
 		// Project routes (handled by API Gateway directly)
 		projects := api.Group("/projects")
 		{
-			projectHandler := handler.NewProjectHandler(logger)
 			projects.GET("", projectHandler.ListProjects)
 			projects.POST("", projectHandler.CreateProject)
 			projects.GET("/:id", projectHandler.GetProject)
@@ -374,11 +957,56 @@ func setupRoutes(
 
 	// WebSocket endpoint for real-time updates
 	router.GET("/ws", middleware.ProductionAuth(authService, logger), createWebSocketHandler(serviceProxies, logger))
+
+	// Unknown paths and methods
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(handler.NoRoute)
+	router.NoMethod(handler.NoMethod)
 }
 
-func createProxyHandler(serviceProxy *proxy.ServiceProxy, method, path string) gin.HandlerFunc {
+// proxyCall forwards a request to a backend path
+type proxyCall func(c *gin.Context, method, path string)
+
+func createProxyHandler(call proxyCall, method, path string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		serviceProxy.ProxyRequest(c, method, path)
+		call(c, method, path)
+	}
+}
+
+// serviceProxyOrUnconfigured returns the call forwarding to the named
+// service through its circuit breaker, or one that answers 503 when the
+// service has no URL configured, so its routes still explain why they can't
+// be served
+func serviceProxyOrUnconfigured(serviceProxies map[string]*proxy.ServiceProxy, circuitBreakers map[string]*proxy.CircuitBreaker, name string, logger *logrus.Logger) proxyCall {
+	if breaker, ok := circuitBreakers[name]; ok {
+		return breaker.Call
+	}
+	if serviceProxy, ok := serviceProxies[name]; ok {
+		return serviceProxy.ProxyRequest
+	}
+	return proxy.NewServiceProxy(name, "", 0, logger).ProxyRequest
+}
+
+// serviceStreamOrUnconfigured is serviceProxyOrUnconfigured for routes
+// whose responses are streamed as the service writes them
+func serviceStreamOrUnconfigured(serviceProxies map[string]*proxy.ServiceProxy, circuitBreakers map[string]*proxy.CircuitBreaker, name string, logger *logrus.Logger) proxyCall {
+	if breaker, ok := circuitBreakers[name]; ok {
+		return breaker.Stream
+	}
+	if serviceProxy, ok := serviceProxies[name]; ok {
+		return serviceProxy.StreamRequest
+	}
+	return proxy.NewServiceProxy(name, "", 0, logger).StreamRequest
+}
+
+// registerProxyRoute routes method requests for relativePath to the backend
+// path, which refers to the route's parameters as :name segments. GET
+// routes also answer HEAD.
+func registerProxyRoute(routes gin.IRoutes, method, relativePath string, call proxyCall, path string) {
+	handler := createProxyHandler(call, method, path)
+	routes.Handle(method, relativePath, handler)
+	if method == http.MethodGet {
+		routes.HEAD(relativePath, handler)
 	}
 }
 
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/eventbridge"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+)
+
+func TestStartEventBridge(t *testing.T) {
+	logger := testutil.NewTestLogger()
+	meter := metricnoop.NewMeterProvider().Meter("test")
+
+	_, err := startEventBridge(eventbridge.Config{}, meter, logger)
+	assert.ErrorContains(t, err, "event_bridge.brokers")
+
+	bridge, err := startEventBridge(eventbridge.Config{Brokers: []string{"127.0.0.1:1"}}, meter, logger)
+	require.NoError(t, err)
+	closed := make(chan error, 1)
+	go func() { closed <- bridge.Close() }()
+	select {
+	case err := <-closed:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing the bridge didn't stop its consumer")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/handler"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/openapi"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+)
+
+// apiVersion is the version of the gateway's API the OpenAPI document
+// describes
+const apiVersion = "1.0.0"
+
+// Security schemes of the OpenAPI document
+var (
+	bearerAuth   = []openapi.SecurityRequirement{{"bearer": {}}}
+	internalAuth = []openapi.SecurityRequirement{{"internal": {}}}
+)
+
+// specGroup adds the operations of a group of routes, which share a tag and
+// an authentication requirement
+type specGroup struct {
+	doc      *openapi.Document
+	tag      string
+	security []openapi.SecurityRequirement
+}
+
+// add adds an operation answering status with response, taking request as
+// its JSON body when it isn't nil
+func (g specGroup) add(method, route, summary string, status int, request, response *openapi.Schema, parameters ...openapi.Parameter) {
+	operation := openapi.Operation{
+		Summary:    summary,
+		Tags:       []string{g.tag},
+		Parameters: parameters,
+		Security:   g.security,
+		Responses: map[string]openapi.Response{
+			strconv.Itoa(status): {Description: http.StatusText(status)},
+			"default":            {Description: "Error", Content: openapi.JSON(&openapi.Schema{Ref: "#/components/schemas/Error"})},
+		},
+	}
+	if response != nil {
+		operation.Responses[strconv.Itoa(status)] = openapi.Response{Description: http.StatusText(status), Content: openapi.JSON(response)}
+	}
+	if request != nil {
+		operation.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(request)}
+	}
+	if g.security != nil {
+		operation.Responses["401"] = openapi.Response{Description: "Missing or invalid credentials"}
+	}
+	g.doc.Add(method, route, operation)
+}
+
+// gatewaySpec describes the routes setupRoutes registers. Every route needs
+// an operation here, which TestGatewaySpec_CoversRoutes checks. Bodies of
+// routes proxied to a backend are described as objects of the backend.
+func gatewaySpec() *openapi.Document {
+	doc := openapi.New("SA3D API Gateway", apiVersion)
+	doc.Components.SecuritySchemes["bearer"] = openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+	doc.Components.SecuritySchemes["internal"] = openapi.SecurityScheme{
+		Type: "apiKey", In: "header", Name: middleware.InternalTokenHeader,
+		Description: "The internal token backends share with the gateway",
+	}
+	doc.Components.Schemas["Error"] = openapi.Object(map[string]*openapi.Schema{
+		"error":   openapi.String(),
+		"code":    openapi.String(),
+		"message": openapi.String(),
+		"details": {Description: "What was wrong with the request, as text or by field"},
+	}, "error")
+
+	message := openapi.Object(map[string]*openapi.Schema{"message": openapi.String()})
+	user := doc.SchemaOf(models.User{})
+	tokens := openapi.Object(map[string]*openapi.Schema{
+		"message":       openapi.String(),
+		"access_token":  openapi.String(),
+		"refresh_token": openapi.String(),
+		"expires_at":    {Type: "string", Format: "date-time"},
+		"user":          user,
+	})
+	backend := func(service string) *openapi.Schema {
+		return openapi.AnyObject("Served by the " + service + " service")
+	}
+
+	health := specGroup{doc: doc, tag: "Health"}
+	health.add(http.MethodGet, "/health", "Report the health of the gateway and its backends", http.StatusOK, nil, doc.SchemaOf(handler.HealthResponse{}))
+	health.add(http.MethodGet, "/health/ready", "Report whether the gateway takes traffic", http.StatusOK, nil, openapi.Object(map[string]*openapi.Schema{
+		"status": openapi.String(),
+		"errors": openapi.Array(openapi.String()),
+	}))
+	health.add(http.MethodGet, "/health/live", "Report that the gateway is running", http.StatusOK, nil, openapi.Object(map[string]*openapi.Schema{
+		"status":    openapi.String(),
+		"timestamp": {Type: "integer", Format: "int64"},
+	}))
+
+	docs := specGroup{doc: doc, tag: "Documentation"}
+	docs.add(http.MethodGet, "/openapi.json", "Describe the API as an OpenAPI 3 document", http.StatusOK, nil, openapi.AnyObject("This document"))
+	docs.add(http.MethodGet, "/docs", "Describe the API as an HTML reference", http.StatusOK, nil, nil)
+
+	auth := specGroup{doc: doc, tag: "Auth"}
+	auth.add(http.MethodPost, "/api/v1/auth/register", "Register a user", http.StatusCreated, doc.SchemaOf(services.UserRegistration{}), openapi.Object(map[string]*openapi.Schema{
+		"message": openapi.String(),
+		"user":    user,
+	}), openapi.Parameter{Name: handler.ChallengeTokenHeader, In: "header", Description: "The human verification token, when a challenge provider is configured", Schema: openapi.String()})
+	auth.add(http.MethodPost, "/api/v1/auth/login", "Log in with email and password", http.StatusOK, doc.SchemaOf(services.UserLogin{}), tokens)
+	auth.add(http.MethodPost, "/api/v1/auth/refresh", "Exchange a refresh token for new tokens", http.StatusOK, openapi.Object(map[string]*openapi.Schema{
+		"refresh_token": openapi.String(),
+	}, "refresh_token"), tokens)
+	auth.add(http.MethodGet, "/api/v1/auth/validate", "Validate the presented token", http.StatusOK, nil, openapi.Object(map[string]*openapi.Schema{
+		"valid":   openapi.Boolean(),
+		"user_id": openapi.String(),
+		"email":   openapi.String(),
+		"role":    openapi.String(),
+	}))
+
+	authInternal := specGroup{doc: doc, tag: "Auth", security: internalAuth}
+	authInternal.add(http.MethodPost, "/api/v1/auth/validate-batch", "Validate up to 100 tokens for a backend", http.StatusOK, doc.SchemaOf(handler.ValidateBatchRequest{}), openapi.Object(map[string]*openapi.Schema{
+		"results": openapi.Array(doc.SchemaOf(handler.TokenValidation{})),
+	}))
+
+	authProtected := specGroup{doc: doc, tag: "Auth", security: bearerAuth}
+	authProtected.add(http.MethodPost, "/api/v1/auth/logout", "End the current session", http.StatusOK, nil, message)
+	authProtected.add(http.MethodGet, "/api/v1/auth/profile", "Get the current user", http.StatusOK, nil, openapi.Object(map[string]*openapi.Schema{"user": user}))
+	authProtected.add(http.MethodPost, "/api/v1/auth/change-password", "Change the current user's password", http.StatusOK, openapi.Object(map[string]*openapi.Schema{
+		"current_password": openapi.String(),
+		"new_password":     openapi.String(),
+	}, "current_password", "new_password"), message)
+
+	identity := specGroup{doc: doc, tag: "Auth", security: append(slices.Clone(bearerAuth), internalAuth...)}
+	identity.add(http.MethodGet, "/api/v1/me", "Describe the caller, a user or a backend, with its roles and permissions", http.StatusOK, nil, doc.SchemaOf(handler.Identity{}))
+
+	sessions := specGroup{doc: doc, tag: "Sessions", security: bearerAuth}
+	sessions.add(http.MethodGet, "/api/v1/me/sessions", "List the current user's active sessions", http.StatusOK, nil, openapi.Object(map[string]*openapi.Schema{
+		"sessions": openapi.Array(doc.SchemaOf(handler.SessionInfo{})),
+		"total":    openapi.Integer(),
+	}))
+	sessions.add(http.MethodDelete, "/api/v1/me/sessions", "Revoke the current user's other sessions", http.StatusOK, nil, openapi.Object(map[string]*openapi.Schema{
+		"revoked": openapi.Integer(),
+	}))
+	sessions.add(http.MethodDelete, "/api/v1/me/sessions/:id", "Revoke one of the current user's sessions", http.StatusNoContent, nil, nil)
+
+	admin := specGroup{doc: doc, tag: "Admin", security: bearerAuth}
+	admin.add(http.MethodGet, "/api/v1/admin/users", "List users", http.StatusOK, nil, doc.SchemaOf(services.UserList{}),
+		openapi.Query("search", "Matches email, username and names", openapi.String()),
+		openapi.Query("role", "", openapi.String()),
+		openapi.Query("is_active", "", openapi.Boolean()),
+		openapi.Query("page", "", openapi.Integer()),
+		openapi.Query("page_size", "", openapi.Integer()))
+	admin.add(http.MethodPut, "/api/v1/admin/users/:id/role", "Set a user's role", http.StatusOK, doc.SchemaOf(handler.SetUserRoleRequest{}), message)
+	admin.add(http.MethodPost, "/api/v1/admin/users/:id/deactivate", "Deactivate a user", http.StatusOK, nil, message)
+	admin.add(http.MethodPost, "/api/v1/admin/users/:id/activate", "Activate a user", http.StatusOK, nil, message)
+	admin.add(http.MethodPost, "/api/v1/admin/users/:id/unlock", "Unlock a user locked out by failed logins", http.StatusOK, nil, message)
+
+	project := doc.SchemaOf(handler.Project{})
+	projects := specGroup{doc: doc, tag: "Projects", security: bearerAuth}
+	projects.add(http.MethodGet, "/api/v1/projects", "List the current user's projects", http.StatusOK, nil, openapi.Object(map[string]*openapi.Schema{
+		"projects": openapi.Array(project),
+		"total":    openapi.Integer(),
+	}))
+	projects.add(http.MethodPost, "/api/v1/projects", "Create a project", http.StatusCreated, doc.SchemaOf(handler.CreateProjectRequest{}), project)
+	projects.add(http.MethodGet, "/api/v1/projects/:id", "Get a project", http.StatusOK, nil, project)
+	projects.add(http.MethodPut, "/api/v1/projects/:id", "Update a project", http.StatusOK, doc.SchemaOf(handler.UpdateProjectRequest{}), project)
+	projects.add(http.MethodDelete, "/api/v1/projects/:id", "Delete a project with its analyses", http.StatusNoContent, nil, nil)
+
+	analysis := specGroup{doc: doc, tag: "Analysis", security: bearerAuth}
+	analysisBody := backend("analysis")
+	analysis.add(http.MethodPost, "/api/v1/analysis/start/:projectId", "Start analyzing a project", http.StatusAccepted, nil, analysisBody)
+	analysis.add(http.MethodPost, "/api/v1/analysis/plan/:projectId", "Preview the files an analysis would cover", http.StatusOK, nil, analysisBody)
+	analysis.add(http.MethodPost, "/api/v1/analysis/partial/:projectId", "Analyze some of a project's files", http.StatusAccepted, analysisBody, analysisBody)
+	analysis.add(http.MethodPost, "/api/v1/analysis/run-sync/:projectId", "Analyze a project within the request", http.StatusOK, nil, analysisBody)
+	analysis.add(http.MethodPost, "/api/v1/analysis/snippet", "Analyze a code snippet within the request", http.StatusOK, analysisBody, analysisBody)
+	analysis.add(http.MethodGet, "/api/v1/analysis/status/:analysisId", "Get an analysis's status", http.StatusOK, nil, analysisBody)
+	analysis.add(http.MethodDelete, "/api/v1/analysis/cancel/:analysisId", "Cancel a pending or running analysis", http.StatusOK, nil, analysisBody)
+	analysis.add(http.MethodGet, "/api/v1/analysis/results/:analysisId", "Get an analysis's results", http.StatusOK, nil, analysisBody)
+	analysis.add(http.MethodGet, "/api/v1/analysis/callgraph/:analysisId", "Get an analysis's call graph", http.StatusOK, nil, analysisBody)
+	analysis.add(http.MethodGet, "/api/v1/analysis/issues/:analysisId", "List an analysis's issues", http.StatusOK, nil, analysisBody)
+	analysis.add(http.MethodGet, "/api/v1/analysis/compare", "Compare two analyses", http.StatusOK, nil, analysisBody)
+
+	visualization := specGroup{doc: doc, tag: "Visualization", security: bearerAuth}
+	visualizationBody := backend("visualization")
+	visualization.add(http.MethodGet, "/api/v1/visualization/project/:projectId", "Get a project's visualization", http.StatusOK, nil, visualizationBody)
+	visualization.add(http.MethodPost, "/api/v1/visualization/render", "Render a visualization", http.StatusOK, visualizationBody, visualizationBody)
+	visualization.add(http.MethodGet, "/api/v1/visualization/layouts", "List the available layouts", http.StatusOK, nil, visualizationBody)
+	visualization.add(http.MethodPut, "/api/v1/visualization/layout/:projectId", "Set a project's layout", http.StatusOK, visualizationBody, visualizationBody)
+
+	collaboration := specGroup{doc: doc, tag: "Collaboration", security: bearerAuth}
+	collaborationBody := backend("collaboration")
+	collaboration.add(http.MethodGet, "/api/v1/collaboration/session/:projectId", "Get a project's collaboration session", http.StatusOK, nil, collaborationBody)
+	collaboration.add(http.MethodPost, "/api/v1/collaboration/session/join", "Join a collaboration session", http.StatusOK, collaborationBody, collaborationBody)
+	collaboration.add(http.MethodPost, "/api/v1/collaboration/session/leave", "Leave a collaboration session", http.StatusOK, collaborationBody, collaborationBody)
+	collaboration.add(http.MethodGet, "/api/v1/collaboration/annotations/:projectId", "List a project's annotations", http.StatusOK, nil, collaborationBody)
+	collaboration.add(http.MethodPost, "/api/v1/collaboration/annotation", "Add an annotation", http.StatusCreated, collaborationBody, collaborationBody)
+	collaboration.add(http.MethodPut, "/api/v1/collaboration/annotation/:id", "Update an annotation", http.StatusOK, collaborationBody, collaborationBody)
+	collaboration.add(http.MethodDelete, "/api/v1/collaboration/annotation/:id", "Delete an annotation", http.StatusOK, nil, collaborationBody)
+
+	metrics := specGroup{doc: doc, tag: "Metrics", security: bearerAuth}
+	metricsBody := backend("metrics")
+	metrics.add(http.MethodGet, "/api/v1/metrics/project/:projectId", "Get a project's latest metrics", http.StatusOK, nil, metricsBody)
+	metrics.add(http.MethodGet, "/api/v1/metrics/file/:projectId/*filePath", "Get the metrics of a file of a project", http.StatusOK, nil, metricsBody)
+	metrics.add(http.MethodGet, "/api/v1/metrics/trends/:projectId", "Get the trends of a project's metrics", http.StatusOK, nil, metricsBody)
+	metrics.add(http.MethodGet, "/api/v1/metrics/compare", "Compare the metrics of two analyses", http.StatusOK, nil, metricsBody)
+
+	realtime := specGroup{doc: doc, tag: "Realtime", security: bearerAuth}
+	realtime.add(http.MethodGet, "/ws", "Upgrade to a WebSocket of real-time updates", http.StatusSwitchingProtocols, nil, nil)
+
+	return doc
+}
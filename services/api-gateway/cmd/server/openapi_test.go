@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/handler"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/openapi"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/proxy"
+)
+
+// TestGatewaySpec_CoversRoutes fails when a route is registered without an
+// operation in gatewaySpec, or an operation is left behind by a removed route
+func TestGatewaySpec_CoversRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	router := gin.New()
+	limits := routeLimits{
+		registration: middleware.NewIPRateLimit(1, 1),
+		snippet:      middleware.NewIPRateLimit(1, 1),
+	}
+	setupRoutes(router, middleware.NewCORSPolicy(middleware.CORSConfig{}), limits,
+		&handler.ProductionAuthHandler{}, &handler.ProjectHandler{}, &handler.HealthHandler{},
+		map[string]*proxy.ServiceProxy{}, map[string]*proxy.CircuitBreaker{},
+		nil, nil, &Config{}, logger)
+
+	doc := gatewaySpec()
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		if route.Method == http.MethodHead {
+			// HEAD answers as GET does
+			continue
+		}
+		registered[route.Method+" "+openapi.Path(route.Path)] = true
+		assert.NotNil(t, doc.Operation(route.Method, route.Path), "%s %s has no operation in gatewaySpec", route.Method, route.Path)
+	}
+
+	described := doc.Routes()
+	require.NotEmpty(t, described)
+	for _, route := range described {
+		assert.True(t, registered[route], "%s is described but not registered", route)
+	}
+}
+
+// documentedStatus is the success status an operation documents
+func documentedStatus(t *testing.T, operation *openapi.Operation) int {
+	t.Helper()
+	for code := range operation.Responses {
+		if strings.HasPrefix(code, "2") {
+			status, err := strconv.Atoi(code)
+			require.NoError(t, err)
+			return status
+		}
+	}
+	t.Fatalf("operation %q documents no success status", operation.Summary)
+	return 0
+}
+
+// TestGatewaySpec_SuccessStatuses serves the routes the gateway answers
+// without a backend and checks each answers with its documented status
+func TestGatewaySpec_SuccessStatuses(t *testing.T) {
+	gateway := newTestGateway(t, &Config{})
+	doc := gatewaySpec()
+
+	create := httptest.NewRequest(http.MethodPost, "/api/v1/projects", strings.NewReader(`{"name":"Spec","language":"go"}`))
+	create.Header.Set("Content-Type", "application/json")
+	w := gateway.serve(create)
+	require.Equal(t, documentedStatus(t, doc.Operation(http.MethodPost, "/api/v1/projects")), w.Code, w.Body.String())
+	var project handler.Project
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+
+	tests := []struct {
+		method string
+		route  string
+		path   string
+		accept string
+		body   string
+	}{
+		{http.MethodGet, "/health/live", "/health/live", "", ""},
+		{http.MethodGet, "/openapi.json", "/openapi.json", "", ""},
+		{http.MethodGet, "/docs", "/docs", "text/html", ""},
+		{http.MethodGet, "/api/v1/auth/validate", "/api/v1/auth/validate", "", ""},
+		{http.MethodGet, "/api/v1/auth/profile", "/api/v1/auth/profile", "", ""},
+		{http.MethodGet, "/api/v1/me", "/api/v1/me", "", ""},
+		{http.MethodGet, "/api/v1/me/sessions", "/api/v1/me/sessions", "", ""},
+		{http.MethodGet, "/api/v1/projects", "/api/v1/projects", "", ""},
+		{http.MethodGet, "/api/v1/projects/:id", "/api/v1/projects/" + project.ID, "", ""},
+		{http.MethodPut, "/api/v1/projects/:id", "/api/v1/projects/" + project.ID, "", `{"name":"Renamed"}`},
+		{http.MethodDelete, "/api/v1/projects/:id", "/api/v1/projects/" + project.ID, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.route, func(t *testing.T) {
+			operation := doc.Operation(tt.method, tt.route)
+			require.NotNil(t, operation)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := gateway.serve(req)
+			assert.Equal(t, documentedStatus(t, operation), w.Code, w.Body.String())
+		})
+	}
+}
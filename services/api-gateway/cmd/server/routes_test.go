@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/time/rate"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/handler"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+)
+
+// testGateway is the gateway's middleware and routes over an in-memory
+// database, with a signed-in user
+type testGateway struct {
+	router *gin.Engine
+	// token is the signed-in user's access token
+	token string
+}
+
+// newTestGateway builds the gateway as main does from config, after setting
+// what an empty config needs to serve
+func newTestGateway(t *testing.T, config *Config) *testGateway {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger := testutil.NewTestLogger()
+
+	db := testutil.NewTestDB(t, &models.User{}, &models.UserSession{}, &models.Project{}, &models.ProjectFile{},
+		// deleting a project deletes its dependents
+		&models.Analysis{}, &models.Visualization{}, &models.Session{}, &models.Participant{}, &models.Annotation{})
+	dbService := services.NewDatabaseServiceFromDB(db, nil, logger)
+	authService := services.NewAuthService(dbService, logger)
+
+	const password = "Str0ng!Passw0rd"
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &models.User{Email: "gateway@example.com", Username: "gateway", Password: string(hash), Role: "user", IsActive: true}
+	require.NoError(t, db.Create(user).Error)
+	login, err := authService.Login(services.UserLogin{Email: user.Email, Password: password})
+	require.NoError(t, err)
+
+	if config.Services.Analysis.Timeout == 0 {
+		config.Services.Analysis.Timeout = 5 * time.Second
+	}
+	serviceProxies := initializeServiceProxies(config, logger)
+	circuitBreakers := initializeCircuitBreakers(serviceProxies, config)
+	limits := routeLimits{
+		registration: middleware.NewIPRateLimit(rate.Inf, 1),
+		snippet:      middleware.NewIPRateLimit(rate.Inf, 1),
+	}
+	limiter := rate.NewLimiter(rate.Limit(config.RateLimit.RequestsPerSecond), config.RateLimit.Burst)
+	if config.RateLimit.RequestsPerSecond == 0 {
+		limiter = rate.NewLimiter(rate.Inf, 1)
+	}
+
+	router := gin.New()
+	corsPolicy := middleware.NewCORSPolicy(config.CORS.CORSConfig)
+	setupMiddleware(router, config, corsPolicy, limiter, middleware.NewRequestCounter(), tracenoop.NewTracerProvider().Tracer("test"), logger)
+	setupRoutes(router, corsPolicy, limits,
+		handler.NewProductionAuthHandler(authService, logger),
+		handler.NewProductionProjectHandler(services.NewProjectService(dbService, nil, logger), logger),
+		handler.NewHealthHandler(serviceProxies, logger),
+		serviceProxies, circuitBreakers, authService, nil, config, logger)
+
+	return &testGateway{router: router, token: login.AccessToken}
+}
+
+// serve sends req through the gateway as the signed-in user
+func (g *testGateway) serve(req *http.Request) *httptest.ResponseRecorder {
+	if req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+	w := httptest.NewRecorder()
+	g.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestProxyRoutes_Methods(t *testing.T) {
+	methods := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods <- r.Method
+		w.Header().Set("X-Total-Count", "3")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"running"}`))
+	}))
+	defer backend.Close()
+
+	config := &Config{}
+	config.Services.Analysis.URL = backend.URL
+	gateway := newTestGateway(t, config)
+	const path = "/api/v1/analysis/status/a1"
+
+	t.Run("GET", func(t *testing.T) {
+		w := gateway.serve(httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.MethodGet, <-methods)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"status":"running"}`, w.Body.String())
+	})
+
+	t.Run("HEAD", func(t *testing.T) {
+		w := gateway.serve(httptest.NewRequest(http.MethodHead, path, nil))
+		assert.Equal(t, http.MethodHead, <-methods)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("OPTIONS", func(t *testing.T) {
+		w := gateway.serve(httptest.NewRequest(http.MethodOptions, path, nil))
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, methods, "OPTIONS is answered by the gateway")
+	})
+
+	t.Run("unsupported method", func(t *testing.T) {
+		w := gateway.serve(httptest.NewRequest(http.MethodPost, path, nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
+		assert.Empty(t, methods)
+	})
+}
+
+func TestProxyRoutes_RunSyncStreams(t *testing.T) {
+	finish := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte(`{"event":"started"}` + "\n"))
+		w.(http.Flusher).Flush()
+		<-finish
+		w.Write([]byte(`{"event":"finished"}` + "\n"))
+	}))
+	defer backend.Close()
+	defer close(finish)
+
+	config := &Config{}
+	config.Services.Analysis.URL = backend.URL
+	// The run outlasts the proxy timeout, which only bounds the headers
+	config.Services.Analysis.Timeout = 50 * time.Millisecond
+	gateway := newTestGateway(t, config)
+	server := httptest.NewUnstartedServer(gateway.router)
+	// and the stream outlasts the server's write timeout too
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v1/analysis/run-sync/p1", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+gateway.token)
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	lines := bufio.NewReader(resp.Body)
+	line, err := lines.ReadString('\n')
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"event":"started"}`, line, "progress arrives before the run finishes")
+
+	time.Sleep(100 * time.Millisecond)
+	finish <- struct{}{}
+	line, err = lines.ReadString('\n')
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"event":"finished"}`, line)
+}
+
+func TestContentNegotiation_Routes(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte(`{"event":"finished"}` + "\n"))
+	}))
+	defer backend.Close()
+
+	config := &Config{}
+	config.Services.Analysis.URL = backend.URL
+	gateway := newTestGateway(t, config)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		accept     string
+		wantStatus int
+	}{
+		{"streamed run", http.MethodPost, "/api/v1/analysis/run-sync/p1", "application/x-ndjson", http.StatusOK},
+		{"html reference", http.MethodGet, "/docs", "text/html", http.StatusOK},
+		{"links", http.MethodGet, "/api/v1/projects", "application/hal+json", http.StatusOK},
+		{"json", http.MethodGet, "/api/v1/projects", "application/json", http.StatusOK},
+		{"html of a json route", http.MethodGet, "/api/v1/projects", "text/html", http.StatusNotAcceptable},
+		{"ndjson of another route", http.MethodGet, "/api/v1/analysis/status/a1", "application/x-ndjson", http.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Accept", tt.accept)
+			w := gateway.serve(req)
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestProjectLinks_ForwardedHeaders(t *testing.T) {
+	get := func(t *testing.T, gateway *testGateway) string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/projects/proj-1", nil)
+		req.Header.Set("Accept", "application/hal+json")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "sa3d.example.com")
+		req.Header.Set("X-Forwarded-Prefix", "/sa3d")
+		w := gateway.serve(req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var project handler.Project
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+		return project.Links["self"].Href
+	}
+
+	t.Run("from a client", func(t *testing.T) {
+		assert.Equal(t, "http://example.com/api/v1/projects/proj-1", get(t, newTestGateway(t, &Config{})))
+	})
+
+	t.Run("from a trusted proxy", func(t *testing.T) {
+		config := &Config{}
+		// httptest requests come from 192.0.2.1
+		config.Server.TrustedProxies = []string{"192.0.2.0/24"}
+		assert.Equal(t, "https://sa3d.example.com/sa3d/api/v1/projects/proj-1", get(t, newTestGateway(t, config)))
+	})
+}
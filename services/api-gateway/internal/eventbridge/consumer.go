@@ -0,0 +1,372 @@
+// Package eventbridge consumes the analysis events published to Kafka so the
+// gateway can relay them to connected WebSocket and SSE clients.
+package eventbridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/sa3d-modernized/sa3d/shared/events"
+)
+
+// Consumer defaults
+const (
+	// DefaultTopic is the topic analysis events are published to
+	DefaultTopic = "analysis-events"
+	// DefaultGroupID prefixes the consumer group of each gateway instance
+	DefaultGroupID = "api-gateway-event-bridge"
+	// DefaultQueueSize is how many fetched messages may wait per partition
+	DefaultQueueSize = 64
+	// DefaultCommitInterval is how often dispatched offsets are committed
+	DefaultCommitInterval = time.Second
+)
+
+// commitTimeout bounds the final commit made while shutting down
+const commitTimeout = 5 * time.Second
+
+// Config configures the consumer group reading analysis events
+type Config struct {
+	Brokers []string `mapstructure:"brokers"`
+	Topic   string   `mapstructure:"topic"`
+	// GroupID is this instance's consumer group. Each instance relays events
+	// to its own clients, so each needs every partition and a group of its
+	// own; it defaults to DefaultGroupID suffixed with the host name.
+	GroupID string `mapstructure:"group_id"`
+	// QueueSize is how many fetched messages may wait for a partition's
+	// dispatcher; when a partition's queue is full fetching blocks, so a slow
+	// dispatcher holds back the reader instead of buffering without bound
+	QueueSize int `mapstructure:"queue_size"`
+	// CommitInterval is how often the offsets of dispatched messages are
+	// committed; they are also committed when the consumer stops
+	CommitInterval time.Duration `mapstructure:"commit_interval"`
+}
+
+// Reader fetches messages for a consumer group and commits their offsets.
+// A *kafka.Reader created with a GroupID satisfies it; it assigns the
+// topic's partitions among the group's members and rebalances them.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// NewReader creates a consumer group reader for the config. Commits are
+// left to the Consumer, which only commits dispatched messages.
+func NewReader(config Config) *kafka.Reader {
+	config = withDefaults(config)
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     config.Brokers,
+		GroupID:     config.GroupID,
+		Topic:       config.Topic,
+		StartOffset: kafka.LastOffset,
+	})
+}
+
+// Handler dispatches a decoded event to its subscribers
+type Handler func(ctx context.Context, event *events.Event) error
+
+// partitionKey identifies a partition of a topic
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// Consumer reads analysis events from a consumer group and dispatches them
+// with one goroutine per partition, so events of one analysis, which share
+// a partition, are dispatched in order while partitions proceed
+// independently. A message's offset is committed only once it has been
+// dispatched, so events are dispatched at least once across restarts and
+// rebalances; events the publisher delivered twice while retrying are
+// recognized by their sequence number and dispatched once.
+type Consumer struct {
+	reader         Reader
+	handler        Handler
+	logger         *logrus.Logger
+	queueSize      int
+	commitInterval time.Duration
+	seen           *events.Deduplicator
+
+	mu         sync.Mutex
+	dispatched map[partitionKey]kafka.Message
+	lag        map[partitionKey]int64
+
+	messages metric.Int64Counter
+	failures metric.Int64Counter
+}
+
+// NewConsumer creates a consumer dispatching the reader's events to handler
+func NewConsumer(reader Reader, handler Handler, config Config, logger *logrus.Logger) *Consumer {
+	config = withDefaults(config)
+	return &Consumer{
+		reader:         reader,
+		handler:        handler,
+		logger:         logger,
+		queueSize:      config.QueueSize,
+		commitInterval: config.CommitInterval,
+		seen:           events.NewDeduplicator(events.DefaultDeduplicatorCapacity),
+		dispatched:     make(map[partitionKey]kafka.Message),
+		lag:            make(map[partitionKey]int64),
+	}
+}
+
+// SetMeter records consumer metrics with meter: dispatched messages and
+// dispatch failures as counters, and each partition's lag, the messages
+// behind its high water mark, as a gauge
+func (c *Consumer) SetMeter(meter metric.Meter) error {
+	messages, err := meter.Int64Counter("messaging.kafka.consumer.messages",
+		metric.WithDescription("Analysis events dispatched by the event bridge"))
+	if err != nil {
+		return fmt.Errorf("failed to create message counter: %w", err)
+	}
+	failures, err := meter.Int64Counter("messaging.kafka.consumer.failures",
+		metric.WithDescription("Analysis events the event bridge failed to decode or dispatch"))
+	if err != nil {
+		return fmt.Errorf("failed to create failure counter: %w", err)
+	}
+	_, err = meter.Int64ObservableGauge("messaging.kafka.consumer.lag",
+		metric.WithDescription("Messages between the last dispatched offset and the partition's high water mark"),
+		metric.WithInt64Callback(func(_ context.Context, observer metric.Int64Observer) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for key, lag := range c.lag {
+				observer.Observe(lag, metric.WithAttributes(partitionAttributes(key)...))
+			}
+			return nil
+		}))
+	if err != nil {
+		return fmt.Errorf("failed to create lag gauge: %w", err)
+	}
+	c.messages = messages
+	c.failures = failures
+	return nil
+}
+
+// Lag returns each partition's lag, by "topic/partition", as of the last
+// message dispatched from it
+func (c *Consumer) Lag() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lag := make(map[string]int64, len(c.lag))
+	for key, n := range c.lag {
+		lag[key.topic+"/"+strconv.Itoa(key.partition)] = n
+	}
+	return lag
+}
+
+// Run consumes until ctx is cancelled or the reader fails. On return,
+// messages already dispatched have their offsets committed and the reader
+// is closed; messages fetched but not yet dispatched are left uncommitted
+// and are redelivered to the group.
+func (c *Consumer) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	queues := make(map[partitionKey]chan kafka.Message)
+
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		ticker := time.NewTicker(c.commitInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.commit(ctx)
+			}
+		}
+	}()
+
+	var runErr error
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				runErr = fmt.Errorf("failed to fetch event: %w", err)
+			}
+			break
+		}
+
+		key := partitionKey{topic: msg.Topic, partition: msg.Partition}
+		queue, ok := queues[key]
+		if !ok {
+			queue = make(chan kafka.Message, c.queueSize)
+			queues[key] = queue
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.dispatchPartition(ctx, queue)
+			}()
+		}
+
+		select {
+		case queue <- msg:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	cancel()
+	wg.Wait()
+	<-committed
+
+	// Commit what was dispatched after the last tick; ctx is already done
+	commitCtx, cancelCommit := context.WithTimeout(context.WithoutCancel(ctx), commitTimeout)
+	defer cancelCommit()
+	if err := c.commit(commitCtx); err != nil {
+		runErr = errors.Join(runErr, err)
+	}
+	if err := c.reader.Close(); err != nil {
+		runErr = errors.Join(runErr, fmt.Errorf("failed to close reader: %w", err))
+	}
+	return runErr
+}
+
+// dispatchPartition dispatches one partition's messages in offset order
+// until ctx is cancelled. The message being dispatched when ctx is cancelled
+// is finished first, so it isn't dispatched twice.
+func (c *Consumer) dispatchPartition(ctx context.Context, queue <-chan kafka.Message) {
+	dispatchCtx := context.WithoutCancel(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-queue:
+			if ctx.Err() != nil {
+				return
+			}
+			c.dispatch(dispatchCtx, msg)
+		}
+	}
+}
+
+// dispatch decodes and hands a message to the handler, then marks it for
+// commit. Messages that can't be decoded or dispatched are logged and
+// committed anyway: the events are notifications for live clients, and
+// retrying a message would hold back its whole partition.
+func (c *Consumer) dispatch(ctx context.Context, msg kafka.Message) {
+	key := partitionKey{topic: msg.Topic, partition: msg.Partition}
+	fields := logrus.Fields{
+		"topic":     msg.Topic,
+		"partition": msg.Partition,
+		"offset":    msg.Offset,
+	}
+
+	event, err := events.Decode(contentType(msg), msg.Value)
+	duplicate := false
+	if err == nil {
+		fields["event_type"] = event.Type
+		fields["analysis_id"] = event.AnalysisID
+		if duplicate = c.seen.Duplicate(event); !duplicate {
+			err = c.handler(ctx, event)
+		}
+	}
+	switch {
+	case duplicate:
+		c.logger.WithFields(fields).Debug("Skipping analysis event delivered twice")
+	case err != nil:
+		c.logger.WithFields(fields).WithError(err).Warn("Failed to dispatch analysis event")
+		if c.failures != nil {
+			c.failures.Add(ctx, 1, metric.WithAttributes(partitionAttributes(key)...))
+		}
+	case c.messages != nil:
+		c.messages.Add(ctx, 1, metric.WithAttributes(partitionAttributes(key)...))
+	}
+
+	c.mu.Lock()
+	c.dispatched[key] = msg
+	if msg.HighWaterMark > 0 {
+		c.lag[key] = max(msg.HighWaterMark-msg.Offset-1, 0)
+	}
+	c.mu.Unlock()
+}
+
+// commit commits the last dispatched message of each partition
+func (c *Consumer) commit(ctx context.Context) error {
+	c.mu.Lock()
+	if len(c.dispatched) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	msgs := make([]kafka.Message, 0, len(c.dispatched))
+	for _, msg := range c.dispatched {
+		msgs = append(msgs, msg)
+	}
+	c.dispatched = make(map[partitionKey]kafka.Message)
+	c.mu.Unlock()
+
+	if err := c.reader.CommitMessages(ctx, msgs...); err != nil {
+		// Keep the offsets for the next attempt unless newer ones were dispatched
+		c.mu.Lock()
+		for _, msg := range msgs {
+			key := partitionKey{topic: msg.Topic, partition: msg.Partition}
+			if _, ok := c.dispatched[key]; !ok {
+				c.dispatched[key] = msg
+			}
+		}
+		c.mu.Unlock()
+		c.logger.WithError(err).Warn("Failed to commit event offsets")
+		return fmt.Errorf("failed to commit offsets: %w", err)
+	}
+	return nil
+}
+
+// contentType returns the message's event content type, or "" for messages
+// published without one
+func contentType(msg kafka.Message) string {
+	for _, header := range msg.Headers {
+		if header.Key == events.ContentTypeHeader {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// partitionAttributes are the metric attributes of a partition
+func partitionAttributes(key partitionKey) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.destination.name", key.topic),
+		attribute.Int("messaging.destination.partition.id", key.partition),
+	}
+}
+
+// withDefaults fills in unset config fields
+func withDefaults(config Config) Config {
+	if config.Topic == "" {
+		config.Topic = DefaultTopic
+	}
+	if config.GroupID == "" {
+		config.GroupID = DefaultGroupID + "-" + instanceName()
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	if config.CommitInterval <= 0 {
+		config.CommitInterval = DefaultCommitInterval
+	}
+	return config
+}
+
+// instanceName names this gateway instance by its host name, or randomly
+// when the host name can't be read
+func instanceName() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return uuid.NewString()
+}
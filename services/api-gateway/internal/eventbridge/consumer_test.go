@@ -0,0 +1,325 @@
+package eventbridge_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/eventbridge"
+	"github.com/sa3d-modernized/sa3d/shared/events"
+)
+
+// stubEventReader is an in-memory consumer group reader
+type stubEventReader struct {
+	messages chan kafka.Message
+	fetched  atomic.Int64
+
+	mu        sync.Mutex
+	committed map[int]int64
+	closed    bool
+}
+
+func (r *stubEventReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-r.messages:
+		r.fetched.Add(1)
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *stubEventReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range msgs {
+		if offset, ok := r.committed[msg.Partition]; !ok || msg.Offset > offset {
+			r.committed[msg.Partition] = msg.Offset
+		}
+	}
+	return nil
+}
+
+func (r *stubEventReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func (r *stubEventReader) state() (map[int]int64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	committed := make(map[int]int64, len(r.committed))
+	for partition, offset := range r.committed {
+		committed[partition] = offset
+	}
+	return committed, r.closed
+}
+
+func newStubEventReader(messages ...kafka.Message) *stubEventReader {
+	reader := &stubEventReader{
+		messages:  make(chan kafka.Message, len(messages)),
+		committed: make(map[int]int64),
+	}
+	for _, msg := range messages {
+		reader.messages <- msg
+	}
+	return reader
+}
+
+// eventMessage encodes an analysis event as the analysis service publishes it
+func eventMessage(t *testing.T, partition int, offset int64, analysisID string) kafka.Message {
+	data, err := events.JSONCodec{}.Marshal(events.New(analysisID, events.AnalysisStarted{ProjectID: "project-1"}))
+	require.NoError(t, err)
+	return kafka.Message{
+		Topic:         eventbridge.DefaultTopic,
+		Partition:     partition,
+		Offset:        offset,
+		HighWaterMark: 10,
+		Key:           []byte(analysisID),
+		Value:         data,
+		Headers:       []kafka.Header{{Key: events.ContentTypeHeader, Value: []byte(events.ContentTypeJSON)}},
+	}
+}
+
+func TestEventBridgeConsumer(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	t.Run("dispatches partitions in order and commits on shutdown", func(t *testing.T) {
+		var messages []kafka.Message
+		for offset := int64(0); offset < 5; offset++ {
+			for partition := 0; partition < 3; partition++ {
+				messages = append(messages, eventMessage(t, partition, offset, fmt.Sprintf("analysis-%d-%d", partition, offset)))
+			}
+		}
+		reader := newStubEventReader(messages...)
+
+		var mu sync.Mutex
+		dispatched := make(map[string][]string)
+		var total atomic.Int64
+		consumer := eventbridge.NewConsumer(reader, func(_ context.Context, event *events.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			partition := strings.Split(event.AnalysisID, "-")[1]
+			dispatched[partition] = append(dispatched[partition], event.AnalysisID)
+			total.Add(1)
+			return nil
+		}, eventbridge.Config{CommitInterval: time.Hour}, logger)
+		metricReader := sdkmetric.NewManualReader()
+		require.NoError(t, consumer.SetMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricReader)).Meter("test")))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- consumer.Run(ctx) }()
+		require.Eventually(t, func() bool { return total.Load() == 15 }, 5*time.Second, 5*time.Millisecond)
+		committed, _ := reader.state()
+		assert.Empty(t, committed, "nothing is committed before the commit interval")
+
+		cancel()
+		require.NoError(t, <-done)
+
+		for partition := 0; partition < 3; partition++ {
+			var want []string
+			for offset := 0; offset < 5; offset++ {
+				want = append(want, fmt.Sprintf("analysis-%d-%d", partition, offset))
+			}
+			assert.Equal(t, want, dispatched[strconv.Itoa(partition)])
+		}
+		committed, closed := reader.state()
+		assert.Equal(t, map[int]int64{0: 4, 1: 4, 2: 4}, committed, "shutdown commits the dispatched offsets")
+		assert.True(t, closed)
+
+		topic := eventbridge.DefaultTopic
+		assert.Equal(t, map[string]int64{topic + "/0": 5, topic + "/1": 5, topic + "/2": 5}, consumer.Lag())
+		var collected metricdata.ResourceMetrics
+		require.NoError(t, metricReader.Collect(context.Background(), &collected))
+		values := make(map[string]int64)
+		for _, m := range collected.ScopeMetrics[0].Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, point := range data.DataPoints {
+					values[m.Name] += point.Value
+				}
+			case metricdata.Gauge[int64]:
+				for _, point := range data.DataPoints {
+					values[m.Name] += point.Value
+				}
+			}
+		}
+		assert.Equal(t, map[string]int64{"messaging.kafka.consumer.messages": 15, "messaging.kafka.consumer.lag": 15}, values)
+	})
+
+	t.Run("commits periodically", func(t *testing.T) {
+		reader := newStubEventReader(eventMessage(t, 0, 0, "a"), eventMessage(t, 0, 1, "b"))
+		consumer := eventbridge.NewConsumer(reader, func(context.Context, *events.Event) error { return nil },
+			eventbridge.Config{CommitInterval: 10 * time.Millisecond}, logger)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		done := make(chan error, 1)
+		go func() { done <- consumer.Run(ctx) }()
+
+		require.Eventually(t, func() bool {
+			committed, _ := reader.state()
+			return committed[0] == 1
+		}, 5*time.Second, 5*time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	t.Run("leaves undispatched messages uncommitted", func(t *testing.T) {
+		reader := newStubEventReader(eventMessage(t, 0, 0, "a"), eventMessage(t, 0, 1, "b"), eventMessage(t, 0, 2, "c"))
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var dispatched []string
+		consumer := eventbridge.NewConsumer(reader, func(ctx context.Context, event *events.Event) error {
+			dispatched = append(dispatched, event.AnalysisID)
+			if event.AnalysisID == "b" {
+				close(started)
+				<-release
+				assert.NoError(t, ctx.Err(), "in-flight dispatches aren't cancelled")
+			}
+			return nil
+		}, eventbridge.Config{CommitInterval: time.Hour}, logger)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- consumer.Run(ctx) }()
+		<-started
+		cancel()
+		close(release)
+		require.NoError(t, <-done)
+
+		assert.Equal(t, []string{"a", "b"}, dispatched)
+		committed, closed := reader.state()
+		assert.Equal(t, map[int]int64{0: 1}, committed, "c is redelivered to the group")
+		assert.True(t, closed)
+	})
+
+	t.Run("applies backpressure", func(t *testing.T) {
+		var messages []kafka.Message
+		for offset := int64(0); offset < 10; offset++ {
+			messages = append(messages, eventMessage(t, 0, offset, fmt.Sprintf("analysis-%d", offset)))
+		}
+		reader := newStubEventReader(messages...)
+		release := make(chan struct{})
+		consumer := eventbridge.NewConsumer(reader, func(context.Context, *events.Event) error {
+			<-release
+			return nil
+		}, eventbridge.Config{QueueSize: 2, CommitInterval: time.Hour}, logger)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- consumer.Run(ctx) }()
+
+		// One message in dispatch, two queued and one waiting to be queued
+		require.Eventually(t, func() bool { return reader.fetched.Load() == 4 }, 5*time.Second, 5*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, int64(4), reader.fetched.Load(), "a full queue stops fetching")
+
+		cancel()
+		close(release)
+		require.NoError(t, <-done)
+		committed, _ := reader.state()
+		assert.Equal(t, map[int]int64{0: 0}, committed)
+	})
+
+	t.Run("commits undecodable messages", func(t *testing.T) {
+		bad := eventMessage(t, 0, 0, "a")
+		bad.Value = []byte("not an event")
+		reader := newStubEventReader(bad, eventMessage(t, 0, 1, "b"))
+		var dispatched atomic.Int64
+		consumer := eventbridge.NewConsumer(reader, func(context.Context, *events.Event) error {
+			dispatched.Add(1)
+			return nil
+		}, eventbridge.Config{CommitInterval: time.Hour}, logger)
+		metricReader := sdkmetric.NewManualReader()
+		require.NoError(t, consumer.SetMeter(sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricReader)).Meter("test")))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- consumer.Run(ctx) }()
+		require.Eventually(t, func() bool { return dispatched.Load() == 1 }, 5*time.Second, 5*time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+
+		committed, _ := reader.state()
+		assert.Equal(t, map[int]int64{0: 1}, committed, "a poison message doesn't hold back its partition")
+		var collected metricdata.ResourceMetrics
+		require.NoError(t, metricReader.Collect(context.Background(), &collected))
+		var failures int64
+		for _, m := range collected.ScopeMetrics[0].Metrics {
+			if m.Name == "messaging.kafka.consumer.failures" {
+				for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					failures += point.Value
+				}
+			}
+		}
+		assert.Equal(t, int64(1), failures)
+	})
+
+	t.Run("drops events delivered twice", func(t *testing.T) {
+		sequenced := func(offset int64, sequence uint64) kafka.Message {
+			event := events.New("a", events.AnalysisStarted{ProjectID: "project-1"})
+			event.Sequence = sequence
+			data, err := events.JSONCodec{}.Marshal(event)
+			require.NoError(t, err)
+			msg := eventMessage(t, 0, offset, "a")
+			msg.Value = data
+			return msg
+		}
+		reader := newStubEventReader(sequenced(0, 1), sequenced(1, 1), sequenced(2, 2))
+		var mu sync.Mutex
+		var sequences []uint64
+		consumer := eventbridge.NewConsumer(reader, func(_ context.Context, event *events.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sequences = append(sequences, event.Sequence)
+			return nil
+		}, eventbridge.Config{CommitInterval: time.Hour}, logger)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- consumer.Run(ctx) }()
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(sequences) == 2
+		}, 5*time.Second, 5*time.Millisecond)
+		cancel()
+		require.NoError(t, <-done)
+
+		assert.Equal(t, []uint64{1, 2}, sequences)
+		committed, _ := reader.state()
+		assert.Equal(t, map[int]int64{0: 2}, committed, "the duplicate is committed with the others")
+	})
+
+	t.Run("reads as a group of its own instance", func(t *testing.T) {
+		hostname, err := os.Hostname()
+		require.NoError(t, err)
+
+		reader := eventbridge.NewReader(eventbridge.Config{Brokers: []string{"127.0.0.1:1"}})
+		defer reader.Close()
+		assert.Equal(t, eventbridge.DefaultGroupID+"-"+hostname, reader.Config().GroupID,
+			"instances sharing a group would each miss the partitions assigned to the others")
+
+		configured := eventbridge.NewReader(eventbridge.Config{Brokers: []string{"127.0.0.1:1"}, GroupID: "gateway-a"})
+		defer configured.Close()
+		assert.Equal(t, "gateway-a", configured.Config().GroupID)
+	})
+}
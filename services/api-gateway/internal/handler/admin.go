@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+)
+
+// AdminHandler handles user administration endpoints
+type AdminHandler struct {
+	authService *services.AuthService
+	logger      *logrus.Logger
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(authService *services.AuthService, logger *logrus.Logger) *AdminHandler {
+	return &AdminHandler{
+		authService: authService,
+		logger:      logger,
+	}
+}
+
+// SetUserRoleRequest represents a request to change a user's role
+type SetUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// ListUsers returns a filtered page of users
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	var filter services.UserFilter
+	var page services.Page
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+		return
+	}
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters", "details": err.Error()})
+		return
+	}
+
+	users, err := h.authService.ListUsers(filter, page)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list users")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// SetUserRole changes a user's role
+func (h *AdminHandler) SetUserRole(c *gin.Context) {
+	var req SetUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+
+	h.modifyUser(c, "role change", false, func(userID uuid.UUID) error {
+		return h.authService.SetUserRole(userID, req.Role)
+	})
+}
+
+// DeactivateUser disables a user account
+func (h *AdminHandler) DeactivateUser(c *gin.Context) {
+	h.modifyUser(c, "deactivation", false, func(userID uuid.UUID) error {
+		return h.authService.DeactivateUser(userID)
+	})
+}
+
+// ActivateUser re-enables a user account
+func (h *AdminHandler) ActivateUser(c *gin.Context) {
+	h.modifyUser(c, "activation", true, func(userID uuid.UUID) error {
+		return h.authService.ActivateUser(userID)
+	})
+}
+
+// UnlockUser clears a login lockout
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	h.modifyUser(c, "unlock", true, func(userID uuid.UUID) error {
+		return h.authService.UnlockUser(userID)
+	})
+}
+
+// modifyUser runs an admin action against the user in the :id path parameter
+// and maps its result to a response. Unless allowSelf is set, admins cannot
+// apply the action to their own account so they do not lock themselves out.
+func (h *AdminHandler) modifyUser(c *gin.Context, action string, allowSelf bool, apply func(userID uuid.UUID) error) {
+	targetID := c.Param("id")
+	targetUUID, err := parseUUID(targetID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if !allowSelf && targetID == contextkeys.UserID.GetString(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot modify your own account"})
+		return
+	}
+
+	err = apply(targetUUID)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	case errors.Is(err, services.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	default:
+		h.logger.WithError(err).WithField("target_user_id", targetID).Errorf("User %s failed", action)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"admin_id":       contextkeys.UserID.GetString(c),
+		"target_user_id": targetID,
+		"action":         action,
+	}).Info("Admin user action performed")
+
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// RedisAnalysisCacheCleaner removes the data the analysis service caches in
+// Redis for deleted analyses: their jobs, summaries and memoized comparisons
+type RedisAnalysisCacheCleaner struct {
+	client *redis.Client
+}
+
+// NewRedisAnalysisCacheCleaner creates a cache cleaner for the given Redis client
+func NewRedisAnalysisCacheCleaner(client *redis.Client) *RedisAnalysisCacheCleaner {
+	return &RedisAnalysisCacheCleaner{client: client}
+}
+
+// CleanupAnalyses implements services.AnalysisCacheCleaner
+func (r *RedisAnalysisCacheCleaner) CleanupAnalyses(analysisIDs []uuid.UUID) error {
+	if len(analysisIDs) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+
+	keys := make([]string, 0, len(analysisIDs)*3)
+	for _, id := range analysisIDs {
+		indexKey := utils.ComparisonCacheIndexKey(id.String())
+		comparisons, err := r.client.SMembers(ctx, indexKey).Result()
+		if err != nil {
+			return err
+		}
+		keys = append(keys, comparisons...)
+		keys = append(keys, fmt.Sprintf("analysis:job:%s", id), fmt.Sprintf("analysis:summary:%s", id), indexKey)
+	}
+
+	return r.client.Del(ctx, keys...).Err()
+}
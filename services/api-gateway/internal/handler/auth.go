@@ -11,6 +11,10 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
 )
 
 // AuthHandler handles authentication endpoints
@@ -114,7 +118,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 // Logout handles user logout
 func (h *AuthHandler) Logout(c *gin.Context) {
-	userID := c.GetString("user_id")
+	userID := contextkeys.UserID.GetString(c)
 	if userID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found"})
 		return
@@ -189,8 +193,8 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 // ValidateToken validates a token
 func (h *AuthHandler) ValidateToken(c *gin.Context) {
 	// Token is already validated by middleware
-	userID := c.GetString("user_id")
-	email := c.GetString("email")
+	userID := contextkeys.UserID.GetString(c)
+	email := contextkeys.Email.GetString(c)
 	
 	c.JSON(http.StatusOK, gin.H{
 		"valid":   true,
@@ -201,7 +205,7 @@ func (h *AuthHandler) ValidateToken(c *gin.Context) {
 
 // generateToken generates a JWT token for a user
 func (h *AuthHandler) generateToken(user *User) (string, time.Time, error) {
-	expiresAt := time.Now().Add(h.tokenDuration)
+	expiresAt := utils.Now().Add(h.tokenDuration)
 	
 	claims := jwt.MapClaims{
 		"user_id": user.ID,
@@ -209,10 +213,10 @@ func (h *AuthHandler) generateToken(user *User) (string, time.Time, error) {
 		"name":    user.Name,
 		"roles":   user.Roles,
 		"exp":     expiresAt.Unix(),
-		"iat":     time.Now().Unix(),
+		"iat":     utils.Now().Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(middleware.JWTSigningMethod, claims)
 	tokenString, err := token.SignedString([]byte(h.jwtSecret))
 	if err != nil {
 		return "", time.Time{}, err
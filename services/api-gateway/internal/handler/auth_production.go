@@ -1,13 +1,20 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/models"
 	"github.com/sa3d-modernized/sa3d/shared/services"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
 )
 
 // parseUUID parses a string to UUID
@@ -17,18 +24,25 @@ func parseUUID(s string) (uuid.UUID, error) {
 
 // ProductionAuthHandler handles authentication endpoints using database
 type ProductionAuthHandler struct {
-	authService *services.AuthService
-	logger      *logrus.Logger
+	authService       *services.AuthService
+	challengeVerifier ChallengeVerifier
+	logger            *logrus.Logger
 }
 
 // NewProductionAuthHandler creates a new production auth handler
 func NewProductionAuthHandler(authService *services.AuthService, logger *logrus.Logger) *ProductionAuthHandler {
 	return &ProductionAuthHandler{
-		authService: authService,
-		logger:      logger,
+		authService:       authService,
+		challengeVerifier: NoopChallengeVerifier{},
+		logger:            logger,
 	}
 }
 
+// SetChallengeVerifier sets the verifier consulted before registration
+func (h *ProductionAuthHandler) SetChallengeVerifier(verifier ChallengeVerifier) {
+	h.challengeVerifier = verifier
+}
+
 // Register handles user registration
 func (h *ProductionAuthHandler) Register(c *gin.Context) {
 	var registration services.UserRegistration
@@ -38,15 +52,26 @@ func (h *ProductionAuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	if err := h.challengeVerifier.Verify(c.Request.Context(), c.GetHeader(ChallengeTokenHeader), middleware.ClientIP(c)); err != nil {
+		h.logger.WithError(err).WithField("ip_address", middleware.ClientIP(c)).Warn("Registration challenge failed")
+
+		if errors.Is(err, ErrChallengeFailed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Challenge verification failed"})
+		} else {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Challenge verification unavailable"})
+		}
+		return
+	}
+
 	user, err := h.authService.Register(registration)
 	if err != nil {
 		h.logger.WithError(err).WithField("email", registration.Email).Error("Registration failed")
 		
-		switch err {
-		case services.ErrUserAlreadyExists:
+		switch appErr := utils.GetAppError(err); {
+		case appErr != nil:
+			c.JSON(appErr.StatusCode, utils.NewErrorResponse(appErr))
+		case errors.Is(err, services.ErrUserAlreadyExists):
 			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
-		case services.ErrWeakPassword:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Password does not meet security requirements"})
 		default:
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Registration failed"})
 		}
@@ -74,7 +99,7 @@ func (h *ProductionAuthHandler) Login(c *gin.Context) {
 	}
 
 	// Set client information for security logging
-	credentials.IPAddress = c.ClientIP()
+	credentials.IPAddress = middleware.ClientIP(c)
 	credentials.UserAgent = c.GetHeader("User-Agent")
 
 	result, err := h.authService.Login(credentials)
@@ -153,8 +178,8 @@ func (h *ProductionAuthHandler) RefreshToken(c *gin.Context) {
 
 // Logout handles user logout
 func (h *ProductionAuthHandler) Logout(c *gin.Context) {
-	userID := c.GetString("user_id")
-	sessionToken := c.GetString("session_token")
+	userID := contextkeys.UserID.GetString(c)
+	sessionToken := contextkeys.SessionToken.GetString(c)
 
 	if userID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID not found"})
@@ -187,9 +212,9 @@ func (h *ProductionAuthHandler) Logout(c *gin.Context) {
 // ValidateToken validates a token and returns user info
 func (h *ProductionAuthHandler) ValidateToken(c *gin.Context) {
 	// Token is already validated by middleware
-	userID := c.GetString("user_id")
-	email := c.GetString("email")
-	role := c.GetString("role")
+	userID := contextkeys.UserID.GetString(c)
+	email := contextkeys.Email.GetString(c)
+	role := contextkeys.Role.GetString(c)
 
 	c.JSON(http.StatusOK, gin.H{
 		"valid":   true,
@@ -199,9 +224,64 @@ func (h *ProductionAuthHandler) ValidateToken(c *gin.Context) {
 	})
 }
 
+// MaxValidateBatch bounds how many tokens one batch validation checks
+const MaxValidateBatch = 100
+
+// ValidateBatchRequest lists the tokens to validate
+type ValidateBatchRequest struct {
+	Tokens []string `json:"tokens" binding:"required,min=1"`
+}
+
+// TokenValidation is the outcome of validating one token. Error is
+// "invalid", "expired" or "inactive" for a token that isn't valid.
+type TokenValidation struct {
+	Valid  bool   `json:"valid"`
+	UserID string `json:"user_id,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Role   string `json:"role,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ValidateBatch validates several tokens for a backend in one call,
+// answering their outcomes in request order. It is meant for internal
+// callers; a failure to look a token up fails the whole batch rather than
+// report a token as invalid.
+func (h *ProductionAuthHandler) ValidateBatch(c *gin.Context) {
+	var req ValidateBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+		return
+	}
+	if len(req.Tokens) > MaxValidateBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d tokens can be validated at once", MaxValidateBatch)})
+		return
+	}
+
+	results := make([]TokenValidation, len(req.Tokens))
+	for i, token := range req.Tokens {
+		user, err := h.authService.ValidateToken(token)
+		switch {
+		case err == nil:
+			results[i] = TokenValidation{Valid: true, UserID: user.ID.String(), Email: user.Email, Role: user.Role}
+		case errors.Is(err, services.ErrInvalidToken):
+			results[i] = TokenValidation{Error: "invalid"}
+		case errors.Is(err, services.ErrTokenExpired):
+			results[i] = TokenValidation{Error: "expired"}
+		case errors.Is(err, services.ErrAccountNotActive):
+			results[i] = TokenValidation{Error: "inactive"}
+		default:
+			h.logger.WithError(err).WithField("request_id", utils.RequestIDFromContext(c.Request.Context())).Error("Batch token validation failed")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Token validation failed"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
 // GetProfile returns the current user's profile
 func (h *ProductionAuthHandler) GetProfile(c *gin.Context) {
-	userID := c.GetString("user_id")
+	userID := contextkeys.UserID.GetString(c)
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -232,6 +312,69 @@ func (h *ProductionAuthHandler) GetProfile(c *gin.Context) {
 	})
 }
 
+// Identity describes who a request authenticated as and what it may do
+type Identity struct {
+	// Type is middleware.IdentityUser or middleware.IdentityAPIKey
+	Type string `json:"type"`
+	// User is the authenticated user's profile; API keys have none
+	User        *models.User `json:"user,omitempty"`
+	Roles       []string     `json:"roles"`
+	Permissions []string     `json:"permissions"`
+	// TokenExpiresAt is when the user's token stops being accepted
+	TokenExpiresAt *time.Time `json:"token_expires_at,omitempty"`
+}
+
+// Me returns the identity the request authenticated as, with its resolved
+// roles and permissions. A user's profile and role are read afresh, so a
+// role changed since the token was issued is reported as it now is.
+func (h *ProductionAuthHandler) Me(c *gin.Context) {
+	switch contextkeys.Identity.GetString(c) {
+	case middleware.IdentityAPIKey:
+		roles := middleware.UserRoles(c)
+		c.JSON(http.StatusOK, Identity{
+			Type:        middleware.IdentityAPIKey,
+			Roles:       roles,
+			Permissions: middleware.Permissions(roles),
+		})
+		return
+	case middleware.IdentityUser:
+	default:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, err := parseUUID(contextkeys.UserID.GetString(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	user, err := h.authService.GetUserByID(userUUID)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		h.logger.WithError(err).WithField("user_id", userUUID).Error("Failed to get user")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		return
+	}
+
+	identity := Identity{
+		Type:        middleware.IdentityUser,
+		User:        user,
+		Roles:       []string{user.Role},
+		Permissions: middleware.Permissions([]string{user.Role}),
+	}
+	session, err := h.authService.GetSession(contextkeys.SessionToken.GetString(c))
+	switch {
+	case err == nil:
+		identity.TokenExpiresAt = &session.ExpiresAt
+	case !errors.Is(err, services.ErrSessionNotFound):
+		h.logger.WithError(err).WithField("user_id", userUUID).Warn("Failed to look up session expiry")
+	}
+	c.JSON(http.StatusOK, identity)
+}
+
 // ChangePassword handles password change
 func (h *ProductionAuthHandler) ChangePassword(c *gin.Context) {
 	var req struct {
@@ -245,7 +388,7 @@ func (h *ProductionAuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetString("user_id")
+	userID := contextkeys.UserID.GetString(c)
 	if userID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -259,4 +402,96 @@ func (h *ProductionAuthHandler) ChangePassword(c *gin.Context) {
 	// 4. Invalidating existing sessions
 	
 	c.JSON(http.StatusNotImplemented, gin.H{"error": "Password change not implemented yet"})
-}
\ No newline at end of file
+}
+
+// SessionInfo describes an active session without exposing its tokens
+type SessionInfo struct {
+	ID         string     `json:"id"`
+	IPAddress  string     `json:"ip_address"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"`
+}
+
+// ListSessions returns the current user's active sessions
+func (h *ProductionAuthHandler) ListSessions(c *gin.Context) {
+	userUUID, err := parseUUID(contextkeys.UserID.GetString(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(userUUID)
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userUUID).Error("Failed to list sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	currentToken := contextkeys.SessionToken.GetString(c)
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, SessionInfo{
+			ID:         session.ID.String(),
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.SessionToken == currentToken,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": infos,
+		"total":    len(infos),
+	})
+}
+
+// RevokeSession revokes one of the current user's sessions
+func (h *ProductionAuthHandler) RevokeSession(c *gin.Context) {
+	userUUID, err := parseUUID(contextkeys.UserID.GetString(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionUUID, err := parseUUID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := h.authService.RevokeSession(userUUID, sessionUUID); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		h.logger.WithError(err).WithField("user_id", userUUID).Error("Failed to revoke session")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeOtherSessions revokes every session of the current user except the
+// one making the request
+func (h *ProductionAuthHandler) RevokeOtherSessions(c *gin.Context) {
+	userUUID, err := parseUUID(contextkeys.UserID.GetString(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	revoked, err := h.authService.RevokeAllOtherSessions(userUUID, contextkeys.SessionToken.GetString(c))
+	if err != nil {
+		h.logger.WithError(err).WithField("user_id", userUUID).Error("Failed to revoke sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revoked": revoked})
+}
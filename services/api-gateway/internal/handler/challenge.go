@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ChallengeTokenHeader carries the CAPTCHA/Turnstile token on registration requests
+const ChallengeTokenHeader = "X-Challenge-Token"
+
+// ErrChallengeFailed is returned when a challenge token is missing or rejected
+var ErrChallengeFailed = errors.New("challenge verification failed")
+
+// ChallengeVerifier validates a human-verification token (CAPTCHA, Turnstile, ...)
+type ChallengeVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// NoopChallengeVerifier accepts every request; intended for development
+type NoopChallengeVerifier struct{}
+
+// Verify always succeeds
+func (NoopChallengeVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	return nil
+}
+
+// TurnstileVerifier validates tokens against the Cloudflare Turnstile siteverify API
+type TurnstileVerifier struct {
+	secret     string
+	verifyURL  string
+	httpClient *http.Client
+}
+
+// DefaultTurnstileVerifyURL is the Cloudflare Turnstile siteverify endpoint
+const DefaultTurnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+// NewTurnstileVerifier creates a Turnstile verifier using the given secret key
+func NewTurnstileVerifier(secret, verifyURL string) *TurnstileVerifier {
+	if verifyURL == "" {
+		verifyURL = DefaultTurnstileVerifyURL
+	}
+	return &TurnstileVerifier{
+		secret:     secret,
+		verifyURL:  verifyURL,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Verify checks the token with Turnstile
+func (v *TurnstileVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return ErrChallengeFailed
+	}
+
+	form := url.Values{
+		"secret":   {v.secret},
+		"response": {token},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create challenge request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to verify challenge: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode challenge response: %w", err)
+	}
+	if !result.Success {
+		return ErrChallengeFailed
+	}
+	return nil
+}
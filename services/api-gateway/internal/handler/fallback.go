@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// NoRoute answers requests for paths no route matches with the standard
+// error response
+func NoRoute(c *gin.Context) {
+	appErr := utils.NewNotFoundError("Route " + c.Request.URL.Path)
+	c.JSON(appErr.StatusCode, utils.NewErrorResponse(appErr))
+}
+
+// NoMethod answers requests whose path matches routes of other methods only.
+// Gin sets the Allow header listing those methods before calling it, as long
+// as the engine's HandleMethodNotAllowed is enabled.
+func NoMethod(c *gin.Context) {
+	appErr := utils.NewMethodNotAllowedError(c.Request.Method)
+	c.JSON(appErr.StatusCode, utils.NewErrorResponse(appErr))
+}
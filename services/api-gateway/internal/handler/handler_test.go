@@ -2,19 +2,36 @@ package handler_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
 
 	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/handler"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/openapi"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/proxy"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
 )
 
 func setupTestRouter() *gin.Engine {
@@ -262,4 +279,1249 @@ func TestProjectHandler_ListProjects(t *testing.T) {
 	
 	assert.Greater(t, len(resp.Projects), 0)
 	assert.Equal(t, len(resp.Projects), resp.Total)
-}
\ No newline at end of file
+}
+
+// staticSourceFetcher reports a fixed set of branches for every repository
+type staticSourceFetcher struct {
+	branches      []string
+	defaultBranch string
+}
+
+func (f staticSourceFetcher) ListBranches(ctx context.Context, repository string) ([]string, string, error) {
+	return f.branches, f.defaultBranch, nil
+}
+
+func TestProjectHandler_ProjectBranch(t *testing.T) {
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t, &models.Project{})
+	projectService := services.NewProjectService(services.NewDatabaseServiceFromDB(db, nil, logger), nil, logger)
+	projectService.SetSourceFetcher(staticSourceFetcher{branches: []string{"master", "develop"}, defaultBranch: "master"})
+	projectHandler := handler.NewProductionProjectHandler(projectService, logger)
+
+	ownerID := uuid.New()
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", ownerID.String())
+		c.Set("role", "user")
+		c.Next()
+	})
+	router.POST("/projects", projectHandler.CreateProject)
+	router.PUT("/projects/:id", projectHandler.UpdateProject)
+
+	send := func(method, path string, payload interface{}) *httptest.ResponseRecorder {
+		body, err := json.Marshal(payload)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/projects", handler.CreateProjectRequest{
+		Name: "Test Project", Language: "go", Repository: "https://example.com/repo.git", Branch: "main",
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Branch not found")
+
+	w = send("POST", "/projects", handler.CreateProjectRequest{
+		Name: "Test Project", Language: "go", Repository: "https://example.com/repo.git",
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var project handler.Project
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+	assert.Equal(t, "master", project.Branch)
+	assert.Equal(t, ownerID.String(), project.CreatedBy)
+
+	w = send("PUT", "/projects/"+project.ID, handler.UpdateProjectRequest{Branch: "release"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send("PUT", "/projects/"+project.ID, handler.UpdateProjectRequest{Branch: "develop"})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+	assert.Equal(t, "develop", project.Branch)
+}
+
+func TestProjectHandler_Notifications(t *testing.T) {
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t, &models.Project{})
+	projectService := services.NewProjectService(services.NewDatabaseServiceFromDB(db, nil, logger), nil, logger)
+	projectHandler := handler.NewProductionProjectHandler(projectService, logger)
+
+	ownerID := uuid.New()
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", ownerID.String())
+		c.Set("role", "user")
+		c.Next()
+	})
+	router.POST("/projects", projectHandler.CreateProject)
+	router.PUT("/projects/:id", projectHandler.UpdateProject)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/projects", `{"name":"Billing","language":"go","notification_emails":["dev@example.com"],"notification_webhooks":["https://hooks.example.com/sa3d"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var project handler.Project
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+	assert.Equal(t, []string{"dev@example.com"}, project.NotificationEmails)
+	assert.Equal(t, []string{"https://hooks.example.com/sa3d"}, project.NotificationWebhooks)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid email", `{"notification_emails":["dev@example.com\r\nBcc: victim@example.com"]}`},
+		{"non-http webhook", `{"notification_webhooks":["ftp://hooks.example.com"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send("PUT", "/projects/"+project.ID, tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Invalid project")
+		})
+	}
+
+	w = send("PUT", "/projects/"+project.ID, `{"notification_emails":[]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	project = handler.Project{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+	assert.Empty(t, project.NotificationEmails, "an empty list removes the recipients")
+	assert.Equal(t, []string{"https://hooks.example.com/sa3d"}, project.NotificationWebhooks, "omitted recipients stay")
+}
+
+func TestProjectHandler_AnalysisSettings(t *testing.T) {
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t, &models.Project{})
+	projectService := services.NewProjectService(services.NewDatabaseServiceFromDB(db, nil, logger), nil, logger)
+	projectHandler := handler.NewProductionProjectHandler(projectService, logger)
+
+	ownerID := uuid.New()
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", ownerID.String())
+		c.Set("role", "user")
+		c.Next()
+	})
+	router.POST("/projects", projectHandler.CreateProject)
+	router.PUT("/projects/:id", projectHandler.UpdateProject)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/projects", `{"name":"Billing","language":"go","enabled_languages":["go","typescript"],"min_severity":"major"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var project handler.Project
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+	assert.Equal(t, []string{"go", "typescript"}, project.EnabledLanguages)
+	assert.Equal(t, "major", project.MinSeverity)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"create with unknown language", "POST", "/projects", `{"name":"Legacy","language":"go","enabled_languages":["cobol"]}`},
+		{"create with unknown severity", "POST", "/projects", `{"name":"Legacy","language":"go","min_severity":"blocker"}`},
+		{"update with unknown language", "PUT", "/projects/" + project.ID, `{"enabled_languages":["go","cobol"]}`},
+		{"update with unknown severity", "PUT", "/projects/" + project.ID, `{"min_severity":"blocker"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(tt.method, tt.path, tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Invalid project")
+		})
+	}
+
+	w = send("PUT", "/projects/"+project.ID, `{"enabled_languages":[],"min_severity":"critical"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	project = handler.Project{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+	assert.Empty(t, project.EnabledLanguages, "an empty list enables every language")
+	assert.Equal(t, "critical", project.MinSeverity)
+}
+
+func TestProjectHandler_DeleteProject(t *testing.T) {
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t,
+		&models.Project{},
+		&models.Analysis{},
+		&models.Visualization{},
+		&models.Session{},
+		&models.Participant{},
+		&models.Annotation{},
+	)
+	projectService := services.NewProjectService(services.NewDatabaseServiceFromDB(db, nil, logger), nil, logger)
+	projectHandler := handler.NewProductionProjectHandler(projectService, logger)
+
+	ownerID := uuid.New()
+	project := &models.Project{Name: "Test Project", Language: "go", CreatedBy: ownerID}
+	require.NoError(t, db.Create(project).Error)
+
+	newRouter := func(userID uuid.UUID, role string) *gin.Engine {
+		router := setupTestRouter()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", userID.String())
+			c.Set("role", role)
+			c.Next()
+		})
+		router.DELETE("/projects/:id", projectHandler.DeleteProject)
+		return router
+	}
+
+	tests := []struct {
+		name       string
+		userID     uuid.UUID
+		projectID  string
+		wantStatus int
+	}{
+		{"invalid project id", ownerID, "not-a-uuid", http.StatusBadRequest},
+		{"unknown project", ownerID, uuid.New().String(), http.StatusNotFound},
+		{"non-owner", uuid.New(), project.ID.String(), http.StatusForbidden},
+		{"owner", ownerID, project.ID.String(), http.StatusNoContent},
+		{"already deleted", ownerID, project.ID.String(), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("DELETE", "/projects/"+tt.projectID, nil)
+			w := httptest.NewRecorder()
+			newRouter(tt.userID, "user").ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestProjectHandler_DeleteProjectDropsCachedAnalyses(t *testing.T) {
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t,
+		&models.Project{},
+		&models.Analysis{},
+		&models.Visualization{},
+		&models.Session{},
+		&models.Participant{},
+		&models.Annotation{},
+	)
+	redisClient := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	projectService := services.NewProjectService(services.NewDatabaseServiceFromDB(db, nil, logger), handler.NewRedisAnalysisCacheCleaner(redisClient), logger)
+	projectHandler := handler.NewProductionProjectHandler(projectService, logger)
+
+	ownerID := uuid.New()
+	project := &models.Project{Name: "Deleted", Language: "go", CreatedBy: ownerID}
+	other := &models.Project{Name: "Kept", Language: "go", CreatedBy: ownerID}
+	require.NoError(t, db.Create(project).Error)
+	require.NoError(t, db.Create(other).Error)
+
+	newAnalysis := func(projectID uuid.UUID) string {
+		analysis := &models.Analysis{ProjectID: projectID, Status: models.AnalysisStatusCompleted}
+		require.NoError(t, db.Create(analysis).Error)
+		return analysis.ID.String()
+	}
+	base, head := newAnalysis(project.ID), newAnalysis(project.ID)
+	otherBase, otherHead := newAnalysis(other.ID), newAnalysis(other.ID)
+
+	// Comparisons as the analysis service memoizes them
+	ctx := context.Background()
+	comparisonKey := func(baseID, headID string) string {
+		key := "analysis:compare:v1:" + baseID + ":" + headID + ":0.5"
+		require.NoError(t, redisClient.Set(ctx, key, "{}", time.Hour).Err())
+		for _, id := range []string{baseID, headID} {
+			require.NoError(t, redisClient.SAdd(ctx, utils.ComparisonCacheIndexKey(id), key).Err())
+		}
+		return key
+	}
+	deleted := comparisonKey(base, head)
+	kept := comparisonKey(otherBase, otherHead)
+	require.NoError(t, redisClient.Set(ctx, "analysis:job:"+base, "{}", time.Hour).Err())
+
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", ownerID.String())
+		c.Set("role", "user")
+		c.Next()
+	})
+	router.DELETE("/projects/:id", projectHandler.DeleteProject)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/projects/"+project.ID.String(), nil))
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	remaining, err := redisClient.Exists(ctx, deleted, "analysis:job:"+base, utils.ComparisonCacheIndexKey(base), utils.ComparisonCacheIndexKey(head)).Result()
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+
+	remaining, err = redisClient.Exists(ctx, kept, utils.ComparisonCacheIndexKey(otherBase)).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), remaining)
+}
+
+func TestAdminHandler_UserManagement(t *testing.T) {
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t, &models.User{}, &models.UserSession{})
+	authService := services.NewAuthService(services.NewDatabaseServiceFromDB(db, nil, logger), logger)
+	adminHandler := handler.NewAdminHandler(authService, logger)
+
+	router := setupTestRouter()
+	adminUsers := router.Group("/admin/users")
+	adminUsers.Use(middleware.ProductionRequireAdmin(authService, logger))
+	adminUsers.GET("", adminHandler.ListUsers)
+	adminUsers.PUT("/:id/role", adminHandler.SetUserRole)
+
+	newUser := func(username, role string) (*models.User, string) {
+		user := &models.User{Email: username + "@example.com", Username: username, Password: "hash", Role: role, IsActive: true}
+		require.NoError(t, db.Create(user).Error)
+		token := "token-" + username
+		require.NoError(t, db.Create(&models.UserSession{
+			UserID:       user.ID,
+			SessionToken: token,
+			RefreshToken: "refresh-" + username,
+			ExpiresAt:    time.Now().Add(time.Hour),
+			IsActive:     true,
+		}).Error)
+		return user, token
+	}
+
+	admin, adminToken := newUser("admin", "admin")
+	member, memberToken := newUser("member", "user")
+
+	do := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		var payload bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&payload).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &payload)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("non-admin is forbidden", func(t *testing.T) {
+		w := do("GET", "/admin/users", memberToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = do("PUT", "/admin/users/"+admin.ID.String()+"/role", memberToken, handler.SetUserRoleRequest{Role: "user"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("admin lists users", func(t *testing.T) {
+		w := do("GET", "/admin/users?search=mem", adminToken, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var list services.UserList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		assert.Equal(t, int64(1), list.Total)
+	})
+
+	t.Run("admin cannot change own role", func(t *testing.T) {
+		w := do("PUT", "/admin/users/"+admin.ID.String()+"/role", adminToken, handler.SetUserRoleRequest{Role: "user"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid role", func(t *testing.T) {
+		w := do("PUT", "/admin/users/"+member.ID.String()+"/role", adminToken, handler.SetUserRoleRequest{Role: "root"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("role change revokes sessions", func(t *testing.T) {
+		w := do("PUT", "/admin/users/"+member.ID.String()+"/role", adminToken, handler.SetUserRoleRequest{Role: "admin"})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		// The promoted user's old token no longer authenticates
+		w = do("GET", "/admin/users", memberToken, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+type rejectingVerifier struct {
+	calls int
+}
+
+func (v *rejectingVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	v.calls++
+	if token == "valid-token" {
+		return nil
+	}
+	return handler.ErrChallengeFailed
+}
+
+func newRegistrationRouter(t *testing.T, verifier handler.ChallengeVerifier, limit rate.Limit, burst int) (*gin.Engine, *gorm.DB) {
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t, &models.User{})
+	authService := services.NewAuthService(services.NewDatabaseServiceFromDB(db, nil, logger), logger)
+	authHandler := handler.NewProductionAuthHandler(authService, logger)
+	if verifier != nil {
+		authHandler.SetChallengeVerifier(verifier)
+	}
+
+	router := setupTestRouter()
+	router.POST("/register", middleware.IPRateLimiter(limit, burst), authHandler.Register)
+	return router, db
+}
+
+func registrationRequest(t *testing.T, username, remoteAddr, challengeToken string) *http.Request {
+	body, err := json.Marshal(services.UserRegistration{
+		Email:     username + "@example.com",
+		Username:  username,
+		Password:  "Str0ng!Passw0rd",
+		FirstName: "Test",
+		LastName:  "User",
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/register", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteAddr
+	if challengeToken != "" {
+		req.Header.Set(handler.ChallengeTokenHeader, challengeToken)
+	}
+	return req
+}
+
+func TestProductionAuthHandler_RegisterRateLimit(t *testing.T) {
+	router, _ := newRegistrationRouter(t, nil, rate.Every(time.Hour), 2)
+
+	for i, username := range []string{"first", "second"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, registrationRequest(t, username, "10.0.0.1:1234", ""))
+		assert.Equal(t, http.StatusCreated, w.Code, "request %d", i)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, registrationRequest(t, "third", "10.0.0.1:1234", ""))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// Other clients have their own budget
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, registrationRequest(t, "fourth", "10.0.0.2:1234", ""))
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestProductionAuthHandler_RegisterChallenge(t *testing.T) {
+	verifier := &rejectingVerifier{}
+	router, db := newRegistrationRouter(t, verifier, rate.Inf, 0)
+
+	tests := []struct {
+		name       string
+		username   string
+		token      string
+		wantStatus int
+	}{
+		{"missing token", "missing", "", http.StatusForbidden},
+		{"rejected token", "rejected", "bogus", http.StatusForbidden},
+		{"accepted token", "accepted", "valid-token", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, registrationRequest(t, tt.username, "10.0.0.1:1234", tt.token))
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			var count int64
+			require.NoError(t, db.Model(&models.User{}).Where("username = ?", tt.username).Count(&count).Error)
+			assert.Equal(t, tt.wantStatus == http.StatusCreated, count == 1, "user should only be created after a passed challenge")
+		})
+	}
+
+	assert.Equal(t, len(tests), verifier.calls)
+}
+
+func TestProductionAuthHandler_RegisterValidation(t *testing.T) {
+	router, _ := newRegistrationRouter(t, nil, rate.Inf, 0)
+
+	tests := []struct {
+		name    string
+		body    string
+		details map[string]interface{}
+	}{
+		{
+			name:    "invalid email",
+			body:    `{"email":"bob@","username":"bob","password":"Str0ng!Passw0rd","first_name":"Bob","last_name":"Builder"}`,
+			details: map[string]interface{}{"email": "must be a valid email address"},
+		},
+		{
+			name:    "invalid username",
+			body:    `{"email":"bob@example.com","username":"bob!","password":"Str0ng!Passw0rd","first_name":"Bob","last_name":"Builder"}`,
+			details: map[string]interface{}{"username": "may only contain letters, digits, '.', '_' and '-'"},
+		},
+		{
+			name: "missing names",
+			body: `{"email":"bob@example.com","username":"bob","password":"Str0ng!Passw0rd"}`,
+			details: map[string]interface{}{
+				"first_name": "is required",
+				"last_name":  "is required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var response utils.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, utils.ErrCodeValidation, response.Code)
+			assert.Equal(t, tt.details, response.Details)
+		})
+	}
+}
+
+func TestProductionAuthHandler_Sessions(t *testing.T) {
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t, &models.User{}, &models.UserSession{})
+	authService := services.NewAuthService(services.NewDatabaseServiceFromDB(db, nil, logger), logger)
+	authHandler := handler.NewProductionAuthHandler(authService, logger)
+
+	router := setupTestRouter()
+	me := router.Group("/me")
+	me.Use(middleware.ProductionAuth(authService, logger))
+	me.GET("/sessions", authHandler.ListSessions)
+	me.DELETE("/sessions", authHandler.RevokeOtherSessions)
+	me.DELETE("/sessions/:id", authHandler.RevokeSession)
+
+	user := &models.User{Email: "sessions@example.com", Username: "sessions", Password: "hash", Role: "user", IsActive: true}
+	require.NoError(t, db.Create(user).Error)
+	for _, token := range []string{"current", "laptop", "phone"} {
+		require.NoError(t, db.Create(&models.UserSession{
+			UserID:       user.ID,
+			SessionToken: token,
+			RefreshToken: "refresh-" + token,
+			ExpiresAt:    time.Now().Add(time.Hour),
+			IPAddress:    "10.0.0.1",
+			UserAgent:    token + "-agent",
+			IsActive:     true,
+		}).Error)
+	}
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer current")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	listSessions := func() []handler.SessionInfo {
+		w := do("GET", "/me/sessions")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		body := w.Body.String()
+		assert.NotContains(t, body, "refresh-", "tokens must not be exposed")
+
+		var resp struct {
+			Sessions []handler.SessionInfo `json:"sessions"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Sessions
+	}
+
+	t.Run("list marks the current session", func(t *testing.T) {
+		sessions := listSessions()
+		require.Len(t, sessions, 3)
+
+		current := 0
+		for _, s := range sessions {
+			if s.Current {
+				current++
+				assert.Equal(t, "current-agent", s.UserAgent)
+			}
+		}
+		assert.Equal(t, 1, current)
+	})
+
+	t.Run("revoke one", func(t *testing.T) {
+		var target handler.SessionInfo
+		for _, s := range listSessions() {
+			if s.UserAgent == "laptop-agent" {
+				target = s
+			}
+		}
+		require.NotEmpty(t, target.ID)
+
+		assert.Equal(t, http.StatusNoContent, do("DELETE", "/me/sessions/"+target.ID).Code)
+		assert.Equal(t, http.StatusNotFound, do("DELETE", "/me/sessions/"+target.ID).Code)
+		assert.Equal(t, http.StatusBadRequest, do("DELETE", "/me/sessions/not-a-uuid").Code)
+		assert.Len(t, listSessions(), 2)
+	})
+
+	t.Run("revoke all others keeps current", func(t *testing.T) {
+		w := do("DELETE", "/me/sessions")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"revoked":1}`, w.Body.String())
+
+		sessions := listSessions()
+		require.Len(t, sessions, 1)
+		assert.True(t, sessions[0].Current)
+	})
+}
+
+func TestHealthHandler_GatewayState(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	newHealthRouter := func(t *testing.T, redisAddr string) (*gin.Engine, *proxy.CircuitBreaker) {
+		serviceProxy := proxy.NewServiceProxy("analysis", backend.URL, 5*time.Second, logger)
+		breaker := proxy.NewCircuitBreaker(serviceProxy, 2, time.Hour)
+
+		redisClient := redis.NewClient(&redis.Options{Addr: redisAddr, MaxRetries: -1})
+		t.Cleanup(func() { redisClient.Close() })
+
+		healthHandler := handler.NewHealthHandler(map[string]*proxy.ServiceProxy{"analysis": serviceProxy}, logger)
+		healthHandler.SetCircuitBreakers(map[string]*proxy.CircuitBreaker{"analysis": breaker})
+		healthHandler.SetRedis(redisClient)
+		healthHandler.SetRateLimiter(rate.NewLimiter(100, 200))
+		healthHandler.SetRequestCounter(middleware.NewRequestCounter())
+
+		router := setupTestRouter()
+		router.GET("/health", healthHandler.Health)
+		router.GET("/fail", func(c *gin.Context) {
+			breaker.Call(c, http.MethodGet, "/fail")
+		})
+		return router, breaker
+	}
+
+	health := func(router *gin.Engine) (int, handler.HealthResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		var response handler.HealthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	t.Run("healthy", func(t *testing.T) {
+		router, _ := newHealthRouter(t, miniredis.RunT(t).Addr())
+
+		code, response := health(router)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "healthy", response.Status)
+		assert.Equal(t, utils.Version, response.Version)
+		assert.Equal(t, utils.BuildCommit(), response.Commit)
+		assert.Equal(t, map[string]string{"analysis": proxy.BreakerClosed}, response.Gateway.CircuitBreakers)
+		require.NotNil(t, response.Gateway.Redis)
+		assert.Equal(t, "healthy", response.Gateway.Redis.Status)
+		require.NotNil(t, response.Gateway.RateLimiter)
+		assert.Equal(t, 200, response.Gateway.RateLimiter.Burst)
+		assert.InDelta(t, 0, response.Gateway.RateLimiter.Saturation, 0.01)
+	})
+
+	t.Run("degraded when a breaker is open", func(t *testing.T) {
+		router, breaker := newHealthRouter(t, miniredis.RunT(t).Addr())
+		for i := 0; i < 2; i++ {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+		}
+		require.Equal(t, proxy.BreakerOpen, breaker.State())
+
+		code, response := health(router)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "degraded", response.Status)
+		assert.Equal(t, "healthy", response.Services["analysis"].Status)
+		assert.Equal(t, proxy.BreakerOpen, response.Gateway.CircuitBreakers["analysis"])
+	})
+
+	t.Run("unhealthy without Redis", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		router, _ := newHealthRouter(t, mr.Addr())
+		mr.Close()
+
+		code, response := health(router)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "unhealthy", response.Status)
+		require.NotNil(t, response.Gateway.Redis)
+		assert.Equal(t, "unhealthy", response.Gateway.Redis.Status)
+		assert.NotEmpty(t, response.Gateway.Redis.Error)
+	})
+}
+
+// shutdownRecorder records the steps of a shutdown sequence
+type shutdownRecorder struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (r *shutdownRecorder) record(step string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, step)
+}
+
+func (r *shutdownRecorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.steps...)
+}
+
+type stubServer struct {
+	recorder *shutdownRecorder
+	onStop   func()
+	err      error
+}
+
+func (s *stubServer) Shutdown(ctx context.Context) error {
+	if s.onStop != nil {
+		s.onStop()
+	}
+	s.recorder.record("server")
+	return s.err
+}
+
+type stubCloser struct {
+	name     string
+	recorder *shutdownRecorder
+	err      error
+}
+
+func (c *stubCloser) Close() error {
+	c.recorder.record(c.name)
+	return c.err
+}
+
+type stubInFlight struct {
+	inFlight atomic.Int64
+}
+
+func (s *stubInFlight) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+func TestShutdown_Sequence(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	newHealth := func() (*handler.HealthHandler, *gin.Engine) {
+		serviceProxy := proxy.NewServiceProxy("analysis", "http://localhost:1", time.Second, logger)
+		health := handler.NewHealthHandler(map[string]*proxy.ServiceProxy{"analysis": serviceProxy}, logger)
+		router := setupTestRouter()
+		router.GET("/ready", health.Ready)
+		return health, router
+	}
+	readiness := func(router *gin.Engine) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		return w.Code
+	}
+
+	t.Run("closes dependencies after requests drain", func(t *testing.T) {
+		health, router := newHealth()
+		require.Equal(t, http.StatusOK, readiness(router))
+
+		recorder := &shutdownRecorder{}
+		requests := &stubInFlight{}
+		requests.inFlight.Store(1)
+
+		var readyWhenStopped int
+		server := &stubServer{recorder: recorder, onStop: func() {
+			readyWhenStopped = readiness(router)
+			// A request outlives the server's own shutdown, as a hijacked
+			// connection would
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				recorder.record("request done")
+				requests.inFlight.Store(0)
+			}()
+		}}
+
+		shutdown := handler.NewShutdown(server, logger)
+		shutdown.SetHealthHandler(health)
+		shutdown.SetRequestCounter(requests)
+		shutdown.AddCloser("redis", &stubCloser{name: "redis", recorder: recorder})
+		shutdown.AddCloser("database", &stubCloser{name: "database", recorder: recorder})
+
+		require.NoError(t, shutdown.Run(context.Background()))
+		assert.Equal(t, http.StatusServiceUnavailable, readyWhenStopped)
+		assert.Equal(t, []string{"server", "request done", "redis", "database"}, recorder.recorded())
+	})
+
+	t.Run("closes dependencies when draining times out", func(t *testing.T) {
+		recorder := &shutdownRecorder{}
+		requests := &stubInFlight{}
+		requests.inFlight.Store(2)
+
+		shutdown := handler.NewShutdown(&stubServer{recorder: recorder}, logger)
+		shutdown.SetRequestCounter(requests)
+		shutdown.AddCloser("redis", &stubCloser{name: "redis", recorder: recorder})
+		shutdown.AddCloser("database", &stubCloser{name: "database", recorder: recorder})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err := shutdown.Run(ctx)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, err.Error(), "2 requests still in flight")
+		assert.Equal(t, []string{"server", "redis", "database"}, recorder.recorded())
+	})
+
+	t.Run("reports every failed step", func(t *testing.T) {
+		recorder := &shutdownRecorder{}
+		serverErr := fmt.Errorf("listener stuck")
+		closeErr := fmt.Errorf("connection reset")
+
+		shutdown := handler.NewShutdown(&stubServer{recorder: recorder, err: serverErr}, logger)
+		shutdown.AddCloser("redis", &stubCloser{name: "redis", recorder: recorder, err: closeErr})
+		shutdown.AddCloser("database", &stubCloser{name: "database", recorder: recorder})
+
+		err := shutdown.Run(context.Background())
+		assert.ErrorIs(t, err, serverErr)
+		assert.ErrorIs(t, err, closeErr)
+		assert.Equal(t, []string{"server", "redis", "database"}, recorder.recorded())
+	})
+}
+
+func TestProductionAuthHandler_LoginLogsMasked(t *testing.T) {
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(&logs)
+	logger.AddHook(utils.MaskingHook{})
+
+	db := testutil.NewTestDB(t, &models.User{})
+	authService := services.NewAuthService(services.NewDatabaseServiceFromDB(db, nil, logger), logger)
+	authHandler := handler.NewProductionAuthHandler(authService, logger)
+
+	router := setupTestRouter()
+	router.Use(middleware.Logger(logger))
+	router.POST("/login", authHandler.Login)
+
+	const password = "hunter2-Sup3rSecret"
+	body, err := json.Marshal(services.UserLogin{Email: "nobody@example.com", Password: password})
+	require.NoError(t, err)
+
+	// Some clients also repeat the credentials in the query string
+	req := httptest.NewRequest(http.MethodPost, "/login?email=nobody%40example.com&password="+password, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+password)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, logs.String(), "Login failed")
+	assert.Contains(t, logs.String(), "password="+utils.RedactedValue)
+	assert.NotContains(t, logs.String(), password)
+}
+
+func TestProductionAuthHandler_LoginRecordsClientIP(t *testing.T) {
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(&logs)
+
+	db := testutil.NewTestDB(t, &models.User{})
+	authService := services.NewAuthService(services.NewDatabaseServiceFromDB(db, nil, logger), logger)
+	authHandler := handler.NewProductionAuthHandler(authService, logger)
+
+	router := setupTestRouter()
+	require.NoError(t, router.SetTrustedProxies([]string{"10.0.0.0/8"}))
+	router.POST("/login", authHandler.Login)
+
+	login := func(remoteAddr, forwardedFor string) map[string]interface{} {
+		logs.Reset()
+		body, err := json.Marshal(services.UserLogin{Email: "nobody@example.com", Password: "wrong"})
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = remoteAddr
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal(line, &entry))
+			if entry["msg"] == "Login failed" {
+				return entry
+			}
+		}
+		t.Fatal("login failure not logged")
+		return nil
+	}
+
+	assert.Equal(t, "203.0.113.9", login("203.0.113.9:4711", "6.6.6.6")["ip_address"])
+	assert.Equal(t, "198.51.100.7", login("10.0.0.5:4711", "198.51.100.7")["ip_address"])
+}
+
+func TestFallbackHandlers(t *testing.T) {
+	router := setupTestRouter()
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(handler.NoRoute)
+	router.NoMethod(handler.NoMethod)
+	projects := router.Group("/api/v1/projects")
+	projects.GET("/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	projects.PUT("/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantCode   string
+		wantAllow  string
+	}{
+		{"unknown path", http.MethodGet, "/api/v1/unknown", http.StatusNotFound, utils.ErrCodeNotFound, ""},
+		{"wrong method", http.MethodPost, "/api/v1/projects/123", http.StatusMethodNotAllowed, utils.ErrCodeMethodNotAllowed, "GET, PUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantAllow, w.Header().Get("Allow"))
+			var body utils.ErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantCode, body.Code)
+			assert.NotEmpty(t, body.Message)
+		})
+	}
+}
+
+func TestProjectHandler_Links(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	projectHandler := handler.NewProjectHandler(logger)
+
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user-123")
+		c.Next()
+	})
+	router.GET("/api/v1/projects", projectHandler.ListProjects)
+	router.GET("/api/v1/projects/:id", projectHandler.GetProject)
+
+	get := func(t *testing.T, req *http.Request) handler.Project {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var project handler.Project
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+		return project
+	}
+
+	t.Run("not requested", func(t *testing.T) {
+		project := get(t, httptest.NewRequest(http.MethodGet, "/api/v1/projects/proj-1", nil))
+		assert.Nil(t, project.Links)
+	})
+
+	t.Run("accept header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/projects/proj-1", nil)
+		req.Header.Set("Accept", utils.HALMediaType)
+		assert.Equal(t, utils.Links{
+			"self":     {Href: "http://example.com/api/v1/projects/proj-1"},
+			"metrics":  {Href: "http://example.com/api/v1/metrics/project/proj-1"},
+			"trends":   {Href: "http://example.com/api/v1/metrics/trends/proj-1"},
+			"analysis": {Href: "http://example.com/api/v1/analysis/start/proj-1", Method: http.MethodPost},
+		}, get(t, req).Links)
+	})
+
+	t.Run("behind a TLS proxy", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/projects/proj-1?links=true", nil)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "sa3d.example.com")
+		assert.Equal(t, "https://sa3d.example.com/api/v1/projects/proj-1", get(t, req).Links["self"].Href)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/projects?links=true", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Projects []handler.Project `json:"projects"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.NotEmpty(t, resp.Projects)
+		for _, project := range resp.Projects {
+			assert.Equal(t, "http://example.com/api/v1/projects/"+project.ID, project.Links["self"].Href)
+		}
+	})
+}
+
+func TestProductionAuthHandler_ValidateBatch(t *testing.T) {
+	const internalToken = "internal-token-0123456789abcdefghij"
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t, &models.User{}, &models.UserSession{})
+	authService := services.NewAuthService(services.NewDatabaseServiceFromDB(db, nil, logger), logger)
+	authHandler := handler.NewProductionAuthHandler(authService, logger)
+
+	router := setupTestRouter()
+	router.POST("/api/v1/auth/validate-batch", middleware.InternalAuth(internalToken), authHandler.ValidateBatch)
+
+	session := func(username string, active bool, expiresAt time.Time) (*models.User, string) {
+		user := &models.User{Email: username + "@example.com", Username: username, Password: "hash", Role: "user", IsActive: true}
+		require.NoError(t, db.Create(user).Error)
+		if !active {
+			require.NoError(t, db.Model(user).Update("is_active", false).Error)
+		}
+		token := "token-" + username
+		require.NoError(t, db.Create(&models.UserSession{
+			UserID:       user.ID,
+			SessionToken: token,
+			RefreshToken: "refresh-" + username,
+			ExpiresAt:    expiresAt,
+			IsActive:     true,
+		}).Error)
+		return user, token
+	}
+	alice, valid := session("alice", true, time.Now().Add(time.Hour))
+	_, expired := session("bob", true, time.Now().Add(-time.Minute))
+	_, inactive := session("carol", false, time.Now().Add(time.Hour))
+
+	validate := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/validate-batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set(middleware.InternalTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("mixed tokens", func(t *testing.T) {
+		body, err := json.Marshal(handler.ValidateBatchRequest{Tokens: []string{valid, expired, "forged", inactive}})
+		require.NoError(t, err)
+		w := validate(internalToken, string(body))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Results []handler.TokenValidation `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, []handler.TokenValidation{
+			{Valid: true, UserID: alice.ID.String(), Email: "alice@example.com", Role: "user"},
+			{Error: "expired"},
+			{Error: "invalid"},
+			{Error: "inactive"},
+		}, resp.Results)
+	})
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"no internal token", "", `{"tokens":["x"]}`, http.StatusUnauthorized},
+		{"wrong internal token", "internal-token-wrong", `{"tokens":["x"]}`, http.StatusUnauthorized},
+		{"user token", valid, `{"tokens":["x"]}`, http.StatusUnauthorized},
+		{"no tokens", internalToken, `{"tokens":[]}`, http.StatusBadRequest},
+		{"too many tokens", internalToken, `{"tokens":[` + strings.Repeat(`"x",`, handler.MaxValidateBatch) + `"x"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := validate(tt.token, tt.body)
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+		})
+	}
+
+	t.Run("disabled without internal token", func(t *testing.T) {
+		router := setupTestRouter()
+		router.POST("/api/v1/auth/validate-batch", middleware.InternalAuth(""), authHandler.ValidateBatch)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/validate-batch", strings.NewReader(`{"tokens":["x"]}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	doc := openapi.New("Test API", "2.0.0")
+	doc.Add(http.MethodGet, "/projects/:id", openapi.Operation{
+		Summary: "Get a <project>",
+		Tags:    []string{"Projects"},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "OK", Content: openapi.JSON(doc.SchemaOf(handler.Project{}))},
+		},
+	})
+	h, err := handler.NewOpenAPIHandler(doc)
+	require.NoError(t, err)
+
+	router := setupTestRouter()
+	router.GET("/openapi.json", h.Spec)
+	router.GET("/docs", h.Docs)
+
+	t.Run("spec", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+		var spec map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+		assert.Equal(t, openapi.Version, spec["openapi"])
+		paths := spec["paths"].(map[string]interface{})
+		require.Contains(t, paths, "/projects/{id}", "gin parameters are written as OpenAPI ones")
+		operation := paths["/projects/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+		parameters := operation["parameters"].([]interface{})
+		require.Len(t, parameters, 1)
+		assert.Equal(t, "id", parameters[0].(map[string]interface{})["name"])
+		assert.Contains(t, spec["components"].(map[string]interface{})["schemas"], "Project")
+	})
+
+	t.Run("docs", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		body := w.Body.String()
+		assert.Contains(t, body, "<h2>Projects</h2>")
+		assert.Contains(t, body, "GET /projects/{id}")
+		assert.Contains(t, body, "Get a &lt;project&gt;", "text is escaped")
+		assert.NotContains(t, body, "<script")
+	})
+}
+
+func TestProjectHandler_TimestampsUTC(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	projectHandler := handler.NewProjectHandler(logger)
+
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "test-user-123")
+		c.Next()
+	})
+	router.POST("/api/v1/projects", projectHandler.CreateProject)
+	router.GET("/api/v1/projects/:id", projectHandler.GetProject)
+
+	assertUTC := func(t *testing.T, w *httptest.ResponseRecorder) {
+		t.Helper()
+		var fields map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fields), w.Body.String())
+		for _, name := range []string{"created_at", "updated_at"} {
+			value, ok := fields[name].(string)
+			require.True(t, ok, name)
+			assert.True(t, strings.HasSuffix(value, "Z"), "%s %q is not UTC", name, value)
+			_, err := time.Parse(time.RFC3339, value)
+			assert.NoError(t, err, name)
+		}
+	}
+
+	t.Run("created", func(t *testing.T) {
+		body, _ := json.Marshal(handler.CreateProjectRequest{Name: "Test", Language: "go"})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/projects", bytes.NewReader(body)))
+		require.Equal(t, http.StatusCreated, w.Code)
+		assertUTC(t, w)
+	})
+
+	t.Run("fetched", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/projects/proj-1", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		assertUTC(t, w)
+	})
+}
+
+func TestProductionAuthHandler_Me(t *testing.T) {
+	const (
+		internalToken = "internal-token"
+		password      = "Str0ng!Passw0rd"
+	)
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t, &models.User{}, &models.UserSession{})
+	authService := services.NewAuthService(services.NewDatabaseServiceFromDB(db, nil, logger), logger)
+	authHandler := handler.NewProductionAuthHandler(authService, logger)
+
+	router := setupTestRouter()
+	router.GET("/api/v1/me", middleware.UserOrInternalAuth(authService, internalToken, logger), authHandler.Me)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &models.User{Email: "me@example.com", Username: "me", Password: string(hash), Role: "user", IsActive: true}
+	require.NoError(t, db.Create(user).Error)
+	login, err := authService.Login(services.UserLogin{Email: user.Email, Password: password})
+	require.NoError(t, err)
+
+	get := func(header, value string) (*httptest.ResponseRecorder, handler.Identity) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var identity handler.Identity
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &identity))
+		}
+		return w, identity
+	}
+
+	t.Run("user token", func(t *testing.T) {
+		w, identity := get("Authorization", "Bearer "+login.AccessToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, middleware.IdentityUser, identity.Type)
+		require.NotNil(t, identity.User)
+		assert.Equal(t, user.ID, identity.User.ID)
+		assert.Equal(t, user.Email, identity.User.Email)
+		assert.Equal(t, []string{"user"}, identity.Roles)
+		assert.Contains(t, identity.Permissions, "projects:write")
+		assert.NotContains(t, identity.Permissions, "users:manage")
+		require.NotNil(t, identity.TokenExpiresAt)
+		assert.WithinDuration(t, login.ExpiresAt, *identity.TokenExpiresAt, time.Millisecond, "expires with the session")
+		assert.NotContains(t, w.Body.String(), `"password"`)
+	})
+
+	t.Run("role changed since login", func(t *testing.T) {
+		require.NoError(t, authService.SetUserRole(user.ID, "admin"))
+		t.Cleanup(func() { _ = authService.SetUserRole(user.ID, "user") })
+
+		// Changing a role revokes the user's sessions
+		relogin, err := authService.Login(services.UserLogin{Email: user.Email, Password: password})
+		require.NoError(t, err)
+		w, identity := get("Authorization", "Bearer "+relogin.AccessToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, []string{"admin"}, identity.Roles)
+		assert.Contains(t, identity.Permissions, "users:manage")
+	})
+
+	t.Run("API key", func(t *testing.T) {
+		w, identity := get(middleware.InternalTokenHeader, internalToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, middleware.IdentityAPIKey, identity.Type)
+		assert.Nil(t, identity.User)
+		assert.Nil(t, identity.TokenExpiresAt)
+		assert.Equal(t, []string{middleware.RoleInternal}, identity.Roles)
+		assert.Equal(t, []string{"tokens:validate"}, identity.Permissions)
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		w, _ := get("", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w, _ = get(middleware.InternalTokenHeader, "wrong")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w, _ = get("Authorization", "Bearer not-a-token")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
@@ -2,20 +2,39 @@ package handler
 
 import (
 	"context"
+	"math"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 
 	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/proxy"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
 )
 
+// rateLimiterSaturationWarning is the share of the global rate limit's burst
+// in use above which the gateway reports itself degraded
+const rateLimiterSaturationWarning = 0.9
+
+// InFlightCounter reports how many requests the gateway is serving
+type InFlightCounter interface {
+	InFlight() int64
+}
+
 // HealthHandler handles health check endpoints
 type HealthHandler struct {
-	services map[string]*proxy.ServiceProxy
-	logger   *logrus.Logger
+	services    map[string]*proxy.ServiceProxy
+	breakers    map[string]*proxy.CircuitBreaker
+	redisClient *redis.Client
+	limiter     *rate.Limiter
+	requests    InFlightCounter
+	shutting    atomic.Bool
+	logger      *logrus.Logger
 }
 
 // NewHealthHandler creates a new health handler
@@ -26,11 +45,39 @@ func NewHealthHandler(services map[string]*proxy.ServiceProxy, logger *logrus.Lo
 	}
 }
 
+// SetCircuitBreakers sets the per-service circuit breakers to report
+func (h *HealthHandler) SetCircuitBreakers(breakers map[string]*proxy.CircuitBreaker) {
+	h.breakers = breakers
+}
+
+// SetRedis sets the Redis client whose connectivity is checked
+func (h *HealthHandler) SetRedis(client *redis.Client) {
+	h.redisClient = client
+}
+
+// SetRateLimiter sets the global rate limiter whose saturation is reported
+func (h *HealthHandler) SetRateLimiter(limiter *rate.Limiter) {
+	h.limiter = limiter
+}
+
+// SetRequestCounter sets the source of the current request concurrency
+func (h *HealthHandler) SetRequestCounter(counter InFlightCounter) {
+	h.requests = counter
+}
+
+// SetShuttingDown marks the gateway as shutting down, which makes it report
+// not ready so load balancers stop sending it traffic
+func (h *HealthHandler) SetShuttingDown(shutting bool) {
+	h.shutting.Store(shutting)
+}
+
 // HealthResponse represents a health check response
 type HealthResponse struct {
 	Status   string                   `json:"status"`
 	Version  string                   `json:"version"`
+	Commit   string                   `json:"commit"`
 	Services map[string]ServiceHealth `json:"services"`
+	Gateway  GatewayHealth            `json:"gateway"`
 }
 
 // ServiceHealth represents the health of a service
@@ -40,21 +87,48 @@ type ServiceHealth struct {
 	Error        string `json:"error,omitempty"`
 }
 
-// Health returns the overall health status
+// GatewayHealth is the gateway's own operational state
+type GatewayHealth struct {
+	CircuitBreakers  map[string]string  `json:"circuit_breakers,omitempty"`
+	Redis            *DependencyHealth  `json:"redis,omitempty"`
+	RateLimiter      *RateLimiterHealth `json:"rate_limiter,omitempty"`
+	InFlightRequests int64              `json:"in_flight_requests"`
+}
+
+// DependencyHealth represents the health of a gateway dependency
+type DependencyHealth struct {
+	Status       string `json:"status"`
+	ResponseTime int64  `json:"response_time_ms"`
+	Error        string `json:"error,omitempty"`
+}
+
+// RateLimiterHealth reports how much of the global rate limit is in use
+type RateLimiterHealth struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+	AvailableTokens   float64 `json:"available_tokens"`
+	Saturation        float64 `json:"saturation"`
+}
+
+// Health returns the overall health status: unhealthy when Redis is down or
+// no backend service is healthy, degraded when some service is unhealthy, a
+// circuit breaker isn't closed or the rate limiter is nearly exhausted
 func (h *HealthHandler) Health(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	response := HealthResponse{
 		Status:   "healthy",
-		Version:  "1.0.0", // TODO: Get from build info
+		Version:  utils.Version,
+		Commit:   utils.BuildCommit(),
 		Services: make(map[string]ServiceHealth),
+		Gateway:  h.gatewayHealth(ctx),
 	}
 
 	// Check all services concurrently
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	allHealthy := true
+	unhealthyServices := 0
 
 	for name, service := range h.services {
 		wg.Add(1)
@@ -73,12 +147,12 @@ func (h *HealthHandler) Health(c *gin.Context) {
 			if err != nil {
 				health.Status = "unhealthy"
 				health.Error = err.Error()
-				mu.Lock()
-				allHealthy = false
-				mu.Unlock()
 			}
 
 			mu.Lock()
+			if err != nil {
+				unhealthyServices++
+			}
 			response.Services[serviceName] = health
 			mu.Unlock()
 		}(name, service)
@@ -86,18 +160,81 @@ func (h *HealthHandler) Health(c *gin.Context) {
 
 	wg.Wait()
 
-	if !allHealthy {
+	gateway := response.Gateway
+	switch {
+	case gateway.Redis != nil && gateway.Redis.Status != "healthy",
+		len(h.services) > 0 && unhealthyServices == len(h.services):
+		response.Status = "unhealthy"
+	case unhealthyServices > 0, anyBreakerTripped(gateway.CircuitBreakers),
+		gateway.RateLimiter != nil && gateway.RateLimiter.Saturation >= rateLimiterSaturationWarning:
 		response.Status = "degraded"
 	}
 
 	statusCode := http.StatusOK
-	if response.Status == "degraded" {
+	if response.Status != "healthy" {
 		statusCode = http.StatusServiceUnavailable
 	}
 
 	c.JSON(statusCode, response)
 }
 
+// gatewayHealth collects the states of the gateway's own components
+func (h *HealthHandler) gatewayHealth(ctx context.Context) GatewayHealth {
+	var health GatewayHealth
+
+	if len(h.breakers) > 0 {
+		health.CircuitBreakers = make(map[string]string, len(h.breakers))
+		for name, breaker := range h.breakers {
+			health.CircuitBreakers[name] = breaker.State()
+		}
+	}
+
+	if h.redisClient != nil {
+		start := time.Now()
+		err := h.redisClient.Ping(ctx).Err()
+		health.Redis = &DependencyHealth{
+			Status:       "healthy",
+			ResponseTime: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			health.Redis.Status = "unhealthy"
+			health.Redis.Error = err.Error()
+		}
+	}
+
+	// An unlimited limiter can't saturate
+	if h.limiter != nil && h.limiter.Limit() != rate.Inf {
+		tokens := h.limiter.Tokens()
+		burst := h.limiter.Burst()
+		saturation := 1.0
+		if burst > 0 {
+			saturation = math.Min(1, math.Max(0, 1-tokens/float64(burst)))
+		}
+		health.RateLimiter = &RateLimiterHealth{
+			RequestsPerSecond: float64(h.limiter.Limit()),
+			Burst:             burst,
+			AvailableTokens:   math.Max(0, tokens),
+			Saturation:        saturation,
+		}
+	}
+
+	if h.requests != nil {
+		health.InFlightRequests = h.requests.InFlight()
+	}
+
+	return health
+}
+
+// anyBreakerTripped reports whether any circuit breaker is open or half-open
+func anyBreakerTripped(states map[string]string) bool {
+	for _, state := range states {
+		if state != proxy.BreakerClosed {
+			return true
+		}
+	}
+	return false
+}
+
 // Ready checks if the service is ready to accept requests
 func (h *HealthHandler) Ready(c *gin.Context) {
 	// Check critical dependencies
@@ -110,6 +247,11 @@ func (h *HealthHandler) Ready(c *gin.Context) {
 		errors = append(errors, "No backend services configured")
 	}
 
+	if h.shutting.Load() {
+		ready = false
+		errors = append(errors, "Shutting down")
+	}
+
 	// TODO: Add more readiness checks (database, cache, etc.)
 
 	if ready {
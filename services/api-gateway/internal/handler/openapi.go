@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/openapi"
+)
+
+// OpenAPIHandler serves the gateway's OpenAPI document, as JSON for clients
+// and as an HTML reference for people
+type OpenAPIHandler struct {
+	spec []byte
+	docs []byte
+}
+
+// docsOperation is an operation as the reference lists it
+type docsOperation struct {
+	Method    string
+	Path      string
+	Operation *openapi.Operation
+	Public    bool
+}
+
+// docsTag is a section of the reference
+type docsTag struct {
+	Name       string
+	Operations []docsOperation
+}
+
+// docsTemplate renders the reference. It has no scripts or styles, which
+// the gateway's Content-Security-Policy would refuse.
+var docsTemplate = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Info.Title}} {{.Info.Version}}</title>
+</head>
+<body>
+<h1>{{.Info.Title}} <small>{{.Info.Version}}</small></h1>
+<p>The machine-readable description is at <a href="openapi.json">openapi.json</a>.</p>
+{{range .Tags}}<h2>{{.Name}}</h2>
+{{range .Operations}}<h3><code>{{.Method}} {{.Path}}</code></h3>
+{{with .Operation.Summary}}<p>{{.}}</p>
+{{end}}{{with .Operation.Description}}<p>{{.}}</p>
+{{end}}{{if .Public}}<p>Public.</p>
+{{end}}{{with .Operation.Parameters}}<ul>
+{{range .}}<li><code>{{.Name}}</code> ({{.In}}{{if .Required}}, required{{end}}){{with .Description}}: {{.}}{{end}}</li>
+{{end}}</ul>
+{{end}}{{end}}{{end}}</body>
+</html>
+`))
+
+// NewOpenAPIHandler renders doc once for every request to serve
+func NewOpenAPIHandler(doc *openapi.Document) (*OpenAPIHandler, error) {
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OpenAPI document: %w", err)
+	}
+
+	sections := make(map[string]*docsTag)
+	for _, route := range doc.Routes() {
+		method, path, _ := strings.Cut(route, " ")
+		operation := doc.Paths[path][strings.ToLower(method)]
+		tag := "Other"
+		if len(operation.Tags) > 0 {
+			tag = operation.Tags[0]
+		}
+		if sections[tag] == nil {
+			sections[tag] = &docsTag{Name: tag}
+		}
+		sections[tag].Operations = append(sections[tag].Operations, docsOperation{
+			Method: method, Path: path, Operation: operation, Public: len(operation.Security) == 0,
+		})
+	}
+	tags := make([]docsTag, 0, len(sections))
+	for _, section := range sections {
+		tags = append(tags, *section)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	var docs bytes.Buffer
+	if err := docsTemplate.Execute(&docs, struct {
+		Info openapi.Info
+		Tags []docsTag
+	}{doc.Info, tags}); err != nil {
+		return nil, fmt.Errorf("failed to render API reference: %w", err)
+	}
+	return &OpenAPIHandler{spec: spec, docs: docs.Bytes()}, nil
+}
+
+// Spec serves the OpenAPI document
+func (h *OpenAPIHandler) Spec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.spec)
+}
+
+// Docs serves the HTML reference
+func (h *OpenAPIHandler) Docs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", h.docs)
+}
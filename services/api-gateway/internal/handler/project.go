@@ -1,17 +1,26 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
 )
 
 // ProjectHandler handles project-related endpoints
 type ProjectHandler struct {
-	logger *logrus.Logger
+	projectService *services.ProjectService
+	logger         *logrus.Logger
 }
 
 // NewProjectHandler creates a new project handler
@@ -21,6 +30,14 @@ func NewProjectHandler(logger *logrus.Logger) *ProjectHandler {
 	}
 }
 
+// NewProductionProjectHandler creates a project handler backed by the database
+func NewProductionProjectHandler(projectService *services.ProjectService, logger *logrus.Logger) *ProjectHandler {
+	return &ProjectHandler{
+		projectService: projectService,
+		logger:         logger,
+	}
+}
+
 // Project represents a project
 type Project struct {
 	ID          string    `json:"id"`
@@ -28,9 +45,20 @@ type Project struct {
 	Description string    `json:"description"`
 	Language    string    `json:"language"`
 	Repository  string    `json:"repository"`
+	Branch      string    `json:"branch,omitempty"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	CreatedBy   string    `json:"created_by"`
+	// NotificationEmails and NotificationWebhooks receive a summary of
+	// each finished analysis
+	NotificationEmails   []string `json:"notification_emails,omitempty"`
+	NotificationWebhooks []string `json:"notification_webhooks,omitempty"`
+	// EnabledLanguages limits analysis to these languages, all when empty;
+	// issues below MinSeverity are left out of the aggregate counts
+	EnabledLanguages []string `json:"enabled_languages,omitempty"`
+	MinSeverity      string   `json:"min_severity,omitempty"`
+	// Links are set when the client asks for them
+	Links utils.Links `json:"_links,omitempty"`
 }
 
 // CreateProjectRequest represents a request to create a project
@@ -39,6 +67,17 @@ type CreateProjectRequest struct {
 	Description string `json:"description"`
 	Language    string `json:"language" binding:"required"`
 	Repository  string `json:"repository"`
+	// Branch defaults to the first configured fallback branch the repository has
+	Branch string `json:"branch"`
+	// NotificationEmails and NotificationWebhooks receive a summary of
+	// each finished analysis; webhooks are http or https URLs
+	NotificationEmails   []string `json:"notification_emails"`
+	NotificationWebhooks []string `json:"notification_webhooks"`
+	// EnabledLanguages are any of go, java, python, javascript, typescript
+	// and csharp, all when empty; MinSeverity is info, minor, major or
+	// critical, counting every issue when empty
+	EnabledLanguages []string `json:"enabled_languages"`
+	MinSeverity      string   `json:"min_severity"`
 }
 
 // UpdateProjectRequest represents a request to update a project
@@ -47,11 +86,20 @@ type UpdateProjectRequest struct {
 	Description string `json:"description"`
 	Language    string `json:"language"`
 	Repository  string `json:"repository"`
+	Branch      string `json:"branch"`
+	// NotificationEmails and NotificationWebhooks replace the recipients
+	// when given; an empty list removes them
+	NotificationEmails   []string `json:"notification_emails"`
+	NotificationWebhooks []string `json:"notification_webhooks"`
+	// EnabledLanguages replaces the languages analyzed when given; an
+	// empty list enables all of them
+	EnabledLanguages []string `json:"enabled_languages"`
+	MinSeverity      string   `json:"min_severity"`
 }
 
 // ListProjects returns a list of projects
 func (h *ProjectHandler) ListProjects(c *gin.Context) {
-	userID := c.GetString("user_id")
+	userID := contextkeys.UserID.GetString(c)
 	
 	// TODO: Implement actual database query
 	// For now, returning mock data
@@ -62,8 +110,8 @@ func (h *ProjectHandler) ListProjects(c *gin.Context) {
 			Description: "A sample Go project",
 			Language:    "go",
 			Repository:  "https://github.com/example/project1",
-			CreatedAt:   time.Now().Add(-24 * time.Hour),
-			UpdatedAt:   time.Now().Add(-2 * time.Hour),
+			CreatedAt:   utils.Now().Add(-24 * time.Hour),
+			UpdatedAt:   utils.Now().Add(-2 * time.Hour),
 			CreatedBy:   userID,
 		},
 		{
@@ -72,12 +120,16 @@ func (h *ProjectHandler) ListProjects(c *gin.Context) {
 			Description: "A sample Python project",
 			Language:    "python",
 			Repository:  "https://github.com/example/project2",
-			CreatedAt:   time.Now().Add(-48 * time.Hour),
-			UpdatedAt:   time.Now().Add(-12 * time.Hour),
+			CreatedAt:   utils.Now().Add(-48 * time.Hour),
+			UpdatedAt:   utils.Now().Add(-12 * time.Hour),
 			CreatedBy:   userID,
 		},
 	}
 
+	for i := range projects {
+		projects[i] = withProjectLinks(c, projects[i])
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"projects": projects,
 		"total":    len(projects),
@@ -92,7 +144,12 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		return
 	}
 
-	userID := c.GetString("user_id")
+	userID := contextkeys.UserID.GetString(c)
+
+	if h.projectService != nil {
+		h.createProject(c, req, userID)
+		return
+	}
 	
 	// Create project
 	project := Project{
@@ -101,9 +158,15 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		Description: req.Description,
 		Language:    req.Language,
 		Repository:  req.Repository,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Branch:      req.Branch,
+		CreatedAt:   utils.Now(),
+		UpdatedAt:   utils.Now(),
 		CreatedBy:   userID,
+
+		NotificationEmails:   req.NotificationEmails,
+		NotificationWebhooks: req.NotificationWebhooks,
+		EnabledLanguages:     req.EnabledLanguages,
+		MinSeverity:          req.MinSeverity,
 	}
 
 	// TODO: Save to database
@@ -113,13 +176,13 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		"user_id":    userID,
 	}).Info("Project created")
 
-	c.JSON(http.StatusCreated, project)
+	c.JSON(http.StatusCreated, withProjectLinks(c, project))
 }
 
 // GetProject returns a specific project
 func (h *ProjectHandler) GetProject(c *gin.Context) {
 	projectID := c.Param("id")
-	userID := c.GetString("user_id")
+	userID := contextkeys.UserID.GetString(c)
 
 	// TODO: Fetch from database
 	// For now, returning mock data
@@ -129,12 +192,12 @@ func (h *ProjectHandler) GetProject(c *gin.Context) {
 		Description: "A sample project",
 		Language:    "go",
 		Repository:  "https://github.com/example/project",
-		CreatedAt:   time.Now().Add(-24 * time.Hour),
-		UpdatedAt:   time.Now().Add(-2 * time.Hour),
+		CreatedAt:   utils.Now().Add(-24 * time.Hour),
+		UpdatedAt:   utils.Now().Add(-2 * time.Hour),
 		CreatedBy:   userID,
 	}
 
-	c.JSON(http.StatusOK, project)
+	c.JSON(http.StatusOK, withProjectLinks(c, project))
 }
 
 // UpdateProject updates a project
@@ -147,6 +210,11 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 		return
 	}
 
+	if h.projectService != nil {
+		h.updateProject(c, projectID, req)
+		return
+	}
+
 	// TODO: Fetch existing project from database
 	// TODO: Check permissions
 	// TODO: Update project in database
@@ -158,30 +226,189 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 		Description: req.Description,
 		Language:    req.Language,
 		Repository:  req.Repository,
-		UpdatedAt:   time.Now(),
+		Branch:      req.Branch,
+		UpdatedAt:   utils.Now(),
+
+		NotificationEmails:   req.NotificationEmails,
+		NotificationWebhooks: req.NotificationWebhooks,
+		EnabledLanguages:     req.EnabledLanguages,
+		MinSeverity:          req.MinSeverity,
 	}
 
 	h.logger.WithFields(logrus.Fields{
 		"project_id": projectID,
-		"user_id":    c.GetString("user_id"),
+		"user_id":    contextkeys.UserID.GetString(c),
 	}).Info("Project updated")
 
-	c.JSON(http.StatusOK, project)
+	c.JSON(http.StatusOK, withProjectLinks(c, project))
+}
+
+func (h *ProjectHandler) createProject(c *gin.Context, req CreateProjectRequest, userID string) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user session"})
+		return
+	}
+
+	project := &models.Project{
+		Name:        req.Name,
+		Description: req.Description,
+		Language:    req.Language,
+		Repository:  req.Repository,
+		Branch:      req.Branch,
+		CreatedBy:   userUUID,
+		Settings: models.ProjectSettings{
+			NotificationEmails:   strings.Join(req.NotificationEmails, ","),
+			NotificationWebhooks: strings.Join(req.NotificationWebhooks, ","),
+			EnabledLanguages:     strings.Join(req.EnabledLanguages, ","),
+			MinSeverity:          req.MinSeverity,
+		},
+	}
+	err = h.projectService.CreateProject(c.Request.Context(), project)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, withProjectLinks(c, toProject(project)))
+	case errors.Is(err, services.ErrBranchNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Branch not found in repository", "details": err.Error()})
+	case errors.Is(err, services.ErrRepositoryUnreachable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Repository could not be reached", "details": err.Error()})
+	case errors.Is(err, services.ErrUnsupportedRepository):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Repository must be an http, https or ssh URL", "details": err.Error()})
+	case errors.Is(err, models.ErrInvalidModel):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project", "details": err.Error()})
+	default:
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to create project")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
+	}
 }
 
-// DeleteProject deletes a project
+func (h *ProjectHandler) updateProject(c *gin.Context, projectID string, req UpdateProjectRequest) {
+	projectUUID, err := uuid.Parse(projectID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(contextkeys.UserID.GetString(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user session"})
+		return
+	}
+
+	project, err := h.projectService.UpdateProject(c.Request.Context(), projectUUID, userUUID, contextkeys.Role.GetString(c), services.ProjectUpdate{
+		Name:        req.Name,
+		Description: req.Description,
+		Language:    req.Language,
+		Repository:  req.Repository,
+		Branch:      req.Branch,
+
+		NotificationEmails:   req.NotificationEmails,
+		NotificationWebhooks: req.NotificationWebhooks,
+		EnabledLanguages:     req.EnabledLanguages,
+		MinSeverity:          req.MinSeverity,
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, withProjectLinks(c, toProject(project)))
+	case errors.Is(err, services.ErrProjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	case errors.Is(err, services.ErrProjectForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	case errors.Is(err, services.ErrBranchNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Branch not found in repository", "details": err.Error()})
+	case errors.Is(err, services.ErrRepositoryUnreachable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Repository could not be reached", "details": err.Error()})
+	case errors.Is(err, services.ErrUnsupportedRepository):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Repository must be an http, https or ssh URL", "details": err.Error()})
+	case errors.Is(err, models.ErrInvalidModel):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project", "details": err.Error()})
+	default:
+		h.logger.WithError(err).WithField("project_id", projectID).Error("Failed to update project")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+	}
+}
+
+// withProjectLinks links a project to itself, its metrics and trends, and
+// starting an analysis of it, when the client asked for links. The hrefs
+// are under the prefix the project routes are mounted at, as /api/v1.
+func withProjectLinks(c *gin.Context, project Project) Project {
+	if !utils.LinksRequested(c.Request) {
+		return project
+	}
+	prefix, _, _ := strings.Cut(c.FullPath(), "/projects")
+	base := utils.BaseURL(c.Request) + prefix
+	id := url.PathEscape(project.ID)
+	project.Links = utils.Links{
+		"self":     {Href: base + "/projects/" + id},
+		"metrics":  {Href: base + "/metrics/project/" + id},
+		"trends":   {Href: base + "/metrics/trends/" + id},
+		"analysis": {Href: base + "/analysis/start/" + id, Method: http.MethodPost},
+	}
+	return project
+}
+
+// toProject converts a stored project to its API representation
+func toProject(project *models.Project) Project {
+	return Project{
+		ID:          project.ID.String(),
+		Name:        project.Name,
+		Description: project.Description,
+		Language:    project.Language,
+		Repository:  project.Repository,
+		Branch:      project.Branch,
+		CreatedAt:   project.CreatedAt,
+		UpdatedAt:   project.UpdatedAt,
+		CreatedBy:   project.CreatedBy.String(),
+
+		NotificationEmails:   utils.SplitList(project.Settings.NotificationEmails),
+		NotificationWebhooks: utils.SplitList(project.Settings.NotificationWebhooks),
+		EnabledLanguages:     utils.SplitList(project.Settings.EnabledLanguages),
+		MinSeverity:          project.Settings.MinSeverity,
+	}
+}
+
+// DeleteProject soft-deletes a project together with its analyses,
+// visualizations and collaboration sessions
 func (h *ProjectHandler) DeleteProject(c *gin.Context) {
 	projectID := c.Param("id")
-	userID := c.GetString("user_id")
+	userID := contextkeys.UserID.GetString(c)
 
-	// TODO: Check permissions
-	// TODO: Delete from database
-	// TODO: Clean up related resources
+	if h.projectService != nil {
+		h.deleteProject(c, projectID, userID)
+		return
+	}
 
 	h.logger.WithFields(logrus.Fields{
 		"project_id": projectID,
 		"user_id":    userID,
 	}).Info("Project deleted")
 
-	c.JSON(http.StatusNoContent, nil)
-}
\ No newline at end of file
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ProjectHandler) deleteProject(c *gin.Context, projectID, userID string) {
+	projectUUID, err := uuid.Parse(projectID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user session"})
+		return
+	}
+
+	err = h.projectService.DeleteProject(projectUUID, userUUID, contextkeys.Role.GetString(c))
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, services.ErrProjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+	case errors.Is(err, services.ErrProjectForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	default:
+		h.logger.WithError(err).WithField("project_id", projectID).Error("Failed to delete project")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+	}
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// drainPollInterval is how often Shutdown checks for requests still in flight
+const drainPollInterval = 50 * time.Millisecond
+
+// Server is the part of an http.Server that Shutdown stops
+type Server interface {
+	Shutdown(ctx context.Context) error
+}
+
+// namedCloser is a dependency closed once requests have drained
+type namedCloser struct {
+	name   string
+	closer io.Closer
+}
+
+// Shutdown stops the gateway in order: it reports not ready, stops the
+// server from accepting connections, waits for in-flight requests to finish
+// and only then closes the dependencies those requests use, in the order
+// they were added.
+type Shutdown struct {
+	server         Server
+	health         *HealthHandler
+	requests       InFlightCounter
+	readinessDelay time.Duration
+	closers        []namedCloser
+	logger         *logrus.Logger
+}
+
+// NewShutdown creates a shutdown sequence for the server
+func NewShutdown(server Server, logger *logrus.Logger) *Shutdown {
+	return &Shutdown{server: server, logger: logger}
+}
+
+// SetHealthHandler sets the health handler whose readiness is flipped first
+func (s *Shutdown) SetHealthHandler(health *HealthHandler) {
+	s.health = health
+}
+
+// SetRequestCounter sets the counter of requests to wait for
+func (s *Shutdown) SetRequestCounter(counter InFlightCounter) {
+	s.requests = counter
+}
+
+// SetReadinessDelay sets how long to keep serving after reporting not ready,
+// so load balancers stop routing to the gateway before the listener closes
+func (s *Shutdown) SetReadinessDelay(delay time.Duration) {
+	s.readinessDelay = delay
+}
+
+// AddCloser adds a dependency to close after requests have drained
+func (s *Shutdown) AddCloser(name string, closer io.Closer) {
+	s.closers = append(s.closers, namedCloser{name: name, closer: closer})
+}
+
+// Run performs the shutdown. The context bounds stopping the server and
+// draining; dependencies are closed even when it expires. Errors of all
+// steps are returned together.
+func (s *Shutdown) Run(ctx context.Context) error {
+	var errs []error
+
+	if s.health != nil {
+		s.health.SetShuttingDown(true)
+		s.logger.Info("Shutdown: readiness set to not ready")
+		if s.readinessDelay > 0 {
+			select {
+			case <-time.After(s.readinessDelay):
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.logger.Errorf("Shutdown: server forced to stop: %v", err)
+		errs = append(errs, fmt.Errorf("server: %w", err))
+	} else {
+		s.logger.Info("Shutdown: server stopped accepting requests")
+	}
+
+	// http.Server.Shutdown doesn't wait for hijacked connections such as
+	// proxied WebSockets, and gives up when ctx expires, so wait on the
+	// counter before pulling dependencies out from under handlers
+	if err := s.drain(ctx); err != nil {
+		s.logger.Errorf("Shutdown: %v", err)
+		errs = append(errs, err)
+	} else {
+		s.logger.Info("Shutdown: in-flight requests drained")
+	}
+
+	for _, c := range s.closers {
+		if err := c.closer.Close(); err != nil {
+			s.logger.Errorf("Shutdown: failed to close %s: %v", c.name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			continue
+		}
+		s.logger.Infof("Shutdown: closed %s", c.name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// drain waits until no requests are in flight or ctx expires
+func (s *Shutdown) drain(ctx context.Context) error {
+	if s.requests == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		inFlight := s.requests.InFlight()
+		if inFlight <= 0 {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%d requests still in flight: %w", inFlight, ctx.Err())
+		}
+	}
+}
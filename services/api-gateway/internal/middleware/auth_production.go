@@ -2,97 +2,114 @@ package middleware
 
 import (
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/models"
 	"github.com/sa3d-modernized/sa3d/shared/services"
 )
 
 // ProductionAuth creates a production authentication middleware
 func ProductionAuth(authService *services.AuthService, logger *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Extract token from Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			logger.Warn("Missing Authorization header")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+		if !authenticate(c, authService, logger) {
 			return
 		}
+		c.Next()
+	}
+}
 
-		// Check for Bearer token format
-		const bearerPrefix = "Bearer "
-		if !strings.HasPrefix(authHeader, bearerPrefix) {
-			logger.Warn("Invalid Authorization header format")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must use Bearer token"})
-			c.Abort()
-			return
-		}
+// authenticate validates the bearer token and populates the user context.
+// It aborts the request and returns false on failure; it never calls c.Next
+// so callers can run further checks before the handler chain continues.
+func authenticate(c *gin.Context, authService *services.AuthService, logger *logrus.Logger) bool {
+	// Extract token from Authorization header
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		logger.Warn("Missing Authorization header")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+		c.Abort()
+		return false
+	}
 
-		token := strings.TrimPrefix(authHeader, bearerPrefix)
-		if token == "" {
-			logger.Warn("Empty token in Authorization header")
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is required"})
-			c.Abort()
-			return
-		}
+	// Check for Bearer token format
+	const bearerPrefix = "Bearer "
+	if !strings.HasPrefix(authHeader, bearerPrefix) {
+		logger.Warn("Invalid Authorization header format")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must use Bearer token"})
+		c.Abort()
+		return false
+	}
 
-		// Validate token and get user
-		user, err := authService.ValidateToken(token)
-		if err != nil {
-			logger.WithError(err).WithFields(logrus.Fields{
-				"token_prefix": token[:min(10, len(token))] + "...",
-				"ip_address":   c.ClientIP(),
-			}).Warn("Token validation failed")
-
-			switch err {
-			case services.ErrInvalidToken:
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			case services.ErrTokenExpired:
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
-			case services.ErrAccountNotActive:
-				c.JSON(http.StatusForbidden, gin.H{"error": "Account is not active"})
-			default:
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed"})
-			}
-			c.Abort()
-			return
+	token := strings.TrimPrefix(authHeader, bearerPrefix)
+	if token == "" {
+		logger.Warn("Empty token in Authorization header")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is required"})
+		c.Abort()
+		return false
+	}
+
+	// Validate token and get user
+	user, err := authService.ValidateToken(token)
+	if err != nil {
+		logger.WithError(err).WithFields(logrus.Fields{
+			"token_prefix": token[:min(10, len(token))] + "...",
+			"ip_address":   ClientIP(c),
+		}).Warn("Token validation failed")
+
+		switch err {
+		case services.ErrInvalidToken:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		case services.ErrTokenExpired:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
+		case services.ErrAccountNotActive:
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is not active"})
+		default:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication failed"})
 		}
+		c.Abort()
+		return false
+	}
 
-		// Set user context in Gin context
-		c.Set("user_id", user.ID.String())
-		c.Set("user", user)
-		c.Set("email", user.Email)
-		c.Set("username", user.Username)
-		c.Set("role", user.Role)
-		c.Set("session_token", token)
-
-		// Log successful authentication
-		logger.WithFields(logrus.Fields{
-			"user_id":    user.ID,
-			"email":      user.Email,
-			"role":       user.Role,
-			"ip_address": c.ClientIP(),
-		}).Debug("User authenticated successfully")
+	setUserContext(c, user, token)
 
-		c.Next()
-	}
+	// Log successful authentication
+	logger.WithFields(logrus.Fields{
+		"user_id":    user.ID,
+		"email":      user.Email,
+		"role":       user.Role,
+		"ip_address": ClientIP(c),
+	}).Debug("User authenticated successfully")
+
+	return true
+}
+
+// setUserContext stores the authenticated user on the Gin context
+func setUserContext(c *gin.Context, user *models.User, token string) {
+	contextkeys.UserID.Set(c, user.ID.String())
+	contextkeys.User.Set(c, user)
+	contextkeys.Email.Set(c, user.Email)
+	contextkeys.Username.Set(c, user.Username)
+	setRoles(c, []string{user.Role})
+	contextkeys.SessionToken.Set(c, token)
+	contextkeys.Identity.Set(c, IdentityUser)
 }
 
 // ProductionRequireRole creates middleware that requires specific user roles
 func ProductionRequireRole(authService *services.AuthService, logger *logrus.Logger, allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// First run authentication
-		ProductionAuth(authService, logger)(c)
-		if c.IsAborted() {
+		if !authenticate(c, authService, logger) {
 			return
 		}
 
 		// Check user role
-		userRole := c.GetString("role")
-		if userRole == "" {
+		userRoles := UserRoles(c)
+		if len(userRoles) == 0 {
 			logger.Error("User role not found in context")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Authorization failed"})
 			c.Abort()
@@ -102,7 +119,7 @@ func ProductionRequireRole(authService *services.AuthService, logger *logrus.Log
 		// Check if user role is allowed
 		roleAllowed := false
 		for _, allowedRole := range allowedRoles {
-			if userRole == allowedRole {
+			if slices.Contains(userRoles, allowedRole) {
 				roleAllowed = true
 				break
 			}
@@ -110,8 +127,8 @@ func ProductionRequireRole(authService *services.AuthService, logger *logrus.Log
 
 		if !roleAllowed {
 			logger.WithFields(logrus.Fields{
-				"user_id":       c.GetString("user_id"),
-				"user_role":     userRole,
+				"user_id":       contextkeys.UserID.GetString(c),
+				"user_roles":    userRoles,
 				"allowed_roles": allowedRoles,
 			}).Warn("User role not authorized for this endpoint")
 
@@ -164,12 +181,7 @@ func ProductionOptionalAuth(authService *services.AuthService, logger *logrus.Lo
 		}
 
 		// Set user context if authentication succeeded
-		c.Set("user_id", user.ID.String())
-		c.Set("user", user)
-		c.Set("email", user.Email)
-		c.Set("username", user.Username)
-		c.Set("role", user.Role)
-		c.Set("session_token", token)
+		setUserContext(c, user, token)
 
 		logger.WithField("user_id", user.ID).Debug("User optionally authenticated")
 		c.Next()
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientIP returns the address of the client that sent the request, for rate
+// limiting and security logging. Forwarding headers such as X-Forwarded-For
+// are only believed when they were added by a proxy the engine trusts (see
+// gin.Engine.SetTrustedProxies); from anyone else they are ignored, so a
+// client can't pick its own address. IPv4-mapped IPv6 addresses are unmapped
+// so one client always gets the same key.
+func ClientIP(c *gin.Context) string {
+	ip := c.ClientIP()
+	if ip == "" {
+		// Not a TCP peer address, as with some test transports
+		host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if err != nil {
+			return c.Request.RemoteAddr
+		}
+		ip = host
+	}
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		return addr.Unmap().String()
+	}
+	return ip
+}
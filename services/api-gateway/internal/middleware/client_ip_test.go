@@ -0,0 +1,81 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+)
+
+func TestClientIP_TrustedProxies(t *testing.T) {
+	newRouter := func(t *testing.T, trusted []string) *gin.Engine {
+		router := setupTestRouter()
+		require.NoError(t, router.SetTrustedProxies(trusted))
+		router.GET("/ip", func(c *gin.Context) {
+			c.String(http.StatusOK, middleware.ClientIP(c))
+		})
+		return router
+	}
+
+	tests := []struct {
+		name         string
+		trusted      []string
+		remoteAddr   string
+		forwardedFor string
+		wantClientIP string
+	}{
+		{"no proxy", []string{"10.0.0.0/8"}, "198.51.100.7:4711", "", "198.51.100.7"},
+		{"trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.5:4711", "198.51.100.7", "198.51.100.7"},
+		{"spoofed header behind trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.5:4711", "6.6.6.6, 198.51.100.7", "198.51.100.7"},
+		{"chain of trusted proxies", []string{"10.0.0.0/8"}, "10.0.0.5:4711", "198.51.100.7, 10.0.0.9", "198.51.100.7"},
+		{"spoofed header from untrusted peer", []string{"10.0.0.0/8"}, "203.0.113.9:4711", "6.6.6.6", "203.0.113.9"},
+		{"nothing trusted", nil, "10.0.0.5:4711", "198.51.100.7", "10.0.0.5"},
+		{"ipv4-mapped peer", nil, "[::ffff:198.51.100.7]:4711", "", "198.51.100.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			w := httptest.NewRecorder()
+			newRouter(t, tt.trusted).ServeHTTP(w, req)
+			assert.Equal(t, tt.wantClientIP, w.Body.String())
+		})
+	}
+}
+
+func TestIPRateLimiter_IgnoresSpoofedForwardedFor(t *testing.T) {
+	router := setupTestRouter()
+	require.NoError(t, router.SetTrustedProxies([]string{"10.0.0.0/8"}))
+	router.GET("/limited", middleware.IPRateLimiter(rate.Every(time.Hour), 1), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A direct client rotating the header is still one client
+	assert.Equal(t, http.StatusOK, request("203.0.113.9:1000", "1.1.1.1"))
+	assert.Equal(t, http.StatusTooManyRequests, request("203.0.113.9:1001", "2.2.2.2"))
+
+	// Behind the load balancer each forwarded client has its own budget
+	assert.Equal(t, http.StatusOK, request("10.0.0.5:1000", "198.51.100.1"))
+	assert.Equal(t, http.StatusOK, request("10.0.0.5:1001", "198.51.100.2"))
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.5:1002", "6.6.6.6, 198.51.100.2"))
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/semaphore"
+)
+
+// DefaultConcurrencyRetryAfter is the Retry-After sent when none is
+// configured
+const DefaultConcurrencyRetryAfter = time.Second
+
+// ConcurrencyConfig configures ConcurrencyLimit. Routes and weights are
+// keyed by full route pattern, such as /api/v1/analysis/snippet.
+type ConcurrencyConfig struct {
+	// MaxInFlight caps the weight of the requests served at once; 0
+	// removes the cap
+	MaxInFlight int64 `mapstructure:"max_in_flight"`
+	// Routes caps the weight of the requests of each listed route served
+	// at once, within MaxInFlight
+	Routes map[string]int64 `mapstructure:"routes"`
+	// Weights counts a request of a listed route as that many against the
+	// caps, for routes that hold on to more than others; others weigh 1
+	Weights map[string]int64 `mapstructure:"weights"`
+	// RetryAfter is sent in the Retry-After header of refused requests,
+	// rounded up to whole seconds
+	RetryAfter time.Duration `mapstructure:"retry_after"`
+	// ExemptPaths are never limited, along with the paths below them
+	ExemptPaths []string `mapstructure:"exempt_paths"`
+	// MaxWebSockets caps the WebSocket upgrades served at once; 0 removes
+	// the cap. A WebSocket holds its place for as long as it is open, so
+	// upgrades count against this cap only, not MaxInFlight or Routes.
+	MaxWebSockets int64 `mapstructure:"max_websockets"`
+	// WebSocketRoutes are the full route patterns that serve WebSockets;
+	// nil means DefaultWebSocketRoutes. Upgrades to other routes are
+	// ordinary requests, so a client can't skip the caps with the headers.
+	WebSocketRoutes []string `mapstructure:"websocket_routes"`
+}
+
+// DefaultConcurrencyExemptPaths keep health checks and metrics scrapes
+// answering while the gateway is saturated
+var DefaultConcurrencyExemptPaths = []string{"/health", "/metrics"}
+
+// DefaultWebSocketRoutes is the gateway's WebSocket route
+var DefaultWebSocketRoutes = []string{"/ws"}
+
+// ConcurrencyLimit middleware caps the requests in flight, globally and per
+// route, with weighted semaphores. A request that doesn't fit is answered
+// 503 Service Unavailable with a Retry-After header rather than queued, so
+// a burst of slow requests can't pile up goroutines and connections. A
+// request heavier than a cap is admitted when nothing else holds it.
+// Streamed responses, as of run-sync, hold their place until the stream
+// ends; a cap of their own in Routes keeps them from taking all of
+// MaxInFlight.
+func ConcurrencyLimit(config ConcurrencyConfig) gin.HandlerFunc {
+	retryAfter := config.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = DefaultConcurrencyRetryAfter
+	}
+	seconds := strconv.FormatInt(int64((retryAfter+time.Second-1)/time.Second), 10)
+
+	var global *capacity
+	if config.MaxInFlight > 0 {
+		global = newCapacity(config.MaxInFlight)
+	}
+	var webSockets *capacity
+	if config.MaxWebSockets > 0 {
+		webSockets = newCapacity(config.MaxWebSockets)
+	}
+	webSocketRoutes := config.WebSocketRoutes
+	if webSocketRoutes == nil {
+		webSocketRoutes = DefaultWebSocketRoutes
+	}
+	routes := make(map[string]*capacity, len(config.Routes))
+	for route, limit := range config.Routes {
+		if limit > 0 {
+			routes[route] = newCapacity(limit)
+		}
+	}
+
+	return func(c *gin.Context) {
+		if pathWithin(c.Request.URL.Path, config.ExemptPaths) {
+			c.Next()
+			return
+		}
+		if c.IsWebsocket() && slices.Contains(webSocketRoutes, c.FullPath()) {
+			if !webSockets.acquire(1) {
+				refuseInFlight(c, seconds)
+				return
+			}
+			defer webSockets.release(1)
+			c.Next()
+			return
+		}
+		weight := int64(1)
+		if w, ok := config.Weights[c.FullPath()]; ok && w > 0 {
+			weight = w
+		}
+
+		route := routes[c.FullPath()]
+		if !global.acquire(weight) {
+			refuseInFlight(c, seconds)
+			return
+		}
+		defer global.release(weight)
+		if !route.acquire(weight) {
+			refuseInFlight(c, seconds)
+			return
+		}
+		defer route.release(weight)
+
+		c.Next()
+	}
+}
+
+// refuseInFlight answers a request over an in-flight cap
+func refuseInFlight(c *gin.Context, retryAfter string) {
+	c.Header("Retry-After", retryAfter)
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+		"error": "Too many requests in flight",
+	})
+}
+
+// capacity is a weighted semaphore of requests in flight; a nil capacity
+// is unlimited
+type capacity struct {
+	sem  *semaphore.Weighted
+	size int64
+}
+
+func newCapacity(size int64) *capacity {
+	return &capacity{sem: semaphore.NewWeighted(size), size: size}
+}
+
+// acquire takes weight without waiting, reporting whether it was free.
+// Weights over the size take all of it.
+func (c *capacity) acquire(weight int64) bool {
+	if c == nil {
+		return true
+	}
+	return c.sem.TryAcquire(c.clamp(weight))
+}
+
+// release returns weight taken by acquire
+func (c *capacity) release(weight int64) {
+	if c != nil {
+		c.sem.Release(c.clamp(weight))
+	}
+}
+
+// clamp limits weight to the size
+func (c *capacity) clamp(weight int64) int64 {
+	if weight > c.size {
+		return c.size
+	}
+	return weight
+}
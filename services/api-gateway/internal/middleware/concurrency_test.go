@@ -0,0 +1,156 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	router := setupTestRouter()
+	router.Use(middleware.ConcurrencyLimit(middleware.ConcurrencyConfig{
+		MaxInFlight: 2,
+		Routes:      map[string]int64{"/slow/:id": 1},
+		Weights:     map[string]int64{"/heavy": 2},
+		RetryAfter:  1500 * time.Millisecond,
+		ExemptPaths: []string{"/health"},
+	}))
+	entered := make(chan struct{})
+	var release chan struct{}
+	handle := func(c *gin.Context) {
+		if c.Query("block") != "" {
+			entered <- struct{}{}
+			<-release
+		}
+		c.Status(http.StatusOK)
+	}
+	for _, path := range []string{"/fast", "/slow/:id", "/heavy", "/health"} {
+		router.GET(path, handle)
+	}
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	// hold serves blocking requests to paths until the returned function
+	// lets them finish
+	hold := func(paths ...string) func() {
+		release = make(chan struct{})
+		var wg sync.WaitGroup
+		for _, path := range paths {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.Equal(t, http.StatusOK, request(path+"?block=1").Code, path)
+			}()
+			<-entered
+		}
+		return func() {
+			close(release)
+			wg.Wait()
+		}
+	}
+
+	t.Run("global cap", func(t *testing.T) {
+		done := hold("/fast", "/slow/1")
+		w := request("/fast")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "2", w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), "Too many requests in flight")
+		assert.Equal(t, http.StatusOK, request("/health").Code, "exempt paths aren't limited")
+		done()
+
+		assert.Equal(t, http.StatusOK, request("/fast").Code, "finished requests free their place")
+	})
+
+	t.Run("route cap", func(t *testing.T) {
+		done := hold("/slow/1")
+		assert.Equal(t, http.StatusServiceUnavailable, request("/slow/2").Code)
+		assert.Equal(t, http.StatusOK, request("/fast").Code, "other routes share the rest of the global cap")
+		done()
+		assert.Equal(t, http.StatusOK, request("/slow/2").Code)
+	})
+
+	t.Run("weights", func(t *testing.T) {
+		done := hold("/heavy")
+		assert.Equal(t, http.StatusServiceUnavailable, request("/fast").Code, "a heavy request takes the whole cap")
+		done()
+		assert.Equal(t, http.StatusOK, request("/fast").Code)
+	})
+}
+
+func TestConcurrencyLimit_WebSockets(t *testing.T) {
+	router := setupTestRouter()
+	router.Use(middleware.ConcurrencyLimit(middleware.ConcurrencyConfig{MaxInFlight: 1, MaxWebSockets: 2}))
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	router.GET("/ws", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusSwitchingProtocols)
+	})
+	router.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	upgrade := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, http.StatusSwitchingProtocols, upgrade().Code)
+		}()
+		<-entered
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "open WebSockets don't take the in-flight cap")
+	assert.Equal(t, http.StatusServiceUnavailable, upgrade().Code, "WebSockets have a cap of their own")
+
+	close(release)
+	wg.Wait()
+}
+
+func TestConcurrencyLimit_SpoofedUpgrade(t *testing.T) {
+	router := setupTestRouter()
+	router.Use(middleware.ConcurrencyLimit(middleware.ConcurrencyConfig{MaxInFlight: 1}))
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	router.GET("/slow", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		done <- w.Code
+	}()
+	<-entered
+
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "an upgrade to an ordinary route counts against MaxInFlight")
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+}
@@ -0,0 +1,67 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+)
+
+func TestRejectAmbiguousHeaders(t *testing.T) {
+	router := setupTestRouter()
+	router.Use(middleware.RejectAmbiguousHeaders())
+	router.POST("/api/v1/analysis/start/:projectId", func(c *gin.Context) { c.Status(http.StatusAccepted) })
+
+	tests := []struct {
+		name             string
+		header           http.Header
+		transferEncoding []string
+		wantReason       string
+	}{
+		{"plain request", http.Header{"Content-Length": {"2"}}, nil, ""},
+		{"chunked request", nil, []string{"chunked"}, ""},
+		{"forwarded request", http.Header{"X-Forwarded-For": {"203.0.113.7, 10.0.0.1", "10.0.0.2"}, "X-Forwarded-Proto": {"https"}}, nil, ""},
+		{"forwarded for with ports", http.Header{"X-Forwarded-For": {"203.0.113.7:5678, [2001:db8::1]:80, ::1"}}, nil, ""},
+		{"duplicate content length", http.Header{"Content-Length": {"2", "2"}}, nil, "duplicate Content-Length"},
+		{"content length list", http.Header{"Content-Length": {"2, 2"}}, nil, "invalid Content-Length"},
+		{"signed content length", http.Header{"Content-Length": {"+2"}}, nil, "invalid Content-Length"},
+		{"unknown transfer encoding", http.Header{"Transfer-Encoding": {"gzip"}}, nil, "malformed Transfer-Encoding"},
+		{"obfuscated transfer encoding", http.Header{"Transfer-Encoding": {"chunked, identity"}}, nil, "malformed Transfer-Encoding"},
+		{"duplicate transfer encoding", http.Header{"Transfer-Encoding": {"chunked"}}, []string{"chunked"}, "malformed Transfer-Encoding"},
+		{"transfer encoding with content length", http.Header{"Content-Length": {"2"}}, []string{"chunked"}, "both Transfer-Encoding and Content-Length"},
+		{"duplicate forwarded host", http.Header{"X-Forwarded-Host": {"gateway.example.com", "internal"}}, nil, "conflicting X-Forwarded-Host"},
+		{"forwarded proto list", http.Header{"X-Forwarded-Proto": {"https,http"}}, nil, "conflicting X-Forwarded-Proto"},
+		{"duplicate real ip", http.Header{"X-Real-Ip": {"203.0.113.7", "10.0.0.1"}}, nil, "conflicting X-Real-IP"},
+		{"malformed forwarded for", http.Header{"X-Forwarded-For": {"203.0.113.7, evil.example.com"}}, nil, "malformed X-Forwarded-For"},
+		{"forwarded for with bad port", http.Header{"X-Forwarded-For": {"203.0.113.7:http"}}, nil, "malformed X-Forwarded-For"},
+		{"unbracketed ipv6 with port", http.Header{"X-Forwarded-For": {"2001:db8::1:80:x"}}, nil, "malformed X-Forwarded-For"},
+		{"empty forwarded for entry", http.Header{"X-Forwarded-For": {"203.0.113.7,,10.0.0.1"}}, nil, "malformed X-Forwarded-For"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/analysis/start/p1", strings.NewReader("{}"))
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			req.TransferEncoding = tt.transferEncoding
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if tt.wantReason == "" {
+				assert.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+				return
+			}
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantReason, body["reason"])
+		})
+	}
+}
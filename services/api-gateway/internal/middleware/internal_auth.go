@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+)
+
+// InternalTokenHeader carries the token backends authenticate to internal
+// endpoints with
+const InternalTokenHeader = "X-Internal-Token"
+
+// InternalAuth middleware admits requests carrying the internal token, for
+// endpoints meant for backends rather than users. With no token
+// configured the endpoints are disabled and every request is refused.
+func InternalAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Internal endpoints are disabled"})
+			return
+		}
+		if !internalTokenValid(c.GetHeader(InternalTokenHeader), token) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid internal token"})
+			return
+		}
+		contextkeys.Identity.Set(c, IdentityAPIKey)
+		setRoles(c, []string{RoleInternal})
+		c.Next()
+	}
+}
+
+// UserOrInternalAuth middleware admits requests carrying the internal token
+// as InternalAuth does, and authenticates any other as ProductionAuth does,
+// for endpoints both backends and users call
+func UserOrInternalAuth(authService *services.AuthService, internalToken string, logger *logrus.Logger) gin.HandlerFunc {
+	internal := InternalAuth(internalToken)
+	return func(c *gin.Context) {
+		if c.GetHeader(InternalTokenHeader) != "" {
+			internal(c)
+			return
+		}
+		if !authenticate(c, authService, logger) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// internalTokenValid reports whether the presented token is the configured
+// internal token; with none configured no token is valid
+func internalTokenValid(presented, token string) bool {
+	return token != "" && presented != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
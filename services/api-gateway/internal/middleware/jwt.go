@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+)
+
+// JWTSigningMethod is the method gateway tokens are signed with, the one
+// the auth service signs access tokens with
+var JWTSigningMethod = services.AccessTokenSigningMethod
+
+// ErrUnexpectedSigningMethod is returned for a token signed with an
+// algorithm other than those allowed, "none" included
+var ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
+
+// ErrTokenModelMismatch is returned by CheckTokenModel when Auth would
+// reject the tokens ProductionAuth accepts
+var ErrTokenModelMismatch = errors.New("auth service tokens are rejected by the JWT middleware")
+
+// allowedJWTAlgorithms are the only "alg" values tokens are accepted with.
+// Listing exact algorithms rather than families keeps a token claiming
+// another algorithm, such as RS256 with the HMAC secret as its public key,
+// from being checked against a key meant for something else.
+var allowedJWTAlgorithms = []string{JWTSigningMethod.Alg()}
+
+// ParseJWT parses and validates a token signed with secret, rejecting any
+// whose algorithm isn't allowed before its signature is checked
+func ParseJWT(tokenString, secret string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		alg, _ := token.Header["alg"].(string)
+		if !slices.Contains(allowedJWTAlgorithms, alg) || token.Method.Alg() != alg {
+			return nil, fmt.Errorf("%w: %q", ErrUnexpectedSigningMethod, token.Header["alg"])
+		}
+		return []byte(secret), nil
+	})
+}
+
+// CheckTokenModel makes sure Auth(jwtSecret) accepts the tokens the auth
+// service issues, which ProductionAuth accepts, with the same identity. It
+// has the service sign a short-lived token for a user that doesn't exist
+// and parses it as Auth would, so a secret set in one place but not the
+// other fails at startup rather than as 401s on some routes.
+func CheckTokenModel(authService *services.AuthService, jwtSecret string) error {
+	probe := &models.User{BaseModel: models.BaseModel{ID: uuid.New()}, Email: "token-model-check@sa3d.invalid", Role: "user"}
+	token, err := authService.SignAccessToken(probe, time.Now().Add(time.Minute))
+	if err != nil {
+		return fmt.Errorf("failed to sign a token to check: %w", err)
+	}
+
+	parsed, err := ParseJWT(token, jwtSecret)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTokenModelMismatch, err)
+	}
+	claims, _ := parsed.Claims.(jwt.MapClaims)
+	if userID, _ := claims["user_id"].(string); userID != probe.ID.String() {
+		return fmt.Errorf("%w: the user_id claim is %q, not %q", ErrTokenModelMismatch, claims["user_id"], probe.ID)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package middleware_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+)
+
+func TestAuth_SigningAlgorithms(t *testing.T) {
+	const jwtSecret = "test-secret"
+	router := setupTestRouter()
+	router.GET("/protected", middleware.Auth(jwtSecret), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": contextkeys.UserID.GetString(c)})
+	})
+
+	claims := jwt.MapClaims{"user_id": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+	sign := func(method jwt.SigningMethod, key interface{}) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+		return token
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	// Signed with the HMAC secret but claiming RS256, as when the secret is
+	// mistaken for an RSA public key
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	confused.Header["alg"] = "RS256"
+	confusedToken, err := confused.SignedString([]byte(jwtSecret))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		wantError  string
+	}{
+		{"HS256", sign(jwt.SigningMethodHS256, []byte(jwtSecret)), http.StatusOK, ""},
+		{"none", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType), http.StatusUnauthorized, "Unsupported token signing algorithm"},
+		{"another HMAC algorithm", sign(jwt.SigningMethodHS512, []byte(jwtSecret)), http.StatusUnauthorized, "Unsupported token signing algorithm"},
+		{"RS256", sign(jwt.SigningMethodRS256, rsaKey), http.StatusUnauthorized, "Unsupported token signing algorithm"},
+		{"RS256 header over an HMAC signature", confusedToken, http.StatusUnauthorized, "Unsupported token signing algorithm"},
+		{"wrong secret", sign(jwt.SigningMethodHS256, []byte("other-secret")), http.StatusUnauthorized, "Invalid token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantError != "" {
+				var response map[string]string
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tt.wantError, response["error"])
+			}
+		})
+	}
+
+	t.Run("ParseJWT reports the algorithm", func(t *testing.T) {
+		_, err := middleware.ParseJWT(sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType), jwtSecret)
+		assert.ErrorIs(t, err, middleware.ErrUnexpectedSigningMethod)
+		assert.ErrorContains(t, err, `"none"`)
+	})
+}
+
+func TestCheckTokenModel(t *testing.T) {
+	const jwtSecret = "test-secret-of-at-least-thirty-two"
+	logger := testutil.NewTestLogger()
+	authService := services.NewAuthService(services.NewDatabaseServiceFromDB(testutil.NewTestDB(t), nil, logger), logger)
+
+	assert.ErrorIs(t, middleware.CheckTokenModel(authService, jwtSecret), middleware.ErrTokenModelMismatch,
+		"an auth service without the secret signs with its own")
+
+	authService.SetTokenSecret("another-secret-of-at-least-thirty")
+	assert.ErrorIs(t, middleware.CheckTokenModel(authService, jwtSecret), middleware.ErrTokenModelMismatch)
+
+	authService.SetTokenSecret(jwtSecret)
+	assert.NoError(t, middleware.CheckTokenModel(authService, jwtSecret))
+}
+
+func TestTokenModel_BothAuthPaths(t *testing.T) {
+	const (
+		jwtSecret = "test-secret-of-at-least-thirty-two"
+		password  = "Str0ng!Passw0rd"
+	)
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t, &models.User{}, &models.UserSession{})
+	authService := services.NewAuthService(services.NewDatabaseServiceFromDB(db, nil, logger), logger)
+	authService.SetTokenSecret(jwtSecret)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &models.User{Email: "both@example.com", Username: "both", Password: string(hash), Role: "admin", IsActive: true}
+	require.NoError(t, db.Create(user).Error)
+	result, err := authService.Login(services.UserLogin{Email: user.Email, Password: password})
+	require.NoError(t, err)
+
+	router := setupTestRouter()
+	identity := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"user_id": contextkeys.UserID.GetString(c),
+			"email":   contextkeys.Email.GetString(c),
+			"roles":   middleware.UserRoles(c),
+		})
+	}
+	router.GET("/jwt", middleware.Auth(jwtSecret), identity)
+	router.GET("/session", middleware.ProductionAuth(authService, logger), identity)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("accepted by both with the same identity", func(t *testing.T) {
+		viaJWT, viaSession := get("/jwt", result.AccessToken), get("/session", result.AccessToken)
+		require.Equal(t, http.StatusOK, viaJWT.Code, viaJWT.Body.String())
+		require.Equal(t, http.StatusOK, viaSession.Code, viaSession.Body.String())
+		assert.JSONEq(t, viaSession.Body.String(), viaJWT.Body.String())
+		assert.Contains(t, viaJWT.Body.String(), user.ID.String())
+	})
+
+	t.Run("rejected by both once the secret changes", func(t *testing.T) {
+		authService.SetTokenSecret("another-secret-of-at-least-thirty")
+		defer authService.SetTokenSecret(jwtSecret)
+		token, err := authService.SignAccessToken(user, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, get("/jwt", token).Code)
+		assert.Equal(t, http.StatusUnauthorized, get("/session", result.AccessToken).Code)
+	})
+}
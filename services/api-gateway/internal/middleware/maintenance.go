@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Maintenance scopes, the requests refused while maintenance is on
+const (
+	// MaintenanceWrites refuses requests with mutating methods, leaving the
+	// gateway read-only
+	MaintenanceWrites = "writes"
+	// MaintenanceAll refuses every request
+	MaintenanceAll = "all"
+)
+
+// DefaultMaintenanceRetryAfter is the Retry-After sent when none is configured
+const DefaultMaintenanceRetryAfter = 5 * time.Minute
+
+// MaintenanceConfig configures Maintenance
+type MaintenanceConfig struct {
+	// Scope is MaintenanceWrites or MaintenanceAll; empty is
+	// MaintenanceWrites
+	Scope string `mapstructure:"scope"`
+	// RetryAfter is sent in the Retry-After header of refused requests,
+	// rounded up to whole seconds
+	RetryAfter time.Duration `mapstructure:"retry_after"`
+	// AllowedPaths are served throughout maintenance, along with the paths
+	// below them
+	AllowedPaths []string `mapstructure:"allowed_paths"`
+}
+
+// DefaultMaintenanceAllowedPaths keep health checks and metrics scrapes
+// working during maintenance
+var DefaultMaintenanceAllowedPaths = []string{"/health", "/metrics"}
+
+// Maintenance middleware answers 503 Service Unavailable with a
+// Retry-After header to the requests within the configured scope while
+// enabled reports true, which it is asked for every request. GET, HEAD
+// and OPTIONS requests are not mutating, and the allowed paths are always
+// served.
+func Maintenance(enabled func() bool, config MaintenanceConfig) gin.HandlerFunc {
+	retryAfter := config.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = DefaultMaintenanceRetryAfter
+	}
+	seconds := strconv.FormatInt(int64((retryAfter+time.Second-1)/time.Second), 10)
+
+	return func(c *gin.Context) {
+		if !enabled() || maintenanceAllowed(c.Request, config) {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", seconds)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Service under maintenance",
+		})
+	}
+}
+
+// maintenanceAllowed reports whether r is served during maintenance
+func maintenanceAllowed(r *http.Request, config MaintenanceConfig) bool {
+	if pathWithin(r.URL.Path, config.AllowedPaths) {
+		return true
+	}
+	if config.Scope == MaintenanceAll {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// pathWithin reports whether path is one of paths or below one of them
+func pathWithin(path string, paths []string) bool {
+	for _, prefix := range paths {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
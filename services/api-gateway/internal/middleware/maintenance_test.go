@@ -0,0 +1,67 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+)
+
+func TestMaintenance(t *testing.T) {
+	features := services.NewFeatureFlags(nil)
+	newRouter := func(config middleware.MaintenanceConfig) *gin.Engine {
+		router := setupTestRouter()
+		router.Use(middleware.Maintenance(features.Maintenance, config))
+		ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+		router.GET("/health", ok)
+		router.GET("/health/ready", ok)
+		router.GET("/api/v1/projects", ok)
+		router.POST("/api/v1/projects", ok)
+		router.POST("/health/ready", ok)
+		return router
+	}
+	request := func(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+	allowed := []string{"/health"}
+
+	t.Run("off", func(t *testing.T) {
+		router := newRouter(middleware.MaintenanceConfig{AllowedPaths: allowed})
+		assert.Equal(t, http.StatusOK, request(router, http.MethodPost, "/api/v1/projects").Code)
+	})
+
+	features.SetOverrides(map[string]bool{services.FlagMaintenance: true})
+	defer features.SetOverrides(nil)
+
+	t.Run("writes", func(t *testing.T) {
+		router := newRouter(middleware.MaintenanceConfig{Scope: middleware.MaintenanceWrites, RetryAfter: 90 * time.Second, AllowedPaths: allowed})
+
+		w := request(router, http.MethodPost, "/api/v1/projects")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "90", w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), "Service under maintenance")
+
+		assert.Equal(t, http.StatusOK, request(router, http.MethodGet, "/api/v1/projects").Code)
+		assert.Equal(t, http.StatusOK, request(router, http.MethodGet, "/health").Code)
+		assert.Equal(t, http.StatusOK, request(router, http.MethodPost, "/health/ready").Code, "paths below an allowed one are served")
+	})
+
+	t.Run("all", func(t *testing.T) {
+		router := newRouter(middleware.MaintenanceConfig{Scope: middleware.MaintenanceAll, AllowedPaths: allowed})
+
+		w := request(router, http.MethodGet, "/api/v1/projects")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "300", w.Header().Get("Retry-After"), "the default Retry-After")
+		assert.Equal(t, http.StatusServiceUnavailable, request(router, http.MethodPost, "/api/v1/projects").Code)
+		assert.Equal(t, http.StatusOK, request(router, http.MethodGet, "/health").Code)
+		assert.Equal(t, http.StatusOK, request(router, http.MethodGet, "/health/ready").Code)
+	})
+}
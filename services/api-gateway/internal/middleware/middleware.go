@@ -2,18 +2,28 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/time/rate"
+
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
 )
 
 // Logger middleware for request logging
@@ -28,13 +38,13 @@ func Logger(logger *logrus.Logger) gin.HandlerFunc {
 
 		// Log request details
 		latency := time.Since(start)
-		clientIP := c.ClientIP()
+		clientIP := ClientIP(c)
 		method := c.Request.Method
 		statusCode := c.Writer.Status()
-		errorMessage := c.Errors.ByType(gin.ErrorTypePrivate).String()
+		errorMessage := utils.MaskString(c.Errors.ByType(gin.ErrorTypePrivate).String())
 
 		if raw != "" {
-			path = path + "?" + raw
+			path = path + "?" + utils.MaskString(raw)
 		}
 
 		entry := logger.WithFields(logrus.Fields{
@@ -43,8 +53,8 @@ func Logger(logger *logrus.Logger) gin.HandlerFunc {
 			"client_ip":    clientIP,
 			"method":       method,
 			"path":         path,
-			"request_id":   c.GetString("request_id"),
-			"user_id":      c.GetString("user_id"),
+			"request_id":   contextkeys.RequestID.GetString(c),
+			"user_id":      contextkeys.UserID.GetString(c),
 		})
 
 		if errorMessage != "" {
@@ -59,26 +69,154 @@ func Logger(logger *logrus.Logger) gin.HandlerFunc {
 	}
 }
 
-// RequestID middleware adds a unique request ID to each request
+// RequestIDConfig configures how RequestID reads and echoes request IDs
+type RequestIDConfig struct {
+	// Header is the header the ID is read from and echoed in; it defaults
+	// to utils.RequestIDHeader
+	Header string `mapstructure:"header"`
+	// FromTraceContext uses the trace ID of an incoming traceparent header
+	// when the request carries no ID, so logs and traces share one identifier
+	FromTraceContext bool `mapstructure:"from_trace_context"`
+}
+
+// RequestID middleware adds a unique request ID to each request, exposing it
+// in the gin context, the request context and the response header
 func RequestID() gin.HandlerFunc {
+	return RequestIDWithConfig(RequestIDConfig{})
+}
+
+// RequestIDWithConfig is RequestID with a configurable header name and
+// trace-derived IDs. Backends always receive the ID in utils.RequestIDHeader.
+func RequestIDWithConfig(config RequestIDConfig) gin.HandlerFunc {
+	header := config.Header
+	if header == "" {
+		header = utils.RequestIDHeader
+	}
 	return func(c *gin.Context) {
-		requestID := c.GetHeader("X-Request-ID")
+		requestID := c.GetHeader(header)
+		if requestID == "" && config.FromTraceContext {
+			requestID, _ = utils.TraceIDFromTraceparent(c.GetHeader(utils.TraceparentHeader))
+		}
 		if requestID == "" {
 			requestID = uuid.New().String()
 		}
-		c.Set("request_id", requestID)
-		c.Header("X-Request-ID", requestID)
+		contextkeys.RequestID.Set(c, requestID)
+		c.Request = c.Request.WithContext(utils.WithRequestID(c.Request.Context(), requestID))
+		c.Header(header, requestID)
 		c.Next()
 	}
 }
 
-// CORS middleware for Cross-Origin Resource Sharing
-func CORS(config struct {
+// CORSConfig is a set of Cross-Origin Resource Sharing rules
+type CORSConfig struct {
 	AllowedOrigins []string `mapstructure:"allowed_origins"`
 	AllowedMethods []string `mapstructure:"allowed_methods"`
 	AllowedHeaders []string `mapstructure:"allowed_headers"`
 	MaxAge         int      `mapstructure:"max_age"`
-}) gin.HandlerFunc {
+}
+
+// withDefaults fills the unset fields of config from defaults
+func (config CORSConfig) withDefaults(defaults CORSConfig) CORSConfig {
+	if len(config.AllowedOrigins) == 0 {
+		config.AllowedOrigins = defaults.AllowedOrigins
+	}
+	if len(config.AllowedMethods) == 0 {
+		config.AllowedMethods = defaults.AllowedMethods
+	}
+	if len(config.AllowedHeaders) == 0 {
+		config.AllowedHeaders = defaults.AllowedHeaders
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = defaults.MaxAge
+	}
+	return config
+}
+
+// CORSPolicy chooses the CORS rules of a request by its route group. The
+// middleware runs for every request, preflights to routes that only
+// answer other methods included, and applies the rules of the group with
+// the longest prefix of the path, or the global rules outside any group.
+// Rules may change while requests are served; each request sees either
+// the old or the new rules.
+type CORSPolicy struct {
+	mu     sync.Mutex
+	global CORSConfig
+	groups map[string]CORSConfig
+	rules  atomic.Pointer[corsRules]
+}
+
+// corsRules is the middleware of the global rules and of each group
+type corsRules struct {
+	handler gin.HandlerFunc
+	groups  []corsGroup
+}
+
+// corsGroup applies CORS rules to the routes under prefix
+type corsGroup struct {
+	prefix  string
+	handler gin.HandlerFunc
+}
+
+// NewCORSPolicy creates a policy applying global to every route until
+// groups are set
+func NewCORSPolicy(global CORSConfig) *CORSPolicy {
+	p := &CORSPolicy{global: global, groups: make(map[string]CORSConfig)}
+	p.rebuild()
+	return p
+}
+
+// SetGroup applies config to the routes under prefix, taking unset fields
+// from the global rules
+func (p *CORSPolicy) SetGroup(prefix string, config CORSConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.groups[strings.TrimSuffix(prefix, "/")] = config
+	p.rebuild()
+}
+
+// Update replaces the global rules and the rules of every group, keyed by
+// prefix, at once
+func (p *CORSPolicy) Update(global CORSConfig, groups map[string]CORSConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.global = global
+	p.groups = make(map[string]CORSConfig, len(groups))
+	for prefix, config := range groups {
+		p.groups[strings.TrimSuffix(prefix, "/")] = config
+	}
+	p.rebuild()
+}
+
+// rebuild publishes the middleware for the current rules; p.mu is held
+func (p *CORSPolicy) rebuild() {
+	rules := &corsRules{handler: CORS(p.global)}
+	for prefix, config := range p.groups {
+		rules.groups = append(rules.groups, corsGroup{prefix: prefix, handler: CORS(config.withDefaults(p.global))})
+	}
+	slices.SortFunc(rules.groups, func(a, b corsGroup) int { return len(b.prefix) - len(a.prefix) })
+	p.rules.Store(rules)
+}
+
+// groupHandler returns the CORS middleware for a request path
+func (p *CORSPolicy) groupHandler(path string) gin.HandlerFunc {
+	rules := p.rules.Load()
+	for _, group := range rules.groups {
+		if path == group.prefix || strings.HasPrefix(path, group.prefix+"/") {
+			return group.handler
+		}
+	}
+	return rules.handler
+}
+
+// Middleware applies the policy to each request
+func (p *CORSPolicy) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p.groupHandler(c.Request.URL.Path)(c)
+	}
+}
+
+// CORS middleware for Cross-Origin Resource Sharing
+func CORS(config CORSConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
 		
@@ -109,9 +247,19 @@ func CORS(config struct {
 	}
 }
 
-// RateLimiter middleware for rate limiting
-func RateLimiter(limiter *rate.Limiter) gin.HandlerFunc {
+// DefaultRateLimitExemptPaths keep health checks and metrics scrapes from
+// using up the API's rate limit
+var DefaultRateLimitExemptPaths = []string{"/health", "/metrics"}
+
+// RateLimiter middleware for rate limiting. Requests to exemptPaths, or
+// below them, and backend requests carrying internalToken are neither
+// limited nor counted against the limit.
+func RateLimiter(limiter *rate.Limiter, internalToken string, exemptPaths ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if pathWithin(c.Request.URL.Path, exemptPaths) || internalTokenValid(c.GetHeader(InternalTokenHeader), internalToken) {
+			c.Next()
+			return
+		}
 		if !limiter.Allow() {
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error": "Too many requests",
@@ -123,6 +271,182 @@ func RateLimiter(limiter *rate.Limiter) gin.HandlerFunc {
 	}
 }
 
+// RequestCounter tracks how many requests the gateway is currently serving
+type RequestCounter struct {
+	inFlight atomic.Int64
+}
+
+// NewRequestCounter creates a request counter
+func NewRequestCounter() *RequestCounter {
+	return &RequestCounter{}
+}
+
+// Middleware counts each request while it is being handled
+func (rc *RequestCounter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rc.inFlight.Add(1)
+		defer rc.inFlight.Add(-1)
+		c.Next()
+	}
+}
+
+// InFlight returns the number of requests currently being handled
+func (rc *RequestCounter) InFlight() int64 {
+	return rc.inFlight.Load()
+}
+
+// ipLimiterTTL is how long an idle client's limiter is kept before pruning
+const ipLimiterTTL = 10 * time.Minute
+
+type ipLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// IPRateLimit keeps one token bucket per client IP
+type IPRateLimit struct {
+	mu        sync.Mutex
+	limit     rate.Limit
+	burst     int
+	entries   map[string]*ipLimiterEntry
+	lastPrune time.Time
+}
+
+// NewIPRateLimit creates a limit of limit requests per second with bursts
+// of burst for each client IP
+func NewIPRateLimit(limit rate.Limit, burst int) *IPRateLimit {
+	return &IPRateLimit{
+		limit:     limit,
+		burst:     burst,
+		entries:   make(map[string]*ipLimiterEntry),
+		lastPrune: time.Now(),
+	}
+}
+
+// SetLimit changes the rate and burst of every client, those already seen
+// included
+func (l *IPRateLimit) SetLimit(limit rate.Limit, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.burst = burst
+	for _, entry := range l.entries {
+		entry.limiter.SetLimit(limit)
+		entry.limiter.SetBurst(burst)
+	}
+}
+
+// Limit returns the rate and burst of each client
+func (l *IPRateLimit) Limit() (rate.Limit, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit, l.burst
+}
+
+func (l *IPRateLimit) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) > ipLimiterTTL {
+		for key, entry := range l.entries {
+			if now.Sub(entry.lastSeen) > ipLimiterTTL {
+				delete(l.entries, key)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	entry, ok := l.entries[ip]
+	if !ok {
+		entry = &ipLimiterEntry{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.entries[ip] = entry
+	}
+	entry.lastSeen = now
+
+	return entry.limiter.Allow()
+}
+
+// IPRateLimiter middleware limits requests per client IP
+func IPRateLimiter(limit rate.Limit, burst int) gin.HandlerFunc {
+	return NewIPRateLimit(limit, burst).Middleware()
+}
+
+// Middleware rejects requests of clients over their limit
+func (l *IPRateLimit) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.allow(ClientIP(c)) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Too many requests",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// MaxBodySize middleware rejects request bodies larger than maxBytes. A
+// declared length over the limit is refused up front; otherwise reading
+// past the limit fails with *http.MaxBytesError. Zero disables the limit.
+func MaxBodySize(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("Request body exceeds %d bytes", maxBytes),
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
+
+// PathLimitConfig bounds the request paths the gateway accepts, so deeply
+// nested or very long paths are refused before they reach a backend
+type PathLimitConfig struct {
+	// MaxLength is the longest escaped path accepted, in bytes
+	MaxLength int `mapstructure:"max_length"`
+	// MaxSegments is the most slash-separated segments a decoded path may
+	// have; encoded slashes count as separators
+	MaxSegments int `mapstructure:"max_segments"`
+}
+
+// PathLimits middleware rejects requests whose path exceeds either limit
+// with 414 URI Too Long. A zero limit is not enforced.
+func PathLimits(config PathLimitConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if config.MaxLength > 0 && len(c.Request.URL.EscapedPath()) > config.MaxLength {
+			c.AbortWithStatusJSON(http.StatusRequestURITooLong, gin.H{
+				"error": fmt.Sprintf("Request path exceeds %d bytes", config.MaxLength),
+			})
+			return
+		}
+		if config.MaxSegments > 0 && pathSegments(c.Request.URL.Path) > config.MaxSegments {
+			c.AbortWithStatusJSON(http.StatusRequestURITooLong, gin.H{
+				"error": fmt.Sprintf("Request path exceeds %d segments", config.MaxSegments),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// pathSegments counts the segments of a path, empty ones included, ignoring
+// a leading and a trailing slash
+func pathSegments(path string) int {
+	path = strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/")
+	if path == "" {
+		return 0
+	}
+	return strings.Count(path, "/") + 1
+}
+
 // Auth middleware for JWT authentication
 func Auth(jwtSecret string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -150,14 +474,14 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 		}
 
 		// Parse and validate token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(jwtSecret), nil
-		})
-
+		token, err := ParseJWT(tokenString, jwtSecret)
+		if errors.Is(err, ErrUnexpectedSigningMethod) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unsupported token signing algorithm",
+			})
+			c.Abort()
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid token",
@@ -168,15 +492,22 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 
 		// Extract claims
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+			contextkeys.Identity.Set(c, IdentityUser)
 			// Set user information in context
 			if userID, ok := claims["user_id"].(string); ok {
-				c.Set("user_id", userID)
+				contextkeys.UserID.Set(c, userID)
 			}
 			if email, ok := claims["email"].(string); ok {
-				c.Set("email", email)
+				contextkeys.Email.Set(c, email)
 			}
-			if roles, ok := claims["roles"].([]interface{}); ok {
-				c.Set("roles", roles)
+			if claimed, ok := claims["roles"].([]interface{}); ok {
+				var roles []string
+				for _, role := range claimed {
+					if roleStr, ok := role.(string); ok {
+						roles = append(roles, roleStr)
+					}
+				}
+				setRoles(c, roles)
 			}
 		} else {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -194,7 +525,7 @@ func Auth(jwtSecret string) gin.HandlerFunc {
 func Tracing(tracer trace.Tracer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Extract trace context from headers
-		ctx := c.Request.Context()
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
 		
 		// Start a new span
 		spanName := fmt.Sprintf("%s %s", c.Request.Method, c.FullPath())
@@ -209,7 +540,7 @@ func Tracing(tracer trace.Tracer) gin.HandlerFunc {
 			attribute.String("http.host", c.Request.Host),
 			attribute.String("http.scheme", c.Request.URL.Scheme),
 			attribute.String("http.user_agent", c.Request.UserAgent()),
-			attribute.String("request_id", c.GetString("request_id")),
+			attribute.String("request_id", contextkeys.RequestID.GetString(c)),
 		)
 
 		// Update request context
@@ -231,11 +562,83 @@ func Tracing(tracer trace.Tracer) gin.HandlerFunc {
 	}
 }
 
+// RequestMetrics middleware records the count and latency of requests as
+// OpenTelemetry metrics, by method, route and status code. Requests matching
+// no route share the "unmatched" route so unknown paths can't grow the
+// number of series.
+func RequestMetrics(meter metric.Meter) (gin.HandlerFunc, error) {
+	requests, err := meter.Int64Counter("http.server.requests",
+		metric.WithDescription("Number of HTTP requests handled"))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("Duration of HTTP requests"),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		attrs := metric.WithAttributes(
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", c.Writer.Status()),
+		)
+		ctx := c.Request.Context()
+		requests.Add(ctx, 1, attrs)
+		duration.Record(ctx, time.Since(start).Seconds(), attrs)
+	}, nil
+}
+
+// rolePrecedence orders the assignable roles from most to least privileged
+var rolePrecedence = []string{"super_admin", "admin", "user"}
+
+// setRoles records the authenticated user's roles, and the most privileged
+// of them as their role, so role checks work whichever auth middleware ran
+func setRoles(c *gin.Context, roles []string) {
+	contextkeys.Roles.Set(c, roles)
+	if role := primaryRole(roles); role != "" {
+		contextkeys.Role.Set(c, role)
+	}
+}
+
+// primaryRole returns the most privileged of roles, or the first when none
+// of them is an assignable role
+func primaryRole(roles []string) string {
+	for _, role := range rolePrecedence {
+		if slices.Contains(roles, role) {
+			return role
+		}
+	}
+	if len(roles) == 0 {
+		return ""
+	}
+	return roles[0]
+}
+
+// UserRoles returns the authenticated user's roles, whether set by Auth from
+// token claims or by the session-backed auth middleware
+func UserRoles(c *gin.Context) []string {
+	roles := slices.Clone(contextkeys.Roles.GetStrings(c))
+	if role := contextkeys.Role.GetString(c); role != "" && !slices.Contains(roles, role) {
+		roles = append(roles, role)
+	}
+	return roles
+}
+
 // RequireRole middleware checks if user has required role
 func RequireRole(requiredRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		roles, exists := c.Get("roles")
-		if !exists {
+		roles := UserRoles(c)
+		if len(roles) == 0 {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error": "No roles found",
 			})
@@ -243,18 +646,7 @@ func RequireRole(requiredRole string) gin.HandlerFunc {
 			return
 		}
 
-		// Check if user has required role
-		hasRole := false
-		if roleList, ok := roles.([]interface{}); ok {
-			for _, role := range roleList {
-				if roleStr, ok := role.(string); ok && roleStr == requiredRole {
-					hasRole = true
-					break
-				}
-			}
-		}
-
-		if !hasRole {
+		if !slices.Contains(roles, requiredRole) {
 			c.JSON(http.StatusForbidden, gin.H{
 				"error": fmt.Sprintf("Required role '%s' not found", requiredRole),
 			})
@@ -0,0 +1,422 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/time/rate"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/proxy"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/telemetry"
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+func setupTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return gin.New()
+}
+
+func TestRequireRole_BothAuthPaths(t *testing.T) {
+	const jwtSecret = "test-secret"
+	logger := testutil.NewTestLogger()
+	db := testutil.NewTestDB(t, &models.User{}, &models.UserSession{})
+	authService := services.NewAuthService(services.NewDatabaseServiceFromDB(db, nil, logger), logger)
+
+	router := setupTestRouter()
+	ok := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"user_id": contextkeys.UserID.GetString(c),
+			"role":    contextkeys.Role.GetString(c),
+			"roles":   middleware.UserRoles(c),
+		})
+	}
+	router.GET("/jwt/admin", middleware.Auth(jwtSecret), middleware.RequireRole("admin"), ok)
+	router.GET("/session/admin", middleware.ProductionAuth(authService, logger), middleware.RequireRole("admin"), ok)
+
+	jwtToken := func(roles ...string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": "jwt-user",
+			"roles":   roles,
+			"exp":     time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte(jwtSecret))
+		require.NoError(t, err)
+		return token
+	}
+	sessionToken := func(username, role string) string {
+		user := &models.User{Email: username + "@example.com", Username: username, Password: "hash", Role: role, IsActive: true}
+		require.NoError(t, db.Create(user).Error)
+		token := "token-" + username
+		require.NoError(t, db.Create(&models.UserSession{
+			UserID:       user.ID,
+			SessionToken: token,
+			RefreshToken: "refresh-" + username,
+			ExpiresAt:    time.Now().Add(time.Hour),
+			IsActive:     true,
+		}).Error)
+		return token
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"token with admin among roles", "/jwt/admin", jwtToken("user", "admin"), http.StatusOK},
+		{"token without admin role", "/jwt/admin", jwtToken("user"), http.StatusForbidden},
+		{"token without roles", "/jwt/admin", jwtToken(), http.StatusForbidden},
+		{"admin session", "/session/admin", sessionToken("admin", "admin"), http.StatusOK},
+		{"user session", "/session/admin", sessionToken("member", "user"), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var body struct {
+				UserID string   `json:"user_id"`
+				Role   string   `json:"role"`
+				Roles  []string `json:"roles"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.NotEmpty(t, body.UserID)
+			assert.Contains(t, body.Roles, "admin")
+			assert.Equal(t, "admin", body.Role, "the most privileged role is the primary one")
+		})
+	}
+}
+
+func TestMaxBodySize_ProxiedSnippet(t *testing.T) {
+	var received atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	serviceProxy := proxy.NewServiceProxy("analysis", backend.URL, 5*time.Second, logger)
+
+	router := setupTestRouter()
+	router.POST("/analysis/snippet", middleware.MaxBodySize(16), func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodPost, "/analysis/snippet")
+	})
+
+	send := func(body io.Reader, length int64) int {
+		req := httptest.NewRequest(http.MethodPost, "/analysis/snippet", body)
+		req.ContentLength = length
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send(strings.NewReader("package main\n"), 13))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(strings.NewReader(strings.Repeat("x", 32)), 32))
+	// Without a declared length the limit applies while the body is read
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(io.MultiReader(strings.NewReader(strings.Repeat("x", 32))), -1))
+	assert.Equal(t, int32(1), received.Load())
+}
+
+func TestRequestMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	requestMetrics, err := middleware.RequestMetrics(meterProvider.Meter("test"))
+	require.NoError(t, err)
+
+	router := setupTestRouter()
+	router.Use(requestMetrics)
+	router.GET("/projects/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/projects/1", "/projects/2", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var collected metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &collected))
+	require.Len(t, collected.ScopeMetrics, 1)
+
+	counts := make(map[string]int64)
+	var durations uint64
+	for _, m := range collected.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			assert.Equal(t, "http.server.requests", m.Name)
+			for _, point := range data.DataPoints {
+				route, _ := point.Attributes.Value("http.route")
+				status, _ := point.Attributes.Value("http.response.status_code")
+				counts[fmt.Sprintf("%s %d", route.AsString(), status.AsInt64())] += point.Value
+			}
+		case metricdata.Histogram[float64]:
+			assert.Equal(t, "http.server.request.duration", m.Name)
+			assert.Equal(t, "s", m.Unit)
+			for _, point := range data.DataPoints {
+				durations += point.Count
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{"/projects/:id 200": 2, "unmatched 404": 1}, counts)
+	assert.Equal(t, uint64(3), durations)
+}
+
+func TestRequestIDWithConfig(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	serve := func(config middleware.RequestIDConfig, headers map[string]string) (*httptest.ResponseRecorder, string) {
+		router := setupTestRouter()
+		router.Use(middleware.RequestIDWithConfig(config))
+		var seen string
+		router.GET("/ping", func(c *gin.Context) {
+			seen = utils.RequestIDFromContext(c.Request.Context())
+			assert.Equal(t, seen, contextkeys.RequestID.GetString(c))
+			c.Status(http.StatusOK)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w, seen
+	}
+
+	t.Run("custom header name", func(t *testing.T) {
+		config := middleware.RequestIDConfig{Header: "X-Correlation-ID"}
+
+		w, seen := serve(config, map[string]string{"X-Correlation-ID": "corr-123", utils.RequestIDHeader: "ignored"})
+		assert.Equal(t, "corr-123", seen)
+		assert.Equal(t, "corr-123", w.Header().Get("X-Correlation-ID"))
+		assert.Empty(t, w.Header().Get(utils.RequestIDHeader))
+
+		w, seen = serve(config, nil)
+		_, err := uuid.Parse(seen)
+		assert.NoError(t, err, "a missing ID is generated")
+		assert.Equal(t, seen, w.Header().Get("X-Correlation-ID"))
+	})
+
+	t.Run("derived from trace context", func(t *testing.T) {
+		config := middleware.RequestIDConfig{FromTraceContext: true}
+
+		w, seen := serve(config, map[string]string{utils.TraceparentHeader: traceparent})
+		assert.Equal(t, traceID, seen)
+		assert.Equal(t, traceID, w.Header().Get(utils.RequestIDHeader))
+
+		_, seen = serve(config, map[string]string{utils.TraceparentHeader: traceparent, utils.RequestIDHeader: "explicit"})
+		assert.Equal(t, "explicit", seen, "an explicit ID wins")
+
+		_, seen = serve(config, map[string]string{utils.TraceparentHeader: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"})
+		_, err := uuid.Parse(seen)
+		assert.NoError(t, err, "an invalid traceparent is ignored")
+
+		_, seen = serve(middleware.RequestIDConfig{}, map[string]string{utils.TraceparentHeader: traceparent})
+		assert.NotEqual(t, traceID, seen, "trace IDs are only used when enabled")
+	})
+
+	t.Run("shared with the request span", func(t *testing.T) {
+		_, err := telemetry.Setup(context.Background(), telemetry.Config{}, logrus.New())
+		require.NoError(t, err)
+		t.Cleanup(func() { otel.SetTracerProvider(tracenoop.NewTracerProvider()) })
+
+		router := setupTestRouter()
+		router.Use(middleware.RequestIDWithConfig(middleware.RequestIDConfig{FromTraceContext: true}))
+		router.Use(middleware.Tracing(sdktrace.NewTracerProvider().Tracer("test")))
+		var spanTraceID string
+		router.GET("/ping", func(c *gin.Context) {
+			spanTraceID = trace.SpanContextFromContext(c.Request.Context()).TraceID().String()
+			c.Status(http.StatusOK)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set(utils.TraceparentHeader, traceparent)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, traceID, spanTraceID, "the span continues the incoming trace")
+		assert.Equal(t, spanTraceID, w.Header().Get(utils.RequestIDHeader))
+	})
+}
+
+func TestPathLimits(t *testing.T) {
+	router := setupTestRouter()
+	router.Use(middleware.PathLimits(middleware.PathLimitConfig{MaxLength: 48, MaxSegments: 8}))
+	router.GET("/api/v1/metrics/file/:projectId/*filePath", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("filePath"))
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{"within limits", "/api/v1/metrics/file/p1/a/b/main.go", http.StatusOK},
+		{"at segment limit", "/api/v1/metrics/file/p1/a/b/c.go/", http.StatusOK},
+		{"too long", "/api/v1/metrics/file/p1/" + strings.Repeat("x", 32) + ".go", http.StatusRequestURITooLong},
+		{"escaped path too long", "/api/v1/metrics/file/p1/" + strings.Repeat("%20", 9), http.StatusRequestURITooLong},
+		{"too many segments", "/api/v1/metrics/file/p1/a/b/c/d.go", http.StatusRequestURITooLong},
+		{"encoded slashes count as segments", "/api/v1/metrics/file/p1/a%2Fb%2Fc/d.go", http.StatusRequestURITooLong},
+		{"empty segments count", "/api/v1/metrics/file/p1/a//b/c", http.StatusRequestURITooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+		})
+	}
+
+	t.Run("zero disables the limits", func(t *testing.T) {
+		router := setupTestRouter()
+		router.Use(middleware.PathLimits(middleware.PathLimitConfig{}))
+		router.GET("/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+strings.Repeat("a/", 500), nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestCORSPolicy(t *testing.T) {
+	const (
+		appOrigin  = "https://app.example.com"
+		authOrigin = "https://login.example.com"
+		wsOrigin   = "https://live.example.com"
+	)
+	cors := middleware.NewCORSPolicy(middleware.CORSConfig{
+		AllowedOrigins: []string{appOrigin},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization"},
+		MaxAge:         600,
+	})
+	cors.SetGroup("/api/v1/auth", middleware.CORSConfig{AllowedOrigins: []string{authOrigin}, AllowedMethods: []string{"POST"}})
+	cors.SetGroup("/api/v1", middleware.CORSConfig{})
+	cors.SetGroup("/ws/", middleware.CORSConfig{AllowedOrigins: []string{wsOrigin}, MaxAge: 60})
+
+	router := setupTestRouter()
+	router.Use(cors.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.POST("/api/v1/auth/login", ok)
+	router.GET("/api/v1/authors", ok)
+	router.GET("/api/v1/projects", ok)
+	router.GET("/ws", ok)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		origin      string
+		wantAllowed bool
+		wantMethods string
+		wantMaxAge  string
+	}{
+		{"api allows the global origin", http.MethodGet, "/api/v1/projects", appOrigin, true, "", ""},
+		{"api rejects the auth origin", http.MethodGet, "/api/v1/projects", authOrigin, false, "", ""},
+		{"auth preflight allows its origin", http.MethodOptions, "/api/v1/auth/login", authOrigin, true, "POST", "600"},
+		{"auth rejects the global origin", http.MethodOptions, "/api/v1/auth/login", appOrigin, false, "POST", "600"},
+		{"group prefixes match whole segments", http.MethodGet, "/api/v1/authors", appOrigin, true, "", ""},
+		{"ws allows its origin", http.MethodGet, "/ws", wsOrigin, true, "", ""},
+		{"ws preflight falls back to global methods", http.MethodOptions, "/ws", wsOrigin, true, "GET, POST", "60"},
+		{"ws rejects the global origin", http.MethodGet, "/ws", appOrigin, false, "", ""},
+		{"routes outside groups use the global rules", http.MethodOptions, "/health", appOrigin, true, "GET, POST", "600"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if tt.wantAllowed {
+				assert.Equal(t, tt.origin, w.Header().Get("Access-Control-Allow-Origin"))
+			} else {
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+			}
+			if tt.method == http.MethodOptions {
+				assert.Equal(t, http.StatusNoContent, w.Code)
+				assert.Equal(t, tt.wantMethods, w.Header().Get("Access-Control-Allow-Methods"))
+				assert.Equal(t, tt.wantMaxAge, w.Header().Get("Access-Control-Max-Age"))
+			} else {
+				assert.Equal(t, http.StatusOK, w.Code)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_ExemptPaths(t *testing.T) {
+	router := setupTestRouter()
+	router.Use(middleware.RateLimiter(rate.NewLimiter(rate.Every(time.Hour), 1), "", middleware.DefaultRateLimitExemptPaths...))
+	for _, path := range []string{"/health", "/health/ready", "/healthz", "/metrics", "/api/v1/projects", "/api/v1/auth/validate-batch"} {
+		router.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+	request := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	for i := 0; i < 5; i++ {
+		for _, path := range []string{"/health", "/health/ready", "/metrics"} {
+			require.Equal(t, http.StatusOK, request(path), path)
+		}
+	}
+	assert.Equal(t, http.StatusOK, request("/api/v1/projects"), "exempt requests don't use up the limit")
+	assert.Equal(t, http.StatusTooManyRequests, request("/api/v1/projects"))
+	assert.Equal(t, http.StatusTooManyRequests, request("/healthz"), "only the exempt paths and those below them bypass the limit")
+	assert.Equal(t, http.StatusTooManyRequests, request("/api/v1/auth/validate-batch"), "internal routes are limited without the internal token")
+	assert.Equal(t, http.StatusOK, request("/health"), "health checks pass while the API is limited")
+	assert.Equal(t, http.StatusOK, request("/metrics"))
+}
+
+func TestRateLimiter_InternalToken(t *testing.T) {
+	const token = "internal-secret"
+	router := setupTestRouter()
+	router.Use(middleware.RateLimiter(rate.NewLimiter(rate.Every(time.Hour), 1), token))
+	router.POST("/api/v1/auth/validate-batch", func(c *gin.Context) { c.Status(http.StatusOK) })
+	request := func(presented string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/validate-batch", nil)
+		if presented != "" {
+			req.Header.Set(middleware.InternalTokenHeader, presented)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusOK, request(token))
+	}
+	assert.Equal(t, http.StatusOK, request(""), "backend requests don't use up the limit")
+	assert.Equal(t, http.StatusTooManyRequests, request(""))
+	assert.Equal(t, http.StatusTooManyRequests, request("guessed"), "a wrong token is limited")
+	assert.Equal(t, http.StatusOK, request(token))
+}
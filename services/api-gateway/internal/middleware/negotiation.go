@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// jsonMediaType is the API's representation
+const jsonMediaType = "application/json"
+
+// DefaultMediaTypes are served by every route: JSON, and JSON with links
+var DefaultMediaTypes = []string{jsonMediaType, utils.HALMediaType}
+
+// NegotiationConfig lists the media types routes serve besides the
+// defaults, by full route pattern such as /api/v1/analysis/run-sync/:projectId
+type NegotiationConfig struct {
+	Routes map[string][]string
+}
+
+// ContentNegotiation answers 406 Not Acceptable to requests whose Accept
+// header rules out every media type their route serves. A missing Accept
+// header accepts anything. Responses are compact JSON unless the client asks
+// for indented JSON with the pretty parameter, as in
+// "Accept: application/json; pretty=true".
+func ContentNegotiation(config NegotiationConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		served := DefaultMediaTypes
+		if extra := config.Routes[c.FullPath()]; len(extra) > 0 {
+			served = append(slices.Clone(DefaultMediaTypes), extra...)
+		}
+		acceptable, pretty := negotiate(c.GetHeader("Accept"), served)
+		if !acceptable {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+				"error":     "Not acceptable",
+				"supported": served,
+			})
+			return
+		}
+		c.Writer.Header().Add("Vary", "Accept")
+		if !pretty {
+			c.Next()
+			return
+		}
+
+		writer := &prettyJSONWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		writer.flush()
+	}
+}
+
+// negotiate reports whether an Accept header admits one of the served media
+// types and whether the most specific range admitting one asks for indented
+// output. Ranges with q=0 are refused; ranges that don't parse are ignored.
+func negotiate(accept string, served []string) (acceptable, pretty bool) {
+	if strings.TrimSpace(accept) == "" {
+		return true, false
+	}
+
+	best := -1 // specificity of the matched range: */*, type/*, type/subtype
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if weight, err := strconv.ParseFloat(q, 64); err != nil || weight <= 0 {
+				continue
+			}
+		}
+
+		specificity := -1
+		for _, servedType := range served {
+			major, _, _ := strings.Cut(servedType, "/")
+			switch mediaType {
+			case "*/*":
+				specificity = max(specificity, 0)
+			case major + "/*":
+				specificity = max(specificity, 1)
+			case servedType:
+				specificity = 2
+			}
+		}
+		if specificity > best {
+			best = specificity
+			pretty, _ = strconv.ParseBool(params["pretty"])
+		}
+	}
+	return best >= 0, pretty
+}
+
+// prettyJSONWriter holds back the response so JSON bodies can be indented
+// once the handler has finished
+type prettyJSONWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (w *prettyJSONWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *prettyJSONWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *prettyJSONWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// flush writes the held back response, indenting it if it is valid JSON
+func (w *prettyJSONWriter) flush() {
+	body := w.body.Bytes()
+	if mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); mediaType == jsonMediaType && len(body) > 0 {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, body, "", "  "); err == nil {
+			indented.WriteByte('\n')
+			body = indented.Bytes()
+		}
+	}
+	if len(body) == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	// Proxied responses carry the backend's length of the compact body
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.Write(body)
+}
@@ -0,0 +1,89 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/proxy"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	router := setupTestRouter()
+	router.Use(middleware.ContentNegotiation(middleware.NegotiationConfig{
+		Routes: map[string][]string{"/stream": {"application/x-ndjson"}},
+	}))
+	router.GET("/projects", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"projects": []string{"sa3d"}})
+	})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"RUNNING"}`))
+	}))
+	defer backend.Close()
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	serviceProxy := proxy.NewServiceProxy("analysis", backend.URL, time.Second, logger)
+	router.GET("/analysis/status", func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodGet, "/analysis/status")
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/x-ndjson", []byte(`{"event":"started"}`))
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		accept     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"no accept header", "/projects", "", http.StatusOK, `{"projects":["sa3d"]}`},
+		{"json", "/projects", "application/json", http.StatusOK, `{"projects":["sa3d"]}`},
+		{"wildcard", "/projects", "text/html, */*;q=0.1", http.StatusOK, `{"projects":["sa3d"]}`},
+		{"application wildcard", "/projects", "application/*", http.StatusOK, `{"projects":["sa3d"]}`},
+		{"explicitly compact", "/projects", "application/json; pretty=false", http.StatusOK, `{"projects":["sa3d"]}`},
+		{"pretty", "/projects", "application/json; pretty=true", http.StatusOK, "{\n  \"projects\": [\n    \"sa3d\"\n  ]\n}\n"},
+		{"pretty over wildcard", "/projects", "*/*, application/json;pretty=1", http.StatusOK, "{\n  \"projects\": [\n    \"sa3d\"\n  ]\n}\n"},
+		{"pretty proxied response", "/analysis/status", "application/json;pretty=true", http.StatusOK, "{\n  \"status\": \"RUNNING\"\n}\n"},
+		{"hal", "/projects", "application/hal+json", http.StatusOK, `{"projects":["sa3d"]}`},
+		{"route media type", "/stream", "application/x-ndjson", http.StatusOK, `{"event":"started"}`},
+		{"media type of another route", "/projects", "application/x-ndjson", http.StatusNotAcceptable, ""},
+		{"xml", "/projects", "application/xml", http.StatusNotAcceptable, ""},
+		{"json refused", "/projects", "application/json;q=0, text/plain", http.StatusNotAcceptable, ""},
+		{"unparseable", "/projects", "garbage", http.StatusNotAcceptable, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusNotAcceptable {
+				var body map[string]interface{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, []interface{}{"application/json", "application/hal+json"}, body["supported"])
+				return
+			}
+			assert.Equal(t, tt.wantBody, w.Body.String())
+			if strings.Contains(tt.wantBody, "\n") {
+				assert.Equal(t, strconv.Itoa(len(tt.wantBody)), w.Header().Get("Content-Length"))
+			}
+			assert.Contains(t, w.Header().Values("Vary"), "Accept")
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"slices"
+	"sort"
+)
+
+// Identities a request authenticates as, stored under contextkeys.Identity
+const (
+	// IdentityUser is a user, authenticated with a session or JWT token
+	IdentityUser = "user"
+	// IdentityAPIKey is a backend, authenticated with the internal token
+	IdentityAPIKey = "api_key"
+)
+
+// RoleInternal is the role of requests authenticated with the internal
+// token
+const RoleInternal = "internal"
+
+// userPermissions are what every user may do
+var userPermissions = []string{
+	"analysis:read", "analysis:run",
+	"collaboration:read", "collaboration:write",
+	"metrics:read",
+	"projects:read", "projects:write",
+	"sessions:manage",
+	"visualization:read", "visualization:write",
+}
+
+// RolePermissions lists what each role may do, as resource:action pairs
+// named after the gateway's route groups. Unlisted roles may do nothing.
+var RolePermissions = map[string][]string{
+	"user":        userPermissions,
+	"admin":       append(slices.Clone(userPermissions), "users:manage"),
+	"super_admin": append(slices.Clone(userPermissions), "users:manage"),
+	RoleInternal:  {"tokens:validate"},
+}
+
+// Permissions resolves roles to what they may do, sorted and without
+// duplicates
+func Permissions(roles []string) []string {
+	permissions := []string{}
+	for _, role := range roles {
+		for _, permission := range RolePermissions[role] {
+			if !slices.Contains(permissions, permission) {
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
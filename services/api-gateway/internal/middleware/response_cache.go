@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// CacheStatusHeader reports whether a response came from the response cache
+const CacheStatusHeader = "X-Cache"
+
+// ResponseCache caches successful GET responses of whitelisted routes in
+// Redis, per path, query and user. Entries are indexed by their route
+// parameters (see utils.ResponseCacheTagKey) so the owning service can drop
+// them when the resource changes.
+type ResponseCache struct {
+	client *redis.Client
+	routes map[string]time.Duration // lower-cased route pattern -> TTL
+	// tagTTL is the longest route TTL, so a tag outlives every entry it
+	// indexes whichever route stored it last
+	tagTTL time.Duration
+	logger *logrus.Logger
+}
+
+// cachedResponse is a stored response
+type cachedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// NewResponseCache creates a response cache for the given routes, keyed by
+// their full pattern such as /api/v1/analysis/status/:analysisId
+func NewResponseCache(client *redis.Client, routes map[string]time.Duration, logger *logrus.Logger) *ResponseCache {
+	normalized := make(map[string]time.Duration, len(routes))
+	var tagTTL time.Duration
+	for route, ttl := range routes {
+		// Config keys are case-insensitive
+		normalized[strings.ToLower(route)] = ttl
+		tagTTL = max(tagTTL, ttl)
+	}
+	return &ResponseCache{client: client, routes: normalized, tagTTL: tagTTL, logger: logger}
+}
+
+// Middleware serves cached responses for whitelisted GET routes and stores
+// fresh ones. Cache-Control: no-cache skips the lookup but still refreshes
+// the entry.
+func (rc *ResponseCache) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ttl, ok := rc.routes[strings.ToLower(c.FullPath())]
+		if !ok || ttl <= 0 || c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := rc.key(c)
+
+		if !strings.Contains(strings.ToLower(c.GetHeader("Cache-Control")), "no-cache") {
+			if cached, ok := rc.get(ctx, key); ok {
+				c.Header(CacheStatusHeader, "HIT")
+				c.Data(cached.Status, cached.ContentType, cached.Body)
+				c.Abort()
+				return
+			}
+		}
+
+		writer := &bodyCapturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Header(CacheStatusHeader, "MISS")
+		c.Next()
+
+		if writer.Status() != http.StatusOK {
+			return
+		}
+		rc.set(ctx, key, c, &cachedResponse{
+			Status:      writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}, ttl)
+	}
+}
+
+// key identifies a cached response by path, query, user and accepted type,
+// and by base URL for responses with links, whose hrefs are absolute
+func (rc *ResponseCache) key(c *gin.Context) string {
+	h := sha256.New()
+	h.Write([]byte(c.Request.URL.Path + "?" + c.Request.URL.Query().Encode()))
+	h.Write([]byte("\n" + contextkeys.UserID.GetString(c) + "\n" + c.GetHeader("Accept")))
+	if utils.LinksRequested(c.Request) {
+		h.Write([]byte("\n" + utils.BaseURL(c.Request)))
+	}
+	return "gateway:response:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func (rc *ResponseCache) get(ctx context.Context, key string) (*cachedResponse, bool) {
+	data, err := rc.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			rc.logger.WithError(err).Warn("Response cache lookup failed")
+		}
+		return nil, false
+	}
+
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, false
+	}
+	return &cached, true
+}
+
+func (rc *ResponseCache) set(ctx context.Context, key string, c *gin.Context, response *cachedResponse, ttl time.Duration) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+
+	pipe := rc.client.TxPipeline()
+	pipe.Set(ctx, key, data, ttl)
+	for _, param := range c.Params {
+		tagKey := utils.ResponseCacheTagKey(param.Key, param.Value)
+		pipe.SAdd(ctx, tagKey, key)
+		pipe.Expire(ctx, tagKey, rc.tagTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		rc.logger.WithError(err).Warn("Failed to store cached response")
+	}
+}
+
+// Invalidate drops the cached responses of routes with the given parameter value
+func (rc *ResponseCache) Invalidate(ctx context.Context, param, value string) error {
+	tagKey := utils.ResponseCacheTagKey(param, value)
+	keys, err := rc.client.SMembers(ctx, tagKey).Result()
+	if err != nil {
+		return err
+	}
+	return rc.client.Del(ctx, append(keys, tagKey)...).Err()
+}
+
+// bodyCapturingWriter keeps a copy of everything written to the response
+type bodyCapturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (w *bodyCapturingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *bodyCapturingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyCapturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
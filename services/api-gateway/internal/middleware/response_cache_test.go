@@ -0,0 +1,166 @@
+package middleware_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/proxy"
+)
+
+func TestResponseCache(t *testing.T) {
+	var calls int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"call":%d}`, n)
+	}))
+	defer backend.Close()
+
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	serviceProxy := proxy.NewServiceProxy("analysis", backend.URL, 5*time.Second, logger)
+	cache := middleware.NewResponseCache(redisClient, map[string]time.Duration{
+		"/analysis/status/:analysisId": time.Minute,
+	}, logger)
+
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Next()
+	}, cache.Middleware())
+	router.GET("/analysis/status/:analysisId", func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodGet, "/analysis/status")
+	})
+	router.GET("/analysis/results/:analysisId", func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodGet, "/analysis/results")
+	})
+
+	get := func(path, user string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.Header.Set("X-Test-User", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	reset := func() {
+		mr.FlushAll()
+		atomic.StoreInt32(&calls, 0)
+	}
+
+	t.Run("miss then hit", func(t *testing.T) {
+		reset()
+		first := get("/analysis/status/a1", "user-1", nil)
+		assert.Equal(t, "MISS", first.Header().Get(middleware.CacheStatusHeader))
+
+		second := get("/analysis/status/a1", "user-1", nil)
+		assert.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, "HIT", second.Header().Get(middleware.CacheStatusHeader))
+		assert.Equal(t, "application/json", second.Header().Get("Content-Type"))
+		assert.JSONEq(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("keyed by query and user", func(t *testing.T) {
+		reset()
+		get("/analysis/status/a1?verbose=1", "user-1", nil)
+		assert.Equal(t, "HIT", get("/analysis/status/a1?verbose=1", "user-1", nil).Header().Get(middleware.CacheStatusHeader))
+		assert.Equal(t, "MISS", get("/analysis/status/a1?verbose=0", "user-1", nil).Header().Get(middleware.CacheStatusHeader))
+		assert.Equal(t, "MISS", get("/analysis/status/a1?verbose=1", "user-2", nil).Header().Get(middleware.CacheStatusHeader))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("expires after TTL", func(t *testing.T) {
+		reset()
+		get("/analysis/status/a1", "user-1", nil)
+		mr.FastForward(time.Minute + time.Second)
+
+		w := get("/analysis/status/a1", "user-1", nil)
+		assert.Equal(t, "MISS", w.Header().Get(middleware.CacheStatusHeader))
+		assert.JSONEq(t, `{"call":2}`, w.Body.String())
+	})
+
+	t.Run("no-cache bypasses and refreshes", func(t *testing.T) {
+		reset()
+		get("/analysis/status/a1", "user-1", nil)
+
+		w := get("/analysis/status/a1", "user-1", http.Header{"Cache-Control": {"no-cache"}})
+		assert.Equal(t, "MISS", w.Header().Get(middleware.CacheStatusHeader))
+		assert.JSONEq(t, `{"call":2}`, w.Body.String())
+
+		w = get("/analysis/status/a1", "user-1", nil)
+		assert.Equal(t, "HIT", w.Header().Get(middleware.CacheStatusHeader))
+		assert.JSONEq(t, `{"call":2}`, w.Body.String())
+	})
+
+	t.Run("routes not listed are not cached", func(t *testing.T) {
+		reset()
+		get("/analysis/results/a1", "user-1", nil)
+		w := get("/analysis/results/a1", "user-1", nil)
+		assert.Empty(t, w.Header().Get(middleware.CacheStatusHeader))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("invalidated by route parameter", func(t *testing.T) {
+		reset()
+		get("/analysis/status/a1", "user-1", nil)
+		get("/analysis/status/a2", "user-1", nil)
+
+		require.NoError(t, cache.Invalidate(context.Background(), "analysisId", "a1"))
+		assert.Equal(t, "MISS", get("/analysis/status/a1", "user-1", nil).Header().Get(middleware.CacheStatusHeader))
+		assert.Equal(t, "HIT", get("/analysis/status/a2", "user-1", nil).Header().Get(middleware.CacheStatusHeader))
+	})
+}
+
+func TestResponseCache_TagOutlivesShorterRoutes(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	cache := middleware.NewResponseCache(redisClient, map[string]time.Duration{
+		"/analysis/status/:analysisId":  2 * time.Second,
+		"/analysis/results/:analysisId": time.Minute,
+	}, logger)
+
+	router := setupTestRouter()
+	router.Use(cache.Middleware())
+	respond := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"path": c.Request.URL.Path})
+	}
+	router.GET("/analysis/status/:analysisId", respond)
+	router.GET("/analysis/results/:analysisId", respond)
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Header().Get(middleware.CacheStatusHeader)
+	}
+
+	// The short-lived status entry is stored after the results entry
+	get("/analysis/results/a1")
+	get("/analysis/status/a1")
+	mr.FastForward(3 * time.Second)
+
+	require.NoError(t, cache.Invalidate(context.Background(), "analysisId", "a1"))
+	assert.Equal(t, "MISS", get("/analysis/results/a1"))
+}
@@ -0,0 +1,84 @@
+package middleware_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	// httptest requests come from 192.0.2.1
+	proxies, err := middleware.NewTrustedProxies([]string{"192.0.2.0/24"})
+	require.NoError(t, err)
+	serve := func(config middleware.SecurityHeadersConfig, prepare func(*http.Request)) http.Header {
+		router := setupTestRouter()
+		router.Use(middleware.SecurityHeaders(config, proxies))
+		router.GET("/test", func(c *gin.Context) { c.Status(http.StatusOK) })
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		if prepare != nil {
+			prepare(req)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Header()
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		headers := serve(middleware.DefaultSecurityHeaders, nil)
+		assert.Equal(t, "nosniff", headers.Get("X-Content-Type-Options"))
+		assert.Equal(t, "DENY", headers.Get("X-Frame-Options"))
+		assert.Equal(t, "no-referrer", headers.Get("Referrer-Policy"))
+		assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", headers.Get("Content-Security-Policy"))
+		assert.Empty(t, headers.Values("Strict-Transport-Security"), "no HSTS over plain HTTP")
+	})
+
+	t.Run("HSTS over HTTPS", func(t *testing.T) {
+		headers := serve(middleware.DefaultSecurityHeaders, func(req *http.Request) {
+			req.Header.Set("X-Forwarded-Proto", "https")
+		})
+		assert.Equal(t, "max-age=31536000; includeSubDomains", headers.Get("Strict-Transport-Security"))
+
+		headers = serve(middleware.DefaultSecurityHeaders, func(req *http.Request) {
+			req.TLS = &tls.ConnectionState{}
+		})
+		assert.Equal(t, "max-age=31536000; includeSubDomains", headers.Get("Strict-Transport-Security"))
+	})
+
+	t.Run("HTTPS claimed by an untrusted client", func(t *testing.T) {
+		headers := serve(middleware.DefaultSecurityHeaders, func(req *http.Request) {
+			req.RemoteAddr = "203.0.113.9:4711"
+			req.Header.Set("X-Forwarded-Proto", "https")
+		})
+		assert.Empty(t, headers.Values("Strict-Transport-Security"))
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		config := middleware.SecurityHeadersConfig{
+			FrameOptions:          "SAMEORIGIN",
+			ReferrerPolicy:        "strict-origin-when-cross-origin",
+			ContentSecurityPolicy: "default-src 'self'",
+			HSTS:                  middleware.HSTSConfig{MaxAge: time.Hour, Preload: true},
+		}
+		headers := serve(config, func(req *http.Request) { req.TLS = &tls.ConnectionState{} })
+		assert.Empty(t, headers.Values("X-Content-Type-Options"), "an empty value leaves the header out")
+		assert.Equal(t, "SAMEORIGIN", headers.Get("X-Frame-Options"))
+		assert.Equal(t, "strict-origin-when-cross-origin", headers.Get("Referrer-Policy"))
+		assert.Equal(t, "default-src 'self'", headers.Get("Content-Security-Policy"))
+		assert.Equal(t, "max-age=3600; preload", headers.Get("Strict-Transport-Security"))
+	})
+
+	t.Run("HSTS disabled", func(t *testing.T) {
+		config := middleware.DefaultSecurityHeaders
+		config.HSTS.MaxAge = 0
+		headers := serve(config, func(req *http.Request) { req.TLS = &tls.ConnectionState{} })
+		assert.Empty(t, headers.Values("Strict-Transport-Security"))
+	})
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// forwardedURLHeaders describe the scheme, host and path prefix the client
+// reached the proxy in front of the gateway at
+var forwardedURLHeaders = []string{"X-Forwarded-Proto", "X-Forwarded-Host", utils.ForwardedPrefixHeader}
+
+// TrustedProxies are the load balancers in front of the gateway, the same
+// addresses and CIDRs the engine trusts (see gin.Engine.SetTrustedProxies).
+// Forwarding headers only describe the original request when one of them
+// added it.
+type TrustedProxies struct {
+	prefixes []netip.Prefix
+}
+
+// NewTrustedProxies parses the addresses and CIDRs of the trusted proxies
+func NewTrustedProxies(proxies []string) (*TrustedProxies, error) {
+	trusted := &TrustedProxies{}
+	for _, proxy := range proxies {
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+			}
+			trusted.prefixes = append(trusted.prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+		}
+		addr = addr.Unmap()
+		trusted.prefixes = append(trusted.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return trusted, nil
+}
+
+// Trusts reports whether the request came straight from a trusted proxy. A
+// nil TrustedProxies trusts no one.
+func (t *TrustedProxies) Trusts(r *http.Request) bool {
+	if t == nil {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range t.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForwardedHeaders middleware removes the X-Forwarded-Proto, X-Forwarded-Host
+// and X-Forwarded-Prefix headers of requests that didn't come straight from
+// a trusted proxy, so the absolute links of responses, and the backends the
+// request is proxied to, never take a client's word for where it is.
+func ForwardedHeaders(proxies *TrustedProxies) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !proxies.Trusts(c.Request) {
+			for _, name := range forwardedURLHeaders {
+				c.Request.Header.Del(name)
+			}
+		}
+		c.Next()
+	}
+}
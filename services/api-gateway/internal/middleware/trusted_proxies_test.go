@@ -0,0 +1,75 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	proxies, err := middleware.NewTrustedProxies([]string{"10.0.0.0/8", "198.51.100.7", "2001:db8::/32"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       bool
+	}{
+		{"in a trusted CIDR", "10.1.2.3:4711", true},
+		{"a trusted address", "198.51.100.7:4711", true},
+		{"ipv4-mapped trusted address", "[::ffff:198.51.100.7]:4711", true},
+		{"ipv6 CIDR", "[2001:db8::1]:4711", true},
+		{"untrusted", "203.0.113.9:4711", false},
+		{"not an address", "pipe", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			assert.Equal(t, tt.want, proxies.Trusts(req))
+		})
+	}
+
+	t.Run("none configured", func(t *testing.T) {
+		var none *middleware.TrustedProxies
+		assert.False(t, none.Trusts(httptest.NewRequest(http.MethodGet, "/", nil)))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := middleware.NewTrustedProxies([]string{"10.0.0.0/33"})
+		assert.Error(t, err)
+		_, err = middleware.NewTrustedProxies([]string{"proxy.internal"})
+		assert.Error(t, err)
+	})
+}
+
+func TestForwardedHeaders(t *testing.T) {
+	proxies, err := middleware.NewTrustedProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	router := setupTestRouter()
+	router.Use(middleware.ForwardedHeaders(proxies))
+	router.GET("/base", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetHeader("X-Forwarded-Proto")+" "+c.GetHeader("X-Forwarded-Host")+" "+c.GetHeader("X-Forwarded-Prefix")+" "+c.GetHeader("X-Forwarded-For"))
+	})
+
+	serve := func(remoteAddr string) string {
+		req := httptest.NewRequest(http.MethodGet, "/base", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "sa3d.example.com")
+		req.Header.Set("X-Forwarded-Prefix", "/sa3d")
+		req.Header.Set("X-Forwarded-For", "198.51.100.7")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	assert.Equal(t, "https sa3d.example.com /sa3d 198.51.100.7", serve("10.0.0.5:4711"))
+	assert.Equal(t, "   198.51.100.7", serve("203.0.113.9:4711"), "X-Forwarded-For is left to ClientIP")
+}
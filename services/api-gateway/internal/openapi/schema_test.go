@@ -0,0 +1,56 @@
+package openapi_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/openapi"
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+// CreateProjectRequest is a request type as handlers declare them
+type CreateProjectRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Language    string `json:"language" binding:"required"`
+}
+
+func TestOpenAPI_SchemaOf(t *testing.T) {
+	doc := openapi.New("Test", "1.0.0")
+
+	t.Run("request types", func(t *testing.T) {
+		schema := doc.SchemaOf(CreateProjectRequest{})
+		require.Equal(t, "#/components/schemas/CreateProjectRequest", schema.Ref)
+
+		component := doc.Components.Schemas["CreateProjectRequest"]
+		require.NotNil(t, component)
+		assert.Equal(t, "object", component.Type)
+		assert.Contains(t, component.Properties, "description")
+		assert.ElementsMatch(t, []string{"name", "language"}, component.Required)
+	})
+
+	t.Run("formats", func(t *testing.T) {
+		doc.SchemaOf(models.User{})
+		component := doc.Components.Schemas["User"]
+		require.NotNil(t, component)
+		assert.Equal(t, "uuid", component.Properties["id"].Format)
+		assert.Equal(t, "date-time", component.Properties["created_at"].Format)
+		assert.Contains(t, component.Properties, "email", "embedded fields are promoted")
+		assert.NotContains(t, component.Properties, "password", "fields json skips aren't described")
+		assert.NotContains(t, component.Properties, "Password")
+	})
+
+	t.Run("collections and pointers", func(t *testing.T) {
+		schema := doc.SchemaOf(struct {
+			Names  []string       `json:"names"`
+			Counts map[string]int `json:"counts"`
+			Parent *string        `json:"parent"`
+		}{})
+		require.Empty(t, schema.Ref, "unnamed types are described in place")
+		assert.Equal(t, "array", schema.Properties["names"].Type)
+		assert.Equal(t, "integer", schema.Properties["counts"].AdditionalProperties.Type)
+		assert.True(t, schema.Properties["parent"].Nullable)
+	})
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// DefaultBodyLogMaxBytes caps each logged body when no limit is configured
+const DefaultBodyLogMaxBytes = 4096
+
+// BodyLogging configures debug logging of proxied request and response
+// bodies. It is meant for debugging a backend integration, never for
+// production traffic.
+type BodyLogging struct {
+	// Routes are the full route patterns whose bodies are logged, such as
+	// /api/v1/analysis/partial/:projectId. Auth routes are always excluded.
+	Routes []string
+	// MaxBytes truncates each logged body; 0 uses DefaultBodyLogMaxBytes
+	MaxBytes int
+}
+
+// SetBodyLogging enables body logging for the configured routes. Bodies
+// are redacted with utils.MaskString before they are truncated.
+func (p *ServiceProxy) SetBodyLogging(config BodyLogging) {
+	routes := make(map[string]bool, len(config.Routes))
+	for _, route := range config.Routes {
+		route = strings.ToLower(strings.TrimSpace(route))
+		if isAuthRoute(route) {
+			p.logger.WithField("route", route).Warn("Body logging is never enabled for auth routes")
+			continue
+		}
+		routes[route] = true
+	}
+
+	maxBytes := config.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultBodyLogMaxBytes
+	}
+	p.bodyLogRoutes = routes
+	p.bodyLogMaxBytes = maxBytes
+}
+
+// logsBodies reports whether the bodies of requests to the route are logged
+func (p *ServiceProxy) logsBodies(route string) bool {
+	route = strings.ToLower(route)
+	return p.bodyLogRoutes[route] && !isAuthRoute(route)
+}
+
+// logBodies logs a proxied exchange's bodies, redacted and truncated
+func (p *ServiceProxy) logBodies(c *gin.Context, method string, requestBody []byte, resp *upstreamResponse) {
+	fields := logrus.Fields{
+		"service":      p.name,
+		"route":        c.FullPath(),
+		"method":       method,
+		"request_id":   utils.RequestIDFromContext(c.Request.Context()),
+		"request_body": p.loggableBody(requestBody),
+	}
+	if resp != nil {
+		fields["status_code"] = resp.status
+		fields["response_body"] = p.loggableBody(resp.body)
+	}
+	p.logger.WithFields(fields).Info("Proxied request bodies")
+}
+
+// loggableBody masks sensitive values in a body, then truncates it
+func (p *ServiceProxy) loggableBody(body []byte) string {
+	if !utf8.Valid(body) {
+		return "[binary body]"
+	}
+	masked := utils.MaskString(string(body))
+	if len(masked) <= p.bodyLogMaxBytes {
+		return masked
+	}
+	truncated := masked[:p.bodyLogMaxBytes]
+	// Don't split a multi-byte character
+	for !utf8.ValidString(truncated) {
+		truncated = truncated[:len(truncated)-1]
+	}
+	return truncated + "...[truncated]"
+}
+
+// isAuthRoute reports whether a route carries credentials, so its bodies
+// must never be logged
+func isAuthRoute(route string) bool {
+	return strings.Contains(route+"/", "/auth/")
+}
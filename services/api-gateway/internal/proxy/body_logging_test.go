@@ -0,0 +1,98 @@
+package proxy_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/proxy"
+)
+
+func TestServiceProxy_BodyLogging(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"a1","access_token":"upstream-token-123","notes":"`+strings.Repeat("n", 200)+`"}`)
+	}))
+	defer backend.Close()
+
+	newRouter := func(t *testing.T, logging *proxy.BodyLogging) (*gin.Engine, *bytes.Buffer) {
+		logs := &bytes.Buffer{}
+		logger := logrus.New()
+		logger.SetFormatter(&logrus.JSONFormatter{})
+		logger.SetOutput(logs)
+
+		serviceProxy := proxy.NewServiceProxy("analysis", backend.URL, 5*time.Second, logger)
+		if logging != nil {
+			serviceProxy.SetBodyLogging(*logging)
+		}
+
+		router := setupTestRouter()
+		for _, route := range []string{"/api/v1/analysis/start/:projectId", "/api/v1/analysis/plan/:projectId", "/api/v1/auth/service-login"} {
+			route := route
+			router.POST(route, func(c *gin.Context) {
+				serviceProxy.ProxyRequest(c, http.MethodPost, route)
+			})
+		}
+		return router, logs
+	}
+
+	post := func(router *gin.Engine, path string) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"languages":["go"],"password":"hunter2"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	bodyEntries := func(logs *bytes.Buffer) []map[string]interface{} {
+		var entries []map[string]interface{}
+		for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+			var entry map[string]interface{}
+			if json.Unmarshal(line, &entry) == nil && entry["msg"] == "Proxied request bodies" {
+				entries = append(entries, entry)
+			}
+		}
+		return entries
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		router, logs := newRouter(t, nil)
+		post(router, "/api/v1/analysis/start/p1")
+		assert.Empty(t, bodyEntries(logs))
+	})
+
+	t.Run("logs configured routes redacted and truncated", func(t *testing.T) {
+		router, logs := newRouter(t, &proxy.BodyLogging{
+			Routes:   []string{"/api/v1/analysis/start/:projectId", "/api/v1/auth/service-login"},
+			MaxBytes: 64,
+		})
+		post(router, "/api/v1/analysis/start/p1")
+		post(router, "/api/v1/analysis/plan/p1")
+		post(router, "/api/v1/auth/service-login")
+
+		entries := bodyEntries(logs)
+		require.Len(t, entries, 1, "only the configured non-auth route is logged")
+		entry := entries[0]
+		assert.Equal(t, "/api/v1/analysis/start/:projectId", entry["route"])
+		assert.Equal(t, float64(http.StatusOK), entry["status_code"])
+		assert.Equal(t, `{"languages":["go"],"password":"[REDACTED]"}`, entry["request_body"])
+
+		response := entry["response_body"].(string)
+		assert.True(t, strings.HasPrefix(response, `{"id":"a1","access_token":"[REDACTED]"`), response)
+		assert.True(t, strings.HasSuffix(response, "...[truncated]"), response)
+		assert.Len(t, response, 64+len("...[truncated]"))
+
+		assert.NotContains(t, logs.String(), "hunter2")
+		assert.NotContains(t, logs.String(), "upstream-token-123")
+	})
+}
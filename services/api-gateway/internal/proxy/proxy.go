@@ -3,38 +3,64 @@ package proxy
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/shared/contextkeys"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
 )
 
+var (
+	// ErrInvalidPath is returned for a target path that would escape the
+	// service's base URL
+	ErrInvalidPath = errors.New("invalid request path")
+
+	// errReadResponse marks a failure reading a backend response body
+	errReadResponse = errors.New("failed to read response")
+)
+
+// StatusClientClosedRequest is the non-standard status, as used by nginx,
+// recorded for requests whose client went away before the service answered
+const StatusClientClosedRequest = 499
+
 // ServiceProxy handles proxying requests to backend services
 type ServiceProxy struct {
-	name    string
-	baseURL string
-	timeout time.Duration
-	client  *http.Client
-	logger  *logrus.Logger
+	name     string
+	baseURL  string
+	timeout  atomic.Int64 // time.Duration bounding each backend call
+	client   *http.Client
+	inflight singleflight.Group
+	logger   *logrus.Logger
+
+	// bodyLogRoutes are the lower-cased routes whose bodies are logged
+	bodyLogRoutes   map[string]bool
+	bodyLogMaxBytes int
 }
 
-// NewServiceProxy creates a new service proxy
+// NewServiceProxy creates a new service proxy. A proxy with an empty
+// baseURL stands in for an unconfigured service and answers 503.
 func NewServiceProxy(name, baseURL string, timeout time.Duration, logger *logrus.Logger) *ServiceProxy {
 	if timeout == 0 {
 		timeout = 30 * time.Second
 	}
 
-	return &ServiceProxy{
+	p := &ServiceProxy{
 		name:    name,
 		baseURL: strings.TrimSuffix(baseURL, "/"),
-		timeout: timeout,
 		client: &http.Client{
-			Timeout: timeout,
 			Transport: &http.Transport{
 				MaxIdleConns:        100,
 				MaxIdleConnsPerHost: 10,
@@ -43,82 +69,315 @@ func NewServiceProxy(name, baseURL string, timeout time.Duration, logger *logrus
 		},
 		logger: logger,
 	}
+	p.timeout.Store(int64(timeout))
+	return p
+}
+
+// SetTimeout changes how long a backend call may take, including reading
+// the response, for calls started afterwards; 0 restores the 30s default
+func (p *ServiceProxy) SetTimeout(timeout time.Duration) {
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	p.timeout.Store(int64(timeout))
+}
+
+// Timeout returns how long a backend call may take
+func (p *ServiceProxy) Timeout() time.Duration {
+	return time.Duration(p.timeout.Load())
 }
 
 // ProxyRequest proxies a request to the backend service
 func (p *ServiceProxy) ProxyRequest(c *gin.Context, method, path string) {
+	req, bodyBytes, ok := p.newUpstreamRequest(c, method, path)
+	if !ok {
+		return
+	}
+	method, targetURL := req.Method, req.URL.String()
+
+	// Execute request; concurrent identical reads share one upstream call
+	var resp *upstreamResponse
+	var err error
+	if coalescable(c.Request, method) {
+		key := strings.Join([]string{method, targetURL, contextkeys.UserID.GetString(c), c.GetHeader("Accept")}, "\n")
+		if utils.LinksRequested(c.Request) {
+			// Links are absolute, so responses for other hosts differ
+			key += "\n" + utils.BaseURL(c.Request)
+		}
+		var result interface{}
+		var shared bool
+		result, err, shared = p.inflight.Do(key, func() (interface{}, error) {
+			// Detached so one caller going away doesn't fail the others
+			return p.do(req.WithContext(context.WithoutCancel(req.Context())))
+		})
+		if err == nil {
+			resp = result.(*upstreamResponse)
+			if shared {
+				resp = resp.clone()
+			}
+		}
+	} else {
+		resp, err = p.do(req)
+	}
+	if p.logsBodies(c.FullPath()) {
+		p.logBodies(c, method, bodyBytes, resp)
+	}
+	if err != nil {
+		p.writeUpstreamError(c, err, targetURL, method)
+		return
+	}
+
+	// Copy response headers
+	for key, values := range resp.header {
+		for _, value := range values {
+			c.Header(key, value)
+		}
+	}
+
+	// Write response; a HEAD response carries the headers only
+	if method == http.MethodHead {
+		c.Status(resp.status)
+		return
+	}
+	c.Data(resp.status, resp.header.Get("Content-Type"), resp.body)
+}
+
+// StreamRequest proxies a request whose response the service writes as it
+// goes, such as NDJSON progress. The proxy's timeout bounds waiting for the
+// response headers only; the body is passed on chunk by chunk, each flushed
+// to the client, for as long as the service writes and the client listens,
+// past the server's write timeout.
+func (p *ServiceProxy) StreamRequest(c *gin.Context, method, path string) {
+	req, _, ok := p.newUpstreamRequest(c, method, path)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	timer := time.AfterFunc(p.Timeout(), cancel)
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if !timer.Stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		err = fmt.Errorf("%w: no response within %s", context.DeadlineExceeded, p.Timeout())
+	}
+	if err != nil {
+		p.writeUpstreamError(c, err, req.URL.String(), req.Method)
+		return
+	}
+	defer resp.Body.Close()
+
+	// The write timeout bounds ordinary responses, not a stream that lasts
+	// as long as the run; writers that can't set deadlines have none
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	for key, values := range resp.Header {
+		for _, value := range values {
+			c.Header(key, value)
+		}
+	}
+	c.Status(resp.StatusCode)
+	c.Writer.WriteHeaderNow()
+	if req.Method == http.MethodHead {
+		return
+	}
+
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(chunk)
+		if n > 0 {
+			if _, err := c.Writer.Write(chunk[:n]); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+		if errors.Is(err, io.EOF) || c.Request.Context().Err() != nil {
+			return
+		}
+		if err != nil {
+			// The status is already sent; all that's left is to stop
+			p.logger.WithError(err).WithFields(logrus.Fields{
+				"service":    p.name,
+				"url":        req.URL.String(),
+				"request_id": utils.RequestIDFromContext(c.Request.Context()),
+			}).Error("Service stream ended early")
+			return
+		}
+	}
+}
+
+// newUpstreamRequest builds the request forwarding c to the service's path,
+// returning it with the body it carries. When c can't be forwarded it is
+// answered here and ok is false.
+func (p *ServiceProxy) newUpstreamRequest(c *gin.Context, method, path string) (req *http.Request, bodyBytes []byte, ok bool) {
+	if !p.Configured() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Service not configured",
+			"service": p.name,
+			"message": fmt.Sprintf("The %s service is not configured on this gateway; set services.%s.url to enable it", p.name, p.name),
+		})
+		return nil, nil, false
+	}
+
+	// GET routes also serve HEAD; the router answers other methods
+	if c.Request.Method == http.MethodHead && method == http.MethodGet {
+		method = http.MethodHead
+	}
+
 	// Build target URL
-	targetURL := p.buildTargetURL(path, c.Request.URL.Query())
+	targetURL, err := p.buildTargetURL(path, c.Params, c.Request.URL.Query())
+	if err != nil {
+		p.logger.WithError(err).WithFields(logrus.Fields{
+			"service":    p.name,
+			"path":       c.Request.URL.Path,
+			"request_id": utils.RequestIDFromContext(c.Request.Context()),
+		}).Warn("Rejected request path")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request path"})
+		return nil, nil, false
+	}
 
-	// Create request body
+	// Create request body; HEAD requests have none
 	var body io.Reader
-	if c.Request.Body != nil {
-		bodyBytes, err := io.ReadAll(c.Request.Body)
+	if c.Request.Body != nil && method != http.MethodHead {
+		bodyBytes, err = io.ReadAll(c.Request.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit)})
+			return nil, nil, false
+		}
 		if err != nil {
 			p.logger.WithError(err).Error("Failed to read request body")
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
-			return
+			return nil, nil, false
 		}
 		body = bytes.NewReader(bodyBytes)
 	}
 
 	// Create new request
-	req, err := http.NewRequestWithContext(c.Request.Context(), method, targetURL, body)
+	req, err = http.NewRequestWithContext(c.Request.Context(), method, targetURL, body)
 	if err != nil {
 		p.logger.WithError(err).Error("Failed to create request")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-		return
+		return nil, nil, false
 	}
 
 	// Copy headers
 	p.copyHeaders(c.Request.Header, req.Header)
 
 	// Add custom headers
-	req.Header.Set("X-Forwarded-For", c.ClientIP())
-	req.Header.Set("X-Request-ID", c.GetString("request_id"))
-	req.Header.Set("X-User-ID", c.GetString("user_id"))
+	req.Header.Set("X-Forwarded-For", middleware.ClientIP(c))
+	if requestID := utils.RequestIDFromContext(req.Context()); requestID != "" {
+		req.Header.Set(utils.RequestIDHeader, requestID)
+	}
+	req.Header.Set("X-User-ID", contextkeys.UserID.GetString(c))
+	setForwardedURL(req.Header, c, path)
+	return req, bodyBytes, true
+}
 
-	// Execute request
-	resp, err := p.client.Do(req)
+// writeUpstreamError answers c for a service call that failed
+func (p *ServiceProxy) writeUpstreamError(c *gin.Context, err error, targetURL, method string) {
+	fields := logrus.Fields{
+		"service":    p.name,
+		"url":        targetURL,
+		"method":     method,
+		"request_id": utils.RequestIDFromContext(c.Request.Context()),
+	}
+	status := upstreamErrorStatus(err)
+	switch {
+	case status == StatusClientClosedRequest:
+		// Nobody is left to answer; record the status for logs and metrics
+		p.logger.WithFields(fields).Info("Client closed request before the service responded")
+		c.Status(status)
+	case status == http.StatusGatewayTimeout:
+		p.logger.WithError(err).WithFields(fields).Error("Service timed out")
+		c.JSON(status, gin.H{"error": "Service timeout"})
+	case errors.Is(err, errReadResponse):
+		p.logger.WithError(err).WithFields(fields).Error("Failed to read response body")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read response"})
+	default:
+		p.logger.WithError(err).WithFields(fields).Error("Failed to execute request")
+		c.JSON(status, gin.H{"error": "Service unavailable"})
+	}
+}
+
+// upstreamResponse is a fully read backend response
+type upstreamResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// clone copies the response so each caller sharing it gets its own
+func (r *upstreamResponse) clone() *upstreamResponse {
+	return &upstreamResponse{
+		status: r.status,
+		header: r.header.Clone(),
+		body:   append([]byte(nil), r.body...),
+	}
+}
+
+// do executes the request and reads the whole response within the timeout
+func (p *ServiceProxy) do(req *http.Request) (*upstreamResponse, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), p.Timeout())
+	defer cancel()
+	resp, err := p.client.Do(req.WithContext(ctx))
 	if err != nil {
-		p.logger.WithError(err).WithFields(logrus.Fields{
-			"service": p.name,
-			"url":     targetURL,
-			"method":  method,
-		}).Error("Failed to execute request")
-		
-		if err == context.DeadlineExceeded {
-			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Service timeout"})
-		} else {
-			c.JSON(http.StatusBadGateway, gin.H{"error": "Service unavailable"})
-		}
-		return
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// Read response body
-	respBody, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		p.logger.WithError(err).Error("Failed to read response body")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read response"})
-		return
+		return nil, fmt.Errorf("%w: %w", errReadResponse, err)
 	}
 
-	// Copy response headers
-	for key, values := range resp.Header {
-		for _, value := range values {
-			c.Header(key, value)
-		}
+	return &upstreamResponse{status: resp.StatusCode, header: resp.Header, body: body}, nil
+}
+
+// upstreamErrorStatus returns the status answering a failed service call:
+// 499 when the client went away, 504 when the call timed out and 502 when
+// the service couldn't be reached. The client wraps these in *url.Error, so
+// they are matched with errors.Is rather than compared.
+func upstreamErrorStatus(err error) int {
+	if errors.Is(err, context.Canceled) {
+		return StatusClientClosedRequest
 	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
+// coalescable reports whether a request is a plain read whose response can
+// be shared with identical requests in flight at the same time
+func coalescable(r *http.Request, method string) bool {
+	if method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+	if r.ContentLength > 0 {
+		return false
+	}
+	cacheControl := strings.ToLower(r.Header.Get("Cache-Control"))
+	return !strings.Contains(cacheControl, "no-cache") && !strings.Contains(cacheControl, "no-store")
+}
 
-	// Write response
-	c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
+// Configured reports whether the proxy has a backend to forward to
+func (p *ServiceProxy) Configured() bool {
+	return p.baseURL != ""
 }
 
 // HealthCheck checks if the service is healthy
 func (p *ServiceProxy) HealthCheck(ctx context.Context) error {
 	healthURL := fmt.Sprintf("%s/health", p.baseURL)
-	
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+	defer cancel()
+
 	req, err := http.NewRequestWithContext(ctx, "GET", healthURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create health check request: %w", err)
@@ -137,11 +396,48 @@ func (p *ServiceProxy) HealthCheck(ctx context.Context) error {
 	return nil
 }
 
-// buildTargetURL builds the target URL for the backend service
-func (p *ServiceProxy) buildTargetURL(path string, query url.Values) string {
-	// Replace path parameters
-	path = p.replacePathParams(path)
-	
+// setForwardedURL tells the backend the scheme, host and path prefix the
+// client reached the request at, for the absolute links of its responses.
+// The prefix is what the gateway route has in front of the backend path
+// pattern, as /api/v1 in front of /analysis/status/:analysisId, after any
+// prefix of a proxy in front of the gateway.
+func setForwardedURL(header http.Header, c *gin.Context, path string) {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	header.Set("X-Forwarded-Proto", scheme)
+	host := c.Request.Host
+	if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+		host = forwarded
+	}
+	header.Set("X-Forwarded-Host", host)
+
+	prefix := utils.ForwardedPrefix(c.Request)
+	route := strings.Split(strings.Trim(c.FullPath(), "/"), "/")
+	backend := strings.Split(strings.Trim(path, "/"), "/")
+	if c.FullPath() != "" && len(route) > len(backend) {
+		prefix += "/" + strings.Join(route[:len(route)-len(backend)], "/")
+	}
+	if prefix != "" {
+		header.Set(utils.ForwardedPrefixHeader, prefix)
+	} else {
+		header.Del(utils.ForwardedPrefixHeader)
+	}
+}
+
+// buildTargetURL builds the target URL for the backend service. The path
+// may reference route parameters as :name segments, which are filled in
+// from params. The result is normalized before it is joined to the base URL.
+func (p *ServiceProxy) buildTargetURL(path string, params gin.Params, query url.Values) (string, error) {
+	path, err := normalizePath(p.replacePathParams(path, params))
+	if err != nil {
+		return "", err
+	}
+
 	// Build URL
 	targetURL := fmt.Sprintf("%s%s", p.baseURL, path)
 	
@@ -150,14 +446,52 @@ func (p *ServiceProxy) buildTargetURL(path string, query url.Values) string {
 		targetURL = fmt.Sprintf("%s?%s", targetURL, query.Encode())
 	}
 	
-	return targetURL
+	return targetURL, nil
+}
+
+// replacePathParams replaces :name segments with the escaped values of the
+// matching route parameters, so a value can't add segments of its own.
+// The slashes of a catch-all parameter's value are escaped too, after its
+// leading one. Segments naming no parameter are left as they are.
+func (p *ServiceProxy) replacePathParams(path string, params gin.Params) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, ":") {
+			continue
+		}
+		if value, ok := params.Get(segment[1:]); ok {
+			segments[i] = url.PathEscape(strings.TrimPrefix(value, "/"))
+		}
+	}
+	return strings.Join(segments, "/")
 }
 
-// replacePathParams replaces Gin path parameters with actual values
-func (p *ServiceProxy) replacePathParams(path string) string {
-	// This is a simplified implementation
-	// In production, you'd want more sophisticated path parameter handling
-	return path
+// normalizePath collapses duplicate slashes, drops "." segments and the
+// trailing slash, and rejects ".." segments, escaped or not, so a path
+// always stays below the service's base URL
+func normalizePath(path string) (string, error) {
+	var cleaned []string
+	for _, segment := range strings.Split(path, "/") {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidPath, err)
+		}
+		switch decoded {
+		case "", ".":
+			continue
+		}
+		// An escaped slash mustn't smuggle a ".." past this check
+		for _, part := range strings.Split(decoded, "/") {
+			if part == ".." {
+				return "", fmt.Errorf("%w: parent directory segment", ErrInvalidPath)
+			}
+		}
+		if strings.ContainsAny(decoded, "\\\x00") {
+			return "", fmt.Errorf("%w: invalid character in segment", ErrInvalidPath)
+		}
+		cleaned = append(cleaned, segment)
+	}
+	return "/" + strings.Join(cleaned, "/"), nil
 }
 
 // copyHeaders copies headers from source to destination
@@ -204,14 +538,23 @@ func (p *ServiceProxy) ProxyWebSocket(c *gin.Context, targetPath string) {
 	c.JSON(http.StatusNotImplemented, gin.H{"error": "WebSocket proxy not implemented"})
 }
 
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
 // CircuitBreaker wraps the proxy with circuit breaker functionality
 type CircuitBreaker struct {
-	proxy           *ServiceProxy
+	proxy            *ServiceProxy
 	failureThreshold int
 	resetTimeout     time.Duration
-	failures         int
-	lastFailureTime  time.Time
-	state            string // "closed", "open", "half-open"
+
+	mu              sync.Mutex
+	failures        int
+	lastFailureTime time.Time
+	state           string // "closed", "open", "half-open"
 }
 
 // NewCircuitBreaker creates a new circuit breaker
@@ -220,64 +563,81 @@ func NewCircuitBreaker(proxy *ServiceProxy, failureThreshold int, resetTimeout t
 		proxy:            proxy,
 		failureThreshold: failureThreshold,
 		resetTimeout:     resetTimeout,
-		state:            "closed",
+		state:            BreakerClosed,
 	}
 }
 
-// Call executes the request with circuit breaker protection
+// Call executes the request with circuit breaker protection. The proxy's
+// own timeout bounds the request, and 5xx responses count as failures.
 func (cb *CircuitBreaker) Call(c *gin.Context, method, path string) {
-	// Check circuit breaker state
-	if cb.state == "open" {
-		if time.Since(cb.lastFailureTime) > cb.resetTimeout {
-			cb.state = "half-open"
-			cb.failures = 0
-		} else {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service circuit breaker is open"})
-			return
-		}
+	cb.call(c, method, path, cb.proxy.ProxyRequest)
+}
+
+// Stream is Call for responses streamed with StreamRequest
+func (cb *CircuitBreaker) Stream(c *gin.Context, method, path string) {
+	cb.call(c, method, path, cb.proxy.StreamRequest)
+}
+
+func (cb *CircuitBreaker) call(c *gin.Context, method, path string, forward func(c *gin.Context, method, path string)) {
+	if !cb.allow() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service circuit breaker is open"})
+		return
 	}
 
-	// Create a channel to capture the result
-	done := make(chan bool, 1)
-	
-	// Execute request in goroutine
-	go func() {
-		cb.proxy.ProxyRequest(c, method, path)
-		done <- true
-	}()
-
-	// Wait for completion or timeout
-	select {
-	case <-done:
-		// Request completed
-		if c.Writer.Status() >= 500 {
-			cb.recordFailure()
-		} else {
-			cb.recordSuccess()
-		}
-	case <-time.After(cb.proxy.timeout):
-		// Timeout
+	forward(c, method, path)
+
+	if c.Writer.Status() >= 500 {
 		cb.recordFailure()
-		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Service timeout"})
+	} else {
+		cb.recordSuccess()
+	}
+}
+
+// State returns the breaker's current state
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// allow reports whether a request may pass, moving an open breaker to
+// half-open once the reset timeout has elapsed
+func (cb *CircuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == BreakerOpen {
+		if time.Since(cb.lastFailureTime) <= cb.resetTimeout {
+			return false
+		}
+		cb.state = BreakerHalfOpen
+		cb.failures = 0
 	}
+	return true
 }
 
 // recordFailure records a failure and potentially opens the circuit
 func (cb *CircuitBreaker) recordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
 	cb.failures++
 	cb.lastFailureTime = time.Now()
-	
+
 	if cb.failures >= cb.failureThreshold {
-		cb.state = "open"
+		cb.state = BreakerOpen
 		cb.proxy.logger.Warnf("Circuit breaker opened for service %s", cb.proxy.name)
 	}
 }
 
 // recordSuccess records a success and potentially closes the circuit
 func (cb *CircuitBreaker) recordSuccess() {
-	if cb.state == "half-open" {
-		cb.state = "closed"
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == BreakerHalfOpen {
+		cb.state = BreakerClosed
 		cb.failures = 0
 		cb.proxy.logger.Infof("Circuit breaker closed for service %s", cb.proxy.name)
 	}
-}
\ No newline at end of file
+}
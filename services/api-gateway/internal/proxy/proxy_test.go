@@ -0,0 +1,364 @@
+package proxy_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/proxy"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+func setupTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return gin.New()
+}
+
+func TestServiceProxy_ForwardsRequestID(t *testing.T) {
+	received := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(utils.RequestIDHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	serviceProxy := proxy.NewServiceProxy("analysis", backend.URL, 5*time.Second, logger)
+
+	router := setupTestRouter()
+	router.Use(middleware.RequestID())
+	router.GET("/analysis/status", func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodGet, "/status")
+	})
+
+	t.Run("forwards incoming ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/analysis/status", nil)
+		req.Header.Set(utils.RequestIDHeader, "req-123")
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "req-123", <-received)
+		assert.Equal(t, "req-123", w.Header().Get(utils.RequestIDHeader))
+	})
+
+	t.Run("forwards generated ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/analysis/status", nil)
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		requestID := w.Header().Get(utils.RequestIDHeader)
+		require.NotEmpty(t, requestID)
+		assert.Equal(t, requestID, <-received)
+	})
+}
+
+func TestServiceProxy_MethodHandling(t *testing.T) {
+	methods := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods <- r.Method
+		w.Header().Set("X-Total-Count", "3")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"running"}`))
+	}))
+	defer backend.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	serviceProxy := proxy.NewServiceProxy("analysis", backend.URL, 5*time.Second, logger)
+
+	router := setupTestRouter()
+	status := func(c *gin.Context) { serviceProxy.ProxyRequest(c, http.MethodGet, "/analysis/status") }
+	router.GET("/status", status)
+	router.HEAD("/status", status)
+
+	t.Run("HEAD passthrough", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/status", nil))
+
+		assert.Equal(t, http.MethodHead, <-methods)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "3", w.Header().Get("X-Total-Count"))
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+		assert.Equal(t, http.MethodGet, <-methods)
+		assert.JSONEq(t, `{"status":"running"}`, w.Body.String())
+	})
+}
+
+func TestServiceProxy_Unconfigured(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	serviceProxy := proxy.NewServiceProxy("visualization", "", 0, logger)
+	assert.False(t, serviceProxy.Configured())
+
+	router := setupTestRouter()
+	router.GET("/visualization/layouts", func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodGet, "/visualization/layouts")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/visualization/layouts", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "Service not configured", body["error"])
+	assert.Equal(t, "visualization", body["service"])
+	assert.Contains(t, body["message"], "services.visualization.url")
+}
+
+func TestServiceProxy_UpstreamErrors(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	received := make(chan struct{}, 1)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL := closed.URL
+	closed.Close()
+
+	route := func(serviceProxy *proxy.ServiceProxy) *gin.Engine {
+		router := setupTestRouter()
+		router.POST("/analysis/start", func(c *gin.Context) {
+			serviceProxy.ProxyRequest(c, http.MethodPost, "/analysis/start")
+		})
+		return router
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		router := route(proxy.NewServiceProxy("analysis", slow.URL, 50*time.Millisecond, logger))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/analysis/start", nil))
+		<-received
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Contains(t, w.Body.String(), "Service timeout")
+	})
+
+	t.Run("client cancelled", func(t *testing.T) {
+		router := route(proxy.NewServiceProxy("analysis", slow.URL, 5*time.Second, logger))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-received
+			cancel()
+		}()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/analysis/start", nil).WithContext(ctx))
+
+		assert.Equal(t, proxy.StatusClientClosedRequest, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("connection refused", func(t *testing.T) {
+		router := route(proxy.NewServiceProxy("analysis", closedURL, time.Second, logger))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/analysis/start", nil))
+
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Contains(t, w.Body.String(), "Service unavailable")
+	})
+}
+
+func TestServiceProxy_CoalescesConcurrentReads(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"running","progress":42}`))
+	}))
+	defer backend.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	serviceProxy := proxy.NewServiceProxy("analysis", backend.URL, 5*time.Second, logger)
+
+	router := setupTestRouter()
+	router.GET("/status", func(c *gin.Context) {
+		c.Set("user_id", "user-1")
+		serviceProxy.ProxyRequest(c, http.MethodGet, "/analysis/status")
+	})
+	router.POST("/start", func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodPost, "/analysis/start")
+	})
+
+	fire := func(n int, method, path string) []*httptest.ResponseRecorder {
+		recorders := make([]*httptest.ResponseRecorder, n)
+		var wg sync.WaitGroup
+		for i := range recorders {
+			recorders[i] = httptest.NewRecorder()
+			wg.Add(1)
+			go func(w *httptest.ResponseRecorder) {
+				defer wg.Done()
+				router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+			}(recorders[i])
+		}
+		// Let every request reach the proxy before the backend answers
+		time.Sleep(200 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		return recorders
+	}
+
+	t.Run("identical GETs share one call", func(t *testing.T) {
+		for _, w := range fire(10, http.MethodGet, "/status") {
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"status":"running","progress":42}`, w.Body.String())
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("writes are not coalesced", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		release = make(chan struct{})
+		fire(3, http.MethodPost, "/start")
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestServiceProxy_PathNormalization(t *testing.T) {
+	paths := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.EscapedPath()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	// A trailing slash on the base URL is dropped
+	serviceProxy := proxy.NewServiceProxy("analysis", backend.URL+"/", 5*time.Second, logger)
+
+	router := setupTestRouter()
+	router.GET("/status/:analysisId", func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodGet, "/analysis/status/:analysisId")
+	})
+	router.GET("/messy/:analysisId", func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodGet, "//analysis//./status/:analysisId/")
+	})
+	router.GET("/escape", func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodGet, "/analysis/../admin")
+	})
+	router.GET("/files/:projectId/*filePath", func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodGet, "/metrics/file/:projectId/:filePath")
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantPath   string
+	}{
+		{"parameter", "/status/a1", http.StatusOK, "/analysis/status/a1"},
+		{"duplicate slashes and trailing slash", "/messy/a1", http.StatusOK, "/analysis/status/a1"},
+		{"escaped parameter", "/status/a%20b", http.StatusOK, "/analysis/status/a%20b"},
+		{"parameter traversal", "/status/..", http.StatusBadRequest, ""},
+		{"encoded parameter traversal", "/status/%2e%2e", http.StatusBadRequest, ""},
+		{"target traversal", "/escape", http.StatusBadRequest, ""},
+		{"catch-all parameter", "/files/p1/src/pkg/a.go", http.StatusOK, "/metrics/file/p1/src%2Fpkg%2Fa.go"},
+		{"encoded catch-all parameter", "/files/p1/src%2Fpkg%2Fa.go", http.StatusOK, "/metrics/file/p1/src%2Fpkg%2Fa.go"},
+		{"catch-all traversal", "/files/p1/src/../../admin", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantPath == "" {
+				assert.Empty(t, paths)
+				return
+			}
+			select {
+			case path := <-paths:
+				assert.Equal(t, tt.wantPath, path)
+			case <-time.After(5 * time.Second):
+				t.Fatal("request didn't reach the backend")
+			}
+		})
+	}
+}
+
+func TestServiceProxy_ForwardsRequestURL(t *testing.T) {
+	received := make(chan http.Header, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	serviceProxy := proxy.NewServiceProxy("analysis", backend.URL, 5*time.Second, logger)
+
+	router := setupTestRouter()
+	router.GET("/api/v1/analysis/status/:analysisId", func(c *gin.Context) {
+		serviceProxy.ProxyRequest(c, http.MethodGet, "/analysis/status/:analysisId")
+	})
+
+	tests := []struct {
+		name       string
+		header     map[string]string
+		wantProto  string
+		wantHost   string
+		wantPrefix string
+	}{
+		{"direct", nil, "http", "example.com", "/api/v1"},
+		{"behind a proxy", map[string]string{
+			"X-Forwarded-Proto":  "https",
+			"X-Forwarded-Host":   "sa3d.example.com",
+			"X-Forwarded-Prefix": "/gateway/",
+		}, "https", "sa3d.example.com", "/gateway/api/v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/analysis/status/a1", nil)
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			header := <-received
+			assert.Equal(t, tt.wantProto, header.Get("X-Forwarded-Proto"))
+			assert.Equal(t, tt.wantHost, header.Get("X-Forwarded-Host"))
+			assert.Equal(t, tt.wantPrefix, header.Get(utils.ForwardedPrefixHeader))
+		})
+	}
+}
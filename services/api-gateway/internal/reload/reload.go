@@ -0,0 +1,149 @@
+// Package reload applies configuration changes to a running gateway, so
+// rate limits, CORS rules, service timeouts and feature flags change
+// without a restart.
+package reload
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/proxy"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+)
+
+// RateLimit is the rate and burst of a token bucket
+type RateLimit struct {
+	Rate  rate.Limit
+	Burst int
+}
+
+// String formats the limit as in "10/s, burst 20"
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%g/s, burst %d", float64(l.Rate), l.Burst)
+}
+
+// Settings are the parts of the gateway configuration applied without
+// a restart
+type Settings struct {
+	// RateLimit bounds all requests; Registration and Snippet bound each
+	// client of those routes
+	RateLimit    RateLimit
+	Registration RateLimit
+	Snippet      RateLimit
+	// CORS is the global CORS rules and CORSGroups those of route groups,
+	// by path prefix
+	CORS       middleware.CORSConfig
+	CORSGroups map[string]middleware.CORSConfig
+	// ServiceTimeouts bounds the calls to each backend service, by name
+	ServiceTimeouts map[string]time.Duration
+	// Features are the configured feature flags, before the environment
+	Features map[string]bool
+}
+
+// Targets are the live components settings are applied to; nil ones are
+// left alone
+type Targets struct {
+	RateLimiter  *rate.Limiter
+	Registration *middleware.IPRateLimit
+	Snippet      *middleware.IPRateLimit
+	CORS         *middleware.CORSPolicy
+	Proxies      map[string]*proxy.ServiceProxy
+	Features     *services.FeatureFlags
+	// Environ returns the FEATURE_* variables overriding Features, in the
+	// form of os.Environ; nil uses os.Environ
+	Environ func() []string
+}
+
+// Reloader applies settings to the targets, keeping the settings last
+// applied to report what changed. It is safe for concurrent use.
+type Reloader struct {
+	targets Targets
+	logger  *logrus.Logger
+
+	mu      sync.Mutex
+	current Settings
+}
+
+// New creates a reloader for targets running with the current settings
+func New(targets Targets, current Settings, logger *logrus.Logger) *Reloader {
+	if targets.Environ == nil {
+		targets.Environ = os.Environ
+	}
+	return &Reloader{targets: targets, current: current, logger: logger}
+}
+
+// Apply applies the settings that differ from those last applied, logging
+// and returning a description of each change. Feature flags are applied
+// first; when they don't parse nothing changes.
+func (r *Reloader) Apply(next Settings) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current := r.current
+
+	var changes []string
+	changed := func(format string, args ...interface{}) {
+		change := fmt.Sprintf(format, args...)
+		r.logger.WithField("change", change).Info("Configuration reloaded")
+		changes = append(changes, change)
+	}
+
+	if !maps.Equal(current.Features, next.Features) {
+		if r.targets.Features != nil {
+			if err := r.targets.Features.Reload(next.Features, r.targets.Environ()); err != nil {
+				return nil, fmt.Errorf("failed to reload feature flags: %w", err)
+			}
+		}
+		changed("features: %v -> %v", current.Features, next.Features)
+	}
+
+	if current.RateLimit != next.RateLimit {
+		if r.targets.RateLimiter != nil {
+			r.targets.RateLimiter.SetLimit(next.RateLimit.Rate)
+			r.targets.RateLimiter.SetBurst(next.RateLimit.Burst)
+		}
+		changed("rate_limit: %s -> %s", current.RateLimit, next.RateLimit)
+	}
+	if current.Registration != next.Registration {
+		if r.targets.Registration != nil {
+			r.targets.Registration.SetLimit(next.Registration.Rate, next.Registration.Burst)
+		}
+		changed("rate_limit.registration: %s -> %s", current.Registration, next.Registration)
+	}
+	if current.Snippet != next.Snippet {
+		if r.targets.Snippet != nil {
+			r.targets.Snippet.SetLimit(next.Snippet.Rate, next.Snippet.Burst)
+		}
+		changed("rate_limit.snippet: %s -> %s", current.Snippet, next.Snippet)
+	}
+
+	if !reflect.DeepEqual(current.CORS, next.CORS) || !reflect.DeepEqual(current.CORSGroups, next.CORSGroups) {
+		if r.targets.CORS != nil {
+			r.targets.CORS.Update(next.CORS, next.CORSGroups)
+		}
+		changed("cors: %+v %v -> %+v %v", current.CORS, current.CORSGroups, next.CORS, next.CORSGroups)
+	}
+
+	names := slices.Sorted(maps.Keys(next.ServiceTimeouts))
+	for _, name := range names {
+		timeout := next.ServiceTimeouts[name]
+		if current.ServiceTimeouts[name] == timeout {
+			continue
+		}
+		if serviceProxy, ok := r.targets.Proxies[name]; ok {
+			serviceProxy.SetTimeout(timeout)
+		}
+		changed("services.%s.timeout: %s -> %s", name, current.ServiceTimeouts[name], timeout)
+	}
+
+	r.current = next
+	return changes, nil
+}
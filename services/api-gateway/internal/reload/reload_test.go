@@ -0,0 +1,107 @@
+package reload_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/middleware"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/proxy"
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/reload"
+	"github.com/sa3d-modernized/sa3d/shared/services"
+)
+
+func setupTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return gin.New()
+}
+
+func TestReloader(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	settings := reload.Settings{
+		RateLimit:       reload.RateLimit{Rate: rate.Every(time.Hour), Burst: 1},
+		Registration:    reload.RateLimit{Rate: rate.Every(time.Hour), Burst: 1},
+		CORS:            middleware.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}},
+		ServiceTimeouts: map[string]time.Duration{"analysis": 30 * time.Second},
+		Features:        map[string]bool{services.FlagRequireEmailVerification: false},
+	}
+	limiter := rate.NewLimiter(settings.RateLimit.Rate, settings.RateLimit.Burst)
+	registration := middleware.NewIPRateLimit(settings.Registration.Rate, settings.Registration.Burst)
+	cors := middleware.NewCORSPolicy(settings.CORS)
+	analysisProxy := proxy.NewServiceProxy("analysis", "http://analysis", settings.ServiceTimeouts["analysis"], logger)
+	features := services.NewFeatureFlags(settings.Features)
+	reloader := reload.New(reload.Targets{
+		RateLimiter:  limiter,
+		Registration: registration,
+		CORS:         cors,
+		Proxies:      map[string]*proxy.ServiceProxy{"analysis": analysisProxy},
+		Features:     features,
+		Environ:      func() []string { return nil },
+	}, settings, logger)
+
+	router := setupTestRouter()
+	router.Use(cors.Middleware(), middleware.RateLimiter(limiter, ""))
+	router.GET("/api/v1/projects", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/api/v1/auth/register", registration.Middleware(), func(c *gin.Context) { c.Status(http.StatusCreated) })
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", "https://new.example.com")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/api/v1/projects").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(http.MethodGet, "/api/v1/projects").Code)
+
+	changes, err := reloader.Apply(settings)
+	require.NoError(t, err)
+	assert.Empty(t, changes, "unchanged settings apply nothing")
+
+	next := settings
+	next.RateLimit = reload.RateLimit{Rate: 1000, Burst: 10}
+	next.Registration = reload.RateLimit{Rate: rate.Every(time.Hour), Burst: 3}
+	next.CORS = middleware.CORSConfig{AllowedOrigins: []string{"https://new.example.com"}}
+	next.ServiceTimeouts = map[string]time.Duration{"analysis": 5 * time.Second}
+	next.Features = map[string]bool{services.FlagRequireEmailVerification: true}
+	changes, err = reloader.Apply(next)
+	require.NoError(t, err)
+	assert.Len(t, changes, 5)
+	assert.Contains(t, changes, "rate_limit.registration: 0.0002777777777777778/s, burst 1 -> 0.0002777777777777778/s, burst 3")
+
+	// The bucket refills at the new rate, up to the new burst
+	time.Sleep(20 * time.Millisecond)
+	w := serve(http.MethodGet, "/api/v1/projects")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://new.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/api/v1/auth/register").Code, "request %d", i)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, serve(http.MethodPost, "/api/v1/auth/register").Code)
+	assert.Equal(t, 5*time.Second, analysisProxy.Timeout())
+	assert.True(t, features.RequireEmailVerification())
+
+	t.Run("invalid feature flags change nothing", func(t *testing.T) {
+		reloader := reload.New(reload.Targets{
+			RateLimiter: limiter,
+			Features:    features,
+			Environ:     func() []string { return []string{"FEATURE_BETA=maybe"} },
+		}, next, logger)
+		broken := next
+		broken.RateLimit = reload.RateLimit{Rate: 1, Burst: 1}
+		broken.Features = map[string]bool{}
+		_, err := reloader.Apply(broken)
+		assert.ErrorIs(t, err, services.ErrInvalidFeatureFlag)
+		assert.Equal(t, rate.Limit(1000), limiter.Limit())
+	})
+}
@@ -0,0 +1,148 @@
+// Package telemetry sets up OpenTelemetry trace and metric export for the
+// gateway.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// DefaultMetricsInterval is how often metrics are exported when no
+// interval is configured
+const DefaultMetricsInterval = 30 * time.Second
+
+// ErrInvalidSampleRatio is returned for a sample ratio outside 0 to 1
+var ErrInvalidSampleRatio = errors.New("sample ratio must be between 0 and 1")
+
+// Config configures OpenTelemetry export over OTLP/HTTP. Without an
+// endpoint nothing is exported and the gateway uses no-op providers.
+type Config struct {
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string `mapstructure:"service_name"`
+	// Endpoint is the collector's base URL, such as http://otel-collector:4318;
+	// traces go to /v1/traces and metrics to /v1/metrics below it
+	Endpoint string `mapstructure:"endpoint"`
+	// Headers are sent with every export, typically for collector auth
+	Headers map[string]string `mapstructure:"headers"`
+	// SampleRatio is the fraction of new traces sampled; requests carrying
+	// a sampled parent are always traced
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+	// Metrics exports request metrics alongside traces, as an alternative
+	// to scraping /metrics
+	Metrics struct {
+		Enabled  bool          `mapstructure:"enabled"`
+		Interval time.Duration `mapstructure:"interval"`
+	} `mapstructure:"metrics"`
+}
+
+// Providers are the trace and meter providers set up from a Config. They
+// are also installed as the otel globals.
+type Providers struct {
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+	shutdown       []func(context.Context) error
+}
+
+// Setup creates the providers for the config and installs them globally.
+// With no endpoint, or metrics disabled, the no-op providers are used. The
+// W3C trace context propagator is installed either way, so incoming
+// traceparent headers are continued.
+func Setup(ctx context.Context, config Config, logger *logrus.Logger) (*Providers, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	providers := &Providers{
+		TracerProvider: tracenoop.NewTracerProvider(),
+		MeterProvider:  metricnoop.NewMeterProvider(),
+	}
+	if config.Endpoint == "" {
+		logger.Info("No OpenTelemetry endpoint configured, traces and metrics are not exported")
+		otel.SetTracerProvider(providers.TracerProvider)
+		otel.SetMeterProvider(providers.MeterProvider)
+		return providers, nil
+	}
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return nil, fmt.Errorf("%w: %g", ErrInvalidSampleRatio, config.SampleRatio)
+	}
+
+	res := resource.NewSchemaless(attribute.String("service.name", serviceName(config)))
+	endpoint := strings.TrimRight(config.Endpoint, "/")
+
+	traceExporter, err := otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpointURL(endpoint+"/v1/traces"),
+		otlptracehttp.WithHeaders(config.Headers))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(traceExporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(res),
+	)
+	providers.TracerProvider = tracerProvider
+	providers.shutdown = append(providers.shutdown, tracerProvider.Shutdown)
+
+	if config.Metrics.Enabled {
+		metricExporter, err := otlpmetrichttp.New(ctx,
+			otlpmetrichttp.WithEndpointURL(endpoint+"/v1/metrics"),
+			otlpmetrichttp.WithHeaders(config.Headers))
+		if err != nil {
+			providers.Shutdown(ctx)
+			return nil, fmt.Errorf("failed to create metric exporter: %w", err)
+		}
+		interval := config.Metrics.Interval
+		if interval <= 0 {
+			interval = DefaultMetricsInterval
+		}
+		meterProvider := sdkmetric.NewMeterProvider(
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(interval))),
+			sdkmetric.WithResource(res),
+		)
+		providers.MeterProvider = meterProvider
+		providers.shutdown = append(providers.shutdown, meterProvider.Shutdown)
+	}
+
+	otel.SetTracerProvider(providers.TracerProvider)
+	otel.SetMeterProvider(providers.MeterProvider)
+
+	logger.WithFields(logrus.Fields{
+		"endpoint":     config.Endpoint,
+		"sample_ratio": config.SampleRatio,
+		"metrics":      config.Metrics.Enabled,
+	}).Info("Exporting OpenTelemetry data")
+
+	return providers, nil
+}
+
+// Shutdown flushes buffered spans and metrics and stops exporting
+func (p *Providers) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, shutdown := range p.shutdown {
+		errs = append(errs, shutdown(ctx))
+	}
+	p.shutdown = nil
+	return errors.Join(errs...)
+}
+
+// serviceName returns the configured service name, defaulting to the gateway's
+func serviceName(config Config) string {
+	if config.ServiceName != "" {
+		return config.ServiceName
+	}
+	return "api-gateway"
+}
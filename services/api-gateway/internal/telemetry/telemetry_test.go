@@ -0,0 +1,96 @@
+package telemetry_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/sa3d-modernized/sa3d/services/api-gateway/internal/telemetry"
+)
+
+func TestTelemetrySetup(t *testing.T) {
+	t.Cleanup(func() {
+		otel.SetTracerProvider(tracenoop.NewTracerProvider())
+		otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	})
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+
+	t.Run("no-op when unconfigured", func(t *testing.T) {
+		providers, err := telemetry.Setup(context.Background(), telemetry.Config{}, logger)
+		require.NoError(t, err)
+
+		_, span := providers.TracerProvider.Tracer("test").Start(context.Background(), "op")
+		assert.False(t, span.IsRecording())
+		span.End()
+		assert.Equal(t, providers.TracerProvider, otel.GetTracerProvider())
+		assert.NoError(t, providers.Shutdown(context.Background()))
+	})
+
+	t.Run("exports to the configured endpoint", func(t *testing.T) {
+		var mu sync.Mutex
+		exports := make(map[string]string)
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			exports[r.URL.Path] = r.Header.Get("X-Collector-Token")
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer collector.Close()
+
+		config := telemetry.Config{
+			// The signal paths are joined without doubling the slash
+			Endpoint:    collector.URL + "/",
+			Headers:     map[string]string{"X-Collector-Token": "secret"},
+			SampleRatio: 1,
+		}
+		config.Metrics.Enabled = true
+		config.Metrics.Interval = time.Hour
+		providers, err := telemetry.Setup(context.Background(), config, logger)
+		require.NoError(t, err)
+
+		assert.IsType(t, &sdktrace.TracerProvider{}, providers.TracerProvider)
+		assert.IsType(t, &sdkmetric.MeterProvider{}, providers.MeterProvider)
+		assert.Equal(t, providers.TracerProvider, otel.GetTracerProvider())
+
+		_, span := otel.Tracer("test").Start(context.Background(), "op")
+		assert.True(t, span.IsRecording())
+		span.End()
+		counter, err := otel.Meter("test").Int64Counter("ops")
+		require.NoError(t, err)
+		counter.Add(context.Background(), 1)
+
+		require.NoError(t, providers.Shutdown(context.Background()), "shutdown flushes")
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, map[string]string{"/v1/traces": "secret", "/v1/metrics": "secret"}, exports)
+	})
+
+	t.Run("samples by ratio", func(t *testing.T) {
+		providers, err := telemetry.Setup(context.Background(), telemetry.Config{Endpoint: "http://127.0.0.1:1", SampleRatio: 0}, logger)
+		require.NoError(t, err)
+		defer providers.Shutdown(context.Background())
+
+		_, span := providers.TracerProvider.Tracer("test").Start(context.Background(), "op")
+		assert.False(t, span.IsRecording(), "a ratio of 0 samples no new traces")
+		span.End()
+		assert.IsType(t, metricnoop.MeterProvider{}, providers.MeterProvider, "metrics are off unless enabled")
+	})
+
+	t.Run("rejects an invalid ratio", func(t *testing.T) {
+		_, err := telemetry.Setup(context.Background(), telemetry.Config{Endpoint: "http://127.0.0.1:1", SampleRatio: 1.5}, logger)
+		assert.ErrorIs(t, err, telemetry.ErrInvalidSampleRatio)
+	})
+}
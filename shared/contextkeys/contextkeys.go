@@ -0,0 +1,73 @@
+// Package contextkeys names the values middleware stores on a request's gin
+// context, so the packages setting and reading them agree on their keys.
+package contextkeys
+
+// Key names a value stored on a request context. Gin keys its values by
+// plain strings, so a Key reads and writes through the methods below rather
+// than being passed to gin directly.
+type Key string
+
+const (
+	// RequestID is the request's ID, as also carried in utils.RequestIDHeader
+	RequestID Key = "request_id"
+	// UserID is the authenticated user's ID
+	UserID Key = "user_id"
+	// User is the authenticated *models.User, set by the session-backed auth
+	User Key = "user"
+	// Email is the authenticated user's email address
+	Email Key = "email"
+	// Username is the authenticated user's username
+	Username Key = "username"
+	// Role is the authenticated user's primary role
+	Role Key = "role"
+	// Roles are all of the authenticated user's roles as a []string. Every
+	// auth path sets it, along with the most privileged of them as Role.
+	Roles Key = "roles"
+	// SessionToken is the token the request authenticated with
+	SessionToken Key = "session_token"
+	// Identity is the kind of credential the request authenticated with,
+	// a user's token or an API key
+	Identity Key = "identity"
+)
+
+// Store is the part of a gin context values are kept in
+type Store interface {
+	Set(key string, value any)
+	Get(key string) (value any, exists bool)
+}
+
+// Set stores value under the key
+func (k Key) Set(store Store, value any) {
+	store.Set(string(k), value)
+}
+
+// Get returns the value stored under the key
+func (k Key) Get(store Store) (any, bool) {
+	return store.Get(string(k))
+}
+
+// GetString returns the string stored under the key, or "" if there is none
+func (k Key) GetString(store Store) string {
+	value, _ := store.Get(string(k))
+	s, _ := value.(string)
+	return s
+}
+
+// GetStrings returns the string slice stored under the key. A []any of
+// strings, as decoded from JSON, is converted; other values yield nil.
+func (k Key) GetStrings(store Store) []string {
+	value, _ := store.Get(string(k))
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []any:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
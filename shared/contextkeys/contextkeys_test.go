@@ -0,0 +1,35 @@
+package contextkeys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mapStore is a Store backed by a map, as gin keeps its values
+type mapStore map[string]any
+
+func (s mapStore) Set(key string, value any) { s[key] = value }
+
+func (s mapStore) Get(key string) (any, bool) {
+	value, ok := s[key]
+	return value, ok
+}
+
+func TestKey(t *testing.T) {
+	store := mapStore{}
+	UserID.Set(store, "user-1")
+	assert.Equal(t, "user-1", store["user_id"])
+	assert.Equal(t, "user-1", UserID.GetString(store))
+	assert.Empty(t, Email.GetString(store))
+
+	Roles.Set(store, []string{"admin"})
+	assert.Equal(t, []string{"admin"}, Roles.GetStrings(store))
+
+	// Roles decoded from token claims
+	Roles.Set(store, []any{"user", 7, "admin"})
+	assert.Equal(t, []string{"user", "admin"}, Roles.GetStrings(store))
+
+	Roles.Set(store, "admin")
+	assert.Nil(t, Roles.GetStrings(store))
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Serialization formats
+const (
+	FormatJSON     = "json"
+	FormatProtobuf = "protobuf"
+)
+
+// Content types identifying the format of an encoded event. Producers send
+// them in the ContentTypeHeader message header so consumers can pick a codec.
+const (
+	ContentTypeHeader   = "content-type"
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Codec encodes and decodes events in one wire format
+type Codec interface {
+	Format() string
+	ContentType() string
+	Marshal(event *Event) ([]byte, error)
+	Unmarshal(data []byte) (*Event, error)
+}
+
+// NewCodec returns the codec for a format; an empty format selects JSON
+func NewCodec(format string) (Codec, error) {
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		return JSONCodec{}, nil
+	case FormatProtobuf, "proto":
+		return ProtobufCodec{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+// Decode decodes an event using the codec matching its content type.
+// Messages without a content type predate the header and are JSON.
+func Decode(contentType string, data []byte) (*Event, error) {
+	switch contentType {
+	case "", ContentTypeJSON:
+		return JSONCodec{}.Unmarshal(data)
+	case ContentTypeProtobuf:
+		return ProtobufCodec{}.Unmarshal(data)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, contentType)
+	}
+}
+
+// JSONCodec encodes events as JSON
+type JSONCodec struct{}
+
+// Format implements Codec
+func (JSONCodec) Format() string { return FormatJSON }
+
+// ContentType implements Codec
+func (JSONCodec) ContentType() string { return ContentTypeJSON }
+
+// Marshal implements Codec
+func (JSONCodec) Marshal(event *Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// Unmarshal implements Codec
+func (JSONCodec) Unmarshal(data []byte) (*Event, error) {
+	var raw struct {
+		SchemaVersion int             `json:"schema_version"`
+		Type          string          `json:"event_type"`
+		AnalysisID    string          `json:"analysis_id"`
+		Timestamp     json.RawMessage `json:"timestamp"`
+		Sequence      uint64          `json:"sequence"`
+		Data          json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+	}
+	// Events published before the version field existed match version 1
+	if raw.SchemaVersion == 0 {
+		raw.SchemaVersion = 1
+	}
+	if err := checkVersion(raw.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	event := &Event{
+		SchemaVersion: raw.SchemaVersion,
+		Type:          raw.Type,
+		AnalysisID:    raw.AnalysisID,
+		Sequence:      raw.Sequence,
+	}
+	if len(raw.Timestamp) > 0 {
+		if err := json.Unmarshal(raw.Timestamp, &event.Timestamp); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+		}
+	}
+
+	payload, err := decodePayload(raw.Type, raw.Data, json.Unmarshal)
+	if err != nil {
+		return nil, err
+	}
+	event.Data = payload
+
+	return event, nil
+}
@@ -0,0 +1,53 @@
+package events
+
+import "sync"
+
+// DefaultDeduplicatorCapacity is how many analyses a Deduplicator tracks by
+// default
+const DefaultDeduplicatorCapacity = 10000
+
+// Deduplicator recognizes events delivered more than once, as retried
+// publishing and at-least-once consumption do, by the sequence numbers of
+// their analysis. Events of an analysis must be seen in order, as they are
+// on the analysis's partition. It tracks a bounded number of analyses,
+// forgetting those it saw first. It is safe for concurrent use.
+type Deduplicator struct {
+	mu       sync.Mutex
+	capacity int
+	last     map[string]uint64
+	order    []string
+}
+
+// NewDeduplicator creates a deduplicator tracking up to capacity analyses;
+// a capacity below 1 uses DefaultDeduplicatorCapacity
+func NewDeduplicator(capacity int) *Deduplicator {
+	if capacity < 1 {
+		capacity = DefaultDeduplicatorCapacity
+	}
+	return &Deduplicator{capacity: capacity, last: make(map[string]uint64)}
+}
+
+// Duplicate reports whether an event with the same or a later sequence of
+// its analysis was seen before, recording the event otherwise. Events
+// without a sequence are never duplicates.
+func (d *Deduplicator) Duplicate(event *Event) bool {
+	if event.Sequence == 0 {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	last, ok := d.last[event.AnalysisID]
+	if ok && event.Sequence <= last {
+		return true
+	}
+	if !ok {
+		d.order = append(d.order, event.AnalysisID)
+		if len(d.order) > d.capacity {
+			delete(d.last, d.order[0])
+			d.order = d.order[1:]
+		}
+	}
+	d.last[event.AnalysisID] = event.Sequence
+	return false
+}
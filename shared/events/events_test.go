@@ -0,0 +1,125 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	timestamp := time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.UTC)
+	payloads := []Payload{
+		AnalysisStarted{ProjectID: "project-1"},
+		AnalysisProgress{ProjectID: "project-1", FilesAnalyzed: 10, TotalFiles: 42},
+		AnalysisCompleted{ProjectID: "project-1", TotalFiles: 42, CompletedAt: timestamp.Add(time.Minute)},
+		AnalysisFailed{ProjectID: "project-1", Error: "failed to get project files"},
+	}
+
+	for _, format := range []string{FormatJSON, FormatProtobuf} {
+		codec, err := NewCodec(format)
+		require.NoError(t, err)
+
+		for _, payload := range payloads {
+			t.Run(format+"/"+payload.EventType(), func(t *testing.T) {
+				event := New("analysis-1", payload)
+				event.Timestamp = timestamp
+				event.Sequence = 3
+
+				data, err := codec.Marshal(event)
+				require.NoError(t, err)
+
+				decoded, err := codec.Unmarshal(data)
+				require.NoError(t, err)
+				assert.Equal(t, event, decoded)
+
+				viaHeader, err := Decode(codec.ContentType(), data)
+				require.NoError(t, err)
+				assert.Equal(t, event, viaHeader)
+			})
+		}
+	}
+}
+
+func TestNewCodec(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", FormatJSON},
+		{"json", FormatJSON},
+		{"protobuf", FormatProtobuf},
+		{"PROTO", FormatProtobuf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			codec, err := NewCodec(tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, codec.Format())
+		})
+	}
+
+	_, err := NewCodec("avro")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestJSONCodec_LegacyEvent(t *testing.T) {
+	legacy := []byte(`{"analysis_id":"analysis-1","event_type":"analysis.completed","timestamp":"2024-03-01T12:30:45Z","data":{"project_id":"project-1","analysis_id":"analysis-1","total_files":3,"completed_at":"2024-03-01T12:30:45Z"}}`)
+
+	event, err := Decode("", legacy)
+	require.NoError(t, err)
+	assert.Equal(t, 1, event.SchemaVersion)
+
+	completed, ok := event.Data.(AnalysisCompleted)
+	require.True(t, ok)
+	assert.Equal(t, "project-1", completed.ProjectID)
+	assert.Equal(t, 3, completed.TotalFiles)
+}
+
+func TestDecode_Errors(t *testing.T) {
+	codec := JSONCodec{}
+
+	future := New("analysis-1", AnalysisStarted{ProjectID: "p"})
+	future.SchemaVersion = SchemaVersion + 1
+	data, err := codec.Marshal(future)
+	require.NoError(t, err)
+	_, err = codec.Unmarshal(data)
+	assert.ErrorIs(t, err, ErrUnsupportedSchemaVersion)
+
+	_, err = codec.Unmarshal([]byte(`{"schema_version":1,"event_type":"analysis.exploded","data":{}}`))
+	assert.ErrorIs(t, err, ErrUnknownEventType)
+
+	_, err = codec.Unmarshal([]byte(`not json`))
+	assert.ErrorIs(t, err, ErrMalformedEvent)
+
+	_, err = ProtobufCodec{}.Unmarshal([]byte{0xff})
+	assert.ErrorIs(t, err, ErrMalformedEvent)
+
+	_, err = Decode("text/plain", nil)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestDeduplicator(t *testing.T) {
+	dedupe := NewDeduplicator(2)
+	event := func(analysisID string, sequence uint64) *Event {
+		e := New(analysisID, AnalysisStarted{})
+		e.Sequence = sequence
+		return e
+	}
+
+	assert.False(t, dedupe.Duplicate(event("a", 1)))
+	assert.False(t, dedupe.Duplicate(event("a", 2)))
+	assert.True(t, dedupe.Duplicate(event("a", 2)), "redelivered")
+	assert.True(t, dedupe.Duplicate(event("a", 1)), "older than the last seen")
+	assert.False(t, dedupe.Duplicate(event("a", 3)))
+	assert.False(t, dedupe.Duplicate(event("legacy", 0)))
+	assert.False(t, dedupe.Duplicate(event("legacy", 0)), "events without a sequence are never duplicates")
+
+	// Past capacity the analysis seen first is forgotten
+	assert.False(t, dedupe.Duplicate(event("b", 1)))
+	assert.False(t, dedupe.Duplicate(event("c", 1)))
+	assert.False(t, dedupe.Duplicate(event("a", 3)))
+	assert.True(t, dedupe.Duplicate(event("c", 1)))
+}
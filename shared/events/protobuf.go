@@ -0,0 +1,208 @@
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ProtobufCodec encodes events as protocol buffers following events.proto
+type ProtobufCodec struct{}
+
+// protoMessage is implemented by payloads that have a protobuf encoding
+type protoMessage interface {
+	appendProto(b []byte) []byte
+}
+
+// Format implements Codec
+func (ProtobufCodec) Format() string { return FormatProtobuf }
+
+// ContentType implements Codec
+func (ProtobufCodec) ContentType() string { return ContentTypeProtobuf }
+
+// Marshal implements Codec
+func (ProtobufCodec) Marshal(event *Event) ([]byte, error) {
+	payload, ok := event.Data.(protoMessage)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnknownEventType, event.Data)
+	}
+
+	var b []byte
+	b = appendVarintField(b, 1, uint64(event.SchemaVersion))
+	b = appendStringField(b, 2, event.Type)
+	b = appendStringField(b, 3, event.AnalysisID)
+	b = appendTimeField(b, 4, event.Timestamp)
+	b = protowire.AppendTag(b, 5, protowire.BytesType)
+	b = protowire.AppendBytes(b, payload.appendProto(nil))
+	b = appendVarintField(b, 6, event.Sequence)
+	return b, nil
+}
+
+// Unmarshal implements Codec
+func (ProtobufCodec) Unmarshal(data []byte) (*Event, error) {
+	event := &Event{}
+	var payload []byte
+	err := rangeFields(data, func(num protowire.Number, v uint64, b []byte) {
+		switch num {
+		case 1:
+			event.SchemaVersion = int(v)
+		case 2:
+			event.Type = string(b)
+		case 3:
+			event.AnalysisID = string(b)
+		case 4:
+			event.Timestamp = unixNanoToTime(v)
+		case 5:
+			payload = b
+		case 6:
+			event.Sequence = v
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+	}
+	if err := checkVersion(event.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	event.Data, err = decodePayload(event.Type, payload, func(b []byte, v interface{}) error {
+		return v.(interface{ unmarshalProto([]byte) error }).unmarshalProto(b)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (p AnalysisStarted) appendProto(b []byte) []byte {
+	return appendStringField(b, 1, p.ProjectID)
+}
+
+func (p *AnalysisStarted) unmarshalProto(data []byte) error {
+	return rangeFields(data, func(num protowire.Number, v uint64, b []byte) {
+		if num == 1 {
+			p.ProjectID = string(b)
+		}
+	})
+}
+
+func (p AnalysisProgress) appendProto(b []byte) []byte {
+	b = appendStringField(b, 1, p.ProjectID)
+	b = appendVarintField(b, 2, uint64(p.FilesAnalyzed))
+	return appendVarintField(b, 3, uint64(p.TotalFiles))
+}
+
+func (p *AnalysisProgress) unmarshalProto(data []byte) error {
+	return rangeFields(data, func(num protowire.Number, v uint64, b []byte) {
+		switch num {
+		case 1:
+			p.ProjectID = string(b)
+		case 2:
+			p.FilesAnalyzed = int(int64(v))
+		case 3:
+			p.TotalFiles = int(int64(v))
+		}
+	})
+}
+
+func (p AnalysisCompleted) appendProto(b []byte) []byte {
+	b = appendStringField(b, 1, p.ProjectID)
+	b = appendVarintField(b, 2, uint64(p.TotalFiles))
+	return appendTimeField(b, 3, p.CompletedAt)
+}
+
+func (p *AnalysisCompleted) unmarshalProto(data []byte) error {
+	return rangeFields(data, func(num protowire.Number, v uint64, b []byte) {
+		switch num {
+		case 1:
+			p.ProjectID = string(b)
+		case 2:
+			p.TotalFiles = int(int64(v))
+		case 3:
+			p.CompletedAt = unixNanoToTime(v)
+		}
+	})
+}
+
+func (p AnalysisFailed) appendProto(b []byte) []byte {
+	b = appendStringField(b, 1, p.ProjectID)
+	return appendStringField(b, 2, p.Error)
+}
+
+func (p *AnalysisFailed) unmarshalProto(data []byte) error {
+	return rangeFields(data, func(num protowire.Number, v uint64, b []byte) {
+		switch num {
+		case 1:
+			p.ProjectID = string(b)
+		case 2:
+			p.Error = string(b)
+		}
+	})
+}
+
+// appendStringField appends a string field, omitting the proto3 default
+func appendStringField(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendVarintField appends a varint field, omitting the proto3 default
+func appendVarintField(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendTimeField appends a timestamp as Unix nanoseconds; the zero time is omitted
+func appendTimeField(b []byte, num protowire.Number, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	return appendVarintField(b, num, uint64(t.UnixNano()))
+}
+
+func unixNanoToTime(v uint64) time.Time {
+	return time.Unix(0, int64(v)).UTC()
+}
+
+// rangeFields calls fn for each varint and length-delimited field in data;
+// fields of other wire types are skipped
+func rangeFields(data []byte, fn func(num protowire.Number, v uint64, b []byte)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, v, nil)
+			data = data[n:]
+		case protowire.BytesType:
+			b, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, 0, b)
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// ErrInvalidModel is returned, wrapped with the offending field, when a
+// model fails validation before it is saved
+var ErrInvalidModel = errors.New("invalid model")
+
+// BeforeSave hook stamps the timestamps of models created or saved, so
+// callers don't set UpdatedAt themselves. It runs for updates too.
+func (b *BaseModel) BeforeSave(tx *gorm.DB) error {
+	now := utils.Now()
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = now
+	}
+	b.UpdatedAt = now
+	return nil
+}
+
+// BeforeSave hook validates the user when it is saved whole
+func (u *User) BeforeSave(tx *gorm.DB) error {
+	return validateBeforeSave(tx, &u.BaseModel, u.Validate)
+}
+
+// BeforeSave hook validates the session when it is saved whole
+func (s *UserSession) BeforeSave(tx *gorm.DB) error {
+	return validateBeforeSave(tx, &s.BaseModel, s.Validate)
+}
+
+// BeforeSave hook validates the project when it is saved whole
+func (p *Project) BeforeSave(tx *gorm.DB) error {
+	return validateBeforeSave(tx, &p.BaseModel, p.Validate)
+}
+
+// BeforeSave hook validates the file when it is saved whole
+func (f *ProjectFile) BeforeSave(tx *gorm.DB) error {
+	return validateBeforeSave(tx, &f.BaseModel, f.Validate)
+}
+
+// BeforeSave hook validates the analysis when it is saved whole
+func (a *Analysis) BeforeSave(tx *gorm.DB) error {
+	return validateBeforeSave(tx, &a.BaseModel, a.Validate)
+}
+
+// Validate reports every missing or malformed field of the user
+func (u *User) Validate() error {
+	var errs []error
+	if strings.TrimSpace(u.Email) == "" {
+		errs = append(errs, invalidField("user", "email is required"))
+	} else if !utils.ValidateEmail(u.Email) {
+		errs = append(errs, invalidField("user", "email %q is not a valid address", u.Email))
+	}
+	if strings.TrimSpace(u.Username) == "" {
+		errs = append(errs, invalidField("user", "username is required"))
+	}
+	if u.Password == "" {
+		errs = append(errs, invalidField("user", "password hash is required"))
+	}
+	if u.FailedLoginAttempts < 0 {
+		errs = append(errs, invalidField("user", "failed login attempts must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate reports every missing field of the session
+func (s *UserSession) Validate() error {
+	var errs []error
+	if s.UserID == uuid.Nil {
+		errs = append(errs, invalidField("session", "user id is required"))
+	}
+	if s.SessionToken == "" {
+		errs = append(errs, invalidField("session", "token is required"))
+	}
+	if s.ExpiresAt.IsZero() {
+		errs = append(errs, invalidField("session", "expiry is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate reports every missing or malformed field of the project
+func (p *Project) Validate() error {
+	var errs []error
+	if strings.TrimSpace(p.Name) == "" {
+		errs = append(errs, invalidField("project", "name is required"))
+	}
+	if strings.TrimSpace(p.Language) == "" {
+		errs = append(errs, invalidField("project", "language is required"))
+	}
+	if p.CreatedBy == uuid.Nil {
+		errs = append(errs, invalidField("project", "creator is required"))
+	}
+	if p.Settings.MaxFileSize < 0 {
+		errs = append(errs, invalidField("project", "max file size must not be negative"))
+	}
+	for _, email := range utils.SplitList(p.Settings.NotificationEmails) {
+		if !utils.ValidateEmail(email) {
+			errs = append(errs, invalidField("project", "notification email %q is not a valid address", email))
+		}
+	}
+	for _, webhook := range utils.SplitList(p.Settings.NotificationWebhooks) {
+		if u, err := url.Parse(webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, invalidField("project", "notification webhook %q must be an http or https URL", webhook))
+		}
+	}
+	for _, language := range utils.SplitList(p.Settings.EnabledLanguages) {
+		if !slices.Contains(ProjectLanguages, strings.ToLower(language)) {
+			errs = append(errs, invalidField("project", "enabled language %q must be one of %s", language, strings.Join(ProjectLanguages, ", ")))
+		}
+	}
+	if severity := strings.TrimSpace(p.Settings.MinSeverity); severity != "" && !slices.Contains(IssueSeverities, strings.ToLower(severity)) {
+		errs = append(errs, invalidField("project", "min severity %q must be one of %s", severity, strings.Join(IssueSeverities, ", ")))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate reports every missing or malformed field of the file
+func (f *ProjectFile) Validate() error {
+	var errs []error
+	if f.ProjectID == uuid.Nil {
+		errs = append(errs, invalidField("project file", "project id is required"))
+	}
+	if strings.TrimSpace(f.Path) == "" {
+		errs = append(errs, invalidField("project file", "path is required"))
+	}
+	if f.Size < 0 {
+		errs = append(errs, invalidField("project file", "size must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate reports every missing or malformed field of the analysis
+func (a *Analysis) Validate() error {
+	var errs []error
+	if a.ProjectID == uuid.Nil {
+		errs = append(errs, invalidField("analysis", "project id is required"))
+	}
+	switch a.Status {
+	case AnalysisStatusPending, AnalysisStatusRunning, AnalysisStatusCompleted, AnalysisStatusFailed, AnalysisStatusCancelled:
+	default:
+		errs = append(errs, invalidField("analysis", "unknown status %q", a.Status))
+	}
+	if a.Progress < 0 || a.TotalFiles < 0 {
+		errs = append(errs, invalidField("analysis", "progress and total files must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateBeforeSave stamps the timestamps and validates a model saved or
+// created whole. Updates of chosen columns, through a map or a partial
+// struct, only carry some fields and are left to the database constraints.
+func validateBeforeSave(tx *gorm.DB, base *BaseModel, validate func() error) error {
+	if savesWholeModel(tx) {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return base.BeforeSave(tx)
+}
+
+// savesWholeModel reports whether the statement writes the model it was
+// called on, as Create and Save do, rather than values given separately
+func savesWholeModel(tx *gorm.DB) bool {
+	if tx == nil || tx.Statement == nil {
+		return true
+	}
+	dest, model := reflect.ValueOf(tx.Statement.Dest), reflect.ValueOf(tx.Statement.Model)
+	return dest.Kind() == reflect.Pointer && model.Kind() == reflect.Pointer && dest.Pointer() == model.Pointer()
+}
+
+func invalidField(model, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s %s", ErrInvalidModel, model, fmt.Sprintf(format, args...))
+}
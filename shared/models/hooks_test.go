@@ -0,0 +1,109 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+)
+
+func newUser() *models.User {
+	return &models.User{Email: "dev@example.com", Username: "dev", Password: "$2a$10$hash"}
+}
+
+func TestBaseModel_Timestamps(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{})
+
+	user := newUser()
+	require.NoError(t, db.Create(user).Error)
+	assert.False(t, user.CreatedAt.IsZero())
+	assert.False(t, user.UpdatedAt.IsZero())
+	createdAt, updatedAt := user.CreatedAt, user.UpdatedAt
+
+	time.Sleep(10 * time.Millisecond)
+	user.FirstName = "Dev"
+	require.NoError(t, db.Save(user).Error)
+	assert.True(t, user.UpdatedAt.After(updatedAt))
+	assert.Equal(t, createdAt, user.CreatedAt)
+
+	var stored models.User
+	require.NoError(t, db.First(&stored, "id = ?", user.ID).Error)
+	assert.True(t, stored.UpdatedAt.After(updatedAt))
+	assert.True(t, stored.CreatedAt.Equal(createdAt))
+
+	t.Run("column updates", func(t *testing.T) {
+		time.Sleep(10 * time.Millisecond)
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{"first_name": "Ops"}).Error)
+		var updated models.User
+		require.NoError(t, db.First(&updated, "id = ?", user.ID).Error)
+		assert.Equal(t, "Ops", updated.FirstName)
+		assert.True(t, updated.UpdatedAt.After(stored.UpdatedAt))
+	})
+}
+
+func TestModels_RejectInvalid(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.UserSession{}, &models.Project{}, &models.ProjectFile{}, &models.Analysis{})
+
+	tests := []struct {
+		name    string
+		model   interface{}
+		wantMsg string
+	}{
+		{"user without email", &models.User{Username: "dev", Password: "hash"}, "user email is required"},
+		{"user with invalid email", &models.User{Email: "not-an-email", Username: "dev", Password: "hash"}, `user email "not-an-email" is not a valid address`},
+		{"user without username", &models.User{Email: "dev@example.com", Password: "hash"}, "user username is required"},
+		{"user without password", &models.User{Email: "dev@example.com", Username: "dev"}, "user password hash is required"},
+		{"session without token", &models.UserSession{UserID: uuid.New(), ExpiresAt: time.Now()}, "session token is required"},
+		{"project without name", &models.Project{Language: "go", CreatedBy: uuid.New()}, "project name is required"},
+		{"project without creator", &models.Project{Name: "sa3d", Language: "go"}, "project creator is required"},
+		{"project with invalid notification email", &models.Project{Name: "sa3d", Language: "go", CreatedBy: uuid.New(), Settings: models.ProjectSettings{NotificationEmails: "dev@example.com, ops"}}, `project notification email "ops" is not a valid address`},
+		{"project with non-http webhook", &models.Project{Name: "sa3d", Language: "go", CreatedBy: uuid.New(), Settings: models.ProjectSettings{NotificationWebhooks: "file:///etc/passwd"}}, `project notification webhook "file:///etc/passwd" must be an http or https URL`},
+		{"project with unknown language", &models.Project{Name: "sa3d", Language: "go", CreatedBy: uuid.New(), Settings: models.ProjectSettings{EnabledLanguages: "go,cobol"}}, `project enabled language "cobol" must be one of go, java, python, javascript, typescript, csharp`},
+		{"project with unknown min severity", &models.Project{Name: "sa3d", Language: "go", CreatedBy: uuid.New(), Settings: models.ProjectSettings{MinSeverity: "blocker"}}, `project min severity "blocker" must be one of info, minor, major, critical`},
+		{"file without path", &models.ProjectFile{ProjectID: uuid.New()}, "project file path is required"},
+		{"analysis with unknown status", &models.Analysis{ProjectID: uuid.New(), Status: "RUNNING"}, `analysis unknown status "RUNNING"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := db.Create(tt.model).Error
+			require.ErrorIs(t, err, models.ErrInvalidModel)
+			assert.Contains(t, err.Error(), tt.wantMsg)
+		})
+	}
+
+	t.Run("every problem is reported", func(t *testing.T) {
+		err := (&models.Project{}).Validate()
+		require.ErrorIs(t, err, models.ErrInvalidModel)
+		for _, msg := range []string{"name is required", "language is required", "creator is required"} {
+			assert.Contains(t, err.Error(), msg)
+		}
+	})
+
+	t.Run("invalid save of a stored model", func(t *testing.T) {
+		user := newUser()
+		require.NoError(t, db.Create(user).Error)
+		user.Email = ""
+		assert.ErrorIs(t, db.Save(user).Error, models.ErrInvalidModel)
+
+		var stored models.User
+		require.NoError(t, db.First(&stored, "id = ?", user.ID).Error)
+		assert.Equal(t, "dev@example.com", stored.Email)
+	})
+
+	t.Run("valid models", func(t *testing.T) {
+		user := newUser()
+		user.Email, user.Username = "ops@example.com", "ops"
+		require.NoError(t, db.Create(user).Error)
+		require.NoError(t, db.Create(&models.Project{Name: "sa3d", Language: "go", CreatedBy: user.ID}).Error)
+		require.NoError(t, db.Create(&models.Project{Name: "sa3d", Language: "go", CreatedBy: user.ID, Settings: models.ProjectSettings{
+			EnabledLanguages: "go, TypeScript",
+			MinSeverity:      "Major",
+		}}).Error)
+		require.NoError(t, db.Create(&models.Analysis{ProjectID: uuid.New(), Status: models.AnalysisStatusPending}).Error)
+	})
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -53,6 +56,7 @@ type UserSession struct {
 	IPAddress    string    `json:"ip_address"`
 	UserAgent    string    `json:"user_agent"`
 	IsActive     bool      `json:"is_active" gorm:"default:true;index"`
+	LastSeenAt   *time.Time `json:"last_seen_at,omitempty"`
 }
 
 // Project represents a software project
@@ -77,6 +81,32 @@ type ProjectSettings struct {
 	AnalyzeFrequency string `json:"analyze_frequency" gorm:"default:'daily'"`
 	IgnorePatterns   string `json:"ignore_patterns"`
 	MaxFileSize      int64  `json:"max_file_size" gorm:"default:10485760"` // 10MB
+	// EnabledLanguages is a comma-separated list of languages to analyze; empty enables all
+	EnabledLanguages string `json:"enabled_languages"`
+	// NotificationEmails and NotificationWebhooks are comma-separated
+	// recipients of analysis summaries
+	NotificationEmails   string `json:"notification_emails"`
+	NotificationWebhooks string `json:"notification_webhooks"`
+	// MinSeverity leaves issues below this severity out of aggregate
+	// counts; empty counts every issue
+	MinSeverity string `json:"min_severity"`
+}
+
+// ProjectLanguages are the languages a project can enable for analysis, and
+// IssueSeverities, from least to most severe, those MinSeverity can name
+var (
+	ProjectLanguages = []string{"go", "java", "python", "javascript", "typescript", "csharp"}
+	IssueSeverities  = []string{"info", "minor", "major", "critical"}
+)
+
+// ProjectFile is a source file stored for a project's analyses
+type ProjectFile struct {
+	BaseModel
+	ProjectID  uuid.UUID `json:"project_id" gorm:"not null;index"`
+	Path       string    `json:"path" gorm:"not null"`
+	Content    []byte    `json:"-"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
 }
 
 // Analysis represents a code analysis run
@@ -87,9 +117,42 @@ type Analysis struct {
 	Status      AnalysisStatus  `json:"status" gorm:"not null"`
 	StartedAt   time.Time       `json:"started_at"`
 	CompletedAt *time.Time      `json:"completed_at,omitempty"`
-	Error       string          `json:"error,omitempty"`
+	Error       string          `json:"error,omitempty" gorm:"column:error_message"`
 	Results     AnalysisResults `json:"results" gorm:"type:jsonb"`
 	Metrics     ProjectMetrics  `json:"metrics" gorm:"embedded"`
+	// AnalyzerVersion and MetricsVersion record the logic that produced the results
+	AnalyzerVersion string `json:"analyzer_version"`
+	MetricsVersion  string `json:"metrics_version"`
+	// Progress and TotalFiles track how far a running analysis has got
+	Progress   int `json:"progress"`
+	TotalFiles int `json:"total_files"`
+	// Job holds what the analysis was started with and what it cost
+	Job AnalysisJobDetails `json:"job" gorm:"column:job_details;type:jsonb"`
+}
+
+// AnalysisJobDetails are the options an analysis job ran with
+type AnalysisJobDetails struct {
+	// Languages restricts the run to these languages; empty means all
+	Languages []string `json:"languages,omitempty"`
+	// MinSeverity is the minimum severity counted in the aggregate metrics
+	MinSeverity string `json:"min_severity,omitempty"`
+	// Priority orders the job among queued analyses: low, normal or high
+	Priority string `json:"priority,omitempty"`
+	// BaseAnalysisID and Paths are set for partial analyses
+	BaseAnalysisID string   `json:"base_analysis_id,omitempty"`
+	Paths          []string `json:"paths,omitempty"`
+	// Resources is the analysis service's record of what the run cost
+	Resources json.RawMessage `json:"resources,omitempty"`
+}
+
+// Value implements driver.Valuer so job details are stored as JSONB
+func (d AnalysisJobDetails) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+// Scan implements sql.Scanner for job details stored as JSONB
+func (d *AnalysisJobDetails) Scan(value interface{}) error {
+	return scanJSON(value, d)
 }
 
 // AnalysisStatus represents the status of an analysis
@@ -113,6 +176,16 @@ type AnalysisResults struct {
 	Statistics    AnalysisStatistics `json:"statistics"`
 }
 
+// Value implements driver.Valuer so results are stored as JSONB
+func (r AnalysisResults) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan implements sql.Scanner for results stored as JSONB
+func (r *AnalysisResults) Scan(value interface{}) error {
+	return scanJSON(value, r)
+}
+
 // FileInfo represents information about a source file
 type FileInfo struct {
 	Path         string         `json:"path"`
@@ -186,6 +259,15 @@ type Issue struct {
 	Message     string `json:"message"`
 	Rule        string `json:"rule"`
 	Effort      string `json:"effort"` // time to fix
+	// Locations lists every place involved, e.g. all copies of a cloned block
+	Locations []IssueLocation `json:"locations,omitempty"`
+}
+
+// IssueLocation is a line range in a file
+type IssueLocation struct {
+	File      string `json:"file"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
 }
 
 // AnalysisStatistics contains overall statistics
@@ -239,6 +321,30 @@ type VisualizationSettings struct {
 	CameraPosition   map[string]float64     `json:"camera_position"`
 }
 
+// Value implements driver.Valuer so settings are stored as JSONB
+func (s VisualizationSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements sql.Scanner for settings stored as JSONB
+func (s *VisualizationSettings) Scan(value interface{}) error {
+	return scanJSON(value, s)
+}
+
+// scanJSON decodes a JSONB column value into dest
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return fmt.Errorf("unsupported JSONB value type %T", value)
+	}
+}
+
 // Session represents a collaboration session
 type Session struct {
 	BaseModel
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+// utcCallback names the query callback UseUTC registers
+const utcCallback = "sa3d:utc"
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	timePtrType   = reflect.TypeOf((*time.Time)(nil))
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+// UseUTC makes db stamp times in UTC and return the times it reads in UTC.
+// Drivers scan timestamps in the host's timezone, whatever the session's,
+// so models read back would otherwise serialize with a local offset.
+func UseUTC(db *gorm.DB) error {
+	db.Config.NowFunc = utils.Now
+	if db.Callback().Query().Get(utcCallback) != nil {
+		return nil
+	}
+	return db.Callback().Query().After("gorm:after_query").Register(utcCallback, toUTC)
+}
+
+// toUTC converts the time fields of the models a query read to UTC
+func toUTC(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	value := reflect.Indirect(db.Statement.ReflectValue)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fieldsToUTC(db, reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		fieldsToUTC(db, value)
+	}
+}
+
+// fieldsToUTC converts the time fields of one model to UTC
+func fieldsToUTC(db *gorm.DB, model reflect.Value) {
+	if model.Kind() != reflect.Struct || model.Type() != db.Statement.Schema.ModelType {
+		return
+	}
+	ctx := db.Statement.Context
+	for _, field := range db.Statement.Schema.Fields {
+		switch field.FieldType {
+		case timeType, timePtrType, deletedAtType:
+		default:
+			continue
+		}
+		value := field.ReflectValueOf(ctx, model)
+		if !value.CanSet() {
+			continue
+		}
+		switch field.FieldType {
+		case timeType:
+			value.Set(reflect.ValueOf(value.Interface().(time.Time).UTC()))
+		case timePtrType:
+			if !value.IsNil() {
+				value.Elem().Set(reflect.ValueOf(value.Elem().Interface().(time.Time).UTC()))
+			}
+		case deletedAtType:
+			deletedAt := value.Interface().(gorm.DeletedAt)
+			deletedAt.Time = deletedAt.Time.UTC()
+			value.Set(reflect.ValueOf(deletedAt))
+		}
+	}
+}
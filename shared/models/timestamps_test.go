@@ -0,0 +1,62 @@
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+)
+
+func TestUseUTC(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{})
+
+	user := newUser()
+	require.NoError(t, db.Create(user).Error)
+	assert.Equal(t, time.UTC, user.CreatedAt.Location(), "stamped times are UTC")
+	assert.Equal(t, time.UTC, user.UpdatedAt.Location())
+
+	// Write times with an offset, as a driver scanning in the host's
+	// timezone would return them
+	cet := time.FixedZone("CET", 3600)
+	lockedUntil := time.Date(2030, 1, 2, 3, 4, 5, 0, cet)
+	require.NoError(t, db.Exec("UPDATE users SET created_at = ?, locked_until = ? WHERE id = ?",
+		lockedUntil, lockedUntil, user.ID).Error)
+
+	t.Run("one model", func(t *testing.T) {
+		var stored models.User
+		require.NoError(t, db.First(&stored, "id = ?", user.ID).Error)
+		assert.Equal(t, time.UTC, stored.CreatedAt.Location())
+		assert.True(t, stored.CreatedAt.Equal(lockedUntil), "times keep their instant")
+		require.NotNil(t, stored.LockedUntil)
+		assert.Equal(t, time.UTC, stored.LockedUntil.Location())
+	})
+
+	t.Run("many models", func(t *testing.T) {
+		var stored []models.User
+		require.NoError(t, db.Find(&stored).Error)
+		require.Len(t, stored, 1)
+		assert.Equal(t, time.UTC, stored[0].CreatedAt.Location())
+		assert.Equal(t, time.UTC, stored[0].LockedUntil.Location())
+	})
+
+	t.Run("serialized as RFC 3339 UTC", func(t *testing.T) {
+		var stored models.User
+		require.NoError(t, db.First(&stored, "id = ?", user.ID).Error)
+		body, err := json.Marshal(stored)
+		require.NoError(t, err)
+
+		var fields map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &fields))
+		assert.Equal(t, "2030-01-02T02:04:05Z", fields["created_at"])
+		assert.Equal(t, "2030-01-02T02:04:05Z", fields["locked_until"])
+		updatedAt, err := time.Parse(time.RFC3339, fields["updated_at"].(string))
+		require.NoError(t, err)
+		_, offset := updatedAt.Zone()
+		assert.Zero(t, offset)
+	})
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+func TestAuthService_AccessTokens(t *testing.T) {
+	const secret = "a-secret-of-at-least-thirty-two-bytes"
+
+	as, _, login := newClockedTestService(t, "Str0ng!Passw0rd")
+	as.SetTokenSecret(secret)
+
+	result, err := as.Login(login)
+	require.NoError(t, err)
+
+	t.Run("signed with the token secret", func(t *testing.T) {
+		var claims AccessTokenClaims
+		token, err := jwt.ParseWithClaims(result.AccessToken, &claims, func(*jwt.Token) (interface{}, error) {
+			return []byte(secret), nil
+		}, jwt.WithValidMethods([]string{"HS256"}))
+		require.NoError(t, err)
+		assert.True(t, token.Valid)
+		assert.Equal(t, result.User.ID.String(), claims.UserID)
+		assert.Equal(t, result.User.Email, claims.Email)
+		assert.Equal(t, []string{result.User.Role}, claims.Roles)
+		assert.Equal(t, result.ExpiresAt.Unix(), claims.ExpiresAt.Unix(), "the token expires with its session")
+	})
+
+	t.Run("validated", func(t *testing.T) {
+		user, err := as.ValidateToken(result.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, result.User.ID, user.ID)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		_, err := as.ValidateToken(result.AccessToken + "x")
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("secret changed", func(t *testing.T) {
+		as.SetTokenSecret("another-secret-of-at-least-thirty-two")
+		_, err := as.ValidateToken(result.AccessToken)
+		assert.ErrorIs(t, err, ErrInvalidToken, "tokens signed with the old secret are rejected")
+
+		refreshed, err := as.RefreshToken(result.RefreshToken)
+		require.NoError(t, err, "the refresh token still gets a new access token")
+		_, err = as.ValidateToken(refreshed.AccessToken)
+		assert.NoError(t, err)
+	})
+}
+
+func TestAuthService_AccessTokens_RandomSecret(t *testing.T) {
+	as, _, login := newClockedTestService(t, "Str0ng!Passw0rd")
+
+	result, err := as.Login(login)
+	require.NoError(t, err)
+	_, err = as.ValidateToken(result.AccessToken)
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(result.AccessToken, func(*jwt.Token) (interface{}, error) {
+		return []byte(""), nil
+	})
+	assert.Error(t, err, "without a secret set, no other validator accepts the tokens")
+}
+
+func TestAuthService_SignAccessToken(t *testing.T) {
+	as, clock, _ := newClockedTestService(t, "Str0ng!Passw0rd")
+	user := &models.User{BaseModel: models.BaseModel{ID: uuid.New()}, Email: "signed@example.com"}
+
+	token, err := as.SignAccessToken(user, clock.Now().Add(time.Hour))
+	require.NoError(t, err)
+	var claims AccessTokenClaims
+	_, _, err = jwt.NewParser().ParseUnverified(token, &claims)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.String(), claims.UserID)
+	assert.Empty(t, claims.Roles, "users without a role claim none")
+	assert.Equal(t, clock.Now().Unix(), claims.IssuedAt.Unix())
+	assert.Equal(t, clock.Now().Add(time.Hour).Unix(), claims.ExpiresAt.Unix())
+	assert.NotEmpty(t, claims.ID)
+
+	other, err := as.SignAccessToken(user, clock.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other, "tokens are unique, as sessions require")
+}
@@ -5,11 +5,13 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
 	"github.com/sa3d-modernized/sa3d/shared/models"
@@ -30,8 +32,14 @@ var (
 
 // AuthService handles user authentication and management
 type AuthService struct {
-	db     *DatabaseService
-	logger *logrus.Logger
+	db       *DatabaseService
+	hasher   PasswordHasher
+	features *FeatureFlags
+	clock    utils.Clock
+	logger   *logrus.Logger
+
+	// tokenSecret signs access tokens
+	tokenSecret []byte
 }
 
 // LoginAttempt represents a login attempt record
@@ -44,13 +52,70 @@ type LoginAttempt struct {
 	AttemptedAt   time.Time
 }
 
-// UserRegistration represents user registration data
+// UserRegistration represents user registration data. Fields are checked
+// by Validate rather than binding tags so each problem can be reported.
 type UserRegistration struct {
-	Email     string `json:"email" binding:"required,email"`
-	Username  string `json:"username" binding:"required,min=3,max=100"`
-	Password  string `json:"password" binding:"required,min=8"`
-	FirstName string `json:"first_name" binding:"required,min=1,max=100"`
-	LastName  string `json:"last_name" binding:"required,min=1,max=100"`
+	Email     string `json:"email"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// Registration field limits
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 100
+	maxNameLength     = 100
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// Validate checks every registration field and returns a validation error
+// whose details map each invalid field to the reason, or nil if all are valid.
+// A weak password wraps ErrWeakPassword.
+func (r UserRegistration) Validate() *utils.AppError {
+	details := make(map[string]interface{})
+	var cause error
+
+	switch {
+	case r.Email == "":
+		details["email"] = "is required"
+	case !utils.ValidateEmail(r.Email):
+		details["email"] = "must be a valid email address"
+	}
+
+	switch length := utf8.RuneCountInString(r.Username); {
+	case length == 0:
+		details["username"] = "is required"
+	case length < minUsernameLength || length > maxUsernameLength:
+		details["username"] = fmt.Sprintf("must be between %d and %d characters", minUsernameLength, maxUsernameLength)
+	case !usernamePattern.MatchString(r.Username):
+		details["username"] = "may only contain letters, digits, '.', '_' and '-'"
+	}
+
+	if r.Password == "" {
+		details["password"] = "is required"
+	} else if err := utils.ValidatePassword(r.Password); err != nil {
+		details["password"] = err.Error()
+		cause = ErrWeakPassword
+	}
+
+	for field, name := range map[string]string{"first_name": r.FirstName, "last_name": r.LastName} {
+		switch length := utf8.RuneCountInString(strings.TrimSpace(name)); {
+		case length == 0:
+			details[field] = "is required"
+		case utf8.RuneCountInString(name) > maxNameLength:
+			details[field] = fmt.Sprintf("must be at most %d characters", maxNameLength)
+		}
+	}
+
+	if len(details) == 0 {
+		return nil
+	}
+	appErr := utils.NewValidationError("Invalid registration data", details)
+	appErr.Err = cause
+	return appErr
 }
 
 // UserLogin represents login credentials
@@ -69,19 +134,41 @@ type AuthResult struct {
 	ExpiresAt    time.Time    `json:"expires_at"`
 }
 
-// NewAuthService creates a new authentication service
+// NewAuthService creates a new authentication service that hashes
+// passwords with bcrypt
 func NewAuthService(db *DatabaseService, logger *logrus.Logger) *AuthService {
+	hasher, _ := NewPasswordHasher(HashAlgorithmBcrypt)
+	return NewAuthServiceWithHasher(db, hasher, logger)
+}
+
+// NewAuthServiceWithHasher creates a new authentication service using the
+// given password hasher
+func NewAuthServiceWithHasher(db *DatabaseService, hasher PasswordHasher, logger *logrus.Logger) *AuthService {
 	return &AuthService{
 		db:     db,
+		hasher: hasher,
+		clock:  utils.SystemClock{},
 		logger: logger,
+
+		tokenSecret: newTokenSecret(),
 	}
 }
 
+// SetClock sets the clock lockouts, sessions and tokens expire by
+func (as *AuthService) SetClock(clock utils.Clock) {
+	as.clock = clock
+}
+
+// SetFeatureFlags sets the flags toggling optional checks such as email
+// verification; without them every optional check is off
+func (as *AuthService) SetFeatureFlags(features *FeatureFlags) {
+	as.features = features
+}
+
 // Register creates a new user account
 func (as *AuthService) Register(registration UserRegistration) (*models.User, error) {
-	// Validate password strength
-	if !utils.IsValidPassword(registration.Password) {
-		return nil, ErrWeakPassword
+	if appErr := registration.Validate(); appErr != nil {
+		return nil, appErr
 	}
 
 	// Check if user already exists
@@ -110,7 +197,7 @@ func (as *AuthService) Register(registration UserRegistration) (*models.User, er
 		Role:            "user",
 		IsActive:        true,
 		IsVerified:      false, // Require email verification
-		PasswordChangedAt: time.Now(),
+		PasswordChangedAt: as.clock.Now(),
 	}
 
 	// Set system context for creation
@@ -148,7 +235,7 @@ func (as *AuthService) Login(credentials UserLogin) (*AuthResult, error) {
 			UserAgent:     credentials.UserAgent,
 			Success:       false,
 			FailureReason: "user not found",
-			AttemptedAt:   time.Now(),
+			AttemptedAt:   as.clock.Now(),
 		})
 		
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -158,14 +245,14 @@ func (as *AuthService) Login(credentials UserLogin) (*AuthResult, error) {
 	}
 
 	// Check if account is locked
-	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+	if user.LockedUntil != nil && user.LockedUntil.After(as.clock.Now()) {
 		as.logLoginAttempt(LoginAttempt{
 			Email:         credentials.Email,
 			IPAddress:     credentials.IPAddress,
 			UserAgent:     credentials.UserAgent,
 			Success:       false,
 			FailureReason: "account locked",
-			AttemptedAt:   time.Now(),
+			AttemptedAt:   as.clock.Now(),
 		})
 		return nil, ErrAccountLocked
 	}
@@ -178,20 +265,20 @@ func (as *AuthService) Login(credentials UserLogin) (*AuthResult, error) {
 			UserAgent:     credentials.UserAgent,
 			Success:       false,
 			FailureReason: "account not active",
-			AttemptedAt:   time.Now(),
+			AttemptedAt:   as.clock.Now(),
 		})
 		return nil, ErrAccountNotActive
 	}
 
-	// Check if account is verified (optional - can be disabled for development)
-	if !user.IsVerified && as.requireEmailVerification() {
+	// Check if account is verified, when the feature flag requires it
+	if !user.IsVerified && as.features.RequireEmailVerification() {
 		as.logLoginAttempt(LoginAttempt{
 			Email:         credentials.Email,
 			IPAddress:     credentials.IPAddress,
 			UserAgent:     credentials.UserAgent,
 			Success:       false,
 			FailureReason: "account not verified",
-			AttemptedAt:   time.Now(),
+			AttemptedAt:   as.clock.Now(),
 		})
 		return nil, ErrAccountNotVerified
 	}
@@ -209,11 +296,21 @@ func (as *AuthService) Login(credentials UserLogin) (*AuthResult, error) {
 			UserAgent:     credentials.UserAgent,
 			Success:       false,
 			FailureReason: "invalid password",
-			AttemptedAt:   time.Now(),
+			AttemptedAt:   as.clock.Now(),
 		})
 		return nil, ErrInvalidCredentials
 	}
 
+	// Upgrade hashes produced by an older algorithm or parameters; the
+	// new hash is persisted together with the login bookkeeping below
+	if as.hasher.NeedsRehash(user.Password) {
+		if rehashed, err := as.hashPassword(credentials.Password); err != nil {
+			as.logger.WithError(err).WithField("user_id", user.ID).Warn("Failed to upgrade password hash")
+		} else {
+			user.Password = rehashed
+		}
+	}
+
 	// Handle successful login
 	if err := as.handleSuccessfulLogin(&user); err != nil {
 		return nil, fmt.Errorf("failed to handle successful login: %w", err)
@@ -236,7 +333,7 @@ func (as *AuthService) Login(credentials UserLogin) (*AuthResult, error) {
 		IPAddress:   credentials.IPAddress,
 		UserAgent:   credentials.UserAgent,
 		Success:     true,
-		AttemptedAt: time.Now(),
+		AttemptedAt: as.clock.Now(),
 	})
 
 	// Remove password from response
@@ -260,7 +357,7 @@ func (as *AuthService) RefreshToken(refreshToken string) (*AuthResult, error) {
 	// Find session by refresh token
 	var session models.UserSession
 	err := as.db.DB.Where("refresh_token = ? AND is_active = ? AND expires_at > ?", 
-		refreshToken, true, time.Now()).First(&session).Error
+		refreshToken, true, as.clock.Now()).First(&session).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrInvalidToken
@@ -293,7 +390,6 @@ func (as *AuthService) RefreshToken(refreshToken string) (*AuthResult, error) {
 	session.SessionToken = accessToken
 	session.RefreshToken = newRefreshToken
 	session.ExpiresAt = expiresAt
-	session.UpdatedAt = time.Now()
 
 	if err := as.db.DB.Save(&session).Error; err != nil {
 		return nil, fmt.Errorf("failed to update session: %w", err)
@@ -325,18 +421,28 @@ func (as *AuthService) Logout(userID uuid.UUID, sessionToken string) error {
 	return nil
 }
 
-// ValidateToken validates a JWT token and returns user information
+// ValidateToken validates a JWT token and returns user information. A
+// token of an active session past its expiry fails with ErrTokenExpired.
+// Its signature must verify with the token secret, so it is accepted here
+// exactly when stateless validators sharing the secret accept it.
 func (as *AuthService) ValidateToken(token string) (*models.User, error) {
+	if err := as.verifyAccessToken(token); err != nil {
+		return nil, err
+	}
+
 	// Find active session with token
 	var session models.UserSession
-	err := as.db.DB.Where("session_token = ? AND is_active = ? AND expires_at > ?", 
-		token, true, time.Now()).First(&session).Error
+	err := as.db.DB.Where("session_token = ? AND is_active = ?", 
+		token, true).First(&session).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrInvalidToken
 		}
 		return nil, fmt.Errorf("failed to find session: %w", err)
 	}
+	if !session.ExpiresAt.After(as.clock.Now()) {
+		return nil, ErrTokenExpired
+	}
 
 	// Get user
 	var user models.User
@@ -353,6 +459,8 @@ func (as *AuthService) ValidateToken(token string) (*models.User, error) {
 		return nil, ErrAccountNotActive
 	}
 
+	as.touchSession(&session)
+
 	// Remove password from response
 	user.Password = ""
 	return &user, nil
@@ -361,7 +469,7 @@ func (as *AuthService) ValidateToken(token string) (*models.User, error) {
 // GetUserByID retrieves a user by ID
 func (as *AuthService) GetUserByID(userID uuid.UUID) (*models.User, error) {
 	var user models.User
-	err := as.db.DB.Where("id = ? AND deleted_at IS NULL", userID).First(&user).Error
+	err := as.db.ReadDB().Where("id = ? AND deleted_at IS NULL", userID).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrUserNotFound
@@ -374,22 +482,27 @@ func (as *AuthService) GetUserByID(userID uuid.UUID) (*models.User, error) {
 	return &user, nil
 }
 
-// hashPassword hashes a password using bcrypt
+// hashPassword hashes a password using the configured hasher
 func (as *AuthService) hashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+	return as.hasher.Hash(password)
 }
 
 // verifyPassword verifies a password against its hash
 func (as *AuthService) verifyPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	ok, err := as.hasher.Verify(password, hash)
+	if err != nil {
+		as.logger.WithError(err).Warn("Failed to verify password hash")
+		return false
+	}
+	return ok
 }
 
-// generateTokens generates access and refresh tokens
+// generateTokens generates a signed access token and an opaque refresh
+// token
 func (as *AuthService) generateTokens(user *models.User) (string, string, time.Time, error) {
-	// For now, generate simple tokens. In production, use proper JWT
-	accessToken, err := as.generateSecureToken(32)
+	expiresAt := as.clock.Now().Add(24 * time.Hour) // 24 hours
+
+	accessToken, err := as.SignAccessToken(user, expiresAt)
 	if err != nil {
 		return "", "", time.Time{}, fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -399,8 +512,6 @@ func (as *AuthService) generateTokens(user *models.User) (string, string, time.T
 		return "", "", time.Time{}, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
-	expiresAt := time.Now().Add(24 * time.Hour) // 24 hours
-
 	return accessToken, refreshToken, expiresAt, nil
 }
 
@@ -415,6 +526,7 @@ func (as *AuthService) generateSecureToken(length int) (string, error) {
 
 // createUserSession creates a new user session record
 func (as *AuthService) createUserSession(user *models.User, accessToken, refreshToken, ipAddress, userAgent string, expiresAt time.Time) error {
+	now := as.clock.Now()
 	session := &models.UserSession{
 		UserID:       user.ID,
 		SessionToken: accessToken,
@@ -423,6 +535,7 @@ func (as *AuthService) createUserSession(user *models.User, accessToken, refresh
 		IPAddress:    ipAddress,
 		UserAgent:    userAgent,
 		IsActive:     true,
+		LastSeenAt:   &now,
 	}
 
 	return as.db.DB.Create(session).Error
@@ -430,11 +543,10 @@ func (as *AuthService) createUserSession(user *models.User, accessToken, refresh
 
 // handleSuccessfulLogin updates user after successful login
 func (as *AuthService) handleSuccessfulLogin(user *models.User) error {
-	now := time.Now()
+	now := as.clock.Now()
 	user.FailedLoginAttempts = 0
 	user.LockedUntil = nil
 	user.LastLogin = &now
-	user.UpdatedAt = now
 
 	return as.db.DB.Save(user).Error
 }
@@ -447,11 +559,10 @@ func (as *AuthService) handleFailedLogin(user *models.User) error {
 	user.FailedLoginAttempts++
 	
 	if user.FailedLoginAttempts >= maxAttempts {
-		lockUntil := time.Now().Add(lockoutDuration)
+		lockUntil := as.clock.Now().Add(lockoutDuration)
 		user.LockedUntil = &lockUntil
 	}
 
-	user.UpdatedAt = time.Now()
 	return as.db.DB.Save(user).Error
 }
 
@@ -472,10 +583,3 @@ func (as *AuthService) logLoginAttempt(attempt LoginAttempt) {
 		as.logger.WithFields(fields).Warn("Login attempt failed")
 	}
 }
-
-// requireEmailVerification returns whether email verification is required
-func (as *AuthService) requireEmailVerification() bool {
-	// In development, email verification might be disabled
-	// In production, this should always return true
-	return false // TODO: Make this configurable
-}
\ No newline at end of file
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+func validRegistration() UserRegistration {
+	return UserRegistration{
+		Email:     "alice@example.com",
+		Username:  "alice_01",
+		Password:  "Str0ng!Passw0rd",
+		FirstName: "Alice",
+		LastName:  "Liddell",
+	}
+}
+
+func TestUserRegistration_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *UserRegistration)
+		field  string
+		reason string
+	}{
+		{"missing email", func(r *UserRegistration) { r.Email = "" }, "email", "is required"},
+		{"malformed email", func(r *UserRegistration) { r.Email = "alice@" }, "email", "must be a valid email address"},
+		{"missing username", func(r *UserRegistration) { r.Username = "" }, "username", "is required"},
+		{"short username", func(r *UserRegistration) { r.Username = "al" }, "username", "must be between 3 and 100 characters"},
+		{"long username", func(r *UserRegistration) { r.Username = strings.Repeat("a", 101) }, "username", "must be between 3 and 100 characters"},
+		{"username charset", func(r *UserRegistration) { r.Username = "alice smith" }, "username", "may only contain letters, digits, '.', '_' and '-'"},
+		{"missing password", func(r *UserRegistration) { r.Password = "" }, "password", "is required"},
+		{"short password", func(r *UserRegistration) { r.Password = "Sh0rt!" }, "password", "password must be at least 8 characters long"},
+		{"blank first name", func(r *UserRegistration) { r.FirstName = "  " }, "first_name", "is required"},
+		{"long first name", func(r *UserRegistration) { r.FirstName = strings.Repeat("é", 101) }, "first_name", "must be at most 100 characters"},
+		{"missing last name", func(r *UserRegistration) { r.LastName = "" }, "last_name", "is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registration := validRegistration()
+			tt.modify(&registration)
+
+			appErr := registration.Validate()
+			require.NotNil(t, appErr)
+			assert.Equal(t, utils.ErrCodeValidation, appErr.Code)
+			assert.Equal(t, http.StatusBadRequest, appErr.StatusCode)
+			assert.Equal(t, map[string]interface{}{tt.field: tt.reason}, appErr.Details)
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		assert.Nil(t, validRegistration().Validate())
+	})
+
+	t.Run("reports every invalid field", func(t *testing.T) {
+		appErr := UserRegistration{Email: "nope", Username: "a", Password: "weakpassword"}.Validate()
+		require.NotNil(t, appErr)
+		assert.Len(t, appErr.Details, 5)
+		assert.ErrorIs(t, appErr, ErrWeakPassword)
+	})
+}
+
+func TestAuthService_RegisterValidation(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{})
+	logger := testutil.NewTestLogger()
+	as := NewAuthService(NewDatabaseServiceFromDB(db, nil, logger), logger)
+
+	registration := validRegistration()
+	registration.Email = "not-an-email"
+	_, err := as.Register(registration)
+
+	appErr := utils.GetAppError(err)
+	require.NotNil(t, appErr)
+	assert.Equal(t, map[string]interface{}{"email": "must be a valid email address"}, appErr.Details)
+
+	var count int64
+	require.NoError(t, db.Model(&models.User{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+// newClockedTestService returns an auth service on a mock clock with an
+// active user who logs in with password
+func newClockedTestService(t *testing.T, password string) (*AuthService, *utils.MockClock, UserLogin) {
+	t.Helper()
+
+	as, db := newAdminTestService(t)
+	clock := utils.NewMockClock(time.Now())
+	as.SetClock(clock)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &models.User{Email: "clocked@example.com", Username: "clocked", Password: string(hash), IsActive: true}
+	require.NoError(t, db.Create(user).Error)
+	return as, clock, UserLogin{Email: user.Email, Password: password}
+}
+
+func TestAuthService_LockoutExpiry(t *testing.T) {
+	as, clock, login := newClockedTestService(t, "Str0ng!Passw0rd")
+
+	wrong := login
+	wrong.Password = "wrong-password"
+	for i := 0; i < 5; i++ {
+		_, err := as.Login(wrong)
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, err := as.Login(login)
+	assert.ErrorIs(t, err, ErrAccountLocked, "five failures lock the account")
+
+	clock.Advance(14 * time.Minute)
+	_, err = as.Login(login)
+	assert.ErrorIs(t, err, ErrAccountLocked, "the lockout lasts 15 minutes")
+
+	clock.Advance(2 * time.Minute)
+	result, err := as.Login(login)
+	require.NoError(t, err, "the lockout expired")
+	assert.Zero(t, result.User.FailedLoginAttempts)
+	assert.Nil(t, result.User.LockedUntil)
+}
+
+func TestAuthService_TokenExpiry(t *testing.T) {
+	as, clock, login := newClockedTestService(t, "Str0ng!Passw0rd")
+
+	result, err := as.Login(login)
+	require.NoError(t, err)
+	assert.Equal(t, clock.Now().Add(24*time.Hour), result.ExpiresAt.UTC())
+
+	clock.Advance(23 * time.Hour)
+	_, err = as.ValidateToken(result.AccessToken)
+	require.NoError(t, err)
+
+	clock.Advance(time.Hour)
+	_, err = as.ValidateToken(result.AccessToken)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+	_, err = as.RefreshToken(result.RefreshToken)
+	assert.Error(t, err, "expired sessions can't be refreshed")
+}
+
+// migratedColumnLength is the length limit the migrations leave a column
+// of sa3d.user_sessions with, or 0 for an unbounded TEXT column
+func migratedColumnLength(t *testing.T, column string) int {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("..", "..", "scripts", "migrations", "*.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	declaration := regexp.MustCompile(`(?i)\b` + column + `\s+(?:TYPE\s+)?(VARCHAR\((\d+)\)|TEXT)`)
+	length := -1
+	// Glob sorts the numbered migrations in the order they are applied
+	for _, path := range paths {
+		script, err := os.ReadFile(path)
+		require.NoError(t, err)
+		for _, match := range declaration.FindAllStringSubmatch(string(script), -1) {
+			length = 0
+			if match[2] != "" {
+				length, err = strconv.Atoi(match[2])
+				require.NoError(t, err)
+			}
+		}
+	}
+	require.NotEqual(t, -1, length, "no migration declares user_sessions.%s", column)
+	return length
+}
+
+func TestAuthService_TokensFitSessionColumns(t *testing.T) {
+	as, db := newAdminTestService(t)
+	const password = "Str0ng!Passw0rd"
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	// The access token carries the email, so a long one makes it longer
+	user := &models.User{Email: strings.Repeat("a", 200) + "@example.com", Username: "long_email", Password: string(hash), IsActive: true}
+	require.NoError(t, db.Create(user).Error)
+
+	result, err := as.Login(UserLogin{Email: user.Email, Password: password})
+	require.NoError(t, err)
+
+	for column, token := range map[string]string{"session_token": result.AccessToken, "refresh_token": result.RefreshToken} {
+		if limit := migratedColumnLength(t, column); limit > 0 {
+			assert.LessOrEqual(t, len(token), limit, "user_sessions.%s is too short for the token", column)
+		}
+	}
+}
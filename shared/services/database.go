@@ -9,6 +9,7 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
+	"github.com/sa3d-modernized/sa3d/shared/models"
 	"github.com/sa3d-modernized/sa3d/shared/utils"
 )
 
@@ -20,11 +21,17 @@ type DatabaseConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+
+	// ReplicaHost and ReplicaPort point at an optional read replica.
+	// When ReplicaHost is empty, reads are served by the primary.
+	ReplicaHost string
+	ReplicaPort string
 }
 
 // DatabaseService handles database connections and operations
 type DatabaseService struct {
 	DB     *gorm.DB
+	readDB *gorm.DB
 	config DatabaseConfig
 	logger *logrus.Logger
 }
@@ -37,15 +44,25 @@ func NewDatabaseService(secretManager *utils.SecretManager, logger *logrus.Logge
 		return nil, fmt.Errorf("failed to get database credentials: %w", err)
 	}
 
+	replicaHost, replicaPort := secretManager.GetDatabaseReplicaCredentials()
+
 	config := DatabaseConfig{
-		Host:     host,
-		Port:     port,
-		User:     user,
-		Password: password,
-		DBName:   dbname,
-		SSLMode:  sslmode,
+		Host:        host,
+		Port:        port,
+		User:        user,
+		Password:    password,
+		DBName:      dbname,
+		SSLMode:     sslmode,
+		ReplicaHost: replicaHost,
+		ReplicaPort: replicaPort,
 	}
 
+	return NewDatabaseServiceWithConfig(config, logger)
+}
+
+// NewDatabaseServiceWithConfig creates a database service connected with
+// config, for services that load their credentials themselves
+func NewDatabaseServiceWithConfig(config DatabaseConfig, logger *logrus.Logger) (*DatabaseService, error) {
 	service := &DatabaseService{
 		config: config,
 		logger: logger,
@@ -58,11 +75,40 @@ func NewDatabaseService(secretManager *utils.SecretManager, logger *logrus.Logge
 	return service, nil
 }
 
+// NewDatabaseServiceFromDB wraps already opened primary and replica handles.
+// The replica may be nil, in which case reads are served by the primary.
+func NewDatabaseServiceFromDB(primary, replica *gorm.DB, logger *logrus.Logger) *DatabaseService {
+	return &DatabaseService{
+		DB:     primary,
+		readDB: replica,
+		logger: logger,
+	}
+}
+
 // Connect establishes connection to the database
 func (ds *DatabaseService) Connect() error {
-	// Build DSN (Data Source Name)
-	dsn := ds.buildDSN()
+	db, err := ds.open(ds.buildDSN(ds.config.Host, ds.config.Port))
+	if err != nil {
+		return err
+	}
+	ds.DB = db
+	ds.logger.Info("Database connection established successfully")
 
+	// Connect to the read replica if one is configured
+	if ds.config.ReplicaHost != "" {
+		replica, err := ds.open(ds.buildDSN(ds.config.ReplicaHost, ds.config.ReplicaPort))
+		if err != nil {
+			return fmt.Errorf("failed to connect to read replica: %w", err)
+		}
+		ds.readDB = replica
+		ds.logger.WithField("replica_host", ds.config.ReplicaHost).Info("Read replica connection established successfully")
+	}
+
+	return nil
+}
+
+// open opens and configures a GORM connection for the given DSN
+func (ds *DatabaseService) open(dsn string) (*gorm.DB, error) {
 	// Configure GORM logger
 	gormLogger := logger.New(
 		ds.logger,
@@ -77,19 +123,20 @@ func (ds *DatabaseService) Connect() error {
 	// Open database connection
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
 		Logger: gormLogger,
-		NowFunc: func() time.Time {
-			return time.Now().UTC()
-		},
+		NowFunc: utils.Now,
 		DisableForeignKeyConstraintWhenMigrating: false,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := models.UseUTC(db); err != nil {
+		return nil, fmt.Errorf("failed to configure database timestamps: %w", err)
 	}
 
 	// Get underlying SQL DB for connection pool configuration
 	sqlDB, err := db.DB()
 	if err != nil {
-		return fmt.Errorf("failed to get underlying database connection: %w", err)
+		return nil, fmt.Errorf("failed to get underlying database connection: %w", err)
 	}
 
 	// Configure connection pool
@@ -98,18 +145,15 @@ func (ds *DatabaseService) Connect() error {
 	sqlDB.SetConnMaxLifetime(time.Hour)        // Maximum amount of time a connection may be reused
 	sqlDB.SetConnMaxIdleTime(10 * time.Minute) // Maximum amount of time a connection may be idle
 
-	ds.DB = db
-	ds.logger.Info("Database connection established successfully")
-
-	return nil
+	return db, nil
 }
 
-// buildDSN constructs the database connection string
-func (ds *DatabaseService) buildDSN() string {
+// buildDSN constructs the database connection string for the given host
+func (ds *DatabaseService) buildDSN(host, port string) string {
 	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s TimeZone=UTC",
-		ds.config.Host,
-		ds.config.Port,
+		host,
+		port,
 		ds.config.User,
 		ds.config.Password,
 		ds.config.DBName,
@@ -131,6 +175,19 @@ func (ds *DatabaseService) Close() error {
 		
 		ds.logger.Info("Database connection closed")
 	}
+
+	if ds.readDB != nil {
+		sqlDB, err := ds.readDB.DB()
+		if err != nil {
+			return fmt.Errorf("failed to get underlying read replica connection: %w", err)
+		}
+
+		if err := sqlDB.Close(); err != nil {
+			return fmt.Errorf("failed to close read replica connection: %w", err)
+		}
+
+		ds.logger.Info("Read replica connection closed")
+	}
 	return nil
 }
 
@@ -149,6 +206,17 @@ func (ds *DatabaseService) Health() error {
 		return fmt.Errorf("database ping failed: %w", err)
 	}
 
+	if ds.readDB != nil {
+		replicaDB, err := ds.readDB.DB()
+		if err != nil {
+			return fmt.Errorf("failed to get underlying read replica connection: %w", err)
+		}
+
+		if err := replicaDB.Ping(); err != nil {
+			return fmt.Errorf("read replica ping failed: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -198,6 +266,16 @@ func (ds *DatabaseService) GetDB() *gorm.DB {
 	return ds.DB
 }
 
+// ReadDB returns the GORM instance to use for read-only queries.
+// It is the read replica when one is configured and the primary otherwise.
+// Reads that must observe a write made in the same request should use DB.
+func (ds *DatabaseService) ReadDB() *gorm.DB {
+	if ds.readDB != nil {
+		return ds.readDB
+	}
+	return ds.DB
+}
+
 // Stats returns database connection statistics
 func (ds *DatabaseService) Stats() (map[string]interface{}, error) {
 	if ds.DB == nil {
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+)
+
+func TestDatabaseService_ReadDB(t *testing.T) {
+	t.Run("falls back to primary without replica", func(t *testing.T) {
+		primary := testutil.NewTestDB(t)
+		ds := NewDatabaseServiceFromDB(primary, nil, testutil.NewTestLogger())
+
+		assert.Same(t, primary, ds.ReadDB())
+		assert.Same(t, primary, ds.GetDB())
+	})
+
+	t.Run("uses replica when configured", func(t *testing.T) {
+		primary := testutil.NewTestDB(t)
+		replica := testutil.NewTestDB(t)
+		ds := NewDatabaseServiceFromDB(primary, replica, testutil.NewTestLogger())
+
+		assert.Same(t, replica, ds.ReadDB())
+		assert.Same(t, primary, ds.GetDB())
+	})
+}
+
+func TestDatabaseService_ReadWriteSplitting(t *testing.T) {
+	primary := testutil.NewTestDB(t, &models.User{}, &models.UserSession{})
+	replica := testutil.NewTestDB(t, &models.User{}, &models.UserSession{})
+	ds := NewDatabaseServiceFromDB(primary, replica, testutil.NewTestLogger())
+	authService := NewAuthService(ds, testutil.NewTestLogger())
+
+	user := &models.User{Email: "replica@example.com", Username: "replica", Password: "hash", IsActive: true}
+	require.NoError(t, replica.Create(user).Error)
+
+	t.Run("reads are served by the replica", func(t *testing.T) {
+		found, err := authService.GetUserByID(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user.Email, found.Email)
+	})
+
+	t.Run("reads do not see primary-only rows", func(t *testing.T) {
+		primaryOnly := &models.User{Email: "primary@example.com", Username: "primary", Password: "hash", IsActive: true}
+		require.NoError(t, primary.Create(primaryOnly).Error)
+
+		_, err := authService.GetUserByID(primaryOnly.ID)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+
+	t.Run("writes go to the primary", func(t *testing.T) {
+		session := &models.UserSession{
+			UserID:       user.ID,
+			SessionToken: "session-token",
+			RefreshToken: "refresh-token",
+			ExpiresAt:    time.Now().Add(time.Hour),
+			IsActive:     true,
+		}
+		require.NoError(t, primary.Create(session).Error)
+		replicaCopy := *session
+		require.NoError(t, replica.Create(&replicaCopy).Error)
+
+		require.NoError(t, authService.Logout(user.ID, "session-token"))
+
+		var onPrimary, onReplica models.UserSession
+		require.NoError(t, primary.First(&onPrimary, "id = ?", session.ID).Error)
+		require.NoError(t, replica.First(&onReplica, "id = ?", session.ID).Error)
+		assert.False(t, onPrimary.IsActive)
+		assert.True(t, onReplica.IsActive)
+	})
+}
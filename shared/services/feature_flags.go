@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Feature flag names, as written under features in the config, after
+// FeatureFlagEnvPrefix in the environment and as fields of the Redis hash.
+// There is no flag enforcing two-factor authentication, which doesn't exist
+// yet, nor one choosing mock auth: the gateway always serves the production
+// auth handler and the mock one remains a test double.
+const (
+	// FlagRequireEmailVerification refuses logins to unverified accounts
+	FlagRequireEmailVerification = "require_email_verification"
+	// FlagEventPublishing publishes analysis events to Kafka
+	FlagEventPublishing = "event_publishing"
+	// FlagMaintenance puts the gateway into maintenance mode
+	FlagMaintenance = "maintenance"
+)
+
+// FeatureFlagEnvPrefix prefixes environment variables overriding a flag:
+// FEATURE_REQUIRE_EMAIL_VERIFICATION=true turns require_email_verification on
+const FeatureFlagEnvPrefix = "FEATURE_"
+
+// FeatureFlagsRedisKey names the Redis hash whose fields override the
+// configured flags at runtime, as in HSET feature_flags event_publishing false
+const FeatureFlagsRedisKey = "feature_flags"
+
+// ErrInvalidFeatureFlag is returned for a flag value that isn't a boolean
+var ErrInvalidFeatureFlag = errors.New("invalid feature flag")
+
+// FeatureFlagLoader returns flag values to apply over the configured ones,
+// such as the fields of FeatureFlagsRedisKey
+type FeatureFlagLoader func(ctx context.Context) (map[string]string, error)
+
+// FeatureFlags toggles behavior at runtime. Flags come from the config and
+// the environment when a service starts and may be overridden later with
+// Refresh. A flag that is set nowhere is off, as is every flag of a nil
+// FeatureFlags. It is safe for concurrent use.
+type FeatureFlags struct {
+	mu         sync.RWMutex
+	configured map[string]bool
+	overrides  map[string]bool
+}
+
+// NewFeatureFlags creates feature flags with the configured values
+func NewFeatureFlags(configured map[string]bool) *FeatureFlags {
+	flags := &FeatureFlags{configured: make(map[string]bool, len(configured))}
+	for name, enabled := range configured {
+		flags.configured[normalizeFlagName(name)] = enabled
+	}
+	return flags
+}
+
+// LoadFeatureFlags creates feature flags with the configured values
+// overridden by the FeatureFlagEnvPrefix variables of environ, which is in
+// the form of os.Environ
+func LoadFeatureFlags(configured map[string]bool, environ []string) (*FeatureFlags, error) {
+	values := make(map[string]string)
+	for _, entry := range environ {
+		name, value, ok := strings.Cut(entry, "=")
+		if ok && strings.HasPrefix(name, FeatureFlagEnvPrefix) {
+			values[strings.TrimPrefix(name, FeatureFlagEnvPrefix)] = value
+		}
+	}
+	fromEnv, err := ParseFeatureFlags(values)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := NewFeatureFlags(configured)
+	for name, enabled := range fromEnv {
+		flags.configured[name] = enabled
+	}
+	return flags, nil
+}
+
+// ParseFeatureFlags parses flag values as strconv.ParseBool does. Names are
+// case-insensitive; every value that doesn't parse is reported.
+func ParseFeatureFlags(values map[string]string) (map[string]bool, error) {
+	flags := make(map[string]bool, len(values))
+	var errs []error
+	for name, value := range values {
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s=%q", ErrInvalidFeatureFlag, name, value))
+			continue
+		}
+		flags[normalizeFlagName(name)] = enabled
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return flags, nil
+}
+
+// Enabled reports whether the named flag is on. Overrides take precedence
+// over the configured value.
+func (f *FeatureFlags) Enabled(name string) bool {
+	if f == nil {
+		return false
+	}
+	name = normalizeFlagName(name)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if enabled, ok := f.overrides[name]; ok {
+		return enabled
+	}
+	return f.configured[name]
+}
+
+// RequireEmailVerification reports whether unverified accounts may not log in
+func (f *FeatureFlags) RequireEmailVerification() bool {
+	return f.Enabled(FlagRequireEmailVerification)
+}
+
+// EventPublishing reports whether analysis events are published
+func (f *FeatureFlags) EventPublishing() bool {
+	return f.Enabled(FlagEventPublishing)
+}
+
+// Maintenance reports whether the gateway is in maintenance mode
+func (f *FeatureFlags) Maintenance() bool {
+	return f.Enabled(FlagMaintenance)
+}
+
+// Reload replaces the configured flags with configured overridden by
+// environ, as LoadFeatureFlags reads them, keeping the current overrides.
+// Nothing changes when a variable doesn't parse.
+func (f *FeatureFlags) Reload(configured map[string]bool, environ []string) error {
+	loaded, err := LoadFeatureFlags(configured, environ)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.configured = loaded.configured
+	f.mu.Unlock()
+	return nil
+}
+
+// SetOverrides replaces the flags overriding the configured ones; flags
+// missing from overrides revert to their configured values
+func (f *FeatureFlags) SetOverrides(overrides map[string]bool) {
+	normalized := make(map[string]bool, len(overrides))
+	for name, enabled := range overrides {
+		normalized[normalizeFlagName(name)] = enabled
+	}
+	f.mu.Lock()
+	f.overrides = normalized
+	f.mu.Unlock()
+}
+
+// Refresh replaces the overrides with the flags load returns. The current
+// overrides are kept when loading or parsing fails.
+func (f *FeatureFlags) Refresh(ctx context.Context, load FeatureFlagLoader) error {
+	values, err := load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load feature flags: %w", err)
+	}
+	overrides, err := ParseFeatureFlags(values)
+	if err != nil {
+		return err
+	}
+	f.SetOverrides(overrides)
+	return nil
+}
+
+// RunRefresh refreshes the flags every interval until ctx is cancelled,
+// logging failed refreshes
+func (f *FeatureFlags) RunRefresh(ctx context.Context, load FeatureFlagLoader, interval time.Duration, logger *logrus.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.Refresh(ctx, load); err != nil && ctx.Err() == nil {
+			logger.WithError(err).Warn("Failed to refresh feature flags")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func normalizeFlagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
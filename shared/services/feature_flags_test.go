@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+)
+
+func TestParseFeatureFlags(t *testing.T) {
+	flags, err := ParseFeatureFlags(map[string]string{
+		"Event_Publishing":           "true",
+		"require_email_verification": " 0 ",
+		"beta_dashboard":             "T",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{
+		FlagEventPublishing:          true,
+		FlagRequireEmailVerification: false,
+		"beta_dashboard":             true,
+	}, flags)
+
+	_, err = ParseFeatureFlags(map[string]string{"event_publishing": "yes", "beta_dashboard": ""})
+	require.ErrorIs(t, err, ErrInvalidFeatureFlag)
+	assert.Contains(t, err.Error(), `event_publishing="yes"`)
+	assert.Contains(t, err.Error(), `beta_dashboard=""`)
+}
+
+func TestFeatureFlags_DefaultOff(t *testing.T) {
+	var unset *FeatureFlags
+	assert.False(t, unset.Enabled(FlagEventPublishing))
+	assert.False(t, unset.RequireEmailVerification())
+
+	flags := NewFeatureFlags(nil)
+	assert.False(t, flags.RequireEmailVerification())
+	assert.False(t, flags.EventPublishing())
+	assert.False(t, flags.Enabled("unknown"))
+}
+
+func TestLoadFeatureFlags(t *testing.T) {
+	flags, err := LoadFeatureFlags(
+		map[string]bool{FlagEventPublishing: true, FlagRequireEmailVerification: false},
+		[]string{"PATH=/usr/bin", "FEATURE_REQUIRE_EMAIL_VERIFICATION=true", "FEATURE_BETA=false"},
+	)
+	require.NoError(t, err)
+	assert.True(t, flags.EventPublishing())
+	assert.True(t, flags.RequireEmailVerification())
+	assert.False(t, flags.Enabled("beta"))
+
+	_, err = LoadFeatureFlags(nil, []string{"FEATURE_EVENT_PUBLISHING=on"})
+	assert.ErrorIs(t, err, ErrInvalidFeatureFlag)
+}
+
+func TestFeatureFlags_Reload(t *testing.T) {
+	flags := NewFeatureFlags(map[string]bool{FlagEventPublishing: true})
+	flags.SetOverrides(map[string]bool{"beta": true})
+
+	require.NoError(t, flags.Reload(map[string]bool{FlagEventPublishing: false}, []string{"FEATURE_REQUIRE_EMAIL_VERIFICATION=1"}))
+	assert.False(t, flags.EventPublishing())
+	assert.True(t, flags.RequireEmailVerification())
+	assert.True(t, flags.Enabled("beta"), "overrides are kept")
+
+	assert.ErrorIs(t, flags.Reload(nil, []string{"FEATURE_BETA=maybe"}), ErrInvalidFeatureFlag)
+	assert.True(t, flags.RequireEmailVerification(), "a failed reload changes nothing")
+}
+
+func TestFeatureFlags_Refresh(t *testing.T) {
+	flags := NewFeatureFlags(map[string]bool{FlagEventPublishing: true})
+	ctx := context.Background()
+
+	load := func(values map[string]string, err error) FeatureFlagLoader {
+		return func(context.Context) (map[string]string, error) { return values, err }
+	}
+
+	require.NoError(t, flags.Refresh(ctx, load(map[string]string{"event_publishing": "false", "require_email_verification": "1"}, nil)))
+	assert.False(t, flags.EventPublishing())
+	assert.True(t, flags.RequireEmailVerification())
+
+	// Failed refreshes keep the current overrides
+	assert.Error(t, flags.Refresh(ctx, load(nil, errors.New("connection refused"))))
+	assert.ErrorIs(t, flags.Refresh(ctx, load(map[string]string{"event_publishing": "maybe"}, nil)), ErrInvalidFeatureFlag)
+	assert.False(t, flags.EventPublishing())
+
+	// Flags no longer overridden revert to their configured values
+	require.NoError(t, flags.Refresh(ctx, load(map[string]string{}, nil)))
+	assert.True(t, flags.EventPublishing())
+	assert.False(t, flags.RequireEmailVerification())
+}
+
+func TestAuthService_LoginRequiresEmailVerification(t *testing.T) {
+	const password = "Str0ng!Passw0rd"
+
+	db := testutil.NewTestDB(t, &models.User{}, &models.UserSession{})
+	logger := testutil.NewTestLogger()
+	as := NewAuthService(NewDatabaseServiceFromDB(db, nil, logger), logger)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &models.User{Email: "unverified@example.com", Username: "unverified", Password: string(hash), IsActive: true}
+	require.NoError(t, db.Create(user).Error)
+	login := UserLogin{Email: user.Email, Password: password}
+
+	// Off by default
+	_, err = as.Login(login)
+	require.NoError(t, err)
+
+	features := NewFeatureFlags(nil)
+	features.SetOverrides(map[string]bool{FlagRequireEmailVerification: true})
+	as.SetFeatureFlags(features)
+	_, err = as.Login(login)
+	assert.ErrorIs(t, err, ErrAccountNotVerified)
+}
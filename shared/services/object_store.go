@@ -0,0 +1,231 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultObjectStoreRegion is the region requests are signed for when an
+// ObjectStoreConfig names none, which S3-compatible stores such as MinIO
+// accept
+const DefaultObjectStoreRegion = "us-east-1"
+
+// ErrObjectStore is returned when an object store refuses a request
+var ErrObjectStore = errors.New("object store request failed")
+
+// ObjectStoreConfig locates an S3-compatible bucket and the credentials
+// requests are signed with
+type ObjectStoreConfig struct {
+	// Endpoint is the store's base URL, as in "https://s3.eu-west-1.amazonaws.com"
+	// or "http://minio:9000". Buckets are addressed path-style under it.
+	Endpoint string
+	Bucket   string
+	// Region defaults to DefaultObjectStoreRegion
+	Region string
+	// Prefix is prepended to every object key, as in "sources/"
+	Prefix    string
+	AccessKey string
+	SecretKey string
+}
+
+// Validate reports every missing or malformed setting of the config
+func (c ObjectStoreConfig) Validate() error {
+	var errs []error
+	if endpoint, err := url.Parse(c.Endpoint); err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		errs = append(errs, fmt.Errorf("endpoint must be an http or https URL: %q", c.Endpoint))
+	}
+	if c.Bucket == "" || strings.Contains(c.Bucket, "/") {
+		errs = append(errs, fmt.Errorf("bucket must be a bucket name: %q", c.Bucket))
+	}
+	if c.AccessKey == "" || c.SecretKey == "" {
+		errs = append(errs, errors.New("access key and secret key are required"))
+	}
+	return errors.Join(errs...)
+}
+
+// ObjectSourceStore keeps source files as objects of an S3-compatible
+// bucket, keyed "<prefix><project>/<path>", signing requests with AWS
+// Signature Version 4
+type ObjectSourceStore struct {
+	config   ObjectStoreConfig
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewObjectSourceStore creates a store on the bucket of config, sending
+// requests with client (http.DefaultClient when nil)
+func NewObjectSourceStore(config ObjectStoreConfig, client *http.Client) (*ObjectSourceStore, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid object store config: %w", err)
+	}
+	if config.Region == "" {
+		config.Region = DefaultObjectStoreRegion
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	endpoint, _ := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	return &ObjectSourceStore{config: config, endpoint: endpoint, client: client, now: time.Now}, nil
+}
+
+// Get implements SourceStore
+func (s *ObjectSourceStore) Get(ctx context.Context, projectID, filePath string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, projectID, filePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, filePath)
+	case resp.StatusCode != http.StatusOK:
+		return nil, responseError(resp)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source object: %w", err)
+	}
+	return content, nil
+}
+
+// Put implements SourceStore
+func (s *ObjectSourceStore) Put(ctx context.Context, projectID, filePath string, content []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, projectID, filePath, content)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// Delete implements SourceStore
+func (s *ObjectSourceStore) Delete(ctx context.Context, projectID, filePath string) error {
+	resp, err := s.do(ctx, http.MethodDelete, projectID, filePath, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+	return responseError(resp)
+}
+
+// do sends a signed request for the object of a project's file
+func (s *ObjectSourceStore) do(ctx context.Context, method, projectID, filePath string, body []byte) (*http.Response, error) {
+	key, err := sourceKey(projectID, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	target := *s.endpoint
+	target.Path += "/" + s.config.Bucket + "/" + s.config.Prefix + key
+	target.RawPath = s.endpoint.EscapedPath() + "/" + uriEncode(s.config.Bucket, false) + "/" + uriEncode(s.config.Prefix+key, true)
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create object store request: %w", err)
+	}
+	s.sign(req, body, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %s: %w", ErrObjectStore, method, key, err)
+	}
+	return resp, nil
+}
+
+// sign adds the Signature Version 4 authorization of req, covering its host
+// and every header set on it
+func (s *ObjectSourceStore) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256.Sum256(body)
+	timestamp := now.UTC().Format("20060102T150405Z")
+	date := timestamp[:8]
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	req.Header.Set("X-Amz-Date", timestamp)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	for _, part := range []string{s.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode percent-encodes s as Signature Version 4 requires: every byte
+// but unreserved characters and, with keepSlash, slashes
+func uriEncode(s string, keepSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && keepSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
+
+// responseError describes a refused request by its status and the start of
+// the store's error document
+func responseError(resp *http.Response) error {
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%w: %s %s: %s: %s", ErrObjectStore, resp.Request.Method, resp.Request.URL.Path,
+		resp.Status, strings.TrimSpace(string(detail)))
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported password hashing algorithms
+const (
+	HashAlgorithmBcrypt   = "bcrypt"
+	HashAlgorithmArgon2id = "argon2id"
+)
+
+var (
+	ErrUnsupportedHashAlgorithm = errors.New("unsupported password hash algorithm")
+	ErrInvalidPasswordHash      = errors.New("invalid password hash")
+)
+
+// PasswordHasher hashes and verifies passwords. Encoded hashes carry an
+// algorithm identifier prefix ("$2a$" for bcrypt, "$argon2id$" for Argon2id)
+// so hashes produced by any supported algorithm can be verified.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of password
+	Hash(password string) (string, error)
+	// Verify reports whether password matches the encoded hash
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether encoded was produced by a different
+	// algorithm or with different parameters than Hash would use now
+	NeedsRehash(encoded string) bool
+}
+
+// algorithmHasher is a PasswordHasher for a single algorithm
+type algorithmHasher interface {
+	PasswordHasher
+	// Recognizes reports whether encoded uses this hasher's algorithm
+	Recognizes(encoded string) bool
+}
+
+// NewPasswordHasher creates a hasher that hashes new passwords with the given
+// algorithm and verifies hashes produced by any supported algorithm
+func NewPasswordHasher(algorithm string) (PasswordHasher, error) {
+	bcryptHasher := NewBcryptHasher(bcrypt.DefaultCost)
+	argon2idHasher := NewArgon2idHasher()
+
+	var preferred algorithmHasher
+	switch strings.ToLower(algorithm) {
+	case "", HashAlgorithmBcrypt:
+		preferred = bcryptHasher
+	case HashAlgorithmArgon2id:
+		preferred = argon2idHasher
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedHashAlgorithm, algorithm)
+	}
+
+	return &multiHasher{
+		preferred: preferred,
+		hashers:   []algorithmHasher{bcryptHasher, argon2idHasher},
+	}, nil
+}
+
+// multiHasher hashes with a preferred algorithm and verifies with whichever
+// algorithm produced the stored hash
+type multiHasher struct {
+	preferred algorithmHasher
+	hashers   []algorithmHasher
+}
+
+func (m *multiHasher) Hash(password string) (string, error) {
+	return m.preferred.Hash(password)
+}
+
+func (m *multiHasher) Verify(password, encoded string) (bool, error) {
+	for _, h := range m.hashers {
+		if h.Recognizes(encoded) {
+			return h.Verify(password, encoded)
+		}
+	}
+	return false, ErrUnsupportedHashAlgorithm
+}
+
+func (m *multiHasher) NeedsRehash(encoded string) bool {
+	return m.preferred.NeedsRehash(encoded)
+}
+
+// BcryptHasher hashes passwords with bcrypt
+type BcryptHasher struct {
+	Cost int
+}
+
+// NewBcryptHasher creates a bcrypt hasher with the given cost
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{Cost: cost}
+}
+
+func (b *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(hash), err
+}
+
+func (b *BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidPasswordHash, err)
+	}
+	return true, nil
+}
+
+func (b *BcryptHasher) NeedsRehash(encoded string) bool {
+	if !b.Recognizes(encoded) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != b.Cost
+}
+
+func (b *BcryptHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+// Argon2idHasher hashes passwords with Argon2id and encodes them in the
+// standard "$argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>" form
+type Argon2idHasher struct {
+	Time       uint32
+	MemoryKiB  uint32
+	Threads    uint8
+	KeyLength  uint32
+	SaltLength uint32
+}
+
+// NewArgon2idHasher creates an Argon2id hasher with the parameters
+// recommended by RFC 9106 for memory-constrained environments
+func NewArgon2idHasher() *Argon2idHasher {
+	return &Argon2idHasher{
+		Time:       3,
+		MemoryKiB:  64 * 1024,
+		Threads:    4,
+		KeyLength:  32,
+		SaltLength: 16,
+	}
+}
+
+const argon2idPrefix = "$argon2id$"
+
+func (a *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Time, a.MemoryKiB, a.Threads, a.KeyLength)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		a.MemoryKiB, a.Time, a.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Time, params.MemoryKiB, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+func (a *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Time != a.Time ||
+		params.MemoryKiB != a.MemoryKiB ||
+		params.Threads != a.Threads ||
+		uint32(len(key)) != a.KeyLength ||
+		uint32(len(salt)) != a.SaltLength
+}
+
+func (a *Argon2idHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+// decodeArgon2id parses an encoded Argon2id hash into its parameters, salt and key
+func decodeArgon2id(encoded string) (*Argon2idHasher, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != HashAlgorithmArgon2id {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	params := &Argon2idHasher{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.MemoryKiB, &params.Time, &params.Threads); err != nil {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+
+	return params, salt, key, nil
+}
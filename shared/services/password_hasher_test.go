@@ -0,0 +1,125 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+)
+
+func TestNewPasswordHasher(t *testing.T) {
+	tests := []struct {
+		algorithm  string
+		wantPrefix string
+		wantErr    error
+	}{
+		{"", "$2a$", nil},
+		{"bcrypt", "$2a$", nil},
+		{"argon2id", "$argon2id$v=19$", nil},
+		{"ARGON2ID", "$argon2id$v=19$", nil},
+		{"md5", "", ErrUnsupportedHashAlgorithm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			hasher, err := NewPasswordHasher(tt.algorithm)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			hash, err := hasher.Hash("Str0ng!Passw0rd")
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(hash, tt.wantPrefix), hash)
+			assert.False(t, hasher.NeedsRehash(hash))
+		})
+	}
+}
+
+func TestPasswordHasher_CrossAlgorithmVerification(t *testing.T) {
+	const password = "Str0ng!Passw0rd"
+
+	bcryptHasher, err := NewPasswordHasher(HashAlgorithmBcrypt)
+	require.NoError(t, err)
+	argon2idHasher, err := NewPasswordHasher(HashAlgorithmArgon2id)
+	require.NoError(t, err)
+
+	bcryptHash, err := bcryptHasher.Hash(password)
+	require.NoError(t, err)
+	argon2idHash, err := argon2idHasher.Hash(password)
+	require.NoError(t, err)
+
+	for name, hasher := range map[string]PasswordHasher{"bcrypt": bcryptHasher, "argon2id": argon2idHasher} {
+		t.Run(name, func(t *testing.T) {
+			for _, hash := range []string{bcryptHash, argon2idHash} {
+				ok, err := hasher.Verify(password, hash)
+				require.NoError(t, err)
+				assert.True(t, ok)
+
+				ok, err = hasher.Verify("wrong-password", hash)
+				require.NoError(t, err)
+				assert.False(t, ok)
+			}
+		})
+	}
+
+	assert.True(t, argon2idHasher.NeedsRehash(bcryptHash))
+	assert.True(t, bcryptHasher.NeedsRehash(argon2idHash))
+
+	_, err = argon2idHasher.Verify(password, "plaintext")
+	assert.ErrorIs(t, err, ErrUnsupportedHashAlgorithm)
+	_, err = argon2idHasher.Verify(password, "$argon2id$v=19$garbage")
+	assert.ErrorIs(t, err, ErrInvalidPasswordHash)
+}
+
+func TestArgon2idHasher_NeedsRehash(t *testing.T) {
+	weak := &Argon2idHasher{Time: 1, MemoryKiB: 8 * 1024, Threads: 1, KeyLength: 32, SaltLength: 16}
+	hash, err := weak.Hash("Str0ng!Passw0rd")
+	require.NoError(t, err)
+
+	assert.False(t, weak.NeedsRehash(hash))
+	assert.True(t, NewArgon2idHasher().NeedsRehash(hash))
+}
+
+func TestAuthService_LoginUpgradesPasswordHash(t *testing.T) {
+	const password = "Str0ng!Passw0rd"
+
+	db := testutil.NewTestDB(t, &models.User{}, &models.UserSession{})
+	logger := testutil.NewTestLogger()
+	dbService := NewDatabaseServiceFromDB(db, nil, logger)
+
+	legacyHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	require.NoError(t, err)
+	user := &models.User{
+		Email:    "legacy@example.com",
+		Username: "legacy",
+		Password: string(legacyHash),
+		IsActive: true,
+	}
+	require.NoError(t, db.Create(user).Error)
+
+	hasher, err := NewPasswordHasher(HashAlgorithmArgon2id)
+	require.NoError(t, err)
+	as := NewAuthServiceWithHasher(dbService, hasher, logger)
+
+	_, err = as.Login(UserLogin{Email: user.Email, Password: password})
+	require.NoError(t, err)
+
+	var stored models.User
+	require.NoError(t, db.First(&stored, "id = ?", user.ID).Error)
+	assert.True(t, strings.HasPrefix(stored.Password, "$argon2id$"), "hash should be upgraded on login")
+	assert.False(t, hasher.NeedsRehash(stored.Password))
+
+	// The upgraded hash keeps working
+	_, err = as.Login(UserLogin{Email: user.Email, Password: password})
+	require.NoError(t, err)
+
+	_, err = as.Login(UserLogin{Email: user.Email, Password: "wrong-password"})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
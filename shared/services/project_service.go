@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/utils"
+)
+
+var (
+	ErrProjectNotFound  = errors.New("project not found")
+	ErrProjectForbidden = errors.New("not allowed to modify this project")
+	// ErrBranchNotFound is returned when a project's branch doesn't exist in its repository
+	ErrBranchNotFound = errors.New("branch not found")
+	// ErrRepositoryUnreachable is returned when a project's repository can't be listed
+	ErrRepositoryUnreachable = errors.New("repository unreachable")
+)
+
+// DefaultBranchFallbacks is the order in which branches are tried when a
+// project doesn't name one
+var DefaultBranchFallbacks = []string{"main", "master"}
+
+// AnalysisCacheCleaner removes cached data belonging to deleted analyses
+type AnalysisCacheCleaner interface {
+	CleanupAnalyses(analysisIDs []uuid.UUID) error
+}
+
+// ProjectService handles project lifecycle operations
+type ProjectService struct {
+	db           *DatabaseService
+	cacheCleaner AnalysisCacheCleaner
+	logger       *logrus.Logger
+
+	// sourceFetcher, when set, is used to check branches against the
+	// project's repository
+	sourceFetcher   SourceFetcher
+	branchFallbacks []string
+}
+
+// NewProjectService creates a new project service.
+// cacheCleaner is optional; when nil, cached analysis data is left to expire.
+func NewProjectService(db *DatabaseService, cacheCleaner AnalysisCacheCleaner, logger *logrus.Logger) *ProjectService {
+	return &ProjectService{
+		db:              db,
+		cacheCleaner:    cacheCleaner,
+		logger:          logger,
+		branchFallbacks: DefaultBranchFallbacks,
+	}
+}
+
+// SetSourceFetcher enables branch validation for projects with a repository
+func (ps *ProjectService) SetSourceFetcher(fetcher SourceFetcher) {
+	ps.sourceFetcher = fetcher
+}
+
+// SetBranchFallbacks sets the branches tried, in order, when a project
+// doesn't name one. An empty list keeps DefaultBranchFallbacks.
+func (ps *ProjectService) SetBranchFallbacks(branches []string) {
+	if len(branches) == 0 {
+		branches = DefaultBranchFallbacks
+	}
+	ps.branchFallbacks = branches
+}
+
+// ProjectUpdate holds the project fields to change; empty fields are left as they are
+type ProjectUpdate struct {
+	Name        string
+	Description string
+	Language    string
+	Repository  string
+	Branch      string
+	// NotificationEmails and NotificationWebhooks replace the project's
+	// recipients of analysis summaries unless nil; an empty list removes them
+	NotificationEmails   []string
+	NotificationWebhooks []string
+	// EnabledLanguages replaces the languages analyzed unless nil; an empty
+	// list enables all of them
+	EnabledLanguages []string
+	MinSeverity      string
+}
+
+// CreateProject stores a new project. When the project has a repository
+// and a source fetcher is set, its branch is checked against the
+// repository; an empty branch resolves to the first fallback that exists.
+func (ps *ProjectService) CreateProject(ctx context.Context, project *models.Project) error {
+	branch, err := ps.resolveBranch(ctx, project.Repository, project.Branch)
+	if err != nil {
+		return err
+	}
+	project.Branch = branch
+
+	if err := ps.db.DB.WithContext(ctx).Create(project).Error; err != nil {
+		return fmt.Errorf("failed to create project: %w", err)
+	}
+
+	ps.logger.WithFields(logrus.Fields{
+		"project_id": project.ID,
+		"user_id":    project.CreatedBy,
+		"branch":     project.Branch,
+		"request_id": utils.RequestIDFromContext(ctx),
+	}).Info("Project created")
+
+	return nil
+}
+
+// UpdateProject applies an update to a project. Only the project owner or an
+// admin may update a project. The branch is re-validated whenever the
+// repository or branch changes.
+func (ps *ProjectService) UpdateProject(ctx context.Context, projectID, userID uuid.UUID, userRole string, update ProjectUpdate) (*models.Project, error) {
+	var project models.Project
+	if err := ps.db.DB.WithContext(ctx).Where("id = ?", projectID).First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, fmt.Errorf("failed to find project: %w", err)
+	}
+
+	if project.CreatedBy != userID && !isAdminRole(userRole) {
+		return nil, ErrProjectForbidden
+	}
+
+	if update.Name != "" {
+		project.Name = update.Name
+	}
+	if update.Description != "" {
+		project.Description = update.Description
+	}
+	if update.Language != "" {
+		project.Language = update.Language
+	}
+	if update.Repository != "" || update.Branch != "" {
+		if update.Repository != "" {
+			project.Repository = update.Repository
+		}
+		// A new repository without a branch falls back like a new project
+		resolved, err := ps.resolveBranch(ctx, project.Repository, update.Branch)
+		if err != nil {
+			return nil, err
+		}
+		project.Branch = resolved
+	}
+	if update.NotificationEmails != nil {
+		project.Settings.NotificationEmails = strings.Join(update.NotificationEmails, ",")
+	}
+	if update.NotificationWebhooks != nil {
+		project.Settings.NotificationWebhooks = strings.Join(update.NotificationWebhooks, ",")
+	}
+	if update.EnabledLanguages != nil {
+		project.Settings.EnabledLanguages = strings.Join(update.EnabledLanguages, ",")
+	}
+	if update.MinSeverity != "" {
+		project.Settings.MinSeverity = update.MinSeverity
+	}
+
+	if err := ps.db.DB.WithContext(ctx).Save(&project).Error; err != nil {
+		return nil, fmt.Errorf("failed to update project: %w", err)
+	}
+
+	ps.logger.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"user_id":    userID,
+		"request_id": utils.RequestIDFromContext(ctx),
+	}).Info("Project updated")
+
+	return &project, nil
+}
+
+// resolveBranch checks branch against the repository. An empty branch
+// resolves to the first fallback the repository has, then to the
+// repository's default branch. Without a repository or source fetcher the
+// branch is taken as given, defaulting to the first fallback.
+func (ps *ProjectService) resolveBranch(ctx context.Context, repository, branch string) (string, error) {
+	if repository == "" || ps.sourceFetcher == nil {
+		if branch == "" && len(ps.branchFallbacks) > 0 {
+			branch = ps.branchFallbacks[0]
+		}
+		return branch, nil
+	}
+
+	branches, defaultBranch, err := ps.sourceFetcher.ListBranches(ctx, repository)
+	if errors.Is(err, ErrUnsupportedRepository) {
+		return "", err
+	}
+	if err != nil {
+		ps.logger.WithError(err).WithFields(logrus.Fields{
+			"repository": repository,
+			"request_id": utils.RequestIDFromContext(ctx),
+		}).Warn("Failed to list repository branches")
+		return "", fmt.Errorf("%w: %s", ErrRepositoryUnreachable, repository)
+	}
+
+	exists := make(map[string]bool, len(branches))
+	for _, name := range branches {
+		exists[name] = true
+	}
+
+	if branch != "" {
+		if !exists[branch] {
+			return "", fmt.Errorf("%w: %s", ErrBranchNotFound, branch)
+		}
+		return branch, nil
+	}
+
+	for _, fallback := range ps.branchFallbacks {
+		if exists[fallback] {
+			return fallback, nil
+		}
+	}
+	if defaultBranch != "" && exists[defaultBranch] {
+		return defaultBranch, nil
+	}
+	return "", fmt.Errorf("%w: none of %s", ErrBranchNotFound, strings.Join(ps.branchFallbacks, ", "))
+}
+
+// DeleteProject soft-deletes a project together with its analyses,
+// visualizations, collaboration sessions, participants and annotations.
+// Only the project owner or an admin may delete a project.
+func (ps *ProjectService) DeleteProject(projectID, userID uuid.UUID, userRole string) error {
+	var project models.Project
+	if err := ps.db.DB.Where("id = ?", projectID).First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrProjectNotFound
+		}
+		return fmt.Errorf("failed to find project: %w", err)
+	}
+
+	if project.CreatedBy != userID && !isAdminRole(userRole) {
+		return ErrProjectForbidden
+	}
+
+	var analysisIDs []uuid.UUID
+	err := ps.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Analysis{}).Where("project_id = ?", projectID).Pluck("id", &analysisIDs).Error; err != nil {
+			return fmt.Errorf("failed to list analyses: %w", err)
+		}
+
+		// Children of collaboration sessions go first so the session
+		// subquery still sees the sessions before they are soft-deleted.
+		sessionIDs := tx.Model(&models.Session{}).Select("id").Where("project_id = ?", projectID)
+		if err := tx.Where("session_id IN (?)", sessionIDs).Delete(&models.Annotation{}).Error; err != nil {
+			return fmt.Errorf("failed to delete annotations: %w", err)
+		}
+		if err := tx.Where("session_id IN (?)", sessionIDs).Delete(&models.Participant{}).Error; err != nil {
+			return fmt.Errorf("failed to delete participants: %w", err)
+		}
+
+		dependents := []struct {
+			model interface{}
+			name  string
+		}{
+			{&models.Session{}, "sessions"},
+			{&models.Visualization{}, "visualizations"},
+			{&models.Analysis{}, "analyses"},
+		}
+		for _, dependent := range dependents {
+			if err := tx.Where("project_id = ?", projectID).Delete(dependent.model).Error; err != nil {
+				return fmt.Errorf("failed to delete %s: %w", dependent.name, err)
+			}
+		}
+
+		if err := tx.Delete(&project).Error; err != nil {
+			return fmt.Errorf("failed to delete project: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Cache cleanup is best-effort; stale entries expire on their own
+	if ps.cacheCleaner != nil && len(analysisIDs) > 0 {
+		if err := ps.cacheCleaner.CleanupAnalyses(analysisIDs); err != nil {
+			ps.logger.WithError(err).WithField("project_id", projectID).Warn("Failed to clean up cached analysis data")
+		}
+	}
+
+	ps.logger.WithFields(logrus.Fields{
+		"project_id": projectID,
+		"user_id":    userID,
+		"analyses":   len(analysisIDs),
+	}).Info("Project deleted")
+
+	return nil
+}
+
+// isAdminRole reports whether the role grants administrative access
+func isAdminRole(role string) bool {
+	return role == "admin" || role == "super_admin"
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+)
+
+type fakeCacheCleaner struct {
+	cleaned []uuid.UUID
+	err     error
+}
+
+func (f *fakeCacheCleaner) CleanupAnalyses(analysisIDs []uuid.UUID) error {
+	f.cleaned = append(f.cleaned, analysisIDs...)
+	return f.err
+}
+
+type projectFixture struct {
+	project       *models.Project
+	analysis      *models.Analysis
+	visualization *models.Visualization
+	session       *models.Session
+	participant   *models.Participant
+	annotation    *models.Annotation
+}
+
+func newProjectTestDB(t *testing.T) *gorm.DB {
+	return testutil.NewTestDB(t,
+		&models.User{},
+		&models.Project{},
+		&models.Analysis{},
+		&models.Visualization{},
+		&models.Session{},
+		&models.Participant{},
+		&models.Annotation{},
+	)
+}
+
+func seedProject(t *testing.T, db *gorm.DB, ownerID uuid.UUID) projectFixture {
+	t.Helper()
+
+	f := projectFixture{}
+	f.project = &models.Project{Name: "Project", Language: "go", CreatedBy: ownerID}
+	require.NoError(t, db.Create(f.project).Error)
+
+	f.analysis = &models.Analysis{ProjectID: f.project.ID, Status: models.AnalysisStatusCompleted}
+	require.NoError(t, db.Create(f.analysis).Error)
+
+	f.visualization = &models.Visualization{ProjectID: f.project.ID, Name: "Default"}
+	require.NoError(t, db.Create(f.visualization).Error)
+
+	f.session = &models.Session{ProjectID: f.project.ID, HostID: ownerID, Name: "Review"}
+	require.NoError(t, db.Create(f.session).Error)
+
+	f.participant = &models.Participant{SessionID: f.session.ID, UserID: ownerID, CursorData: "{}"}
+	require.NoError(t, db.Create(f.participant).Error)
+
+	f.annotation = &models.Annotation{SessionID: f.session.ID, UserID: ownerID, Content: "Looks good", Position: "{}"}
+	require.NoError(t, db.Create(f.annotation).Error)
+
+	return f
+}
+
+// assertSoftDeleted checks the record is hidden from normal queries but still stored
+func assertSoftDeleted(t *testing.T, db *gorm.DB, model interface{}, id uuid.UUID, deleted bool) {
+	t.Helper()
+
+	err := db.First(model, "id = ?", id).Error
+	if deleted {
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "%T should be soft-deleted", model)
+		assert.NoError(t, db.Unscoped().First(model, "id = ?", id).Error, "%T should still be stored", model)
+	} else {
+		assert.NoError(t, err, "%T should not be deleted", model)
+	}
+}
+
+func TestProjectService_DeleteProject(t *testing.T) {
+	ownerID := uuid.New()
+
+	t.Run("owner soft-deletes project and dependents", func(t *testing.T) {
+		db := newProjectTestDB(t)
+		cleaner := &fakeCacheCleaner{}
+		ps := NewProjectService(NewDatabaseServiceFromDB(db, nil, testutil.NewTestLogger()), cleaner, testutil.NewTestLogger())
+
+		target := seedProject(t, db, ownerID)
+		other := seedProject(t, db, ownerID)
+
+		require.NoError(t, ps.DeleteProject(target.project.ID, ownerID, "user"))
+
+		assertSoftDeleted(t, db, &models.Project{}, target.project.ID, true)
+		assertSoftDeleted(t, db, &models.Analysis{}, target.analysis.ID, true)
+		assertSoftDeleted(t, db, &models.Visualization{}, target.visualization.ID, true)
+		assertSoftDeleted(t, db, &models.Session{}, target.session.ID, true)
+		assertSoftDeleted(t, db, &models.Participant{}, target.participant.ID, true)
+		assertSoftDeleted(t, db, &models.Annotation{}, target.annotation.ID, true)
+
+		// Other projects are untouched
+		assertSoftDeleted(t, db, &models.Project{}, other.project.ID, false)
+		assertSoftDeleted(t, db, &models.Analysis{}, other.analysis.ID, false)
+		assertSoftDeleted(t, db, &models.Annotation{}, other.annotation.ID, false)
+
+		assert.Equal(t, []uuid.UUID{target.analysis.ID}, cleaner.cleaned)
+	})
+
+	t.Run("non-owner is forbidden", func(t *testing.T) {
+		db := newProjectTestDB(t)
+		ps := NewProjectService(NewDatabaseServiceFromDB(db, nil, testutil.NewTestLogger()), nil, testutil.NewTestLogger())
+		target := seedProject(t, db, ownerID)
+
+		err := ps.DeleteProject(target.project.ID, uuid.New(), "user")
+		assert.ErrorIs(t, err, ErrProjectForbidden)
+		assertSoftDeleted(t, db, &models.Project{}, target.project.ID, false)
+		assertSoftDeleted(t, db, &models.Analysis{}, target.analysis.ID, false)
+	})
+
+	t.Run("admin may delete any project", func(t *testing.T) {
+		db := newProjectTestDB(t)
+		ps := NewProjectService(NewDatabaseServiceFromDB(db, nil, testutil.NewTestLogger()), nil, testutil.NewTestLogger())
+		target := seedProject(t, db, ownerID)
+
+		require.NoError(t, ps.DeleteProject(target.project.ID, uuid.New(), "admin"))
+		assertSoftDeleted(t, db, &models.Project{}, target.project.ID, true)
+	})
+
+	t.Run("missing project", func(t *testing.T) {
+		db := newProjectTestDB(t)
+		ps := NewProjectService(NewDatabaseServiceFromDB(db, nil, testutil.NewTestLogger()), nil, testutil.NewTestLogger())
+
+		err := ps.DeleteProject(uuid.New(), ownerID, "user")
+		assert.ErrorIs(t, err, ErrProjectNotFound)
+	})
+
+	t.Run("cache cleanup failure does not fail deletion", func(t *testing.T) {
+		db := newProjectTestDB(t)
+		cleaner := &fakeCacheCleaner{err: errors.New("redis down")}
+		ps := NewProjectService(NewDatabaseServiceFromDB(db, nil, testutil.NewTestLogger()), cleaner, testutil.NewTestLogger())
+		target := seedProject(t, db, ownerID)
+
+		require.NoError(t, ps.DeleteProject(target.project.ID, ownerID, "user"))
+		assertSoftDeleted(t, db, &models.Project{}, target.project.ID, true)
+	})
+}
+
+func TestProjectService_CreateProjectBranch(t *testing.T) {
+	repo := newGitFixture(t)
+	ownerID := uuid.New()
+
+	tests := []struct {
+		name       string
+		fetcher    SourceFetcher
+		fallbacks  []string
+		repository string
+		branch     string
+		wantBranch string
+		wantErr    error
+	}{
+		{name: "falls back to master", fetcher: NewGitSourceFetcher(0), repository: repo, wantBranch: "master"},
+		{name: "explicit branch", fetcher: NewGitSourceFetcher(0), repository: repo, branch: "develop", wantBranch: "develop"},
+		{name: "missing branch", fetcher: NewGitSourceFetcher(0), repository: repo, branch: "main", wantErr: ErrBranchNotFound},
+		{name: "custom fallback order", fetcher: NewGitSourceFetcher(0), fallbacks: []string{"develop", "master"}, repository: repo, wantBranch: "develop"},
+		{name: "default branch when no fallback exists", fetcher: NewGitSourceFetcher(0), fallbacks: []string{"trunk"}, repository: repo, wantBranch: "master"},
+		{name: "unreachable repository", fetcher: NewGitSourceFetcher(0), repository: repo + "-missing", wantErr: ErrRepositoryUnreachable},
+		{name: "local repository", fetcher: NewGitSourceFetcher(0), repository: "file:///srv/other-project", wantErr: ErrUnsupportedRepository},
+		{name: "no fetcher skips validation", repository: repo, branch: "main", wantBranch: "main"},
+		{name: "no repository", fetcher: NewGitSourceFetcher(0), wantBranch: "main"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newProjectTestDB(t)
+			ps := NewProjectService(NewDatabaseServiceFromDB(db, nil, testutil.NewTestLogger()), nil, testutil.NewTestLogger())
+			if tt.fetcher != nil {
+				ps.SetSourceFetcher(tt.fetcher)
+			}
+			ps.SetBranchFallbacks(tt.fallbacks)
+
+			project := &models.Project{Name: "Project", Language: "go", Repository: tt.repository, Branch: tt.branch, CreatedBy: ownerID}
+			err := ps.CreateProject(context.Background(), project)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				var count int64
+				require.NoError(t, db.Model(&models.Project{}).Count(&count).Error)
+				assert.Zero(t, count)
+				return
+			}
+			require.NoError(t, err)
+
+			var stored models.Project
+			require.NoError(t, db.First(&stored, "id = ?", project.ID).Error)
+			assert.Equal(t, tt.wantBranch, stored.Branch)
+		})
+	}
+}
+
+func TestProjectService_UpdateProject(t *testing.T) {
+	repo := newGitFixture(t)
+	db := newProjectTestDB(t)
+	ps := NewProjectService(NewDatabaseServiceFromDB(db, nil, testutil.NewTestLogger()), nil, testutil.NewTestLogger())
+	ps.SetSourceFetcher(NewGitSourceFetcher(0))
+
+	ownerID := uuid.New()
+	project := &models.Project{Name: "Project", Language: "go", CreatedBy: ownerID}
+	require.NoError(t, db.Create(project).Error)
+
+	ctx := context.Background()
+
+	_, err := ps.UpdateProject(ctx, uuid.New(), ownerID, "user", ProjectUpdate{Name: "Renamed"})
+	assert.ErrorIs(t, err, ErrProjectNotFound)
+
+	_, err = ps.UpdateProject(ctx, project.ID, uuid.New(), "user", ProjectUpdate{Name: "Renamed"})
+	assert.ErrorIs(t, err, ErrProjectForbidden)
+
+	updated, err := ps.UpdateProject(ctx, project.ID, ownerID, "user", ProjectUpdate{Name: "Renamed", Repository: repo})
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", updated.Name)
+	assert.Equal(t, "master", updated.Branch)
+
+	_, err = ps.UpdateProject(ctx, project.ID, ownerID, "user", ProjectUpdate{Branch: "main"})
+	assert.ErrorIs(t, err, ErrBranchNotFound)
+
+	updated, err = ps.UpdateProject(ctx, project.ID, uuid.New(), "admin", ProjectUpdate{Branch: "develop"})
+	require.NoError(t, err)
+	assert.Equal(t, "develop", updated.Branch)
+
+	updated, err = ps.UpdateProject(ctx, project.ID, ownerID, "user", ProjectUpdate{
+		NotificationEmails:   []string{"dev@example.com", "ops@example.com"},
+		NotificationWebhooks: []string{"https://hooks.example.com/sa3d"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "dev@example.com,ops@example.com", updated.Settings.NotificationEmails)
+
+	_, err = ps.UpdateProject(ctx, project.ID, ownerID, "user", ProjectUpdate{NotificationEmails: []string{"ops"}})
+	assert.ErrorIs(t, err, models.ErrInvalidModel)
+
+	updated, err = ps.UpdateProject(ctx, project.ID, ownerID, "user", ProjectUpdate{NotificationWebhooks: []string{}})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Settings.NotificationWebhooks, "an empty list removes the webhooks")
+
+	updated, err = ps.UpdateProject(ctx, project.ID, ownerID, "user", ProjectUpdate{
+		EnabledLanguages: []string{"go", "python"},
+		MinSeverity:      "major",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "go,python", updated.Settings.EnabledLanguages)
+	assert.Equal(t, "major", updated.Settings.MinSeverity)
+
+	_, err = ps.UpdateProject(ctx, project.ID, ownerID, "user", ProjectUpdate{MinSeverity: "blocker"})
+	assert.ErrorIs(t, err, models.ErrInvalidModel)
+
+	updated, err = ps.UpdateProject(ctx, project.ID, ownerID, "user", ProjectUpdate{EnabledLanguages: []string{}})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Settings.EnabledLanguages, "an empty list enables every language")
+	assert.Equal(t, "major", updated.Settings.MinSeverity)
+
+	var stored models.Project
+	require.NoError(t, db.First(&stored, "id = ?", project.ID).Error)
+	assert.Equal(t, "Renamed", stored.Name)
+	assert.Equal(t, repo, stored.Repository)
+	assert.Equal(t, "develop", stored.Branch)
+	assert.Equal(t, "dev@example.com,ops@example.com", stored.Settings.NotificationEmails, "a failed update changes nothing")
+}
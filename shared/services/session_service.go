@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+// sessionTouchInterval limits how often a session's last-seen time is written
+const sessionTouchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// ListSessions returns the user's active, unexpired sessions, most recently
+// used first
+func (as *AuthService) ListSessions(userID uuid.UUID) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	err := as.db.ReadDB().
+		Where("user_id = ? AND is_active = ? AND expires_at > ?", userID, true, as.clock.Now()).
+		Order("COALESCE(last_seen_at, created_at) DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// GetSession returns the active session a token authenticates with. It
+// reads the primary, since the session may have just been created.
+func (as *AuthService) GetSession(token string) (*models.UserSession, error) {
+	var session models.UserSession
+	err := as.db.DB.Where("session_token = ? AND is_active = ?", token, true).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find session: %w", err)
+	}
+	return &session, nil
+}
+
+// RevokeSession deactivates one of the user's sessions
+func (as *AuthService) RevokeSession(userID, sessionID uuid.UUID) error {
+	result := as.db.DB.Model(&models.UserSession{}).
+		Where("id = ? AND user_id = ? AND is_active = ?", sessionID, userID, true).
+		Update("is_active", false)
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	as.logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"session_id": sessionID,
+	}).Info("Session revoked")
+	return nil
+}
+
+// RevokeAllOtherSessions deactivates every session of the user except the one
+// identified by currentToken and returns how many were revoked
+func (as *AuthService) RevokeAllOtherSessions(userID uuid.UUID, currentToken string) (int64, error) {
+	result := as.db.DB.Model(&models.UserSession{}).
+		Where("user_id = ? AND session_token <> ? AND is_active = ?", userID, currentToken, true).
+		Update("is_active", false)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+
+	as.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"revoked": result.RowsAffected,
+	}).Info("Other sessions revoked")
+	return result.RowsAffected, nil
+}
+
+// touchSession records session activity, at most once per sessionTouchInterval
+func (as *AuthService) touchSession(session *models.UserSession) {
+	now := as.clock.Now()
+	if session.LastSeenAt != nil && now.Sub(*session.LastSeenAt) < sessionTouchInterval {
+		return
+	}
+
+	if err := as.db.DB.Model(session).UpdateColumn("last_seen_at", now).Error; err != nil {
+		as.logger.WithError(err).WithField("session_id", session.ID).Warn("Failed to update session last-seen time")
+	}
+}
+
+// SessionPurgeResult reports the outcome of PurgeExpiredSessions
+type SessionPurgeResult struct {
+	Deleted     int64
+	Deactivated int64
+}
+
+// PurgeExpiredSessions permanently deletes sessions that expired more than
+// olderThan ago and deactivates remaining sessions of deactivated users
+func (as *AuthService) PurgeExpiredSessions(olderThan time.Duration) (*SessionPurgeResult, error) {
+	cutoff := as.clock.Now().Add(-olderThan)
+
+	deleted := as.db.DB.Unscoped().
+		Where("expires_at < ?", cutoff).
+		Delete(&models.UserSession{})
+	if deleted.Error != nil {
+		return nil, fmt.Errorf("failed to delete expired sessions: %w", deleted.Error)
+	}
+
+	inactiveUsers := as.db.DB.Model(&models.User{}).Select("id").Where("is_active = ?", false)
+	deactivated := as.db.DB.Model(&models.UserSession{}).
+		Where("is_active = ? AND user_id IN (?)", true, inactiveUsers).
+		Update("is_active", false)
+	if deactivated.Error != nil {
+		return nil, fmt.Errorf("failed to deactivate sessions of inactive users: %w", deactivated.Error)
+	}
+
+	result := &SessionPurgeResult{
+		Deleted:     deleted.RowsAffected,
+		Deactivated: deactivated.RowsAffected,
+	}
+
+	if result.Deleted > 0 || result.Deactivated > 0 {
+		as.logger.WithFields(logrus.Fields{
+			"deleted":     result.Deleted,
+			"deactivated": result.Deactivated,
+		}).Info("Purged expired sessions")
+	}
+	return result, nil
+}
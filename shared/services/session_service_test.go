@@ -0,0 +1,164 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+func addSession(t *testing.T, db *gorm.DB, userID uuid.UUID, token string, expiresAt time.Time) *models.UserSession {
+	t.Helper()
+
+	session := &models.UserSession{
+		UserID:       userID,
+		SessionToken: token,
+		RefreshToken: "refresh-" + token,
+		ExpiresAt:    expiresAt,
+		IPAddress:    "10.0.0.1",
+		UserAgent:    "test-agent",
+		IsActive:     true,
+	}
+	require.NoError(t, db.Create(session).Error)
+	return session
+}
+
+func TestAuthService_ListSessions(t *testing.T) {
+	as, db := newAdminTestService(t)
+	user, current := seedUserWithSession(t, db, "alice", "user")
+	other, _ := seedUserWithSession(t, db, "bob", "user")
+
+	laptop := addSession(t, db, user.ID, "laptop", time.Now().Add(time.Hour))
+	addSession(t, db, user.ID, "expired", time.Now().Add(-time.Hour))
+	revoked := addSession(t, db, user.ID, "revoked", time.Now().Add(time.Hour))
+	require.NoError(t, as.RevokeSession(user.ID, revoked.ID))
+
+	// Validating a token records activity on that session
+	_, err := as.ValidateToken("laptop")
+	require.NoError(t, err)
+
+	sessions, err := as.ListSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	assert.Equal(t, laptop.ID, sessions[0].ID, "most recently seen first")
+	require.NotNil(t, sessions[0].LastSeenAt)
+	assert.Equal(t, "10.0.0.1", sessions[0].IPAddress)
+	assert.Equal(t, "test-agent", sessions[0].UserAgent)
+	assert.Equal(t, current, sessions[1].SessionToken)
+
+	otherSessions, err := as.ListSessions(other.ID)
+	require.NoError(t, err)
+	assert.Len(t, otherSessions, 1)
+}
+
+func TestAuthService_ValidateToken_Expired(t *testing.T) {
+	as, db := newAdminTestService(t)
+	user, _ := seedUserWithSession(t, db, "alice", "user")
+	addSession(t, db, user.ID, "expired", time.Now().Add(-time.Hour))
+
+	_, err := as.ValidateToken("expired")
+	assert.ErrorIs(t, err, ErrTokenExpired)
+
+	_, err = as.ValidateToken("unknown")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestAuthService_GetSession(t *testing.T) {
+	as, db := newAdminTestService(t)
+	user, token := seedUserWithSession(t, db, "alice", "user")
+	revoked := addSession(t, db, user.ID, "revoked", time.Now().Add(time.Hour))
+	require.NoError(t, as.RevokeSession(user.ID, revoked.ID))
+
+	session, err := as.GetSession(token)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, session.UserID)
+
+	_, err = as.GetSession("revoked")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+	_, err = as.GetSession("unknown")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestAuthService_RevokeSession(t *testing.T) {
+	as, db := newAdminTestService(t)
+	user, _ := seedUserWithSession(t, db, "alice", "user")
+	other, otherToken := seedUserWithSession(t, db, "bob", "user")
+	phone := addSession(t, db, user.ID, "phone", time.Now().Add(time.Hour))
+
+	require.NoError(t, as.RevokeSession(user.ID, phone.ID))
+	_, err := as.ValidateToken("phone")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	assert.ErrorIs(t, as.RevokeSession(user.ID, phone.ID), ErrSessionNotFound, "already revoked")
+	assert.ErrorIs(t, as.RevokeSession(user.ID, uuid.New()), ErrSessionNotFound)
+
+	// Users cannot revoke sessions belonging to someone else
+	otherSessions, err := as.ListSessions(other.ID)
+	require.NoError(t, err)
+	require.Len(t, otherSessions, 1)
+	assert.ErrorIs(t, as.RevokeSession(user.ID, otherSessions[0].ID), ErrSessionNotFound)
+	_, err = as.ValidateToken(otherToken)
+	assert.NoError(t, err)
+}
+
+func TestAuthService_RevokeAllOtherSessions(t *testing.T) {
+	as, db := newAdminTestService(t)
+	user, current := seedUserWithSession(t, db, "alice", "user")
+	_, otherToken := seedUserWithSession(t, db, "bob", "user")
+	addSession(t, db, user.ID, "laptop", time.Now().Add(time.Hour))
+	addSession(t, db, user.ID, "phone", time.Now().Add(time.Hour))
+
+	revoked, err := as.RevokeAllOtherSessions(user.ID, current)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), revoked)
+
+	sessions, err := as.ListSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, current, sessions[0].SessionToken)
+
+	_, err = as.ValidateToken(current)
+	assert.NoError(t, err, "current session is kept")
+	_, err = as.ValidateToken(otherToken)
+	assert.NoError(t, err, "other users are unaffected")
+}
+
+func TestAuthService_PurgeExpiredSessions(t *testing.T) {
+	as, db := newAdminTestService(t)
+	user, activeToken := seedUserWithSession(t, db, "alice", "user")
+	inactive, inactiveToken := seedUserWithSession(t, db, "bob", "user")
+	require.NoError(t, db.Model(inactive).Update("is_active", false).Error)
+
+	longExpired := addSession(t, db, user.ID, "long-expired", time.Now().Add(-48*time.Hour))
+	recentlyExpired := addSession(t, db, user.ID, "recently-expired", time.Now().Add(-time.Hour))
+
+	result, err := as.PurgeExpiredSessions(24 * time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Deleted)
+	assert.Equal(t, int64(1), result.Deactivated)
+
+	var count int64
+	require.NoError(t, db.Unscoped().Model(&models.UserSession{}).Where("id = ?", longExpired.ID).Count(&count).Error)
+	assert.Zero(t, count, "sessions past the grace period are deleted")
+
+	require.NoError(t, db.Model(&models.UserSession{}).Where("id = ?", recentlyExpired.ID).Count(&count).Error)
+	assert.Equal(t, int64(1), count, "sessions within the grace period are kept")
+
+	var active, ofInactive models.UserSession
+	require.NoError(t, db.Where("session_token = ?", activeToken).First(&active).Error)
+	assert.True(t, active.IsActive, "active sessions are untouched")
+	require.NoError(t, db.Where("session_token = ?", inactiveToken).First(&ofInactive).Error)
+	assert.False(t, ofInactive.IsActive, "sessions of deactivated users are deactivated")
+
+	// A second run has nothing left to do
+	result, err = as.PurgeExpiredSessions(24 * time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, result.Deleted)
+	assert.Zero(t, result.Deactivated)
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ErrUnsupportedRepository is returned for repository URLs that aren't
+// remote http, https or ssh URLs, such as local paths and file:// URLs,
+// which would let users read repositories on the host
+var ErrUnsupportedRepository = errors.New("unsupported repository URL")
+
+// SourceFetcher looks up the branches of a project's repository
+type SourceFetcher interface {
+	// ListBranches returns the repository's branch names and the branch its
+	// HEAD points to, which is empty when the remote doesn't advertise it
+	ListBranches(ctx context.Context, repository string) (branches []string, defaultBranch string, err error)
+}
+
+// GitSourceFetcher lists branches with git ls-remote
+type GitSourceFetcher struct {
+	timeout time.Duration
+}
+
+// NewGitSourceFetcher creates a fetcher that gives up on a repository after
+// timeout (0 means no limit beyond the caller's context)
+func NewGitSourceFetcher(timeout time.Duration) *GitSourceFetcher {
+	return &GitSourceFetcher{timeout: timeout}
+}
+
+// ListBranches implements SourceFetcher
+func (f *GitSourceFetcher) ListBranches(ctx context.Context, repository string) ([]string, string, error) {
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+
+	if err := checkRepositoryURL(repository); err != nil {
+		return nil, "", err
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", "--symref", "--", repository, "HEAD", "refs/heads/*")
+	// Never prompt for credentials, and keep repositories to remote
+	// transports so a URL can't invoke remote helpers or reach the host's
+	// files, whatever checkRepositoryURL lets through
+	cmd.Env = append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ALLOW_PROTOCOL=http:https:ssh",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, "", fmt.Errorf("git ls-remote failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var branches []string
+	var defaultBranch string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		// Lines are "<sha>\trefs/heads/<branch>", plus
+		// "ref: refs/heads/<branch>\tHEAD" for the default branch
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 3 && fields[0] == "ref:" && fields[2] == "HEAD":
+			defaultBranch = strings.TrimPrefix(fields[1], "refs/heads/")
+		case len(fields) == 2 && strings.HasPrefix(fields[1], "refs/heads/"):
+			branches = append(branches, strings.TrimPrefix(fields[1], "refs/heads/"))
+		}
+	}
+	return branches, defaultBranch, scanner.Err()
+}
+
+// checkRepositoryURL accepts http, https and ssh URLs with a host, and the
+// scp-like [user@]host:path form git treats as ssh
+func checkRepositoryURL(repository string) error {
+	if strings.Contains(repository, "://") {
+		u, err := url.Parse(repository)
+		if err == nil && u.Host != "" && !strings.HasPrefix(u.Host, "-") {
+			switch u.Scheme {
+			case "http", "https", "ssh":
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrUnsupportedRepository, repository)
+	}
+	// transport::address names a remote helper rather than a host
+	host, path, ok := strings.Cut(repository, ":")
+	if !ok || host == "" || path == "" || strings.HasPrefix(path, ":") || strings.ContainsAny(host, "/\\") || strings.HasPrefix(host, "-") {
+		return fmt.Errorf("%w: %s", ErrUnsupportedRepository, repository)
+	}
+	return nil
+}
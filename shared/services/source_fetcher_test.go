@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGitFixture creates a repository whose default branch is master, with
+// an extra develop branch, and returns its URL. It is served over git's
+// dumb HTTP protocol, as local repositories are refused.
+func newGitFixture(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	require.NoError(t, os.Mkdir(work, 0o755))
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = work
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+		)
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+
+	git("init", "-q", "-b", "master")
+	require.NoError(t, os.WriteFile(filepath.Join(work, "main.go"), []byte("package main\n"), 0o644))
+	git("add", "main.go")
+	git("commit", "-q", "-m", "Initial commit")
+	git("branch", "develop")
+	git("clone", "-q", "--bare", ".", filepath.Join(dir, "repo.git"))
+	git("--git-dir", filepath.Join(dir, "repo.git"), "update-server-info")
+
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(server.Close)
+	return server.URL + "/repo.git"
+}
+
+func TestGitSourceFetcher_ListBranches(t *testing.T) {
+	repo := newGitFixture(t)
+	fetcher := NewGitSourceFetcher(10 * time.Second)
+
+	branches, defaultBranch, err := fetcher.ListBranches(context.Background(), repo)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"develop", "master"}, branches)
+	assert.Equal(t, "master", defaultBranch)
+
+	_, _, err = fetcher.ListBranches(context.Background(), repo+"-missing")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrUnsupportedRepository)
+}
+
+func TestGitSourceFetcher_RejectsLocalRepositories(t *testing.T) {
+	// A repository on the host, which a local URL would otherwise reach
+	local := t.TempDir()
+	require.NoError(t, exec.Command("git", "init", "-q", local).Run())
+	fetcher := NewGitSourceFetcher(10 * time.Second)
+
+	for _, repository := range []string{
+		local,
+		"file://" + local,
+		"./relative/repo",
+		"../repo",
+		"git://example.com/repo.git",
+		"ext::sh -c touch% /tmp/pwned",
+		"ssh://-oProxyCommand=touch/repo",
+		"-uhelp",
+		"",
+	} {
+		t.Run(repository, func(t *testing.T) {
+			_, _, err := fetcher.ListBranches(context.Background(), repository)
+			assert.ErrorIs(t, err, ErrUnsupportedRepository)
+		})
+	}
+
+	for _, repository := range []string{
+		"https://github.com/example/repo.git",
+		"http://git.example.com/repo",
+		"ssh://git@example.com/repo.git",
+		"git@github.com:example/repo.git",
+	} {
+		assert.NoError(t, checkRepositoryURL(repository), repository)
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrSourceNotFound is returned for a file a SourceStore doesn't hold
+	ErrSourceNotFound = errors.New("source file not found")
+	// ErrInvalidSourcePath is returned for a project ID or file path that
+	// would name a file outside the project
+	ErrInvalidSourcePath = errors.New("invalid source path")
+)
+
+// DefaultInlineSourceLimit is the largest file content kept in the database
+// when there is a SourceStore; larger content goes to the store
+const DefaultInlineSourceLimit = 64 << 10
+
+// SourceStore holds the content of project source files outside the
+// database, so large repositories don't have to live in it. Files are
+// addressed by project ID and slash-separated path within the project.
+type SourceStore interface {
+	// Get returns the content of a file, or ErrSourceNotFound
+	Get(ctx context.Context, projectID, filePath string) ([]byte, error)
+	// Put stores the content of a file, replacing any previous content
+	Put(ctx context.Context, projectID, filePath string, content []byte) error
+	// Delete removes a file; removing a file that isn't stored is not an
+	// error
+	Delete(ctx context.Context, projectID, filePath string) error
+}
+
+// sourceKey returns the slash-separated key of a project's file, as in
+// "<project>/src/main.go"
+func sourceKey(projectID, filePath string) (string, error) {
+	if projectID == "" || projectID == "." || projectID == ".." || strings.ContainsAny(projectID, "/\\\x00") {
+		return "", fmt.Errorf("%w: project id %q", ErrInvalidSourcePath, projectID)
+	}
+	cleaned := path.Clean(filePath)
+	if filePath == "" || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") ||
+		path.IsAbs(cleaned) || strings.ContainsRune(cleaned, 0) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidSourcePath, filePath)
+	}
+	return projectID + "/" + cleaned, nil
+}
+
+// FileSourceStore keeps source files in a directory tree on the local
+// filesystem, one directory per project under its root
+type FileSourceStore struct {
+	root string
+}
+
+// NewFileSourceStore creates a store keeping files under root, which is
+// created when the first file is stored
+func NewFileSourceStore(root string) *FileSourceStore {
+	return &FileSourceStore{root: root}
+}
+
+// Get implements SourceStore
+func (s *FileSourceStore) Get(ctx context.Context, projectID, filePath string) ([]byte, error) {
+	name, err := s.name(projectID, filePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source file: %w", err)
+	}
+	return content, nil
+}
+
+// Put implements SourceStore. The content is written to a temporary file
+// renamed into place, so readers never see a partial file.
+func (s *FileSourceStore) Put(ctx context.Context, projectID, filePath string, content []byte) error {
+	name, err := s.name(projectID, filePath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("failed to create source directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".source-*")
+	if err != nil {
+		return fmt.Errorf("failed to create source file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write source file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write source file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("failed to write source file: %w", err)
+	}
+	return nil
+}
+
+// Delete implements SourceStore
+func (s *FileSourceStore) Delete(ctx context.Context, projectID, filePath string) error {
+	name, err := s.name(projectID, filePath)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete source file: %w", err)
+	}
+	return nil
+}
+
+// name returns the local file name of a project's file
+func (s *FileSourceStore) name(projectID, filePath string) (string, error) {
+	key, err := sourceKey(projectID, filePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSourceStore exercises the SourceStore contract on store
+func testSourceStore(t *testing.T, store SourceStore) {
+	ctx := context.Background()
+
+	_, err := store.Get(ctx, "project-1", "main.go")
+	assert.ErrorIs(t, err, ErrSourceNotFound)
+
+	require.NoError(t, store.Put(ctx, "project-1", "main.go", []byte("package main\n")))
+	require.NoError(t, store.Put(ctx, "project-1", "pkg/util/strings.go", []byte("package util\n")))
+	require.NoError(t, store.Put(ctx, "project-2", "main.go", []byte("package other\n")))
+
+	content, err := store.Get(ctx, "project-1", "main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(content))
+	content, err = store.Get(ctx, "project-1", "pkg/util/strings.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package util\n", string(content))
+	content, err = store.Get(ctx, "project-2", "main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package other\n", string(content), "projects don't share files")
+
+	require.NoError(t, store.Put(ctx, "project-1", "main.go", []byte("package main\n\nfunc main() {}\n")))
+	content, err = store.Get(ctx, "project-1", "main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc main() {}\n", string(content), "put replaces the content")
+
+	require.NoError(t, store.Put(ctx, "project-1", "docs/a file+notes.txt", []byte{}))
+	content, err = store.Get(ctx, "project-1", "docs/a file+notes.txt")
+	require.NoError(t, err)
+	assert.Empty(t, content)
+
+	require.NoError(t, store.Delete(ctx, "project-1", "main.go"))
+	_, err = store.Get(ctx, "project-1", "main.go")
+	assert.ErrorIs(t, err, ErrSourceNotFound)
+	assert.NoError(t, store.Delete(ctx, "project-1", "main.go"), "deleting a missing file is not an error")
+
+	for _, path := range []string{"", ".", "../project-2/main.go", "pkg/../../project-2/main.go", "/etc/passwd"} {
+		_, err := store.Get(ctx, "project-1", path)
+		assert.ErrorIs(t, err, ErrInvalidSourcePath, path)
+		assert.ErrorIs(t, store.Put(ctx, "project-1", path, nil), ErrInvalidSourcePath, path)
+	}
+	for _, projectID := range []string{"", "..", "a/b"} {
+		_, err := store.Get(ctx, projectID, "main.go")
+		assert.ErrorIs(t, err, ErrInvalidSourcePath, projectID)
+	}
+}
+
+func TestFileSourceStore(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "sources")
+	testSourceStore(t, NewFileSourceStore(root))
+
+	content, err := os.ReadFile(filepath.Join(root, "project-1", "pkg", "util", "strings.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package util\n", string(content), "files are laid out by project and path")
+
+	entries, err := os.ReadDir(filepath.Join(root, "project-1"))
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), ".source-", "temporary files are cleaned up")
+	}
+}
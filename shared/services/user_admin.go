@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+var ErrInvalidRole = errors.New("invalid role")
+
+// validRoles lists the roles an administrator may assign
+var validRoles = map[string]bool{
+	"user":        true,
+	"admin":       true,
+	"super_admin": true,
+}
+
+// UserFilter narrows the users returned by ListUsers
+type UserFilter struct {
+	Search   string `form:"search"`
+	Role     string `form:"role"`
+	IsActive *bool  `form:"is_active"`
+}
+
+// Page describes the requested page of a listing
+type Page struct {
+	Number int `form:"page"`
+	Size   int `form:"page_size"`
+}
+
+// UserList is a page of users
+type UserList struct {
+	Users    []models.User `json:"users"`
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+}
+
+// ListUsers returns a page of users matching the filter
+func (as *AuthService) ListUsers(filter UserFilter, page Page) (*UserList, error) {
+	if page.Number < 1 {
+		page.Number = 1
+	}
+	if page.Size < 1 {
+		page.Size = defaultUserPageSize
+	}
+	if page.Size > maxUserPageSize {
+		page.Size = maxUserPageSize
+	}
+
+	query := as.db.ReadDB().Model(&models.User{})
+	if filter.Search != "" {
+		pattern := "%" + strings.ToLower(filter.Search) + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(username) LIKE ?", pattern, pattern)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.IsActive != nil {
+		query = query.Where("is_active = ?", *filter.IsActive)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	var users []models.User
+	err := query.Order("created_at ASC").
+		Offset((page.Number - 1) * page.Size).
+		Limit(page.Size).
+		Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	for i := range users {
+		users[i].Password = ""
+	}
+
+	return &UserList{
+		Users:    users,
+		Total:    total,
+		Page:     page.Number,
+		PageSize: page.Size,
+	}, nil
+}
+
+// SetUserRole changes a user's role and revokes their active sessions
+func (as *AuthService) SetUserRole(userID uuid.UUID, role string) error {
+	if !validRoles[role] {
+		return ErrInvalidRole
+	}
+
+	if err := as.updateUserAndRevoke(userID, map[string]interface{}{"role": role}, true); err != nil {
+		return err
+	}
+
+	as.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"role":    role,
+	}).Info("User role changed")
+	return nil
+}
+
+// DeactivateUser disables a user account and revokes its active sessions
+func (as *AuthService) DeactivateUser(userID uuid.UUID) error {
+	if err := as.updateUserAndRevoke(userID, map[string]interface{}{"is_active": false}, true); err != nil {
+		return err
+	}
+
+	as.logger.WithField("user_id", userID).Info("User deactivated")
+	return nil
+}
+
+// ActivateUser re-enables a user account
+func (as *AuthService) ActivateUser(userID uuid.UUID) error {
+	if err := as.updateUserAndRevoke(userID, map[string]interface{}{"is_active": true}, false); err != nil {
+		return err
+	}
+
+	as.logger.WithField("user_id", userID).Info("User activated")
+	return nil
+}
+
+// UnlockUser clears a lockout caused by failed login attempts
+func (as *AuthService) UnlockUser(userID uuid.UUID) error {
+	updates := map[string]interface{}{
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	}
+	if err := as.updateUserAndRevoke(userID, updates, false); err != nil {
+		return err
+	}
+
+	as.logger.WithField("user_id", userID).Info("User unlocked")
+	return nil
+}
+
+// updateUserAndRevoke applies updates to a user and, if requested, revokes
+// all of the user's active sessions in the same transaction
+func (as *AuthService) updateUserAndRevoke(userID uuid.UUID, updates map[string]interface{}, revokeSessions bool) error {
+	return as.db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", userID).Updates(updates)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update user: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		if !revokeSessions {
+			return nil
+		}
+
+		if err := tx.Model(&models.UserSession{}).
+			Where("user_id = ? AND is_active = ?", userID, true).
+			Update("is_active", false).Error; err != nil {
+			return fmt.Errorf("failed to revoke user sessions: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+	"github.com/sa3d-modernized/sa3d/shared/testutil"
+)
+
+func newAdminTestService(t *testing.T) (*AuthService, *gorm.DB) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.UserSession{})
+	logger := testutil.NewTestLogger()
+	return NewAuthService(NewDatabaseServiceFromDB(db, nil, logger), logger), db
+}
+
+func seedUserWithSession(t *testing.T, db *gorm.DB, username, role string) (*models.User, string) {
+	t.Helper()
+
+	user := &models.User{
+		Email:    username + "@example.com",
+		Username: username,
+		Password: "hash",
+		Role:     role,
+		IsActive: true,
+	}
+	require.NoError(t, db.Create(user).Error)
+
+	token := "token-" + username
+	session := &models.UserSession{
+		UserID:       user.ID,
+		SessionToken: token,
+		RefreshToken: "refresh-" + username,
+		ExpiresAt:    time.Now().Add(time.Hour),
+		IsActive:     true,
+	}
+	require.NoError(t, db.Create(session).Error)
+
+	return user, token
+}
+
+func TestAuthService_SetUserRole(t *testing.T) {
+	as, db := newAdminTestService(t)
+	user, token := seedUserWithSession(t, db, "alice", "user")
+	_, otherToken := seedUserWithSession(t, db, "bob", "user")
+
+	_, err := as.ValidateToken(token)
+	require.NoError(t, err)
+
+	require.NoError(t, as.SetUserRole(user.ID, "admin"))
+
+	updated, err := as.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", updated.Role)
+
+	_, err = as.ValidateToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "existing sessions must be revoked")
+
+	_, err = as.ValidateToken(otherToken)
+	assert.NoError(t, err, "other users' sessions are untouched")
+
+	assert.ErrorIs(t, as.SetUserRole(user.ID, "root"), ErrInvalidRole)
+	assert.ErrorIs(t, as.SetUserRole(uuid.New(), "admin"), ErrUserNotFound)
+}
+
+func TestAuthService_DeactivateAndActivateUser(t *testing.T) {
+	as, db := newAdminTestService(t)
+	user, token := seedUserWithSession(t, db, "carol", "user")
+
+	require.NoError(t, as.DeactivateUser(user.ID))
+
+	found, err := as.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.False(t, found.IsActive)
+
+	_, err = as.ValidateToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	require.NoError(t, as.ActivateUser(user.ID))
+	found, err = as.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, found.IsActive)
+
+	assert.ErrorIs(t, as.DeactivateUser(uuid.New()), ErrUserNotFound)
+}
+
+func TestAuthService_UnlockUser(t *testing.T) {
+	as, db := newAdminTestService(t)
+	user, _ := seedUserWithSession(t, db, "dave", "user")
+
+	lockedUntil := time.Now().Add(time.Hour)
+	require.NoError(t, db.Model(user).Updates(map[string]interface{}{
+		"failed_login_attempts": 5,
+		"locked_until":          lockedUntil,
+	}).Error)
+
+	require.NoError(t, as.UnlockUser(user.ID))
+
+	found, err := as.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, found.FailedLoginAttempts)
+	assert.Nil(t, found.LockedUntil)
+}
+
+func TestAuthService_ListUsers(t *testing.T) {
+	as, db := newAdminTestService(t)
+	seedUserWithSession(t, db, "erin", "user")
+	seedUserWithSession(t, db, "frank", "admin")
+	inactive, _ := seedUserWithSession(t, db, "grace", "user")
+	require.NoError(t, as.DeactivateUser(inactive.ID))
+
+	active := true
+	tests := []struct {
+		name      string
+		filter    UserFilter
+		page      Page
+		wantTotal int64
+		wantCount int
+	}{
+		{"all users", UserFilter{}, Page{}, 3, 3},
+		{"search by username", UserFilter{Search: "FRA"}, Page{}, 1, 1},
+		{"filter by role", UserFilter{Role: "user"}, Page{}, 2, 2},
+		{"active only", UserFilter{IsActive: &active}, Page{}, 2, 2},
+		{"paged", UserFilter{}, Page{Number: 2, Size: 2}, 3, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := as.ListUsers(tt.filter, tt.page)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTotal, list.Total)
+			assert.Len(t, list.Users, tt.wantCount)
+			for _, u := range list.Users {
+				assert.Empty(t, u.Password)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Package testutil provides helpers shared by tests across the SA3D services.
+package testutil
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/sa3d-modernized/sa3d/shared/models"
+)
+
+var dbCounter int64
+
+func init() {
+	// DatabaseService.SetUserContext sets PostgreSQL RLS variables through
+	// set_config(name, value, is_local); emulate it as a no-op returning value.
+	gosqlite.MustRegisterDeterministicScalarFunction("set_config", 3, func(ctx *gosqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return args[1], nil
+	})
+}
+
+// NewTestDB opens an isolated in-memory SQLite database and creates the
+// tables for the given models. The database is closed when the test ends.
+func NewTestDB(t testing.TB, tables ...interface{}) *gorm.DB {
+	t.Helper()
+
+	name := fmt.Sprintf("file:testdb%d?mode=memory&cache=shared", atomic.AddInt64(&dbCounter, 1))
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := models.UseUTC(db); err != nil {
+		t.Fatalf("failed to configure test database timestamps: %v", err)
+	}
+
+	for _, table := range tables {
+		// SQLite can't evaluate the Postgres gen_random_uuid() default;
+		// IDs are assigned in BaseModel.BeforeCreate anyway.
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(table); err != nil {
+			t.Fatalf("failed to parse model %T: %v", table, err)
+		}
+		if field := stmt.Schema.LookUpField("ID"); field != nil {
+			field.HasDefaultValue = false
+			field.DefaultValue = ""
+			field.DefaultValueInterface = nil
+		}
+		if err := db.Migrator().CreateTable(table); err != nil {
+			t.Fatalf("failed to create table for %T: %v", table, err)
+		}
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	return db
+}
+
+// NewTestLogger returns a logger that suppresses output during tests
+func NewTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	return logger
+}
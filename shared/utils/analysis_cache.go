@@ -0,0 +1,10 @@
+package utils
+
+import "fmt"
+
+// ComparisonCacheIndexKey names the Redis set indexing the memoized
+// comparisons an analysis takes part in. Whoever deletes the analysis
+// deletes the set's members along with it.
+func ComparisonCacheIndexKey(analysisID string) string {
+	return fmt.Sprintf("analysis:compare:index:%s", analysisID)
+}
@@ -30,20 +30,21 @@ func (e *AppError) Unwrap() error {
 
 // Common error codes
 const (
-	ErrCodeValidation      = "VALIDATION_ERROR"
-	ErrCodeNotFound        = "NOT_FOUND"
-	ErrCodeUnauthorized    = "UNAUTHORIZED"
-	ErrCodeForbidden       = "FORBIDDEN"
-	ErrCodeConflict        = "CONFLICT"
-	ErrCodeInternal        = "INTERNAL_ERROR"
-	ErrCodeBadRequest      = "BAD_REQUEST"
-	ErrCodeTimeout         = "TIMEOUT"
-	ErrCodeRateLimit       = "RATE_LIMIT"
-	ErrCodeServiceDown     = "SERVICE_UNAVAILABLE"
-	ErrCodeInvalidToken    = "INVALID_TOKEN"
-	ErrCodeExpiredToken    = "EXPIRED_TOKEN"
-	ErrCodeDatabaseError   = "DATABASE_ERROR"
-	ErrCodeExternalService = "EXTERNAL_SERVICE_ERROR"
+	ErrCodeValidation       = "VALIDATION_ERROR"
+	ErrCodeNotFound         = "NOT_FOUND"
+	ErrCodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	ErrCodeUnauthorized     = "UNAUTHORIZED"
+	ErrCodeForbidden        = "FORBIDDEN"
+	ErrCodeConflict         = "CONFLICT"
+	ErrCodeInternal         = "INTERNAL_ERROR"
+	ErrCodeBadRequest       = "BAD_REQUEST"
+	ErrCodeTimeout          = "TIMEOUT"
+	ErrCodeRateLimit        = "RATE_LIMIT"
+	ErrCodeServiceDown      = "SERVICE_UNAVAILABLE"
+	ErrCodeInvalidToken     = "INVALID_TOKEN"
+	ErrCodeExpiredToken     = "EXPIRED_TOKEN"
+	ErrCodeDatabaseError    = "DATABASE_ERROR"
+	ErrCodeExternalService  = "EXTERNAL_SERVICE_ERROR"
 )
 
 // NewAppError creates a new application error
@@ -79,6 +80,11 @@ func NewNotFoundError(resource string) *AppError {
 	return NewAppError(ErrCodeNotFound, fmt.Sprintf("%s not found", resource), http.StatusNotFound, nil)
 }
 
+// NewMethodNotAllowedError creates a method not allowed error
+func NewMethodNotAllowedError(method string) *AppError {
+	return NewAppError(ErrCodeMethodNotAllowed, fmt.Sprintf("Method %s not allowed", method), http.StatusMethodNotAllowed, nil)
+}
+
 // NewUnauthorizedError creates an unauthorized error
 func NewUnauthorizedError(message string) *AppError {
 	if message == "" {
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// HALMediaType is the Accept value asking for responses with links
+const HALMediaType = "application/hal+json"
+
+// ForwardedPrefixHeader carries the path a proxy mounts a backend's routes
+// under, such as "/api/v1"
+const ForwardedPrefixHeader = "X-Forwarded-Prefix"
+
+// Link is a related resource of a response. Method is set for links that
+// act on the resource rather than fetch it.
+type Link struct {
+	Href   string `json:"href"`
+	Method string `json:"method,omitempty"`
+}
+
+// Links are a response's related resources by relation, serialized as its
+// "_links" section
+type Links map[string]Link
+
+// LinksRequested reports whether the client asked for links, by accepting
+// application/hal+json or with a truthy "links" query parameter
+func LinksRequested(r *http.Request) bool {
+	if requested, err := strconv.ParseBool(r.URL.Query().Get("links")); err == nil {
+		return requested
+	}
+	for _, value := range r.Header.Values("Accept") {
+		for _, accepted := range strings.Split(value, ",") {
+			mediaType, _, err := mime.ParseMediaType(accepted)
+			if err == nil && mediaType == HALMediaType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BaseURL returns the absolute URL the client reached the service at, as in
+// "https://api.example.com/api/v1". The X-Forwarded-Proto, X-Forwarded-Host
+// and X-Forwarded-Prefix headers of a proxy take precedence over the
+// request's own scheme and host; whoever serves clients directly must drop
+// them from requests a trusted proxy didn't send.
+func BaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Forwarded-Proto"))); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host := r.Host
+	if forwarded := strings.TrimSpace(r.Header.Get("X-Forwarded-Host")); forwarded != "" {
+		host = forwarded
+	}
+	return scheme + "://" + host + ForwardedPrefix(r)
+}
+
+// ForwardedPrefix returns the request's X-Forwarded-Prefix with a leading
+// and no trailing slash, or "" when there is none
+func ForwardedPrefix(r *http.Request) string {
+	prefix := strings.Trim(strings.TrimSpace(r.Header.Get(ForwardedPrefixHeader)), "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
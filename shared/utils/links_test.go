@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinksRequested(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		want   bool
+	}{
+		{"plain JSON", "/analysis/status/a1", "application/json", false},
+		{"HAL accepted", "/analysis/status/a1", "application/json, application/hal+json;q=0.9", true},
+		{"query flag", "/analysis/status/a1?links=true", "", true},
+		{"query flag overrides Accept", "/analysis/status/a1?links=0", HALMediaType, false},
+		{"malformed query flag", "/analysis/status/a1?links=maybe", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			assert.Equal(t, tt.want, LinksRequested(req))
+		})
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	req := httptest.NewRequest("GET", "/projects", nil)
+	assert.Equal(t, "http://example.com", BaseURL(req))
+
+	req.TLS = &tls.ConnectionState{}
+	assert.Equal(t, "https://example.com", BaseURL(req))
+
+	req.Header.Set("X-Forwarded-Proto", "HTTP")
+	req.Header.Set("X-Forwarded-Host", "api.example.com:8443")
+	req.Header.Set(ForwardedPrefixHeader, "api/v1/")
+	assert.Equal(t, "http://api.example.com:8443/api/v1", BaseURL(req))
+
+	req.Header.Set("X-Forwarded-Proto", "javascript")
+	assert.Equal(t, "https://api.example.com:8443/api/v1", BaseURL(req), "unknown schemes are ignored")
+}
@@ -5,14 +5,28 @@ import (
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Defaults for rotated log files
+const (
+	defaultLogMaxSizeMB  = 100
+	defaultLogMaxBackups = 5
+	defaultLogMaxAgeDays = 30
 )
 
 // LoggerConfig contains logger configuration
 type LoggerConfig struct {
 	Level      string
 	Format     string
-	Output     string
+	Output     string // "stdout", "stderr" or a file path
 	TimeFormat string
+
+	// Rotation settings, used when Output is a file path
+	MaxSizeMB  int  // size that triggers rotation
+	MaxBackups int  // rotated files kept
+	MaxAgeDays int  // days rotated files are kept
+	Compress   bool // gzip rotated files
 }
 
 // NewLogger creates a new configured logger
@@ -41,18 +55,41 @@ func NewLogger(config LoggerConfig) *logrus.Logger {
 	
 	// Set output
 	switch strings.ToLower(config.Output) {
-	case "stdout":
+	case "", "stdout":
 		logger.SetOutput(os.Stdout)
 	case "stderr":
 		logger.SetOutput(os.Stderr)
 	default:
-		// Could add file output here
-		logger.SetOutput(os.Stdout)
+		logger.SetOutput(newRotatingFile(config))
 	}
+
+	logger.AddHook(MaskingHook{})
 	
 	return logger
 }
 
+// newRotatingFile returns a writer appending to config.Output and rotating it
+// by size, keeping a bounded number of old files
+func newRotatingFile(config LoggerConfig) *lumberjack.Logger {
+	file := &lumberjack.Logger{
+		Filename:   config.Output,
+		MaxSize:    config.MaxSizeMB,
+		MaxBackups: config.MaxBackups,
+		MaxAge:     config.MaxAgeDays,
+		Compress:   config.Compress,
+	}
+	if file.MaxSize <= 0 {
+		file.MaxSize = defaultLogMaxSizeMB
+	}
+	if file.MaxBackups <= 0 {
+		file.MaxBackups = defaultLogMaxBackups
+	}
+	if file.MaxAge <= 0 {
+		file.MaxAge = defaultLogMaxAgeDays
+	}
+	return file
+}
+
 // LoggerWithFields creates a logger entry with common fields
 func LoggerWithFields(logger *logrus.Logger, fields map[string]interface{}) *logrus.Entry {
 	return logger.WithFields(fields)
@@ -63,7 +100,7 @@ func LogError(logger *logrus.Logger, err error, message string, fields map[strin
 	if fields == nil {
 		fields = make(map[string]interface{})
 	}
-	fields["error"] = err.Error()
+	fields["error"] = MaskString(err.Error())
 	logger.WithFields(fields).Error(message)
 }
 
@@ -97,7 +134,7 @@ func LogDatabaseQuery(logger *logrus.Logger, query string, duration float64, row
 	}
 	
 	if err != nil {
-		fields["error"] = err.Error()
+		fields["error"] = MaskString(err.Error())
 		logger.WithFields(fields).Error("Database query failed")
 	} else {
 		logger.WithFields(fields).Debug("Database query executed")
@@ -114,7 +151,7 @@ func LogServiceCall(logger *logrus.Logger, service, method string, duration floa
 	fields["duration_ms"] = duration
 	
 	if err != nil {
-		fields["error"] = err.Error()
+		fields["error"] = MaskString(err.Error())
 		logger.WithFields(fields).Error("Service call failed")
 	} else {
 		logger.WithFields(fields).Info("Service call completed")
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogger_FileOutputRotates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "service.log")
+
+	logger := NewLogger(LoggerConfig{
+		Level:      "info",
+		Format:     "json",
+		Output:     path,
+		MaxSizeMB:  1,
+		MaxBackups: 3,
+	})
+	t.Cleanup(func() { logger.Out.(io.Closer).Close() })
+
+	// ~1.5MB of entries crosses the 1MB limit once
+	message := strings.Repeat("x", 1024)
+	for i := 0; i < 1500; i++ {
+		logger.WithField("i", i).Info(message)
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "active file plus one rotated backup")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, info.Size(), int64(1<<20))
+
+	// Entries are still JSON formatted
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	firstLine := strings.SplitN(string(data), "\n", 2)[0]
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(firstLine), &entry))
+	assert.Equal(t, message, entry["msg"])
+}
+
+func TestNewLogger_StandardOutputs(t *testing.T) {
+	assert.Equal(t, os.Stdout, NewLogger(LoggerConfig{Output: "stdout"}).Out)
+	assert.Equal(t, os.Stderr, NewLogger(LoggerConfig{Output: "STDERR"}).Out)
+	assert.Equal(t, os.Stdout, NewLogger(LoggerConfig{}).Out)
+}
+
+func TestMaskString(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"query string", "email=a%40b.com&password=hunter2&page=1", "email=a%40b.com&password=[REDACTED]&page=1"},
+		{"json", `{"email":"a@b.com","refresh_token":"abc.def"}`, `{"email":"a@b.com","refresh_token":"[REDACTED]"}`},
+		{"authorization header", "Authorization: Bearer eyJhbGci.x.y", "Authorization: [REDACTED]"},
+		{"error message", "login failed: secret=s3cr3t, user=bob", "login failed: secret=[REDACTED], user=bob"},
+		{"case and compound keys", "NEW_PASSWORD=x X-Auth-Token=y", "NEW_PASSWORD=[REDACTED] X-Auth-Token=[REDACTED]"},
+		{"api keys", "api_key=k1 X-API-Key: k2 access_key=k3", "api_key=[REDACTED] X-API-Key: [REDACTED] access_key=[REDACTED]"},
+		{"nothing sensitive", "status=ok&page=2", "status=ok&page=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, MaskString(tt.input))
+		})
+	}
+}
+
+func TestIsSensitiveKey(t *testing.T) {
+	for _, key := range []string{"password", "X-Auth-Token", "jwt_secret", "api_key", "access_key", "signing_key", "X-API-Key"} {
+		assert.True(t, IsSensitiveKey(key), key)
+	}
+	for _, key := range []string{"user", "bucket", "keys_per_page", "headers"} {
+		assert.False(t, IsSensitiveKey(key), key)
+	}
+}
+
+func TestMaskingHook(t *testing.T) {
+	var buf strings.Builder
+	logger := NewLogger(LoggerConfig{Level: "info", Format: "json"})
+	logger.SetOutput(&buf)
+
+	logger.WithFields(map[string]interface{}{
+		"password": "hunter2",
+		"query":    "token=abc123",
+		"headers":  map[string]string{"Authorization": "Bearer abc123", "Accept": "application/json"},
+		"user":     "bob",
+	}).WithError(errors.New("bad request: password=hunter2")).Warn("login with secret=hunter2 failed")
+
+	output := buf.String()
+	assert.NotContains(t, output, "hunter2")
+	assert.NotContains(t, output, "abc123")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(output), &entry))
+	assert.Equal(t, RedactedValue, entry["password"])
+	assert.Equal(t, "token="+RedactedValue, entry["query"])
+	assert.Equal(t, "application/json", entry["headers"].(map[string]interface{})["Accept"])
+	assert.Equal(t, "bob", entry["user"])
+	assert.Equal(t, "bad request: password="+RedactedValue, entry["error"])
+	assert.Equal(t, "login with secret="+RedactedValue+" failed", entry["msg"])
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RedactedValue replaces sensitive values in logs
+const RedactedValue = "[REDACTED]"
+
+// SensitiveLogKeys are the words marking a field or parameter as sensitive.
+// A key containing any of them, in any case, is masked, so new_password and
+// X-Auth-Token are covered too.
+var SensitiveLogKeys = []string{
+	"password", "token", "authorization", "secret", "api_key", "api-key", "apikey", "access_key", "access-key",
+}
+
+// sensitiveAssignment matches key=value, key: value and "key":"value" pairs
+// with a sensitive key, as found in query strings, headers, JSON and error
+// messages. An authorization scheme such as Bearer is masked with its value.
+var sensitiveAssignment = regexp.MustCompile(
+	`(?i)("?[\w-]*(?:` + strings.Join(SensitiveLogKeys, "|") + `)[\w-]*"?\s*[:=]\s*)` +
+		`("(?:[^"\\]|\\.)*"|(?:(?:bearer|basic)\s+)?[^\s&,;"'}\]]+)`)
+
+// IsSensitiveKey reports whether values under the key must not be logged:
+// keys containing one of SensitiveLogKeys and keys ending in "key", such as
+// signing_key or X-API-Key
+func IsSensitiveKey(key string) bool {
+	lower := strings.ToLower(key)
+	if strings.HasSuffix(lower, "key") {
+		return true
+	}
+	for _, word := range SensitiveLogKeys {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskString redacts the values of sensitive keys appearing in s
+func MaskString(s string) string {
+	// Most strings name no sensitive key at all
+	if !IsSensitiveKey(s) {
+		return s
+	}
+	return sensitiveAssignment.ReplaceAllStringFunc(s, func(match string) string {
+		parts := sensitiveAssignment.FindStringSubmatch(match)
+		if strings.HasPrefix(parts[2], `"`) {
+			return parts[1] + `"` + RedactedValue + `"`
+		}
+		return parts[1] + RedactedValue
+	})
+}
+
+// MaskFields returns a copy of fields with the values of sensitive keys
+// redacted and sensitive pairs masked inside strings, errors and nested maps
+func MaskFields(fields map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		if IsSensitiveKey(key) {
+			masked[key] = RedactedValue
+			continue
+		}
+		masked[key] = maskValue(value)
+	}
+	return masked
+}
+
+func maskValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return MaskString(v)
+	case error:
+		if masked := MaskString(v.Error()); masked != v.Error() {
+			return masked
+		}
+		return v
+	case map[string]interface{}:
+		return MaskFields(v)
+	case logrus.Fields:
+		return logrus.Fields(MaskFields(v))
+	case map[string]string:
+		masked := make(map[string]string, len(v))
+		for key, s := range v {
+			if IsSensitiveKey(key) {
+				masked[key] = RedactedValue
+			} else {
+				masked[key] = MaskString(s)
+			}
+		}
+		return masked
+	}
+	return value
+}
+
+// MaskingHook masks sensitive data in every entry's message and fields
+// before it is written
+type MaskingHook struct{}
+
+// Levels implements logrus.Hook
+func (MaskingHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (MaskingHook) Fire(entry *logrus.Entry) error {
+	entry.Data = MaskFields(entry.Data)
+	entry.Message = MaskString(entry.Message)
+	return nil
+}
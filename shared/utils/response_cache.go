@@ -0,0 +1,10 @@
+package utils
+
+import "fmt"
+
+// ResponseCacheTagKey names the Redis set indexing the gateway's cached
+// responses for a route parameter value, e.g. every cached read of one
+// analysis. Services delete the set's members when that resource changes.
+func ResponseCacheTagKey(param, value string) string {
+	return fmt.Sprintf("gateway:response:tag:%s:%s", param, value)
+}
@@ -1,9 +1,7 @@
 package utils
 
 import (
-	"crypto/rand"
 	"crypto/sha256"
-	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"os"
@@ -19,20 +17,30 @@ const (
 	DefaultJWTSecretLength = 32
 	// MinJWTSecretLength is the absolute minimum length for JWT secrets
 	MinJWTSecretLength = 16
+	// MinInternalTokenLength is the minimum length of the token backends
+	// authenticate to internal gateway endpoints with
+	MinInternalTokenLength = 32
 )
 
 // SecretManager handles secure secret management
 type SecretManager struct {
+	clock  Clock
 	logger *logrus.Logger
 }
 
 // NewSecretManager creates a new secret manager
 func NewSecretManager(logger *logrus.Logger) *SecretManager {
 	return &SecretManager{
+		clock:  SystemClock{},
 		logger: logger,
 	}
 }
 
+// SetClock sets the clock secret rotation is dated by
+func (sm *SecretManager) SetClock(clock Clock) {
+	sm.clock = clock
+}
+
 // GetJWTSecret retrieves or generates a secure JWT secret
 func (sm *SecretManager) GetJWTSecret() (string, error) {
 	// Try to get from environment first
@@ -55,6 +63,18 @@ func (sm *SecretManager) GetJWTSecret() (string, error) {
 	return secret, nil
 }
 
+// GetInternalToken retrieves the token backends present to internal
+// gateway endpoints from INTERNAL_AUTH_TOKEN. It returns "" when unset,
+// leaving those endpoints disabled; no token is generated, as backends
+// would have no way to learn it.
+func (sm *SecretManager) GetInternalToken() (string, error) {
+	token := os.Getenv("INTERNAL_AUTH_TOKEN")
+	if token != "" && len(token) < MinInternalTokenLength {
+		return "", fmt.Errorf("internal auth token must be at least %d characters long", MinInternalTokenLength)
+	}
+	return token, nil
+}
+
 // GetDatabaseCredentials retrieves secure database credentials
 func (sm *SecretManager) GetDatabaseCredentials() (host, port, user, password, dbname, sslmode string, err error) {
 	host = sm.getEnvOrDefault("DB_HOST", "localhost")
@@ -93,6 +113,14 @@ func (sm *SecretManager) GetDatabaseCredentials() (host, port, user, password, d
 	return
 }
 
+// GetDatabaseReplicaCredentials retrieves the optional read replica location.
+// The replica shares credentials with the primary; an empty host disables it.
+func (sm *SecretManager) GetDatabaseReplicaCredentials() (host, port string) {
+	host = os.Getenv("DB_REPLICA_HOST")
+	port = sm.getEnvOrDefault("DB_REPLICA_PORT", sm.getEnvOrDefault("DB_PORT", "5432"))
+	return
+}
+
 // GetRedisCredentials retrieves Redis connection details
 func (sm *SecretManager) GetRedisCredentials() (addr, password string, db int, err error) {
 	host := sm.getEnvOrDefault("REDIS_HOST", "localhost")
@@ -141,11 +169,7 @@ func (sm *SecretManager) validateJWTSecret(secret string) error {
 
 // generateSecureSecret generates a cryptographically secure random secret
 func (sm *SecretManager) generateSecureSecret(length int) (string, error) {
-	bytes := make([]byte, length)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return base64.URLEncoding.EncodeToString(bytes)[:length], nil
+	return GenerateRandomString(length)
 }
 
 // getEnvOrDefault gets an environment variable or returns a default value
@@ -183,10 +207,11 @@ type SecretRotationInfo struct {
 
 // GetSecretRotationInfo returns rotation information for audit purposes
 func (sm *SecretManager) GetSecretRotationInfo(secret string) SecretRotationInfo {
+	now := sm.clock.Now()
 	return SecretRotationInfo{
 		SecretHash:    sm.HashSecret(secret),
-		RotatedAt:     time.Now(),
-		ExpiresAt:     time.Now().Add(30 * 24 * time.Hour), // 30 days
+		RotatedAt:     now,
+		ExpiresAt:     now.Add(30 * 24 * time.Hour), // 30 days
 		RotationCount: 1,
 	}
 }
\ No newline at end of file
@@ -4,6 +4,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -45,6 +46,38 @@ func TestSecretManager_GetJWTSecret(t *testing.T) {
 	})
 }
 
+func TestSecretManager_GetInternalToken(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	sm := NewSecretManager(logger)
+
+	t.Run("disabled when not set", func(t *testing.T) {
+		os.Unsetenv("INTERNAL_AUTH_TOKEN")
+
+		token, err := sm.GetInternalToken()
+		require.NoError(t, err)
+		assert.Empty(t, token)
+	})
+
+	t.Run("uses environment variable", func(t *testing.T) {
+		valid := strings.Repeat("k", MinInternalTokenLength)
+		os.Setenv("INTERNAL_AUTH_TOKEN", valid)
+		defer os.Unsetenv("INTERNAL_AUTH_TOKEN")
+
+		token, err := sm.GetInternalToken()
+		require.NoError(t, err)
+		assert.Equal(t, valid, token)
+	})
+
+	t.Run("rejects short token", func(t *testing.T) {
+		os.Setenv("INTERNAL_AUTH_TOKEN", "too-short")
+		defer os.Unsetenv("INTERNAL_AUTH_TOKEN")
+
+		_, err := sm.GetInternalToken()
+		assert.Error(t, err)
+	})
+}
+
 func TestSecretManager_GetDatabaseCredentials(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.FatalLevel)
@@ -145,6 +178,46 @@ func TestSecretManager_GetRedisCredentials(t *testing.T) {
 	})
 }
 
+func TestSecretManager_GetDatabaseReplicaCredentials(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	sm := NewSecretManager(logger)
+
+	t.Run("replica disabled when host not set", func(t *testing.T) {
+		os.Clearenv()
+
+		host, port := sm.GetDatabaseReplicaCredentials()
+		assert.Equal(t, "", host)
+		assert.Equal(t, "5432", port)
+	})
+
+	t.Run("port falls back to primary port", func(t *testing.T) {
+		os.Setenv("DB_REPLICA_HOST", "replica")
+		os.Setenv("DB_PORT", "5433")
+		defer func() {
+			os.Unsetenv("DB_REPLICA_HOST")
+			os.Unsetenv("DB_PORT")
+		}()
+
+		host, port := sm.GetDatabaseReplicaCredentials()
+		assert.Equal(t, "replica", host)
+		assert.Equal(t, "5433", port)
+	})
+
+	t.Run("uses replica port when set", func(t *testing.T) {
+		os.Setenv("DB_REPLICA_HOST", "replica")
+		os.Setenv("DB_REPLICA_PORT", "6432")
+		defer func() {
+			os.Unsetenv("DB_REPLICA_HOST")
+			os.Unsetenv("DB_REPLICA_PORT")
+		}()
+
+		host, port := sm.GetDatabaseReplicaCredentials()
+		assert.Equal(t, "replica", host)
+		assert.Equal(t, "6432", port)
+	})
+}
+
 func TestSecretManager_validateJWTSecret(t *testing.T) {
 	logger := logrus.New()
 	logger.SetLevel(logrus.FatalLevel)
@@ -203,6 +276,13 @@ func TestSecretManager_generateSecureSecret(t *testing.T) {
 		// Should not contain characters that could cause issues in URLs or configs
 		assert.False(t, strings.ContainsAny(secret, " \t\n\r\"'`\\"))
 	})
+
+	t.Run("rejects invalid lengths", func(t *testing.T) {
+		for _, length := range []int{0, -32} {
+			_, err := sm.generateSecureSecret(length)
+			assert.ErrorIs(t, err, ErrInvalidLength, length)
+		}
+	})
 }
 
 func TestSecretManager_RotateJWTSecret(t *testing.T) {
@@ -256,4 +336,18 @@ func TestSecretManager_GetSecretRotationInfo(t *testing.T) {
 		assert.True(t, info.ExpiresAt.After(info.RotatedAt))
 		assert.Equal(t, 1, info.RotationCount)
 	})
+
+	t.Run("dated by the clock", func(t *testing.T) {
+		rotatedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+		clock := NewMockClock(rotatedAt)
+		sm := NewSecretManager(logger)
+		sm.SetClock(clock)
+
+		info := sm.GetSecretRotationInfo("test-secret")
+		assert.Equal(t, rotatedAt, info.RotatedAt)
+		assert.Equal(t, rotatedAt.Add(30*24*time.Hour), info.ExpiresAt)
+
+		clock.Advance(time.Hour)
+		assert.Equal(t, rotatedAt.Add(time.Hour), sm.GetSecretRotationInfo("test-secret").RotatedAt)
+	})
 }
\ No newline at end of file
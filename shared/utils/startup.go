@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Version and Commit identify the build. They are set at link time with
+// -ldflags "-X github.com/sa3d-modernized/sa3d/shared/utils.Version=..."; an
+// unset Commit falls back to the VCS revision Go stamps into the binary.
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// BuildCommit returns the commit the binary was built from, or "unknown"
+func BuildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// LogStartup logs the build and the effective configuration of a service
+// as it starts, with secrets redacted by RedactConfig
+func LogStartup(logger *logrus.Logger, service string, config interface{}) {
+	logger.WithFields(logrus.Fields{
+		"service":    service,
+		"version":    Version,
+		"commit":     BuildCommit(),
+		"go_version": runtime.Version(),
+		"config":     RedactConfig(config),
+	}).Infof("Starting %s", service)
+}
+
+// RedactConfig returns a configuration struct as nested maps keyed by the
+// fields' mapstructure names, fit for logging. Set values under sensitive
+// keys (see IsSensitiveKey) are replaced by RedactedValue and passwords
+// embedded in URLs are masked; empty ones are kept so a missing secret
+// still shows.
+func RedactConfig(config interface{}) map[string]interface{} {
+	redacted, ok := redactValue(reflect.ValueOf(config)).(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}
+	}
+	return redacted
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func redactValue(value reflect.Value) interface{} {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Type() == durationType {
+		return time.Duration(value.Int()).String()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		fields := make(map[string]interface{})
+		redactStruct(value, fields)
+		return fields
+	case reflect.Map:
+		entries := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			entries[key] = redactEntry(key, iter.Value())
+		}
+		return entries
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return []interface{}{}
+		}
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = redactValue(value.Index(i))
+		}
+		return items
+	case reflect.String:
+		return redactURL(value.String())
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil
+	}
+	return value.Interface()
+}
+
+// redactStruct adds the exported fields of a struct to fields; squashed
+// fields are flattened into it as mapstructure decodes them
+func redactStruct(value reflect.Value, fields map[string]interface{}) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+		if options == "squash" && field.Type.Kind() == reflect.Struct {
+			redactStruct(value.Field(i), fields)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = redactEntry(name, value.Field(i))
+	}
+}
+
+// credentialMapKeys name settings whose entries are all credentials, such as
+// the OTLP exporter headers carrying api-key or x-honeycomb-team
+var credentialMapKeys = []string{"headers"}
+
+// redactEntry redacts a value under key, wholly when the key is sensitive or
+// names a map of credentials
+func redactEntry(key string, value reflect.Value) interface{} {
+	if (IsSensitiveKey(key) || isCredentialMap(key, value)) && !value.IsZero() {
+		return RedactedValue
+	}
+	return redactValue(value)
+}
+
+func isCredentialMap(key string, value reflect.Value) bool {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return false
+		}
+		value = value.Elem()
+	}
+	return value.Kind() == reflect.Map && value.Len() > 0 && slices.Contains(credentialMapKeys, strings.ToLower(key))
+}
+
+// redactURL masks the password of a URL such as a connection string
+func redactURL(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	parsed, err := url.Parse(s)
+	if err != nil || parsed.User == nil {
+		return s
+	}
+	if _, ok := parsed.User.Password(); !ok {
+		return s
+	}
+	return parsed.Redacted()
+}
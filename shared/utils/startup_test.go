@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStartupConfig struct {
+	Server struct {
+		Port        string        `mapstructure:"port"`
+		ReadTimeout time.Duration `mapstructure:"read_timeout"`
+	} `mapstructure:"server"`
+	Auth struct {
+		JWTSecret string `mapstructure:"jwt_secret"`
+	} `mapstructure:"auth"`
+	Redis struct {
+		Addr     string `mapstructure:"addr"`
+		Password string `mapstructure:"password"`
+	} `mapstructure:"redis"`
+	Database struct {
+		Password string `mapstructure:"password"`
+		URL      string `mapstructure:"url"`
+	} `mapstructure:"database"`
+	S3 struct {
+		AccessKey string `mapstructure:"access_key"`
+		SecretKey string `mapstructure:"secret_key"`
+		Bucket    string `mapstructure:"bucket"`
+	} `mapstructure:"s3"`
+	APIKey   string            `mapstructure:"api_key"`
+	Brokers  []string          `mapstructure:"brokers"`
+	Headers  map[string]string `mapstructure:"headers"`
+	Enabled  *bool             `mapstructure:"enabled"`
+	Untagged int
+	internal string
+}
+
+func TestRedactConfig(t *testing.T) {
+	var config testStartupConfig
+	config.Server.Port = "8080"
+	config.Server.ReadTimeout = 15 * time.Second
+	config.Auth.JWTSecret = "jwt-signing-key"
+	config.Redis.Addr = "localhost:6379"
+	config.Redis.Password = "redis-pass"
+	config.Database.URL = "postgres://sa3d:db-pass@db:5432/sa3d"
+	config.Brokers = []string{"kafka:9092"}
+	config.S3.AccessKey = "AKIAEXAMPLE"
+	config.S3.Bucket = "sources"
+	config.APIKey = "service-api-key"
+	config.Headers = map[string]string{"api-key": "otlp-key", "x-honeycomb-team": "honeycomb-team-key"}
+	config.Untagged = 3
+	config.internal = "hidden"
+
+	redacted := RedactConfig(&config)
+
+	assert.Equal(t, map[string]interface{}{"port": "8080", "read_timeout": "15s"}, redacted["server"])
+	assert.Equal(t, map[string]interface{}{"jwt_secret": RedactedValue}, redacted["auth"])
+	assert.Equal(t, map[string]interface{}{"addr": "localhost:6379", "password": RedactedValue}, redacted["redis"])
+	assert.Equal(t, map[string]interface{}{
+		"password": "",
+		"url":      "postgres://sa3d:xxxxx@db:5432/sa3d",
+	}, redacted["database"])
+	assert.Equal(t, map[string]interface{}{
+		"access_key": RedactedValue,
+		"secret_key": "",
+		"bucket":     "sources",
+	}, redacted["s3"])
+	assert.Equal(t, RedactedValue, redacted["api_key"])
+	assert.Equal(t, []interface{}{"kafka:9092"}, redacted["brokers"])
+	assert.Equal(t, RedactedValue, redacted["headers"])
+	assert.Nil(t, redacted["enabled"])
+	assert.Equal(t, 3, redacted["Untagged"])
+	assert.NotContains(t, redacted, "internal")
+
+	encoded, err := json.Marshal(redacted)
+	require.NoError(t, err)
+	for _, secret := range []string{
+		"jwt-signing-key", "redis-pass", "db-pass", "AKIAEXAMPLE", "service-api-key", "otlp-key", "honeycomb-team-key",
+	} {
+		assert.NotContains(t, string(encoded), secret)
+	}
+}
+
+func TestRedactConfig_EmptyHeaders(t *testing.T) {
+	var config testStartupConfig
+	config.Headers = map[string]string{}
+
+	assert.Equal(t, map[string]interface{}{}, RedactConfig(config)["headers"])
+}
+
+func TestLogStartup(t *testing.T) {
+	var output bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&output)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	var config testStartupConfig
+	config.Server.Port = "8080"
+	config.Auth.JWTSecret = "jwt-signing-key"
+	LogStartup(logger, "api-gateway", config)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &entry))
+	assert.Equal(t, "Starting api-gateway", entry["msg"])
+	assert.Equal(t, Version, entry["version"])
+	assert.NotEmpty(t, entry["commit"])
+	assert.NotEmpty(t, entry["go_version"])
+	assert.Contains(t, output.String(), `"port":"8080"`)
+	assert.NotContains(t, output.String(), "jwt-signing-key")
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -11,13 +12,26 @@ import (
 	"github.com/google/uuid"
 )
 
-// GenerateRandomString generates a random string of specified length
+// MaxRandomStringLength is the longest string GenerateRandomString makes
+const MaxRandomStringLength = 1 << 16
+
+// ErrInvalidLength is returned for a random string length below 1 or above
+// MaxRandomStringLength
+var ErrInvalidLength = errors.New("invalid random string length")
+
+// GenerateRandomString generates a random string of specified length from
+// the URL-safe base64 alphabet
 func GenerateRandomString(length int) (string, error) {
-	bytes := make([]byte, length)
+	if length < 1 || length > MaxRandomStringLength {
+		return "", fmt.Errorf("%w: %d is not between 1 and %d", ErrInvalidLength, length, MaxRandomStringLength)
+	}
+
+	// Each character carries 6 random bits
+	bytes := make([]byte, (length*6+7)/8)
 	if _, err := rand.Read(bytes); err != nil {
 		return "", err
 	}
-	return base64.URLEncoding.EncodeToString(bytes)[:length], nil
+	return base64.RawURLEncoding.EncodeToString(bytes)[:length], nil
 }
 
 // ValidateEmail validates an email address
@@ -119,6 +133,18 @@ func RemoveDuplicates(slice []string) []string {
 	return result
 }
 
+// SplitList splits a comma-separated list, as stored in settings columns,
+// trimming its items and dropping empty ones
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // GetFileExtension returns the file extension from a path
 func GetFileExtension(path string) string {
 	parts := strings.Split(path, ".")
@@ -3,6 +3,7 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -74,6 +75,21 @@ func TestGenerateRandomString(t *testing.T) {
 			assert.NotEqual(t, str, str2)
 		})
 	}
+
+	t.Run("longest length", func(t *testing.T) {
+		str, err := GenerateRandomString(MaxRandomStringLength)
+		require.NoError(t, err)
+		assert.Len(t, str, MaxRandomStringLength)
+		assert.NotContains(t, str, "=")
+	})
+
+	for _, length := range []int{0, -1, MaxRandomStringLength + 1, math.MaxInt} {
+		t.Run(fmt.Sprintf("invalid_length_%d", length), func(t *testing.T) {
+			str, err := GenerateRandomString(length)
+			assert.ErrorIs(t, err, ErrInvalidLength)
+			assert.Empty(t, str)
+		})
+	}
 }
 
 func TestSanitizeString(t *testing.T) {
@@ -138,6 +154,24 @@ func TestRemoveDuplicates(t *testing.T) {
 	}
 }
 
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"items", "a,b,c", []string{"a", "b", "c"}},
+		{"spaces and empty items", " a , ,b,", []string{"a", "b"}},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SplitList(tt.input))
+		})
+	}
+}
+
 func TestGenerateSlug(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -242,4 +276,31 @@ func TestAppError(t *testing.T) {
 		assert.Equal(t, appErr, GetAppError(appErr))
 		assert.Nil(t, GetAppError(normalErr))
 	})
-}
\ No newline at end of file
+}
+
+func TestTraceIDFromTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		want        string
+		wantOK      bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{"future version with extra fields", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra", "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{"empty", "", "", false},
+		{"all-zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "", false},
+		{"all-zero parent ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", "", false},
+		{"upper case", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "", false},
+		{"short trace ID", "00-4bf92f3577b34da6-00f067aa0ba902b7-01", "", false},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "", false},
+		{"version 00 with extra fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := TraceIDFromTraceparent(tt.traceparent)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}